@@ -0,0 +1,229 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed accessibility.wgsl
+var accessibilityShader string
+
+// accessibilityFilter is the stage after the scene. The scene is copied into
+// scene, and a full screen pass remaps the colours and limits how much
+// brightness can change since the previous frame, which it keeps in history.
+type accessibilityFilter struct {
+	layout         *wgpu.BindGroupLayout
+	pipelineLayout *wgpu.PipelineLayout
+	pipeline       *wgpu.RenderPipeline
+	params         *wgpu.Buffer
+
+	scene        *wgpu.Texture
+	sceneView    *wgpu.TextureView
+	history      [2]*wgpu.Texture
+	historyViews [2]*wgpu.TextureView
+	bindGroups   [2]*wgpu.BindGroup
+	frame        int
+}
+
+const historyFormat = wgpu.TextureFormat_RGBA16Float
+
+func newAccessibilityFilter(s *State, cfg AccessibilityConfig) (f *accessibilityFilter, err error) {
+	if cfg.MaxLuminanceChange < 0 || cfg.MaxLuminanceChange > 1 {
+		return nil, fmt.Errorf("max luminance change %v out of range [0, 1]", cfg.MaxLuminanceChange)
+	}
+
+	f = &accessibilityFilter{}
+	defer func() {
+		if err != nil {
+			f.Release()
+		}
+	}()
+
+	shader := s.createShader("accessibility shader", accessibilityShader)
+	defer shader.Release()
+
+	texture := wgpu.TextureBindingLayout{
+		SampleType:    wgpu.TextureSampleType_UnfilterableFloat,
+		ViewDimension: wgpu.TextureViewDimension_2D,
+	}
+	f.layout, err = s.bindGroupLayout("accessibility",
+		bufferEntry(0, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		wgpu.BindGroupLayoutEntry{Binding: 1, Visibility: wgpu.ShaderStage_Fragment, Texture: texture},
+		wgpu.BindGroupLayoutEntry{Binding: 2, Visibility: wgpu.ShaderStage_Fragment, Texture: texture},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	f.pipelineLayout, err = s.pipelineLayout("accessibility", f.layout)
+	if err != nil {
+		return nil, err
+	}
+
+	f.pipeline, err = s.device.CreateRenderPipeline(&wgpu.RenderPipelineDescriptor{
+		Label:  "accessibility",
+		Layout: f.pipelineLayout,
+		Vertex: wgpu.VertexState{
+			Module:     shader,
+			EntryPoint: "main_vs",
+		},
+		Fragment: &wgpu.FragmentState{
+			Module:     shader,
+			EntryPoint: "main_fs",
+			Targets: []wgpu.ColorTargetState{
+				{Format: s.config.Format, WriteMask: wgpu.ColorWriteMask_All},
+				{Format: historyFormat, WriteMask: wgpu.ColorWriteMask_All},
+			},
+		},
+		Primitive: wgpu.PrimitiveState{
+			Topology: wgpu.PrimitiveTopology_TriangleList,
+		},
+		Multisample: wgpu.MultisampleState{
+			Count: 1,
+			Mask:  0xFFFFFFFF,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var colourblind uint32
+	if cfg.ColourblindSafe {
+		colourblind = 1
+	}
+	f.params = s.uniformBuffer("accessibility params", wgpu.ToBytes([]uint32{
+		colourblind, math.Float32bits(cfg.MaxLuminanceChange), 0, 0,
+	}))
+
+	return f, f.resize(s)
+}
+
+func (s *State) renderTexture(label string, format wgpu.TextureFormat) (*wgpu.Texture, *wgpu.TextureView, error) {
+	return s.sizedRenderTexture(label, format, s.config.Width, s.config.Height)
+}
+
+// sizedRenderTexture is renderTexture at a size of its own.
+func (s *State) sizedRenderTexture(label string, format wgpu.TextureFormat, width, height uint32) (*wgpu.Texture, *wgpu.TextureView, error) {
+	texture, err := s.device.CreateTexture(&wgpu.TextureDescriptor{
+		Label:         label,
+		Usage:         wgpu.TextureUsage_RenderAttachment | wgpu.TextureUsage_TextureBinding,
+		Dimension:     wgpu.TextureDimension_2D,
+		Size:          wgpu.Extent3D{Width: width, Height: height, DepthOrArrayLayers: 1},
+		Format:        format,
+		MipLevelCount: 1,
+		SampleCount:   1,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	view, err := texture.CreateView(nil)
+	if err != nil {
+		texture.Release()
+		return nil, nil, err
+	}
+	return texture, view, nil
+}
+
+// resize recreates the textures to match the swapchain.
+func (f *accessibilityFilter) resize(s *State) (err error) {
+	f.releaseTextures()
+
+	f.scene, f.sceneView, err = s.renderTexture("scene", s.config.Format)
+	if err != nil {
+		return err
+	}
+	for i := range f.history {
+		f.history[i], f.historyViews[i], err = s.renderTexture("history", historyFormat)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range f.bindGroups {
+		f.bindGroups[i], err = s.device.CreateBindGroup(&wgpu.BindGroupDescriptor{
+			Label:  "accessibility",
+			Layout: f.layout,
+			Entries: []wgpu.BindGroupEntry{
+				{Binding: 0, Buffer: f.params, Size: wgpu.WholeSize},
+				{Binding: 1, TextureView: f.sceneView},
+				{Binding: 2, TextureView: f.historyViews[i]},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *accessibilityFilter) input() *wgpu.TextureView {
+	return f.sceneView
+}
+
+// apply draws the filtered scene into view.
+func (f *accessibilityFilter) apply(s *State, encoder *commandEncoder, view *wgpu.TextureView) {
+	previous, next := f.frame%2, (f.frame+1)%2
+	pass := encoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
+		ColorAttachments: []wgpu.RenderPassColorAttachment{
+			attachColourToView(view, s.palette.clear()),
+			attachColourToView(f.historyViews[next], s.palette.clear()),
+		},
+	})
+	defer pass.Release()
+
+	pass.SetPipeline(f.pipeline)
+	pass.SetBindGroup(0, f.bindGroups[previous], nil)
+	pass.Draw(3, 1, 0, 0)
+	pass.End()
+
+	f.frame += 1
+}
+
+func (f *accessibilityFilter) releaseTextures() {
+	for i := range f.bindGroups {
+		if f.bindGroups[i] != nil {
+			f.bindGroups[i].Release()
+			f.bindGroups[i] = nil
+		}
+	}
+	for i := range f.history {
+		if f.historyViews[i] != nil {
+			f.historyViews[i].Release()
+			f.historyViews[i] = nil
+		}
+		if f.history[i] != nil {
+			f.history[i].Release()
+			f.history[i] = nil
+		}
+	}
+	if f.sceneView != nil {
+		f.sceneView.Release()
+		f.sceneView = nil
+	}
+	if f.scene != nil {
+		f.scene.Release()
+		f.scene = nil
+	}
+}
+
+func (f *accessibilityFilter) Release() {
+	f.releaseTextures()
+	if f.params != nil {
+		f.params.Release()
+		f.params = nil
+	}
+	if f.pipeline != nil {
+		f.pipeline.Release()
+		f.pipeline = nil
+	}
+	if f.pipelineLayout != nil {
+		f.pipelineLayout.Release()
+		f.pipelineLayout = nil
+	}
+	if f.layout != nil {
+		f.layout.Release()
+		f.layout = nil
+	}
+}
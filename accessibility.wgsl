@@ -0,0 +1,64 @@
+struct Params {
+  colourblind: u32,
+  maxChange: f32,
+};
+
+struct FragmentOutput {
+  @location(0) screen: vec4<f32>,
+  @location(1) history: vec4<f32>,
+};
+
+@group(0) @binding(0) var<uniform> params: Params;
+@group(0) @binding(1) var scene: texture_2d<f32>;
+@group(0) @binding(2) var history: texture_2d<f32>;
+
+// One triangle covering the whole screen.
+@vertex
+fn main_vs(@builtin(vertex_index) i: u32) -> @builtin(position) vec4<f32> {
+  let uv = vec2<f32>(f32((i << 1u) & 2u), f32(i & 2u));
+  return vec4<f32>(uv * 2.0 - 1.0, 0.0, 1.0);
+}
+
+@fragment
+fn main_fs(@builtin(position) pos: vec4<f32>) -> FragmentOutput {
+  let p = vec2<i32>(pos.xy);
+  var colour = textureLoad(scene, p, 0).rgb;
+  if params.colourblind == 1u {
+    colour = cividis(luminance(colour));
+  }
+
+  // Move only part of the way towards the new frame when the brightness
+  // would jump by more than the limit.
+  if params.maxChange > 0.0 {
+    let previous = textureLoad(history, p, 0).rgb;
+    let change = abs(luminance(colour) - luminance(previous));
+    if change > params.maxChange {
+      colour = mix(previous, colour, params.maxChange / change);
+    }
+  }
+
+  var output: FragmentOutput;
+  output.screen = vec4<f32>(colour, 1.0);
+  output.history = output.screen;
+  return output;
+}
+
+fn luminance(c: vec3<f32>) -> f32 {
+  return dot(c, vec3(0.2126, 0.7152, 0.0722));
+}
+
+// Cividis reads the same with the common forms of colour blindness and
+// increases steadily in brightness.
+fn cividis(t: f32) -> vec3<f32> {
+  let x = clamp(t, 0.0, 1.0) * 4.0;
+  if x < 1.0 {
+    return mix(vec3(0.0, 0.125, 0.302), vec3(0.255, 0.302, 0.42), x);
+  }
+  if x < 2.0 {
+    return mix(vec3(0.255, 0.302, 0.42), vec3(0.486, 0.482, 0.471), x - 1.0);
+  }
+  if x < 3.0 {
+    return mix(vec3(0.486, 0.482, 0.471), vec3(0.737, 0.686, 0.435), x - 2.0);
+  }
+  return mix(vec3(0.737, 0.686, 0.435), vec3(1.0, 0.918, 0.275), x - 3.0);
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// ageTracker is implemented by simulations that count how many generations
+// each cell has been alive, 0 for dead cells.
+type ageTracker interface {
+	Ages(s *State) ([]uint32, error)
+}
+
+// ageStats sums up the ages of the live cells. median and oldest are 0 when
+// nothing is alive.
+type ageStats struct {
+	live           int
+	mean           float64
+	median, oldest uint32
+}
+
+func summariseAges(ages []uint32) ageStats {
+	var live []uint32
+	total := 0.0
+	for _, a := range ages {
+		if a != 0 {
+			live = append(live, a)
+			total += float64(a)
+		}
+	}
+	if len(live) == 0 {
+		return ageStats{}
+	}
+	slices.Sort(live)
+	return ageStats{
+		live:   len(live),
+		mean:   total / float64(len(live)),
+		median: live[len(live)/2],
+		oldest: live[len(live)-1],
+	}
+}
+
+// handleAgeKey prints how old the live cells are with A.
+func (s *State) handleAgeKey(key glfw.Key, action glfw.Action) {
+	if key != glfw.KeyA || action != glfw.Press {
+		return
+	}
+	t, ok := s.sim.(ageTracker)
+	if !ok {
+		fmt.Println("this simulation doesn't track ages")
+		return
+	}
+	ages, err := t.Ages(s)
+	if err != nil {
+		fmt.Println("reading ages:", err)
+		return
+	}
+	a := summariseAges(ages)
+	fmt.Printf("%s live cells, %s generations old on average, median %s, oldest %s\n",
+		s.format.Count(int64(a.live)), s.format.Float(a.mean, 1),
+		s.format.Count(int64(a.median)), s.format.Count(int64(a.oldest)))
+}
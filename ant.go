@@ -0,0 +1,272 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed ant_compute.wgsl
+var antCompute string
+
+//go:embed ant_draw.wgsl
+var antDraw string
+
+// Ants is Langton's ant generalised to turmites: any number of ants walking
+// over a trail grid, turning according to a rule string.
+type Ants struct {
+	computeLayout   *wgpu.BindGroupLayout
+	drawLayout      *wgpu.BindGroupLayout
+	computePipeline *wgpu.ComputePipeline
+	cellPipeline    *wgpu.RenderPipeline
+	antPipeline     *wgpu.RenderPipeline
+	pipelineLayouts []*wgpu.PipelineLayout
+
+	params  *wgpu.Buffer
+	cells   *wgpu.Buffer
+	ants    *wgpu.Buffer
+	turns   *wgpu.Buffer
+	compute *wgpu.BindGroup
+	draw    *wgpu.BindGroup
+
+	vertices      *wgpu.Buffer
+	camera        *viewCamera
+	count         uint32
+	width, height int
+}
+
+// parseTurmiteRule converts a rule such as "RL" into the number of clockwise
+// quarter turns to make on each cell state.
+func parseTurmiteRule(rule string) ([]uint32, error) {
+	if rule == "" {
+		return nil, fmt.Errorf("empty turmite rule")
+	}
+	turns := make([]uint32, len(rule))
+	for i, c := range strings.ToUpper(rule) {
+		switch c {
+		case 'N':
+			turns[i] = 0
+		case 'R':
+			turns[i] = 1
+		case 'U':
+			turns[i] = 2
+		case 'L':
+			turns[i] = 3
+		default:
+			return nil, fmt.Errorf("turmite rule %q: unknown turn %q, want L, R, N or U", rule, c)
+		}
+	}
+	return turns, nil
+}
+
+func newAnts(s *State, cfg *Config) (sim Simulation, err error) {
+	turns, err := parseTurmiteRule(cfg.Ant.Rule)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Ant.Count < 1 {
+		return nil, fmt.Errorf("need at least one ant, got %d", cfg.Ant.Count)
+	}
+	if cfg.Ant.StepsPerFrame < 1 {
+		return nil, fmt.Errorf("ants need at least one step per frame, got %d", cfg.Ant.StepsPerFrame)
+	}
+
+	a := &Ants{
+		vertices: s.vertexBuffer,
+		camera:   s.camera,
+		count:    uint32(cfg.Ant.Count),
+		width:    s.gridWidth,
+		height:   s.gridHeight,
+	}
+	defer func() {
+		if err != nil {
+			a.Release()
+		}
+	}()
+
+	computeShader := s.createShader("ant compute shader", antCompute)
+	defer computeShader.Release()
+
+	drawShader := s.createShader("ant render shader", antDraw+cameraShader)
+	defer drawShader.Release()
+
+	a.computeLayout, err = s.bindGroupLayout("ant compute",
+		bufferEntry(0, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(2, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(3, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(4, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+	)
+	if err != nil {
+		return nil, err
+	}
+	a.drawLayout, err = s.bindGroupLayout("ant render",
+		bufferEntry(0, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_Uniform),
+		bufferEntry(2, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(3, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_ReadOnlyStorage),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	computeLayout, err := s.pipelineLayout("ant compute", a.computeLayout)
+	if err != nil {
+		return nil, err
+	}
+	a.pipelineLayouts = append(a.pipelineLayouts, computeLayout)
+	drawLayout, err := s.pipelineLayout("ant render", a.drawLayout, s.cameraLayout)
+	if err != nil {
+		return nil, err
+	}
+	a.pipelineLayouts = append(a.pipelineLayouts, drawLayout)
+
+	a.computePipeline, err = s.computePipeline("ant compute", computeLayout, computeShader, "main")
+	if err != nil {
+		return nil, err
+	}
+	a.cellPipeline, err = s.renderPipeline("ant trail", drawLayout, drawShader, "cell_vs", "main_fs")
+	if err != nil {
+		return nil, err
+	}
+	a.antPipeline, err = s.renderPipeline("ants", drawLayout, drawShader, "ant_vs", "main_fs")
+	if err != nil {
+		return nil, err
+	}
+
+	a.params = s.uniformBuffer("ant params", wgpu.ToBytes([]uint32{
+		uint32(len(turns)), a.count, uint32(cfg.Ant.StepsPerFrame), 0,
+	}))
+	a.turns = s.storageBuffer(wgpu.ToBytes(turns))
+	a.setCells(s, make([]uint32, a.width*a.height), initialAnts(cfg.Ant.Count, a.width, a.height))
+	return a, nil
+}
+
+// setCells replaces the trail and the ants, which are x, y and direction
+// triples.
+func (a *Ants) setCells(s *State, cells, ants []uint32) {
+	for _, bg := range []*wgpu.BindGroup{a.compute, a.draw} {
+		if bg != nil {
+			bg.Release()
+		}
+	}
+	for _, b := range []*wgpu.Buffer{a.cells, a.ants} {
+		if b != nil {
+			b.Release()
+		}
+	}
+	a.cells = s.storageBuffer(wgpu.ToBytes(cells))
+	a.ants = s.storageBuffer(wgpu.ToBytes(ants))
+	a.compute = s.bindGroup("ant compute", a.computeLayout, s.gridBuffer, a.params, a.cells, a.ants, a.turns)
+	a.draw = s.bindGroup("ant render", a.drawLayout, s.gridBuffer, a.params, a.cells, a.ants)
+}
+
+func (a *Ants) ResizeGrid(s *State, r gridRemap) error {
+	cells, err := s.readBuffer(a.cells)
+	if err != nil {
+		return err
+	}
+	antBytes, err := s.readBuffer(a.ants)
+	if err != nil {
+		return err
+	}
+	ants := wgpu.FromBytes[uint32](antBytes)
+	for i := 0; i < len(ants); i += 3 {
+		x, y := r.point(int(ants[i]), int(ants[i+1]))
+		ants[i], ants[i+1] = uint32(x), uint32(y)
+	}
+	a.width, a.height = r.width, r.height
+	a.setCells(s, wgpu.FromBytes[uint32](r.cells(cells, 4, nil)), ants)
+	return nil
+}
+
+// initialAnts places the first ant in the middle of the grid and spreads any
+// others evenly around a circle, each facing a different way.
+func initialAnts(n, width, height int) []uint32 {
+	ants := make([]uint32, 0, n*3)
+	radius := float64(min(width, height) / 4)
+	for i := 0; i < n; i++ {
+		x, y := width/2, height/2
+		if n > 1 {
+			angle := 2 * math.Pi * float64(i) / float64(n)
+			x += int(radius * math.Cos(angle))
+			y += int(radius * math.Sin(angle))
+		}
+		ants = append(ants, uint32(x), uint32(y), uint32(i%4))
+	}
+	return ants
+}
+
+// Population is the number of cells the ants have left in a state other than
+// the first.
+func (a *Ants) Population(s *State) (float64, error) {
+	return liveCells(s, a.cells)
+}
+
+func (a *Ants) Step(encoder *commandEncoder) {
+	computePass := encoder.BeginComputePass(nil)
+	defer computePass.Release()
+
+	computePass.SetPipeline(a.computePipeline)
+	computePass.SetBindGroup(0, a.compute, nil)
+	computePass.DispatchWorkgroups(1, 1, 1)
+	computePass.End()
+}
+
+func (a *Ants) squareCells() bool { return true }
+
+func (a *Ants) usesCamera() {}
+
+func (a *Ants) Draw(pass *renderPass) {
+	pass.SetBindGroup(0, a.draw, nil)
+	pass.SetBindGroup(1, a.camera.bindGroup, nil)
+	pass.SetVertexBuffer(0, a.vertices, 0, wgpu.WholeSize)
+
+	pass.SetPipeline(a.cellPipeline)
+	pass.Draw(6, uint32(a.width*a.height), 0, 0)
+
+	pass.SetPipeline(a.antPipeline)
+	pass.Draw(6, a.count, 0, 0)
+}
+
+func (a *Ants) Release() {
+	for _, bg := range []*wgpu.BindGroup{a.compute, a.draw} {
+		if bg != nil {
+			bg.Release()
+		}
+	}
+	a.compute, a.draw = nil, nil
+	for _, b := range []*wgpu.Buffer{a.params, a.cells, a.ants, a.turns} {
+		if b != nil {
+			b.Release()
+		}
+	}
+	a.params, a.cells, a.ants, a.turns = nil, nil, nil, nil
+	if a.computePipeline != nil {
+		a.computePipeline.Release()
+		a.computePipeline = nil
+	}
+	if a.cellPipeline != nil {
+		a.cellPipeline.Release()
+		a.cellPipeline = nil
+	}
+	if a.antPipeline != nil {
+		a.antPipeline.Release()
+		a.antPipeline = nil
+	}
+	for _, l := range a.pipelineLayouts {
+		l.Release()
+	}
+	a.pipelineLayouts = nil
+	if a.computeLayout != nil {
+		a.computeLayout.Release()
+		a.computeLayout = nil
+	}
+	if a.drawLayout != nil {
+		a.drawLayout.Release()
+		a.drawLayout = nil
+	}
+}
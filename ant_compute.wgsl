@@ -0,0 +1,59 @@
+struct Params {
+  states: u32,
+  ants: u32,
+  steps: u32,
+  padding: u32,
+};
+
+struct Ant {
+  x: u32,
+  y: u32,
+  dir: u32,
+};
+
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<uniform> params: Params;
+@group(0) @binding(2) var<storage, read_write> cells: array<u32>;
+@group(0) @binding(3) var<storage, read_write> ants: array<Ant>;
+@group(0) @binding(4) var<storage> turns: array<u32>;
+
+// A single invocation moves every ant in order, so ants sharing a cell
+// always see each other's writes and runs are deterministic.
+@compute
+@workgroup_size(1)
+fn main() {
+  for (var step = 0u; step < params.steps; step++) {
+    for (var a = 0u; a < params.ants; a++) {
+      var ant = ants[a];
+      let i = ant.y * u32(grid.x) + ant.x;
+      let state = cells[i];
+
+      ant.dir = (ant.dir + turns[state]) % 4u;
+      cells[i] = (state + 1u) % params.states;
+
+      let next = forward(vec2(ant.x, ant.y), ant.dir);
+      ant.x = next.x;
+      ant.y = next.y;
+      ants[a] = ant;
+    }
+  }
+}
+
+// dir is 0 = up, 1 = right, 2 = down, 3 = left; the grid wraps around.
+fn forward(pos: vec2<u32>, dir: u32) -> vec2<u32> {
+  let size = vec2<u32>(grid);
+  switch dir {
+    case 0u: {
+      return vec2(pos.x, (pos.y + 1u) % size.y);
+    }
+    case 1u: {
+      return vec2((pos.x + 1u) % size.x, pos.y);
+    }
+    case 2u: {
+      return vec2(pos.x, (pos.y + size.y - 1u) % size.y);
+    }
+    default: {
+      return vec2((pos.x + size.x - 1u) % size.x, pos.y);
+    }
+  }
+}
@@ -0,0 +1,63 @@
+// camera.wgsl goes after this.
+struct VertexInput {
+  @location(0) pos: vec2<f32>,
+  @builtin(instance_index) instance: u32,
+};
+
+struct VertexOutput {
+  @builtin(position) pos: vec4<f32>,
+  @location(0) colour: vec3<f32>,
+};
+
+struct Params {
+  states: u32,
+  ants: u32,
+  steps: u32,
+  padding: u32,
+};
+
+struct Ant {
+  x: u32,
+  y: u32,
+  dir: u32,
+};
+
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<uniform> params: Params;
+@group(0) @binding(2) var<storage> cells: array<u32>;
+@group(0) @binding(3) var<storage> ants: array<Ant>;
+
+fn place(pos: vec2<f32>, cell: vec2<f32>) -> vec4<f32> {
+  let cellOffset = cell / grid * 2.0;
+  return camera * vec4<f32>((pos + 1.0) / grid - 1.0 + cellOffset, 0.0, 1.0);
+}
+
+// Trail cells, coloured by how far through the rule they are. State 0 is
+// collapsed to nothing like dead cells in the life renderer.
+@vertex
+fn cell_vs(input: VertexInput) -> VertexOutput {
+  let i = f32(input.instance);
+  let state = cells[input.instance];
+  let cell = vec2<f32>(i % grid.x, floor(i / grid.x));
+  let t = f32(state) / f32(max(params.states - 1u, 1u));
+
+  var output: VertexOutput;
+  output.pos = place(f32(min(state, 1u)) * input.pos, cell);
+  output.colour = vec3<f32>(t, 1.0 - t, 0.5 + 0.5 * t);
+  return output;
+}
+
+@vertex
+fn ant_vs(input: VertexInput) -> VertexOutput {
+  let ant = ants[input.instance];
+
+  var output: VertexOutput;
+  output.pos = place(input.pos * 1.2, vec2<f32>(f32(ant.x), f32(ant.y)));
+  output.colour = vec3<f32>(1.0, 0.2, 0.2);
+  return output;
+}
+
+@fragment
+fn main_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  return vec4<f32>(input.colour, 1.0);
+}
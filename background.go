@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// backgroundModes are what -background can ask for while the window is out
+// of focus or minimised: carry on as normal, pause, or throttle.
+var backgroundModes = []string{"run", "pause", "throttle"}
+
+// While the window is in the background with -background pause or throttle,
+// frames come at backgroundFrameRate a second, and throttled simulations run
+// no faster than backgroundTickRate generations a second.
+const (
+	backgroundFrameRate = 5
+	backgroundTickRate  = 5
+)
+
+func parseBackground(mode string) error {
+	if !slices.Contains(backgroundModes, mode) {
+		return fmt.Errorf("unknown background mode %q, want %s", mode, strings.Join(backgroundModes, ", "))
+	}
+	return nil
+}
+
+// away is whether the window is out of focus or minimised, to save the
+// battery of a laptop left running in the background.
+type away struct {
+	unfocused, iconified bool
+	// paused is whether going into the background paused the simulation,
+	// so that coming back only carries it on then.
+	paused bool
+}
+
+// inBackground is whether the window is in the background and -background
+// says to do something about it.
+func (s *State) inBackground() bool {
+	return (s.away.unfocused || s.away.iconified) && s.cfg.Background != "run"
+}
+
+// throttled is whether the simulation runs at backgroundTickRate at most.
+func (s *State) throttled() bool {
+	return s.inBackground() && s.cfg.Background == "throttle"
+}
+
+// setAway is called when the window gains or loses focus or is minimised or
+// restored, pausing the simulation or carrying it on again as -background
+// says.
+func (s *State) setAway(unfocused, iconified bool) {
+	was := s.inBackground()
+	s.away.unfocused, s.away.iconified = unfocused, iconified
+	now := s.inBackground()
+	switch {
+	case now == was || s.cfg.Background != "pause":
+	case now && !s.paused:
+		s.paused, s.away.paused = true, true
+		s.updateTitle()
+	case !now && s.away.paused:
+		s.paused, s.away.paused = false, false
+		s.updateTitle()
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// keyChord is a key and the modifiers held with it.
+type keyChord struct {
+	key  glfw.Key
+	mods glfw.ModifierKey
+}
+
+// chordMods are the modifiers a chord can have; the lock keys aren't one.
+const chordMods = glfw.ModShift | glfw.ModControl | glfw.ModAlt | glfw.ModSuper
+
+var modNames = map[string]glfw.ModifierKey{
+	"shift": glfw.ModShift,
+	"ctrl":  glfw.ModControl,
+	"alt":   glfw.ModAlt,
+	"super": glfw.ModSuper,
+}
+
+// keyNames are the keys bindings can name, by lower case name, besides the
+// letters and digits.
+var keyNames = map[string]glfw.Key{
+	"space": glfw.KeySpace, "tab": glfw.KeyTab, "enter": glfw.KeyEnter,
+	"esc": glfw.KeyEscape, "backspace": glfw.KeyBackspace, "delete": glfw.KeyDelete,
+	"insert": glfw.KeyInsert, "home": glfw.KeyHome, "end": glfw.KeyEnd,
+	"page up": glfw.KeyPageUp, "page down": glfw.KeyPageDown,
+	"left": glfw.KeyLeft, "right": glfw.KeyRight, "up": glfw.KeyUp, "down": glfw.KeyDown,
+	"`": glfw.KeyGraveAccent, "-": glfw.KeyMinus, "=": glfw.KeyEqual,
+	"[": glfw.KeyLeftBracket, "]": glfw.KeyRightBracket, "\\": glfw.KeyBackslash,
+	";": glfw.KeySemicolon, "'": glfw.KeyApostrophe, ",": glfw.KeyComma,
+	".": glfw.KeyPeriod, "/": glfw.KeySlash,
+	"keypad +": glfw.KeyKPAdd, "keypad -": glfw.KeyKPSubtract,
+	"f1": glfw.KeyF1, "f2": glfw.KeyF2, "f3": glfw.KeyF3, "f4": glfw.KeyF4,
+	"f5": glfw.KeyF5, "f6": glfw.KeyF6, "f7": glfw.KeyF7, "f8": glfw.KeyF8,
+	"f9": glfw.KeyF9, "f10": glfw.KeyF10, "f11": glfw.KeyF11, "f12": glfw.KeyF12,
+}
+
+// parseChord reads a chord written as the commands' shortcuts are, such as
+// "Ctrl+Shift+P", "Space" or "Alt+Left", in any case.
+func parseChord(spec string) (keyChord, error) {
+	parts := strings.Split(spec, "+")
+	var c keyChord
+	for _, m := range parts[:len(parts)-1] {
+		mod, ok := modNames[strings.ToLower(strings.TrimSpace(m))]
+		if !ok {
+			return c, fmt.Errorf("key %q: no modifier %q, want Shift, Ctrl, Alt or Super", spec, m)
+		}
+		c.mods |= mod
+	}
+	name := strings.ToLower(strings.TrimSpace(parts[len(parts)-1]))
+	switch key, ok := keyNames[name]; {
+	case ok:
+		c.key = key
+	case len(name) == 1 && name[0] >= 'a' && name[0] <= 'z':
+		c.key = glfw.KeyA + glfw.Key(name[0]-'a')
+	case len(name) == 1 && name[0] >= '0' && name[0] <= '9':
+		c.key = glfw.Key0 + glfw.Key(name[0]-'0')
+	default:
+		return c, fmt.Errorf("key %q: no key %q", spec, parts[len(parts)-1])
+	}
+	return c, nil
+}
+
+// keyBindings moves commands from their own keys to others, from the keys
+// section of the config file: action names, as the commands table has
+// them, to the chords that do them instead. A key a command is moved off
+// does nothing, unless another is moved onto it.
+type keyBindings struct {
+	// keys is what a chord pressed stands for, where it isn't itself;
+	// KeyUnknown for nothing.
+	keys      map[keyChord]keyChord
+	shortcuts map[string]string
+}
+
+func newKeyBindings(bound map[string]string) (*keyBindings, error) {
+	b := &keyBindings{keys: map[keyChord]keyChord{}, shortcuts: map[string]string{}}
+	byAction := map[string]command{}
+	for _, c := range commands {
+		byAction[c.action] = c
+	}
+	// Actions are bound in order, so that the same mistake is always the one
+	// reported.
+	actions := make([]string, 0, len(bound))
+	for a := range bound {
+		actions = append(actions, a)
+	}
+	sort.Strings(actions)
+	var moved []keyChord
+	boundTo := map[keyChord]string{}
+	for _, a := range actions {
+		c, ok := byAction[a]
+		if !ok {
+			return nil, fmt.Errorf("keys: no action %q", a)
+		}
+		chord, err := parseChord(bound[a])
+		if err != nil {
+			return nil, fmt.Errorf("keys: %s: %w", a, err)
+		}
+		if other, taken := boundTo[chord]; taken {
+			return nil, fmt.Errorf("keys: %s and %s are both bound to %s", other, a, bound[a])
+		}
+		boundTo[chord] = a
+		b.keys[chord] = keyChord{c.key, c.mods}
+		b.shortcuts[a] = bound[a]
+		moved = append(moved, keyChord{c.key, c.mods})
+	}
+	for _, from := range moved {
+		if _, ok := b.keys[from]; !ok {
+			b.keys[from] = keyChord{key: glfw.KeyUnknown}
+		}
+	}
+	return b, nil
+}
+
+// translate is the key a chord pressed stands for, and false if it does
+// nothing. Like the highlighter, nil bindings leave every key as it is.
+func (b *keyBindings) translate(key glfw.Key, mods glfw.ModifierKey) (glfw.Key, glfw.ModifierKey, bool) {
+	if b == nil {
+		return key, mods, true
+	}
+	to, ok := b.keys[keyChord{key, mods & chordMods}]
+	if !ok {
+		return key, mods, true
+	}
+	return to.key, to.mods, to.key != glfw.KeyUnknown
+}
+
+// shortcut is the key c is on now.
+func (b *keyBindings) shortcut(c command) string {
+	if b != nil {
+		if s, ok := b.shortcuts[c.action]; ok {
+			return s
+		}
+		own := keyChord{c.key, c.mods}
+		if to, ok := b.keys[own]; ok && to != own {
+			return "no key"
+		}
+	}
+	return c.shortcut
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	_ "embed"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed binning.wgsl
+var binningShader string
+
+// binning sorts bodies into bins on the GPU each step with the passes in
+// binning.wgsl, for simulations whose bodies only feel those near them.
+// Their compute shaders go in front of binning.wgsl and their bind group
+// layouts have binningEntries at bindings 4 to 7.
+type binning struct {
+	bins, bodies int
+
+	count  *wgpu.Buffer
+	start  *wgpu.Buffer
+	slots  *wgpu.Buffer
+	sorted *wgpu.Buffer
+	passes [4]*wgpu.ComputePipeline
+}
+
+func binningEntries() []wgpu.BindGroupLayoutEntry {
+	var entries []wgpu.BindGroupLayoutEntry
+	for i := uint32(4); i <= 7; i++ {
+		entries = append(entries, bufferEntry(i, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage))
+	}
+	return entries
+}
+
+// newBinning makes the buffers and pipelines to sort bodies into bins with
+// shader, the simulation's compute shader with binning.wgsl after it.
+func newBinning(s *State, label string, layout *wgpu.PipelineLayout, shader *wgpu.ShaderModule, bins, bodies int) (b *binning, err error) {
+	b = &binning{bins: bins, bodies: bodies}
+	defer func() {
+		if err != nil {
+			b.Release()
+		}
+	}()
+	for i, entry := range []string{"clear", "count", "scan", "scatter"} {
+		b.passes[i], err = s.computePipeline(label+" "+entry, layout, shader, entry)
+		if err != nil {
+			return nil, err
+		}
+	}
+	b.count = s.storageBuffer(make([]byte, bins*4))
+	b.start = s.storageBuffer(make([]byte, bins*4))
+	b.slots = s.storageBuffer(make([]byte, bodies*4))
+	b.sorted = s.storageBuffer(make([]byte, bodies*4))
+	return b, nil
+}
+
+// buffers are the buffers for bindings 4 to 7.
+func (b *binning) buffers() []*wgpu.Buffer {
+	return []*wgpu.Buffer{b.count, b.start, b.slots, b.sorted}
+}
+
+// record sorts the bodies, with the simulation's bind group already set on
+// the pass.
+func (b *binning) record(pass *computePass) {
+	bins := uint32(b.bins+63) / 64
+	bodies := uint32(b.bodies+63) / 64
+	for i, groups := range []uint32{bins, bodies, 1, bodies} {
+		pass.SetPipeline(b.passes[i])
+		pass.DispatchWorkgroups(groups, 1, 1)
+	}
+}
+
+func (b *binning) Release() {
+	for _, buf := range []*wgpu.Buffer{b.count, b.start, b.slots, b.sorted} {
+		if buf != nil {
+			buf.Release()
+		}
+	}
+	b.count, b.start, b.slots, b.sorted = nil, nil, nil, nil
+	for i, p := range b.passes {
+		if p != nil {
+			p.Release()
+			b.passes[i] = nil
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Counting sort of bodies into bins, for finding the bodies near a point
+// without looking at all of them. Put this after a shader that provides
+// bodyCount(), binTotal() and binOfBody(i), the bin body i is in, and
+// leaves bindings 4 to 7 for these. After clear, count, scan and scatter
+// have run in order, the bodies in bin b are sorted[binStart[b]] onwards,
+// binCount[b] of them.
+
+@group(0) @binding(4) var<storage, read_write> binCount: array<atomic<u32>>;
+@group(0) @binding(5) var<storage, read_write> binStart: array<u32>;
+// slot is where each body is among those in its bin.
+@group(0) @binding(6) var<storage, read_write> slot: array<u32>;
+@group(0) @binding(7) var<storage, read_write> sorted: array<u32>;
+
+@compute
+@workgroup_size(64)
+fn clear(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x < binTotal() {
+    atomicStore(&binCount[id.x], 0u);
+  }
+}
+
+@compute
+@workgroup_size(64)
+fn count(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x < bodyCount() {
+    slot[id.x] = atomicAdd(&binCount[binOfBody(id.x)], 1u);
+  }
+}
+
+// scan is a single invocation: there are few enough bins to add up in one
+// go.
+@compute
+@workgroup_size(1)
+fn scan() {
+  var start = 0u;
+  for (var b = 0u; b < binTotal(); b += 1u) {
+    binStart[b] = start;
+    start += atomicLoad(&binCount[b]);
+  }
+}
+
+@compute
+@workgroup_size(64)
+fn scatter(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x < bodyCount() {
+    sorted[binStart[binOfBody(id.x)] + slot[id.x]] = id.x;
+  }
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// previewGrid is the grid size of previews, and previewSize the width and
+// height in pixels they are drawn at offscreen.
+const (
+	previewGrid = 64
+	previewSize = 128
+)
+
+// ruleBrowser is a mode that shows every registered simulation running side
+// by side in small previews. The arrow keys pick one, Enter switches to it
+// and Esc goes back.
+type ruleBrowser struct {
+	prev     Mode
+	previews []*instance
+	selected int
+}
+
+func (s *State) openRuleBrowser() {
+	s.setMode(&ruleBrowser{prev: s.mode})
+}
+
+func (b *ruleBrowser) Enter(s *State) {
+	for _, name := range simulationNames() {
+		cfg := defaultConfig()
+		cfg.Simulation = name
+		p, err := newPreview(s, cfg)
+		if err != nil {
+			log.Printf("previewing %s: %v", name, err)
+			continue
+		}
+		if name == s.cfg.Simulation {
+			b.selected = len(b.previews)
+		}
+		b.previews = append(b.previews, p)
+	}
+	b.describe(s)
+}
+
+// newPreview starts a small copy of the simulation cfg describes, whatever
+// its grid size.
+func newPreview(s *State, cfg *Config) (*instance, error) {
+	c := *cfg
+	c.Life3D.Size = min(c.Life3D.Size, 24)
+	c.NBody.Count = min(c.NBody.Count, 1024)
+	c.SPH.Count = min(c.SPH.Count, 1024)
+	c.Fractal.Samples = min(c.Fractal.Samples, 16)
+	c.Fractal.Still = ""
+	return newInstance(s, &c, previewGrid, previewGrid, previewSize, s.rand.Int63())
+}
+
+func (b *ruleBrowser) Exit(s *State) {
+	for _, p := range b.previews {
+		p.Release()
+	}
+	b.previews = nil
+}
+
+func (b *ruleBrowser) describe(s *State) {
+	if len(b.previews) == 0 {
+		s.showPrompt("Nothing to preview (Esc goes back)")
+		return
+	}
+	info := simulations[b.previews[b.selected].name]
+	s.showPrompt(fmt.Sprintf("%s: %s (Enter picks, Esc goes back)", info.Name, info.Description))
+	fmt.Printf("  -sim %s, discovered by %s\n", b.previews[b.selected].name, info.Discoverer)
+	fmt.Printf("  look out for %s\n", strings.Join(info.Behaviours, "; "))
+	for _, flags := range info.Recommended {
+		fmt.Printf("  try %s\n", flags)
+	}
+}
+
+// columns is how many previews go across each row of the browser.
+func (b *ruleBrowser) columns() int {
+	return int(math.Ceil(math.Sqrt(float64(len(b.previews)))))
+}
+
+func (b *ruleBrowser) HandleKey(s *State, key glfw.Key, action glfw.Action, mods glfw.ModifierKey) bool {
+	if action == glfw.Release {
+		return true
+	}
+	n, cols := len(b.previews), b.columns()
+	selected := b.selected
+	switch key {
+	case glfw.KeyLeft:
+		selected--
+	case glfw.KeyRight:
+		selected++
+	case glfw.KeyUp:
+		selected -= cols
+	case glfw.KeyDown:
+		selected += cols
+	case glfw.KeyEscape:
+		s.setMode(b.prev)
+	case glfw.KeyEnter:
+		if n == 0 {
+			return true
+		}
+		cfg := *s.cfg
+		cfg.Simulation = b.previews[b.selected].name
+		cfg.Grid = GridConfig{Width: s.gridWidth, Height: s.gridHeight}
+		s.setMode(b.prev)
+		if err := s.switchSimulation(&cfg); err != nil {
+			fmt.Println("switching simulation:", err)
+			return true
+		}
+		s.emit(actionRule)
+	}
+	if selected != b.selected && selected >= 0 && selected < n {
+		b.selected = selected
+		b.describe(s)
+	}
+	return true
+}
+
+func (b *ruleBrowser) OnAction(s *State, action string) {}
+
+func (b *ruleBrowser) Step(encoder *commandEncoder) {
+	for _, p := range b.previews {
+		p.sim.Step(encoder)
+	}
+}
+
+// Draw lays the previews out in square tiles, drawing the selected one at
+// full size and the rest a little smaller.
+func (b *ruleBrowser) Draw(s *State, pass *renderPass) {
+	if len(b.previews) == 0 {
+		return
+	}
+	cols := b.columns()
+	rows := (len(b.previews) + cols - 1) / cols
+	width, height := float32(s.config.Width), float32(s.config.Height)
+	tile := min(width/float32(cols), height/float32(rows))
+	left := (width - tile*float32(cols)) / 2
+	top := (height - tile*float32(rows)) / 2
+
+	// Viewports are measured from the top left, so the first row is at the
+	// top.
+	for i, p := range b.previews {
+		x := left + tile*float32(i%cols)
+		y := top + tile*float32(i/cols)
+		inset := tile * 0.12
+		if i == b.selected {
+			inset = tile * 0.02
+		}
+		pass.SetViewport(x+inset, y+inset, tile-2*inset, tile-2*inset, 0, 1)
+		p.sim.Draw(pass)
+	}
+}
+
+// switchSimulation replaces the running simulation with a new one set up
+// from cfg, on a grid of the size it asks for.
+func (s *State) switchSimulation(cfg *Config) error {
+	width, height := cfg.Grid.Width, cfg.Grid.Height
+	if limit := cfg.gridLimit(); width < 1 || height < 1 || width > limit || height > limit {
+		return fmt.Errorf("grid size %dx%d out of range [1, %d] for %s", width, height, limit, cfg.Simulation)
+	}
+	oldWidth, oldHeight := s.gridWidth, s.gridHeight
+	if err := s.setGridSize(width, height); err != nil {
+		return err
+	}
+	sim, err := s.startSimulation(cfg)
+	if err != nil {
+		if err := s.setGridSize(oldWidth, oldHeight); err != nil {
+			log.Println("restoring grid size:", err)
+		}
+		return err
+	}
+	s.sim.Release()
+	s.sim = sim
+	s.cfg = cfg
+	s.camera.reset()
+	s.highlights.restart()
+	fmt.Println("switched to", simulations[cfg.Simulation].Name)
+	return nil
+}
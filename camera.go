@@ -0,0 +1,272 @@
+package main
+
+import (
+	_ "embed"
+	"math"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+
+	"webgpu-go/viewmath"
+)
+
+// orbitCamera circles the origin, looking in at it from a little above, for
+// simulations drawn in 3D. Its uniform is the angle around the y axis and
+// the window's aspect ratio; shaders put the eye at (sin, 0.6, cos) of the
+// angle times 3 and look towards the origin with a focal length of 2.
+type orbitCamera struct {
+	buffer *wgpu.Buffer
+	queue  *uploadQueue
+	config *wgpu.SwapChainDescriptor
+	angle  float32
+}
+
+func newOrbitCamera(s *State, label string) *orbitCamera {
+	c := &orbitCamera{queue: s.queue, config: s.config}
+	c.buffer = s.uniformBuffer(label, c.bytes())
+	return c
+}
+
+func (c *orbitCamera) bytes() []byte {
+	aspect := float32(1)
+	if c.config.Height > 0 {
+		aspect = float32(c.config.Width) / float32(c.config.Height)
+	}
+	return wgpu.ToBytes([]float32{c.angle, aspect, 0, 0})
+}
+
+// advance moves the camera on a little, once a step.
+func (c *orbitCamera) advance() {
+	c.angle += 0.01
+	c.update()
+}
+
+// update uploads the angle and the window's aspect ratio as they are now.
+func (c *orbitCamera) update() {
+	c.queue.WriteBuffer(c.buffer, 0, c.bytes())
+}
+
+func (c *orbitCamera) Release() {
+	if c.buffer != nil {
+		c.buffer.Release()
+		c.buffer = nil
+	}
+}
+
+//go:embed camera.wgsl
+var cameraShader string
+
+// viewCamera pans and zooms simulations drawn flat on the grid, so that big
+// grids can be looked at closely rather than always squeezed into the
+// window. Its uniform is view's matrix, which simulations' vertex shaders
+// take grid space positions to clip space with, letterboxing the grid so
+// that it keeps its shape whatever the window's. The arrow keys and dragging
+// with the right mouse button pan, Page Up and Page Down zoom and Home shows
+// the whole grid again. The scroll wheel zooms about the cursor, easing
+// there over a few frames. It zooms out no further than the whole grid and
+// in no further than a single cell filling the window.
+type viewCamera struct {
+	buffer    *wgpu.Buffer
+	bindGroup *wgpu.BindGroup
+	queue     *uploadQueue
+	config    *wgpu.SwapChainDescriptor
+	grid      [2]int
+	view      viewmath.View
+	maxZoom   float32
+	// While easing, the view is zooming towards targetZoom keeping the grid
+	// point anchor at clip position at, under the cursor.
+	easing     bool
+	targetZoom float32
+	anchor, at viewmath.Vec2
+	// dragging is set while the right button is held, with where the cursor
+	// was last.
+	dragging     bool
+	lastX, lastY float64
+}
+
+// cameraUser is implemented by simulations whose vertex shaders go through
+// the view camera, bound as group 1.
+type cameraUser interface {
+	usesCamera()
+}
+
+func (s *State) initCamera() (err error) {
+	s.cameraLayout, err = s.bindGroupLayout("view camera",
+		bufferEntry(0, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_Uniform),
+	)
+	if err != nil {
+		return err
+	}
+	s.camera = newViewCamera(s)
+	return nil
+}
+
+func newViewCamera(s *State) *viewCamera {
+	c := &viewCamera{
+		queue:   s.queue,
+		config:  s.config,
+		grid:    [2]int{s.gridWidth, s.gridHeight},
+		maxZoom: float32(max(s.gridWidth, s.gridHeight, 1)),
+	}
+	c.view = viewmath.View{Zoom: 1, Scale: c.letterbox()}
+	c.buffer = s.uniformBuffer("view camera", c.bytes())
+	c.bindGroup = s.bindGroup("view camera", s.cameraLayout, c.buffer)
+	return c
+}
+
+func (c *viewCamera) bytes() []byte {
+	m := c.view.Matrix()
+	return wgpu.ToBytes(m[:])
+}
+
+func (c *viewCamera) zoom() float32 {
+	return c.view.Zoom
+}
+
+func (c *viewCamera) letterbox() viewmath.Vec2 {
+	return viewmath.Letterbox(c.grid[0], c.grid[1], int(c.config.Width), int(c.config.Height))
+}
+
+// refit letterboxes the grid again if the target has changed shape, once a
+// frame before drawing.
+func (c *viewCamera) refit() {
+	if c.letterbox() != c.view.Scale {
+		c.set(c.view)
+	}
+}
+
+// move pans by dx, dy in grid space and zooms by a factor of zoom at once,
+// stopping any easing.
+func (c *viewCamera) move(dx, dy, zoom float32) {
+	c.easing = false
+	v := c.view
+	v.Centre = viewmath.Vec2{v.Centre[0] + dx, v.Centre[1] + dy}
+	v.Zoom *= zoom
+	c.set(v)
+}
+
+// set puts the view at v, clamped to the grid, and uploads it.
+func (c *viewCamera) set(v viewmath.View) {
+	v.Scale = c.letterbox()
+	c.view = v.Clamp(c.maxZoom)
+	c.queue.WriteBuffer(c.buffer, 0, c.bytes())
+}
+
+// zoomAt starts easing towards a zoom factor times further in, or further
+// on from where it was already heading, about clip position at.
+func (c *viewCamera) zoomAt(at viewmath.Vec2, factor float32) {
+	if !c.easing {
+		c.targetZoom = c.view.Zoom
+	}
+	c.targetZoom = min(max(c.targetZoom*factor, 1), c.maxZoom)
+	c.at = at
+	c.anchor = c.view.ClipToGrid(at)
+	c.easing = true
+}
+
+// ease goes half of the way left to the target zoom, once a frame.
+func (c *viewCamera) ease() {
+	if !c.easing {
+		return
+	}
+	zoom := c.view.Zoom * float32(math.Sqrt(float64(c.targetZoom/c.view.Zoom)))
+	if math.Abs(math.Log(float64(c.targetZoom/zoom))) < 0.01 {
+		zoom, c.easing = c.targetZoom, false
+	}
+	c.set(c.view.ZoomedAbout(c.anchor, c.at, zoom))
+}
+
+// fit letterboxes a width by height grid, and limits the zoom to one of its
+// cells filling the window.
+func (c *viewCamera) fit(width, height int) {
+	c.grid = [2]int{width, height}
+	c.maxZoom = float32(max(width, height, 1))
+	c.easing = false
+	c.set(c.view)
+}
+
+// reset shows the whole grid.
+func (c *viewCamera) reset() {
+	c.easing = false
+	c.set(viewmath.View{Zoom: 1})
+}
+
+// handleCameraKey pans a quarter of the view with the arrow keys and zooms
+// in and out twice as far with Page Up and Page Down.
+func (s *State) handleCameraKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	// With Alt the arrows are the timeline's.
+	if action == glfw.Release || s.camera == nil || mods&glfw.ModAlt != 0 {
+		return
+	}
+	if _, ok := s.sim.(cameraUser); !ok {
+		return
+	}
+	c := s.camera
+	step := 0.5 / c.zoom()
+	switch key {
+	case glfw.KeyLeft:
+		c.move(-step, 0, 1)
+	case glfw.KeyRight:
+		c.move(step, 0, 1)
+	case glfw.KeyDown:
+		c.move(0, -step, 1)
+	case glfw.KeyUp:
+		c.move(0, step, 1)
+	case glfw.KeyPageUp:
+		c.move(0, 0, 2)
+	case glfw.KeyPageDown:
+		c.move(0, 0, 0.5)
+	case glfw.KeyHome:
+		c.reset()
+	}
+}
+
+// handleCameraMouse drags the grid along with the cursor while the right
+// button is held.
+func (s *State) handleCameraMouse(w *glfw.Window) {
+	if s.camera == nil {
+		return
+	}
+	c := s.camera
+	x, y := w.GetCursorPos()
+	held := w.GetMouseButton(glfw.MouseButtonRight) == glfw.Press
+	if _, ok := s.sim.(cameraUser); !ok || !held {
+		c.dragging = false
+		return
+	}
+	if c.dragging {
+		width, height := w.GetSize()
+		if delta, ok := viewmath.CursorDelta(x-c.lastX, y-c.lastY, width, height); ok {
+			c.easing = false
+			c.set(c.view.Dragged(delta))
+		}
+	}
+	c.dragging, c.lastX, c.lastY = true, x, y
+}
+
+// handleCameraScroll zooms about the cursor, twice as far in for every two
+// notches of the wheel.
+func (s *State) handleCameraScroll(w *glfw.Window, notches float64) {
+	if s.camera == nil {
+		return
+	}
+	if _, ok := s.sim.(cameraUser); !ok {
+		return
+	}
+	width, height := w.GetSize()
+	x, y := w.GetCursorPos()
+	if at, ok := viewmath.CursorToClip(x, y, width, height); ok {
+		s.camera.zoomAt(at, float32(math.Exp2(notches/2)))
+	}
+}
+
+func (c *viewCamera) Release() {
+	if c.bindGroup != nil {
+		c.bindGroup.Release()
+		c.bindGroup = nil
+	}
+	if c.buffer != nil {
+		c.buffer.Release()
+		c.buffer = nil
+	}
+}
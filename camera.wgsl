@@ -0,0 +1,3 @@
+// camera is the view matrix that pans and zooms simulations drawn flat on
+// the grid, see viewCamera in camera.go.
+@group(1) @binding(0) var<uniform> camera: mat4x4<f32>;
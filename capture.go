@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+// captureFrame draws the current generation into an offscreen texture the
+// size of s.config and reads it back.
+func (s *State) captureFrame() (*image.RGBA, error) {
+	return s.capture(s.config.Format, s.config.Width, s.config.Height, s.draw)
+}
+
+// capture has render draw into an offscreen texture of the given format and
+// size, and reads it back. HDR scenes come back the way an SDR display
+// shows them.
+func (s *State) capture(format wgpu.TextureFormat, width, height uint32, render func(encoder *commandEncoder, view *wgpu.TextureView)) (img *image.RGBA, err error) {
+	if s.hdr() && format == s.config.Format {
+		return s.output.capture(s, width, height, render)
+	}
+	var bgra bool
+	switch format {
+	case wgpu.TextureFormat_BGRA8Unorm, wgpu.TextureFormat_BGRA8UnormSrgb:
+		bgra = true
+	case wgpu.TextureFormat_RGBA8Unorm, wgpu.TextureFormat_RGBA8UnormSrgb:
+	default:
+		return nil, fmt.Errorf("can't capture %s frames", format)
+	}
+	data, bytesPerRow, err := s.readTexture(format, 4, width, height, render)
+	if err != nil {
+		return nil, err
+	}
+
+	img = image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	for y := 0; y < int(height); y++ {
+		row := data[y*bytesPerRow : y*bytesPerRow+int(width)*4]
+		copy(img.Pix[y*img.Stride:], row)
+	}
+	if bgra {
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i], img.Pix[i+2] = img.Pix[i+2], img.Pix[i]
+		}
+	}
+	// The frame is opaque whatever the alpha channel ended up as.
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	return img, nil
+}
+
+// captureWide is capture for 16 bits a channel, with render drawing into an
+// RGBA16Float texture whatever it would have for the screen.
+func (s *State) captureWide(width, height uint32, render func(encoder *commandEncoder, view *wgpu.TextureView)) (*image.RGBA64, error) {
+	data, bytesPerRow, err := s.readTexture(historyFormat, 8, width, height, render)
+	if err != nil {
+		return nil, err
+	}
+	return s.wideImage(data, bytesPerRow, int(width), int(height)), nil
+}
+
+// readTexture has render draw into an offscreen texture with bytesPerPixel
+// bytes a pixel, and returns its rows bytesPerRow apart.
+func (s *State) readTexture(format wgpu.TextureFormat, bytesPerPixel, width, height uint32, render func(encoder *commandEncoder, view *wgpu.TextureView)) (data []byte, bytesPerRow int, err error) {
+	size := wgpu.Extent3D{Width: width, Height: height, DepthOrArrayLayers: 1}
+
+	texture, err := s.device.CreateTexture(&wgpu.TextureDescriptor{
+		Label:         "capture",
+		Usage:         wgpu.TextureUsage_RenderAttachment | wgpu.TextureUsage_CopySrc,
+		Dimension:     wgpu.TextureDimension_2D,
+		Size:          size,
+		Format:        format,
+		MipLevelCount: 1,
+		SampleCount:   1,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer texture.Release()
+
+	view, err := texture.CreateView(nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer view.Release()
+
+	// Rows in a texture copy have to start on 256 byte boundaries.
+	rowSize := (width*bytesPerPixel + 255) / 256 * 256
+	readback, err := s.device.CreateBuffer(&wgpu.BufferDescriptor{
+		Label: "capture readback",
+		Size:  uint64(rowSize * height),
+		Usage: wgpu.BufferUsage_MapRead | wgpu.BufferUsage_CopyDst,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer readback.Release()
+
+	encoder, err := s.newEncoder()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer encoder.Release()
+
+	render(encoder, view)
+	err = encoder.CopyTextureToBuffer(texture.AsImageCopy(), &wgpu.ImageCopyBuffer{
+		Buffer: readback,
+		Layout: wgpu.TextureDataLayout{BytesPerRow: rowSize, RowsPerImage: height},
+	}, &size)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cmdBuffer, err := encoder.Finish(nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cmdBuffer.Release()
+	s.queue.Submit(cmdBuffer)
+
+	data, err = s.mapRead(readback)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, int(rowSize), nil
+}
+
+func savePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := encodePNG(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed texture_compute.wgsl
+var textureCompute string
+
+//go:embed texture_draw.wgsl
+var textureDraw string
+
+// cellTexture draws life through a texture of its cells rather than a quad
+// each. A compute pass after every step copies the cells into it, and a
+// single quad the size of the grid, moved by the camera like the cells'
+// would be, looks each fragment's cell up in it. The vertex work no longer
+// grows with the grid, which is most of what large grids cost.
+type cellTexture struct {
+	computeLayout         *wgpu.BindGroupLayout
+	drawLayout            *wgpu.BindGroupLayout
+	computePipelineLayout *wgpu.PipelineLayout
+	drawPipelineLayout    *wgpu.PipelineLayout
+	fill                  *wgpu.ComputePipeline
+	pipeline              *wgpu.RenderPipeline
+
+	texture *wgpu.Texture
+	view    *wgpu.TextureView
+	// fillGroups copy from each of the cell buffers, and drawGroup draws.
+	fillGroups    []*wgpu.BindGroup
+	drawGroup     *wgpu.BindGroup
+	width, height int
+	// limit is the most cells across the texture can have.
+	limit    int
+	camera   *viewCamera
+	vertices *wgpu.Buffer
+}
+
+func newCellTexture(s *State, l *Life) (t *cellTexture, err error) {
+	if l.topology != "square" {
+		return nil, fmt.Errorf("the texture renderer needs the square topology")
+	}
+	t = &cellTexture{
+		limit:    int(s.device.GetLimits().Limits.MaxTextureDimension2D),
+		camera:   l.camera,
+		vertices: s.vertexBuffer,
+	}
+	defer func() {
+		if err != nil {
+			t.Release()
+		}
+	}()
+	if l.width > t.limit || l.height > t.limit {
+		return nil, fmt.Errorf("the texture renderer draws grids up to %d cells across, not %dx%d", t.limit, l.width, l.height)
+	}
+
+	t.computeLayout, err = s.bindGroupLayout("cell texture fill",
+		bufferEntry(0, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(2, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(3, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(4, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		wgpu.BindGroupLayoutEntry{Binding: 5, Visibility: wgpu.ShaderStage_Compute, StorageTexture: wgpu.StorageTextureBindingLayout{
+			Access:        wgpu.StorageTextureAccess_WriteOnly,
+			Format:        wgpu.TextureFormat_R32Uint,
+			ViewDimension: wgpu.TextureViewDimension_2D,
+		}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	t.drawLayout, err = s.bindGroupLayout("cell texture draw",
+		bufferEntry(0, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		bufferEntry(2, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		wgpu.BindGroupLayoutEntry{Binding: 3, Visibility: wgpu.ShaderStage_Fragment, Texture: wgpu.TextureBindingLayout{
+			SampleType:    wgpu.TextureSampleType_Uint,
+			ViewDimension: wgpu.TextureViewDimension_2D,
+		}},
+		wgpu.BindGroupLayoutEntry{Binding: 4, Visibility: wgpu.ShaderStage_Fragment, Texture: wgpu.TextureBindingLayout{
+			SampleType:    wgpu.TextureSampleType_Float,
+			ViewDimension: wgpu.TextureViewDimension_1D,
+		}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if t.computePipelineLayout, err = s.pipelineLayout("cell texture fill", t.computeLayout); err != nil {
+		return nil, err
+	}
+	if t.drawPipelineLayout, err = s.pipelineLayout("cell texture draw", t.drawLayout, s.cameraLayout); err != nil {
+		return nil, err
+	}
+
+	computeShader := s.createShader("cell texture fill", textureCompute)
+	defer computeShader.Release()
+	entry := "unpacked"
+	if l.packed {
+		entry = "packed"
+	}
+	if t.fill, err = s.computePipeline("cell texture fill", t.computePipelineLayout, computeShader, entry); err != nil {
+		return nil, err
+	}
+	drawShader := s.createShader("cell texture draw", textureDraw+paletteShader+cameraShader)
+	defer drawShader.Release()
+	if t.pipeline, err = s.renderPipeline("cell texture draw", t.drawPipelineLayout, drawShader, "main_vs", "main_fs"); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// setCells binds the life's cell buffers, making the texture over again if
+// the grid has changed size, and fills it from the current generation.
+func (t *cellTexture) setCells(s *State, l *Life, trail *wgpu.Buffer) error {
+	if t.width != l.width || t.height != l.height {
+		t.releaseTexture()
+		texture, err := s.device.CreateTexture(&wgpu.TextureDescriptor{
+			Label:         "cell texture",
+			Usage:         wgpu.TextureUsage_StorageBinding | wgpu.TextureUsage_TextureBinding,
+			Dimension:     wgpu.TextureDimension_2D,
+			Size:          wgpu.Extent3D{Width: uint32(l.width), Height: uint32(l.height), DepthOrArrayLayers: 1},
+			Format:        wgpu.TextureFormat_R32Uint,
+			MipLevelCount: 1,
+			SampleCount:   1,
+		})
+		if err != nil {
+			return err
+		}
+		t.texture = texture
+		if t.view, err = texture.CreateView(nil); err != nil {
+			return err
+		}
+		t.width, t.height = l.width, l.height
+	}
+	t.releaseGroups()
+	for i := range l.cellStateStorage {
+		t.fillGroups = append(t.fillGroups, s.textureBindGroup("cell texture fill", t.computeLayout,
+			[]*wgpu.Buffer{s.gridBuffer, l.cellStateStorage[i], l.ageStorage[i], trail, l.boundaryBuffer}, t.view))
+	}
+	t.drawGroup = s.textureBindGroup("cell texture draw", t.drawLayout,
+		[]*wgpu.Buffer{s.gridBuffer, l.boundaryBuffer, s.paletteBuffer}, t.view, l.gradientView)
+
+	encoder, err := s.newEncoder()
+	if err != nil {
+		return err
+	}
+	defer encoder.Release()
+	t.update(encoder, l.steps%2)
+	cmdBuffer, err := encoder.Finish(nil)
+	if err != nil {
+		return err
+	}
+	defer cmdBuffer.Release()
+	s.queue.Submit(cmdBuffer)
+	return nil
+}
+
+// update records copying the cells from buffer side into the texture.
+func (t *cellTexture) update(encoder *commandEncoder, side int) {
+	pass := encoder.BeginComputePass(nil)
+	defer pass.Release()
+	pass.SetPipeline(t.fill)
+	pass.SetBindGroup(0, t.fillGroups[side], nil)
+	pass.DispatchWorkgroups(uint32(t.width+15)/16, uint32(t.height+15)/16, 1)
+	pass.End()
+}
+
+func (t *cellTexture) draw(pass *renderPass) {
+	pass.SetPipeline(t.pipeline)
+	pass.SetBindGroup(0, t.drawGroup, nil)
+	pass.SetBindGroup(1, t.camera.bindGroup, nil)
+	pass.SetVertexBuffer(0, t.vertices, 0, wgpu.WholeSize)
+	pass.Draw(6, 1, 0, 0)
+}
+
+func (t *cellTexture) releaseGroups() {
+	for _, g := range t.fillGroups {
+		g.Release()
+	}
+	t.fillGroups = nil
+	if t.drawGroup != nil {
+		t.drawGroup.Release()
+		t.drawGroup = nil
+	}
+}
+
+func (t *cellTexture) releaseTexture() {
+	if t.view != nil {
+		t.view.Release()
+		t.view = nil
+	}
+	if t.texture != nil {
+		t.texture.Release()
+		t.texture = nil
+	}
+	t.width, t.height = 0, 0
+}
+
+func (t *cellTexture) Release() {
+	t.releaseGroups()
+	t.releaseTexture()
+	if t.pipeline != nil {
+		t.pipeline.Release()
+		t.pipeline = nil
+	}
+	if t.fill != nil {
+		t.fill.Release()
+		t.fill = nil
+	}
+	if t.drawPipelineLayout != nil {
+		t.drawPipelineLayout.Release()
+		t.drawPipelineLayout = nil
+	}
+	if t.computePipelineLayout != nil {
+		t.computePipelineLayout.Release()
+		t.computePipelineLayout = nil
+	}
+	if t.drawLayout != nil {
+		t.drawLayout.Release()
+		t.drawLayout = nil
+	}
+	if t.computeLayout != nil {
+		t.computeLayout.Release()
+		t.computeLayout = nil
+	}
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed cloth_compute.wgsl
+var clothCompute string
+
+//go:embed cloth_draw.wgsl
+var clothDraw string
+
+const (
+	clothGravity = 9.8
+	// clothDamping is how much of its speed the cloth keeps each step.
+	clothDamping = 0.995
+	// clothDepthFormat is the format of the depth buffer the cloth is drawn
+	// with.
+	clothDepthFormat = wgpu.TextureFormat_Depth24Plus
+)
+
+// Cloth is a mass-spring cloth hanging from two corners, stepped by
+// relaxing its springs on the GPU; see cloth_compute.wgsl. Holding the left
+// mouse button over it grabs the nearest particle and drags it about.
+//
+// The shared render pass has no depth buffer, so each step draws the lit
+// mesh into a scene of its own with one, which Draw copies to the window.
+type Cloth struct {
+	state *State
+
+	computeLayout         *wgpu.BindGroupLayout
+	meshLayout            *wgpu.BindGroupLayout
+	copyLayout            *wgpu.BindGroupLayout
+	computePipelineLayout *wgpu.PipelineLayout
+	meshPipelineLayout    *wgpu.PipelineLayout
+	copyPipelineLayout    *wgpu.PipelineLayout
+	mesh, copy            *wgpu.RenderPipeline
+	// The compute passes, in the order of their entry points in
+	// cloth_compute.wgsl.
+	integrate, relax, shade, release, pick *wgpu.ComputePipeline
+
+	params      []uint32
+	paramBuffer *wgpu.Buffer
+	camera      *orbitCamera
+	positions   []*wgpu.Buffer
+	previous    *wgpu.Buffer
+	normals     *wgpu.Buffer
+	grab        *wgpu.Buffer
+	computeSets []*wgpu.BindGroup
+	meshSets    []*wgpu.BindGroup
+	vertices    *wgpu.Buffer
+
+	scene, depth         *wgpu.Texture
+	sceneView, depthView *wgpu.TextureView
+	copySet              *wgpu.BindGroup
+	width, height        uint32
+
+	size          int
+	iterations    int
+	stepsPerFrame int
+	// current is which of positions the cloth is in.
+	current int
+	// picking is set when the button goes down, for the next step to pick
+	// the particle to grab.
+	picking, holding bool
+}
+
+func newCloth(s *State, cfg *Config) (sim Simulation, err error) {
+	p := cfg.Cloth
+	if p.Size < 2 || p.Size > 256 {
+		return nil, fmt.Errorf("cloth size %d out of range [2, 256]", p.Size)
+	}
+	if p.Iterations < 1 || p.StepsPerFrame < 1 {
+		return nil, fmt.Errorf("cloth needs at least one relaxation and one step per frame, got %d and %d", p.Iterations, p.StepsPerFrame)
+	}
+	if p.DT <= 0 {
+		return nil, fmt.Errorf("cloth time step must be positive, got %v", p.DT)
+	}
+
+	c := &Cloth{
+		state:         s,
+		vertices:      s.vertexBuffer,
+		size:          p.Size,
+		iterations:    p.Iterations,
+		stepsPerFrame: p.StepsPerFrame,
+	}
+	defer func() {
+		if err != nil {
+			c.Release()
+		}
+	}()
+
+	drawShader := s.createShader("cloth render shader", clothDraw)
+	defer drawShader.Release()
+
+	computeShader := s.createShader("cloth compute shader", clothCompute)
+	defer computeShader.Release()
+
+	c.computeLayout, err = s.bindGroupLayout("cloth compute",
+		bufferEntry(0, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(2, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(3, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(4, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(5, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(6, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+	)
+	if err != nil {
+		return nil, err
+	}
+	c.meshLayout, err = s.bindGroupLayout("cloth mesh",
+		bufferEntry(0, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		bufferEntry(2, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(3, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_ReadOnlyStorage),
+	)
+	if err != nil {
+		return nil, err
+	}
+	c.copyLayout, err = s.bindGroupLayout("cloth copy",
+		wgpu.BindGroupLayoutEntry{Binding: 0, Visibility: wgpu.ShaderStage_Fragment, Texture: wgpu.TextureBindingLayout{
+			SampleType:    wgpu.TextureSampleType_UnfilterableFloat,
+			ViewDimension: wgpu.TextureViewDimension_2D,
+		}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	c.computePipelineLayout, err = s.pipelineLayout("cloth compute", c.computeLayout)
+	if err != nil {
+		return nil, err
+	}
+	c.meshPipelineLayout, err = s.pipelineLayout("cloth mesh", c.meshLayout)
+	if err != nil {
+		return nil, err
+	}
+	c.copyPipelineLayout, err = s.pipelineLayout("cloth copy", c.copyLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mesh, err = s.device.CreateRenderPipeline(&wgpu.RenderPipelineDescriptor{
+		Label:  "cloth mesh",
+		Layout: c.meshPipelineLayout,
+		Vertex: wgpu.VertexState{
+			Module:     drawShader,
+			EntryPoint: "mesh_vs",
+		},
+		Fragment: &wgpu.FragmentState{
+			Module:     drawShader,
+			EntryPoint: "mesh_fs",
+			Targets: []wgpu.ColorTargetState{
+				{Format: s.config.Format, WriteMask: wgpu.ColorWriteMask_All},
+			},
+		},
+		// Both sides of the cloth are drawn.
+		Primitive: wgpu.PrimitiveState{
+			Topology: wgpu.PrimitiveTopology_TriangleList,
+		},
+		DepthStencil: &wgpu.DepthStencilState{
+			Format:            clothDepthFormat,
+			DepthWriteEnabled: true,
+			DepthCompare:      wgpu.CompareFunction_Less,
+			StencilFront:      wgpu.StencilFaceState{Compare: wgpu.CompareFunction_Always},
+			StencilBack:       wgpu.StencilFaceState{Compare: wgpu.CompareFunction_Always},
+		},
+		Multisample: wgpu.MultisampleState{
+			Count: 1,
+			Mask:  0xFFFFFFFF,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.copy, err = s.renderPipeline("cloth copy", c.copyPipelineLayout, drawShader, "copy_vs", "copy_fs")
+	if err != nil {
+		return nil, err
+	}
+	for _, pass := range []struct {
+		pipeline **wgpu.ComputePipeline
+		entry    string
+	}{
+		{&c.integrate, "integrate"},
+		{&c.relax, "relax"},
+		{&c.shade, "shade"},
+		{&c.release, "release"},
+		{&c.pick, "pick"},
+	} {
+		*pass.pipeline, err = s.computePipeline("cloth "+pass.entry, c.computePipelineLayout, computeShader, pass.entry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The cloth starts out flat and level, hanging from the corners of its
+	// far edge.
+	spacing := 1.6 / float32(c.size-1)
+	c.params = []uint32{
+		uint32(c.size), 0, math.Float32bits(spacing), math.Float32bits(p.DT),
+		math.Float32bits(clothGravity), math.Float32bits(clothDamping), 0, 0,
+	}
+	c.paramBuffer = s.uniformBuffer("cloth params", wgpu.ToBytes(c.params))
+	c.camera = newOrbitCamera(s, "cloth camera")
+	c.camera.angle = 0.6
+
+	particles := make([]float32, 0, 4*c.size*c.size)
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			free := float32(1)
+			if y == 0 && (x == 0 || x == c.size-1) {
+				free = 0
+			}
+			particles = append(particles, -0.8+float32(x)*spacing, 0.8, -0.8+float32(y)*spacing, free)
+		}
+	}
+	c.positions = []*wgpu.Buffer{
+		s.storageBuffer(wgpu.ToBytes(particles)),
+		s.storageBuffer(wgpu.ToBytes(particles)),
+	}
+	c.previous = s.storageBuffer(wgpu.ToBytes(particles))
+	c.normals = s.storageBuffer(make([]byte, len(particles)*4))
+	c.grab = s.storageBuffer(wgpu.ToBytes([]uint32{math.MaxUint32}))
+
+	for i := range c.positions {
+		in, out := c.positions[i], c.positions[1-i]
+		c.computeSets = append(c.computeSets, s.bindGroup("cloth compute", c.computeLayout,
+			c.paramBuffer, c.camera.buffer, in, out, c.previous, c.normals, c.grab))
+		c.meshSets = append(c.meshSets, s.bindGroup("cloth mesh", c.meshLayout,
+			c.paramBuffer, c.camera.buffer, in, c.normals))
+	}
+	return c, c.resize()
+}
+
+// resize remakes the scene and its depth buffer when the view has changed
+// size.
+func (c *Cloth) resize() (err error) {
+	config := c.state.config
+	if c.scene != nil && c.width == config.Width && c.height == config.Height {
+		return nil
+	}
+	c.releaseTextures()
+	c.scene, c.sceneView, err = c.state.renderTexture("cloth scene", config.Format)
+	if err != nil {
+		return err
+	}
+	c.depth, c.depthView, err = c.state.renderTexture("cloth depth", clothDepthFormat)
+	if err != nil {
+		return err
+	}
+	c.copySet, err = c.state.device.CreateBindGroup(&wgpu.BindGroupDescriptor{
+		Label:   "cloth copy",
+		Layout:  c.copyLayout,
+		Entries: []wgpu.BindGroupEntry{{Binding: 0, TextureView: c.sceneView}},
+	})
+	if err != nil {
+		return err
+	}
+	c.width, c.height = config.Width, config.Height
+	return nil
+}
+
+// HandleMouse grabs the particle nearest the cursor when the left button
+// goes down, and lets go of it when it comes up.
+func (c *Cloth) HandleMouse(x, y float32, left, right bool) {
+	if left && !c.holding {
+		c.picking = true
+	}
+	c.holding = left
+	c.params[1] = 0
+	if left {
+		c.params[1] = 1
+	}
+	c.params[6] = math.Float32bits(x)
+	c.params[7] = math.Float32bits(y)
+	c.state.queue.WriteBuffer(c.paramBuffer, 0, wgpu.ToBytes(c.params))
+}
+
+func (c *Cloth) Step(encoder *commandEncoder) {
+	c.camera.update()
+	if err := c.resize(); err != nil {
+		log.Println("resizing the cloth's scene:", err)
+		return
+	}
+
+	computePass := encoder.BeginComputePass(nil)
+	defer computePass.Release()
+
+	groups := uint32(c.size+7) / 8
+	dispatch := func(pipeline *wgpu.ComputePipeline, flip bool) {
+		computePass.SetPipeline(pipeline)
+		computePass.SetBindGroup(0, c.computeSets[c.current], nil)
+		computePass.DispatchWorkgroups(groups, groups, 1)
+		if flip {
+			c.current = 1 - c.current
+		}
+	}
+	if c.picking {
+		computePass.SetPipeline(c.release)
+		computePass.SetBindGroup(0, c.computeSets[c.current], nil)
+		computePass.DispatchWorkgroups(1, 1, 1)
+		dispatch(c.pick, false)
+		c.picking = false
+	}
+	for i := 0; i < c.stepsPerFrame; i++ {
+		dispatch(c.integrate, true)
+		for j := 0; j < c.iterations; j++ {
+			dispatch(c.relax, true)
+		}
+	}
+	dispatch(c.shade, false)
+	computePass.End()
+
+	pass := encoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
+		ColorAttachments: []wgpu.RenderPassColorAttachment{attachColourToView(c.sceneView, c.state.palette.clear())},
+		DepthStencilAttachment: &wgpu.RenderPassDepthStencilAttachment{
+			View:            c.depthView,
+			DepthLoadOp:     wgpu.LoadOp_Clear,
+			DepthStoreOp:    wgpu.StoreOp_Discard,
+			DepthClearValue: 1,
+			// The depth buffer has no stencil, but the ops must be set.
+			StencilLoadOp:  wgpu.LoadOp_Clear,
+			StencilStoreOp: wgpu.StoreOp_Discard,
+		},
+	})
+	defer pass.Release()
+	pass.SetPipeline(c.mesh)
+	pass.SetBindGroup(0, c.meshSets[c.current], nil)
+	pass.Draw(6, uint32((c.size-1)*(c.size-1)), 0, 0)
+	pass.End()
+}
+
+// Fields reads where each particle of the cloth is and how fast it was
+// moving over the last step, one cell a particle.
+func (c *Cloth) Fields(s *State) (*fieldSet, error) {
+	data, err := s.readBuffer(c.positions[c.current])
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.readBuffer(c.previous)
+	if err != nil {
+		return nil, err
+	}
+	dt := math.Float32frombits(c.params[3])
+	channels := []fieldChannel{{name: "x"}, {name: "y"}, {name: "z"}, {name: "vx"}, {name: "vy"}, {name: "vz"}}
+	prev := wgpu.FromBytes[[4]float32](previous)
+	for i, p := range wgpu.FromBytes[[4]float32](data) {
+		for j := 0; j < 3; j++ {
+			channels[j].values = append(channels[j].values, p[j])
+			channels[3+j].values = append(channels[3+j].values, (p[j]-prev[i][j])/dt)
+		}
+	}
+	return &fieldSet{
+		width:    c.size,
+		height:   c.size,
+		channels: channels,
+		attributes: map[string]string{
+			"dt": formatAttribute(dt),
+		},
+	}, nil
+}
+
+func (c *Cloth) Draw(pass *renderPass) {
+	if c.copySet == nil {
+		return
+	}
+	pass.SetPipeline(c.copy)
+	pass.SetBindGroup(0, c.copySet, nil)
+	pass.SetVertexBuffer(0, c.vertices, 0, wgpu.WholeSize)
+	pass.Draw(6, 1, 0, 0)
+}
+
+func (c *Cloth) releaseTextures() {
+	if c.copySet != nil {
+		c.copySet.Release()
+		c.copySet = nil
+	}
+	for _, v := range []*wgpu.TextureView{c.sceneView, c.depthView} {
+		if v != nil {
+			v.Release()
+		}
+	}
+	c.sceneView, c.depthView = nil, nil
+	for _, t := range []*wgpu.Texture{c.scene, c.depth} {
+		if t != nil {
+			t.Release()
+		}
+	}
+	c.scene, c.depth = nil, nil
+}
+
+func (c *Cloth) Release() {
+	c.releaseTextures()
+	for _, bg := range append(c.computeSets, c.meshSets...) {
+		bg.Release()
+	}
+	c.computeSets, c.meshSets = nil, nil
+	for _, b := range c.positions {
+		b.Release()
+	}
+	c.positions = nil
+	for _, b := range []*wgpu.Buffer{c.previous, c.normals, c.grab, c.paramBuffer} {
+		if b != nil {
+			b.Release()
+		}
+	}
+	c.previous, c.normals, c.grab, c.paramBuffer = nil, nil, nil, nil
+	if c.camera != nil {
+		c.camera.Release()
+		c.camera = nil
+	}
+	for _, p := range []**wgpu.ComputePipeline{&c.integrate, &c.relax, &c.shade, &c.release, &c.pick} {
+		if *p != nil {
+			(*p).Release()
+			*p = nil
+		}
+	}
+	for _, p := range []**wgpu.RenderPipeline{&c.mesh, &c.copy} {
+		if *p != nil {
+			(*p).Release()
+			*p = nil
+		}
+	}
+	for _, l := range []**wgpu.PipelineLayout{&c.computePipelineLayout, &c.meshPipelineLayout, &c.copyPipelineLayout} {
+		if *l != nil {
+			(*l).Release()
+			*l = nil
+		}
+	}
+	for _, l := range []**wgpu.BindGroupLayout{&c.computeLayout, &c.meshLayout, &c.copyLayout} {
+		if *l != nil {
+			(*l).Release()
+			*l = nil
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// A square of cloth as size x size particles joined by springs, stepped by
+// Verlet integration and then relaxed towards the springs' lengths a number
+// of times. Springs join each particle to the next ones along and diagonally
+// across, and more weakly to the ones two along, which resist bending.
+struct Params {
+  size: u32,
+  // holding is 1 while the mouse button is down, when the particle in grab
+  // follows the cursor.
+  holding: u32,
+  spacing: f32,
+  dt: f32,
+  gravity: f32,
+  damping: f32,
+  mouse: vec2<f32>,
+};
+
+struct Camera {
+  angle: f32,
+  aspect: f32,
+};
+
+@group(0) @binding(0) var<uniform> params: Params;
+@group(0) @binding(1) var<uniform> camera: Camera;
+// Particles are a position and then 1, or 0 where the cloth is pinned.
+@group(0) @binding(2) var<storage> positionIn: array<vec4<f32>>;
+@group(0) @binding(3) var<storage, read_write> positionOut: array<vec4<f32>>;
+@group(0) @binding(4) var<storage, read_write> previous: array<vec4<f32>>;
+@group(0) @binding(5) var<storage, read_write> normals: array<vec4<f32>>;
+// grab is the particle nearest the cursor when the button went down in its
+// low 16 bits, or all ones for none.
+@group(0) @binding(6) var<storage, read_write> grab: atomic<u32>;
+
+const none = 0xffffffffu;
+
+fn eye() -> vec3<f32> {
+  return vec3(sin(camera.angle), 0.6, cos(camera.angle)) * 3.0;
+}
+
+fn grabbed() -> u32 {
+  let g = atomicLoad(&grab);
+  if params.holding == 0u || g == none {
+    return none;
+  }
+  return g & 0xffffu;
+}
+
+// underCursor is where under the cursor the grabbed particle at p goes, as far
+// from the eye as it is.
+fn underCursor(p: vec3<f32>) -> vec3<f32> {
+  let e = eye();
+  let forward = normalize(-e);
+  let right = normalize(cross(forward, vec3(0.0, 1.0, 0.0)));
+  let up = cross(right, forward);
+  let dir = normalize(forward * 2.0 + right * params.mouse.x * camera.aspect + up * params.mouse.y);
+  return e + dir * (dot(p - e, forward) / dot(dir, forward));
+}
+
+fn particle(id: vec3<u32>) -> u32 {
+  return id.y * params.size + id.x;
+}
+
+@compute
+@workgroup_size(8, 8)
+fn integrate(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x >= params.size || id.y >= params.size {
+    return;
+  }
+  let i = particle(id);
+  let p = positionIn[i];
+  var next = p.xyz;
+  if p.w != 0.0 {
+    let velocity = (p.xyz - previous[i].xyz) * params.damping;
+    next += velocity + vec3(0.0, -params.gravity, 0.0) * params.dt * params.dt;
+  }
+  if i == grabbed() {
+    next = underCursor(p.xyz);
+  }
+  previous[i] = p;
+  positionOut[i] = vec4(next, p.w);
+}
+
+// weight is how readily particle i moves to meet a spring.
+fn weight(i: u32) -> f32 {
+  return select(positionIn[i].w, 0.0, i == grabbed());
+}
+
+// relax moves every particle part of the way towards the lengths of all its
+// springs at once, each taking its share of a spring by its weight.
+@compute
+@workgroup_size(8, 8)
+fn relax(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x >= params.size || id.y >= params.size {
+    return;
+  }
+  let i = particle(id);
+  let p = positionIn[i];
+  let w = weight(i);
+  if w == 0.0 {
+    positionOut[i] = p;
+    return;
+  }
+  var offsets = array<vec2<i32>, 12>(
+    vec2(1, 0), vec2(-1, 0), vec2(0, 1), vec2(0, -1),
+    vec2(1, 1), vec2(-1, 1), vec2(1, -1), vec2(-1, -1),
+    vec2(2, 0), vec2(-2, 0), vec2(0, 2), vec2(0, -2),
+  );
+  let size = i32(params.size);
+  var change = vec3(0.0);
+  var springs = 0.0;
+  for (var k = 0; k < 12; k += 1) {
+    let o = offsets[k];
+    let cell = vec2<i32>(id.xy) + o;
+    if any(cell < vec2(0)) || any(cell >= vec2(size)) {
+      continue;
+    }
+    let j = u32(cell.y * size + cell.x);
+    let d = positionIn[j].xyz - p.xyz;
+    let apart = length(d);
+    if apart < 1e-6 {
+      continue;
+    }
+    let rest = params.spacing * sqrt(f32(dot(o, o)));
+    let stiffness = select(1.0, 0.3, k >= 8);
+    change += d * (1.0 - rest / apart) * stiffness * w / (w + weight(j));
+    springs += 1.0;
+  }
+  positionOut[i] = vec4(p.xyz + change * 1.5 / springs, p.w);
+}
+
+@compute
+@workgroup_size(8, 8)
+fn shade(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x >= params.size || id.y >= params.size {
+    return;
+  }
+  let last = params.size - 1u;
+  let across = positionIn[id.y * params.size + min(id.x + 1u, last)].xyz - positionIn[id.y * params.size + max(id.x, 1u) - 1u].xyz;
+  let down = positionIn[min(id.y + 1u, last) * params.size + id.x].xyz - positionIn[(max(id.y, 1u) - 1u) * params.size + id.x].xyz;
+  normals[particle(id)] = vec4(normalize(cross(down, across)), 0.0);
+}
+
+@compute
+@workgroup_size(1)
+fn release() {
+  atomicStore(&grab, none);
+}
+
+// pick finds the particle nearest the cursor on the screen, if any is close.
+@compute
+@workgroup_size(8, 8)
+fn pick(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x >= params.size || id.y >= params.size {
+    return;
+  }
+  let e = eye();
+  let forward = normalize(-e);
+  let right = normalize(cross(forward, vec3(0.0, 1.0, 0.0)));
+  let up = cross(right, forward);
+  let v = positionIn[particle(id)].xyz - e;
+  let z = dot(v, forward);
+  let screen = vec2(dot(v, right) / camera.aspect, dot(v, up)) * 2.0 / z;
+  let off = length(screen - params.mouse);
+  if z > 0.0 && off < 0.1 {
+    atomicMin(&grab, (u32(off / 0.1 * 65535.0) << 16u) | particle(id));
+  }
+}
@@ -0,0 +1,99 @@
+struct Params {
+  size: u32,
+  holding: u32,
+  spacing: f32,
+  dt: f32,
+  gravity: f32,
+  damping: f32,
+  mouse: vec2<f32>,
+};
+
+struct Camera {
+  angle: f32,
+  aspect: f32,
+};
+
+struct VertexOutput {
+  @builtin(position) pos: vec4<f32>,
+  @location(0) world: vec3<f32>,
+  @location(1) normal: vec3<f32>,
+  @location(2) uv: vec2<f32>,
+};
+
+@group(0) @binding(0) var<uniform> params: Params;
+@group(0) @binding(1) var<uniform> camera: Camera;
+@group(0) @binding(2) var<storage> positions: array<vec4<f32>>;
+@group(0) @binding(3) var<storage> normals: array<vec4<f32>>;
+
+fn eye() -> vec3<f32> {
+  return vec3(sin(camera.angle), 0.6, cos(camera.angle)) * 3.0;
+}
+
+// Every instance is one square between four particles, as two triangles.
+@vertex
+fn mesh_vs(@builtin(vertex_index) v: u32, @builtin(instance_index) square: u32) -> VertexOutput {
+  var corners = array<vec2<u32>, 6>(
+    vec2(0u, 0u), vec2(1u, 0u), vec2(1u, 1u),
+    vec2(0u, 0u), vec2(1u, 1u), vec2(0u, 1u),
+  );
+  let cell = vec2(square % (params.size - 1u), square / (params.size - 1u)) + corners[v];
+  let i = cell.y * params.size + cell.x;
+  let p = positions[i].xyz;
+
+  let e = eye();
+  let forward = normalize(-e);
+  let right = normalize(cross(forward, vec3(0.0, 1.0, 0.0)));
+  let up = cross(right, forward);
+  let view = p - e;
+  let z = dot(view, forward);
+  // Depth runs from 0 at the near plane to 1 at the far one.
+  let near = 0.1;
+  let far = 20.0;
+
+  var output: VertexOutput;
+  output.pos = vec4(
+    dot(view, right) * 2.0 / camera.aspect,
+    dot(view, up) * 2.0,
+    (z - near) * far / (far - near),
+    z,
+  );
+  output.world = p;
+  output.normal = normals[i].xyz;
+  output.uv = vec2<f32>(cell);
+  return output;
+}
+
+// The cloth is checked, different on each side, lit from above on whichever
+// side faces the eye.
+@fragment
+fn mesh_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  var n = normalize(input.normal);
+  let front = dot(n, eye() - input.world) > 0.0;
+  n = select(-n, n, front);
+  let light = 0.3 + 0.7 * max(dot(n, normalize(vec3(0.3, 1.0, 0.5))), 0.0);
+  let check = (i32(floor(input.uv.x / 4.0)) + i32(floor(input.uv.y / 4.0))) % 2 == 0;
+  var colour = select(vec3(0.95, 0.9, 0.8), vec3(0.75, 0.15, 0.2), check);
+  if !front {
+    colour = select(vec3(0.7, 0.7, 0.75), vec3(0.3, 0.3, 0.45), check);
+  }
+  return vec4(colour * light, 1.0);
+}
+
+@group(0) @binding(0) var scene: texture_2d<f32>;
+
+// The cell tile is stretched over the whole window to copy the scene the
+// mesh was drawn into onto it.
+@vertex
+fn copy_vs(@location(0) pos: vec2<f32>) -> VertexOutput {
+  var output: VertexOutput;
+  output.uv = pos / 0.8;
+  output.pos = vec4(output.uv, 0.0, 1.0);
+  return output;
+}
+
+@fragment
+fn copy_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  let size = vec2<f32>(textureDimensions(scene));
+  let p = (input.uv * vec2(0.5, -0.5) + 0.5) * size;
+  return textureLoad(scene, min(vec2<i32>(p), vec2<i32>(size) - 1), 0);
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+// isSrgb is whether format encodes what is written to it into sRGB, so that
+// shaders drawing into it work in linear colour.
+func isSrgb(format wgpu.TextureFormat) bool {
+	switch format {
+	case wgpu.TextureFormat_BGRA8UnormSrgb, wgpu.TextureFormat_RGBA8UnormSrgb:
+		return true
+	}
+	return false
+}
+
+// exportFormat is the RGBA8 format to export frames drawn for the screen
+// through, encoding them the same way the screen does.
+func (s *State) exportFormat() wgpu.TextureFormat {
+	if isSrgb(s.config.Format) {
+		return wgpu.TextureFormat_RGBA8UnormSrgb
+	}
+	return wgpu.TextureFormat_RGBA8Unorm
+}
+
+// srgbEncode is the sRGB transfer function, from linear to encoded.
+func srgbEncode(c float32) float32 {
+	if c <= 0.0031308 {
+		return 12.92 * c
+	}
+	return float32(1.055*math.Pow(float64(c), 1/2.4) - 0.055)
+}
+
+// halfFloat is the value of an IEEE 754 half-precision float.
+func halfFloat(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exponent := uint32(h>>10) & 0x1f
+	mantissa := uint32(h) & 0x3ff
+	switch {
+	case exponent == 0x1f:
+		return math.Float32frombits(sign | 0xff<<23 | mantissa<<13)
+	case exponent != 0:
+		return math.Float32frombits(sign | (exponent+112)<<23 | mantissa<<13)
+	case mantissa == 0:
+		return math.Float32frombits(sign)
+	}
+	// Subnormal halves are normal as floats.
+	f := float32(mantissa) / (1 << 24)
+	if sign != 0 {
+		return -f
+	}
+	return f
+}
+
+// wideImage turns RGBA16Float texels, rows bytesPerRow apart, into a 16-bit
+// image. The texels hold what would have been written to s.config.Format,
+// so they are encoded into sRGB the same way the screen would have.
+func (s *State) wideImage(data []byte, bytesPerRow, width, height int) *image.RGBA64 {
+	encode := isSrgb(s.config.Format)
+	img := image.NewRGBA64(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		row := data[y*bytesPerRow:]
+		for x := 0; x < width; x++ {
+			var c [3]uint16
+			for i := range c {
+				v := halfFloat(binary.LittleEndian.Uint16(row[8*x+2*i:]))
+				v = min(max(v, 0), 1)
+				if encode {
+					v = srgbEncode(v)
+				}
+				c[i] = uint16(v*0xffff + 0.5)
+			}
+			img.SetRGBA64(x, y, color.RGBA64{c[0], c[1], c[2], 0xffff})
+		}
+	}
+	return img
+}
+
+// encodePNG writes img as a PNG tagged as sRGB, which is what everything
+// it draws is in. The gAMA and cHRM chunks the PNG spec asks to go with
+// sRGB are there for readers that don't know it.
+func encodePNG(w io.Writer, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	// The signature and IHDR come first, and the colour chunks have to be
+	// before the image data.
+	const header = 8 + 4 + 4 + 13 + 4
+	b := buf.Bytes()
+	if _, err := w.Write(b[:header]); err != nil {
+		return err
+	}
+	chromaticities := make([]byte, 0, 32)
+	for _, v := range []uint32{31270, 32900, 64000, 33000, 30000, 60000, 15000, 6000} {
+		chromaticities = binary.BigEndian.AppendUint32(chromaticities, v)
+	}
+	for _, chunk := range []struct {
+		kind string
+		data []byte
+	}{
+		// Perceptual rendering intent.
+		{"sRGB", []byte{0}},
+		{"gAMA", binary.BigEndian.AppendUint32(nil, 45455)},
+		{"cHRM", chromaticities},
+	} {
+		if err := writePNGChunk(w, chunk.kind, chunk.data); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(b[header:])
+	return err
+}
+
+func writePNGChunk(w io.Writer, kind string, data []byte) error {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, kind...)
+	chunk = append(chunk, data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	_, err := w.Write(chunk)
+	return err
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// paletteShown is how many of the best matches the title bar has room for.
+const paletteShown = 5
+
+// commandPalette finds commands and the simulation's parameters by typing
+// part of their names, in the title bar. Up and Down pick a match, Enter
+// runs it and Esc goes back. A number at the end of what is typed is the
+// value to set a parameter to.
+type commandPalette struct {
+	prev     Mode
+	query    string
+	matches  []paletteEntry
+	selected int
+}
+
+type paletteEntry struct {
+	label string
+	run   func(s *State) error
+}
+
+func (s *State) openCommandPalette() {
+	s.setMode(&commandPalette{prev: s.mode})
+}
+
+func (p *commandPalette) Enter(s *State) { p.update(s) }
+func (p *commandPalette) Exit(s *State)  {}
+
+func (p *commandPalette) HandleKey(s *State, key glfw.Key, action glfw.Action, mods glfw.ModifierKey) bool {
+	if action == glfw.Release {
+		return true
+	}
+	switch key {
+	case glfw.KeyEscape:
+		s.setMode(p.prev)
+	case glfw.KeyEnter, glfw.KeyKPEnter:
+		if len(p.matches) == 0 {
+			return true
+		}
+		// The mode goes back first, so that the command's key does what it
+		// would have done had it been pressed there.
+		entry := p.matches[p.selected]
+		s.setMode(p.prev)
+		if err := entry.run(s); err != nil {
+			log.Println(err)
+		}
+	case glfw.KeyBackspace:
+		if r := []rune(p.query); len(r) > 0 {
+			p.query = string(r[:len(r)-1])
+			p.update(s)
+		}
+	case glfw.KeyUp:
+		p.selected = max(p.selected-1, 0)
+		p.show(s)
+	case glfw.KeyDown:
+		p.selected = min(p.selected+1, max(len(p.matches)-1, 0))
+		p.show(s)
+	}
+	return true
+}
+
+func (p *commandPalette) HandleChar(s *State, char rune) {
+	p.query += string(char)
+	p.update(s)
+}
+
+func (p *commandPalette) OnAction(s *State, action string) {}
+
+// update finds the entries matching the query, best first.
+func (p *commandPalette) update(s *State) {
+	pattern, value, hasValue := strings.TrimSpace(p.query), 0.0, false
+	if i := strings.LastIndexByte(pattern, ' '); i >= 0 {
+		if v, err := strconv.ParseFloat(pattern[i+1:], 64); err == nil {
+			pattern, value, hasValue = strings.TrimSpace(pattern[:i]), v, true
+		}
+	}
+
+	var entries []paletteEntry
+	for _, c := range commands {
+		c := c
+		entries = append(entries, paletteEntry{
+			label: fmt.Sprintf("%s (%s)", c.name, s.bindings.shortcut(c)),
+			run:   func(s *State) error { c.run(s); return nil },
+		})
+	}
+	if s.macros != nil {
+		for _, mc := range s.macros.saved {
+			mc := mc
+			entries = append(entries, paletteEntry{
+				label: fmt.Sprintf("Play macro %s (F%d)", mc.Name, mc.Key),
+				run:   func(s *State) error { s.playMacro(mc); return nil },
+			})
+		}
+	}
+	for _, param := range s.parameters.snapshot().Parameters {
+		name := param.Name
+		e := paletteEntry{label: fmt.Sprintf("Set %s (now %s, type a value)", name, s.format.Float(param.Value, 3))}
+		e.run = func(s *State) error { return fmt.Errorf("type a value to set %s to", name) }
+		if hasValue {
+			e.label = fmt.Sprintf("Set %s to %g", name, value)
+			e.run = func(s *State) error { return s.setParameter(name, value) }
+		}
+		entries = append(entries, e)
+	}
+
+	type scored struct {
+		paletteEntry
+		score int
+	}
+	var found []scored
+	for _, e := range entries {
+		if score, ok := fuzzyScore(pattern, e.label); ok {
+			found = append(found, scored{e, score})
+		}
+	}
+	if pattern != "" {
+		sort.SliceStable(found, func(i, j int) bool { return found[i].score > found[j].score })
+	}
+	p.matches = p.matches[:0]
+	for _, f := range found {
+		p.matches = append(p.matches, f.paletteEntry)
+	}
+	p.selected = 0
+	p.show(s)
+}
+
+// show puts the query and the best matches in the title bar, the selected
+// one in brackets. It doesn't go through showPrompt, which would print every
+// key typed.
+func (p *commandPalette) show(s *State) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "> %s_", p.query)
+	if len(p.matches) == 0 {
+		b.WriteString(" - nothing matches")
+	}
+	first := max(0, min(p.selected-paletteShown+1, len(p.matches)-paletteShown))
+	for i := first; i < min(first+paletteShown, len(p.matches)); i++ {
+		if i == p.selected {
+			fmt.Fprintf(&b, " | [%s]", p.matches[i].label)
+		} else {
+			fmt.Fprintf(&b, " | %s", p.matches[i].label)
+		}
+	}
+	s.prompt = b.String()
+	s.updateTitle()
+}
+
+// fuzzyScore says whether the letters of pattern appear in text in order,
+// ignoring case and spaces, and how well: matches that run on from the last
+// or start a word count for more, and shorter texts break ties.
+func fuzzyScore(pattern, text string) (int, bool) {
+	want := []rune(strings.ToLower(strings.ReplaceAll(pattern, " ", "")))
+	score, next, prev := 0, 0, -2
+	runes := []rune(strings.ToLower(text))
+	for i, r := range runes {
+		if next == len(want) {
+			break
+		}
+		if r != want[next] {
+			continue
+		}
+		score += 1
+		if i == prev+1 {
+			score += 2
+		}
+		if i == 0 || !unicode.IsLetter(runes[i-1]) && !unicode.IsDigit(runes[i-1]) {
+			score += 3
+		}
+		prev, next = i, next+1
+	}
+	if next < len(want) {
+		return 0, false
+	}
+	return score*100 - len(runes), true
+}
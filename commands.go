@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// command is something the keys do, named so that it can be found without
+// knowing the key. Running it presses the key, so it behaves exactly as the
+// key does in normal mode: commands for other simulations do nothing. The
+// action is what the keys section of the config file binds it by.
+type command struct {
+	action   string
+	name     string
+	shortcut string
+	key      glfw.Key
+	mods     glfw.ModifierKey
+}
+
+// commands lists every key in normal mode.
+var commands = append([]command{
+	{"browse", "Browse the simulations", "Tab", glfw.KeyTab, 0},
+	{"presets", "Pick a preset", "P", glfw.KeyP, 0},
+	{"still", "Take a still", "S", glfw.KeyS, 0},
+	{"poster", "Save a poster", "E", glfw.KeyE, 0},
+	{"gif", "Save a GIF of the next generations", "Shift+E", glfw.KeyE, glfw.ModShift},
+	{"exr", "Save the raw fields as EXR", "X", glfw.KeyX, 0},
+	{"numpy", "Save the state as a NumPy array", "Shift+N", glfw.KeyN, glfw.ModShift},
+	{"new-soup", "Start again from a new soup", "N", glfw.KeyN, 0},
+	{"clear", "Clear the grid", "C", glfw.KeyC, 0},
+	{"fullscreen", "Switch to or from fullscreen", "F11", glfw.KeyF11, 0},
+	{"screenshot", "Save a screenshot", "F12", glfw.KeyF12, 0},
+	{"record", "Start or stop recording a video", "Shift+F12", glfw.KeyF12, glfw.ModShift},
+	{"stats", "Show or hide frame stats", "I", glfw.KeyI, 0},
+	{"frame-graph", "Show or hide the frame time graph", "Shift+I", glfw.KeyI, glfw.ModShift},
+	{"hud", "Show or hide the HUD", "H", glfw.KeyH, 0},
+	{"help", "Show or hide the help", "F1", glfw.KeyF1, 0},
+	{"debug-panel", "Show or hide the debug panel", "`", glfw.KeyGraveAccent, 0},
+	{"grid-lines", "Show or hide grid lines", "L", glfw.KeyL, 0},
+	{"effects", "Turn the effects off or back on", "V", glfw.KeyV, 0},
+	{"next-palette", "Switch to the next palette", "T", glfw.KeyT, 0},
+	{"previous-palette", "Switch to the palette before", "Shift+T", glfw.KeyT, glfw.ModShift},
+	{"ages", "Print how old the live cells are", "A", glfw.KeyA, 0},
+	{"report", "Print resource usage", "R", glfw.KeyR, 0},
+	{"macro", "Start or stop recording a macro", "M", glfw.KeyM, 0},
+	{"pause", "Pause or resume", "Space", glfw.KeySpace, 0},
+	{"step", "Step a generation", ".", glfw.KeyPeriod, 0},
+	{"step-10", "Step 10 generations", "Shift+.", glfw.KeyPeriod, glfw.ModShift},
+	{"step-100", "Step 100 generations", "Ctrl+.", glfw.KeyPeriod, glfw.ModControl},
+	{"faster", "Run faster", "+", glfw.KeyEqual, glfw.ModShift},
+	{"slower", "Run slower", "-", glfw.KeyMinus, 0},
+	{"fast-forward", "Fast-forward", "G", glfw.KeyG, 0},
+	{"advance", "Step far ahead on the GPU", "Shift+G", glfw.KeyG, glfw.ModShift},
+	{"grow-grid", "Double the grid", "]", glfw.KeyRightBracket, 0},
+	{"shrink-grid", "Halve the grid", "[", glfw.KeyLeftBracket, 0},
+	{"stretch-grid", "Double the grid, stretching the cells", "Shift+]", glfw.KeyRightBracket, glfw.ModShift},
+	{"squash-grid", "Halve the grid, stretching the cells", "Shift+[", glfw.KeyLeftBracket, glfw.ModShift},
+	{"zoom-in", "Zoom in", "Page Up", glfw.KeyPageUp, 0},
+	{"zoom-out", "Zoom out", "Page Down", glfw.KeyPageDown, 0},
+	{"whole-grid", "Show the whole grid", "Home", glfw.KeyHome, 0},
+	{"pan-left", "Pan left", "Left", glfw.KeyLeft, 0},
+	{"pan-right", "Pan right", "Right", glfw.KeyRight, 0},
+	{"pan-up", "Pan up", "Up", glfw.KeyUp, 0},
+	{"pan-down", "Pan down", "Down", glfw.KeyDown, 0},
+	{"scrub-back", "Scrub back a generation", "Alt+Left", glfw.KeyLeft, glfw.ModAlt},
+	{"scrub-forward", "Scrub forward a generation", "Alt+Right", glfw.KeyRight, glfw.ModAlt},
+	{"copy", "Life: copy the selection", "Ctrl+C", glfw.KeyC, glfw.ModControl},
+	{"cut", "Life: cut the selection", "Ctrl+X", glfw.KeyX, glfw.ModControl},
+	{"clear-selection", "Life: clear the selection", "Delete", glfw.KeyDelete, 0},
+	{"deselect", "Life: let go of the selection", "Esc", glfw.KeyEscape, 0},
+	{"paste", "Life: paste the clipboard", "Ctrl+V", glfw.KeyV, glfw.ModControl},
+	{"stamp", "Life: stamp the pattern at the cursor", "Q", glfw.KeyQ, 0},
+	{"turn-stamps", "Life: turn stamps a quarter clockwise", "Shift+Q", glfw.KeyQ, glfw.ModShift},
+	{"mirror-stamps", "Life: mirror stamps", "Ctrl+Q", glfw.KeyQ, glfw.ModControl},
+	{"next-pattern", "Life: stamp the next pattern", "W", glfw.KeyW, 0},
+	{"previous-pattern", "Life: stamp the pattern before", "Shift+W", glfw.KeyW, glfw.ModShift},
+	{"undo", "Life: undo the last edit", "Ctrl+Z", glfw.KeyZ, glfw.ModControl},
+	{"redo", "Life: redo the edit undone", "Ctrl+Y", glfw.KeyY, glfw.ModControl},
+	{"save-snapshot", "Save a snapshot", "Ctrl+S", glfw.KeyS, glfw.ModControl},
+	{"load-snapshot", "Load the last snapshot", "Ctrl+O", glfw.KeyO, glfw.ModControl},
+	{"boundary", "Life: change the boundary", "B", glfw.KeyB, 0},
+	{"faster-trails", "Life: fade trails faster", "Ctrl+=", glfw.KeyEqual, glfw.ModControl},
+	{"slower-trails", "Life: fade trails slower", "Ctrl+-", glfw.KeyMinus, glfw.ModControl},
+	{"raise-feed", "Gray-Scott: raise the feed rate", "F", glfw.KeyF, 0},
+	{"lower-feed", "Gray-Scott: lower the feed rate", "Shift+F", glfw.KeyF, glfw.ModShift},
+	{"raise-kill", "Gray-Scott: raise the kill rate", "K", glfw.KeyK, 0},
+	{"lower-kill", "Gray-Scott: lower the kill rate", "Shift+K", glfw.KeyK, glfw.ModShift},
+	{"orbit", "Fractal: start or stop orbiting", "O", glfw.KeyO, 0},
+	{"all-instances", "Instances: control all of them", "0", glfw.Key0, 0},
+}, instanceCommands()...)
+
+func instanceCommands() []command {
+	var cs []command
+	for i := 1; i <= 9; i++ {
+		cs = append(cs, command{fmt.Sprintf("instance-%d", i), fmt.Sprintf("Instances: control number %d", i), fmt.Sprint(i), glfw.Key0 + glfw.Key(i), 0})
+	}
+	return cs
+}
+
+// commandByAction is the command bound by action.
+func commandByAction(action string) (command, bool) {
+	for _, c := range commands {
+		if c.action == action {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+func (c command) run(s *State) {
+	s.pressKey(c.key, glfw.Press, c.mods)
+	s.pressKey(c.key, glfw.Release, c.mods)
+}
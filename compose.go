@@ -0,0 +1,183 @@
+package main
+
+import (
+	_ "embed"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed compose.wgsl
+var composeShader string
+
+// stage is a full screen pass between the simulation and the screen: it
+// draws what has been drawn into its input into the view it is given.
+type stage interface {
+	input() *wgpu.TextureView
+	apply(s *State, encoder *commandEncoder, view *wgpu.TextureView)
+}
+
+// sceneTarget is the texture the simulation pass draws into, first of the
+// stages, so that nothing after it has to know how the simulation is drawn
+// or at what size. It is copied on as it is.
+type sceneTarget struct {
+	width, height uint32
+
+	layout         *wgpu.BindGroupLayout
+	pipelineLayout *wgpu.PipelineLayout
+	pipeline       *wgpu.RenderPipeline
+
+	texture *wgpu.Texture
+	view    *wgpu.TextureView
+	set     *wgpu.BindGroup
+}
+
+func newSceneTarget(s *State) (t *sceneTarget, err error) {
+	t = &sceneTarget{}
+	defer func() {
+		if err != nil {
+			t.Release()
+		}
+	}()
+
+	t.layout, err = s.bindGroupLayout("compose", wgpu.BindGroupLayoutEntry{
+		Binding:    0,
+		Visibility: wgpu.ShaderStage_Fragment,
+		Texture: wgpu.TextureBindingLayout{
+			SampleType:    wgpu.TextureSampleType_UnfilterableFloat,
+			ViewDimension: wgpu.TextureViewDimension_2D,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.pipelineLayout, err = s.pipelineLayout("compose", t.layout)
+	if err != nil {
+		return nil, err
+	}
+	t.pipeline, err = t.copyPipeline(s, s.config.Format)
+	if err != nil {
+		return nil, err
+	}
+	return t, t.resize(s)
+}
+
+// copyPipeline draws a texture bound with the scene's layout into a target
+// of format as it is.
+func (t *sceneTarget) copyPipeline(s *State, format wgpu.TextureFormat) (*wgpu.RenderPipeline, error) {
+	shader := s.createShader("compose shader", composeShader)
+	defer shader.Release()
+	return s.device.CreateRenderPipeline(&wgpu.RenderPipelineDescriptor{
+		Label:  "compose",
+		Layout: t.pipelineLayout,
+		Vertex: wgpu.VertexState{
+			Module:     shader,
+			EntryPoint: "main_vs",
+		},
+		Fragment: &wgpu.FragmentState{
+			Module:     shader,
+			EntryPoint: "main_fs",
+			Targets:    []wgpu.ColorTargetState{{Format: format, WriteMask: wgpu.ColorWriteMask_All}},
+		},
+		Primitive: wgpu.PrimitiveState{
+			Topology: wgpu.PrimitiveTopology_TriangleList,
+		},
+		Multisample: wgpu.MultisampleState{
+			Count: 1,
+			Mask:  0xFFFFFFFF,
+		},
+	})
+}
+
+// resize recreates the texture to match the swapchain.
+func (t *sceneTarget) resize(s *State) (err error) {
+	t.releaseTexture()
+	t.texture, t.view, err = s.renderTexture("scene", s.config.Format)
+	if err != nil {
+		return err
+	}
+	t.set, err = s.device.CreateBindGroup(&wgpu.BindGroupDescriptor{
+		Label:   "compose",
+		Layout:  t.layout,
+		Entries: []wgpu.BindGroupEntry{{Binding: 0, TextureView: t.view}},
+	})
+	if err != nil {
+		return err
+	}
+	t.width, t.height = s.config.Width, s.config.Height
+	return nil
+}
+
+func (t *sceneTarget) input() *wgpu.TextureView {
+	return t.view
+}
+
+func (t *sceneTarget) apply(s *State, encoder *commandEncoder, view *wgpu.TextureView) {
+	pass := encoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
+		ColorAttachments: []wgpu.RenderPassColorAttachment{attachColourToView(view, s.palette.clear())},
+	})
+	defer pass.Release()
+
+	pass.SetPipeline(t.pipeline)
+	pass.SetBindGroup(0, t.set, nil)
+	pass.Draw(3, 1, 0, 0)
+	pass.End()
+}
+
+// stages are those the frame goes through on its way to the screen, or to
+// a capture, starting with the scene: the accessibility filter and then the
+// effects, if they are on. The output pass is left for Render to add, as
+// captures take their own way through it.
+func (s *State) stages() []stage {
+	stages := []stage{s.scene}
+	if s.filter != nil {
+		stages = append(stages, s.filter)
+	}
+	if s.post.active() {
+		stages = append(stages, s.post)
+	}
+	return stages
+}
+
+// compose records the simulation pass into the first of stages, and each
+// stage into the next one's input, the last of them into view.
+func (s *State) compose(encoder *commandEncoder, view *wgpu.TextureView, stages []stage) {
+	s.drawSimulation(encoder, stages[0].input(), s.scene.width, s.scene.height)
+	for i, st := range stages {
+		target := view
+		if i < len(stages)-1 {
+			target = stages[i+1].input()
+		}
+		st.apply(s, encoder, target)
+	}
+}
+
+func (t *sceneTarget) releaseTexture() {
+	if t.set != nil {
+		t.set.Release()
+		t.set = nil
+	}
+	if t.view != nil {
+		t.view.Release()
+		t.view = nil
+	}
+	if t.texture != nil {
+		t.texture.Release()
+		t.texture = nil
+	}
+}
+
+func (t *sceneTarget) Release() {
+	t.releaseTexture()
+	if t.pipeline != nil {
+		t.pipeline.Release()
+		t.pipeline = nil
+	}
+	if t.pipelineLayout != nil {
+		t.pipelineLayout.Release()
+		t.pipelineLayout = nil
+	}
+	if t.layout != nil {
+		t.layout.Release()
+		t.layout = nil
+	}
+}
@@ -1,20 +1,38 @@
+
 @group(0) @binding(0) var<uniform> grid: vec2<f32>;
 @group(0) @binding(1) var<storage> cellStateIn: array<u32>;
 @group(0) @binding(2) var<storage, read_write> cellStateOut: array<u32>;
+struct Life {
+  // 0 wraps around (torus), 1 treats cells beyond the edges as dead and 2
+  // mirrors the edge cells.
+  boundary: u32,
+  // species is how many populations compete; live cells hold which of them
+  // they are, from 1.
+  species: u32,
+};
+
+@group(0) @binding(3) var<uniform> life: Life;
+// ageIn is how many generations each cell has been alive, 0 for dead cells.
+@group(0) @binding(4) var<storage> ageIn: array<u32>;
+@group(0) @binding(5) var<storage, read_write> ageOut: array<u32>;
 
 @compute
 @workgroup_size(16)
-fn main(@builtin(global_invocation_id) cell: vec3<u32>) {
-      let activeNeighbors = cellActive(cell.x+u32(1), cell.y+u32(1)) +
-                            cellActive(cell.x+u32(1), cell.y) +
-                            cellActive(cell.x+u32(1), cell.y-u32(1)) +
-                            cellActive(cell.x, cell.y-u32(1) +
-                            cellActive(cell.x-u32(1), cell.y-u32(1)) +
-                            cellActive(cell.x-u32(1), cell.y)) +
-                            cellActive(cell.x-u32(1), cell.y+u32(1)) +
-                            cellActive(cell.x, cell.y+u32(1));
-
-      let i = cellIndex(cell.xy);
+fn main(@builtin(global_invocation_id) id: vec3<u32>) {
+      if id.x >= u32(grid.x) || id.y >= u32(grid.y) {
+        return;
+      }
+      let cell = vec2<i32>(id.xy);
+      let activeNeighbors = cellActive(cell.x + 1, cell.y + 1) +
+                            cellActive(cell.x + 1, cell.y) +
+                            cellActive(cell.x + 1, cell.y - 1) +
+                            cellActive(cell.x, cell.y - 1) +
+                            cellActive(cell.x - 1, cell.y - 1) +
+                            cellActive(cell.x - 1, cell.y) +
+                            cellActive(cell.x - 1, cell.y + 1) +
+                            cellActive(cell.x, cell.y + 1);
+
+      let i = cellIndex(cell);
 
       // Conway's game of life rules:
       switch activeNeighbors {
@@ -22,19 +40,75 @@ fn main(@builtin(global_invocation_id) cell: vec3<u32>) {
           cellStateOut[i] = cellStateIn[i];
         }
         case 3u: {
-          cellStateOut[i] = u32(1);
+          if cellStateIn[i] == 0u {
+            cellStateOut[i] = newborn(cell);
+          } else {
+            cellStateOut[i] = cellStateIn[i];
+          }
         }
         default: {
           cellStateOut[i] = u32(0);
         }
       }
+      ageOut[i] = select(0u, ageIn[i] + 1u, cellStateOut[i] != 0u);
 }
 
-fn cellActive(x: u32, y: u32) -> u32 {
-  return cellStateIn[cellIndex(vec2(x, y))];
+// newborn is the species of a cell born to three live neighbours: the one
+// most of them are, or when all three differ the one after their sum, so
+// that no species is favoured.
+fn newborn(cell: vec2<i32>) -> u32 {
+  if life.species <= 1u {
+    return 1u;
+  }
+  var parents = array<u32, 3>(0u, 0u, 0u);
+  var n = 0;
+  for (var dy = -1; dy <= 1; dy += 1) {
+    for (var dx = -1; dx <= 1; dx += 1) {
+      let state = cellState(cell.x + dx, cell.y + dy);
+      if (dx != 0 || dy != 0) && state != 0u && n < 3 {
+        parents[n] = state;
+        n += 1;
+      }
+    }
+  }
+  if parents[0] == parents[1] || parents[0] == parents[2] {
+    return parents[0];
+  }
+  if parents[1] == parents[2] {
+    return parents[1];
+  }
+  return (parents[0] + parents[1] + parents[2] - 3u) % life.species + 1u;
 }
 
-fn cellIndex(cell: vec2<u32>) -> u32 {
-  return (cell.y % u32(grid.y)) * u32(grid.x) + (cell.x % u32(grid.x));
+fn cellActive(x: i32, y: i32) -> u32 {
+  return min(cellState(x, y), 1u);
 }
 
+fn cellState(x: i32, y: i32) -> u32 {
+  let size = vec2<i32>(grid);
+  var cell = vec2(x, y);
+  switch life.boundary {
+    case 1u: {
+      if any(cell < vec2(0)) || any(cell >= size) {
+        return 0u;
+      }
+    }
+    case 2u: {
+      cell = mirror(cell, size);
+    }
+    default: {
+      cell = (cell % size + size) % size;
+    }
+  }
+  return cellStateIn[cellIndex(cell)];
+}
+
+// Reflects coordinates just outside the grid back onto the edge cells.
+fn mirror(cell: vec2<i32>, size: vec2<i32>) -> vec2<i32> {
+  return select(cell, -cell - 1, cell < vec2(0)) -
+         select(vec2(0), 2 * (cell - size) + 1, cell >= size);
+}
+
+fn cellIndex(cell: vec2<i32>) -> u32 {
+  return u32(cell.y * i32(grid.x) + cell.x);
+}
@@ -0,0 +1,839 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Config holds everything the user can change without recompiling. It is
+// read from an optional JSON file and then overridden by command line flags.
+type Config struct {
+	Simulation string           `json:"simulation"`
+	Grid       GridConfig       `json:"grid"`
+	Life       LifeConfig       `json:"life"`
+	Life3D     Life3DConfig     `json:"life_3d"`
+	Ant        AntConfig        `json:"ant"`
+	Lenia      LeniaConfig      `json:"lenia"`
+	GrayScott  GrayScottConfig  `json:"gray_scott"`
+	HashLife   HashLifeConfig   `json:"hashlife"`
+	Table      TableConfig      `json:"table"`
+	NBody      NBodyConfig      `json:"nbody"`
+	SPH        SPHConfig        `json:"sph"`
+	Stochastic StochasticConfig `json:"stochastic"`
+	Cloth      ClothConfig      `json:"cloth"`
+	Fractal    FractalConfig    `json:"fractal"`
+	Init       InitConfig       `json:"init"`
+	Plugins    PluginsConfig    `json:"plugins"`
+
+	Accessibility AccessibilityConfig `json:"accessibility"`
+	HDR           HDRConfig           `json:"hdr"`
+	Timelapse     TimelapseConfig     `json:"timelapse"`
+	Highlights    HighlightsConfig    `json:"highlights"`
+	Still         StillConfig         `json:"still"`
+	Poster        PosterConfig        `json:"poster"`
+	Recording     RecordingConfig     `json:"recording"`
+	GIF           GIFConfig           `json:"gif"`
+	Session       SessionConfig       `json:"session"`
+
+	// TickRate is how many generations a second the simulation runs, from
+	// minTickRate to maxTickRate, or 0 for as many as the GPU keeps up
+	// with. + and - change it while running.
+	TickRate float64 `json:"tick_rate"`
+	// Patterns is a directory of RLE files to stamp onto life as well as the
+	// built-in patterns, where get saves them by default.
+	Patterns string `json:"patterns"`
+	// Snapshot is the name of a snapshot in Storage to carry on from,
+	// whatever the other settings say.
+	Snapshot string `json:"snapshot"`
+	// Trackpad takes scrolling as a trackpad's gestures: a two-finger drag
+	// pans and a pinch zooms.
+	Trackpad bool `json:"trackpad"`
+	// Background is what happens while the window is out of focus or
+	// minimised: "run" carries on as normal, "pause" pauses until it comes
+	// back and "throttle" slows to a few generations a second. Both of those
+	// draw only a few frames a second as well.
+	Background string `json:"background"`
+	// MaxFPS caps how many frames a second are drawn, or 0 for as many as
+	// the display refreshes.
+	MaxFPS float64 `json:"max_fps"`
+	// Keys moves commands onto other keys, by the action names in the
+	// commands table, such as {"pause": "P", "screenshot": "Ctrl+Shift+S"}.
+	Keys map[string]string `json:"keys"`
+	// KeyframeEvery is how many generations apart the timeline keeps
+	// keyframes to scrub back to, or 0 for no timeline.
+	KeyframeEvery int `json:"keyframe_every"`
+	// FastForward is how many generations G jumps ahead, using HashLife.
+	FastForward uint64 `json:"fast_forward"`
+	// Advance is how many generations Shift+G steps ahead on the GPU, and
+	// Skip how many are stepped the same way before the first frame.
+	Advance int `json:"advance"`
+	Skip    int `json:"skip"`
+	// Instances runs this many independent copies of the simulation side
+	// by side.
+	Instances int `json:"instances"`
+	// Panes runs a simulation side by side for each of these in place of
+	// Instances, with the flags in it over the rest of the config, such as
+	// ["-sim life", "-sim life -topology hex", "-sim table -rule B36/S23"].
+	// They all share the one grid size.
+	Panes []string `json:"panes"`
+	// Seed starts the random source everything random is drawn from, so
+	// that a run can be repeated. 0 picks one from the clock.
+	Seed int64 `json:"seed"`
+	// Integrator is how continuous simulations step forward in time:
+	// euler, rk2, rk4 or semi-implicit.
+	Integrator string `json:"integrator"`
+	// Validate steps this many times on both the GPU and the simulation's
+	// CPU reference at the start, and prints how far apart they end up.
+	Validate int `json:"validate"`
+	// RecordInput is a file to write every input event to as JSON lines.
+	RecordInput string `json:"record_input"`
+	// MSAA is how many samples a pixel simulations are drawn with: 1, or
+	// 4, or 2, 8 or 16 where the adapter can.
+	MSAA int `json:"msaa"`
+	// HTTP is an address to serve the web dashboard on, such as
+	// localhost:8080.
+	HTTP string `json:"http"`
+	// Storage is where snapshots and presets are kept: a directory or
+	// s3://bucket/prefix.
+	Storage string `json:"storage"`
+	// Manifest writes a record of each run under runs/ in Storage.
+	Manifest bool `json:"manifest"`
+	// Tutorial starts in the guided walkthrough of the controls.
+	Tutorial bool `json:"tutorial"`
+	// Palette names the colours cells are drawn in and the background
+	// behind them, from the built-in palettes or Palettes.
+	Palette string `json:"palette"`
+	// Palettes are palettes of the config's own, added to the built-in ones
+	// or replacing them by name.
+	Palettes map[string]PaletteConfig `json:"palettes"`
+	// CellShape draws cells as squares, circles or rounded squares, and
+	// CellSoftness blurs their edges across that much of a cell. An empty
+	// shape or negative softness keeps the palette's own.
+	CellShape    string  `json:"cell_shape"`
+	CellSoftness float32 `json:"cell_softness"`
+	// PNGDepth is the bits a channel that stills, from S and
+	// -fractal-still, are saved with: 8, or 16 to keep the precision of
+	// their averaged samples.
+	PNGDepth int `json:"png_depth"`
+	// Post lists the full screen effects to start with, from bloom,
+	// scanlines, crt and vignette.
+	Post []string `json:"post"`
+	// GridLines starts with lines drawn between cells, which L turns on and
+	// off.
+	GridLines bool `json:"grid_lines"`
+	// HUD starts with the generation, frame rate, rule and population shown
+	// in the corner, which H shows and hides.
+	HUD bool `json:"hud"`
+	// FrameGraph starts with the graph of frame times shown, which Shift+I
+	// shows and hides.
+	FrameGraph bool `json:"frame_graph"`
+	// Locale picks how numbers are written for people, e.g. "de_DE" or
+	// "plain". Empty uses LC_ALL, LC_NUMERIC or LANG.
+	Locale string `json:"locale"`
+}
+
+// GridConfig is the size of the 2D simulations in cells.
+type GridConfig struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type LifeConfig struct {
+	// Topology is "square" for the usual eight neighbours or "hex" for a
+	// hexagonal grid with six.
+	Topology string `json:"topology"`
+	// Boundary is what lies beyond the edges: "torus" wraps around, "dead"
+	// is empty and "mirror" reflects the edge cells.
+	Boundary string `json:"boundary"`
+	// Packed stores 32 cells in each word of the cell buffers instead of
+	// one, which allows grids up to maxPackedGridSize.
+	Packed bool `json:"packed"`
+	// Species is how many populations compete for space. Each newborn takes
+	// the species most of its parents are, and each starts out as an even
+	// share of the live cells.
+	Species int `json:"species"`
+	// ColourBy is "position" to colour cells by where they are or "age" by
+	// how many generations they have been alive.
+	ColourBy string `json:"colour_by"`
+	// AgeGradient names the ramp of colours that colouring by age runs
+	// through, from newborn cells to old ones.
+	AgeGradient string `json:"age_gradient"`
+	// Trail leaves fading tracks where cells have been alive, losing this
+	// much of their strength a generation. 0 leaves none.
+	Trail float32 `json:"trail"`
+	// Renderer is "instances" to draw a quad for every cell, "texture" to
+	// copy the cells into a texture and draw one quad over the grid from
+	// it, which is far cheaper for large grids, or "indirect" to draw a
+	// quad for each live cell only, picked out on the GPU.
+	Renderer string `json:"renderer"`
+}
+
+// TableConfig is the rule of -sim table. Transitions[state][n] is the next
+// state of a cell in state with n live neighbours, those in state 1, for
+// automata a rule can't describe. Without it the table is built from Rule,
+// in B/S/C notation or Larger-than-Life's, which sets its own neighbourhood.
+// Otherwise neighbours are counted over a Neighbourhood, moore or
+// von-neumann, of Radius, or by Weights: a square matrix centred on the
+// cell of how much each cell around it counts for.
+type TableConfig struct {
+	Rule          string     `json:"rule"`
+	Transitions   [][]uint32 `json:"transitions"`
+	Radius        int        `json:"radius"`
+	Neighbourhood string     `json:"neighbourhood"`
+	Weights       [][]uint32 `json:"weights"`
+}
+
+// NBodyConfig is how many bodies -sim nbody has and how it steps them.
+// Softening keeps close passes from flinging bodies off.
+type NBodyConfig struct {
+	Count         int     `json:"count"`
+	DT            float32 `json:"dt"`
+	Softening     float32 `json:"softening"`
+	StepsPerFrame int     `json:"steps_per_frame"`
+}
+
+// SPHConfig is how many particles -sim sph has and how it steps them.
+type SPHConfig struct {
+	Count         int     `json:"count"`
+	DT            float32 `json:"dt"`
+	Stiffness     float32 `json:"stiffness"`
+	Viscosity     float32 `json:"viscosity"`
+	Gravity       float32 `json:"gravity"`
+	StepsPerFrame int     `json:"steps_per_frame"`
+}
+
+// StochasticConfig picks the rule -sim stochastic runs and the chances of
+// its random transitions: for the forest-fire model of a tree growing on
+// empty ground and of lightning striking a tree, and for life of each birth
+// and death B3/S23 calls for happening.
+type StochasticConfig struct {
+	Rule      string  `json:"rule"`
+	Growth    float32 `json:"growth"`
+	Lightning float32 `json:"lightning"`
+	Birth     float32 `json:"birth"`
+	Death     float32 `json:"death"`
+}
+
+// ClothConfig is how many particles along each side -sim cloth has and how
+// it steps them: Iterations is how many times each step relaxes the springs.
+type ClothConfig struct {
+	Size          int     `json:"size"`
+	DT            float32 `json:"dt"`
+	Iterations    int     `json:"iterations"`
+	StepsPerFrame int     `json:"steps_per_frame"`
+}
+
+// FractalConfig is which fractal -sim fractal ray-marches and how:
+// Samples is how many jittered rays each pixel averages once the camera
+// stops, and Still where to save the image when they are all in.
+type FractalConfig struct {
+	Shape      string `json:"shape"`
+	Iterations int    `json:"iterations"`
+	Samples    int    `json:"samples"`
+	Orbit      bool   `json:"orbit"`
+	Still      string `json:"still"`
+}
+
+// InitConfig picks the pattern the life simulations start from and how
+// densely it is filled.
+type InitConfig struct {
+	Pattern string  `json:"pattern"`
+	Density float64 `json:"density"`
+	// File is a .npy array to start from instead, which sets the grid's
+	// size. array is what loadConfig read from it.
+	File  string `json:"file"`
+	array *npyArray
+}
+
+// PluginsConfig is where rule packs are found and what their parameters
+// are set to, over the defaults each one comes with.
+type PluginsConfig struct {
+	Dir    string             `json:"dir"`
+	Params map[string]float64 `json:"params"`
+}
+
+// Life3DConfig is the edge length of the cubic volume and its rule in Bays'
+// notation, survival range then birth range.
+type Life3DConfig struct {
+	Size int    `json:"size"`
+	Rule string `json:"rule"`
+}
+
+type AntConfig struct {
+	// Rule has one letter per cell state: L and R turn, N goes straight on
+	// and U turns around. "RL" is Langton's ant.
+	Rule          string `json:"rule"`
+	Count         int    `json:"count"`
+	StepsPerFrame int    `json:"steps_per_frame"`
+}
+
+// LeniaConfig sets the kernel radius in cells and the centre and width of
+// the growth function. DT is how far each step moves towards the growth.
+type LeniaConfig struct {
+	Radius int     `json:"radius"`
+	Mu     float32 `json:"mu"`
+	Sigma  float32 `json:"sigma"`
+	DT     float32 `json:"dt"`
+	// Precision is single or double-single, which is slower but doesn't
+	// drift over long runs of small steps.
+	Precision string `json:"precision"`
+}
+
+// GrayScottConfig holds the starting feed and kill rates, which can also be
+// changed while running, and the diffusion rates of the two chemicals.
+type GrayScottConfig struct {
+	Feed          float32 `json:"feed"`
+	Kill          float32 `json:"kill"`
+	DiffuseU      float32 `json:"diffuse_u"`
+	DiffuseV      float32 `json:"diffuse_v"`
+	DT            float32 `json:"dt"`
+	StepsPerFrame int     `json:"steps_per_frame"`
+}
+
+type HashLifeConfig struct {
+	StepsPerFrame int `json:"steps_per_frame"`
+}
+
+// AccessibilityConfig changes how every simulation is shown rather than what
+// it does.
+type AccessibilityConfig struct {
+	// ColourblindSafe replaces the simulation's colours with the cividis
+	// palette, which only varies in blue to yellow and in brightness.
+	ColourblindSafe bool `json:"colourblind_safe"`
+	// MaxLuminanceChange caps how far any pixel's brightness can move in
+	// one frame, from 0 to 1, to cut down flashing. 0 turns the cap off.
+	MaxLuminanceChange float32 `json:"max_luminance_change"`
+}
+
+func (a AccessibilityConfig) enabled() bool {
+	return a.ColourblindSafe || a.MaxLuminanceChange != 0
+}
+
+// PaletteConfig is a palette: the Background behind the cells, the Colours
+// of cells in the bottom left, bottom right, top left and top right corners
+// of the grid, which those between blend, and the Shape and Softness of the
+// cells, as -cell-shape and -cell-softness take them. Colours are RGB from
+// 0 to 1.
+type PaletteConfig struct {
+	Background [3]float32    `json:"background"`
+	Colours    [4][3]float32 `json:"colours"`
+	Shape      string        `json:"shape"`
+	Softness   float32       `json:"softness"`
+}
+
+// HDRConfig draws the scene in high dynamic range when Enabled, with live
+// cells Brightness times brighter than white and a glow of Bloom times the
+// light spilling past it. Displays that can't go past white are tonemapped.
+type HDRConfig struct {
+	Enabled    bool    `json:"enabled"`
+	Brightness float32 `json:"brightness"`
+	Bloom      float32 `json:"bloom"`
+}
+
+// TimelapseConfig runs without a window, saving a Width by Height frame
+// every Minutes into Dir. Video, if set, is where to stitch the frames
+// together at FPS once the run is stopped.
+type TimelapseConfig struct {
+	Minutes float64 `json:"minutes"`
+	Dir     string  `json:"dir"`
+	Video   string  `json:"video"`
+	FPS     int     `json:"fps"`
+	Width   int     `json:"width"`
+	Height  int     `json:"height"`
+}
+
+// SessionConfig records every parameter change into Record and replays
+// those recorded in Replay, playing Audio from the start of either.
+type SessionConfig struct {
+	Record string `json:"record"`
+	Replay string `json:"replay"`
+	Audio  string `json:"audio"`
+}
+
+// HighlightsConfig saves clips into Dir when the population, sampled every
+// Every steps, moves more than Threshold standard deviations from its recent
+// mean. Clips have Before samples leading up to the jump and After
+// following it.
+type HighlightsConfig struct {
+	Dir       string  `json:"dir"`
+	Every     int     `json:"every"`
+	Threshold float64 `json:"threshold"`
+	Before    int     `json:"before"`
+	After     int     `json:"after"`
+}
+
+// StillConfig is how S makes stills of the paused simulation: Samples
+// shifted frames averaged together, each Scale times the window's size.
+type StillConfig struct {
+	Samples int `json:"samples"`
+	Scale   int `json:"scale"`
+}
+
+// PosterConfig is how E saves posters: Width pixels across, keeping the
+// window's shape, drawn in tiles of up to Tile pixels along each side.
+type PosterConfig struct {
+	Width int `json:"width"`
+	Tile  int `json:"tile"`
+}
+
+// RecordingConfig is how Shift+F12 records videos: into a Format file,
+// "mp4" or "webm", playing at FPS frames a second, or at the display's
+// refresh rate when FPS is 0.
+type RecordingConfig struct {
+	Format string `json:"format"`
+	FPS    int    `json:"fps"`
+}
+
+// GIFConfig is how animated GIFs are made: a frame every Every of the next
+// Generations, playing at FPS. Shift+E captures them from the window; with
+// Path set the run has no window, and saves them there Width by Height.
+type GIFConfig struct {
+	Path        string `json:"path"`
+	Generations int    `json:"generations"`
+	Every       int    `json:"every"`
+	FPS         int    `json:"fps"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
+
+// maxGridSize keeps the largest cell buffers inside the default storage
+// buffer binding limit of 128MiB. Packed life cells take a bit rather than a
+// word, so the buffers have room for far more, but every cell is still drawn
+// as its own quad.
+const (
+	maxGridSize       = 4096
+	maxPackedGridSize = 16384
+)
+
+func defaultConfig() *Config {
+	return &Config{
+		Simulation:    "life",
+		Palette:       "classic",
+		PNGDepth:      8,
+		CellSoftness:  -1,
+		Manifest:      true,
+		Instances:     1,
+		MSAA:          1,
+		Integrator:    "euler",
+		FastForward:   1024,
+		Advance:       10000,
+		KeyframeEvery: 100,
+		TickRate:      defaultTickRate,
+		Background:    "run",
+		Patterns:      "patterns",
+		Grid: GridConfig{
+			Width:  128,
+			Height: 128,
+		},
+		Life: LifeConfig{
+			Topology:    "square",
+			Boundary:    "torus",
+			Renderer:    "instances",
+			Species:     1,
+			ColourBy:    "position",
+			AgeGradient: "fade",
+		},
+		Table: TableConfig{
+			Rule:          "B2/S/3",
+			Radius:        1,
+			Neighbourhood: "moore",
+		},
+		Stochastic: StochasticConfig{
+			Rule:      "forest-fire",
+			Growth:    0.01,
+			Lightning: 0.00001,
+			Birth:     0.9,
+			Death:     0.9,
+		},
+		Cloth: ClothConfig{
+			Size:          48,
+			DT:            1.0 / 240,
+			Iterations:    16,
+			StepsPerFrame: 4,
+		},
+		Fractal: FractalConfig{
+			Shape:      "mandelbulb",
+			Iterations: 10,
+			Samples:    256,
+			Orbit:      true,
+		},
+		Init: InitConfig{
+			Pattern: "soup",
+			Density: 0.3,
+		},
+		Plugins: PluginsConfig{
+			Dir: "plugins",
+		},
+		Life3D: Life3DConfig{
+			Size: 48,
+			Rule: "4555",
+		},
+		Ant: AntConfig{
+			Rule:          "RL",
+			Count:         1,
+			StepsPerFrame: 10,
+		},
+		NBody: NBodyConfig{
+			Count:         4096,
+			DT:            0.002,
+			Softening:     0.03,
+			StepsPerFrame: 4,
+		},
+		SPH: SPHConfig{
+			Count:         4096,
+			DT:            0.002,
+			Stiffness:     20,
+			Viscosity:     0.01,
+			Gravity:       2,
+			StepsPerFrame: 8,
+		},
+		Lenia: LeniaConfig{
+			Radius:    13,
+			Mu:        0.15,
+			Sigma:     0.015,
+			DT:        0.1,
+			Precision: "single",
+		},
+		Timelapse: TimelapseConfig{
+			FPS:    24,
+			Width:  640,
+			Height: 480,
+		},
+		Highlights: HighlightsConfig{
+			Every:     10,
+			Threshold: 4,
+			Before:    10,
+			After:     20,
+		},
+		HDR: HDRConfig{
+			Brightness: 2,
+			Bloom:      0.6,
+		},
+		Still: StillConfig{
+			Samples: 64,
+			Scale:   2,
+		},
+		Poster: PosterConfig{
+			Width: 8192,
+			Tile:  2048,
+		},
+		Recording: RecordingConfig{
+			Format: "mp4",
+		},
+		GIF: GIFConfig{
+			Generations: 100,
+			Every:       1,
+			FPS:         10,
+			Width:       480,
+			Height:      480,
+		},
+		HashLife: HashLifeConfig{
+			StepsPerFrame: 1,
+		},
+		GrayScott: GrayScottConfig{
+			Feed:          0.055,
+			Kill:          0.062,
+			DiffuseU:      1.0,
+			DiffuseV:      0.5,
+			DT:            1,
+			StepsPerFrame: 20,
+		},
+	}
+}
+
+func (cfg *Config) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.Simulation, "sim", cfg.Simulation, "simulation to run: "+strings.Join(simulationNames(), ", "))
+	fs.StringVar(&cfg.Palette, "palette", cfg.Palette, "colours to draw cells in: "+strings.Join(paletteNames(), ", "))
+	fs.StringVar(&cfg.CellShape, "cell-shape", cfg.CellShape, "shape to draw cells in: "+strings.Join(cellShapeNames(), ", ")+" (default: the palette's)")
+	float32Var(fs, &cfg.CellSoftness, "cell-softness", "how far across a cell its edges blur, 0 to 1 (negative: the palette's)")
+	fs.BoolVar(&cfg.GridLines, "grid-lines", cfg.GridLines, "draw lines between cells")
+	fs.BoolVar(&cfg.HUD, "hud", cfg.HUD, "show the generation, frame rate, rule and population over the simulation")
+	fs.Float64Var(&cfg.TickRate, "tick-rate", cfg.TickRate, "generations a second the simulation runs (0: as fast as it can)")
+	fs.BoolVar(&cfg.FrameGraph, "frame-graph", cfg.FrameGraph, "show a graph of the CPU and GPU time frames take")
+	fs.IntVar(&cfg.PNGDepth, "png-depth", cfg.PNGDepth, "bits a channel stills are saved with: 8 or 16")
+	fs.IntVar(&cfg.Grid.Width, "width", cfg.Grid.Width, "grid width in cells")
+	fs.IntVar(&cfg.Grid.Height, "height", cfg.Grid.Height, "grid height in cells")
+	fs.StringVar(&cfg.Life.Topology, "topology", cfg.Life.Topology, "life grid topology: square or hex")
+	fs.StringVar(&cfg.Life.Boundary, "boundary", cfg.Life.Boundary, "life edges: torus, dead or mirror")
+	fs.BoolVar(&cfg.Life.Packed, "packed", cfg.Life.Packed, "pack 32 life cells into each word, for grids up to 16384 cells across")
+	fs.StringVar(&cfg.Life.Renderer, "renderer", cfg.Life.Renderer, "how life cells are drawn: "+strings.Join(lifeRenderers, ", "))
+	fs.StringVar(&cfg.Life.ColourBy, "colour-by", cfg.Life.ColourBy, "colour life cells by position or age")
+	float32Var(fs, &cfg.Life.Trail, "trail", "how much of the tracks cells leave fades each generation, 0 to 1 (0 for none)")
+	fs.StringVar(&cfg.Life.AgeGradient, "age-gradient", cfg.Life.AgeGradient, "colours -colour-by age runs through: "+strings.Join(ageGradientNames(), ", "))
+	fs.IntVar(&cfg.Life.Species, "species", cfg.Life.Species, fmt.Sprintf("how many life species compete for space, up to %d", maxSpecies))
+	fs.StringVar(&cfg.Init.Pattern, "init", cfg.Init.Pattern, "life starting pattern: "+strings.Join(generatorNames(), ", "))
+	fs.Float64Var(&cfg.Init.Density, "density", cfg.Init.Density, "fraction of the starting pattern that is alive, 0 to 1")
+	fs.StringVar(&cfg.Init.File, "init-file", cfg.Init.File, "start from this .npy array instead of a pattern, on a grid its size")
+	fs.StringVar(&cfg.Patterns, "patterns", cfg.Patterns, "directory of RLE patterns to stamp onto life")
+	fs.StringVar(&cfg.Plugins.Dir, "plugins", cfg.Plugins.Dir, "directory of rule pack plugins, each run with -sim and its directory's name")
+	fs.Var(pluginParamValue{&cfg.Plugins.Params}, "plugin-param", "set a plugin's parameter as name=value, repeatable")
+	fs.StringVar(&cfg.Table.Rule, "table-rule", cfg.Table.Rule, "rule for -sim table in B/S/C or Larger-than-Life notation, e.g. B2/S/3 or R5,C0,M1,S34..58,B34..45,NM")
+	fs.IntVar(&cfg.Table.Radius, "table-radius", cfg.Table.Radius, "neighbourhood radius for -sim table")
+	fs.StringVar(&cfg.Table.Neighbourhood, "table-neighbourhood", cfg.Table.Neighbourhood, "neighbourhood for -sim table: moore or von-neumann")
+	fs.StringVar(&cfg.Stochastic.Rule, "stochastic-rule", cfg.Stochastic.Rule, "rule for -sim stochastic: forest-fire or life")
+	float32Var(fs, &cfg.Stochastic.Growth, "stochastic-growth", "chance of a tree growing on empty ground each generation")
+	float32Var(fs, &cfg.Stochastic.Lightning, "stochastic-lightning", "chance of lightning striking a tree each generation")
+	float32Var(fs, &cfg.Stochastic.Birth, "stochastic-birth", "chance of each stochastic life birth happening")
+	float32Var(fs, &cfg.Stochastic.Death, "stochastic-death", "chance of each stochastic life death happening")
+	fs.IntVar(&cfg.Cloth.Size, "cloth-size", cfg.Cloth.Size, "particles along each side of -sim cloth")
+	float32Var(fs, &cfg.Cloth.DT, "cloth-dt", "cloth time step")
+	fs.IntVar(&cfg.Cloth.Iterations, "cloth-iterations", cfg.Cloth.Iterations, "times each cloth step relaxes the springs")
+	fs.IntVar(&cfg.Cloth.StepsPerFrame, "cloth-steps", cfg.Cloth.StepsPerFrame, "cloth steps per frame")
+	fs.StringVar(&cfg.Fractal.Shape, "fractal", cfg.Fractal.Shape, "fractal for -sim fractal: mandelbulb or menger")
+	fs.IntVar(&cfg.Fractal.Iterations, "fractal-iterations", cfg.Fractal.Iterations, "iterations of the fractal's distance estimate")
+	fs.IntVar(&cfg.Fractal.Samples, "fractal-samples", cfg.Fractal.Samples, "samples each fractal pixel averages while the camera is still")
+	fs.BoolVar(&cfg.Fractal.Orbit, "fractal-orbit", cfg.Fractal.Orbit, "orbit the camera around the fractal (O toggles)")
+	fs.StringVar(&cfg.Fractal.Still, "fractal-still", cfg.Fractal.Still, "save the fractal to this PNG once its samples are in")
+	fs.IntVar(&cfg.Life3D.Size, "size-3d", cfg.Life3D.Size, "edge length of the 3d life volume")
+	fs.IntVar(&cfg.NBody.Count, "nbody-count", cfg.NBody.Count, "bodies in -sim nbody")
+	float32Var(fs, &cfg.NBody.DT, "nbody-dt", "n-body time step")
+	float32Var(fs, &cfg.NBody.Softening, "nbody-softening", "n-body softening length")
+	fs.IntVar(&cfg.NBody.StepsPerFrame, "nbody-steps", cfg.NBody.StepsPerFrame, "n-body steps per frame")
+	fs.IntVar(&cfg.SPH.Count, "sph-count", cfg.SPH.Count, "particles in -sim sph")
+	float32Var(fs, &cfg.SPH.DT, "sph-dt", "SPH time step")
+	float32Var(fs, &cfg.SPH.Stiffness, "sph-stiffness", "how hard SPH particles push back when crowded")
+	float32Var(fs, &cfg.SPH.Viscosity, "sph-viscosity", "SPH viscosity")
+	float32Var(fs, &cfg.SPH.Gravity, "sph-gravity", "SPH gravity")
+	fs.IntVar(&cfg.SPH.StepsPerFrame, "sph-steps", cfg.SPH.StepsPerFrame, "SPH steps per frame")
+	fs.StringVar(&cfg.Life3D.Rule, "rule-3d", cfg.Life3D.Rule, "3d life rule, e.g. 4555 or 5,7,6,6")
+	fs.StringVar(&cfg.Ant.Rule, "ant-rule", cfg.Ant.Rule, "turmite rule string, e.g. RL or LLRR")
+	fs.IntVar(&cfg.Ant.Count, "ants", cfg.Ant.Count, "number of ants")
+	fs.IntVar(&cfg.Ant.StepsPerFrame, "ant-steps", cfg.Ant.StepsPerFrame, "ant moves per frame")
+	fs.Uint64Var(&cfg.FastForward, "fast-forward", cfg.FastForward, "generations to jump ahead with G")
+	fs.IntVar(&cfg.Advance, "advance", cfg.Advance, "generations to step ahead on the GPU with Shift+G")
+	fs.IntVar(&cfg.Skip, "skip", cfg.Skip, "generations to step ahead on the GPU before the first frame")
+	fs.IntVar(&cfg.HashLife.StepsPerFrame, "hashlife-steps", cfg.HashLife.StepsPerFrame, "generations per frame for -sim hashlife")
+	fs.IntVar(&cfg.Instances, "instances", cfg.Instances, "independent copies of the simulation to run side by side")
+	fs.Var(paneList{&cfg.Panes}, "panes", "simulations to run side by side, as flags over the others, separated by semicolons")
+	fs.StringVar(&cfg.Integrator, "integrator", cfg.Integrator, "time integrator for gray-scott and lenia: "+strings.Join(integratorNames(), ", "))
+	fs.IntVar(&cfg.Validate, "validate", cfg.Validate, "check this many steps against the CPU reference at the start (lenia only; slow)")
+	fs.Int64Var(&cfg.Seed, "seed", cfg.Seed, "seed for the random starting state, to repeat a run (default: from the clock)")
+	fs.StringVar(&cfg.RecordInput, "record-input", cfg.RecordInput, "write timestamped input events to this file")
+	fs.StringVar(&cfg.Session.Record, "record-session", cfg.Session.Record, "write every parameter change, with when it happened, to this file")
+	fs.StringVar(&cfg.Session.Replay, "replay-session", cfg.Session.Replay, "replay the parameter changes recorded in this file at the pace they were made")
+	fs.StringVar(&cfg.Session.Audio, "session-audio", cfg.Session.Audio, "play this audio file with ffplay from the start of a recorded or replayed session")
+	fs.IntVar(&cfg.MSAA, "msaa", cfg.MSAA, "samples a pixel to draw simulations with, smoothing their edges: 1, 4, or 2, 8 or 16 where the adapter can")
+	fs.StringVar(&cfg.HTTP, "http", cfg.HTTP, "serve a web dashboard of stats, the frame, parameters and logs on this address, such as localhost:8080")
+	fs.StringVar(&cfg.Locale, "locale", cfg.Locale, "number formatting locale, e.g. en_GB, de_DE or plain (default: from the environment)")
+	fs.BoolVar(&cfg.Tutorial, "tutorial", cfg.Tutorial, "start with a guided tour of the controls")
+	fs.BoolVar(&cfg.Manifest, "manifest", cfg.Manifest, "write a run manifest to storage")
+	fs.IntVar(&cfg.KeyframeEvery, "keyframe-every", cfg.KeyframeEvery, "generations between the timeline's keyframes that Alt+Left scrubs back to, 0 for none")
+	fs.Float64Var(&cfg.MaxFPS, "max-fps", cfg.MaxFPS, "most frames a second to draw (default: as many as the display refreshes)")
+	fs.StringVar(&cfg.Background, "background", cfg.Background, "what to do while the window is out of focus or minimised: "+strings.Join(backgroundModes, ", "))
+	fs.BoolVar(&cfg.Trackpad, "trackpad", cfg.Trackpad, "pan with two fingers and zoom with a pinch on a trackpad, instead of zooming by scrolling")
+	fs.StringVar(&cfg.Snapshot, "snapshot", cfg.Snapshot, "carry on from the snapshot of this name, saved with Ctrl+S")
+	fs.StringVar(&cfg.Storage, "storage", cfg.Storage, "directory or s3://bucket/prefix for snapshots and presets (default: user config dir)")
+	fs.BoolVar(&cfg.Accessibility.ColourblindSafe, "colourblind-safe", cfg.Accessibility.ColourblindSafe, "show every simulation in a colourblind-safe palette")
+	float32Var(fs, &cfg.Accessibility.MaxLuminanceChange, "max-flash", "largest brightness change per frame, 0 to 1 (0 for no limit)")
+	fs.Var(listValue{&cfg.Post}, "post", "full screen effects to start with, comma-separated: bloom, scanlines, crt, vignette")
+	fs.BoolVar(&cfg.HDR.Enabled, "hdr", cfg.HDR.Enabled, "draw in high dynamic range, on an HDR display where the surface offers one and tonemapped otherwise")
+	float32Var(fs, &cfg.HDR.Brightness, "hdr-brightness", "times brighter than white live cells are drawn with -hdr")
+	float32Var(fs, &cfg.HDR.Bloom, "bloom", "how strongly light past white glows with -hdr (0 for none)")
+	fs.Float64Var(&cfg.Timelapse.Minutes, "timelapse", cfg.Timelapse.Minutes, "run headless and save a frame every this many minutes")
+	fs.StringVar(&cfg.Timelapse.Dir, "timelapse-dir", cfg.Timelapse.Dir, "directory for time-lapse frames (default: timelapse-<start time>)")
+	fs.StringVar(&cfg.Timelapse.Video, "timelapse-video", cfg.Timelapse.Video, "stitch the time-lapse into this video with ffmpeg when stopped")
+	fs.StringVar(&cfg.Highlights.Dir, "highlights", cfg.Highlights.Dir, "save clips of sudden population changes into this directory")
+	fs.IntVar(&cfg.Still.Samples, "still-samples", cfg.Still.Samples, "shifted frames S averages into a still")
+	fs.IntVar(&cfg.Still.Scale, "still-scale", cfg.Still.Scale, "times the window's size S makes stills at")
+	fs.IntVar(&cfg.Poster.Width, "poster-width", cfg.Poster.Width, "width in pixels of the posters E saves, up to 16384")
+	fs.IntVar(&cfg.Poster.Tile, "poster-tile", cfg.Poster.Tile, "size of the tiles posters are drawn in")
+	fs.StringVar(&cfg.Recording.Format, "recording-format", cfg.Recording.Format, "container Shift+F12 records videos in: mp4 or webm")
+	fs.IntVar(&cfg.Recording.FPS, "recording-fps", cfg.Recording.FPS, "frame rate recorded videos play at (0: the display's)")
+	fs.StringVar(&cfg.GIF.Path, "gif", cfg.GIF.Path, "run headless and save the first -gif-generations as an animated GIF here")
+	fs.IntVar(&cfg.GIF.Generations, "gif-generations", cfg.GIF.Generations, "generations GIFs cover")
+	fs.IntVar(&cfg.GIF.Every, "gif-every", cfg.GIF.Every, "generations between GIF frames")
+	fs.IntVar(&cfg.GIF.FPS, "gif-fps", cfg.GIF.FPS, "frame rate GIFs play at, up to 100")
+	fs.Float64Var(&cfg.Highlights.Threshold, "highlight-threshold", cfg.Highlights.Threshold, "standard deviations from the recent mean that count as a highlight")
+	fs.IntVar(&cfg.Lenia.Radius, "lenia-radius", cfg.Lenia.Radius, "lenia kernel radius in cells")
+	float32Var(fs, &cfg.Lenia.Mu, "lenia-mu", "lenia growth centre")
+	float32Var(fs, &cfg.Lenia.Sigma, "lenia-sigma", "lenia growth width")
+	float32Var(fs, &cfg.Lenia.DT, "lenia-dt", "lenia time step")
+	fs.StringVar(&cfg.Lenia.Precision, "lenia-precision", cfg.Lenia.Precision, "lenia state precision: single, or double-single which is slower but doesn't drift")
+	float32Var(fs, &cfg.GrayScott.Feed, "gs-feed", "gray-scott feed rate")
+	float32Var(fs, &cfg.GrayScott.Kill, "gs-kill", "gray-scott kill rate")
+	float32Var(fs, &cfg.GrayScott.DT, "gs-dt", "gray-scott time step")
+	fs.IntVar(&cfg.GrayScott.StepsPerFrame, "gs-steps", cfg.GrayScott.StepsPerFrame, "gray-scott iterations per frame")
+}
+
+type float32Value struct{ p *float32 }
+
+func (v float32Value) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return strconv.FormatFloat(float64(*v.p), 'g', -1, 32)
+}
+
+func (v float32Value) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return err
+	}
+	*v.p = float32(f)
+	return nil
+}
+
+// pluginParamValue adds name=value to a map of plugin parameters. Presets
+// parse their flags over a copy of the config, so the map is copied rather
+// than changed in place.
+type pluginParamValue struct{ p *map[string]float64 }
+
+func (v pluginParamValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	var params []string
+	for name, value := range *v.p {
+		params = append(params, name+"="+strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	sort.Strings(params)
+	return strings.Join(params, ",")
+}
+
+func (v pluginParamValue) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("want name=value, got %q", s)
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+	params := map[string]float64{name: f}
+	for k, old := range *v.p {
+		if k != name {
+			params[k] = old
+		}
+	}
+	*v.p = params
+	return nil
+}
+
+// listValue is a comma-separated list, replacing any list before it.
+type listValue struct{ p *[]string }
+
+func (v listValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return strings.Join(*v.p, ",")
+}
+
+func (v listValue) Set(s string) error {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*v.p = list
+	return nil
+}
+
+// paneList is a semicolon-separated list of panes, each a set of flags,
+// replacing any list before it.
+type paneList struct{ p *[]string }
+
+func (v paneList) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return strings.Join(*v.p, "; ")
+}
+
+func (v paneList) Set(s string) error {
+	var panes []string
+	for _, pane := range strings.Split(s, ";") {
+		if pane = strings.TrimSpace(pane); pane != "" {
+			panes = append(panes, pane)
+		}
+	}
+	*v.p = panes
+	return nil
+}
+
+func float32Var(fs *flag.FlagSet, p *float32, name, usage string) {
+	fs.Var(float32Value{p}, name, usage)
+}
+
+// loadConfig builds the configuration from the defaults, the file named by
+// -config and finally the remaining flags in args.
+func loadConfig(args []string) (*Config, error) {
+	cfg := defaultConfig()
+
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	path := fs.String("config", "", "path to a JSON config file")
+	cfg.registerFlags(fs)
+
+	// Parse once to find the config file, then again so that flags take
+	// precedence over whatever the file set.
+	fs.Parse(args)
+	if *path != "" {
+		b, err := os.ReadFile(*path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, cfg); err != nil {
+			return nil, fmt.Errorf("%s: %w", *path, err)
+		}
+		fs.Parse(args)
+	}
+	if err := loadPlugins(cfg.Plugins.Dir); err != nil {
+		return nil, err
+	}
+	if cfg.Instances < 1 {
+		return nil, fmt.Errorf("need at least one instance, got %d", cfg.Instances)
+	}
+	for _, pane := range cfg.Panes {
+		if _, err := presetConfig(cfg, pane); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Init.File != "" {
+		a, err := readNPY(cfg.Init.File)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Init.array = a
+		cfg.Grid = GridConfig{Width: a.width, Height: a.height}
+	}
+	if cfg.TickRate != 0 && (cfg.TickRate < minTickRate || cfg.TickRate > maxTickRate) {
+		return nil, fmt.Errorf("tick rate %g out of range [%d, %d], or 0 for as fast as it can", cfg.TickRate, minTickRate, maxTickRate)
+	}
+	if cfg.MaxFPS < 0 {
+		return nil, fmt.Errorf("can't draw %g frames a second", cfg.MaxFPS)
+	}
+	if err := parseBackground(cfg.Background); err != nil {
+		return nil, err
+	}
+	if cfg.Advance < 0 || cfg.Skip < 0 {
+		return nil, fmt.Errorf("can't step a negative number of generations ahead")
+	}
+	if cfg.KeyframeEvery < 0 {
+		return nil, fmt.Errorf("can't keep keyframes every %d generations", cfg.KeyframeEvery)
+	}
+	if cfg.PNGDepth != 8 && cfg.PNGDepth != 16 {
+		return nil, fmt.Errorf("PNG depth must be 8 or 16, got %d", cfg.PNGDepth)
+	}
+	if limit := cfg.gridLimit(); cfg.Grid.Width < 1 || cfg.Grid.Height < 1 || cfg.Grid.Width > limit || cfg.Grid.Height > limit {
+		return nil, fmt.Errorf("grid size %dx%d out of range [1, %d]", cfg.Grid.Width, cfg.Grid.Height, limit)
+	}
+	return cfg, nil
+}
+
+// gridLimit is the largest grid width or height the simulation can start
+// with.
+func (cfg *Config) gridLimit() int {
+	switch {
+	case cfg.Simulation == "life" && cfg.Life.Packed:
+		return maxPackedGridSize
+	case cfg.Simulation == "life-sparse":
+		return maxSparseView
+	}
+	return maxGridSize
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed cull.wgsl
+var cullCompute string
+
+// cellCuller draws only the life cells that show. After every step a
+// compute pass appends the live cells, and those with trails, to a buffer
+// and counts them into the arguments of an indirect draw, which culled_vs
+// in the draw shader then draws from. Sparse grids draw a few quads rather
+// than one for every cell, dead or not.
+type cellCuller struct {
+	layout         *wgpu.BindGroupLayout
+	visibleLayout  *wgpu.BindGroupLayout
+	pipelineLayout *wgpu.PipelineLayout
+	drawLayout     *wgpu.PipelineLayout
+	reset          *wgpu.ComputePipeline
+	cull           *wgpu.ComputePipeline
+	pipeline       *wgpu.RenderPipeline
+
+	// visible has room for every cell, and args is the draw's.
+	visible *wgpu.Buffer
+	args    *wgpu.Buffer
+	// groups cull each of the cell buffers, and visibleGroup draws.
+	groups        []*wgpu.BindGroup
+	visibleGroup  *wgpu.BindGroup
+	width, height int
+}
+
+// newCellCuller culls l's cells, drawing them with drawShader, the one l
+// draws every cell with.
+func newCellCuller(s *State, l *Life, drawShader *wgpu.ShaderModule) (c *cellCuller, err error) {
+	if l.topology != "square" {
+		return nil, fmt.Errorf("the indirect renderer needs the square topology")
+	}
+	c = &cellCuller{}
+	defer func() {
+		if err != nil {
+			c.Release()
+		}
+	}()
+
+	c.layout, err = s.bindGroupLayout("cull",
+		bufferEntry(0, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(2, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(3, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(4, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(5, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+	)
+	if err != nil {
+		return nil, err
+	}
+	c.visibleLayout, err = s.bindGroupLayout("visible cells",
+		bufferEntry(0, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_ReadOnlyStorage),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if c.pipelineLayout, err = s.pipelineLayout("cull", c.layout); err != nil {
+		return nil, err
+	}
+	if c.drawLayout, err = s.pipelineLayout("culled cells", l.bindGroupLayout, s.cameraLayout, c.visibleLayout); err != nil {
+		return nil, err
+	}
+
+	shader := s.createShader("cull", cullCompute)
+	defer shader.Release()
+	if c.reset, err = s.computePipeline("cull reset", c.pipelineLayout, shader, "reset"); err != nil {
+		return nil, err
+	}
+	entry := "unpacked"
+	if l.packed {
+		entry = "packed"
+	}
+	if c.cull, err = s.computePipeline("cull", c.pipelineLayout, shader, entry); err != nil {
+		return nil, err
+	}
+	if c.pipeline, err = s.renderPipeline("culled cells", c.drawLayout, drawShader, "culled_vs", "main_fs"); err != nil {
+		return nil, err
+	}
+
+	// The vertex count stays as it is; the compute pass only ever sets the
+	// instances.
+	c.args, err = s.device.CreateBufferInit(&wgpu.BufferInitDescriptor{
+		Label:    "culled draw",
+		Contents: wgpu.ToBytes([]uint32{l.vertexCount, 0, 0, 0}),
+		Usage:    wgpu.BufferUsage_Storage | wgpu.BufferUsage_Indirect,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// setCells binds the life's cell buffers, making the buffer of visible
+// cells over again if the grid has changed size, and culls the current
+// generation.
+func (c *cellCuller) setCells(s *State, l *Life, trail *wgpu.Buffer) error {
+	if c.width != l.width || c.height != l.height {
+		if c.visible != nil {
+			c.visible.Release()
+		}
+		var err error
+		c.visible, err = s.device.CreateBuffer(&wgpu.BufferDescriptor{
+			Label: "visible cells",
+			Size:  uint64(l.width*l.height) * 4,
+			Usage: wgpu.BufferUsage_Storage,
+		})
+		if err != nil {
+			return err
+		}
+		c.width, c.height = l.width, l.height
+	}
+	c.releaseGroups()
+	for i := range l.cellStateStorage {
+		c.groups = append(c.groups, s.bindGroup("cull", c.layout,
+			s.gridBuffer, l.cellStateStorage[i], trail, l.boundaryBuffer, c.visible, c.args))
+	}
+	c.visibleGroup = s.bindGroup("visible cells", c.visibleLayout, c.visible)
+
+	encoder, err := s.newEncoder()
+	if err != nil {
+		return err
+	}
+	defer encoder.Release()
+	c.update(encoder, l.steps%2)
+	cmdBuffer, err := encoder.Finish(nil)
+	if err != nil {
+		return err
+	}
+	defer cmdBuffer.Release()
+	s.queue.Submit(cmdBuffer)
+	return nil
+}
+
+// update records culling the cells in buffer side.
+func (c *cellCuller) update(encoder *commandEncoder, side int) {
+	pass := encoder.BeginComputePass(nil)
+	defer pass.Release()
+	pass.SetBindGroup(0, c.groups[side], nil)
+	pass.SetPipeline(c.reset)
+	pass.DispatchWorkgroups(1, 1, 1)
+	pass.SetPipeline(c.cull)
+	pass.DispatchWorkgroups(uint32(c.width+15)/16, uint32(c.height+15)/16, 1)
+	pass.End()
+}
+
+// draw draws the cells culled last, with l's bindings.
+func (c *cellCuller) draw(pass *renderPass, l *Life) {
+	pass.SetPipeline(c.pipeline)
+	pass.SetBindGroup(0, l.gridBindGroups[l.steps%2], nil)
+	pass.SetBindGroup(1, l.camera.bindGroup, nil)
+	pass.SetBindGroup(2, c.visibleGroup, nil)
+	pass.SetVertexBuffer(0, l.vertices, 0, wgpu.WholeSize)
+	pass.DrawIndirect(c.args, 0)
+}
+
+func (c *cellCuller) releaseGroups() {
+	for _, g := range c.groups {
+		g.Release()
+	}
+	c.groups = nil
+	if c.visibleGroup != nil {
+		c.visibleGroup.Release()
+		c.visibleGroup = nil
+	}
+}
+
+func (c *cellCuller) Release() {
+	c.releaseGroups()
+	if c.visible != nil {
+		c.visible.Release()
+		c.visible = nil
+	}
+	if c.args != nil {
+		c.args.Release()
+		c.args = nil
+	}
+	if c.pipeline != nil {
+		c.pipeline.Release()
+		c.pipeline = nil
+	}
+	if c.cull != nil {
+		c.cull.Release()
+		c.cull = nil
+	}
+	if c.reset != nil {
+		c.reset.Release()
+		c.reset = nil
+	}
+	if c.drawLayout != nil {
+		c.drawLayout.Release()
+		c.drawLayout = nil
+	}
+	if c.pipelineLayout != nil {
+		c.pipelineLayout.Release()
+		c.pipelineLayout = nil
+	}
+	if c.visibleLayout != nil {
+		c.visibleLayout.Release()
+		c.visibleLayout = nil
+	}
+	if c.layout != nil {
+		c.layout.Release()
+		c.layout = nil
+	}
+}
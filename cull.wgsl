@@ -0,0 +1,52 @@
+// Picks out the life cells worth drawing, the live ones and the dead ones
+// whose trails still show, into visible, counting them into the instances
+// of the indirect draw in args.
+struct DrawArgs {
+  vertexCount: u32,
+  instanceCount: atomic<u32>,
+  firstVertex: u32,
+  firstInstance: u32,
+};
+
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<storage> cells: array<u32>;
+@group(0) @binding(2) var<storage> trail: array<f32>;
+// life.w is 1 when there are trails.
+@group(0) @binding(3) var<uniform> life: vec4<u32>;
+@group(0) @binding(4) var<storage, read_write> visible: array<u32>;
+@group(0) @binding(5) var<storage, read_write> args: DrawArgs;
+
+@compute @workgroup_size(1)
+fn reset() {
+  atomicStore(&args.instanceCount, 0u);
+}
+
+fn keep(i: u32) {
+  visible[atomicAdd(&args.instanceCount, 1u)] = i;
+}
+
+@compute @workgroup_size(16, 16)
+fn unpacked(@builtin(global_invocation_id) id: vec3<u32>) {
+  let size = vec2<u32>(grid);
+  if id.x >= size.x || id.y >= size.y {
+    return;
+  }
+  let i = id.y * size.x + id.x;
+  // The same threshold draw.wgsl leaves trails out below.
+  if cells[i] > 0u || (life.w == 1u && trail[i] > 1.0 / 256.0) {
+    keep(i);
+  }
+}
+
+// Packed rows are (width + 31) / 32 words, cell x in bit x % 32.
+@compute @workgroup_size(16, 16)
+fn packed(@builtin(global_invocation_id) id: vec3<u32>) {
+  let size = vec2<u32>(grid);
+  if id.x >= size.x || id.y >= size.y {
+    return;
+  }
+  let word = cells[id.y * ((size.x + 31u) / 32u) + id.x / 32u];
+  if ((word >> (id.x % 32u)) & 1u) != 0u {
+    keep(id.y * size.x + id.x);
+  }
+}
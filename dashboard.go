@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// logLines is how many lines of output the dashboard keeps to show.
+const logLines = 500
+
+// dashboard serves a small web UI and the JSON API behind it, so that a run,
+// headless or not, can be watched and tuned from a browser. Everything that
+// touches the engine goes through RunOnMainThread.
+type dashboard struct {
+	server *http.Server
+	logs   *logRing
+	// release puts standard output back as it was.
+	release func()
+}
+
+// dashboardStatus is what /api/status reports, polled by the dashboard for
+// its charts.
+type dashboardStatus struct {
+	Simulation string  `json:"simulation"`
+	Step       int     `json:"step"`
+	Uptime     float64 `json:"uptime"`
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	// Population is null for simulations that can't count theirs.
+	Population  *float64 `json:"population"`
+	DrawCalls   int      `json:"draw_calls"`
+	Instances   uint64   `json:"instances"`
+	Dispatches  int      `json:"dispatches"`
+	UploadBytes uint64   `json:"upload_bytes"`
+}
+
+func startDashboard(s *State, addr string) (*dashboard, error) {
+	static, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dashboard: %w", err)
+	}
+	d := &dashboard{}
+	d.logs, d.release = captureOutput(logLines)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.FS(static)))
+	mux.HandleFunc("/api/status", d.engine(s, d.status))
+	mux.HandleFunc("/api/frame.png", d.engine(s, d.frame))
+	mux.HandleFunc("/api/params", sameOriginOnly(d.engine(s, d.params)))
+	mux.HandleFunc("/api/params/ws", d.syncParams(s))
+	mux.HandleFunc("/api/logs", d.serveLogs)
+	d.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := d.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Println("dashboard:", err)
+		}
+	}()
+	fmt.Printf("dashboard on http://%s/\n", listener.Addr())
+	return d, nil
+}
+
+// engine wraps a handler that needs the engine, running it on the main
+// thread between frames. Its result is sent back as JSON.
+func (d *dashboard) engine(s *State, h func(s *State, r *http.Request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var v any
+		var err error
+		if perr := s.RunOnMainThread(func() { v, err = h(s, r) }); perr != nil {
+			http.Error(w, perr.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if img, ok := v.(pngImage); ok {
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Cache-Control", "no-store")
+			png.Encode(w, img.Image)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+func (d *dashboard) status(s *State, r *http.Request) (any, error) {
+	st := dashboardStatus{
+		Simulation:  s.cfg.Simulation,
+		Step:        s.steps,
+		Uptime:      time.Since(s.start).Seconds(),
+		Width:       s.gridWidth,
+		Height:      s.gridHeight,
+		DrawCalls:   s.lastStats.drawCalls,
+		Instances:   s.lastStats.instances,
+		Dispatches:  s.lastStats.dispatches,
+		UploadBytes: s.lastStats.uploadBytes,
+	}
+	if c, ok := s.sim.(populationCounter); ok {
+		p, err := c.Population(s)
+		if err != nil {
+			return nil, err
+		}
+		st.Population = &p
+	}
+	return st, nil
+}
+
+// pngImage is a handler's result to send as a PNG rather than JSON.
+type pngImage struct{ image.Image }
+
+func (d *dashboard) frame(s *State, r *http.Request) (any, error) {
+	img, err := s.captureFrame()
+	if err != nil {
+		return nil, err
+	}
+	return pngImage{img}, nil
+}
+
+// sameOrigin is whether r comes from a page the dashboard served, or from
+// something other than a browser, which sends no Origin. Browsers say where
+// a request comes from, so that other sites open in them can't use the
+// dashboard. A site can still have its own name lead to the dashboard, by
+// DNS rebinding, so only requests made to ownHost are trusted at all.
+func sameOrigin(r *http.Request) bool {
+	if !ownHost(r) {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return r.Header.Get("Sec-Fetch-Site") != "cross-site"
+	}
+	u, err := url.Parse(origin)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && strings.EqualFold(u.Host, r.Host)
+}
+
+// ownHost is whether r was made to a name for the dashboard that no other
+// site can have: localhost, a loopback address or the address the
+// connection came in on.
+func ownHost(r *http.Request) bool {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	if ip == nil {
+		return false
+	}
+	local, _ := r.Context().Value(http.LocalAddrContextKey).(*net.TCPAddr)
+	return ip.IsLoopback() || local != nil && local.IP.Equal(ip)
+}
+
+// sameOriginOnly refuses requests that change anything from anywhere but
+// the dashboard's own pages.
+func sameOriginOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && !sameOrigin(r) {
+			http.Error(w, "cross-origin requests can't change parameters", http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// params lists the simulation's parameters, after setting those given as
+// query values on a POST.
+func (d *dashboard) params(s *State, r *http.Request) (any, error) {
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		for name := range r.Form {
+			v, err := strconv.ParseFloat(r.Form.Get(name), 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			if err := s.setParameter(name, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s.parameters.snapshot().Parameters, nil
+}
+
+// parameterChange is a message a WebSocket client sends to set a parameter.
+type parameterChange struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// syncParams keeps a WebSocket client's controls in step with everyone
+// else's. It sends the parameters on connecting and whenever they change,
+// however they were changed, and sets those the client sends as
+// parameterChanges. Only the client that sent a change hears if it failed.
+func (d *dashboard) syncParams(s *State) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgradeWebSocket(w, r)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		updates, stop := s.parameters.watch()
+		defer stop()
+
+		go func() {
+			// Closing the connection when the updates stop, as the engine
+			// shuts down, is what ends the read loop below.
+			defer ws.Close()
+			for snap := range updates {
+				msg, _ := json.Marshal(snap)
+				if ws.writeText(msg) != nil {
+					return
+				}
+			}
+		}()
+		for {
+			msg, err := ws.read()
+			if err != nil {
+				return
+			}
+			var change parameterChange
+			err = json.Unmarshal([]byte(msg), &change)
+			if err == nil {
+				if perr := s.RunOnMainThread(func() { err = s.setParameter(change.Name, change.Value) }); perr != nil {
+					err = perr
+				}
+			}
+			if err != nil {
+				reply, _ := json.Marshal(map[string]string{"error": err.Error()})
+				ws.writeText(reply)
+			}
+		}
+	}
+}
+
+// serveLogs sends the lines of output after the one numbered by the after
+// query value, and the number of the last.
+func (d *dashboard) serveLogs(w http.ResponseWriter, r *http.Request) {
+	after, _ := strconv.Atoi(r.URL.Query().Get("after"))
+	lines, last := d.logs.since(after)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Lines []string `json:"lines"`
+		Last  int      `json:"last"`
+	}{lines, last})
+}
+
+func (d *dashboard) Close() error {
+	err := d.server.Close()
+	d.release()
+	return err
+}
+
+// logRing keeps the last lines of output, numbered from 1.
+type logRing struct {
+	mu    sync.Mutex
+	lines []string
+	last  int
+}
+
+func (l *logRing) add(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.lines) == cap(l.lines) {
+		copy(l.lines, l.lines[1:])
+		l.lines = l.lines[:len(l.lines)-1]
+	}
+	l.lines = append(l.lines, line)
+	l.last++
+}
+
+func (l *logRing) since(after int) ([]string, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := min(max(l.last-after, 0), len(l.lines))
+	return append([]string{}, l.lines[len(l.lines)-n:]...), l.last
+}
+
+// captureOutput copies everything printed, to standard output or through
+// the log package, into a logRing of size lines as well. Messages are
+// printed from all over, so it is standard output itself that is swapped
+// for a pipe. The pipe is read for as long as it is open, whatever is
+// written to it, so that printing never blocks; release puts standard
+// output back, once everything written to the pipe has been passed on.
+func captureOutput(size int) (ring *logRing, release func()) {
+	ring = &logRing{lines: make([]string, 0, size)}
+	r, w, err := os.Pipe()
+	if err != nil {
+		log.Println("dashboard: can't capture output:", err)
+		return ring, func() {}
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	log.SetOutput(io.MultiWriter(os.Stderr, ringWriter{ring}))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer r.Close()
+		lines := bufio.NewReader(r)
+		for {
+			line, err := lines.ReadString('\n')
+			stdout.WriteString(line)
+			if line != "" {
+				ring.add(strings.TrimSuffix(line, "\n"))
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return ring, func() {
+		os.Stdout = stdout
+		log.SetOutput(os.Stderr)
+		w.Close()
+		<-done
+	}
+}
+
+// ringWriter adds what the log package writes, a line at a time, to a
+// logRing.
+type ringWriter struct{ ring *logRing }
+
+func (w ringWriter) Write(p []byte) (int, error) {
+	line := string(p)
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+	w.ring.add(line)
+	return len(p), nil
+}
@@ -0,0 +1,154 @@
+// The dashboard polls the engine's API once a second, charting the last few
+// minutes of it. Its parameter sliders follow a WebSocket instead, so they
+// move whenever anything else changes a parameter.
+const history = 300;
+const rates = [];
+const populations = [];
+let last = null;
+let logLast = 0;
+let shown = null;
+let params = null;
+
+async function getJSON(url, options) {
+  const response = await fetch(url, options);
+  if (!response.ok) {
+    throw new Error(await response.text());
+  }
+  return response.json();
+}
+
+function push(values, v) {
+  values.push(v);
+  if (values.length > history) {
+    values.shift();
+  }
+}
+
+function chart(canvas, values, colour) {
+  const g = canvas.getContext("2d");
+  g.clearRect(0, 0, canvas.width, canvas.height);
+  const known = values.filter((v) => v !== null);
+  if (known.length < 2) {
+    return;
+  }
+  const hi = Math.max(...known) || 1;
+  g.strokeStyle = colour;
+  g.beginPath();
+  values.forEach((v, i) => {
+    if (v === null) {
+      return;
+    }
+    const x = (i / (history - 1)) * canvas.width;
+    const y = canvas.height - (v / hi) * (canvas.height - 10) - 5;
+    g.lineTo(x, y);
+  });
+  g.stroke();
+  g.fillStyle = "#999";
+  g.fillText(hi.toPrecision(3), 4, 12);
+}
+
+async function pollStatus() {
+  const st = await getJSON("api/status");
+  if (last && st.uptime > last.uptime) {
+    push(rates, (st.step - last.step) / (st.uptime - last.uptime));
+  }
+  push(populations, st.population);
+  last = st;
+
+  document.getElementById("simulation").textContent = st.simulation;
+  document.getElementById("summary").textContent =
+    `step ${st.step}, ${st.width}x${st.height} cells, up ${Math.round(st.uptime)}s`;
+  document.getElementById("work").textContent =
+    `${st.draw_calls} draws, ${st.instances} instances, ${st.dispatches} dispatches, ` +
+    `${st.upload_bytes} bytes uploaded`;
+  chart(document.getElementById("rate"), rates, "#6cf");
+  chart(document.getElementById("population"), populations, "#fc6");
+}
+
+// syncParams shows the parameters the engine sends, reconnecting if the
+// connection drops.
+function syncParams() {
+  const url = new URL("api/params/ws", location.href);
+  url.protocol = url.protocol === "https:" ? "wss:" : "ws:";
+  params = new WebSocket(url);
+  params.onmessage = (event) => {
+    const msg = JSON.parse(event.data);
+    if (msg.error) {
+      console.warn(msg.error);
+      return;
+    }
+    const names = msg.simulation + ":" + msg.parameters.map((p) => p.name).join(",");
+    if (names !== shown) {
+      shown = names;
+      showParams(msg.parameters);
+    }
+    for (const p of msg.parameters) {
+      const label = document.getElementById(`param-${p.name}`);
+      if (label) {
+        label.input.value = p.value;
+        label.value.textContent = p.value.toPrecision(3);
+      }
+    }
+  };
+  params.onclose = () => {
+    shown = null;
+    setTimeout(syncParams, 2000);
+  };
+}
+
+function showParams(params) {
+  const form = document.getElementById("params");
+  form.replaceChildren();
+  if (params.length === 0) {
+    form.innerHTML = '<p class="none">This simulation has none.</p>';
+    return;
+  }
+  for (const p of params) {
+    const label = document.createElement("label");
+    const input = document.createElement("input");
+    const value = document.createElement("span");
+    label.id = `param-${p.name}`;
+    label.input = input;
+    label.value = value;
+    input.type = "range";
+    input.min = p.min;
+    input.max = p.max;
+    input.step = (p.max - p.min) / 1000;
+    input.value = p.value;
+    value.textContent = p.value.toPrecision(3);
+    input.addEventListener("input", () => {
+      params.send(JSON.stringify({ name: p.name, value: Number(input.value) }));
+    });
+    label.append(p.name, input, value);
+    form.append(label);
+  }
+}
+
+function pollFrame() {
+  const frame = document.getElementById("frame");
+  frame.src = `api/frame.png?t=${Date.now()}`;
+}
+
+async function pollLogs() {
+  const got = await getJSON(`api/logs?after=${logLast}`);
+  logLast = got.last;
+  if (got.lines.length === 0) {
+    return;
+  }
+  const log = document.getElementById("log");
+  const atBottom = log.scrollTop + log.clientHeight >= log.scrollHeight - 4;
+  log.textContent += got.lines.join("\n") + "\n";
+  if (atBottom) {
+    log.scrollTop = log.scrollHeight;
+  }
+}
+
+function every(ms, f) {
+  const run = () => f().catch((e) => console.warn(e)).finally(() => setTimeout(run, ms));
+  run();
+}
+
+syncParams();
+every(1000, pollStatus);
+every(1000, pollLogs);
+every(2000, async () => pollFrame());
@@ -0,0 +1,67 @@
+// Double-single arithmetic: a value is kept as the sum of two f32s, x the
+// rounded value and y what rounding it lost, for about 48 bits of mantissa.
+// -validate checks the results against a float64 CPU reference.
+
+// ds_one is 1, but read from a uniform by the shader before it uses any of
+// these: shader compilers are free to treat f32 as real numbers, and would
+// otherwise fold the rounding errors measured here away to nothing.
+var<private> ds_one: f32;
+
+// ds_two_sum returns a + b exactly as a double-single.
+fn ds_two_sum(a: f32, b: f32) -> vec2<f32> {
+  let s = a + b;
+  let v = s * ds_one - a;
+  let e = (a - (s - v) * ds_one) + (b - v);
+  return vec2(s, e);
+}
+
+// ds_normalise moves as much of y into x as fits.
+fn ds_normalise(a: vec2<f32>) -> vec2<f32> {
+  let s = a.x + a.y;
+  return vec2(s, a.y - (s * ds_one - a.x));
+}
+
+fn ds_add_f32(a: vec2<f32>, b: f32) -> vec2<f32> {
+  let s = ds_two_sum(a.x, b);
+  return ds_normalise(vec2(s.x, s.y + a.y));
+}
+
+fn ds_add(a: vec2<f32>, b: vec2<f32>) -> vec2<f32> {
+  let s = ds_two_sum(a.x, b.x);
+  let t = ds_two_sum(a.y, b.y);
+  let u = ds_normalise(vec2(s.x, s.y + t.x));
+  return ds_normalise(vec2(u.x, u.y + t.y));
+}
+
+// ds_split breaks a into two halves of 12 bits each, whose products with
+// each other are exact.
+fn ds_split(a: f32) -> vec2<f32> {
+  let t = 4097.0 * a;
+  let hi = t * ds_one - (t - a);
+  return vec2(hi, a - hi);
+}
+
+// ds_two_product returns a * b exactly as a double-single.
+fn ds_two_product(a: f32, b: f32) -> vec2<f32> {
+  let p = a * b;
+  let x = ds_split(a);
+  let y = ds_split(b);
+  let e = ((x.x * y.x - p) + x.x * y.y + x.y * y.x) + x.y * y.y;
+  return vec2(p, e);
+}
+
+fn ds_mul_f32(a: vec2<f32>, b: f32) -> vec2<f32> {
+  let p = ds_two_product(a.x, b);
+  return ds_normalise(vec2(p.x, p.y + a.y * b));
+}
+
+// ds_clamp clamps a to [lo, hi], dropping the low part at either end.
+fn ds_clamp(a: vec2<f32>, lo: f32, hi: f32) -> vec2<f32> {
+  if a.x < lo || (a.x == lo && a.y < 0.0) {
+    return vec2(lo, 0.0);
+  }
+  if a.x > hi || (a.x == hi && a.y > 0.0) {
+    return vec2(hi, 0.0);
+  }
+  return a;
+}
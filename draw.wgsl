@@ -1,3 +1,4 @@
+// palette.wgsl and camera.wgsl go after this.
 struct VertexInput {
   @location(0) pos: vec2<f32>,
   @builtin(instance_index) instance: u32,
@@ -6,30 +7,94 @@ struct VertexInput {
 struct VertexOutput {
   @builtin(position) pos: vec4<f32>,
   @location(0) cell: vec2<f32>, 
+  @location(1) @interpolate(flat) species: u32,
+  @location(2) @interpolate(flat) age: u32,
+  @location(3) @interpolate(flat) trail: f32,
+  // local runs from -1 to 1 across the cell's quad.
+  @location(4) local: vec2<f32>,
 };
 
 @group(0) @binding(0) var<uniform> grid: vec2<f32>;
 @group(0) @binding(1) var<storage> cellStateIn: array<u32>;
 @group(0) @binding(2) var<storage, read_write> cellStateOut: array<u32>;
+// life.y is how many species compete, life.z 1 to colour cells by age and
+// life.w 1 to draw trails.
+@group(0) @binding(3) var<uniform> life: vec4<u32>;
+@group(0) @binding(4) var<storage> ageIn: array<u32>;
+@group(0) @binding(6) var<uniform> palette: Palette;
+// trail fades from 1 where cells were alive, see trail.wgsl.
+@group(0) @binding(7) var<storage> trail: array<f32>;
+// gradient runs from the colour of newborn cells to that of old ones.
+@group(0) @binding(8) var gradient: texture_1d<f32>;
+// visible are the cells culled_vs draws.
+@group(2) @binding(0) var<storage> visible: array<u32>;
 
 @vertex
-fn main_vs(input: VertexInput) -> VertexOutput{
-    let i = f32(input.instance);
-    let state = f32(cellStateIn[input.instance]);
+fn main_vs(input: VertexInput) -> VertexOutput {
+    return cellVertex(input.pos, input.instance);
+}
+
+// culled_vs draws the cells cull.wgsl picked out, each instance the one at
+// its place in visible.
+@vertex
+fn culled_vs(input: VertexInput) -> VertexOutput {
+    return cellVertex(input.pos, visible[input.instance]);
+}
+
+fn cellVertex(pos: vec2<f32>, index: u32) -> VertexOutput {
+    let i = f32(index);
+    let species = cellStateIn[index];
+    var fading = 0.0;
+    if life.w == 1u && species == 0u {
+        fading = trail[index];
+    }
+    let state = select(0.0, 1.0, species > 0u || fading > 1.0 / 256.0);
     
     let cell = vec2<f32>(i % grid.x, floor(i / grid.x));
     let cellOffset = cell / grid * 2.0;
-    let gridPos = (state*input.pos + 1.0) / grid - 1.0 + cellOffset;
+    let gridPos = (state*pos + 1.0) / grid - 1.0 + cellOffset;
     
     var output: VertexOutput;
-    output.pos = vec4<f32>(gridPos, 0.0, 1.0);
+    output.pos = camera * vec4<f32>(gridPos, 0.0, 1.0);
     output.cell = cell; 
+    output.species = species;
+    output.age = ageIn[index];
+    output.trail = fading;
+    output.local = pos / 0.8;
     return output;
 }
 
+// Competing species each get a hue spaced evenly around the colour wheel.
+// Dead cells are only drawn for their trails, fading into the background.
 @fragment
 fn main_fs(input: VertexOutput) -> @location(0) vec4<f32> {
-    let c = input.cell / grid;
-    return vec4<f32>(c, 1.0-c.x, 1.0);
+    let coverage = cellShape(palette, input.local, fwidth(input.local));
+    if input.species == 0u {
+        return shapeColour(palette, paletteColour(palette, input.cell / grid), 0.6 * input.trail * coverage);
+    }
+    if life.y > 1u {
+        let hue = f32(input.species - 1u) / f32(life.y) * 6.28318;
+        let colour = 0.55 + 0.45 * cos(vec3(hue, hue - 2.09440, hue + 2.09440));
+        return shapeColour(palette, colour * ageShade(input.age), coverage);
+    }
+    if life.z == 1u {
+        return shapeColour(palette, ageColour(input.age), coverage);
+    }
+    return shapeColour(palette, paletteColour(palette, input.cell / grid), coverage);
+}
+
+// ageColour runs along the gradient from newborn cells to those that have
+// lived a few hundred generations.
+fn ageColour(age: u32) -> vec3<f32> {
+    let old = 1.0 - exp(-f32(age) / 64.0);
+    let last = i32(textureDimensions(gradient)) - 1;
+    return textureLoad(gradient, i32(old * f32(last) + 0.5), 0).rgb;
 }
 
+// ageShade dims older cells of a species when colouring by age.
+fn ageShade(age: u32) -> f32 {
+    if life.z != 1u {
+        return 1.0;
+    }
+    return 1.0 - 0.7 * (1.0 - exp(-f32(age) / 64.0));
+}
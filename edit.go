@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+
+	"webgpu-go/viewmath"
+)
+
+// cellRect is w by h cells with its bottom left one at x, y, counting cells
+// from the bottom left of the grid as viewmath does.
+type cellRect struct {
+	x, y, w, h int
+}
+
+// cellEditor is implemented by simulations whose cells the mouse can change
+// directly. Cells read and write as in the NumPy arrays, 0 for dead and
+// otherwise alive, row by row from the bottom.
+type cellEditor interface {
+	// editable is false while the cells can't be found under the cursor,
+	// such as on a hex grid.
+	editable() bool
+	readCells(s *State, r cellRect) ([]uint32, error)
+	writeCells(s *State, r cellRect, cells []uint32) error
+}
+
+// maxBrushRadius is as far as the brush reaches around the cell under the
+// cursor.
+const maxBrushRadius = 32
+
+// cellEdits is what the mouse is doing to the cells. Unless Shift is held,
+// which drags out the selection, a stroke starts when the left button goes
+// down and paints every cell within radius of the cursor on the way until
+// it comes up: alive if the cell it started on was dead, and dead if it was
+// alive, so that a click with a radius of 0 toggles one cell.
+type cellEdits struct {
+	// held is whether the left button was down when it was last looked at.
+	held     bool
+	radius   int
+	painting bool
+	value    uint32
+	// selecting is set while the drag is the selection's instead.
+	selecting bool
+	// last is the cell the cursor was last in, and pending the cells the
+	// stroke has covered since they were last written.
+	last    [2]int
+	pending map[[2]int]bool
+}
+
+// cursorCell is the cell of a width by height grid under the cursor, through
+// the view camera.
+func (s *State) cursorCell(w *glfw.Window, width, height int) (x, y int, ok bool) {
+	if s.camera == nil {
+		return 0, 0, false
+	}
+	windowWidth, windowHeight := w.GetSize()
+	cx, cy := w.GetCursorPos()
+	at, ok := viewmath.CursorToClip(cx, cy, windowWidth, windowHeight)
+	if !ok {
+		return 0, 0, false
+	}
+	return viewmath.GridToCell(s.camera.view.ClipToGrid(at), width, height)
+}
+
+// editor is the simulation if its cells can be edited now.
+func (s *State) editor() (cellEditor, bool) {
+	if _, browsing := s.mode.(sceneMode); browsing {
+		return nil, false
+	}
+	e, ok := s.sim.(cellEditor)
+	return e, ok && e.editable()
+}
+
+// handleEditMouse starts a stroke when the left button goes down and carries
+// it on as the cursor moves, ending it when the button comes up.
+func (s *State) handleEditMouse(w *glfw.Window) {
+	held := w.GetMouseButton(glfw.MouseButtonLeft) == glfw.Press
+	pressed := held && !s.edits.held
+	s.edits.held = held
+	if !held {
+		s.edits.painting, s.edits.selecting = false, false
+		return
+	}
+	e, ok := s.editor()
+	if !ok {
+		return
+	}
+	x, y, ok := s.cursorCell(w, s.gridWidth, s.gridHeight)
+	if !ok {
+		return
+	}
+	shift := w.GetKey(glfw.KeyLeftShift) == glfw.Press || w.GetKey(glfw.KeyRightShift) == glfw.Press
+	if pressed && shift {
+		s.selection.begin(x, y)
+		s.edits.selecting = true
+		return
+	}
+	if pressed {
+		cells, err := e.readCells(s, cellRect{x, y, 1, 1})
+		if err != nil {
+			fmt.Println("painting cells:", err)
+			return
+		}
+		s.edits.value = 0
+		if cells[0] == 0 {
+			s.edits.value = 1
+		}
+		s.edits.painting, s.edits.last = true, [2]int{x, y}
+		s.history.open = false
+		s.emit(actionPaint)
+	}
+	switch {
+	case s.edits.selecting:
+		s.selection.extend(x, y)
+	case s.edits.painting:
+		s.edits.stroke(x, y)
+	}
+}
+
+// stroke covers every cell within the brush's radius of the line from the
+// last cell to x, y.
+func (e *cellEdits) stroke(x, y int) {
+	if e.pending == nil {
+		e.pending = map[[2]int]bool{}
+	}
+	dx, dy := x-e.last[0], y-e.last[1]
+	n := max(abs(dx), abs(dy), 1)
+	reach := (float64(e.radius) + 0.5) * (float64(e.radius) + 0.5)
+	for i := 0; i <= n; i++ {
+		cx, cy := e.last[0]+dx*i/n, e.last[1]+dy*i/n
+		for oy := -e.radius; oy <= e.radius; oy++ {
+			for ox := -e.radius; ox <= e.radius; ox++ {
+				if float64(ox*ox+oy*oy) <= reach {
+					e.pending[[2]int{cx + ox, cy + oy}] = true
+				}
+			}
+		}
+	}
+	e.last = [2]int{x, y}
+}
+
+// flush writes the cells the stroke has covered since the last frame, in a
+// single write of the rectangle around them, as more of the stroke's edit.
+func (e *cellEdits) flush(s *State) {
+	if len(e.pending) == 0 {
+		return
+	}
+	defer clear(e.pending)
+	editor, ok := s.editor()
+	if !ok {
+		return
+	}
+	x0, y0, x1, y1 := s.gridWidth, s.gridHeight, -1, -1
+	for p := range e.pending {
+		if p[0] < 0 || p[1] < 0 || p[0] >= s.gridWidth || p[1] >= s.gridHeight {
+			continue
+		}
+		x0, y0 = min(x0, p[0]), min(y0, p[1])
+		x1, y1 = max(x1, p[0]), max(y1, p[1])
+	}
+	if x1 < 0 {
+		return
+	}
+	r := cellRect{x0, y0, x1 - x0 + 1, y1 - y0 + 1}
+	cells, err := editor.readCells(s, r)
+	if err != nil {
+		fmt.Println("painting cells:", err)
+		return
+	}
+	before := append([]uint32(nil), cells...)
+	for p := range e.pending {
+		if p[0] < x0 || p[1] < y0 || p[0] > x1 || p[1] > y1 {
+			continue
+		}
+		// Live cells painted alive keep their species.
+		if i := (p[1]-y0)*r.w + p[0] - x0; (cells[i] == 0) != (e.value == 0) {
+			cells[i] = e.value
+		}
+	}
+	if err := s.edit(editor, r, before, cells, true); err != nil {
+		fmt.Println("painting cells:", err)
+	}
+}
+
+// handleBrushScroll makes the brush bigger or smaller by a cell for every
+// notch of the scroll wheel while Ctrl is held, or Alt with -trackpad,
+// returning whether it did.
+func (s *State) handleBrushScroll(w *glfw.Window, notches float64) bool {
+	if held := ctrlHeld(w); s.cfg.Trackpad {
+		if !altHeld(w) {
+			return false
+		}
+	} else if !held {
+		return false
+	}
+	if _, ok := s.editor(); !ok {
+		return false
+	}
+	step := int(math.Round(notches))
+	if step == 0 {
+		step = 1
+		if notches < 0 {
+			step = -1
+		}
+	}
+	s.edits.radius = min(max(s.edits.radius+step, 0), maxBrushRadius)
+	fmt.Printf("brush radius %d\n", s.edits.radius)
+	return true
+}
+
+func (l *Life) editable() bool { return l.topology == "square" }
+
+// span is where the rows r is in start and end in the cell buffers, from
+// the first word of r to the one after its last, and the words in a row.
+func (l *Life) span(r cellRect) (first, last, rowWords int) {
+	rowWords = l.width
+	x0, x1 := r.x, r.x+r.w-1
+	if l.packed {
+		rowWords = (l.width + 31) / 32
+		x0, x1 = x0/32, x1/32
+	}
+	return r.y*rowWords + x0, (r.y+r.h-1)*rowWords + x1 + 1, rowWords
+}
+
+// word is where cell x, y is in the words of the cell buffers from first,
+// and which bit of it the cell is when they are packed.
+func (l *Life) word(x, y, first, rowWords int) (i, bit int) {
+	if l.packed {
+		return y*rowWords + x/32 - first, x % 32
+	}
+	return y*rowWords + x - first, 0
+}
+
+// readCells reads the cells of r in the current generation.
+func (l *Life) readCells(s *State, r cellRect) ([]uint32, error) {
+	first, last, rowWords := l.span(r)
+	data, err := s.readBufferRange(l.cellStateStorage[l.steps%2], uint64(first)*4, uint64(last-first)*4)
+	if err != nil {
+		return nil, err
+	}
+	words := wgpu.FromBytes[uint32](data)
+	cells := make([]uint32, 0, r.w*r.h)
+	for y := r.y; y < r.y+r.h; y++ {
+		for x := r.x; x < r.x+r.w; x++ {
+			i, bit := l.word(x, y, first, rowWords)
+			if l.packed {
+				cells = append(cells, words[i]>>bit&1)
+			} else {
+				cells = append(cells, words[i])
+			}
+		}
+	}
+	return cells, nil
+}
+
+// writeCells replaces the cells of r in the current generation in a single
+// write, reading back the rest of the rows between them to write them as
+// they were. Cells brought to life are counted as just born and those that
+// die lose their age.
+func (l *Life) writeCells(s *State, r cellRect, cells []uint32) error {
+	first, last, rowWords := l.span(r)
+	offset, size := uint64(first)*4, uint64(last-first)*4
+	buffer := l.cellStateStorage[l.steps%2]
+	data, err := s.readBufferRange(buffer, offset, size)
+	if err != nil {
+		return err
+	}
+	words := wgpu.FromBytes[uint32](data)
+	var ages []uint32
+	if !l.packed {
+		data, err := s.readBufferRange(l.ageStorage[l.steps%2], offset, size)
+		if err != nil {
+			return err
+		}
+		ages = wgpu.FromBytes[uint32](data)
+	}
+	for j, c := range cells {
+		x, y := r.x+j%r.w, r.y+j/r.w
+		i, bit := l.word(x, y, first, rowWords)
+		switch {
+		case l.packed:
+			words[i] = words[i]&^(1<<bit) | min(c, 1)<<bit
+		case (words[i] == 0) != (c == 0):
+			words[i], ages[i] = c, min(c, 1)
+		default:
+			words[i] = c
+		}
+	}
+	if err := s.queue.WriteBuffer(buffer, offset, wgpu.ToBytes(words)); err != nil {
+		return err
+	}
+	if ages != nil {
+		if err := s.queue.WriteBuffer(l.ageStorage[l.steps%2], offset, wgpu.ToBytes(ages)); err != nil {
+			return err
+		}
+	}
+	return l.redraw(s)
+}
+
+// redraw brings the texture or the culled cells, whichever draws the cells,
+// up to date with cells written since the last step.
+func (l *Life) redraw(s *State) error {
+	if l.texture == nil && l.culler == nil {
+		return nil
+	}
+	encoder, err := s.newEncoder()
+	if err != nil {
+		return err
+	}
+	defer encoder.Release()
+	if l.texture != nil {
+		l.texture.update(encoder, l.steps%2)
+	}
+	if l.culler != nil {
+		l.culler.update(encoder, l.steps%2)
+	}
+	cmdBuffer, err := encoder.Finish(nil)
+	if err != nil {
+		return err
+	}
+	defer cmdBuffer.Release()
+	s.queue.Submit(cmdBuffer)
+	return nil
+}
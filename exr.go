@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// fieldExporter is implemented by simulations with continuous state to
+// save as it is, rather than as the colours it is drawn in.
+type fieldExporter interface {
+	Fields(s *State) (*fieldSet, error)
+}
+
+// fieldSet is a width by height grid of named float channels, rows from
+// the bottom of the grid up, with attributes describing where they came
+// from.
+type fieldSet struct {
+	width, height int
+	channels      []fieldChannel
+	attributes    map[string]string
+	// whole is set for cell states, which are whole numbers.
+	whole bool
+}
+
+type fieldChannel struct {
+	name   string
+	values []float32
+}
+
+// cellFields is the fieldSet for a width by height grid of cell states.
+func cellFields(cells []uint32, width, height int) *fieldSet {
+	values := make([]float32, len(cells))
+	for i, c := range cells {
+		values[i] = float32(c)
+	}
+	return &fieldSet{
+		width:      width,
+		height:     height,
+		channels:   []fieldChannel{{"state", values}},
+		attributes: map[string]string{},
+		whole:      true,
+	}
+}
+
+// formatAttribute writes a simulation parameter for a fieldSet's
+// attributes.
+func formatAttribute(v float32) string {
+	return strconv.FormatFloat(float64(v), 'g', -1, 32)
+}
+
+// writeEXR writes f as an uncompressed, single part, scanline OpenEXR
+// image of 32-bit float channels, top row first so that it looks the way
+// the grid is drawn. The attributes are string attributes in the header.
+func writeEXR(w io.Writer, f *fieldSet) error {
+	for _, c := range f.channels {
+		if len(c.values) != f.width*f.height {
+			return fmt.Errorf("field %s has %d values for %dx%d cells", c.name, len(c.values), f.width, f.height)
+		}
+	}
+	// EXR keeps channels sorted by name.
+	channels := append([]fieldChannel(nil), f.channels...)
+	sort.Slice(channels, func(i, j int) bool { return channels[i].name < channels[j].name })
+
+	le := binary.LittleEndian
+	var head bytes.Buffer
+	attribute := func(name, kind string, value []byte) {
+		head.WriteString(name + "\x00" + kind + "\x00")
+		head.Write(le.AppendUint32(nil, uint32(len(value))))
+		head.Write(value)
+	}
+	box := func(width, height int) []byte {
+		var b []byte
+		for _, v := range []int{0, 0, width - 1, height - 1} {
+			b = le.AppendUint32(b, uint32(v))
+		}
+		return b
+	}
+	float := func(v float32) []byte { return le.AppendUint32(nil, math.Float32bits(v)) }
+
+	// The magic number, then version 2 with no flags: one part of scanlines.
+	head.Write([]byte{0x76, 0x2f, 0x31, 0x01, 2, 0, 0, 0})
+
+	var chlist []byte
+	for _, c := range channels {
+		// FLOAT pixels, not perceptually linear, sampled every pixel.
+		chlist = append(chlist, c.name...)
+		chlist = append(chlist, 0)
+		chlist = le.AppendUint32(chlist, 2)
+		chlist = append(chlist, 0, 0, 0, 0)
+		chlist = le.AppendUint32(chlist, 1)
+		chlist = le.AppendUint32(chlist, 1)
+	}
+	attribute("channels", "chlist", append(chlist, 0))
+	attribute("compression", "compression", []byte{0})
+	attribute("dataWindow", "box2i", box(f.width, f.height))
+	attribute("displayWindow", "box2i", box(f.width, f.height))
+	attribute("lineOrder", "lineOrder", []byte{0})
+	attribute("pixelAspectRatio", "float", float(1))
+	attribute("screenWindowCenter", "v2f", append(float(0), float(0)...))
+	attribute("screenWindowWidth", "float", float(1))
+	names := make([]string, 0, len(f.attributes))
+	for name := range f.attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		attribute(name, "string", []byte(f.attributes[name]))
+	}
+	head.WriteByte(0)
+
+	// Every scanline is a block of its own, found through the offset table
+	// after the header: its y, its size and then each channel's row in turn.
+	rowSize := 4 * f.width * len(channels)
+	start := head.Len() + 8*f.height
+	for y := 0; y < f.height; y++ {
+		head.Write(le.AppendUint64(nil, uint64(start+y*(8+rowSize))))
+	}
+	b := bufio.NewWriter(w)
+	if _, err := b.Write(head.Bytes()); err != nil {
+		return err
+	}
+	block := make([]byte, 0, 8+rowSize)
+	for y := 0; y < f.height; y++ {
+		block = le.AppendUint32(block[:0], uint32(y))
+		block = le.AppendUint32(block, uint32(rowSize))
+		row := (f.height - 1 - y) * f.width
+		for _, c := range channels {
+			for _, v := range c.values[row : row+f.width] {
+				block = le.AppendUint32(block, math.Float32bits(v))
+			}
+		}
+		if _, err := b.Write(block); err != nil {
+			return err
+		}
+	}
+	return b.Flush()
+}
+
+func saveEXR(path string, f *fieldSet) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeEXR(file, f); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// handleFieldsKey saves the simulation's raw fields as an EXR with X.
+func (s *State) handleFieldsKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	// Ctrl+X cuts the selection.
+	if key != glfw.KeyX || action != glfw.Press || mods&(glfw.ModControl|glfw.ModSuper) != 0 {
+		return
+	}
+	e, ok := s.sim.(fieldExporter)
+	if !ok {
+		fmt.Println("this simulation has no fields to export")
+		return
+	}
+	f, err := e.Fields(s)
+	if err != nil {
+		fmt.Println("reading fields:", err)
+		return
+	}
+	f.attributes["simulation"] = s.cfg.Simulation
+	f.attributes["step"] = strconv.Itoa(s.steps)
+	f.attributes["saved"] = time.Now().UTC().Format(time.RFC3339)
+	path := fmt.Sprintf("fields-%06d.exr", s.steps)
+	if err := saveEXR(path, f); err != nil {
+		fmt.Println("saving fields:", err)
+		return
+	}
+	fmt.Printf("saved %s, %dx%d cells of %d channels\n", path, f.width, f.height, len(f.channels))
+}
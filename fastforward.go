@@ -0,0 +1,446 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+// fastForwarder is implemented by simulations that can jump many
+// generations ahead at once.
+type fastForwarder interface {
+	FastForward(s *State, generations uint64) error
+}
+
+// fastForward jumps the simulation cfg.FastForward generations ahead.
+func (s *State) fastForward() error {
+	f, ok := s.sim.(fastForwarder)
+	if !ok {
+		return fmt.Errorf("this simulation can't fast-forward")
+	}
+	n := s.cfg.FastForward
+	start := time.Now()
+	if err := f.FastForward(s, n); err != nil {
+		return err
+	}
+	s.steps += int(n)
+	// HashLife's grid has no edges, so the GPU couldn't step there again.
+	s.timeline.edited(s)
+	fmt.Printf("fast-forwarded %s generations in %s\n", s.format.Count(int64(n)), s.format.Duration(time.Since(start)))
+	return nil
+}
+
+// maxBatch is the most generations advance records into one submission.
+const maxBatch = 256
+
+// batchStepper is implemented by simulations whose Step only records compute
+// work, uploading and reading back nothing, so that many generations can go
+// in one submission.
+type batchStepper interface {
+	batchesSteps() bool
+}
+
+// advance steps the simulation n generations as fast as the GPU can,
+// drawing none of them: maxBatch to a submission where the simulation lets
+// it, cut short where the timeline keeps a keyframe, and one at a time as
+// stepNow does where not. Unlike fastForward it runs the simulation's own
+// rule, edges and all.
+func (s *State) advance(n int) error {
+	if b, ok := s.sim.(batchStepper); !ok || !b.batchesSteps() {
+		return s.stepNow(n)
+	}
+	for n > 0 {
+		batch := min(n, maxBatch)
+		if t := s.timeline; t != nil && t.next > s.steps {
+			batch = min(batch, t.next-s.steps)
+		}
+		encoder, err := s.newEncoder()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < batch; i++ {
+			s.step(encoder)
+		}
+		cmdBuffer, err := encoder.Finish(nil)
+		encoder.Release()
+		if err != nil {
+			return err
+		}
+		s.queue.Submit(cmdBuffer)
+		cmdBuffer.Release()
+		// Waiting for each batch keeps the GPU from falling far behind.
+		s.device.Poll(true, nil)
+		s.timeline.observe(s)
+		n -= batch
+	}
+	return nil
+}
+
+// advanceBy advances n generations, saying how long it took.
+func (s *State) advanceBy(n int) error {
+	start := time.Now()
+	if err := s.advance(n); err != nil {
+		return err
+	}
+	elapsed := time.Since(start)
+	fmt.Printf("advanced %s generations in %s, %s\n", s.format.Count(int64(n)),
+		s.format.Duration(elapsed), s.format.Rate(int64(n), elapsed, "generations"))
+	return nil
+}
+
+// handleFastForwardKey fast-forwards with G, and advances on the GPU with
+// Shift+G.
+func (s *State) handleFastForwardKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if key != glfw.KeyG || action != glfw.Press {
+		return
+	}
+	var err error
+	if mods&glfw.ModShift != 0 {
+		err = s.advanceBy(s.cfg.Advance)
+	} else {
+		err = s.fastForward()
+	}
+	if err != nil {
+		fmt.Println("fast-forwarding:", err)
+	}
+}
+
+// gridBlocks splits a width by height grid of cells into the blocks of a
+// universe, with the grid centred on the origin.
+func gridBlocks(cells []uint32, width, height int) map[chunkPos]*block {
+	blocks := map[chunkPos]*block{}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if cells[y*width+x] == 0 {
+				continue
+			}
+			ux, uy := int64(x-width/2), int64(y-height/2)
+			pos := chunkPos{int32(ux >> blockLevel), int32(uy >> blockLevel)}
+			b := blocks[pos]
+			if b == nil {
+				b = &block{}
+				blocks[pos] = b
+			}
+			b[uy&63] |= 1 << (ux & 63)
+		}
+	}
+	return blocks
+}
+
+// blockGrid is the inverse of gridBlocks, also returning how many live cells
+// were outside the grid.
+func blockGrid(blocks map[chunkPos]*block, width, height int) ([]uint32, int) {
+	cells := make([]uint32, width*height)
+	dropped := 0
+	for pos, b := range blocks {
+		for by, row := range b {
+			for bx := 0; row != 0; bx, row = bx+1, row>>1 {
+				if row&1 == 0 {
+					continue
+				}
+				x := int(pos.x)*64 + bx + width/2
+				y := int(pos.y)*64 + by + height/2
+				if x < 0 || y < 0 || x >= width || y >= height {
+					dropped++
+					continue
+				}
+				cells[y*width+x] = 1
+			}
+		}
+	}
+	return cells, dropped
+}
+
+// FastForward runs HashLife from the current generation, as far as
+// stepWithin lets it.
+func (l *Life) FastForward(s *State, generations uint64) error {
+	if l.topology != "square" {
+		return fmt.Errorf("HashLife only runs life on a square grid")
+	}
+	if l.species > 1 {
+		return fmt.Errorf("HashLife can't tell competing species apart")
+	}
+	if boundaryModes[l.boundary] != "dead" {
+		return fmt.Errorf("HashLife can't follow %s edges, only dead ones; Shift+G advances on the GPU", boundaryModes[l.boundary])
+	}
+	data, err := s.readBuffer(l.cellStateStorage[l.steps%2])
+	if err != nil {
+		return err
+	}
+	u := newUniverse()
+	u.setBlocks(gridBlocks(l.decode(data), l.width, l.height))
+	if err := stepWithin(u, l.width, l.height, generations); err != nil {
+		return err
+	}
+	cells, _ := blockGrid(u.blocks(), l.width, l.height)
+	// HashLife doesn't know how old cells are, so they all start again.
+	l.setCells(s, l.encode(cells), l.startingAges(cells))
+	return nil
+}
+
+// maxEdgeChecks is how many times stepWithin stops to see how near the
+// edges the pattern has come before giving up on it.
+const maxEdgeChecks = 4096
+
+// stepWithin steps u, set from a width by height grid by gridBlocks, on as
+// many generations as the grid would go with dead edges. HashLife's plane
+// only gives what they would while nothing is born past them, so it steps
+// no further at a time than a cell past them could be affected, an edge's
+// distance from the nearest live cell less one, giving up once the pattern
+// is on them or has kept near them too long.
+func stepWithin(u *universe, width, height int, generations uint64) error {
+	for checks := 0; generations > 0; checks++ {
+		safe := generations
+		if x0, y0, x1, y1, ok := u.bounds(); ok {
+			x0, x1 = x0+int64(width/2), x1+int64(width/2)
+			y0, y1 = y0+int64(height/2), y1+int64(height/2)
+			edge := min(x0+1, y0+1, int64(width)-x1, int64(height)-y1)
+			if edge <= 1 || checks == maxEdgeChecks {
+				return fmt.Errorf("the pattern has come too near the grid's edges for HashLife to follow them; Shift+G advances on the GPU")
+			}
+			safe = min(safe, uint64(edge-1))
+		}
+		u.step(safe)
+		generations -= safe
+	}
+	return nil
+}
+
+// FastForward runs HashLife from the current generation and puts the result
+// back in chunks.
+func (l *SparseLife) FastForward(s *State, generations uint64) error {
+	data, err := s.readBuffer(l.cellStateStorage[l.steps%2])
+	if err != nil {
+		return err
+	}
+	cells := wgpu.FromBytes[uint32](data)
+	blocks := map[chunkPos]*block{}
+	for pos, slot := range l.chunks {
+		b := &block{}
+		live := false
+		for i, c := range cells[slot*chunkCells:][:chunkCells] {
+			if c != 0 {
+				b[i/chunkSize] |= 1 << (i % chunkSize)
+				live = true
+			}
+		}
+		if live {
+			blocks[pos] = b
+		}
+	}
+	u := newUniverse()
+	u.setBlocks(blocks)
+	u.step(generations)
+	blocks = u.blocks()
+
+	l.chunks = map[chunkPos]int{}
+	l.slots, l.free = nil, nil
+	for pos := range blocks {
+		for dy := int32(-1); dy <= 1; dy++ {
+			for dx := int32(-1); dx <= 1; dx++ {
+				l.allocate(chunkPos{pos.x + dx, pos.y + dy})
+			}
+		}
+	}
+	capacity := 16
+	for capacity < len(l.slots) {
+		capacity *= 2
+	}
+	if limit := s.device.GetLimits().Limits.MaxStorageBufferBindingSize; uint64(capacity*chunkCells*4) > limit {
+		return fmt.Errorf("%d chunks don't fit in a storage buffer of %s bytes", len(l.slots), s.format.Count(int64(limit)))
+	}
+	cells = make([]uint32, len(l.slots)*chunkCells)
+	for pos, b := range blocks {
+		slot := l.chunks[pos]
+		for y, row := range b {
+			for x := 0; x < chunkSize; x++ {
+				cells[slot*chunkCells+y*chunkSize+x] = uint32(row >> x & 1)
+			}
+		}
+	}
+	l.setCells(s, capacity, cells)
+	return nil
+}
+
+// HashLife is Conway's Life run on the CPU by HashLife, showing the grid's
+// worth of the plane around the origin.
+type HashLife struct {
+	bindGroupLayout *wgpu.BindGroupLayout
+	pipelineLayout  *wgpu.PipelineLayout
+	pipeline        *wgpu.RenderPipeline
+
+	cells     *wgpu.Buffer
+	bindGroup *wgpu.BindGroup
+	vertices  *wgpu.Buffer
+	camera    *viewCamera
+	queue     *uploadQueue
+	// params, ages and trail stand in for what the life renderer reads of
+	// cells that HashLife doesn't keep: one species, no ages and no trails.
+	params       *wgpu.Buffer
+	ages, trail  *wgpu.Buffer
+	gradient     *wgpu.Texture
+	gradientView *wgpu.TextureView
+
+	universe      *universe
+	stepsPerFrame uint64
+	width, height int
+}
+
+func newHashLife(s *State, cfg *Config) (sim Simulation, err error) {
+	if cfg.HashLife.StepsPerFrame < 1 {
+		return nil, fmt.Errorf("hashlife needs at least one step per frame, got %d", cfg.HashLife.StepsPerFrame)
+	}
+	h := &HashLife{
+		vertices:      s.vertexBuffer,
+		camera:        s.camera,
+		queue:         s.queue,
+		universe:      newUniverse(),
+		stepsPerFrame: uint64(cfg.HashLife.StepsPerFrame),
+		width:         s.gridWidth,
+		height:        s.gridHeight,
+	}
+	defer func() {
+		if err != nil {
+			h.Release()
+		}
+	}()
+
+	drawShader := s.createShader("hashlife render shader", draw+paletteShader+cameraShader)
+	defer drawShader.Release()
+
+	h.bindGroupLayout, err = s.bindGroupLayout("hashlife",
+		bufferEntry(0, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(2, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(3, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		bufferEntry(4, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(5, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(6, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		bufferEntry(7, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_ReadOnlyStorage),
+		wgpu.BindGroupLayoutEntry{Binding: 8, Visibility: wgpu.ShaderStage_Fragment, Texture: wgpu.TextureBindingLayout{
+			SampleType:    wgpu.TextureSampleType_Float,
+			ViewDimension: wgpu.TextureViewDimension_1D,
+		}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	h.pipelineLayout, err = s.pipelineLayout("hashlife", h.bindGroupLayout, s.cameraLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	h.pipeline, err = s.renderPipeline("hashlife render", h.pipelineLayout, drawShader, "main_vs", "main_fs")
+	if err != nil {
+		return nil, err
+	}
+
+	h.params = s.uniformBuffer("hashlife params", wgpu.ToBytes([]uint32{0, 1, 0, 0}))
+	h.ages = s.storageBuffer(make([]byte, 4))
+	h.trail = s.storageBuffer(make([]byte, 4))
+	h.gradient, h.gradientView, err = s.gradientTexture(cfg.Life.AgeGradient)
+	if err != nil {
+		return nil, err
+	}
+
+	gen, err := newGenerator(cfg.Init)
+	if err != nil {
+		return nil, err
+	}
+	h.universe.setBlocks(gridBlocks(generate(gen, s.rand, h.width, h.height), h.width, h.height))
+	h.setCells(s)
+	return h, nil
+}
+
+// setCells makes a cell buffer the size of the view and fills it.
+func (h *HashLife) setCells(s *State) {
+	h.releaseCells()
+	cells, _ := blockGrid(h.universe.blocks(), h.width, h.height)
+	h.cells = s.storageBuffer(wgpu.ToBytes(cells))
+	h.bindGroup = s.textureBindGroup("hashlife", h.bindGroupLayout, []*wgpu.Buffer{s.gridBuffer, h.cells, h.cells, h.params, h.ages, h.ages, s.paletteBuffer, h.trail}, h.gradientView)
+}
+
+func (h *HashLife) upload() {
+	cells, _ := blockGrid(h.universe.blocks(), h.width, h.height)
+	if err := h.queue.WriteBuffer(h.cells, 0, wgpu.ToBytes(cells)); err != nil {
+		panic(err)
+	}
+}
+
+func (h *HashLife) FastForward(s *State, generations uint64) error {
+	h.universe.step(generations)
+	h.upload()
+	return nil
+}
+
+// ResizeGrid changes how much of the plane is in view.
+func (h *HashLife) ResizeGrid(s *State, r gridRemap) error {
+	h.width, h.height = r.width, r.height
+	h.setCells(s)
+	return nil
+}
+
+func (h *HashLife) Population(s *State) (float64, error) {
+	return float64(h.universe.root.population), nil
+}
+
+func (h *HashLife) Step(encoder *commandEncoder) {
+	h.universe.step(h.stepsPerFrame)
+	h.upload()
+}
+
+func (h *HashLife) squareCells() bool { return true }
+
+func (h *HashLife) usesCamera() {}
+
+func (h *HashLife) Draw(pass *renderPass) {
+	pass.SetPipeline(h.pipeline)
+	pass.SetBindGroup(0, h.bindGroup, nil)
+	pass.SetBindGroup(1, h.camera.bindGroup, nil)
+	pass.SetVertexBuffer(0, h.vertices, 0, wgpu.WholeSize)
+	pass.Draw(6, uint32(h.width*h.height), 0, 0)
+}
+
+func (h *HashLife) releaseCells() {
+	if h.bindGroup != nil {
+		h.bindGroup.Release()
+		h.bindGroup = nil
+	}
+	if h.cells != nil {
+		h.cells.Release()
+		h.cells = nil
+	}
+}
+
+func (h *HashLife) Release() {
+	h.releaseCells()
+	for _, b := range []*wgpu.Buffer{h.params, h.ages, h.trail} {
+		if b != nil {
+			b.Release()
+		}
+	}
+	h.params, h.ages, h.trail = nil, nil, nil
+	if h.gradientView != nil {
+		h.gradientView.Release()
+		h.gradientView = nil
+	}
+	if h.gradient != nil {
+		h.gradient.Release()
+		h.gradient = nil
+	}
+	if h.pipeline != nil {
+		h.pipeline.Release()
+		h.pipeline = nil
+	}
+	if h.pipelineLayout != nil {
+		h.pipelineLayout.Release()
+		h.pipelineLayout = nil
+	}
+	if h.bindGroupLayout != nil {
+		h.bindGroupLayout.Release()
+		h.bindGroupLayout = nil
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// numberFormat writes counts, durations and rates for people to read, with
+// the digit grouping and decimal mark of their locale.
+type numberFormat struct {
+	group   string
+	decimal string
+}
+
+var plainFormat = numberFormat{group: "", decimal: "."}
+
+// numberFormats is keyed by language, or language_REGION where a region
+// differs from the rest of its language.
+var numberFormats = map[string]numberFormat{
+	"plain": plainFormat,
+	"en":    {group: ",", decimal: "."},
+	"ja":    {group: ",", decimal: "."},
+	"zh":    {group: ",", decimal: "."},
+	"de":    {group: ".", decimal: ","},
+	"es":    {group: ".", decimal: ","},
+	"it":    {group: ".", decimal: ","},
+	"nl":    {group: ".", decimal: ","},
+	"pt":    {group: ".", decimal: ","},
+	"fr":    {group: "\u202f", decimal: ","},
+	"pl":    {group: "\u00a0", decimal: ","},
+	"ru":    {group: "\u00a0", decimal: ","},
+	"sv":    {group: "\u00a0", decimal: ","},
+	"de_CH": {group: "’", decimal: "."},
+}
+
+// newNumberFormat looks up a locale such as "de_DE.UTF-8" or "fr", or the
+// style "plain". An empty name uses the environment, falling back to plain
+// for locales it does not know.
+func newNumberFormat(name string) (numberFormat, error) {
+	explicit := name != ""
+	if !explicit {
+		for _, env := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+			if name = os.Getenv(env); name != "" {
+				break
+			}
+		}
+	}
+	if name == "" || name == "C" || name == "POSIX" {
+		return plainFormat, nil
+	}
+
+	tag, _, _ := strings.Cut(name, ".")
+	tag = strings.ReplaceAll(tag, "-", "_")
+	lang, _, _ := strings.Cut(tag, "_")
+	if f, ok := numberFormats[tag]; ok {
+		return f, nil
+	}
+	if f, ok := numberFormats[strings.ToLower(lang)]; ok {
+		return f, nil
+	}
+	if explicit {
+		return plainFormat, fmt.Errorf("unknown locale %q", name)
+	}
+	return plainFormat, nil
+}
+
+func (f numberFormat) Count(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	return sign + f.groupDigits(s)
+}
+
+// Float formats x with prec digits after the decimal mark.
+func (f numberFormat) Float(x float64, prec int) string {
+	if math.IsInf(x, 0) || math.IsNaN(x) {
+		return strconv.FormatFloat(x, 'f', prec, 64)
+	}
+	s := strconv.FormatFloat(math.Abs(x), 'f', prec, 64)
+	sign := ""
+	if math.Signbit(x) && strings.Trim(s, "0.") != "" {
+		sign = "-"
+	}
+	whole, frac, ok := strings.Cut(s, ".")
+	s = f.groupDigits(whole)
+	if ok {
+		s += f.decimal + frac
+	}
+	return sign + s
+}
+
+func (f numberFormat) groupDigits(digits string) string {
+	if f.group == "" || len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	first := len(digits) % 3
+	if first == 0 {
+		first = 3
+	}
+	b.WriteString(digits[:first])
+	for i := first; i < len(digits); i += 3 {
+		b.WriteString(f.group)
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
+
+// Duration rounds to tenths of a second under a minute, to seconds under an
+// hour and to minutes after that.
+func (f numberFormat) Duration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return f.Float(d.Seconds(), 1) + " s"
+	case d < time.Hour:
+		d = d.Round(time.Second)
+		return fmt.Sprintf("%d min %02d s", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		d = d.Round(time.Minute)
+		return fmt.Sprintf("%s h %02d min", f.Count(int64(d.Hours())), int(d.Minutes())%60)
+	}
+}
+
+// Rate is how many of unit happened per second over d.
+func (f numberFormat) Rate(n int64, d time.Duration, unit string) string {
+	if d <= 0 {
+		return "- " + unit + "/s"
+	}
+	return f.Float(float64(n)/d.Seconds(), 1) + " " + unit + "/s"
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed fractal_draw.wgsl
+var fractalDraw string
+
+// fractalShapes are the fractals -fractal can name, by their shape in
+// fractal_draw.wgsl.
+var fractalShapes = map[string]uint32{
+	"mandelbulb": 0,
+	"menger":     1,
+}
+
+// fractalPower is the power the Mandelbulb raises its orbit to.
+const fractalPower = 8
+
+func fractalShapeNames() []string {
+	names := make([]string, 0, len(fractalShapes))
+	for name := range fractalShapes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Fractal ray-marches a 3D fractal from the orbit camera, one ray through
+// each pixel a frame. While the camera holds still each frame's rays are
+// jittered within their pixels and averaged into the frames before, so the
+// image converges to a noise-free still; moving the camera starts it over.
+// O starts and stops the camera orbiting.
+//
+// The mean is kept in a pair of float textures the sample pass ping-pongs
+// between, which Draw tone-maps onto the window.
+type Fractal struct {
+	state *State
+
+	sampleLayout         *wgpu.BindGroupLayout
+	showLayout           *wgpu.BindGroupLayout
+	samplePipelineLayout *wgpu.PipelineLayout
+	showPipelineLayout   *wgpu.PipelineLayout
+	sample, show         *wgpu.RenderPipeline
+	// resolve is show for saving 16-bit stills, when depth asks for them.
+	resolve *wgpu.RenderPipeline
+	depth   int
+
+	params      []uint32
+	paramBuffer *wgpu.Buffer
+	camera      *orbitCamera
+	vertices    *wgpu.Buffer
+
+	history       [2]*wgpu.Texture
+	historyViews  [2]*wgpu.TextureView
+	sampleSets    [2]*wgpu.BindGroup
+	showSets      [2]*wgpu.BindGroup
+	width, height uint32
+	// current is which of history has the mean in it.
+	current int
+
+	samples  int
+	taken    int
+	orbiting bool
+	// still is where to save the image once it has converged, and saved
+	// whether it has been.
+	still string
+	saved bool
+}
+
+func newFractal(s *State, cfg *Config) (sim Simulation, err error) {
+	p := cfg.Fractal
+	shape, ok := fractalShapes[p.Shape]
+	if !ok {
+		return nil, fmt.Errorf("unknown fractal %q (have %v)", p.Shape, fractalShapeNames())
+	}
+	if p.Iterations < 1 || p.Iterations > 64 {
+		return nil, fmt.Errorf("fractal iterations %d out of range [1, 64]", p.Iterations)
+	}
+	if p.Samples < 1 {
+		return nil, fmt.Errorf("fractal needs at least one sample a pixel, got %d", p.Samples)
+	}
+
+	f := &Fractal{
+		state:    s,
+		vertices: s.vertexBuffer,
+		samples:  p.Samples,
+		orbiting: p.Orbit,
+		still:    p.Still,
+		depth:    cfg.PNGDepth,
+	}
+	defer func() {
+		if err != nil {
+			f.Release()
+		}
+	}()
+
+	shader := s.createShader("fractal shader", fractalDraw)
+	defer shader.Release()
+
+	texture := wgpu.TextureBindingLayout{
+		SampleType:    wgpu.TextureSampleType_UnfilterableFloat,
+		ViewDimension: wgpu.TextureViewDimension_2D,
+	}
+	f.sampleLayout, err = s.bindGroupLayout("fractal sample",
+		bufferEntry(0, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		wgpu.BindGroupLayoutEntry{Binding: 2, Visibility: wgpu.ShaderStage_Fragment, Texture: texture},
+	)
+	if err != nil {
+		return nil, err
+	}
+	f.showLayout, err = s.bindGroupLayout("fractal show",
+		wgpu.BindGroupLayoutEntry{Binding: 0, Visibility: wgpu.ShaderStage_Fragment, Texture: texture},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	f.samplePipelineLayout, err = s.pipelineLayout("fractal sample", f.sampleLayout)
+	if err != nil {
+		return nil, err
+	}
+	f.showPipelineLayout, err = s.pipelineLayout("fractal show", f.showLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	f.sample, err = s.device.CreateRenderPipeline(&wgpu.RenderPipelineDescriptor{
+		Label:  "fractal sample",
+		Layout: f.samplePipelineLayout,
+		Vertex: wgpu.VertexState{
+			Module:     shader,
+			EntryPoint: "tile_vs",
+			Buffers:    quadBufferLayout,
+		},
+		Fragment: &wgpu.FragmentState{
+			Module:     shader,
+			EntryPoint: "sample_fs",
+			Targets: []wgpu.ColorTargetState{
+				{Format: historyFormat, WriteMask: wgpu.ColorWriteMask_All},
+			},
+		},
+		Primitive: wgpu.PrimitiveState{
+			Topology:  wgpu.PrimitiveTopology_TriangleList,
+			FrontFace: wgpu.FrontFace_CCW,
+			CullMode:  wgpu.CullMode_Back,
+		},
+		Multisample: wgpu.MultisampleState{
+			Count: 1,
+			Mask:  0xFFFFFFFF,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	f.show, err = s.renderPipeline("fractal show", f.showPipelineLayout, shader, "tile_vs", "show_fs")
+	if err != nil {
+		return nil, err
+	}
+	if f.depth == 16 {
+		f.resolve, err = s.device.CreateRenderPipeline(&wgpu.RenderPipelineDescriptor{
+			Label:  "fractal resolve",
+			Layout: f.showPipelineLayout,
+			Vertex: wgpu.VertexState{
+				Module:     shader,
+				EntryPoint: "tile_vs",
+				Buffers:    quadBufferLayout,
+			},
+			Fragment: &wgpu.FragmentState{
+				Module:     shader,
+				EntryPoint: "show_fs",
+				Targets: []wgpu.ColorTargetState{
+					{Format: historyFormat, WriteMask: wgpu.ColorWriteMask_All},
+				},
+			},
+			Primitive: wgpu.PrimitiveState{
+				Topology:  wgpu.PrimitiveTopology_TriangleList,
+				FrontFace: wgpu.FrontFace_CCW,
+				CullMode:  wgpu.CullMode_Back,
+			},
+			Multisample: wgpu.MultisampleState{
+				Count: 1,
+				Mask:  0xFFFFFFFF,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f.params = []uint32{shape, uint32(p.Iterations), 0, math.Float32bits(fractalPower), 0, 0, 0, 0}
+	f.paramBuffer = s.uniformBuffer("fractal params", wgpu.ToBytes(f.params))
+	f.camera = newOrbitCamera(s, "fractal camera")
+	return f, f.resize()
+}
+
+// resize remakes the accumulated image when the view has changed size,
+// starting it over.
+func (f *Fractal) resize() (err error) {
+	config := f.state.config
+	if f.history[0] != nil && f.width == config.Width && f.height == config.Height {
+		return nil
+	}
+	f.releaseTextures()
+	for i := range f.history {
+		f.history[i], f.historyViews[i], err = f.state.renderTexture("fractal history", historyFormat)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range f.history {
+		f.sampleSets[i], err = f.state.device.CreateBindGroup(&wgpu.BindGroupDescriptor{
+			Label:  "fractal sample",
+			Layout: f.sampleLayout,
+			Entries: []wgpu.BindGroupEntry{
+				{Binding: 0, Buffer: f.paramBuffer, Size: wgpu.WholeSize},
+				{Binding: 1, Buffer: f.camera.buffer, Size: wgpu.WholeSize},
+				{Binding: 2, TextureView: f.historyViews[i]},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		f.showSets[i], err = f.state.device.CreateBindGroup(&wgpu.BindGroupDescriptor{
+			Label:   "fractal show",
+			Layout:  f.showLayout,
+			Entries: []wgpu.BindGroupEntry{{Binding: 0, TextureView: f.historyViews[i]}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	f.width, f.height = config.Width, config.Height
+	f.taken = 0
+	return nil
+}
+
+// halton is the i'th number of the Halton sequence in base, which spreads
+// the samples of a pixel out more evenly than random ones would.
+func halton(i, base int) float32 {
+	result, fraction := float32(0), float32(1)
+	for ; i > 0; i /= base {
+		fraction /= float32(base)
+		result += fraction * float32(i%base)
+	}
+	return result
+}
+
+// HandleKey starts and stops the camera orbiting with O. Ctrl+O is the
+// snapshots'.
+func (f *Fractal) HandleKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if key != glfw.KeyO || action != glfw.Press || mods&(glfw.ModControl|glfw.ModSuper) != 0 {
+		return
+	}
+	f.orbiting = !f.orbiting
+	fmt.Printf("fractal orbiting %v\n", f.orbiting)
+}
+
+func (f *Fractal) Step(encoder *commandEncoder) {
+	if f.orbiting {
+		f.camera.advance()
+		f.taken = 0
+	} else {
+		f.camera.update()
+	}
+	if err := f.resize(); err != nil {
+		log.Println("resizing the fractal's image:", err)
+		return
+	}
+	if f.taken >= f.samples {
+		f.saveStill()
+		return
+	}
+
+	// The first sample goes through the middle of its pixel.
+	f.params[2] = uint32(f.taken)
+	f.params[4] = math.Float32bits(halton(f.taken+1, 2) - 0.5)
+	f.params[5] = math.Float32bits(halton(f.taken+1, 3) - 0.5)
+	if f.taken == 0 {
+		f.params[4], f.params[5] = 0, 0
+	}
+	f.params[6] = math.Float32bits(2 / float32(f.width))
+	f.params[7] = math.Float32bits(2 / float32(f.height))
+	f.state.queue.WriteBuffer(f.paramBuffer, 0, wgpu.ToBytes(f.params))
+
+	next := 1 - f.current
+	pass := encoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
+		ColorAttachments: []wgpu.RenderPassColorAttachment{attachColourToView(f.historyViews[next], f.state.palette.clear())},
+	})
+	defer pass.Release()
+	pass.SetPipeline(f.sample)
+	pass.SetBindGroup(0, f.sampleSets[f.current], nil)
+	pass.SetVertexBuffer(0, f.vertices, 0, wgpu.WholeSize)
+	pass.Draw(6, 1, 0, 0)
+	pass.End()
+	f.current = next
+	f.taken += 1
+}
+
+// saveStill writes the converged image to -fractal-still, once.
+func (f *Fractal) saveStill() {
+	if f.still == "" || f.saved || f.state.sim != Simulation(f) {
+		return
+	}
+	f.saved = true
+	var img image.Image
+	var err error
+	if f.depth == 16 {
+		img, err = f.state.captureWide(f.width, f.height, func(encoder *commandEncoder, view *wgpu.TextureView) {
+			pass := encoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
+				ColorAttachments: []wgpu.RenderPassColorAttachment{attachColourToView(view, f.state.palette.clear())},
+			})
+			defer pass.Release()
+			f.drawWith(pass, f.resolve)
+			pass.End()
+		})
+	} else {
+		img, err = f.state.captureFrame()
+	}
+	if err == nil {
+		err = savePNG(f.still, img)
+	}
+	if err != nil {
+		log.Println("saving the fractal still:", err)
+		return
+	}
+	fmt.Printf("saved %s after %s samples a pixel\n", f.still, f.state.format.Count(int64(f.taken)))
+}
+
+func (f *Fractal) Draw(pass *renderPass) {
+	f.drawWith(pass, f.show)
+}
+
+// drawWith tone-maps the image so far with pipeline, show or resolve.
+func (f *Fractal) drawWith(pass *renderPass, pipeline *wgpu.RenderPipeline) {
+	if f.showSets[f.current] == nil {
+		return
+	}
+	pass.SetPipeline(pipeline)
+	pass.SetBindGroup(0, f.showSets[f.current], nil)
+	pass.SetVertexBuffer(0, f.vertices, 0, wgpu.WholeSize)
+	pass.Draw(6, 1, 0, 0)
+}
+
+func (f *Fractal) releaseTextures() {
+	for i := range f.history {
+		for _, bg := range []*wgpu.BindGroup{f.sampleSets[i], f.showSets[i]} {
+			if bg != nil {
+				bg.Release()
+			}
+		}
+		f.sampleSets[i], f.showSets[i] = nil, nil
+		if f.historyViews[i] != nil {
+			f.historyViews[i].Release()
+			f.historyViews[i] = nil
+		}
+		if f.history[i] != nil {
+			f.history[i].Release()
+			f.history[i] = nil
+		}
+	}
+}
+
+func (f *Fractal) Release() {
+	f.releaseTextures()
+	if f.paramBuffer != nil {
+		f.paramBuffer.Release()
+		f.paramBuffer = nil
+	}
+	if f.camera != nil {
+		f.camera.Release()
+		f.camera = nil
+	}
+	for _, p := range []**wgpu.RenderPipeline{&f.sample, &f.show, &f.resolve} {
+		if *p != nil {
+			(*p).Release()
+			*p = nil
+		}
+	}
+	for _, l := range []**wgpu.PipelineLayout{&f.samplePipelineLayout, &f.showPipelineLayout} {
+		if *l != nil {
+			(*l).Release()
+			*l = nil
+		}
+	}
+	for _, l := range []**wgpu.BindGroupLayout{&f.sampleLayout, &f.showLayout} {
+		if *l != nil {
+			(*l).Release()
+			*l = nil
+		}
+	}
+}
@@ -0,0 +1,141 @@
+struct Params {
+  // shape is 0 for the Mandelbulb and 1 for the Menger sponge.
+  shape: u32,
+  iterations: u32,
+  // sample is how many samples are in the accumulated image so far, and
+  // jitter where in its pixel this one is taken.
+  sample: u32,
+  power: f32,
+  jitter: vec2<f32>,
+  // pixel is the size of a pixel in clip space.
+  pixel: vec2<f32>,
+};
+
+struct Camera {
+  angle: f32,
+  aspect: f32,
+};
+
+struct VertexOutput {
+  @builtin(position) pos: vec4<f32>,
+  @location(0) uv: vec2<f32>,
+};
+
+@group(0) @binding(0) var<uniform> params: Params;
+@group(0) @binding(1) var<uniform> camera: Camera;
+// previous is the mean of the samples so far.
+@group(0) @binding(2) var previous: texture_2d<f32>;
+
+// The cell tile is stretched over the whole window, and each pixel marches a
+// ray from the orbit camera.
+@vertex
+fn tile_vs(@location(0) pos: vec2<f32>) -> VertexOutput {
+  var output: VertexOutput;
+  output.uv = pos / 0.8;
+  output.pos = vec4<f32>(output.uv, 0.0, 1.0);
+  return output;
+}
+
+// mandelbulb is the distance estimate to the power-n Mandelbulb, from the
+// derivative of its orbit (White and Nylander, 2009).
+fn mandelbulb(p: vec3<f32>) -> f32 {
+  let n = params.power;
+  var z = p;
+  var dr = 1.0;
+  var r = length(z);
+  for (var i = 0u; i < params.iterations && r < 2.0; i += 1u) {
+    let theta = acos(clamp(z.z / r, -1.0, 1.0)) * n;
+    let phi = atan2(z.y, z.x) * n;
+    dr = pow(r, n - 1.0) * n * dr + 1.0;
+    z = pow(r, n) * vec3(sin(theta) * cos(phi), sin(phi) * sin(theta), cos(theta)) + p;
+    r = length(z);
+  }
+  return 0.5 * log(max(r, 1e-6)) * r / dr;
+}
+
+// menger is the distance to the Menger sponge filling [-1, 1], cutting the
+// cross out of every level of the box (Quilez, 2011).
+fn menger(p: vec3<f32>) -> f32 {
+  let q = abs(p) - 1.0;
+  var d = length(max(q, vec3(0.0))) + min(max(q.x, max(q.y, q.z)), 0.0);
+  var scale = 1.0;
+  for (var i = 0u; i < params.iterations; i += 1u) {
+    let m = p * scale;
+    let a = m - 2.0 * floor(m / 2.0) - 1.0;
+    scale *= 3.0;
+    let r = abs(1.0 - 3.0 * abs(a));
+    let hole = (min(max(r.x, r.y), min(max(r.y, r.z), max(r.z, r.x))) - 1.0) / scale;
+    d = max(d, hole);
+  }
+  return d;
+}
+
+fn estimate(p: vec3<f32>) -> f32 {
+  if params.shape == 1u {
+    return menger(p);
+  }
+  // The bulb's axis is its z, which is turned to point up.
+  return mandelbulb(p.xzy);
+}
+
+fn normal(p: vec3<f32>, e: f32) -> vec3<f32> {
+  let k = vec2(1.0, -1.0);
+  return normalize(k.xyy * estimate(p + k.xyy * e) + k.yyx * estimate(p + k.yyx * e) +
+                   k.yxy * estimate(p + k.yxy * e) + k.xxx * estimate(p + k.xxx * e));
+}
+
+fn background(dir: vec3<f32>) -> vec3<f32> {
+  return mix(vec3(0.02, 0.02, 0.05), vec3(0.15, 0.2, 0.35), 0.5 + 0.5 * dir.y);
+}
+
+fn shade(eye: vec3<f32>, dir: vec3<f32>) -> vec3<f32> {
+  var t = 0.0;
+  for (var i = 0; i < 256; i += 1) {
+    let p = eye + dir * t;
+    let d = estimate(p);
+    if d < 0.0004 * t {
+      let n = normal(p, 0.0002 * t);
+      let light = normalize(vec3(0.5, 0.8, 0.3));
+      // Rays that took many steps to get here passed close to a lot of
+      // the fractal, which stands in for ambient occlusion.
+      let occlusion = 1.0 - f32(i) / 256.0;
+      let diffuse = max(dot(n, light), 0.0);
+      let colour = mix(vec3(0.9, 0.6, 0.3), vec3(0.4, 0.6, 0.9), 0.5 + 0.5 * n.y);
+      return colour * (0.15 + 0.85 * diffuse) * occlusion * occlusion;
+    }
+    t += d;
+    if t > 8.0 {
+      break;
+    }
+  }
+  return background(dir);
+}
+
+// sample_fs takes one more sample of the pixel and adds it into the mean.
+@fragment
+fn sample_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  let eye = vec3(sin(camera.angle), 0.6, cos(camera.angle)) * 3.0;
+  let forward = normalize(-eye);
+  let right = normalize(cross(forward, vec3(0.0, 1.0, 0.0)));
+  let up = cross(right, forward);
+  let uv = (input.uv + params.jitter * params.pixel) * vec2(camera.aspect, 1.0);
+  let dir = normalize(forward * 2.0 + right * uv.x + up * uv.y);
+  let colour = shade(eye, dir);
+
+  if params.sample == 0u {
+    return vec4(colour, 1.0);
+  }
+  let mean = textureLoad(previous, vec2<i32>(input.pos.xy), 0).rgb;
+  return vec4(mix(mean, colour, 1.0 / f32(params.sample + 1u)), 1.0);
+}
+
+@group(0) @binding(0) var image: texture_2d<f32>;
+
+// show_fs tone-maps the accumulated image onto the window.
+@fragment
+fn show_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  let size = vec2<f32>(textureDimensions(image));
+  let p = (input.uv * vec2(0.5, -0.5) + 0.5) * size;
+  let colour = textureLoad(image, min(vec2<i32>(p), vec2<i32>(size) - 1), 0).rgb;
+  return vec4(1.0 - exp(-2.0 * colour), 1.0);
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+// frameGraphLength is how many frames the graph goes back, and
+// frameGraphAverage how many of the latest the numbers over it average.
+const (
+	frameGraphLength  = 240
+	frameGraphAverage = 30
+)
+
+// frameTiming is how long a frame took from the start of the one before,
+// how much of it went on recording and submitting its commands, and how
+// long the GPU took over them after that.
+type frameTiming struct {
+	interval, cpu, gpu time.Duration
+}
+
+var (
+	graphFrame = [4]float32{0.2, 0.2, 0.22, 0.8}
+	graphCPU   = [4]float32{0.1, 0.35, 0.9, 1}
+	graphGPU   = [4]float32{0.9, 0.35, 0.05, 1}
+	graphLine  = [4]float32{1, 1, 1, 0.35}
+)
+
+// frameGraph shows a rolling bar chart of frame times in the bottom left
+// corner, each bar split into the time spent on the CPU and on the GPU,
+// with the frame rate and the averages over it. Shift+I shows and hides it.
+//
+// The GPU's time is from submitting the frame until the device says it is
+// done, which means waiting for it every frame. That costs the overlap
+// between one frame's GPU work and the next one's recording, so it is only
+// measured while the graph is shown.
+type frameGraph struct {
+	shown bool
+	text  *textRenderer
+
+	timings [frameGraphLength]frameTiming
+	next    int
+	count   int
+	// started is when the frame being recorded was started.
+	started time.Time
+}
+
+func newFrameGraph(s *State) (*frameGraph, error) {
+	text, err := newTextRenderer(s, s.surfaceFormat)
+	if err != nil {
+		return nil, err
+	}
+	return &frameGraph{text: text}, nil
+}
+
+// begin starts timing a frame.
+func (g *frameGraph) begin() {
+	if g == nil || !g.shown {
+		return
+	}
+	now := time.Now()
+	g.timings[g.next].interval = 0
+	if !g.started.IsZero() {
+		g.timings[g.next].interval = now.Sub(g.started)
+	}
+	g.started = now
+}
+
+// end finishes timing the frame index was submitted as, waiting for the
+// GPU to be done with it.
+func (g *frameGraph) end(s *State, index wgpu.SubmissionIndex) {
+	if g == nil || !g.shown || g.started.IsZero() {
+		return
+	}
+	t := &g.timings[g.next]
+	submitted := time.Now()
+	t.cpu = submitted.Sub(g.started)
+	s.device.Poll(true, &wgpu.WrappedSubmissionIndex{Queue: s.queue.Queue, SubmissionIndex: index})
+	t.gpu = time.Since(submitted)
+	t.interval = max(t.interval, t.cpu+t.gpu)
+	g.next = (g.next + 1) % frameGraphLength
+	g.count = min(g.count+1, frameGraphLength)
+}
+
+// toggle shows or hides the graph, starting it over when it comes back.
+func (g *frameGraph) toggle() {
+	if g == nil {
+		return
+	}
+	g.shown = !g.shown
+	g.count, g.next, g.started = 0, 0, time.Time{}
+}
+
+// at is the ith latest timing.
+func (g *frameGraph) at(i int) frameTiming {
+	return g.timings[(g.next-1-i+2*frameGraphLength)%frameGraphLength]
+}
+
+// update lays the graph out afresh from the timings so far.
+func (g *frameGraph) update(s *State) {
+	if g == nil || !g.shown {
+		return
+	}
+	t := g.text
+	t.setScale(s.scaled(2))
+	t.clear()
+	if g.count > 0 {
+		g.layOut(s)
+	}
+	if err := t.upload(); err != nil {
+		fmt.Println("laying out the frame graph:", err)
+	}
+}
+
+// layOut adds the chart and the numbers under it.
+func (g *frameGraph) layOut(s *State) {
+	t := g.text
+
+	var sum frameTiming
+	n := min(g.count, frameGraphAverage)
+	for i := 0; i < n; i++ {
+		f := g.at(i)
+		sum.interval += f.interval
+		sum.cpu += f.cpu
+		sum.gpu += f.gpu
+	}
+	ms := func(d time.Duration) string {
+		return s.format.Float(float64(d)/float64(time.Millisecond)/float64(n), 1) + " ms"
+	}
+	fps := float64(n) / sum.interval.Seconds()
+
+	// The chart's height is a whole number of 60 Hz frames, two at least.
+	longest := time.Duration(0)
+	for i := 0; i < g.count; i++ {
+		longest = max(longest, g.at(i).interval)
+	}
+	const vsync = time.Second / 60
+	top := vsync * time.Duration(max(2, math.Ceil(float64(longest)/float64(vsync))))
+
+	bar := float32(max(1, math.Round(float64(s.scaled(1)))))
+	width, height := bar*frameGraphLength, float32(s.scaled(100))
+	margin := s.scaled(hudMargin)
+	left, bottom := margin, float32(s.config.Height)-margin
+	scale := func(d time.Duration) float32 {
+		return height * float32(d) / float32(top)
+	}
+
+	t.addRect(left, bottom-height, width, height, uiPanel)
+	for i := 0; i < g.count; i++ {
+		f := g.at(i)
+		x := left + width - bar*float32(i+1)
+		t.addRect(x, bottom-scale(f.interval), bar, scale(f.interval), graphFrame)
+		t.addRect(x, bottom-scale(f.cpu), bar, scale(f.cpu), graphCPU)
+		t.addRect(x, bottom-scale(f.cpu+f.gpu), bar, scale(f.gpu), graphGPU)
+	}
+	for _, line := range []struct {
+		at   time.Duration
+		name string
+	}{{vsync, "60 fps"}, {2 * vsync, "30 fps"}} {
+		y := bottom - scale(line.at)
+		t.addRect(left, y, width, bar, graphLine)
+		t.addShadowedText(left+width+2*t.pixel, y-glyphHeight*t.pixel/2, textWhite, line.name)
+	}
+	y := bottom - height - 2*t.lineHeight()
+	t.addShadowedText(left, y, textWhite, fmt.Sprintf("%s fps, %s a frame", s.format.Float(fps, 1), ms(sum.interval)))
+	t.addShadowedText(left, y+t.lineHeight(), graphCPU, "cpu "+ms(sum.cpu))
+	t.addShadowedText(left+t.textWidth("cpu 000.0 ms  "), y+t.lineHeight(), graphGPU, "gpu "+ms(sum.gpu))
+}
+
+// draw records the graph over view, if it is shown.
+func (g *frameGraph) draw(s *State, encoder *commandEncoder, view *wgpu.TextureView) {
+	if g == nil || !g.shown {
+		return
+	}
+	g.text.draw(encoder, view, s.config.Width, s.config.Height)
+}
+
+func (g *frameGraph) Release() {
+	if g.text != nil {
+		g.text.Release()
+		g.text = nil
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// handleFullscreenKey switches between a window and the whole of the
+// monitor it is mostly on with F11. The swap chain follows through the
+// framebuffer size callback.
+func (s *State) handleFullscreenKey(key glfw.Key, action glfw.Action) {
+	if key != glfw.KeyF11 || action != glfw.Press || s.window == nil {
+		return
+	}
+	if s.window.GetMonitor() != nil {
+		g := s.windowed
+		s.window.SetMonitor(nil, g[0], g[1], g[2], g[3], 0)
+		return
+	}
+	monitor := s.currentMonitor()
+	if monitor == nil {
+		return
+	}
+	x, y := s.window.GetPos()
+	width, height := s.window.GetSize()
+	s.windowed = [4]int{x, y, width, height}
+	mode := monitor.GetVideoMode()
+	s.window.SetMonitor(monitor, 0, 0, mode.Width, mode.Height, mode.RefreshRate)
+}
+
+// currentMonitor is the monitor with the most of the window on it, or the
+// primary monitor if it is on none.
+func (s *State) currentMonitor() *glfw.Monitor {
+	x, y := s.window.GetPos()
+	width, height := s.window.GetSize()
+	best, most := glfw.GetPrimaryMonitor(), 0
+	for _, m := range glfw.GetMonitors() {
+		mode := m.GetVideoMode()
+		if mode == nil {
+			continue
+		}
+		mx, my := m.GetPos()
+		w := min(x+width, mx+mode.Width) - max(x, mx)
+		h := min(y+height, my+mode.Height) - max(y, my)
+		if w > 0 && h > 0 && w*h > most {
+			best, most = m, w*h
+		}
+	}
+	return best
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// gamepadDeadZone is how far a stick has to be pushed before it moves the
+// camera, past the drift of one left alone.
+const gamepadDeadZone = 0.2
+
+// gamepadButtons are the commands the buttons run, by action, as if their
+// keys were pressed.
+var gamepadButtons = map[glfw.GamepadButton]string{
+	glfw.ButtonA:           "pause",
+	glfw.ButtonB:           "step",
+	glfw.ButtonX:           "new-soup",
+	glfw.ButtonY:           "whole-grid",
+	glfw.ButtonStart:       "pause",
+	glfw.ButtonLeftBumper:  "slower",
+	glfw.ButtonRightBumper: "faster",
+	glfw.ButtonDpadLeft:    "previous-palette",
+	glfw.ButtonDpadRight:   "next-palette",
+}
+
+// gamepad reads the first gamepad plugged in once a frame: the left stick
+// pans, the right stick zooms in pushed up and out pulled down, and the
+// buttons run gamepadButtons.
+type gamepad struct {
+	joystick  glfw.Joystick
+	connected bool
+	// buttons are how they were last frame, to run commands as they go down.
+	buttons [len(glfw.GamepadState{}.Buttons)]glfw.Action
+	last    time.Time
+}
+
+// find is the first joystick GLFW knows a gamepad mapping for, keeping to
+// the one it had while it is still there.
+func (g *gamepad) find() (glfw.Joystick, bool) {
+	if g.connected && g.joystick.Present() && g.joystick.IsGamepad() {
+		return g.joystick, true
+	}
+	g.connected = false
+	for j := glfw.Joystick1; j <= glfw.JoystickLast; j++ {
+		if j.Present() && j.IsGamepad() {
+			fmt.Println("gamepad:", j.GetGamepadName())
+			g.joystick, g.connected = j, true
+			return j, true
+		}
+	}
+	return 0, false
+}
+
+// pollGamepad reads the gamepad, if there is one. GLFW only lets it be read
+// from the main thread.
+func (s *State) pollGamepad() {
+	g := &s.gamepad
+	now := time.Now()
+	dt := float32(min(now.Sub(g.last).Seconds(), 0.1))
+	g.last = now
+	j, ok := g.find()
+	if !ok {
+		return
+	}
+	state := j.GetGamepadState()
+	if state == nil {
+		return
+	}
+	for i, action := range state.Buttons {
+		pressed := action == glfw.Press && g.buttons[i] != glfw.Press
+		g.buttons[i] = action
+		if name, bound := gamepadButtons[glfw.GamepadButton(i)]; bound && pressed {
+			if c, ok := commandByAction(name); ok {
+				c.run(s)
+			}
+		}
+	}
+
+	if _, ok := s.sim.(cameraUser); !ok || s.camera == nil {
+		return
+	}
+	if _, browsing := s.mode.(sceneMode); browsing {
+		return
+	}
+	stick := func(a glfw.GamepadAxis) float32 {
+		v := state.Axes[a]
+		if max(v, -v) < gamepadDeadZone {
+			return 0
+		}
+		return v
+	}
+	// A stick pushed all the way pans a whole view a second, and zooms
+	// twice as far in or out every half second. Up is negative on sticks.
+	x, y, zoom := stick(glfw.AxisLeftX), stick(glfw.AxisLeftY), stick(glfw.AxisRightY)
+	if x == 0 && y == 0 && zoom == 0 {
+		return
+	}
+	step := dt / s.camera.zoom()
+	s.camera.move(x*step, -y*step, float32(math.Exp2(float64(-zoom*dt*2))))
+}
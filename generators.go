@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// generator decides which cells of a life grid start alive.
+type generator interface {
+	// Alive reports whether cell (x, y) of a width by height grid starts
+	// alive, drawing from rng for anything random.
+	Alive(rng *rand.Rand, x, y, width, height int) bool
+}
+
+// generators are the starting patterns -init can name. Each fills its shape
+// at random with the given density, so a density of 1 fills it solid.
+var generators = map[string]func(density float64) generator{
+	"soup":  func(d float64) generator { return soup{d} },
+	"block": func(d float64) generator { return centreBlock{d} },
+	"ring":  func(d float64) generator { return ring{d} },
+	"cross": func(d float64) generator { return cross{d} },
+	"empty": func(d float64) generator { return empty{} },
+}
+
+func generatorNames() []string {
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newGenerator(cfg InitConfig) (generator, error) {
+	if cfg.array != nil {
+		return arrayGenerator{cfg.array}, nil
+	}
+	create, ok := generators[cfg.Pattern]
+	if !ok {
+		return nil, fmt.Errorf("unknown starting pattern %q (have %v)", cfg.Pattern, generatorNames())
+	}
+	if cfg.Density < 0 || cfg.Density > 1 {
+		return nil, fmt.Errorf("density %g out of range [0, 1]", cfg.Density)
+	}
+	return create(cfg.Density), nil
+}
+
+// generate returns a width by height grid of cells from g.
+func generate(g generator, rng *rand.Rand, width, height int) []uint32 {
+	cells := make([]uint32, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if g.Alive(rng, x, y, width, height) {
+				cells[y*width+x] = 1
+			}
+		}
+	}
+	return cells
+}
+
+// soup is the whole grid at random.
+type soup struct{ density float64 }
+
+func (g soup) Alive(rng *rand.Rand, x, y, width, height int) bool {
+	return rng.Float64() < g.density
+}
+
+// centreBlock is a square in the middle, half as wide as the grid's shorter
+// side.
+type centreBlock struct{ density float64 }
+
+func (g centreBlock) Alive(rng *rand.Rand, x, y, width, height int) bool {
+	half := min(width, height) / 4
+	dx, dy := x-width/2, y-height/2
+	return dx >= -half && dx < half && dy >= -half && dy < half && rng.Float64() < g.density
+}
+
+// ring is a band around the middle, a third of the grid's shorter side out
+// and a twelfth of it thick.
+type ring struct{ density float64 }
+
+func (g ring) Alive(rng *rand.Rand, x, y, width, height int) bool {
+	side := float64(min(width, height))
+	outer, inner := side/3, side/3-max(side/12, 1)
+	dx, dy := float64(x-width/2)+0.5, float64(y-height/2)+0.5
+	d := dx*dx + dy*dy
+	return d < outer*outer && d >= inner*inner && rng.Float64() < g.density
+}
+
+// cross is a bar across the middle each way, a sixteenth of the grid's
+// shorter side thick.
+type cross struct{ density float64 }
+
+func (g cross) Alive(rng *rand.Rand, x, y, width, height int) bool {
+	half := max(min(width, height)/32, 1)
+	dx, dy := x-width/2, y-height/2
+	on := dx >= -half && dx < half || dy >= -half && dy < half
+	return on && rng.Float64() < g.density
+}
+
+// empty starts with nothing alive.
+type empty struct{}
+
+func (empty) Alive(rng *rand.Rand, x, y, width, height int) bool {
+	return false
+}
+
+// arrayGenerator starts the cells that are non-zero in an array -init-file
+// read alive.
+type arrayGenerator struct{ array *npyArray }
+
+func (g arrayGenerator) Alive(rng *rand.Rand, x, y, width, height int) bool {
+	return g.array.at(x, y, 0, width, height) != 0
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// trackpadPan is how far a scroll of one line pans with -trackpad, as a
+// part of the view.
+const trackpadPan = 0.05
+
+// ctrlHeld and altHeld are whether either of the keys is down.
+func ctrlHeld(w *glfw.Window) bool {
+	return w.GetKey(glfw.KeyLeftControl) == glfw.Press || w.GetKey(glfw.KeyRightControl) == glfw.Press
+}
+
+func altHeld(w *glfw.Window) bool {
+	return w.GetKey(glfw.KeyLeftAlt) == glfw.Press || w.GetKey(glfw.KeyRightAlt) == glfw.Press
+}
+
+// handleTrackpadScroll pans with a two-finger drag and zooms about the
+// cursor with a pinch, with -trackpad, returning whether the scroll was
+// taken as either. GLFW has no touch or gesture events, but trackpads send
+// their gestures as scrolling: a drag scrolls both ways at once, and a
+// pinch scrolls with Ctrl held, on the platforms that pass it on at all.
+func (s *State) handleTrackpadScroll(w *glfw.Window, x, y float64) bool {
+	if !s.cfg.Trackpad || s.camera == nil {
+		return false
+	}
+	if _, ok := s.sim.(cameraUser); !ok {
+		return false
+	}
+	if ctrlHeld(w) {
+		s.handleCameraScroll(w, y)
+		return true
+	}
+	// The grid goes the way the fingers do.
+	step := float32(trackpadPan) * 2 / s.camera.zoom()
+	s.camera.move(-float32(x)*step, float32(y)*step, 1)
+	return true
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxDownload is the most get reads of any one file, so that a broken
+// index can't fill the disk.
+const maxDownload = 64 << 20
+
+// getClient is what get downloads with.
+var getClient = &http.Client{Timeout: 2 * time.Minute}
+
+// packIndex is a community index of rule packs and patterns, a JSON file
+// served over HTTPS. File URLs can be relative to the index's own.
+type packIndex struct {
+	Packs []indexedPack `json:"packs"`
+}
+
+// indexedPack is a rule pack, installed into the plugins directory under
+// its name, or a pattern, whose files go straight into the patterns
+// directory.
+type indexedPack struct {
+	Name        string        `json:"name"`
+	Kind        string        `json:"kind"`
+	Description string        `json:"description"`
+	Files       []indexedFile `json:"files"`
+}
+
+type indexedFile struct {
+	Path   string `json:"path"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// runGet is the get subcommand: it lists the index with no arguments and
+// otherwise downloads the packs named, checking every file against its
+// checksum before any of them are put in place. Nothing is installed
+// unless every pack downloads and checks out.
+func runGet(args []string) error {
+	defaults := defaultConfig()
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	index := fs.String("index", "", "URL of the rule pack index, https only")
+	plugins := fs.String("plugins", defaults.Plugins.Dir, "directory to install rule packs into")
+	patterns := fs.String("patterns", "patterns", "directory to save patterns into")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s get -index URL [flags] [name ...]\n\nWith no names, lists what the index has.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *index == "" {
+		return fmt.Errorf("get needs a rule pack index: -index https://.../index.json")
+	}
+	base, err := httpsURL(*index)
+	if err != nil {
+		return err
+	}
+	b, err := download(base)
+	if err != nil {
+		return err
+	}
+	var idx packIndex
+	if err := json.Unmarshal(b, &idx); err != nil {
+		return fmt.Errorf("%s: %w", base, err)
+	}
+
+	if fs.NArg() == 0 {
+		for _, p := range idx.Packs {
+			fmt.Printf("%-20s %-8s %s\n", p.Name, p.Kind, p.Description)
+		}
+		return nil
+	}
+	var fetched []fetchedPack
+	defer func() {
+		for _, f := range fetched {
+			os.RemoveAll(f.tmp)
+		}
+	}()
+	for _, name := range fs.Args() {
+		p, ok := idx.find(name)
+		if !ok {
+			return fmt.Errorf("%s has no pack called %q", base, name)
+		}
+		var dir string
+		switch p.Kind {
+		case "plugin":
+			dir = *plugins
+		case "pattern":
+			dir = *patterns
+		default:
+			return fmt.Errorf("%s: unknown kind %q, want plugin or pattern", name, p.Kind)
+		}
+		tmp, err := p.fetch(base, dir)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		fetched = append(fetched, fetchedPack{p, dir, tmp})
+	}
+	for _, f := range fetched {
+		if err := f.install(); err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// fetchedPack is a pack downloaded and checked into tmp, a directory
+// inside dir, the one it is to be installed into.
+type fetchedPack struct {
+	indexedPack
+	dir, tmp string
+}
+
+func (idx *packIndex) find(name string) (indexedPack, bool) {
+	for _, p := range idx.Packs {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return indexedPack{}, false
+}
+
+// fetch downloads p's files into a new directory inside dir and checks
+// them, returning the directory.
+func (p indexedPack) fetch(base *url.URL, dir string) (string, error) {
+	if p.Kind == "plugin" && !pluginName.MatchString(p.Name) {
+		return "", fmt.Errorf("bad plugin name: plugin names are lower-case letters, digits, - and _")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.MkdirTemp(dir, ".get-*")
+	if err != nil {
+		return "", err
+	}
+	if err := p.fetchFiles(base, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	return tmp, nil
+}
+
+func (p indexedPack) fetchFiles(base *url.URL, tmp string) error {
+	for _, f := range p.Files {
+		if !filepath.IsLocal(filepath.FromSlash(f.Path)) {
+			return fmt.Errorf("bad file path %q", f.Path)
+		}
+		ref, err := url.Parse(f.URL)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Path, err)
+		}
+		u, err := httpsURL(base.ResolveReference(ref).String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Path, err)
+		}
+		b, err := download(u)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(b)
+		if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, f.SHA256) {
+			return fmt.Errorf("%s: checksum %s, the index says %s", f.Path, got, f.SHA256)
+		}
+		path := filepath.Join(tmp, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// install puts what was fetched in place: a plugin replaces whatever was
+// in its directory before, a pattern's files overwrite any of the same
+// name.
+func (f fetchedPack) install() error {
+	if f.Kind == "plugin" {
+		final := filepath.Join(f.dir, f.Name)
+		if err := os.RemoveAll(final); err != nil {
+			return err
+		}
+		if err := os.Rename(f.tmp, final); err != nil {
+			return err
+		}
+		fmt.Printf("installed %s into %s, run it with -sim %s\n", f.Name, final, f.Name)
+		return nil
+	}
+	for _, file := range f.Files {
+		path := filepath.FromSlash(file.Path)
+		final := filepath.Join(f.dir, path)
+		if err := os.MkdirAll(filepath.Dir(final), 0o755); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(f.tmp, path), final); err != nil {
+			return err
+		}
+		fmt.Printf("saved %s\n", final)
+	}
+	return nil
+}
+
+func httpsURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("%s: only https is fetched from", s)
+	}
+	return u, nil
+}
+
+func download(u *url.URL) ([]byte, error) {
+	resp, err := getClient.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxDownload+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", u, err)
+	}
+	if len(b) > maxDownload {
+		return nil, fmt.Errorf("%s: larger than %d bytes", u, maxDownload)
+	}
+	return b, nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+// gifCapture collects a frame every cfg.Every generations until it has
+// cfg.Generations worth, for writing out as an animated GIF.
+type gifCapture struct {
+	cfg    GIFConfig
+	path   string
+	frames []*image.RGBA
+	// next is the generation the next frame is taken at. Frames that run
+	// several ticks can go past it, and then the first after it is taken.
+	next int
+}
+
+// frames is how many frames cfg makes.
+func (cfg GIFConfig) frames() int {
+	return (cfg.Generations + cfg.Every - 1) / cfg.Every
+}
+
+func (cfg GIFConfig) check() error {
+	if cfg.Generations < 1 || cfg.Every < 1 {
+		return fmt.Errorf("GIF of %d generations every %d must have both positive", cfg.Generations, cfg.Every)
+	}
+	if cfg.FPS < 1 || cfg.FPS > 100 {
+		return fmt.Errorf("GIF frame rate %d out of range [1, 100]", cfg.FPS)
+	}
+	return nil
+}
+
+// startGIF starts capturing the next generations the window shows.
+func (s *State) startGIF() {
+	if s.gif != nil {
+		fmt.Printf("already capturing a GIF, %d frames of %d in\n", len(s.gif.frames), s.gif.cfg.frames())
+		return
+	}
+	cfg := s.cfg.GIF
+	if err := cfg.check(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	path := cfg.Path
+	if path == "" {
+		path = time.Now().Format("generations-20060102-150405") + ".gif"
+	}
+	s.gif = &gifCapture{cfg: cfg, path: path, next: s.steps + 1}
+	fmt.Printf("capturing %s generations into %s\n", s.format.Count(int64(cfg.Generations)), path)
+}
+
+// capture takes the frame just presented if it is one of the capture's,
+// and writes the GIF in the background once it has them all.
+func (g *gifCapture) capture(s *State) {
+	if g == nil {
+		return
+	}
+	if s.steps < g.next {
+		return
+	}
+	g.next = s.steps + g.cfg.Every
+	if len(g.frames) > 0 && (s.config.Width != uint32(g.frames[0].Rect.Dx()) || s.config.Height != uint32(g.frames[0].Rect.Dy())) {
+		fmt.Println("the window changed size, so the GIF was dropped")
+		s.gif = nil
+		return
+	}
+	img, err := s.captureFrame()
+	if err != nil {
+		fmt.Println("capturing a GIF frame:", err)
+		s.gif = nil
+		return
+	}
+	g.frames = append(g.frames, img)
+	if len(g.frames) < g.cfg.frames() {
+		return
+	}
+	s.gif = nil
+	s.saving.Add(1)
+	go func() {
+		defer s.saving.Done()
+		if err := g.save(s); err != nil {
+			fmt.Println("saving the GIF:", err)
+		}
+	}()
+}
+
+func (g *gifCapture) save(s *State) error {
+	start := time.Now()
+	f, err := os.Create(g.path)
+	if err != nil {
+		return err
+	}
+	if err := gif.EncodeAll(f, quantize(g.frames, g.cfg.FPS)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("saved %s, %s frames in %s\n", g.path, s.format.Count(int64(len(g.frames))), s.format.Duration(time.Since(start)))
+	return s.manifest.AddArtifact("gif", g.path)
+}
+
+// quantize turns frames into a GIF playing at fps, sharing one palette of
+// the 256 most common colours across them. Colours are counted to 5 bits a
+// channel, and each of those is drawn as the nearest in the palette. Cells
+// come in a handful of colours, so most animations have no more than 256
+// and come out exact, or nearly so.
+func quantize(frames []*image.RGBA, fps int) *gif.GIF {
+	type bucket struct {
+		n       int
+		r, g, b int
+	}
+	buckets := make([]bucket, 1<<15)
+	key := func(pix []uint8) int {
+		return int(pix[0]>>3)<<10 | int(pix[1]>>3)<<5 | int(pix[2]>>3)
+	}
+	for _, f := range frames {
+		for i := 0; i < len(f.Pix); i += 4 {
+			b := &buckets[key(f.Pix[i:])]
+			b.n++
+			b.r += int(f.Pix[i])
+			b.g += int(f.Pix[i+1])
+			b.b += int(f.Pix[i+2])
+		}
+	}
+	var used []int
+	for k, b := range buckets {
+		if b.n > 0 {
+			used = append(used, k)
+		}
+	}
+	sort.Slice(used, func(i, j int) bool { return buckets[used[i]].n > buckets[used[j]].n })
+	if len(used) > 256 {
+		used = used[:256]
+	}
+	palette := make(color.Palette, len(used))
+	for i, k := range used {
+		b := buckets[k]
+		palette[i] = color.RGBA{uint8(b.r / b.n), uint8(b.g / b.n), uint8(b.b / b.n), 255}
+	}
+
+	// Which entry each bucket is drawn as, looked up the first time a pixel
+	// falls in it.
+	nearest := make([]int16, len(buckets))
+	for i := range nearest {
+		nearest[i] = -1
+	}
+	out := &gif.GIF{}
+	delay := (100 + fps/2) / fps
+	for _, f := range frames {
+		p := image.NewPaletted(f.Rect, palette)
+		for i, j := 0, 0; i < len(f.Pix); i, j = i+4, j+1 {
+			k := key(f.Pix[i:])
+			if nearest[k] < 0 {
+				nearest[k] = int16(palette.Index(color.RGBA{f.Pix[i], f.Pix[i+1], f.Pix[i+2], 255}))
+			}
+			p.Pix[j] = uint8(nearest[k])
+		}
+		out.Image = append(out.Image, p)
+		out.Delay = append(out.Delay, delay)
+	}
+	return out
+}
+
+// runGIF steps the simulation without a window, capturing cfg.GIF's
+// generations into a GIF as fast as it can.
+func runGIF(cfg *Config) (err error) {
+	g := cfg.GIF
+	if err := g.check(); err != nil {
+		return err
+	}
+	if g.Width < 1 || g.Height < 1 {
+		return fmt.Errorf("GIF frame size %dx%d must be positive", g.Width, g.Height)
+	}
+
+	s := &State{start: time.Now(), mainThread: newMainThread()}
+	defer s.Destroy()
+
+	s.instance = wgpu.CreateInstance(nil)
+	s.setDevice()
+	s.config = &wgpu.SwapChainDescriptor{
+		Format: wgpu.TextureFormat_RGBA8Unorm,
+		Width:  uint32(g.Width),
+		Height: uint32(g.Height),
+	}
+	if err := s.init(cfg); err != nil {
+		return err
+	}
+
+	capture := &gifCapture{cfg: g, path: g.Path}
+	for len(capture.frames) < g.frames() {
+		s.mainThread.runQueued()
+		img, err := s.captureFrame()
+		if err != nil {
+			return err
+		}
+		capture.frames = append(capture.frames, img)
+		for i := 0; i < g.Every; i++ {
+			if err := s.stepHeadless(); err != nil {
+				return err
+			}
+		}
+	}
+	return capture.save(s)
+}
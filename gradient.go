@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+// gradientSize is how many colours a gradient is sampled into for the cell
+// shaders.
+const gradientSize = 256
+
+// gradient is a ramp of colours spaced evenly from the first to the last.
+type gradient [][3]float32
+
+// ageGradients are the gradients -age-gradient can name, running from the
+// colour of newborn cells to that of the oldest. fade is the ramp ages have
+// always been drawn in.
+var ageGradients = map[string]gradient{
+	"fade": {{1, 0.95, 0.6}, {0.1, 0.15, 0.5}},
+	"viridis": {
+		{0.993, 0.906, 0.144}, {0.369, 0.788, 0.383}, {0.128, 0.567, 0.551},
+		{0.229, 0.322, 0.546}, {0.267, 0.005, 0.329},
+	},
+	"inferno": {
+		{0.988, 1, 0.645}, {0.988, 0.645, 0.040}, {0.865, 0.317, 0.226},
+		{0.578, 0.148, 0.404}, {0.258, 0.039, 0.406}, {0.05, 0.02, 0.1},
+	},
+	"heat":  {{1, 1, 1}, {1, 0.9, 0.2}, {0.9, 0.2, 0.05}, {0.3, 0.02, 0.02}},
+	"mono":  {{1, 1, 1}, {0.15, 0.15, 0.15}},
+	"pulse": {{0.3, 1, 0.4}, {0.1, 0.5, 1}, {0.6, 0.1, 0.8}, {0.2, 0.05, 0.2}},
+}
+
+func ageGradientNames() []string {
+	names := make([]string, 0, len(ageGradients))
+	for name := range ageGradients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// at is the colour t of the way along g, blending the stops either side.
+func (g gradient) at(t float32) [3]float32 {
+	if len(g) == 1 {
+		return g[0]
+	}
+	x := t * float32(len(g)-1)
+	i := min(int(x), len(g)-2)
+	f := x - float32(i)
+	var c [3]float32
+	for j := range c {
+		c[j] = g[i][j] + (g[i+1][j]-g[i][j])*f
+	}
+	return c
+}
+
+// texels samples g into gradientSize RGBA8 colours.
+func (g gradient) texels() []byte {
+	texels := make([]byte, 0, 4*gradientSize)
+	for i := 0; i < gradientSize; i++ {
+		c := g.at(float32(i) / (gradientSize - 1))
+		texels = append(texels, byte(c[0]*255+0.5), byte(c[1]*255+0.5), byte(c[2]*255+0.5), 255)
+	}
+	return texels
+}
+
+// gradientTexture uploads the gradient called name as a 1D texture for the
+// cell shaders to look colours up in.
+func (s *State) gradientTexture(name string) (*wgpu.Texture, *wgpu.TextureView, error) {
+	g, ok := ageGradients[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown age gradient %q (have %v)", name, ageGradientNames())
+	}
+	size := wgpu.Extent3D{Width: gradientSize, Height: 1, DepthOrArrayLayers: 1}
+	texture, err := s.device.CreateTexture(&wgpu.TextureDescriptor{
+		Label:         "age gradient",
+		Usage:         wgpu.TextureUsage_TextureBinding | wgpu.TextureUsage_CopyDst,
+		Dimension:     wgpu.TextureDimension_1D,
+		Size:          size,
+		Format:        wgpu.TextureFormat_RGBA8Unorm,
+		MipLevelCount: 1,
+		SampleCount:   1,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	err = s.queue.WriteTexture(texture.AsImageCopy(), g.texels(),
+		&wgpu.TextureDataLayout{BytesPerRow: 4 * gradientSize, RowsPerImage: 1}, &size)
+	if err != nil {
+		texture.Release()
+		return nil, nil, err
+	}
+	view, err := texture.CreateView(nil)
+	if err != nil {
+		texture.Release()
+		return nil, nil, err
+	}
+	return texture, view, nil
+}
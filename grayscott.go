@@ -0,0 +1,350 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"math/rand"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed grayscott_compute.wgsl
+var grayScottCompute string
+
+//go:embed grayscott_draw.wgsl
+var grayScottDraw string
+
+// GrayScott is a two-chemical reaction-diffusion system. U is fed in at the
+// feed rate, V is removed at the kill rate and U+2V -> 3V wherever they meet.
+type GrayScott struct {
+	bindGroupLayout    *wgpu.BindGroupLayout
+	pipelineLayout     *wgpu.PipelineLayout
+	drawPipelineLayout *wgpu.PipelineLayout
+	pipeline           *wgpu.RenderPipeline
+	simulationPipeline *wgpu.ComputePipeline
+
+	params           *wgpu.Buffer
+	integration      *integration
+	cellStateStorage []*wgpu.Buffer
+	// rateBindGroups has, for a step from either cell state, a bind group
+	// for each stage of the integrator. The first binds the cell state
+	// itself and is also the one drawn with.
+	rateBindGroups [2][]*wgpu.BindGroup
+	vertices       *wgpu.Buffer
+	camera         *viewCamera
+	queue          *uploadQueue
+	format         numberFormat
+	width, height  int
+	steps          int
+
+	feed, kill         float32
+	diffuseU, diffuseV float32
+	iterations         int
+}
+
+func newGrayScott(s *State, cfg *Config) (sim Simulation, err error) {
+	if cfg.GrayScott.StepsPerFrame < 1 {
+		return nil, fmt.Errorf("gray-scott needs at least one step per frame, got %d", cfg.GrayScott.StepsPerFrame)
+	}
+	if cfg.GrayScott.DT <= 0 {
+		return nil, fmt.Errorf("gray-scott time step must be positive, got %v", cfg.GrayScott.DT)
+	}
+	g := &GrayScott{
+		vertices:   s.vertexBuffer,
+		camera:     s.camera,
+		queue:      s.queue,
+		format:     s.format,
+		width:      s.gridWidth,
+		height:     s.gridHeight,
+		feed:       cfg.GrayScott.Feed,
+		kill:       cfg.GrayScott.Kill,
+		diffuseU:   cfg.GrayScott.DiffuseU,
+		diffuseV:   cfg.GrayScott.DiffuseV,
+		iterations: cfg.GrayScott.StepsPerFrame,
+	}
+	defer func() {
+		if err != nil {
+			g.Release()
+		}
+	}()
+
+	drawShader := s.createShader("gray-scott render shader", grayScottDraw+cameraShader)
+	defer drawShader.Release()
+
+	computeShader := s.createShader("gray-scott compute shader", grayScottCompute)
+	defer computeShader.Release()
+
+	g.bindGroupLayout, err = s.bindGroupLayout("gray-scott",
+		bufferEntry(0, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(2, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(3, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(4, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	g.pipelineLayout, err = s.pipelineLayout("gray-scott", g.bindGroupLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	g.drawPipelineLayout, err = s.pipelineLayout("gray-scott render", g.bindGroupLayout, s.cameraLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	g.pipeline, err = s.renderPipeline("gray-scott render", g.drawPipelineLayout, drawShader, "main_vs", "main_fs")
+	if err != nil {
+		return nil, err
+	}
+	g.simulationPipeline, err = s.computePipeline("gray-scott compute", g.pipelineLayout, computeShader, "main")
+	if err != nil {
+		return nil, err
+	}
+
+	g.integration, err = newIntegration(s, cfg.Integrator, "single", cfg.GrayScott.DT, 2)
+	if err != nil {
+		return nil, err
+	}
+	g.params = s.uniformBuffer("gray-scott params", g.paramBytes())
+
+	cells := grayScottSeed(s.rand, g.width, g.height)
+	if a := cfg.Init.array; a != nil {
+		cells = grayScottArray(a, g.width, g.height)
+	}
+	g.setCells(s, wgpu.ToBytes(cells))
+	return g, nil
+}
+
+// grayScottArray is cells from an -init-file array of U and V, or of just V
+// with U making up the rest.
+func grayScottArray(a *npyArray, width, height int) []float32 {
+	cells := make([]float32, width*height*2)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := (y*width + x) * 2
+			if a.channels == 1 {
+				v := a.at(x, y, 0, width, height)
+				cells[i], cells[i+1] = 1-v, v
+			} else {
+				cells[i], cells[i+1] = a.at(x, y, 0, width, height), a.at(x, y, 1, width, height)
+			}
+		}
+	}
+	return cells
+}
+
+func (g *GrayScott) paramBytes() []byte {
+	return wgpu.ToBytes([]float32{g.feed, g.kill, g.diffuseU, g.diffuseV})
+}
+
+// grayScottSeed starts with U everywhere and drops a few squares of V near
+// the middle for the reaction to spread from. Squares that stick out past an
+// edge wrap around like the simulation does.
+func grayScottSeed(rng *rand.Rand, width, height int) []float32 {
+	cells := make([]float32, width*height*2)
+	for i := 0; i < len(cells); i += 2 {
+		cells[i] = 1
+	}
+	for n := 0; n < 8; n++ {
+		cx := width/4 + rng.Intn(max(width/2, 1))
+		cy := height/4 + rng.Intn(max(height/2, 1))
+		for y := cy - 3; y <= cy+3; y++ {
+			for x := cx - 3; x <= cx+3; x++ {
+				i := (((y+height)%height)*width + (x+width)%width) * 2
+				cells[i], cells[i+1] = 0.5, 0.25
+			}
+		}
+	}
+	return cells
+}
+
+// setCells replaces both cell state buffers with cells.
+func (g *GrayScott) setCells(s *State, cells []byte) {
+	g.releaseCells()
+	g.cellStateStorage = []*wgpu.Buffer{
+		s.storageBuffer(cells),
+		s.storageBuffer(cells),
+	}
+	from, to := g.integration.setCells(s, g.cellStateStorage)
+	for p := range g.rateBindGroups {
+		for i := range from[p] {
+			g.rateBindGroups[p] = append(g.rateBindGroups[p], s.bindGroup("gray-scott rate", g.bindGroupLayout,
+				s.gridBuffer, g.params, from[p][i], to[i], g.integration.step))
+		}
+	}
+}
+
+func (g *GrayScott) ResizeGrid(s *State, r gridRemap) error {
+	cells, err := s.readBuffer(g.cellStateStorage[g.steps%2])
+	if err != nil {
+		return err
+	}
+	g.width, g.height = r.width, r.height
+	// New cells start out as pure U, like the whole grid does.
+	g.setCells(s, r.cells(cells, 8, wgpu.ToBytes([]float32{1, 0})))
+	return nil
+}
+
+// Population is the total amount of V.
+func (g *GrayScott) Population(s *State) (float64, error) {
+	data, err := s.readBuffer(g.cellStateStorage[g.steps%2])
+	if err != nil {
+		return 0, err
+	}
+	var total float64
+	for _, c := range wgpu.FromBytes[[2]float32](data) {
+		total += float64(c[1])
+	}
+	return total, nil
+}
+
+// Fields reads the concentrations of U and V.
+func (g *GrayScott) Fields(s *State) (*fieldSet, error) {
+	data, err := s.readBuffer(g.cellStateStorage[g.steps%2])
+	if err != nil {
+		return nil, err
+	}
+	cells := wgpu.FromBytes[[2]float32](data)
+	u, v := make([]float32, len(cells)), make([]float32, len(cells))
+	for i, c := range cells {
+		u[i], v[i] = c[0], c[1]
+	}
+	return &fieldSet{
+		width:    g.width,
+		height:   g.height,
+		channels: []fieldChannel{{"U", u}, {"V", v}},
+		attributes: map[string]string{
+			"feed":        formatAttribute(g.feed),
+			"kill":        formatAttribute(g.kill),
+			"diffusion_u": formatAttribute(g.diffuseU),
+			"diffusion_v": formatAttribute(g.diffuseV),
+		},
+	}, nil
+}
+
+func (g *GrayScott) Step(encoder *commandEncoder) {
+	computePass := encoder.BeginComputePass(nil)
+	defer computePass.Release()
+
+	for i := 0; i < g.iterations; i++ {
+		p := g.steps % 2
+		g.integration.record(computePass, p, g.simulationPipeline, g.rateBindGroups[p], uint32(g.width+7)/8, uint32(g.height+7)/8)
+		g.steps += 1
+	}
+	computePass.End()
+}
+
+func (g *GrayScott) batchesSteps() bool { return true }
+
+func (g *GrayScott) squareCells() bool { return true }
+
+func (g *GrayScott) usesCamera() {}
+
+func (g *GrayScott) Draw(pass *renderPass) {
+	pass.SetPipeline(g.pipeline)
+	pass.SetBindGroup(0, g.rateBindGroups[g.steps%2][0], nil)
+	pass.SetBindGroup(1, g.camera.bindGroup, nil)
+	pass.SetVertexBuffer(0, g.vertices, 0, wgpu.WholeSize)
+	pass.Draw(6, uint32(g.width*g.height), 0, 0)
+}
+
+// HandleKey nudges the feed rate with F and the kill rate with K, holding
+// shift to decrease them.
+func (g *GrayScott) HandleKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if action == glfw.Release {
+		return
+	}
+	delta := float32(0.001)
+	if mods&glfw.ModShift != 0 {
+		delta = -delta
+	}
+	switch key {
+	case glfw.KeyF:
+		g.feed = clamp32(g.feed+delta, 0, 0.1)
+	case glfw.KeyK:
+		g.kill = clamp32(g.kill+delta, 0, 0.1)
+	default:
+		return
+	}
+	g.updateParams()
+}
+
+func (g *GrayScott) updateParams() {
+	if err := g.queue.WriteBuffer(g.params, 0, g.paramBytes()); err != nil {
+		panic(err)
+	}
+	fmt.Printf("gray-scott feed %s kill %s\n", g.format.Float(float64(g.feed), 3), g.format.Float(float64(g.kill), 3))
+}
+
+func (g *GrayScott) Parameters() []parameter {
+	return []parameter{
+		{"feed", float64(g.feed), 0, 0.1},
+		{"kill", float64(g.kill), 0, 0.1},
+	}
+}
+
+func (g *GrayScott) SetParameter(name string, value float64) error {
+	switch name {
+	case "feed":
+		g.feed = clamp32(float32(value), 0, 0.1)
+	case "kill":
+		g.kill = clamp32(float32(value), 0, 0.1)
+	default:
+		return fmt.Errorf("gray-scott has no parameter %q", name)
+	}
+	g.updateParams()
+	return nil
+}
+
+func clamp32(v, lo, hi float32) float32 {
+	return max(lo, min(v, hi))
+}
+
+func (g *GrayScott) releaseCells() {
+	for p := range g.rateBindGroups {
+		for _, bg := range g.rateBindGroups[p] {
+			bg.Release()
+		}
+		g.rateBindGroups[p] = nil
+	}
+	for _, b := range g.cellStateStorage {
+		b.Release()
+	}
+	g.cellStateStorage = nil
+}
+
+func (g *GrayScott) Release() {
+	g.releaseCells()
+	if g.integration != nil {
+		g.integration.Release()
+		g.integration = nil
+	}
+	if g.params != nil {
+		g.params.Release()
+		g.params = nil
+	}
+	if g.simulationPipeline != nil {
+		g.simulationPipeline.Release()
+		g.simulationPipeline = nil
+	}
+	if g.pipeline != nil {
+		g.pipeline.Release()
+		g.pipeline = nil
+	}
+	if g.pipelineLayout != nil {
+		g.pipelineLayout.Release()
+		g.pipelineLayout = nil
+	}
+	if g.drawPipelineLayout != nil {
+		g.drawPipelineLayout.Release()
+		g.drawPipelineLayout = nil
+	}
+	if g.bindGroupLayout != nil {
+		g.bindGroupLayout.Release()
+		g.bindGroupLayout = nil
+	}
+}
@@ -0,0 +1,54 @@
+struct Params {
+  feed: f32,
+  kill: f32,
+  diffuseU: f32,
+  diffuseV: f32,
+};
+
+struct Step {
+  dt: f32,
+  semiImplicit: u32,
+};
+
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<uniform> params: Params;
+@group(0) @binding(2) var<storage> cellStateIn: array<vec2<f32>>;
+@group(0) @binding(3) var<storage, read_write> rateOut: array<vec2<f32>>;
+@group(0) @binding(4) var<uniform> step: Step;
+
+// The rate of change of U and V; the integrator adds it up into the next
+// state.
+@compute
+@workgroup_size(8, 8)
+fn main(@builtin(global_invocation_id) cell: vec3<u32>) {
+  let size = vec2<i32>(grid);
+  let pos = vec2<i32>(cell.xy);
+  if pos.x >= size.x || pos.y >= size.y {
+    return;
+  }
+
+  let c = concentration(pos, 0, 0);
+  let laplacian = 0.2 * (concentration(pos, 1, 0) + concentration(pos, -1, 0) +
+                         concentration(pos, 0, 1) + concentration(pos, 0, -1)) +
+                  0.05 * (concentration(pos, 1, 1) + concentration(pos, 1, -1) +
+                          concentration(pos, -1, 1) + concentration(pos, -1, -1)) -
+                  c;
+
+  // Split into what is added and what decays in proportion to each
+  // chemical, so that semi-implicit steps can take the decay implicitly.
+  let reaction = c.x * c.y * c.y;
+  let added = vec2(params.diffuseU * laplacian.x - reaction + params.feed,
+                   params.diffuseV * laplacian.y + reaction);
+  let decay = vec2(params.feed, params.kill + params.feed);
+  var rate = added - decay * c;
+  if step.semiImplicit != 0u {
+    rate /= 1.0 + step.dt * decay;
+  }
+  rateOut[pos.y * size.x + pos.x] = rate;
+}
+
+fn concentration(pos: vec2<i32>, dx: i32, dy: i32) -> vec2<f32> {
+  let size = vec2<i32>(grid);
+  let n = (pos + vec2(dx, dy) + size) % size;
+  return cellStateIn[n.y * size.x + n.x];
+}
@@ -0,0 +1,35 @@
+// camera.wgsl goes after this.
+struct VertexInput {
+  @location(0) pos: vec2<f32>,
+  @builtin(instance_index) instance: u32,
+};
+
+struct VertexOutput {
+  @builtin(position) pos: vec4<f32>,
+  @location(0) uv: vec2<f32>,
+};
+
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(2) var<storage> cellStateIn: array<vec2<f32>>;
+
+@vertex
+fn main_vs(input: VertexInput) -> VertexOutput {
+  let i = f32(input.instance);
+  let cell = vec2<f32>(i % grid.x, floor(i / grid.x));
+  let cellOffset = cell / grid * 2.0;
+  let gridPos = (input.pos * 1.25 + 1.0) / grid - 1.0 + cellOffset;
+
+  var output: VertexOutput;
+  output.pos = camera * vec4<f32>(gridPos, 0.0, 1.0);
+  output.uv = cellStateIn[input.instance];
+  return output;
+}
+
+@fragment
+fn main_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  // V is the interesting chemical; U fills in where it has been used up.
+  let v = smoothstep(0.0, 0.4, input.uv.y);
+  let background = vec3<f32>(0.0, 0.01, 0.05);
+  let colour = mix(background, vec3<f32>(0.1, 0.7, 0.9), v);
+  return vec4<f32>(mix(colour, vec3<f32>(1.0), v * (1.0 - input.uv.x)), 1.0);
+}
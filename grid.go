@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+// gridResizer is implemented by simulations that can carry their state over
+// to a new grid size.
+type gridResizer interface {
+	ResizeGrid(s *State, r gridRemap) error
+}
+
+// gridLimiter is implemented by simulations whose grids can go past
+// maxGridSize.
+type gridLimiter interface {
+	gridLimit() int
+}
+
+// gridRemap moves cells from an old grid size to a new one, either keeping
+// them at their size and cropping or padding around the centre, or
+// stretching the old grid over the new one.
+type gridRemap struct {
+	oldWidth, oldHeight int
+	width, height       int
+	resample            bool
+}
+
+// source returns the old cell that new cell (x, y) comes from, and false if
+// it is outside the old grid.
+func (r gridRemap) source(x, y int) (int, int, bool) {
+	if r.resample {
+		return x * r.oldWidth / r.width, y * r.oldHeight / r.height, true
+	}
+	x -= (r.width - r.oldWidth) / 2
+	y -= (r.height - r.oldHeight) / 2
+	return x, y, x >= 0 && y >= 0 && x < r.oldWidth && y < r.oldHeight
+}
+
+// point moves a position on the old grid to the new one.
+func (r gridRemap) point(x, y int) (int, int) {
+	if r.resample {
+		return x * r.width / r.oldWidth, y * r.height / r.oldHeight
+	}
+	x += (r.width - r.oldWidth) / 2
+	y += (r.height - r.oldHeight) / 2
+	return (x%r.width + r.width) % r.width, (y%r.height + r.height) % r.height
+}
+
+// cells remaps a row-major cell buffer with stride bytes per cell. New cells
+// outside the old grid are set to empty, or zero if it is nil.
+func (r gridRemap) cells(data []byte, stride int, empty []byte) []byte {
+	out := make([]byte, r.width*r.height*stride)
+	for y := 0; y < r.height; y++ {
+		for x := 0; x < r.width; x++ {
+			dst := out[(y*r.width+x)*stride:][:stride]
+			if sx, sy, ok := r.source(x, y); ok {
+				copy(dst, data[(sy*r.oldWidth+sx)*stride:])
+			} else if empty != nil {
+				copy(dst, empty)
+			}
+		}
+	}
+	return out
+}
+
+// resizeGrid changes the grid size of a running simulation.
+func (s *State) resizeGrid(width, height int, resample bool) error {
+	r, ok := s.sim.(gridResizer)
+	if !ok {
+		return fmt.Errorf("this simulation can't change its grid size")
+	}
+	limit := maxGridSize
+	if l, ok := s.sim.(gridLimiter); ok {
+		limit = l.gridLimit()
+	}
+	if width < 1 || height < 1 || width > limit || height > limit {
+		return fmt.Errorf("grid size %dx%d out of range [1, %d]", width, height, limit)
+	}
+	err := r.ResizeGrid(s, gridRemap{
+		oldWidth:  s.gridWidth,
+		oldHeight: s.gridHeight,
+		width:     width,
+		height:    height,
+		resample:  resample,
+	})
+	if err != nil {
+		return err
+	}
+	return s.setGridSize(width, height)
+}
+
+// setGridSize updates the grid uniform the simulations share.
+func (s *State) setGridSize(width, height int) error {
+	s.gridWidth, s.gridHeight = width, height
+	s.grid = []float32{float32(width), float32(height)}
+	if s.camera != nil {
+		s.camera.fit(width, height)
+	}
+	return s.queue.WriteBuffer(s.gridBuffer, 0, wgpu.ToBytes(s.grid))
+}
+
+// handleGridKey doubles the grid with ] and halves it with [, cropping or
+// padding around the centre, or stretching the cells when shift is held.
+func (s *State) handleGridKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if action != glfw.Press {
+		return
+	}
+	var width, height int
+	switch key {
+	case glfw.KeyRightBracket:
+		width, height = s.gridWidth*2, s.gridHeight*2
+	case glfw.KeyLeftBracket:
+		width, height = max(s.gridWidth/2, 1), max(s.gridHeight/2, 1)
+	default:
+		return
+	}
+	if err := s.resizeGrid(width, height, mods&glfw.ModShift != 0); err != nil {
+		fmt.Println("resizing grid:", err)
+		return
+	}
+	fmt.Printf("grid %dx%d\n", width, height)
+}
+
+// readBuffer copies the contents of a buffer back from the GPU. The buffer
+// needs CopySrc usage.
+func (s *State) readBuffer(buf *wgpu.Buffer) ([]byte, error) {
+	return s.readBufferRange(buf, 0, buf.GetSize())
+}
+
+// readBufferRange copies size bytes of a buffer from offset back from the
+// GPU, both multiples of 4.
+func (s *State) readBufferRange(buf *wgpu.Buffer, offset, size uint64) ([]byte, error) {
+	readback, err := s.device.CreateBuffer(&wgpu.BufferDescriptor{
+		Label: "readback",
+		Size:  size,
+		Usage: wgpu.BufferUsage_MapRead | wgpu.BufferUsage_CopyDst,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer readback.Release()
+
+	encoder, err := s.device.CreateCommandEncoder(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer encoder.Release()
+	if err := encoder.CopyBufferToBuffer(buf, offset, readback, 0, size); err != nil {
+		return nil, err
+	}
+	cmdBuffer, err := encoder.Finish(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer cmdBuffer.Release()
+	s.queue.Submit(cmdBuffer)
+
+	return s.mapRead(readback)
+}
+
+// mapRead waits for the GPU and returns a copy of a MapRead buffer.
+func (s *State) mapRead(buf *wgpu.Buffer) ([]byte, error) {
+	var status wgpu.BufferMapAsyncStatus
+	err := buf.MapAsync(wgpu.MapMode_Read, 0, buf.GetSize(), func(st wgpu.BufferMapAsyncStatus) {
+		status = st
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.device.Poll(true, nil)
+	if status != wgpu.BufferMapAsyncStatus_Success {
+		return nil, fmt.Errorf("mapping buffer for reading: %s", status)
+	}
+	defer buf.Unmap()
+
+	return append([]byte(nil), buf.GetMappedRange(0, uint(buf.GetSize()))...), nil
+}
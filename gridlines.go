@@ -0,0 +1,111 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed gridlines.wgsl
+var gridLinesShader string
+
+// squareCellGrid is implemented by simulations that draw square cells
+// filling the view, the grid s.gridBuffer describes, for grid lines to be
+// drawn between.
+type squareCellGrid interface {
+	squareCells() bool
+}
+
+// gridLines draws thin lines between the cells over the simulation, for
+// when they are big enough to want to see where one ends. L turns them on
+// and off.
+type gridLines struct {
+	layout         *wgpu.BindGroupLayout
+	pipelineLayout *wgpu.PipelineLayout
+	pipeline       *wgpu.RenderPipeline
+	bindGroup      *wgpu.BindGroup
+	shown          bool
+}
+
+func newGridLines(s *State, shown bool) (g *gridLines, err error) {
+	g = &gridLines{shown: shown}
+	defer func() {
+		if err != nil {
+			g.Release()
+		}
+	}()
+
+	shader := s.createShader("grid lines shader", gridLinesShader+paletteShader+cameraShader)
+	defer shader.Release()
+
+	g.layout, err = s.bindGroupLayout("grid lines",
+		bufferEntry(0, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+	)
+	if err != nil {
+		return nil, err
+	}
+	g.pipelineLayout, err = s.pipelineLayout("grid lines", g.layout, s.cameraLayout)
+	if err != nil {
+		return nil, err
+	}
+	g.pipeline, err = s.blendedRenderPipeline("grid lines", g.pipelineLayout, shader, "main_vs", "main_fs", &wgpu.BlendState{
+		Color: wgpu.BlendComponent{Operation: wgpu.BlendOperation_Add, SrcFactor: wgpu.BlendFactor_SrcAlpha, DstFactor: wgpu.BlendFactor_OneMinusSrcAlpha},
+		Alpha: wgpu.BlendComponent{Operation: wgpu.BlendOperation_Add, SrcFactor: wgpu.BlendFactor_Zero, DstFactor: wgpu.BlendFactor_One},
+	})
+	if err != nil {
+		return nil, err
+	}
+	g.bindGroup = s.bindGroup("grid lines", g.layout, s.gridBuffer, s.paletteBuffer)
+	return g, nil
+}
+
+// drawGridLines draws the grid lines over the simulation if they are on
+// and it has square cells.
+func (s *State) drawGridLines(pass *renderPass) {
+	if s.gridLines == nil || !s.gridLines.shown {
+		return
+	}
+	if g, ok := s.sim.(squareCellGrid); !ok || !g.squareCells() {
+		return
+	}
+	pass.SetPipeline(s.gridLines.pipeline)
+	pass.SetBindGroup(0, s.gridLines.bindGroup, nil)
+	pass.SetBindGroup(1, s.camera.bindGroup, nil)
+	pass.SetVertexBuffer(0, s.vertexBuffer, 0, wgpu.WholeSize)
+	pass.Draw(6, 1, 0, 0)
+}
+
+// handleGridLinesKey turns the grid lines on and off with L.
+func (s *State) handleGridLinesKey(key glfw.Key, action glfw.Action) {
+	if key != glfw.KeyL || action != glfw.Press || s.gridLines == nil {
+		return
+	}
+	if g, ok := s.sim.(squareCellGrid); !ok || !g.squareCells() {
+		fmt.Println("grid lines need a single simulation of square cells")
+		return
+	}
+	s.gridLines.shown = !s.gridLines.shown
+	fmt.Printf("grid lines %v\n", s.gridLines.shown)
+}
+
+func (g *gridLines) Release() {
+	if g.bindGroup != nil {
+		g.bindGroup.Release()
+		g.bindGroup = nil
+	}
+	if g.pipeline != nil {
+		g.pipeline.Release()
+		g.pipeline = nil
+	}
+	if g.pipelineLayout != nil {
+		g.pipelineLayout.Release()
+		g.pipelineLayout = nil
+	}
+	if g.layout != nil {
+		g.layout.Release()
+		g.layout = nil
+	}
+}
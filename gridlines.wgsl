@@ -0,0 +1,36 @@
+// palette.wgsl and camera.wgsl go after this.
+struct VertexOutput {
+  @builtin(position) pos: vec4<f32>,
+  // cell is where on the grid the pixel is, in cells.
+  @location(0) cell: vec2<f32>,
+};
+
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<uniform> palette: Palette;
+
+// The tile quad is 0.8 across, so scaling it up covers the whole grid.
+@vertex
+fn main_vs(@location(0) pos: vec2<f32>) -> VertexOutput {
+  let view = pos / 0.8;
+  var output: VertexOutput;
+  output.pos = camera * vec4<f32>(view, 0.0, 1.0);
+  output.cell = (view * 0.5 + 0.5) * grid;
+  return output;
+}
+
+// Lines are a pixel wide between cells, light on dark backgrounds and dark
+// on light ones, and fade out as cells get too small to see them between.
+@fragment
+fn main_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  let pixel = fwidth(input.cell);
+  let f = fract(input.cell);
+  let distance = min(f, 1.0 - f) / pixel;
+  let line = 1.0 - clamp(min(distance.x, distance.y), 0.0, 1.0);
+  let cellPixels = 1.0 / max(pixel.x, pixel.y);
+  let strength = line * smoothstep(4.0, 12.0, cellPixels);
+
+  let background = palette.background.rgb;
+  let light = dot(background, vec3(0.2126, 0.7152, 0.0722)) > 0.5;
+  let colour = select(vec3(1.0), vec3(0.0), light);
+  return vec4<f32>(colour, 0.3 * strength);
+}
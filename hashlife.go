@@ -0,0 +1,329 @@
+package main
+
+import "math/bits"
+
+// node is a square of 2^level cells in a HashLife universe. Nodes are
+// interned, so two squares with the same contents are the same node and
+// whatever has been worked out about one holds for the other.
+type node struct {
+	nw, ne, sw, se *node
+	level          int
+	population     int64
+}
+
+type quad [4]*node
+
+type resultKey struct {
+	n *node
+	j int
+}
+
+// universe is Conway's Life on an unbounded plane using Gosper's HashLife,
+// which remembers how every square it has seen evolves and so can jump
+// through generations far faster than stepping them one at a time, for
+// patterns with any regularity to them. The root is centred on the origin,
+// with x to the right and y down the rows.
+type universe struct {
+	on, off *node
+	nodes   map[quad]*node
+	empty   []*node
+	results map[resultKey]*node
+	root    *node
+}
+
+// maxNodes is how many nodes a universe keeps before throwing away the ones
+// the current pattern doesn't use, along with every remembered result.
+const maxNodes = 1 << 20
+
+func newUniverse() *universe {
+	u := &universe{
+		on:  &node{population: 1},
+		off: &node{},
+	}
+	u.reset()
+	return u
+}
+
+func (u *universe) reset() {
+	u.nodes = map[quad]*node{}
+	u.results = map[resultKey]*node{}
+	u.empty = []*node{u.off}
+	u.root = u.emptyNode(blockLevel + 1)
+}
+
+func (u *universe) join(nw, ne, sw, se *node) *node {
+	q := quad{nw, ne, sw, se}
+	if n, ok := u.nodes[q]; ok {
+		return n
+	}
+	n := &node{
+		nw: nw, ne: ne, sw: sw, se: se,
+		level:      nw.level + 1,
+		population: nw.population + ne.population + sw.population + se.population,
+	}
+	u.nodes[q] = n
+	return n
+}
+
+func (u *universe) emptyNode(level int) *node {
+	for len(u.empty) <= level {
+		e := u.empty[len(u.empty)-1]
+		u.empty = append(u.empty, u.join(e, e, e, e))
+	}
+	return u.empty[level]
+}
+
+// expand returns a node twice the size of n with n in the middle.
+func (u *universe) expand(n *node) *node {
+	e := u.emptyNode(n.level - 1)
+	return u.join(
+		u.join(e, e, e, n.nw),
+		u.join(e, e, n.ne, e),
+		u.join(e, n.sw, e, e),
+		u.join(n.se, e, e, e),
+	)
+}
+
+// centre returns the middle half of n.
+func (u *universe) centre(n *node) *node {
+	return u.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw)
+}
+
+// successor returns the middle half of n, level 2 or more, 2^j generations
+// on. j is at most level-2, as far as anything outside n can't reach.
+func (u *universe) successor(n *node, j int) *node {
+	if n.population == 0 {
+		return n.nw
+	}
+	j = min(j, n.level-2)
+	key := resultKey{n, j}
+	if r, ok := u.results[key]; ok {
+		return r
+	}
+
+	var r *node
+	if n.level == 2 {
+		r = u.life4x4(n)
+	} else {
+		// The nine overlapping squares of half the size, stepped on.
+		c := [9]*node{
+			u.successor(n.nw, j),
+			u.successor(u.join(n.nw.ne, n.ne.nw, n.nw.se, n.ne.sw), j),
+			u.successor(n.ne, j),
+			u.successor(u.join(n.nw.sw, n.nw.se, n.sw.nw, n.sw.ne), j),
+			u.successor(u.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw), j),
+			u.successor(u.join(n.ne.sw, n.ne.se, n.se.nw, n.se.ne), j),
+			u.successor(n.sw, j),
+			u.successor(u.join(n.sw.ne, n.se.nw, n.sw.se, n.se.sw), j),
+			u.successor(n.se, j),
+		}
+		if j < n.level-2 {
+			// They have already gone as far as they need to, so take
+			// their middles.
+			r = u.join(
+				u.join(c[0].se, c[1].sw, c[3].ne, c[4].nw),
+				u.join(c[1].se, c[2].sw, c[4].ne, c[5].nw),
+				u.join(c[3].se, c[4].sw, c[6].ne, c[7].nw),
+				u.join(c[4].se, c[5].sw, c[7].ne, c[8].nw),
+			)
+		} else {
+			// Halfway there: step each group of four on again.
+			r = u.join(
+				u.successor(u.join(c[0], c[1], c[3], c[4]), j),
+				u.successor(u.join(c[1], c[2], c[4], c[5]), j),
+				u.successor(u.join(c[3], c[4], c[6], c[7]), j),
+				u.successor(u.join(c[4], c[5], c[7], c[8]), j),
+			)
+		}
+	}
+	u.results[key] = r
+	return r
+}
+
+// life4x4 steps the middle 2x2 cells of a 4x4 node one generation.
+func (u *universe) life4x4(n *node) *node {
+	var cells uint16
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if u.alive(n, x, y) {
+				cells |= 1 << (y*4 + x)
+			}
+		}
+	}
+	next := func(x, y int) *node {
+		count := 0
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if dx != 0 || dy != 0 {
+					count += int(cells>>((y+dy)*4+x+dx)) & 1
+				}
+			}
+		}
+		if count == 3 || count == 2 && cells>>(y*4+x)&1 == 1 {
+			return u.on
+		}
+		return u.off
+	}
+	return u.join(next(1, 1), next(2, 1), next(1, 2), next(2, 2))
+}
+
+// alive reports whether the cell at (x, y) from the top left of n is alive.
+func (u *universe) alive(n *node, x, y int) bool {
+	for n.level > 0 {
+		half := 1 << (n.level - 1)
+		switch {
+		case x < half && y < half:
+			n = n.nw
+		case y < half:
+			n, x = n.ne, x-half
+		case x < half:
+			n, y = n.sw, y-half
+		default:
+			n, x, y = n.se, x-half, y-half
+		}
+	}
+	return n == u.on
+}
+
+// step moves the universe on n generations, a power of two at a time.
+func (u *universe) step(n uint64) {
+	for j := 63 - bits.LeadingZeros64(n); j >= 0; j-- {
+		if n&(1<<j) == 0 {
+			continue
+		}
+		// The pattern has to be in the middle quarter of the root, so
+		// that it can't grow out of the middle half that successor
+		// returns.
+		for u.root.level < j+3 || u.centre(u.centre(u.root)).population != u.root.population {
+			u.root = u.expand(u.root)
+		}
+		u.root = u.successor(u.root, j)
+		if len(u.nodes) > maxNodes {
+			u.collect()
+		}
+	}
+}
+
+// collect throws away every node and result, then interns the root again.
+func (u *universe) collect() {
+	root := u.root
+	u.reset()
+	copied := map[*node]*node{u.on: u.on, u.off: u.off}
+	var intern func(n *node) *node
+	intern = func(n *node) *node {
+		if c, ok := copied[n]; ok {
+			return c
+		}
+		c := u.join(intern(n.nw), intern(n.ne), intern(n.sw), intern(n.se))
+		copied[n] = c
+		return c
+	}
+	u.root = intern(root)
+}
+
+// blockLevel is the level of the 64x64 blocks that cells go in and out of a
+// universe in, the same size as the chunks of SparseLife.
+const blockLevel = 6
+
+// block is a 64x64 square of cells, bit x of row y.
+type block [64]uint64
+
+func (u *universe) blockNode(b *block, level, x, y int) *node {
+	if level == 0 {
+		if b[y]>>x&1 == 1 {
+			return u.on
+		}
+		return u.off
+	}
+	half := 1 << (level - 1)
+	return u.join(
+		u.blockNode(b, level-1, x, y),
+		u.blockNode(b, level-1, x+half, y),
+		u.blockNode(b, level-1, x, y+half),
+		u.blockNode(b, level-1, x+half, y+half),
+	)
+}
+
+// setBlocks replaces the universe with blocks, keyed by their position in
+// blocks from the origin.
+func (u *universe) setBlocks(blocks map[chunkPos]*block) {
+	u.reset()
+	for pos, b := range blocks {
+		if b == nil {
+			continue
+		}
+		x, y := int64(pos.x)*64, int64(pos.y)*64
+		for u.outside(x, y) || u.outside(x+63, y+63) {
+			u.root = u.expand(u.root)
+		}
+		half := int64(1) << (u.root.level - 1)
+		u.root = u.place(u.root, x+half, y+half, u.blockNode(b, blockLevel, 0, 0))
+	}
+}
+
+func (u *universe) outside(x, y int64) bool {
+	half := int64(1) << (u.root.level - 1)
+	return x < -half || y < -half || x >= half || y >= half
+}
+
+// place returns n with the block at (x, y) from its top left replaced by b.
+func (u *universe) place(n *node, x, y int64, b *node) *node {
+	if n.level == b.level {
+		return b
+	}
+	half := int64(1) << (n.level - 1)
+	switch {
+	case x < half && y < half:
+		return u.join(u.place(n.nw, x, y, b), n.ne, n.sw, n.se)
+	case y < half:
+		return u.join(n.nw, u.place(n.ne, x-half, y, b), n.sw, n.se)
+	case x < half:
+		return u.join(n.nw, n.ne, u.place(n.sw, x, y-half, b), n.se)
+	default:
+		return u.join(n.nw, n.ne, n.sw, u.place(n.se, x-half, y-half, b))
+	}
+}
+
+// blocks returns every block of the universe with live cells in it.
+func (u *universe) blocks() map[chunkPos]*block {
+	blocks := map[chunkPos]*block{}
+	half := int64(1) << (u.root.level - 1)
+	u.eachLive(u.root, -half, -half, func(x, y int64) {
+		pos := chunkPos{int32(x >> blockLevel), int32(y >> blockLevel)}
+		b := blocks[pos]
+		if b == nil {
+			b = &block{}
+			blocks[pos] = b
+		}
+		b[y&63] |= 1 << (x & 63)
+	})
+	return blocks
+}
+
+// bounds returns the first and last columns and rows with live cells in,
+// from the origin, and whether there are any.
+func (u *universe) bounds() (x0, y0, x1, y1 int64, ok bool) {
+	half := int64(1) << (u.root.level - 1)
+	x0, y0, x1, y1 = half, half, -half, -half
+	u.eachLive(u.root, -half, -half, func(x, y int64) {
+		x0, y0, x1, y1 = min(x0, x), min(y0, y), max(x1, x), max(y1, y)
+	})
+	return x0, y0, x1, y1, x0 <= x1
+}
+
+// eachLive calls fn with the position of every live cell in n, whose top left
+// is at (x, y).
+func (u *universe) eachLive(n *node, x, y int64, fn func(x, y int64)) {
+	if n.population == 0 {
+		return
+	}
+	if n.level == 0 {
+		fn(x, y)
+		return
+	}
+	half := int64(1) << (n.level - 1)
+	u.eachLive(n.nw, x, y, fn)
+	u.eachLive(n.ne, x+half, y, fn)
+	u.eachLive(n.sw, x, y+half, fn)
+	u.eachLive(n.se, x+half, y+half, fn)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// lifeStep is one generation of Conway's life on a width by height grid
+// with dead edges, done directly.
+func lifeStep(cells []uint32, width, height int) []uint32 {
+	next := make([]uint32, len(cells))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			n := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if (dx != 0 || dy != 0) && nx >= 0 && ny >= 0 && nx < width && ny < height {
+						n += int(cells[ny*width+nx])
+					}
+				}
+			}
+			if n == 3 || n == 2 && cells[y*width+x] == 1 {
+				next[y*width+x] = 1
+			}
+		}
+	}
+	return next
+}
+
+// placed is a width by height grid with the named pattern's top left at
+// (x, y).
+func placed(t *testing.T, rle string, width, height, x, y int) []uint32 {
+	t.Helper()
+	p, err := parseRLE("test", rle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cells := make([]uint32, width*height)
+	for py := 0; py < p.height; py++ {
+		for px := 0; px < p.width; px++ {
+			// Patterns go from the bottom row up.
+			cells[(y+p.height-1-py)*width+x+px] = p.at(px, py)
+		}
+	}
+	return cells
+}
+
+func TestUniverseStep(t *testing.T) {
+	const width, height = 256, 256
+	patterns := []struct{ name, rle string }{
+		{"blinker", "3o!"},
+		{"glider", "bob$2bo$3o!"},
+		{"r-pentomino", "b2o$2o$bo!"},
+	}
+	for _, tt := range patterns {
+		start := placed(t, tt.rle, width, height, width/2, height/2)
+		want := start
+		done := uint64(0)
+		for _, n := range []uint64{1, 2, 3, 4, 7, 8, 13, 32, 64, 100} {
+			for ; done < n; done++ {
+				want = lifeStep(want, width, height)
+			}
+			u := newUniverse()
+			u.setBlocks(gridBlocks(start, width, height))
+			u.step(n)
+			got, dropped := blockGrid(u.blocks(), width, height)
+			if dropped != 0 || !slices.Equal(got, want) {
+				t.Errorf("%s after %d generations: HashLife differs from stepping directly (%d dropped)", tt.name, n, dropped)
+			}
+		}
+	}
+}
+
+func TestGridBlocksRoundTrip(t *testing.T) {
+	rand := rand.New(rand.NewSource(1))
+	for _, size := range [][2]int{{1, 1}, {3, 5}, {63, 65}, {64, 64}, {130, 70}, {7, 200}} {
+		width, height := size[0], size[1]
+		cells := make([]uint32, width*height)
+		for i := range cells {
+			cells[i] = uint32(rand.Intn(2))
+		}
+		got, dropped := blockGrid(gridBlocks(cells, width, height), width, height)
+		if dropped != 0 || !slices.Equal(got, cells) {
+			t.Errorf("%dx%d: cells changed going through blocks (%d dropped)", width, height, dropped)
+		}
+	}
+	// A grid half the size keeps the middle and drops the rest.
+	cells := make([]uint32, 8*8)
+	cells[0], cells[4*8+4] = 1, 1
+	got, dropped := blockGrid(gridBlocks(cells, 8, 8), 4, 4)
+	if dropped != 1 || slices.Index(got, 1) != 2*4+2 {
+		t.Errorf("shrinking 8x8 to 4x4: got %v with %d dropped, want the middle cell and 1 dropped", got, dropped)
+	}
+}
+
+func TestStepWithin(t *testing.T) {
+	const width, height = 40, 30
+	for _, tt := range []struct {
+		name, rle   string
+		x, y        int
+		generations uint64
+		fails       bool
+	}{
+		{"glider clear of the edges", "bob$2bo$3o!", 5, 5, 40, false},
+		{"blinker near an edge", "3o!", 1, 2, 1000, false},
+		{"glider into an edge", "bob$2bo$3o!", 5, 5, 200, true},
+		{"blinker on an edge", "3o!", 0, 10, 2, true},
+	} {
+		start := placed(t, tt.rle, width, height, tt.x, tt.y)
+		u := newUniverse()
+		u.setBlocks(gridBlocks(start, width, height))
+		err := stepWithin(u, width, height, tt.generations)
+		if tt.fails {
+			if err == nil {
+				t.Errorf("%s: stepped, want an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		want := start
+		for i := uint64(0); i < tt.generations; i++ {
+			want = lifeStep(want, width, height)
+		}
+		if got, _ := blockGrid(u.blocks(), width, height); !slices.Equal(got, want) {
+			t.Errorf("%s: HashLife differs from stepping with dead edges", tt.name)
+		}
+	}
+}
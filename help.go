@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+var helpKey = [4]float32{0.55, 0.75, 1, 1}
+
+// help is the overlay F1 shows and hides over everything: the settings the
+// simulation is running with, then every command with the key it is on now,
+// which the keys section of the config file may have moved. It takes as
+// many columns as it needs to fit the window, and is kept up to date at the
+// HUD's pace while it is shown.
+type help struct {
+	shown   bool
+	text    *textRenderer
+	updated time.Time
+}
+
+func newHelp(s *State) (*help, error) {
+	text, err := newTextRenderer(s, s.surfaceFormat)
+	if err != nil {
+		return nil, err
+	}
+	return &help{text: text}, nil
+}
+
+// helpLine is a key and what it does, or a setting and its value. Headings
+// have no key.
+type helpLine struct {
+	key, text string
+}
+
+// settings is what the help says the simulation is running with.
+func (s *State) settings() []helpLine {
+	cfg := s.cfg
+	lines := []helpLine{
+		{"simulation", simulations[cfg.Simulation].Name},
+		{"rule", ruleName(cfg)},
+		{"grid", fmt.Sprintf("%dx%d", s.gridWidth, s.gridHeight)},
+	}
+	if l, ok := s.sim.(*Life); ok {
+		lines = append(lines, helpLine{"topology", l.topology}, helpLine{"boundary", boundaryModes[l.boundary]})
+	}
+	speed := "as fast as it can"
+	if s.tickRate > 0 {
+		speed = s.format.Float(s.tickRate, 0) + " generations a second"
+	}
+	if s.paused {
+		speed = "paused"
+	}
+	lines = append(lines,
+		helpLine{"speed", speed},
+		helpLine{"palette", cfg.Palette},
+		helpLine{"seed", fmt.Sprint(s.seed)},
+	)
+	switch {
+	case len(cfg.Panes) > 0:
+		lines = append(lines, helpLine{"panes", fmt.Sprint(len(cfg.Panes))})
+	case cfg.Instances > 1:
+		lines = append(lines, helpLine{"instances", fmt.Sprint(cfg.Instances)})
+	}
+	return lines
+}
+
+// lines is everything the help says.
+func (h *help) lines(s *State) []helpLine {
+	lines := []helpLine{{"", "Settings"}}
+	lines = append(lines, s.settings()...)
+	lines = append(lines, helpLine{}, helpLine{"", "Keys (F1 hides this)"})
+	for _, c := range commands {
+		lines = append(lines, helpLine{s.bindings.shortcut(c), c.name})
+	}
+	return lines
+}
+
+// update lays the help out again once hudInterval has passed since it last
+// was, while it is shown.
+func (h *help) update(s *State) {
+	if h == nil || !h.shown || time.Since(h.updated) < hudInterval {
+		return
+	}
+	lines := h.lines(s)
+	// The text is the HUD's size if it fits the window across, and half
+	// that if not.
+	for _, pixel := range []float32{s.scaled(2), s.scaled(1)} {
+		h.text.setScale(pixel)
+		if h.lay(s, lines) {
+			break
+		}
+	}
+	if err := h.text.upload(); err != nil {
+		fmt.Println("setting the help's text:", err)
+	}
+	h.updated = time.Now()
+}
+
+// lay sets out lines in columns from the top left, over a panel across the
+// window, returning whether they fitted it.
+func (h *help) lay(s *State, lines []helpLine) bool {
+	t := h.text
+	t.clear()
+	width, height := float32(s.config.Width), float32(s.config.Height)
+	t.addRect(0, 0, width, height, uiPanel)
+	margin := s.scaled(hudMargin)
+	rows := max(1, int((height-2*margin)/t.lineHeight()))
+	gap := t.textWidth("  ")
+	x := margin
+	for first := 0; first < len(lines); first += rows {
+		column := lines[first:min(first+rows, len(lines))]
+		var keys, texts float32
+		for _, l := range column {
+			keys, texts = max(keys, t.textWidth(l.key)), max(texts, t.textWidth(l.text))
+		}
+		for i, l := range column {
+			y := margin + float32(i)*t.lineHeight()
+			if l.key == "" {
+				t.addShadowedText(x, y, textWhite, l.text)
+				continue
+			}
+			t.addShadowedText(x, y, helpKey, l.key)
+			t.addShadowedText(x+keys+gap, y, textWhite, l.text)
+		}
+		x += keys + gap + texts + 2*gap
+	}
+	return x-2*gap+margin <= width
+}
+
+// draw records the help over view, if it is shown.
+func (h *help) draw(s *State, encoder *commandEncoder, view *wgpu.TextureView) {
+	if h == nil || !h.shown {
+		return
+	}
+	h.text.draw(encoder, view, s.config.Width, s.config.Height)
+}
+
+// handleHelpKey shows and hides the help with F1.
+func (s *State) handleHelpKey(key glfw.Key, action glfw.Action) {
+	if key != glfw.KeyF1 || action != glfw.Press || s.help == nil {
+		return
+	}
+	s.help.shown = !s.help.shown
+	s.help.updated = time.Time{}
+}
+
+func (h *help) Release() {
+	if h.text != nil {
+		h.text.Release()
+		h.text = nil
+	}
+}
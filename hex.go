@@ -0,0 +1,32 @@
+package main
+
+import (
+	_ "embed"
+	"math"
+)
+
+//go:embed hex_draw.wgsl
+var hexDraw string
+
+//go:embed hex_compute.wgsl
+var hexCompute string
+
+// hexVertices is a pointy-top hexagon one unit wide, shrunk a little like the
+// square tile so neighbouring cells stay distinguishable, as a fan of six
+// counter-clockwise triangles.
+func hexVertices() []float32 {
+	const shrink = 0.9
+	radius := shrink / math.Sqrt(3)
+
+	vertices := make([]float32, 0, 6*3*2)
+	corner := func(k int) (float32, float32) {
+		angle := math.Pi/6 + float64(k)*math.Pi/3
+		return float32(radius * math.Cos(angle)), float32(radius * math.Sin(angle))
+	}
+	for k := 0; k < 6; k++ {
+		x0, y0 := corner(k)
+		x1, y1 := corner(k + 1)
+		vertices = append(vertices, 0, 0, x0, y0, x1, y1)
+	}
+	return vertices
+}
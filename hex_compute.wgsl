@@ -0,0 +1,68 @@
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<storage> cellStateIn: array<u32>;
+@group(0) @binding(2) var<storage, read_write> cellStateOut: array<u32>;
+// 0 wraps around (torus), 1 treats cells beyond the edges as dead and 2
+// mirrors the edge cells.
+@group(0) @binding(3) var<uniform> boundary: u32;
+// ageIn is how many generations each cell has been alive, 0 for dead cells.
+@group(0) @binding(4) var<storage> ageIn: array<u32>;
+@group(0) @binding(5) var<storage, read_write> ageOut: array<u32>;
+
+// Cells are stored row by row with odd rows shifted half a cell right. The
+// neighbourhood is easiest in axial coordinates (q, r), where the six
+// neighbours are fixed offsets and q = column - floor(row / 2).
+@compute
+@workgroup_size(8, 8)
+fn main(@builtin(global_invocation_id) cell: vec3<u32>) {
+  let size = vec2<i32>(grid);
+  let offset = vec2<i32>(cell.xy);
+  if offset.x >= size.x || offset.y >= size.y {
+    return;
+  }
+
+  let axial = vec2<i32>(offset.x - (offset.y >> 1u), offset.y);
+  let activeNeighbors = cellActive(axial + vec2(1, 0)) +
+                        cellActive(axial + vec2(-1, 0)) +
+                        cellActive(axial + vec2(0, 1)) +
+                        cellActive(axial + vec2(0, -1)) +
+                        cellActive(axial + vec2(1, -1)) +
+                        cellActive(axial + vec2(-1, 1));
+
+  let i = u32(offset.y * size.x + offset.x);
+
+  // B2/S34, the usual hexagonal counterpart to Conway's rules:
+  switch activeNeighbors {
+    case 2u: {
+      cellStateOut[i] = u32(1);
+    }
+    case 3u, 4u: {
+      cellStateOut[i] = cellStateIn[i];
+    }
+    default: {
+      cellStateOut[i] = u32(0);
+    }
+  }
+  ageOut[i] = select(0u, ageIn[i] + 1u, cellStateOut[i] != 0u);
+}
+
+// Converts back to offset coordinates before applying the boundary, so with
+// an even number of rows the torus has no seam.
+fn cellActive(axial: vec2<i32>) -> u32 {
+  let size = vec2<i32>(grid);
+  var cell = vec2<i32>(axial.x + (axial.y >> 1u), axial.y);
+  switch boundary {
+    case 1u: {
+      if any(cell < vec2(0)) || any(cell >= size) {
+        return 0u;
+      }
+    }
+    case 2u: {
+      cell = select(cell, -cell - 1, cell < vec2(0)) -
+             select(vec2(0), 2 * (cell - size) + 1, cell >= size);
+    }
+    default: {
+      cell = (cell % size + size) % size;
+    }
+  }
+  return cellStateIn[u32(cell.y * size.x + cell.x)];
+}
@@ -0,0 +1,65 @@
+// palette.wgsl and camera.wgsl go after this.
+struct VertexInput {
+  @location(0) pos: vec2<f32>,
+  @builtin(instance_index) instance: u32,
+};
+
+struct VertexOutput {
+  @builtin(position) pos: vec4<f32>,
+  @location(0) cell: vec2<f32>,
+  @location(1) @interpolate(flat) age: u32,
+  @location(2) @interpolate(flat) trail: f32,
+  @location(3) @interpolate(flat) alive: u32,
+};
+
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<storage> cellStateIn: array<u32>;
+@group(0) @binding(2) var<storage, read_write> cellStateOut: array<u32>;
+// life.z is 1 to colour cells by age, and life.w 1 to draw trails.
+@group(0) @binding(3) var<uniform> life: vec4<u32>;
+@group(0) @binding(4) var<storage> ageIn: array<u32>;
+@group(0) @binding(6) var<uniform> palette: Palette;
+@group(0) @binding(7) var<storage> trail: array<f32>;
+@group(0) @binding(8) var gradient: texture_1d<f32>;
+
+// input.pos is a hexagon one unit wide, and rows are sqrt(3)/2 units apart.
+@vertex
+fn main_vs(input: VertexInput) -> VertexOutput {
+  let width = u32(grid.x);
+  let cell = vec2<u32>(input.instance % width, input.instance / width);
+  let alive = cellStateIn[input.instance];
+  var fading = 0.0;
+  if life.w == 1u && alive == 0u {
+    fading = trail[input.instance];
+  }
+  let state = select(0.0, 1.0, alive > 0u || fading > 1.0 / 256.0);
+
+  // Odd rows stick out half a cell, so make room for them.
+  let spacing = vec2<f32>(2.0 / (grid.x + 0.5), 2.0 / grid.y);
+  let centre = vec2<f32>(f32(cell.x) + 0.5 + 0.5 * f32(cell.y & 1u), f32(cell.y) + 0.5) * spacing - 1.0;
+  let scale = spacing * vec2<f32>(1.0, 1.0 / 0.8660254);
+
+  var output: VertexOutput;
+  output.pos = camera * vec4<f32>(centre + state * input.pos * scale, 0.0, 1.0);
+  output.cell = vec2<f32>(cell);
+  output.age = ageIn[input.instance];
+  output.trail = fading;
+  output.alive = alive;
+  return output;
+}
+
+// Dead cells are drawn the way draw.wgsl draws them, for their trails.
+@fragment
+fn main_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  if input.alive == 0u {
+    let colour = paletteColour(palette, input.cell / grid);
+    return vec4<f32>(mix(palette.background.rgb, colour, 0.6 * input.trail), 1.0);
+  }
+  if life.z == 1u {
+    // The same lookup as draw.wgsl's ageColour.
+    let old = 1.0 - exp(-f32(input.age) / 64.0);
+    let last = i32(textureDimensions(gradient)) - 1;
+    return vec4<f32>(textureLoad(gradient, i32(old * f32(last) + 0.5), 0).rgb, 1.0);
+  }
+  return vec4<f32>(paletteColour(palette, input.cell / grid), 1.0);
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// highlighter watches the population every few steps and, when it jumps
+// well outside its recent range, saves the frames around the jump as a
+// clip, so an unattended run leaves a reel of the moments worth looking at.
+type highlighter struct {
+	cfg HighlightsConfig
+
+	// Exponentially weighted mean and variance of the population.
+	mean, variance float64
+	samples        int
+
+	recent []*image.RGBA
+	clip   *highlight
+	events []*highlight
+}
+
+type highlight struct {
+	Step       int       `json:"step"`
+	Time       time.Time `json:"time"`
+	Population float64   `json:"population"`
+	Mean       float64   `json:"mean"`
+	Dir        string    `json:"dir"`
+
+	frames    int
+	remaining int
+}
+
+// warmup is how many samples to take before anything counts as unusual.
+const warmup = 20
+
+func newHighlighter(s *State, cfg HighlightsConfig) (*highlighter, error) {
+	if _, ok := s.sim.(populationCounter); !ok {
+		return nil, fmt.Errorf("highlights need a simulation that can count its population")
+	}
+	if cfg.Every < 1 || cfg.Threshold <= 0 || cfg.Before < 0 || cfg.After < 0 {
+		return nil, fmt.Errorf("highlights need every >= 1, threshold > 0 and before and after >= 0")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &highlighter{cfg: cfg}, nil
+}
+
+// restart forgets the population so far, for when the simulation has been
+// switched for another.
+func (h *highlighter) restart() {
+	if h == nil {
+		return
+	}
+	h.mean, h.variance, h.samples = 0, 0, 0
+	h.recent = nil
+}
+
+// observe is called after every step. Like the manifest methods it does
+// nothing on a nil highlighter.
+func (h *highlighter) observe(s *State) {
+	if h == nil || s.steps%h.cfg.Every != 0 {
+		return
+	}
+	if err := h.sample(s); err != nil {
+		log.Println("highlights:", err)
+	}
+}
+
+func (h *highlighter) sample(s *State) error {
+	// The simulation can be switched for one that can't count.
+	counter, ok := s.sim.(populationCounter)
+	if !ok {
+		return nil
+	}
+	population, err := counter.Population(s)
+	if err != nil {
+		return err
+	}
+	unusual := h.unusual(population)
+	h.update(population)
+
+	if h.clip != nil {
+		frame, err := s.captureFrame()
+		if err != nil {
+			return err
+		}
+		return h.record(s, frame)
+	}
+	if !unusual && h.cfg.Before == 0 {
+		return nil
+	}
+
+	frame, err := s.captureFrame()
+	if err != nil {
+		return err
+	}
+	h.recent = append(h.recent, frame)
+	if len(h.recent) > h.cfg.Before+1 {
+		h.recent = h.recent[1:]
+	}
+	if !unusual {
+		return nil
+	}
+
+	h.clip = &highlight{
+		Step:       s.steps,
+		Time:       time.Now().UTC(),
+		Population: population,
+		Mean:       h.mean,
+		Dir:        filepath.Join(h.cfg.Dir, fmt.Sprintf("step-%09d", s.steps)),
+		remaining:  h.cfg.After,
+	}
+	if err := os.MkdirAll(h.clip.Dir, 0o755); err != nil {
+		return err
+	}
+	fmt.Printf("highlight at step %s: population %s, usually %s\n", s.format.Count(int64(s.steps)),
+		s.format.Float(population, 0), s.format.Float(h.mean, 0))
+	// The frame that triggered the clip is the last of h.recent.
+	for _, f := range h.recent {
+		if err := h.saveFrame(f); err != nil {
+			return err
+		}
+	}
+	h.recent = nil
+	if h.clip.remaining == 0 {
+		return h.finish(s)
+	}
+	return nil
+}
+
+// unusual reports whether population is more than Threshold standard
+// deviations from the mean. The deviation is floored at 1% of the mean so
+// that a population which has settled down does not trigger on every blip.
+func (h *highlighter) unusual(population float64) bool {
+	if h.samples < warmup {
+		return false
+	}
+	deviation := max(math.Sqrt(h.variance), 0.01*math.Abs(h.mean), 1)
+	return math.Abs(population-h.mean) > h.cfg.Threshold*deviation
+}
+
+func (h *highlighter) update(population float64) {
+	const alpha = 0.1
+	h.samples++
+	if h.samples == 1 {
+		h.mean = population
+		return
+	}
+	d := population - h.mean
+	h.mean += alpha * d
+	h.variance = (1 - alpha) * (h.variance + alpha*d*d)
+}
+
+func (h *highlighter) record(s *State, frame *image.RGBA) error {
+	if err := h.saveFrame(frame); err != nil {
+		return err
+	}
+	h.clip.remaining--
+	if h.clip.remaining > 0 {
+		return nil
+	}
+	return h.finish(s)
+}
+
+func (h *highlighter) saveFrame(frame *image.RGBA) error {
+	h.clip.frames++
+	return savePNG(filepath.Join(h.clip.Dir, fmt.Sprintf("frame-%03d.png", h.clip.frames)), frame)
+}
+
+// finish adds the clip to highlights.json, which is rewritten each time so
+// it is complete even if the run is killed.
+func (h *highlighter) finish(s *State) error {
+	h.events = append(h.events, h.clip)
+	if err := s.manifest.AddArtifact("highlight", h.clip.Dir); err != nil {
+		log.Println("writing run manifest:", err)
+	}
+	h.clip = nil
+
+	b, err := json.MarshalIndent(h.events, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(h.cfg.Dir, "highlights.json"), b, 0o644)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+var hoverEdge = [4]float32{1, 1, 1, 0.8}
+
+// hoverCell is a cell of the grid, or none while over is false.
+type hoverCell struct {
+	x, y int
+	over bool
+}
+
+// hover outlines the cell under the cursor while the HUD is shown, and the
+// HUD says where it is and what state it is in. Like the selection it is
+// kept out of stills and screenshots.
+type hover struct {
+	text *textRenderer
+	// cell is under the cursor, and said is the one the HUD last described,
+	// so that it is described again as soon as the cursor moves.
+	cell, said hoverCell
+}
+
+func newHover(s *State) (*hover, error) {
+	text, err := newTextRenderer(s, s.surfaceFormat)
+	if err != nil {
+		return nil, err
+	}
+	return &hover{text: text}, nil
+}
+
+// update finds the cell under the cursor and outlines it.
+func (h *hover) update(s *State) {
+	if h == nil {
+		return
+	}
+	h.cell = hoverCell{}
+	t := h.text
+	t.clear()
+	if _, ok := s.editor(); !ok || s.window == nil || s.hud == nil || !s.hud.shown {
+		return
+	}
+	x, y, ok := s.cursorCell(s.window, s.gridWidth, s.gridHeight)
+	if !ok {
+		return
+	}
+	h.cell = hoverCell{x, y, true}
+	left, top, width, height := s.cellPixels(cellRect{x, y, 1, 1})
+	// Zoomed far out a cell is less than a pixel, and the outline would
+	// only hide what is around it.
+	if edge := max(1, s.scaled(1)); width >= 3*edge {
+		outline(t, left, top, width, height, edge, hoverEdge)
+	}
+	if err := t.upload(); err != nil {
+		fmt.Println("outlining the cell under the cursor:", err)
+	}
+}
+
+// moved is whether the cursor has moved onto another cell since the HUD
+// last described the one it was over.
+func (h *hover) moved() bool {
+	return h != nil && h.cell != h.said
+}
+
+// describe is the HUD's line for the cell under the cursor, reading its
+// state back, or nothing when it isn't over the grid.
+func (h *hover) describe(s *State) []string {
+	if h == nil {
+		return nil
+	}
+	h.said = h.cell
+	e, ok := s.editor()
+	if !ok || !h.cell.over {
+		return nil
+	}
+	c := h.cell
+	line := fmt.Sprintf("cell %d, %d", c.x, c.y)
+	cells, err := e.readCells(s, cellRect{c.x, c.y, 1, 1})
+	switch {
+	case err != nil:
+		fmt.Println("reading the cell under the cursor:", err)
+	case cells[0] == 0:
+		line += ", dead"
+	case s.cfg.Life.Species > 1:
+		line += fmt.Sprintf(", species %d", cells[0])
+	default:
+		line += ", alive"
+	}
+	return []string{line}
+}
+
+// draw records the outline over view, if there is a cell under the cursor.
+func (h *hover) draw(s *State, encoder *commandEncoder, view *wgpu.TextureView) {
+	if h == nil || len(h.text.instances) == 0 {
+		return
+	}
+	h.text.draw(encoder, view, s.config.Width, s.config.Height)
+}
+
+func (h *hover) Release() {
+	if h.text != nil {
+		h.text.Release()
+		h.text = nil
+	}
+}
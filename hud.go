@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+
+	"webgpu-go/life"
+)
+
+// hudInterval is how often the HUD's text is brought up to date. Counting
+// the population reads the grid back, which is too slow for every frame.
+const hudInterval = 500 * time.Millisecond
+
+// hudMargin is how far the HUD is from the window's corner, in screen
+// coordinates.
+const hudMargin = 8
+
+// hud shows the generation, whether it is paused, the frame rate, the rule
+// and the population in the top left corner, over everything else, and the
+// cell under the cursor. H shows and hides it.
+type hud struct {
+	shown bool
+	text  *textRenderer
+	// frames have been presented since lines were set at updated.
+	frames  int
+	updated time.Time
+	lines   []string
+}
+
+func newHUD(s *State, shown bool) (*hud, error) {
+	text, err := newTextRenderer(s, s.surfaceFormat)
+	if err != nil {
+		return nil, err
+	}
+	return &hud{shown: shown, text: text, updated: time.Now()}, nil
+}
+
+// ruleName is what the HUD calls the rule cfg runs.
+func ruleName(cfg *Config) string {
+	switch cfg.Simulation {
+	case "life", "life-sparse", "hashlife":
+		return life.Conway.String()
+	case "life-3d":
+		return cfg.Life3D.Rule
+	case "table":
+		if len(cfg.Table.Transitions) > 0 {
+			return "from the transition table"
+		}
+		return cfg.Table.Rule
+	case "stochastic":
+		return cfg.Stochastic.Rule
+	case "ant":
+		return cfg.Ant.Rule
+	}
+	if info, ok := simulations[cfg.Simulation]; ok {
+		return info.Name
+	}
+	return cfg.Simulation
+}
+
+// update counts a frame, and sets the text again once hudInterval has
+// passed since it last was, or sooner for the cell under the cursor.
+func (h *hud) update(s *State) {
+	if h == nil || !h.shown {
+		return
+	}
+	h.frames++
+	if elapsed := time.Since(h.updated); elapsed >= hudInterval {
+		h.lines = h.stats(s, elapsed)
+		h.frames, h.updated = 0, time.Now()
+	} else if !s.hover.moved() {
+		return
+	}
+	lines := append(h.lines[:len(h.lines):len(h.lines)], s.hover.describe(s)...)
+	margin := s.scaled(hudMargin)
+	if err := h.text.setLines(margin, margin, s.scaled(2), lines); err != nil {
+		fmt.Println("setting the HUD's text:", err)
+	}
+}
+
+// stats is the HUD's lines about the simulation, with frames counted over
+// elapsed.
+func (h *hud) stats(s *State, elapsed time.Duration) []string {
+	generation := "generation " + s.format.Count(int64(s.steps))
+	if s.paused {
+		generation += ", paused"
+	}
+	lines := []string{
+		generation,
+		s.format.Float(float64(h.frames)/elapsed.Seconds(), 0) + " fps",
+		"rule " + ruleName(s.cfg),
+	}
+	if c, ok := s.sim.(populationCounter); ok {
+		p, err := c.Population(s)
+		if err != nil {
+			fmt.Println("counting the population:", err)
+		} else {
+			lines = append(lines, "population "+s.format.Float(p, 0))
+		}
+	}
+	return lines
+}
+
+// draw records the HUD over view, if it is shown.
+func (h *hud) draw(s *State, encoder *commandEncoder, view *wgpu.TextureView) {
+	if h == nil || !h.shown {
+		return
+	}
+	h.text.draw(encoder, view, s.config.Width, s.config.Height)
+}
+
+// handleHUDKey shows and hides the HUD with H, starting its frame count
+// over when it comes back.
+func (s *State) handleHUDKey(key glfw.Key, action glfw.Action) {
+	if key != glfw.KeyH || action != glfw.Press || s.hud == nil {
+		return
+	}
+	s.hud.shown = !s.hud.shown
+	s.hud.frames, s.hud.updated = 0, time.Now()
+}
+
+func (h *hud) Release() {
+	if h.text != nil {
+		h.text.Release()
+		h.text = nil
+	}
+}
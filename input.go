@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// InputEvent is one GLFW input callback, stamped with when it arrived and
+// the simulation step it was applied before.
+type InputEvent struct {
+	Time   time.Duration    `json:"t"`
+	Step   int              `json:"step"`
+	Kind   string           `json:"kind"`
+	Key    glfw.Key         `json:"key,omitempty"`
+	Button glfw.MouseButton `json:"button,omitempty"`
+	Action glfw.Action      `json:"action,omitempty"`
+	Mods   glfw.ModifierKey `json:"mods,omitempty"`
+	X      float64          `json:"x,omitempty"`
+	Y      float64          `json:"y,omitempty"`
+}
+
+// inputLog timestamps input events, optionally writes them to a JSON lines
+// file and estimates input-to-photon latency from when the first frame after
+// each event is presented.
+type inputLog struct {
+	start   time.Time
+	refresh time.Duration
+
+	waiting []time.Time
+	latency time.Duration
+
+	file *os.File
+	out  *bufio.Writer
+	enc  *json.Encoder
+}
+
+func newInputLog(path string) (*inputLog, error) {
+	l := &inputLog{start: time.Now()}
+	if m := glfw.GetPrimaryMonitor(); m != nil {
+		if mode := m.GetVideoMode(); mode != nil && mode.RefreshRate > 0 {
+			l.refresh = time.Second / time.Duration(mode.RefreshRate)
+		}
+	}
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		l.file = f
+		l.out = bufio.NewWriter(f)
+		l.enc = json.NewEncoder(l.out)
+	}
+	return l, nil
+}
+
+func (l *inputLog) record(e InputEvent, step int) {
+	now := time.Now()
+	e.Time = now.Sub(l.start)
+	e.Step = step
+	l.waiting = append(l.waiting, now)
+	if l.enc != nil {
+		if err := l.enc.Encode(e); err != nil {
+			fmt.Println("writing the input log, which stops here:", err)
+			l.enc = nil
+		}
+	}
+}
+
+// presented is called after a frame is handed to the swapchain. The frame
+// becomes visible at the next vertical blank at the earliest, so one refresh
+// interval is added to the estimate.
+func (l *inputLog) presented() {
+	if len(l.waiting) == 0 {
+		return
+	}
+	now := time.Now()
+	for _, t := range l.waiting {
+		sample := now.Sub(t) + l.refresh
+		if l.latency == 0 {
+			l.latency = sample
+		} else {
+			l.latency += (sample - l.latency) / 10
+		}
+	}
+	l.waiting = l.waiting[:0]
+}
+
+// Latency is a moving average of the input-to-photon estimate, which I shows
+// in the title bar with the frame stats.
+func (l *inputLog) Latency() time.Duration {
+	return l.latency
+}
+
+func (l *inputLog) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	if err := l.out.Flush(); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,32 @@
+// One step of an integrator: out = base + the weighted sum of the rates,
+// for every component of every cell. The weights have the time step in
+// them already.
+struct Combine {
+  weights: vec4<f32>,
+  components: u32,
+};
+
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<uniform> combine: Combine;
+@group(0) @binding(2) var<storage> base: array<f32>;
+@group(0) @binding(3) var<storage> rate0: array<f32>;
+@group(0) @binding(4) var<storage> rate1: array<f32>;
+@group(0) @binding(5) var<storage> rate2: array<f32>;
+@group(0) @binding(6) var<storage> rate3: array<f32>;
+@group(0) @binding(7) var<storage, read_write> out: array<f32>;
+
+@compute
+@workgroup_size(8, 8)
+fn main(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x >= u32(grid.x) || id.y >= u32(grid.y) {
+    return;
+  }
+  let w = combine.weights;
+  let cell = (id.y * u32(grid.x) + id.x) * combine.components;
+  for (var c = 0u; c < combine.components; c += 1u) {
+    let i = cell + c;
+    let v = base[i] + w.x * rate0[i] + w.y * rate1[i] + w.z * rate2[i] + w.w * rate3[i];
+    // Every continuous simulation keeps its values in [0, 1].
+    out[i] = clamp(v, 0.0, 1.0);
+  }
+}
@@ -0,0 +1,43 @@
+// integrate.wgsl in double-single precision, for doublesingle.wgsl to be
+// put in front of. The states keep their rounded values in base and out,
+// where the simulation reads and draws them as usual, and what rounding
+// lost in baseLow and outLow. Rates stay f32: only adding many small steps
+// up needs the extra precision.
+struct Combine {
+  weights: vec4<f32>,
+  components: u32,
+  one: f32,
+};
+
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<uniform> combine: Combine;
+@group(0) @binding(2) var<storage> base: array<f32>;
+@group(0) @binding(3) var<storage> rate0: array<f32>;
+@group(0) @binding(4) var<storage> rate1: array<f32>;
+@group(0) @binding(5) var<storage> rate2: array<f32>;
+@group(0) @binding(6) var<storage> rate3: array<f32>;
+@group(0) @binding(7) var<storage, read_write> out: array<f32>;
+@group(0) @binding(8) var<storage> baseLow: array<f32>;
+@group(0) @binding(9) var<storage, read_write> outLow: array<f32>;
+
+@compute
+@workgroup_size(8, 8)
+fn main(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x >= u32(grid.x) || id.y >= u32(grid.y) {
+    return;
+  }
+  ds_one = combine.one;
+  let w = combine.weights;
+  let cell = (id.y * u32(grid.x) + id.x) * combine.components;
+  for (var c = 0u; c < combine.components; c += 1u) {
+    let i = cell + c;
+    var v = vec2(base[i], baseLow[i]);
+    v = ds_add(v, ds_two_product(w.x, rate0[i]));
+    v = ds_add(v, ds_two_product(w.y, rate1[i]));
+    v = ds_add(v, ds_two_product(w.z, rate2[i]));
+    v = ds_add(v, ds_two_product(w.w, rate3[i]));
+    v = ds_clamp(v, 0.0, 1.0);
+    out[i] = v.x;
+    outLow[i] = v.y;
+  }
+}
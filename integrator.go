@@ -0,0 +1,315 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed integrate.wgsl
+var integrateShader string
+
+//go:embed integrate_ds.wgsl
+var integrateDoubleSingleShader string
+
+//go:embed doublesingle.wgsl
+var doubleSingleShader string
+
+// tableau is an explicit Runge-Kutta method. The rate of stage i+1 is taken
+// at the state plus a[i] of the rates of the stages before it, each times
+// the time step, and the step as a whole adds b of every stage's rate.
+type tableau struct {
+	a [][]float32
+	b []float32
+	// semiImplicit has the rate shaders treat each simulation's decay
+	// terms implicitly, which stays stable at time steps that make Euler
+	// blow up.
+	semiImplicit bool
+}
+
+// integrators are the methods -integrator can name.
+var integrators = map[string]tableau{
+	"euler": {b: []float32{1}},
+	"rk2":   {a: [][]float32{{0.5}}, b: []float32{0, 1}},
+	"rk4": {
+		a: [][]float32{{0.5}, {0, 0.5}, {0, 0, 1}},
+		b: []float32{1.0 / 6, 1.0 / 3, 1.0 / 3, 1.0 / 6},
+	},
+	"semi-implicit": {b: []float32{1}, semiImplicit: true},
+}
+
+// precisions are how continuous simulations can keep their state: plain f32,
+// or double-single, which keeps what each f32 lost to rounding in a second
+// buffer so that long runs of small steps don't drift.
+var precisions = []string{"single", "double-single"}
+
+func checkPrecision(name string) (doubleSingle bool, err error) {
+	switch name {
+	case "single":
+		return false, nil
+	case "double-single":
+		return true, nil
+	}
+	return false, fmt.Errorf("unknown precision %q (have %v)", name, precisions)
+}
+
+func integratorNames() []string {
+	names := make([]string, 0, len(integrators))
+	for name := range integrators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// referenceChecker is implemented by simulations with a CPU reference to check
+// the GPU's steps against, for -validate.
+type referenceChecker interface {
+	CheckReference(s *State, steps int) (float64, error)
+}
+
+// integration steps a continuous simulation with one of the integrators.
+// The simulation provides a compute pipeline that writes the rate of change
+// of the state it is bound to, and integration runs it once per stage,
+// combining the rates into the stage states and then into the next state.
+//
+// Rate shaders bind the step uniform, the time step and whether to be
+// semi-implicit, alongside their own parameters.
+type integration struct {
+	method       tableau
+	components   int
+	doubleSingle bool
+
+	bindGroupLayout *wgpu.BindGroupLayout
+	pipelineLayout  *wgpu.PipelineLayout
+	pipeline        *wgpu.ComputePipeline
+
+	step    *wgpu.Buffer
+	weights []*wgpu.Buffer
+	// rates holds each stage's rate and stage the state the next rate is
+	// taken at.
+	rates []*wgpu.Buffer
+	stage *wgpu.Buffer
+	// low has what rounding lost from each cell state in double-single
+	// precision, and stageLow that of stage.
+	low         []*wgpu.Buffer
+	stageLow    *wgpu.Buffer
+	combineSets [2][]*wgpu.BindGroup
+}
+
+// newIntegration sets up the integrator called name for cells with the
+// given number of f32 components, in the precision called precision.
+func newIntegration(s *State, name, precision string, dt float32, components int) (in *integration, err error) {
+	method, ok := integrators[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown integrator %q (have %v)", name, integratorNames())
+	}
+	if dt <= 0 {
+		return nil, fmt.Errorf("time step must be positive, got %v", dt)
+	}
+	doubleSingle, err := checkPrecision(precision)
+	if err != nil {
+		return nil, err
+	}
+	in = &integration{method: method, components: components, doubleSingle: doubleSingle}
+	defer func() {
+		if err != nil {
+			in.Release()
+		}
+	}()
+
+	code := integrateShader
+	if doubleSingle {
+		log.Println("double-single precision is slower to step")
+		code = doubleSingleShader + integrateDoubleSingleShader
+	}
+	shader := s.createShader("integrate shader", code)
+	defer shader.Release()
+
+	entries := []wgpu.BindGroupLayoutEntry{
+		bufferEntry(0, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+	}
+	for i := uint32(2); i < 7; i++ {
+		entries = append(entries, bufferEntry(i, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage))
+	}
+	entries = append(entries, bufferEntry(7, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage))
+	if doubleSingle {
+		entries = append(entries,
+			bufferEntry(8, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+			bufferEntry(9, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		)
+	}
+	in.bindGroupLayout, err = s.bindGroupLayout("integrate", entries...)
+	if err != nil {
+		return nil, err
+	}
+	in.pipelineLayout, err = s.pipelineLayout("integrate", in.bindGroupLayout)
+	if err != nil {
+		return nil, err
+	}
+	in.pipeline, err = s.computePipeline("integrate", in.pipelineLayout, shader, "main")
+	if err != nil {
+		return nil, err
+	}
+
+	semiImplicit := uint32(0)
+	if method.semiImplicit {
+		semiImplicit = 1
+	}
+	// Uniform buffers are padded out to 16 bytes.
+	in.step = s.uniformBuffer("integrate step", wgpu.ToBytes([]uint32{math.Float32bits(dt), semiImplicit, 0, 0}))
+
+	// One combination for each stage after the first, then the step.
+	for _, a := range append(method.a, method.b) {
+		var w [8]uint32
+		for j, c := range a {
+			w[j] = math.Float32bits(c * dt)
+		}
+		w[4] = uint32(components)
+		// The double-single helpers need a 1 the compiler can't see.
+		w[5] = math.Float32bits(1)
+		in.weights = append(in.weights, s.uniformBuffer("integrate weights", wgpu.ToBytes(w[:])))
+	}
+	return in, nil
+}
+
+// setCells makes the stage buffers for the pair of cell state buffers the
+// simulation steps between. For a step from either state it returns the
+// state each stage's rate is taken at, and the buffer the rate goes in.
+func (in *integration) setCells(s *State, states []*wgpu.Buffer) (from [2][]*wgpu.Buffer, to []*wgpu.Buffer) {
+	in.releaseCells()
+	empty := make([]byte, states[0].GetSize())
+	for range in.method.b {
+		in.rates = append(in.rates, s.storageBuffer(empty))
+	}
+	in.stage = s.storageBuffer(empty)
+	if in.doubleSingle {
+		// Cells start out exactly as their f32 values.
+		in.low = []*wgpu.Buffer{s.storageBuffer(empty), s.storageBuffer(empty)}
+		in.stageLow = s.storageBuffer(empty)
+	}
+
+	for p := range in.combineSets {
+		for i := range in.weights {
+			out, outLow := in.stage, in.stageLow
+			if i == len(in.weights)-1 {
+				out = states[1-p]
+				if in.doubleSingle {
+					outLow = in.low[1-p]
+				}
+			}
+			buffers := []*wgpu.Buffer{s.gridBuffer, in.weights[i], states[p]}
+			for j := 0; j < 4; j++ {
+				buffers = append(buffers, in.rates[min(j, len(in.rates)-1)])
+			}
+			buffers = append(buffers, out)
+			if in.doubleSingle {
+				buffers = append(buffers, in.low[p], outLow)
+			}
+			in.combineSets[p] = append(in.combineSets[p], s.bindGroup("integrate", in.bindGroupLayout, buffers...))
+		}
+		from[p] = []*wgpu.Buffer{states[p]}
+		for range in.method.a {
+			from[p] = append(from[p], in.stage)
+		}
+	}
+	return from, in.rates
+}
+
+// record adds one step from state p to the pass. rate is the simulation's
+// rate pipeline and rateSets its bind groups for each stage from state p,
+// and x and y are the workgroups to dispatch over the grid.
+func (in *integration) record(pass *computePass, p int, rate *wgpu.ComputePipeline, rateSets []*wgpu.BindGroup, x, y uint32) {
+	for i := range rateSets {
+		pass.SetPipeline(rate)
+		pass.SetBindGroup(0, rateSets[i], nil)
+		pass.DispatchWorkgroups(x, y, 1)
+
+		pass.SetPipeline(in.pipeline)
+		pass.SetBindGroup(0, in.combineSets[p][i], nil)
+		pass.DispatchWorkgroups(x, y, 1)
+	}
+}
+
+// reference takes one step of the method on the CPU in float64, clamping
+// every stage to [0, 1] like the GPU does, for checking the GPU's results.
+// rate writes the rate of change of a state to out.
+func (t tableau) reference(state []float64, dt float64, rate func(state, out []float64)) {
+	rates := make([][]float64, len(t.b))
+	stage := state
+	for i := range t.b {
+		if i > 0 {
+			stage = make([]float64, len(state))
+			for c := range stage {
+				v := state[c]
+				for j, a := range t.a[i-1] {
+					v += dt * float64(a) * rates[j][c]
+				}
+				stage[c] = math.Max(0, math.Min(v, 1))
+			}
+		}
+		rates[i] = make([]float64, len(state))
+		rate(stage, rates[i])
+	}
+	for c := range state {
+		v := state[c]
+		for i, b := range t.b {
+			v += dt * float64(b) * rates[i][c]
+		}
+		state[c] = math.Max(0, math.Min(v, 1))
+	}
+}
+
+func (in *integration) releaseCells() {
+	for p := range in.combineSets {
+		for _, bg := range in.combineSets[p] {
+			bg.Release()
+		}
+		in.combineSets[p] = nil
+	}
+	for _, b := range in.rates {
+		b.Release()
+	}
+	in.rates = nil
+	for _, b := range in.low {
+		b.Release()
+	}
+	in.low = nil
+	if in.stage != nil {
+		in.stage.Release()
+		in.stage = nil
+	}
+	if in.stageLow != nil {
+		in.stageLow.Release()
+		in.stageLow = nil
+	}
+}
+
+func (in *integration) Release() {
+	in.releaseCells()
+	for _, b := range in.weights {
+		b.Release()
+	}
+	in.weights = nil
+	if in.step != nil {
+		in.step.Release()
+		in.step = nil
+	}
+	if in.pipeline != nil {
+		in.pipeline.Release()
+		in.pipeline = nil
+	}
+	if in.pipelineLayout != nil {
+		in.pipelineLayout.Release()
+		in.pipelineLayout = nil
+	}
+	if in.bindGroupLayout != nil {
+		in.bindGroupLayout.Release()
+		in.bindGroupLayout = nil
+	}
+}
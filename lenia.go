@@ -0,0 +1,389 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed lenia_compute.wgsl
+var leniaCompute string
+
+//go:embed lenia_draw.wgsl
+var leniaDraw string
+
+// Lenia is a continuous cellular automaton: every cell holds a value in
+// [0, 1] that grows or decays depending on a smooth ring-shaped average of
+// its neighbourhood.
+type Lenia struct {
+	bindGroupLayout    *wgpu.BindGroupLayout
+	pipelineLayout     *wgpu.PipelineLayout
+	drawPipelineLayout *wgpu.PipelineLayout
+	pipeline           *wgpu.RenderPipeline
+	simulationPipeline *wgpu.ComputePipeline
+
+	params           *wgpu.Buffer
+	integration      *integration
+	cellStateStorage []*wgpu.Buffer
+	// rateBindGroups has, for a step from either cell state, a bind group
+	// for each stage of the integrator. The first binds the cell state
+	// itself and is also the one drawn with.
+	rateBindGroups [2][]*wgpu.BindGroup
+	vertices       *wgpu.Buffer
+	camera         *viewCamera
+	queue          *uploadQueue
+	width, height  int
+	steps          int
+
+	radius        int
+	mu, sigma, dt float32
+}
+
+func newLenia(s *State, cfg *Config) (sim Simulation, err error) {
+	p := cfg.Lenia
+	if maxRadius := min(s.gridWidth, s.gridHeight) / 2; p.Radius < 1 || p.Radius > maxRadius {
+		return nil, fmt.Errorf("lenia radius %d out of range [1, %d]", p.Radius, maxRadius)
+	}
+	if p.Sigma <= 0 {
+		return nil, fmt.Errorf("lenia sigma must be positive, got %v", p.Sigma)
+	}
+
+	l := &Lenia{
+		vertices: s.vertexBuffer,
+		camera:   s.camera,
+		queue:    s.queue,
+		width:    s.gridWidth,
+		height:   s.gridHeight,
+		radius:   p.Radius,
+		mu:       p.Mu,
+		sigma:    p.Sigma,
+		dt:       p.DT,
+	}
+	defer func() {
+		if err != nil {
+			l.Release()
+		}
+	}()
+
+	drawShader := s.createShader("lenia render shader", leniaDraw+cameraShader)
+	defer drawShader.Release()
+
+	computeShader := s.createShader("lenia compute shader", leniaCompute)
+	defer computeShader.Release()
+
+	l.bindGroupLayout, err = s.bindGroupLayout("lenia",
+		bufferEntry(0, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(2, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(3, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(4, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	l.pipelineLayout, err = s.pipelineLayout("lenia", l.bindGroupLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	l.drawPipelineLayout, err = s.pipelineLayout("lenia render", l.bindGroupLayout, s.cameraLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	l.pipeline, err = s.renderPipeline("lenia render", l.drawPipelineLayout, drawShader, "main_vs", "main_fs")
+	if err != nil {
+		return nil, err
+	}
+	l.simulationPipeline, err = s.computePipeline("lenia compute", l.pipelineLayout, computeShader, "main")
+	if err != nil {
+		return nil, err
+	}
+
+	l.integration, err = newIntegration(s, cfg.Integrator, p.Precision, p.DT, 1)
+	if err != nil {
+		return nil, err
+	}
+	l.params = s.uniformBuffer("lenia params", l.paramBytes())
+
+	cells := leniaSoup(s.rand, l.width, l.height)
+	if a := cfg.Init.array; a != nil {
+		for y := 0; y < l.height; y++ {
+			for x := 0; x < l.width; x++ {
+				cells[y*l.width+x] = a.at(x, y, 0, l.width, l.height)
+			}
+		}
+	}
+	l.setCells(s, wgpu.ToBytes(cells))
+	return l, nil
+}
+
+// leniaSoup fills the middle half of the grid with random values; the empty
+// border gives creatures room to form before they wrap around.
+func leniaSoup(rng *rand.Rand, width, height int) []float32 {
+	cells := make([]float32, width*height)
+	for y := height / 4; y < height*3/4; y++ {
+		for x := width / 4; x < width*3/4; x++ {
+			cells[y*width+x] = rng.Float32()
+		}
+	}
+	return cells
+}
+
+// setCells replaces both cell state buffers with cells.
+func (l *Lenia) setCells(s *State, cells []byte) {
+	l.releaseCells()
+	l.cellStateStorage = []*wgpu.Buffer{
+		s.storageBuffer(cells),
+		s.storageBuffer(cells),
+	}
+	from, to := l.integration.setCells(s, l.cellStateStorage)
+	for p := range l.rateBindGroups {
+		for i := range from[p] {
+			l.rateBindGroups[p] = append(l.rateBindGroups[p], s.bindGroup("lenia rate", l.bindGroupLayout,
+				s.gridBuffer, l.params, from[p][i], to[i], l.integration.step))
+		}
+	}
+}
+
+func (l *Lenia) ResizeGrid(s *State, r gridRemap) error {
+	cells, err := s.readBuffer(l.cellStateStorage[l.steps%2])
+	if err != nil {
+		return err
+	}
+	l.width, l.height = r.width, r.height
+	l.setCells(s, r.cells(cells, 4, nil))
+	return nil
+}
+
+// Population is the total mass of the field.
+func (l *Lenia) Population(s *State) (float64, error) {
+	data, err := s.readBuffer(l.cellStateStorage[l.steps%2])
+	if err != nil {
+		return 0, err
+	}
+	var total float64
+	for _, v := range wgpu.FromBytes[float32](data) {
+		total += float64(v)
+	}
+	return total, nil
+}
+
+// Fields reads the field, with the low halves of double-single cells added
+// back in as far as a float can hold them.
+func (l *Lenia) Fields(s *State) (*fieldSet, error) {
+	data, err := s.readBuffer(l.cellStateStorage[l.steps%2])
+	if err != nil {
+		return nil, err
+	}
+	field := wgpu.FromBytes[float32](data)
+	if l.integration.doubleSingle {
+		low, err := s.readBuffer(l.integration.low[l.steps%2])
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range wgpu.FromBytes[float32](low) {
+			field[i] += v
+		}
+	}
+	return &fieldSet{
+		width:    l.width,
+		height:   l.height,
+		channels: []fieldChannel{{"A", field}},
+		attributes: map[string]string{
+			"radius": strconv.Itoa(l.radius),
+			"mu":     formatAttribute(l.mu),
+			"sigma":  formatAttribute(l.sigma),
+			"dt":     formatAttribute(l.dt),
+		},
+	}, nil
+}
+
+// CheckReference steps the GPU and the CPU reference from the current cells
+// and returns the largest difference between them, then puts the cells
+// back.
+func (l *Lenia) CheckReference(s *State, steps int) (float64, error) {
+	start, err := s.readBuffer(l.cellStateStorage[l.steps%2])
+	if err != nil {
+		return 0, err
+	}
+	defer l.setCells(s, start)
+
+	for i := 0; i < steps; i++ {
+		encoder, err := s.newEncoder()
+		if err != nil {
+			return 0, err
+		}
+		l.Step(encoder)
+		cmdBuffer, err := encoder.Finish(nil)
+		encoder.Release()
+		if err != nil {
+			return 0, err
+		}
+		s.queue.Submit(cmdBuffer)
+		cmdBuffer.Release()
+	}
+	data, err := s.readBuffer(l.cellStateStorage[l.steps%2])
+	if err != nil {
+		return 0, err
+	}
+	gpu := make([]float64, l.width*l.height)
+	for i, v := range wgpu.FromBytes[float32](data) {
+		gpu[i] = float64(v)
+	}
+	if l.integration.doubleSingle {
+		low, err := s.readBuffer(l.integration.low[l.steps%2])
+		if err != nil {
+			return 0, err
+		}
+		for i, v := range wgpu.FromBytes[float32](low) {
+			gpu[i] += float64(v)
+		}
+	}
+
+	state := make([]float64, l.width*l.height)
+	for i, v := range wgpu.FromBytes[float32](start) {
+		state[i] = float64(v)
+	}
+	for i := 0; i < steps; i++ {
+		l.integration.method.reference(state, float64(l.dt), l.referenceRate)
+	}
+	var diff float64
+	for i, v := range gpu {
+		diff = math.Max(diff, math.Abs(v-state[i]))
+	}
+	return diff, nil
+}
+
+// referenceRate is lenia_compute.wgsl in float64.
+func (l *Lenia) referenceRate(state, out []float64) {
+	r := l.radius
+	kernel := make([]float64, 0, (2*r+1)*(2*r+1))
+	var weight float64
+	for dy := -r; dy <= r; dy++ {
+		for dx := -r; dx <= r; dx++ {
+			k := 0.0
+			if d := math.Hypot(float64(dx), float64(dy)) / float64(r); d > 0 && d < 1 {
+				k = math.Exp(4 - 1/(d*(1-d)))
+			}
+			kernel = append(kernel, k)
+			weight += k
+		}
+	}
+	for y := 0; y < l.height; y++ {
+		for x := 0; x < l.width; x++ {
+			var total float64
+			k := 0
+			for dy := -r; dy <= r; dy++ {
+				ny := (y + dy + l.height) % l.height
+				for dx := -r; dx <= r; dx++ {
+					nx := (x + dx + l.width) % l.width
+					total += kernel[k] * state[ny*l.width+nx]
+					k++
+				}
+			}
+			u := total / math.Max(weight, 1e-6)
+			g := (u - float64(l.mu)) / float64(l.sigma)
+			out[y*l.width+x] = 2*math.Exp(-0.5*g*g) - 1
+		}
+	}
+}
+
+func (l *Lenia) Step(encoder *commandEncoder) {
+	computePass := encoder.BeginComputePass(nil)
+	defer computePass.Release()
+
+	p := l.steps % 2
+	l.integration.record(computePass, p, l.simulationPipeline, l.rateBindGroups[p], uint32(l.width+7)/8, uint32(l.height+7)/8)
+	computePass.End()
+
+	l.steps += 1
+}
+
+func (l *Lenia) batchesSteps() bool { return true }
+
+func (l *Lenia) squareCells() bool { return true }
+
+func (l *Lenia) usesCamera() {}
+
+func (l *Lenia) Draw(pass *renderPass) {
+	pass.SetPipeline(l.pipeline)
+	pass.SetBindGroup(0, l.rateBindGroups[l.steps%2][0], nil)
+	pass.SetBindGroup(1, l.camera.bindGroup, nil)
+	pass.SetVertexBuffer(0, l.vertices, 0, wgpu.WholeSize)
+	pass.Draw(6, uint32(l.width*l.height), 0, 0)
+}
+
+func (l *Lenia) releaseCells() {
+	for p := range l.rateBindGroups {
+		for _, bg := range l.rateBindGroups[p] {
+			bg.Release()
+		}
+		l.rateBindGroups[p] = nil
+	}
+	for _, b := range l.cellStateStorage {
+		b.Release()
+	}
+	l.cellStateStorage = nil
+}
+
+func (l *Lenia) paramBytes() []byte {
+	// Uniform buffers are padded out to 16 bytes.
+	return wgpu.ToBytes([]float32{float32(l.radius), l.mu, l.sigma, 0})
+}
+
+func (l *Lenia) Parameters() []parameter {
+	return []parameter{
+		{"mu", float64(l.mu), 0, 1},
+		{"sigma", float64(l.sigma), 0.001, 0.5},
+	}
+}
+
+func (l *Lenia) SetParameter(name string, value float64) error {
+	switch name {
+	case "mu":
+		l.mu = clamp32(float32(value), 0, 1)
+	case "sigma":
+		l.sigma = clamp32(float32(value), 0.001, 0.5)
+	default:
+		return fmt.Errorf("lenia has no parameter %q", name)
+	}
+	return l.queue.WriteBuffer(l.params, 0, l.paramBytes())
+}
+
+func (l *Lenia) Release() {
+	l.releaseCells()
+	if l.integration != nil {
+		l.integration.Release()
+		l.integration = nil
+	}
+	if l.params != nil {
+		l.params.Release()
+		l.params = nil
+	}
+	if l.simulationPipeline != nil {
+		l.simulationPipeline.Release()
+		l.simulationPipeline = nil
+	}
+	if l.pipeline != nil {
+		l.pipeline.Release()
+		l.pipeline = nil
+	}
+	if l.pipelineLayout != nil {
+		l.pipelineLayout.Release()
+		l.pipelineLayout = nil
+	}
+	if l.drawPipelineLayout != nil {
+		l.drawPipelineLayout.Release()
+		l.drawPipelineLayout = nil
+	}
+	if l.bindGroupLayout != nil {
+		l.bindGroupLayout.Release()
+		l.bindGroupLayout = nil
+	}
+}
@@ -0,0 +1,52 @@
+struct Params {
+  radius: f32,
+  mu: f32,
+  sigma: f32,
+};
+
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<uniform> params: Params;
+@group(0) @binding(2) var<storage> cellStateIn: array<f32>;
+@group(0) @binding(3) var<storage, read_write> rateOut: array<f32>;
+
+// The rate of change of each cell; the integrator adds it up into the next
+// state. Growth has no decay term to take implicitly, so semi-implicit
+// steps are the same as Euler's and the step uniform goes unused.
+@compute
+@workgroup_size(8, 8)
+fn main(@builtin(global_invocation_id) cell: vec3<u32>) {
+  let size = vec2<i32>(grid);
+  let pos = vec2<i32>(cell.xy);
+  if pos.x >= size.x || pos.y >= size.y {
+    return;
+  }
+
+  // Potential: the kernel-weighted average of the neighbourhood.
+  let r = i32(params.radius);
+  var total = 0.0;
+  var weight = 0.0;
+  for (var dy = -r; dy <= r; dy++) {
+    for (var dx = -r; dx <= r; dx++) {
+      let k = kernel(length(vec2<f32>(f32(dx), f32(dy))) / params.radius);
+      let n = (pos + vec2(dx, dy) + size) % size;
+      total += k * cellStateIn[n.y * size.x + n.x];
+      weight += k;
+    }
+  }
+
+  let u = total / max(weight, 1e-6);
+  rateOut[pos.y * size.x + pos.x] = growth(u);
+}
+
+// Smooth ring peaking halfway out, zero at the centre and beyond the radius.
+fn kernel(d: f32) -> f32 {
+  if d <= 0.0 || d >= 1.0 {
+    return 0.0;
+  }
+  return exp(4.0 - 1.0 / (d * (1.0 - d)));
+}
+
+fn growth(u: f32) -> f32 {
+  let x = (u - params.mu) / params.sigma;
+  return 2.0 * exp(-0.5 * x * x) - 1.0;
+}
@@ -0,0 +1,45 @@
+// camera.wgsl goes after this.
+struct VertexInput {
+  @location(0) pos: vec2<f32>,
+  @builtin(instance_index) instance: u32,
+};
+
+struct VertexOutput {
+  @builtin(position) pos: vec4<f32>,
+  @location(0) value: f32,
+};
+
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(2) var<storage> cellStateIn: array<f32>;
+
+@vertex
+fn main_vs(input: VertexInput) -> VertexOutput {
+  let i = f32(input.instance);
+  let cell = vec2<f32>(i % grid.x, floor(i / grid.x));
+  let cellOffset = cell / grid * 2.0;
+
+  // Continuous fields read better without gaps, so grow the tile to fill
+  // the whole cell.
+  let gridPos = (input.pos * 1.25 + 1.0) / grid - 1.0 + cellOffset;
+
+  var output: VertexOutput;
+  output.pos = camera * vec4<f32>(gridPos, 0.0, 1.0);
+  output.value = cellStateIn[input.instance];
+  return output;
+}
+
+@fragment
+fn main_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  return vec4<f32>(gradient(input.value), 1.0);
+}
+
+fn gradient(t: f32) -> vec3<f32> {
+  let x = clamp(t, 0.0, 1.0) * 3.0;
+  if x < 1.0 {
+    return mix(vec3(0.0, 0.01, 0.05), vec3(0.2, 0.1, 0.5), x);
+  }
+  if x < 2.0 {
+    return mix(vec3(0.2, 0.1, 0.5), vec3(0.9, 0.3, 0.3), x - 1.0);
+  }
+  return mix(vec3(0.9, 0.3, 0.3), vec3(1.0, 0.95, 0.6), x - 2.0);
+}
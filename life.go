@@ -0,0 +1,563 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed draw.wgsl
+var draw string
+
+//go:embed compute.wgsl
+var compute string
+
+//go:embed packed_draw.wgsl
+var packedDraw string
+
+//go:embed packed_compute.wgsl
+var packedCompute string
+
+// Life is Conway's game of life, ping-ponging between two cell state buffers.
+// Alongside them a pair of age buffers counts how many generations each
+// cell has been alive, except for packed cells.
+type Life struct {
+	bindGroupLayout    *wgpu.BindGroupLayout
+	pipelineLayout     *wgpu.PipelineLayout
+	drawPipelineLayout *wgpu.PipelineLayout
+	pipeline           *wgpu.RenderPipeline
+	simulationPipeline *wgpu.ComputePipeline
+
+	cellStateStorage []*wgpu.Buffer
+	ageStorage       []*wgpu.Buffer
+	gridBindGroups   []*wgpu.BindGroup
+	vertices         *wgpu.Buffer
+	camera           *viewCamera
+	vertexCount      uint32
+	hexVertices      *wgpu.Buffer
+	boundaryBuffer   *wgpu.Buffer
+	// gradient is the ramp of colours cells are drawn in by age.
+	gradient     *wgpu.Texture
+	gradientView *wgpu.TextureView
+	// trail is where cells have been alive lately, nil unless -trail is
+	// set. noTrail stands in for its buffer otherwise.
+	trail   *trail
+	noTrail *wgpu.Buffer
+	// texture draws the cells when -renderer is texture, and culler when it
+	// is indirect.
+	texture       *cellTexture
+	culler        *cellCuller
+	queue         *uploadQueue
+	topology      string
+	packed        bool
+	boundary      uint32
+	species       int
+	width, height int
+	steps         int
+}
+
+// maxSpecies keeps competing life species to colours that can be told apart.
+const maxSpecies = 8
+
+// boundaryModes are indexed by the value the compute shaders switch on.
+var boundaryModes = []string{"torus", "dead", "mirror"}
+
+// lifeRenderers are what -renderer can name: a quad for every cell, one
+// quad over the grid sampling a texture of the cells, or a quad for only
+// the live cells, which the GPU picks out.
+var lifeRenderers = []string{"instances", "texture", "indirect"}
+
+func parseBoundary(name string) (uint32, error) {
+	for i, m := range boundaryModes {
+		if m == name {
+			return uint32(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown boundary %q, want %s", name, strings.Join(boundaryModes, ", "))
+}
+
+func newLife(s *State, cfg *Config) (sim Simulation, err error) {
+	l := &Life{
+		vertices:    s.vertexBuffer,
+		camera:      s.camera,
+		vertexCount: 6,
+		queue:       s.queue,
+		topology:    cfg.Life.Topology,
+		packed:      cfg.Life.Packed,
+		species:     cfg.Life.Species,
+		width:       s.gridWidth,
+		height:      s.gridHeight,
+	}
+	defer func() {
+		if err != nil {
+			l.Release()
+		}
+	}()
+
+	l.boundary, err = parseBoundary(cfg.Life.Boundary)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.species < 1 || l.species > maxSpecies {
+		return nil, fmt.Errorf("life species %d out of range [1, %d]", l.species, maxSpecies)
+	}
+	if l.species > 1 && (l.topology != "square" || l.packed) {
+		return nil, fmt.Errorf("competing species need the square topology, unpacked")
+	}
+
+	drawCode, computeCode := draw, compute
+	switch l.topology {
+	case "square":
+		if l.packed {
+			drawCode, computeCode = packedDraw, packedCompute
+		}
+	case "hex":
+		if l.packed {
+			return nil, fmt.Errorf("packed cells need the square topology")
+		}
+		drawCode, computeCode = hexDraw, hexCompute
+		verts := hexVertices()
+		l.hexVertices, err = s.device.CreateBufferInit(&wgpu.BufferInitDescriptor{
+			Label:    "Hex Vertices",
+			Contents: wgpu.ToBytes(verts),
+			Usage:    wgpu.BufferUsage_Vertex,
+		})
+		if err != nil {
+			return nil, err
+		}
+		l.vertices = l.hexVertices
+		l.vertexCount = uint32(len(verts) / 2)
+	default:
+		return nil, fmt.Errorf("unknown life topology %q, want square or hex", l.topology)
+	}
+
+	drawShader := s.createShader("render shader", drawCode+paletteShader+cameraShader)
+	defer drawShader.Release()
+
+	computeShader := s.createShader("compute shader", computeCode)
+	defer computeShader.Release()
+
+	l.bindGroupLayout, err = s.bindGroupLayout("bind group layouts",
+		bufferEntry(0, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Compute|wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(2, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(3, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Compute|wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		bufferEntry(4, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(5, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(6, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		bufferEntry(7, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_ReadOnlyStorage),
+		wgpu.BindGroupLayoutEntry{Binding: 8, Visibility: wgpu.ShaderStage_Fragment, Texture: wgpu.TextureBindingLayout{
+			SampleType:    wgpu.TextureSampleType_Float,
+			ViewDimension: wgpu.TextureViewDimension_1D,
+		}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	l.pipelineLayout, err = s.pipelineLayout("Compute Pipeline Layout", l.bindGroupLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	l.drawPipelineLayout, err = s.pipelineLayout("Render Pipeline Layout", l.bindGroupLayout, s.cameraLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	l.pipeline, err = s.renderPipeline("Render Pipeline", l.drawPipelineLayout, drawShader, "main_vs", "main_fs")
+	if err != nil {
+		return nil, err
+	}
+
+	cells, err := l.soup(s, cfg.Init)
+	if err != nil {
+		return nil, err
+	}
+
+	var colourByAge uint32
+	switch cfg.Life.ColourBy {
+	case "position":
+	case "age":
+		if l.packed {
+			return nil, fmt.Errorf("packed cells don't keep their ages to colour by")
+		}
+		colourByAge = 1
+	default:
+		return nil, fmt.Errorf("unknown life colouring %q, want position or age", cfg.Life.ColourBy)
+	}
+	l.gradient, l.gradientView, err = s.gradientTexture(cfg.Life.AgeGradient)
+	if err != nil {
+		return nil, err
+	}
+
+	var trails uint32
+	if cfg.Life.Trail != 0 {
+		if l.packed {
+			return nil, fmt.Errorf("packed cells don't leave trails")
+		}
+		l.trail, err = newTrail(s, cfg.Life.Trail)
+		if err != nil {
+			return nil, err
+		}
+		trails = 1
+	}
+
+	// Uniform buffers are padded out to 16 bytes.
+	l.boundaryBuffer = s.uniformBuffer("boundary", wgpu.ToBytes([]uint32{l.boundary, uint32(l.species), colourByAge, trails}))
+	switch cfg.Life.Renderer {
+	case "instances":
+	case "texture":
+		if l.texture, err = newCellTexture(s, l); err != nil {
+			return nil, err
+		}
+	case "indirect":
+		if l.culler, err = newCellCuller(s, l, drawShader); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown life renderer %q, want %s", cfg.Life.Renderer, strings.Join(lifeRenderers, ", "))
+	}
+	l.setCells(s, l.encode(cells), l.startingAges(cells))
+
+	l.simulationPipeline, err = s.computePipeline("compute", l.pipelineLayout, computeShader, "main")
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// soup is a new grid of cells from the starting pattern, each live one of
+// them a species at random.
+func (l *Life) soup(s *State, init InitConfig) ([]uint32, error) {
+	gen, err := newGenerator(init)
+	if err != nil {
+		return nil, err
+	}
+	cells := generate(gen, s.rand, l.width, l.height)
+	if l.species > 1 {
+		for i, c := range cells {
+			if c != 0 {
+				cells[i] = uint32(1 + s.rand.Intn(l.species))
+			}
+		}
+	}
+	return cells, nil
+}
+
+// encode returns cells, one per element, in the layout of the cell buffers.
+func (l *Life) encode(cells []uint32) []byte {
+	if l.packed {
+		return wgpu.ToBytes(packCells(cells, l.width, l.height))
+	}
+	return wgpu.ToBytes(cells)
+}
+
+// decode is the inverse of encode.
+func (l *Life) decode(data []byte) []uint32 {
+	if l.packed {
+		return unpackCells(wgpu.FromBytes[uint32](data), l.width, l.height)
+	}
+	return wgpu.FromBytes[uint32](data)
+}
+
+// packCells packs width by height cells into rows of 32 cells per word,
+// cell x in bit x%32, rounding each row up to a whole number of words.
+func packCells(cells []uint32, width, height int) []uint32 {
+	rowWords := (width + 31) / 32
+	words := make([]uint32, rowWords*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if cells[y*width+x] != 0 {
+				words[y*rowWords+x/32] |= 1 << (x % 32)
+			}
+		}
+	}
+	return words
+}
+
+func unpackCells(words []uint32, width, height int) []uint32 {
+	rowWords := (width + 31) / 32
+	cells := make([]uint32, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			cells[y*width+x] = words[y*rowWords+x/32] >> (x % 32) & 1
+		}
+	}
+	return cells
+}
+
+// startingAges are the ages of cells, in the layout of the age buffers,
+// counting every live cell as just born. Packed cells have no ages.
+func (l *Life) startingAges(cells []uint32) []byte {
+	if l.packed {
+		return nil
+	}
+	ages := make([]uint32, len(cells))
+	for i, c := range cells {
+		ages[i] = min(c, 1)
+	}
+	return wgpu.ToBytes(ages)
+}
+
+// setCells replaces both cell state buffers with cells and both age buffers
+// with ages. Without ages the age buffers are left as placeholders the
+// shaders don't read.
+func (l *Life) setCells(s *State, cells, ages []byte) {
+	l.releaseCells()
+	l.cellStateStorage = []*wgpu.Buffer{
+		s.storageBuffer(cells),
+		s.storageBuffer(cells),
+	}
+	if ages == nil {
+		ages = make([]byte, 4)
+	}
+	l.ageStorage = []*wgpu.Buffer{
+		s.storageBuffer(ages),
+		s.storageBuffer(ages),
+	}
+	trailBuffer := l.noTrail
+	if l.trail != nil {
+		l.trail.setCells(s, l.cellStateStorage, l.width, l.height)
+		trailBuffer = l.trail.buffer
+	} else if trailBuffer == nil {
+		l.noTrail = s.storageBuffer(make([]byte, 4))
+		trailBuffer = l.noTrail
+	}
+	l.gridBindGroups = []*wgpu.BindGroup{
+		s.textureBindGroup("cell renderer A", l.bindGroupLayout, []*wgpu.Buffer{s.gridBuffer, l.cellStateStorage[0], l.cellStateStorage[1], l.boundaryBuffer, l.ageStorage[0], l.ageStorage[1], s.paletteBuffer, trailBuffer}, l.gradientView),
+		s.textureBindGroup("cell renderer B", l.bindGroupLayout, []*wgpu.Buffer{s.gridBuffer, l.cellStateStorage[1], l.cellStateStorage[0], l.boundaryBuffer, l.ageStorage[1], l.ageStorage[0], s.paletteBuffer, trailBuffer}, l.gradientView),
+	}
+	if l.texture != nil {
+		if err := l.texture.setCells(s, l, trailBuffer); err != nil {
+			panic(err)
+		}
+	}
+	if l.culler != nil {
+		if err := l.culler.setCells(s, l, trailBuffer); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (l *Life) ResizeGrid(s *State, r gridRemap) error {
+	data, err := s.readBuffer(l.cellStateStorage[l.steps%2])
+	if err != nil {
+		return err
+	}
+	cells := r.cells(wgpu.ToBytes(l.decode(data)), 4, nil)
+	var ages []byte
+	if !l.packed {
+		if ages, err = s.readBuffer(l.ageStorage[l.steps%2]); err != nil {
+			return err
+		}
+		ages = r.cells(ages, 4, nil)
+	}
+	l.width, l.height = r.width, r.height
+	l.setCells(s, l.encode(wgpu.FromBytes[uint32](cells)), ages)
+	return nil
+}
+
+// Fields reads the cells, 0 for dead and otherwise the species.
+func (l *Life) Fields(s *State) (*fieldSet, error) {
+	data, err := s.readBuffer(l.cellStateStorage[l.steps%2])
+	if err != nil {
+		return nil, err
+	}
+	f := cellFields(l.decode(data), l.width, l.height)
+	f.attributes["topology"] = l.topology
+	return f, nil
+}
+
+// Ages reads how many generations each cell has been alive.
+func (l *Life) Ages(s *State) ([]uint32, error) {
+	if l.packed {
+		return nil, fmt.Errorf("packed cells don't keep their ages")
+	}
+	data, err := s.readBuffer(l.ageStorage[l.steps%2])
+	if err != nil {
+		return nil, err
+	}
+	return wgpu.FromBytes[uint32](data), nil
+}
+
+func (l *Life) gridLimit() int {
+	limit := maxGridSize
+	if l.packed {
+		limit = maxPackedGridSize
+	}
+	if l.texture != nil {
+		limit = min(limit, l.texture.limit)
+	}
+	if l.culler != nil {
+		// The visible cells take a word each, packed or not.
+		limit = min(limit, maxGridSize)
+	}
+	return limit
+}
+
+func (l *Life) Population(s *State) (float64, error) {
+	if !l.packed {
+		return liveCells(s, l.cellStateStorage[l.steps%2])
+	}
+	data, err := s.readBuffer(l.cellStateStorage[l.steps%2])
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, w := range wgpu.FromBytes[uint32](data) {
+		n += bits.OnesCount32(w)
+	}
+	return float64(n), nil
+}
+
+func (l *Life) Step(encoder *commandEncoder) {
+	computePass := encoder.BeginComputePass(nil)
+	defer computePass.Release()
+
+	computePass.SetPipeline(l.simulationPipeline)
+	computePass.SetBindGroup(0, l.gridBindGroups[l.steps%2], nil)
+	if l.topology == "hex" {
+		computePass.DispatchWorkgroups(uint32(l.width+7)/8, uint32(l.height+7)/8, 1)
+	} else if l.packed {
+		rowWords := (l.width + 31) / 32
+		computePass.DispatchWorkgroups(uint32(rowWords+15)/16, uint32(l.height), 1)
+	} else {
+		computePass.DispatchWorkgroups(uint32(l.width+15)/16, uint32(l.height), 1)
+	}
+	computePass.End()
+
+	l.steps += 1
+	if l.trail != nil {
+		l.trail.step(encoder, l.steps%2)
+	}
+	if l.texture != nil {
+		l.texture.update(encoder, l.steps%2)
+	}
+	if l.culler != nil {
+		l.culler.update(encoder, l.steps%2)
+	}
+}
+
+func (l *Life) batchesSteps() bool { return true }
+
+func (l *Life) squareCells() bool { return l.topology == "square" }
+
+func (l *Life) usesCamera() {}
+
+func (l *Life) Draw(pass *renderPass) {
+	if l.texture != nil {
+		l.texture.draw(pass)
+		return
+	}
+	if l.culler != nil {
+		l.culler.draw(pass, l)
+		return
+	}
+	pass.SetPipeline(l.pipeline)
+	pass.SetBindGroup(0, l.gridBindGroups[l.steps%2], nil)
+	pass.SetBindGroup(1, l.camera.bindGroup, nil)
+	pass.SetVertexBuffer(0, l.vertices, 0, wgpu.WholeSize)
+	pass.Draw(l.vertexCount, uint32(l.width*l.height), 0, 0)
+}
+
+// HandleKey cycles through the boundary modes with B, and with trails on
+// makes them fade twice as fast with Ctrl+= or half as fast with Ctrl+-.
+func (l *Life) HandleKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if action != glfw.Press {
+		return
+	}
+	switch key {
+	case glfw.KeyB:
+		l.boundary = (l.boundary + 1) % uint32(len(boundaryModes))
+		l.queue.WriteBuffer(l.boundaryBuffer, 0, wgpu.ToBytes([]uint32{l.boundary}))
+		fmt.Printf("life boundary %s\n", boundaryModes[l.boundary])
+	case glfw.KeyEqual, glfw.KeyMinus:
+		// Without Ctrl they are the tick rate's.
+		if l.trail == nil || mods&glfw.ModControl == 0 {
+			return
+		}
+		fade := l.trail.fade * 2
+		if key == glfw.KeyMinus {
+			fade = l.trail.fade / 2
+		}
+		l.trail.setFade(l.queue, fade)
+		fmt.Printf("trails fade by %g a generation\n", l.trail.fade)
+	}
+}
+
+func (l *Life) releaseCells() {
+	for _, bg := range l.gridBindGroups {
+		bg.Release()
+	}
+	l.gridBindGroups = nil
+	for _, b := range l.cellStateStorage {
+		b.Release()
+	}
+	l.cellStateStorage = nil
+	for _, b := range l.ageStorage {
+		b.Release()
+	}
+	l.ageStorage = nil
+}
+
+func (l *Life) Release() {
+	l.releaseCells()
+	if l.texture != nil {
+		l.texture.Release()
+		l.texture = nil
+	}
+	if l.culler != nil {
+		l.culler.Release()
+		l.culler = nil
+	}
+	if l.trail != nil {
+		l.trail.Release()
+		l.trail = nil
+	}
+	if l.noTrail != nil {
+		l.noTrail.Release()
+		l.noTrail = nil
+	}
+	if l.boundaryBuffer != nil {
+		l.boundaryBuffer.Release()
+		l.boundaryBuffer = nil
+	}
+	if l.hexVertices != nil {
+		l.hexVertices.Release()
+		l.hexVertices = nil
+	}
+	if l.gradientView != nil {
+		l.gradientView.Release()
+		l.gradientView = nil
+	}
+	if l.gradient != nil {
+		l.gradient.Release()
+		l.gradient = nil
+	}
+	if l.simulationPipeline != nil {
+		l.simulationPipeline.Release()
+		l.simulationPipeline = nil
+	}
+	if l.pipeline != nil {
+		l.pipeline.Release()
+		l.pipeline = nil
+	}
+	if l.pipelineLayout != nil {
+		l.pipelineLayout.Release()
+		l.pipelineLayout = nil
+	}
+	if l.drawPipelineLayout != nil {
+		l.drawPipelineLayout.Release()
+		l.drawPipelineLayout = nil
+	}
+	if l.bindGroupLayout != nil {
+		l.bindGroupLayout.Release()
+		l.bindGroupLayout = nil
+	}
+}
@@ -0,0 +1,416 @@
+// Package life steps Life-like cellular automata on the GPU, for programs
+// that want fast stepping without the window and renderer of the rest of
+// webgpu-go:
+//
+//	next := life.Step(cells, width, height, life.Conway)
+//
+// Grids are width by height cells row by row, any non-zero cell alive, and
+// wrap around at the edges. Step sets up a GPU device the first time it is
+// called and keeps it; a Stepper is the same with its own device, errors
+// rather than panics and several generations at a time. Calls made from
+// different goroutines at once are batched into one submission.
+package life
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed step.wgsl
+var stepShader string
+
+var shared struct {
+	once    sync.Once
+	stepper *Stepper
+	err     error
+}
+
+// Step returns the generation after cells under rule. It panics if there
+// is no GPU to step on or the grid doesn't fit it, as well as when cells
+// isn't width by height.
+func Step(cells []uint32, width, height int, rule Rule) []uint32 {
+	shared.once.Do(func() {
+		shared.stepper, shared.err = NewStepper()
+	})
+	if shared.err != nil {
+		panic(shared.err)
+	}
+	next, err := shared.stepper.Step(cells, width, height, rule, 1)
+	if err != nil {
+		panic(err)
+	}
+	return next
+}
+
+// Stepper steps grids on a GPU device of its own. Its methods can be called
+// from any goroutine.
+type Stepper struct {
+	instance       *wgpu.Instance
+	adapter        *wgpu.Adapter
+	device         *wgpu.Device
+	queue          *wgpu.Queue
+	shader         *wgpu.ShaderModule
+	layout         *wgpu.BindGroupLayout
+	pipelineLayout *wgpu.PipelineLayout
+	pipeline       *wgpu.ComputePipeline
+	limits         wgpu.Limits
+
+	mu     sync.Mutex
+	closed bool
+	jobs   chan *job
+	done   chan struct{}
+}
+
+type job struct {
+	cells         []uint32
+	width, height int
+	rule          Rule
+	generations   int
+	result        chan result
+}
+
+type result struct {
+	cells []uint32
+	err   error
+}
+
+// NewStepper sets up a GPU device to step grids on, a software one if
+// WGPU_FORCE_FALLBACK_ADAPTER is 1.
+func NewStepper() (st *Stepper, err error) {
+	st = &Stepper{jobs: make(chan *job, 64), done: make(chan struct{})}
+	defer func() {
+		if err != nil {
+			st.release()
+		}
+	}()
+
+	st.instance = wgpu.CreateInstance(nil)
+	st.adapter, err = st.instance.RequestAdapter(&wgpu.RequestAdapterOptions{
+		ForceFallbackAdapter: os.Getenv("WGPU_FORCE_FALLBACK_ADAPTER") == "1",
+	})
+	if err != nil {
+		return nil, err
+	}
+	st.device, err = st.adapter.RequestDevice(nil)
+	if err != nil {
+		return nil, err
+	}
+	st.queue = st.device.GetQueue()
+	st.limits = st.device.GetLimits().Limits
+
+	st.shader, err = st.device.CreateShaderModule(&wgpu.ShaderModuleDescriptor{
+		Label:          "life step",
+		WGSLDescriptor: &wgpu.ShaderModuleWGSLDescriptor{Code: stepShader},
+	})
+	if err != nil {
+		return nil, err
+	}
+	entry := func(binding uint32, typ wgpu.BufferBindingType) wgpu.BindGroupLayoutEntry {
+		return wgpu.BindGroupLayoutEntry{
+			Binding:    binding,
+			Visibility: wgpu.ShaderStage_Compute,
+			Buffer:     wgpu.BufferBindingLayout{Type: typ},
+		}
+	}
+	st.layout, err = st.device.CreateBindGroupLayout(&wgpu.BindGroupLayoutDescriptor{
+		Label: "life step",
+		Entries: []wgpu.BindGroupLayoutEntry{
+			entry(0, wgpu.BufferBindingType_Uniform),
+			entry(1, wgpu.BufferBindingType_ReadOnlyStorage),
+			entry(2, wgpu.BufferBindingType_Storage),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	st.pipelineLayout, err = st.device.CreatePipelineLayout(&wgpu.PipelineLayoutDescriptor{
+		Label:            "life step",
+		BindGroupLayouts: []*wgpu.BindGroupLayout{st.layout},
+	})
+	if err != nil {
+		return nil, err
+	}
+	st.pipeline, err = st.device.CreateComputePipeline(&wgpu.ComputePipelineDescriptor{
+		Label:   "life step",
+		Layout:  st.pipelineLayout,
+		Compute: wgpu.ProgrammableStageDescriptor{Module: st.shader, EntryPoint: "main"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go st.run()
+	return st, nil
+}
+
+// Step returns cells after generations generations under rule, with live
+// cells 1.
+func (st *Stepper) Step(cells []uint32, width, height int, rule Rule, generations int) ([]uint32, error) {
+	if width <= 0 || height <= 0 || len(cells) != width*height {
+		return nil, fmt.Errorf("%d cells for a %dx%d grid", len(cells), width, height)
+	}
+	if generations < 0 {
+		return nil, fmt.Errorf("%d generations", generations)
+	}
+	if limit := st.limits.MaxStorageBufferBindingSize; uint64(len(cells))*4 > limit {
+		return nil, fmt.Errorf("a %dx%d grid is past the GPU's %d byte buffers", width, height, limit)
+	}
+	if limit := st.limits.MaxComputeWorkgroupsPerDimension; uint64(width+7)/8 > uint64(limit) || uint64(height+7)/8 > uint64(limit) {
+		return nil, fmt.Errorf("a %dx%d grid is too large to dispatch", width, height)
+	}
+
+	j := &job{cells, width, height, rule, generations, make(chan result, 1)}
+	st.mu.Lock()
+	if st.closed {
+		st.mu.Unlock()
+		return nil, errors.New("life: stepper closed")
+	}
+	st.jobs <- j
+	st.mu.Unlock()
+	r := <-j.result
+	return r.cells, r.err
+}
+
+// run steps every job waiting whenever it gets to them, so that calls made
+// together share a submission and a wait for the GPU.
+func (st *Stepper) run() {
+	defer close(st.done)
+	for j := range st.jobs {
+		batch := []*job{j}
+	more:
+		for {
+			select {
+			case j, ok := <-st.jobs:
+				if !ok {
+					break more
+				}
+				batch = append(batch, j)
+			default:
+				break more
+			}
+		}
+		st.step(batch)
+	}
+}
+
+// pending is one job's buffers on the GPU. buffers ping-pong, sets[i] reading
+// buffers[i] and writing the other.
+type pending struct {
+	*job
+	params   *wgpu.Buffer
+	buffers  [2]*wgpu.Buffer
+	sets     [2]*wgpu.BindGroup
+	readback *wgpu.Buffer
+}
+
+func (st *Stepper) step(batch []*job) {
+	encoder, err := st.device.CreateCommandEncoder(nil)
+	if err != nil {
+		for _, j := range batch {
+			j.result <- result{err: err}
+		}
+		return
+	}
+	defer encoder.Release()
+
+	var runs []*pending
+	defer func() {
+		for _, r := range runs {
+			r.release()
+		}
+	}()
+	for _, j := range batch {
+		r, err := st.encode(encoder, j)
+		if err != nil {
+			j.result <- result{err: err}
+			continue
+		}
+		runs = append(runs, r)
+	}
+
+	cmdBuffer, err := encoder.Finish(nil)
+	if err != nil {
+		for _, r := range runs {
+			r.result <- result{err: err}
+		}
+		return
+	}
+	defer cmdBuffer.Release()
+	st.queue.Submit(cmdBuffer)
+
+	statuses := make([]wgpu.BufferMapAsyncStatus, len(runs))
+	errs := make([]error, len(runs))
+	for i, r := range runs {
+		i := i
+		errs[i] = r.readback.MapAsync(wgpu.MapMode_Read, 0, r.readback.GetSize(), func(status wgpu.BufferMapAsyncStatus) {
+			statuses[i] = status
+		})
+	}
+	st.device.Poll(true, nil)
+	for i, r := range runs {
+		if errs[i] == nil && statuses[i] != wgpu.BufferMapAsyncStatus_Success {
+			errs[i] = fmt.Errorf("mapping buffer for reading: %s", statuses[i])
+		}
+		if errs[i] != nil {
+			r.result <- result{err: errs[i]}
+			continue
+		}
+		data := r.readback.GetMappedRange(0, uint(r.readback.GetSize()))
+		cells := make([]uint32, len(r.cells))
+		for c := range cells {
+			// With no generations to step the cells are as they were
+			// given, any non-zero value alive.
+			if binary.LittleEndian.Uint32(data[4*c:]) != 0 {
+				cells[c] = 1
+			}
+		}
+		r.readback.Unmap()
+		r.result <- result{cells: cells}
+	}
+}
+
+// encode records j's generations into encoder, followed by copying the last
+// of them out to be read.
+func (st *Stepper) encode(encoder *wgpu.CommandEncoder, j *job) (r *pending, err error) {
+	r = &pending{job: j}
+	defer func() {
+		if err != nil {
+			r.release()
+		}
+	}()
+
+	size := uint64(len(j.cells)) * 4
+	r.params, err = st.device.CreateBufferInit(&wgpu.BufferInitDescriptor{
+		Label:    "life step params",
+		Contents: wgpu.ToBytes([]uint32{uint32(j.width), uint32(j.height), uint32(j.rule.Birth), uint32(j.rule.Survival)}),
+		Usage:    wgpu.BufferUsage_Uniform,
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.buffers[0], err = st.device.CreateBufferInit(&wgpu.BufferInitDescriptor{
+		Label:    "life step cells",
+		Contents: wgpu.ToBytes(j.cells),
+		Usage:    wgpu.BufferUsage_Storage | wgpu.BufferUsage_CopySrc,
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.buffers[1], err = st.device.CreateBuffer(&wgpu.BufferDescriptor{
+		Label: "life step cells",
+		Size:  size,
+		Usage: wgpu.BufferUsage_Storage | wgpu.BufferUsage_CopySrc,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range r.sets {
+		r.sets[i], err = st.device.CreateBindGroup(&wgpu.BindGroupDescriptor{
+			Label:  "life step",
+			Layout: st.layout,
+			Entries: []wgpu.BindGroupEntry{
+				{Binding: 0, Buffer: r.params, Size: wgpu.WholeSize},
+				{Binding: 1, Buffer: r.buffers[i], Size: wgpu.WholeSize},
+				{Binding: 2, Buffer: r.buffers[1-i], Size: wgpu.WholeSize},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	r.readback, err = st.device.CreateBuffer(&wgpu.BufferDescriptor{
+		Label: "life step readback",
+		Size:  size,
+		Usage: wgpu.BufferUsage_MapRead | wgpu.BufferUsage_CopyDst,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if j.generations > 0 {
+		pass := encoder.BeginComputePass(nil)
+		pass.SetPipeline(st.pipeline)
+		for g := 0; g < j.generations; g++ {
+			pass.SetBindGroup(0, r.sets[g%2], nil)
+			pass.DispatchWorkgroups(uint32(j.width+7)/8, uint32(j.height+7)/8, 1)
+		}
+		pass.End()
+		pass.Release()
+	}
+	return r, encoder.CopyBufferToBuffer(r.buffers[j.generations%2], 0, r.readback, 0, size)
+}
+
+func (r *pending) release() {
+	if r.readback != nil {
+		r.readback.Release()
+	}
+	for i := range r.sets {
+		if r.sets[i] != nil {
+			r.sets[i].Release()
+		}
+	}
+	for i := range r.buffers {
+		if r.buffers[i] != nil {
+			r.buffers[i].Release()
+		}
+	}
+	if r.params != nil {
+		r.params.Release()
+	}
+}
+
+// Close waits for the steps under way and releases the device. Steps asked
+// for afterwards fail.
+func (st *Stepper) Close() {
+	st.mu.Lock()
+	if st.closed {
+		st.mu.Unlock()
+		return
+	}
+	st.closed = true
+	close(st.jobs)
+	st.mu.Unlock()
+	<-st.done
+	st.release()
+}
+
+func (st *Stepper) release() {
+	if st.pipeline != nil {
+		st.pipeline.Release()
+		st.pipeline = nil
+	}
+	if st.pipelineLayout != nil {
+		st.pipelineLayout.Release()
+		st.pipelineLayout = nil
+	}
+	if st.layout != nil {
+		st.layout.Release()
+		st.layout = nil
+	}
+	if st.shader != nil {
+		st.shader.Release()
+		st.shader = nil
+	}
+	if st.queue != nil {
+		st.queue.Release()
+		st.queue = nil
+	}
+	if st.device != nil {
+		st.device.Release()
+		st.device = nil
+	}
+	if st.adapter != nil {
+		st.adapter.Release()
+		st.adapter = nil
+	}
+	if st.instance != nil {
+		st.instance.Release()
+		st.instance = nil
+	}
+}
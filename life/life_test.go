@@ -0,0 +1,126 @@
+package life
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	for _, tt := range []struct {
+		rule, want string
+	}{
+		{"B3/S23", "B3/S23"},
+		{"b36/s23", "B36/S23"},
+		{"B2/S", "B2/S"},
+		{"B/S012345678", "B/S012345678"},
+		{"B33/S32", "B3/S23"},
+	} {
+		r, err := ParseRule(tt.rule)
+		if err != nil {
+			t.Errorf("ParseRule(%q): %v", tt.rule, err)
+			continue
+		}
+		if got := r.String(); got != tt.want {
+			t.Errorf("ParseRule(%q) is %s, want %s", tt.rule, got, tt.want)
+		}
+		if again, err := ParseRule(r.String()); err != nil || again != r {
+			t.Errorf("%s doesn't read back as itself: %v, %v", r, again, err)
+		}
+	}
+	if r, _ := ParseRule("B3/S23"); r != Conway {
+		t.Errorf("B3/S23 is %v, want Conway", r)
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	for _, rule := range []string{"", "B3", "S23/B3", "B9/S23", "B3/S2x", "B3/S23/", "B3/S23/C2", "3/23"} {
+		if r, err := ParseRule(rule); err == nil {
+			t.Errorf("ParseRule(%q) is %v, want an error", rule, r)
+		}
+	}
+}
+
+// stepper is a Stepper for the test, which is skipped if there is no GPU.
+func stepper(t *testing.T) *Stepper {
+	t.Helper()
+	st, err := NewStepper()
+	if err != nil {
+		t.Skip("no GPU to step on:", err)
+	}
+	t.Cleanup(st.Close)
+	return st
+}
+
+func TestStepperStep(t *testing.T) {
+	st := stepper(t)
+	horizontal := []uint32{
+		0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0,
+		0, 1, 1, 1, 0,
+		0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0,
+	}
+	vertical := []uint32{
+		0, 0, 0, 0, 0,
+		0, 0, 1, 0, 0,
+		0, 0, 1, 0, 0,
+		0, 0, 1, 0, 0,
+		0, 0, 0, 0, 0,
+	}
+	glider := make([]uint32, 8*8)
+	for _, i := range []int{1, 8 + 2, 16, 16 + 1, 16 + 2} {
+		glider[i] = 1
+	}
+	// A glider moves a cell across and down every 4 generations, so on
+	// an 8x8 torus it is back where it started after 32.
+	moved := make([]uint32, 8*8)
+	for _, i := range []int{1, 8 + 2, 16, 16 + 1, 16 + 2} {
+		moved[(i/8+1)*8+i%8+1] = 1
+	}
+	for _, tt := range []struct {
+		name          string
+		cells         []uint32
+		width, height int
+		generations   int
+		want          []uint32
+	}{
+		{"blinker", horizontal, 5, 5, 1, vertical},
+		{"blinker twice", horizontal, 5, 5, 2, horizontal},
+		{"glider", glider, 8, 8, 4, moved},
+		{"glider round the torus", glider, 8, 8, 32, glider},
+	} {
+		got, err := st.Step(tt.cells, tt.width, tt.height, Conway, tt.generations)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s after %d generations is %v, want %v", tt.name, tt.generations, got, tt.want)
+		}
+	}
+}
+
+func TestStepperLiveCellsAreOne(t *testing.T) {
+	st := stepper(t)
+	got, err := st.Step([]uint32{7, 0, 2, 0}, 2, 2, Conway, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint32{1, 0, 1, 0}; !slices.Equal(got, want) {
+		t.Errorf("stepping no generations gave %v, want %v", got, want)
+	}
+}
+
+func TestStepperErrors(t *testing.T) {
+	st := stepper(t)
+	if _, err := st.Step(make([]uint32, 5), 2, 2, Conway, 1); err == nil {
+		t.Error("stepping 5 cells as a 2x2 grid succeeded")
+	}
+	if _, err := st.Step(make([]uint32, 4), 2, 2, Conway, -1); err == nil {
+		t.Error("stepping -1 generations succeeded")
+	}
+	st.Close()
+	if _, err := st.Step(make([]uint32, 4), 2, 2, Conway, 1); err == nil {
+		t.Error("stepping after Close succeeded")
+	}
+}
@@ -0,0 +1,51 @@
+package life
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rule is a Life-like rule: Birth has bit n set when a dead cell with n
+// live neighbours comes to life, and Survival when a live one with n stays
+// alive.
+type Rule struct {
+	Birth, Survival uint16
+}
+
+// Conway is B3/S23, the Game of Life.
+var Conway = Rule{Birth: 1 << 3, Survival: 1<<2 | 1<<3}
+
+// ParseRule reads a rule in B/S notation, such as B3/S23 or B36/S23.
+func ParseRule(rule string) (Rule, error) {
+	fields := strings.Split(strings.ToUpper(rule), "/")
+	if len(fields) != 2 || !strings.HasPrefix(fields[0], "B") || !strings.HasPrefix(fields[1], "S") {
+		return Rule{}, fmt.Errorf("rule %q isn't in B/S notation, such as B3/S23", rule)
+	}
+	var r Rule
+	for i, counts := range []*uint16{&r.Birth, &r.Survival} {
+		for _, c := range fields[i][1:] {
+			if c < '0' || c > '8' {
+				return Rule{}, fmt.Errorf("rule %q has %q for a neighbour count", rule, c)
+			}
+			*counts |= 1 << (c - '0')
+		}
+	}
+	return r, nil
+}
+
+func (r Rule) String() string {
+	var b strings.Builder
+	b.WriteByte('B')
+	for n := 0; n <= 8; n++ {
+		if r.Birth&(1<<n) != 0 {
+			b.WriteByte(byte('0' + n))
+		}
+	}
+	b.WriteString("/S")
+	for n := 0; n <= 8; n++ {
+		if r.Survival&(1<<n) != 0 {
+			b.WriteByte(byte('0' + n))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,33 @@
+struct Params {
+  size: vec2<u32>,
+  // birth and survival have bit n set for n live neighbours bringing a dead
+  // cell to life and keeping a live one alive.
+  birth: u32,
+  survival: u32,
+};
+
+@group(0) @binding(0) var<uniform> params: Params;
+@group(0) @binding(1) var<storage> cellsIn: array<u32>;
+@group(0) @binding(2) var<storage, read_write> cellsOut: array<u32>;
+
+@compute
+@workgroup_size(8, 8)
+fn main(@builtin(global_invocation_id) id: vec3<u32>) {
+  if any(id.xy >= params.size) {
+    return;
+  }
+  let size = vec2<i32>(params.size);
+  let cell = vec2<i32>(id.xy);
+  var n = 0u;
+  for (var dy = -1; dy <= 1; dy += 1) {
+    for (var dx = -1; dx <= 1; dx += 1) {
+      if dx != 0 || dy != 0 {
+        let c = (cell + vec2(dx, dy) + size) % size;
+        n += min(cellsIn[c.y * size.x + c.x], 1u);
+      }
+    }
+  }
+  let i = cell.y * size.x + cell.x;
+  let rule = select(params.birth, params.survival, cellsIn[i] != 0u);
+  cellsOut[i] = (rule >> n) & 1u;
+}
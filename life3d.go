@@ -0,0 +1,203 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed life3d_compute.wgsl
+var life3DCompute string
+
+//go:embed life3d_draw.wgsl
+var life3DDraw string
+
+// Life3D is life on a cubic lattice with 26 neighbours, drawn by raymarching
+// through the volume from a camera slowly orbiting it.
+type Life3D struct {
+	bindGroupLayout    *wgpu.BindGroupLayout
+	pipelineLayout     *wgpu.PipelineLayout
+	pipeline           *wgpu.RenderPipeline
+	simulationPipeline *wgpu.ComputePipeline
+
+	params           *wgpu.Buffer
+	camera           *orbitCamera
+	cellStateStorage []*wgpu.Buffer
+	gridBindGroups   []*wgpu.BindGroup
+	vertices         *wgpu.Buffer
+	size             int
+	steps            int
+}
+
+// parseBaysRule reads a 3D life rule in Carter Bays' notation: the lowest
+// and highest neighbour counts for survival, then for birth. "4555" is one
+// digit each; counts above 9 need commas, as in "5,7,6,6".
+func parseBaysRule(rule string) ([4]uint32, error) {
+	var r [4]uint32
+	fields := strings.Split(rule, ",")
+	if len(fields) == 1 {
+		fields = strings.Split(rule, "")
+	}
+	if len(fields) != 4 {
+		return r, fmt.Errorf("3d life rule %q: want four neighbour counts such as 4555", rule)
+	}
+	for i, f := range fields {
+		n, err := strconv.ParseUint(strings.TrimSpace(f), 10, 32)
+		if err != nil || n > 26 {
+			return r, fmt.Errorf("3d life rule %q: bad neighbour count %q", rule, f)
+		}
+		r[i] = uint32(n)
+	}
+	if r[0] > r[1] || r[2] > r[3] {
+		return r, fmt.Errorf("3d life rule %q: ranges must be low then high", rule)
+	}
+	return r, nil
+}
+
+func newLife3D(s *State, cfg *Config) (sim Simulation, err error) {
+	rule, err := parseBaysRule(cfg.Life3D.Rule)
+	if err != nil {
+		return nil, err
+	}
+	size := cfg.Life3D.Size
+	if size < 4 || size > 256 {
+		return nil, fmt.Errorf("3d life size %d out of range [4, 256]", size)
+	}
+
+	l := &Life3D{
+		vertices: s.vertexBuffer,
+		size:     size,
+	}
+	defer func() {
+		if err != nil {
+			l.Release()
+		}
+	}()
+
+	drawShader := s.createShader("3d life render shader", life3DDraw)
+	defer drawShader.Release()
+
+	computeShader := s.createShader("3d life compute shader", life3DCompute)
+	defer computeShader.Release()
+
+	l.bindGroupLayout, err = s.bindGroupLayout("3d life",
+		bufferEntry(0, wgpu.ShaderStage_Compute|wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		bufferEntry(2, wgpu.ShaderStage_Compute|wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(3, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	l.pipelineLayout, err = s.pipelineLayout("3d life", l.bindGroupLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	l.pipeline, err = s.renderPipeline("3d life render", l.pipelineLayout, drawShader, "main_vs", "main_fs")
+	if err != nil {
+		return nil, err
+	}
+	l.simulationPipeline, err = s.computePipeline("3d life compute", l.pipelineLayout, computeShader, "main")
+	if err != nil {
+		return nil, err
+	}
+
+	l.params = s.uniformBuffer("3d life params", wgpu.ToBytes([]uint32{
+		rule[0], rule[1], rule[2], rule[3], uint32(size), 0, 0, 0,
+	}))
+	l.camera = newOrbitCamera(s, "3d life camera")
+
+	cells := life3DSoup(s.rand, size, cfg.Init.Density)
+	l.cellStateStorage = []*wgpu.Buffer{
+		s.storageBuffer(wgpu.ToBytes(cells)),
+		s.storageBuffer(wgpu.ToBytes(cells)),
+	}
+	l.gridBindGroups = []*wgpu.BindGroup{
+		s.bindGroup("3d life A", l.bindGroupLayout, l.params, l.camera.buffer, l.cellStateStorage[0], l.cellStateStorage[1]),
+		s.bindGroup("3d life B", l.bindGroupLayout, l.params, l.camera.buffer, l.cellStateStorage[1], l.cellStateStorage[0]),
+	}
+	return l, nil
+}
+
+// life3DSoup fills a cube in the middle of the volume, a third of the way in
+// from each face, at random with the given density.
+func life3DSoup(rng *rand.Rand, size int, density float64) []uint32 {
+	cells := make([]uint32, size*size*size)
+	for z := size / 3; z < size*2/3; z++ {
+		for y := size / 3; y < size*2/3; y++ {
+			for x := size / 3; x < size*2/3; x++ {
+				if rng.Float64() < density {
+					cells[(z*size+y)*size+x] = 1
+				}
+			}
+		}
+	}
+	return cells
+}
+
+func (l *Life3D) Population(s *State) (float64, error) {
+	return liveCells(s, l.cellStateStorage[l.steps%2])
+}
+
+func (l *Life3D) Step(encoder *commandEncoder) {
+	l.camera.advance()
+
+	computePass := encoder.BeginComputePass(nil)
+	defer computePass.Release()
+
+	n := uint32(l.size+3) / 4
+	computePass.SetPipeline(l.simulationPipeline)
+	computePass.SetBindGroup(0, l.gridBindGroups[l.steps%2], nil)
+	computePass.DispatchWorkgroups(n, n, n)
+	computePass.End()
+
+	l.steps += 1
+}
+
+func (l *Life3D) Draw(pass *renderPass) {
+	pass.SetPipeline(l.pipeline)
+	pass.SetBindGroup(0, l.gridBindGroups[l.steps%2], nil)
+	pass.SetVertexBuffer(0, l.vertices, 0, wgpu.WholeSize)
+	pass.Draw(6, 1, 0, 0)
+}
+
+func (l *Life3D) Release() {
+	for _, bg := range l.gridBindGroups {
+		bg.Release()
+	}
+	l.gridBindGroups = nil
+	for _, b := range l.cellStateStorage {
+		b.Release()
+	}
+	l.cellStateStorage = nil
+	if l.camera != nil {
+		l.camera.Release()
+		l.camera = nil
+	}
+	if l.params != nil {
+		l.params.Release()
+		l.params = nil
+	}
+	if l.simulationPipeline != nil {
+		l.simulationPipeline.Release()
+		l.simulationPipeline = nil
+	}
+	if l.pipeline != nil {
+		l.pipeline.Release()
+		l.pipeline = nil
+	}
+	if l.pipelineLayout != nil {
+		l.pipelineLayout.Release()
+		l.pipelineLayout = nil
+	}
+	if l.bindGroupLayout != nil {
+		l.bindGroupLayout.Release()
+		l.bindGroupLayout = nil
+	}
+}
@@ -0,0 +1,47 @@
+struct Params {
+  // Bays' notation: survive with rule.x to rule.y neighbours, be born with
+  // rule.z to rule.w.
+  rule: vec4<u32>,
+  size: u32,
+};
+
+@group(0) @binding(0) var<uniform> params: Params;
+@group(0) @binding(2) var<storage> cellStateIn: array<u32>;
+@group(0) @binding(3) var<storage, read_write> cellStateOut: array<u32>;
+
+@compute
+@workgroup_size(4, 4, 4)
+fn main(@builtin(global_invocation_id) id: vec3<u32>) {
+  let size = i32(params.size);
+  let cell = vec3<i32>(id);
+  if any(cell >= vec3(size)) {
+    return;
+  }
+
+  // The 26 cells of the Moore neighbourhood, wrapping around every face.
+  var activeNeighbors = 0u;
+  for (var z = -1; z <= 1; z++) {
+    for (var y = -1; y <= 1; y++) {
+      for (var x = -1; x <= 1; x++) {
+        if x == 0 && y == 0 && z == 0 {
+          continue;
+        }
+        let n = (cell + vec3(x, y, z) + size) % size;
+        activeNeighbors += cellStateIn[cellIndex(n)];
+      }
+    }
+  }
+
+  let i = cellIndex(cell);
+  let r = params.rule;
+  if cellStateIn[i] == 1u {
+    cellStateOut[i] = u32(activeNeighbors >= r.x && activeNeighbors <= r.y);
+  } else {
+    cellStateOut[i] = u32(activeNeighbors >= r.z && activeNeighbors <= r.w);
+  }
+}
+
+fn cellIndex(cell: vec3<i32>) -> u32 {
+  let size = i32(params.size);
+  return u32((cell.z * size + cell.y) * size + cell.x);
+}
@@ -0,0 +1,91 @@
+struct Params {
+  rule: vec4<u32>,
+  size: u32,
+};
+
+struct Camera {
+  angle: f32,
+  aspect: f32,
+};
+
+struct VertexOutput {
+  @builtin(position) pos: vec4<f32>,
+  @location(0) uv: vec2<f32>,
+};
+
+@group(0) @binding(0) var<uniform> params: Params;
+@group(0) @binding(1) var<uniform> camera: Camera;
+@group(0) @binding(2) var<storage> cellStateIn: array<u32>;
+
+// The cell tile is stretched over the whole window and every pixel marches
+// a ray through the volume, which spans [-1, 1] on each axis.
+@vertex
+fn main_vs(@location(0) pos: vec2<f32>) -> VertexOutput {
+  var output: VertexOutput;
+  output.uv = pos / 0.8;
+  output.pos = vec4<f32>(output.uv, 0.0, 1.0);
+  return output;
+}
+
+@fragment
+fn main_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  let eye = vec3(sin(camera.angle), 0.6, cos(camera.angle)) * 3.0;
+  let forward = normalize(-eye);
+  let right = normalize(cross(forward, vec3(0.0, 1.0, 0.0)));
+  let up = cross(right, forward);
+  let uv = input.uv * vec2(camera.aspect, 1.0);
+  var dir = normalize(forward * 2.0 + right * uv.x + up * uv.y);
+  dir = select(dir, vec3(1e-6), abs(dir) < vec3(1e-6));
+  let inv = 1.0 / dir;
+
+  let t0 = (vec3(-1.0) - eye) * inv;
+  let t1 = (vec3(1.0) - eye) * inv;
+  let near = min(t0, t1);
+  let tEnter = max(max(near.x, near.y), near.z);
+  let far = max(t0, t1);
+  let tExit = min(min(far.x, far.y), far.z);
+  if tExit < max(tEnter, 0.0) {
+    return background();
+  }
+
+  // Walk the voxels the ray passes through (Amanatides and Woo), starting
+  // from where it enters the volume.
+  let size = i32(params.size);
+  let scale = f32(size) / 2.0;
+  let start = eye + dir * (max(tEnter, 0.0) + 1e-4);
+  var voxel = clamp(vec3<i32>(floor((start + 1.0) * scale)), vec3(0), vec3(size - 1));
+  let step = vec3<i32>(sign(dir));
+  let tDelta = abs(inv) / scale;
+  let bound = (vec3<f32>(voxel) + select(vec3(0.0), vec3(1.0), dir > vec3(0.0))) / scale - 1.0;
+  var tMax = (bound - eye) * inv;
+  var normal = select(vec3(0.0), -sign(dir), near == vec3(tEnter));
+
+  for (var i = 0; i < 3 * size; i++) {
+    if any(voxel < vec3(0)) || any(voxel >= vec3(size)) {
+      break;
+    }
+    if cellStateIn[u32((voxel.z * size + voxel.y) * size + voxel.x)] == 1u {
+      let light = 0.35 + 0.65 * max(dot(normal, normalize(vec3(0.4, 0.8, 0.3))), 0.0);
+      let colour = mix(vec3(0.2, 0.1, 0.5), vec3(1.0, 0.95, 0.6), vec3<f32>(voxel) / f32(size));
+      return vec4<f32>(colour * light, 1.0);
+    }
+    if tMax.x < tMax.y && tMax.x < tMax.z {
+      voxel.x += step.x;
+      tMax.x += tDelta.x;
+      normal = vec3(-f32(step.x), 0.0, 0.0);
+    } else if tMax.y < tMax.z {
+      voxel.y += step.y;
+      tMax.y += tDelta.y;
+      normal = vec3(0.0, -f32(step.y), 0.0);
+    } else {
+      voxel.z += step.z;
+      tMax.z += tDelta.z;
+      normal = vec3(0.0, 0.0, -f32(step.z));
+    }
+  }
+  return background();
+}
+
+fn background() -> vec4<f32> {
+  return vec4<f32>(0.0, 0.01, 0.05, 1.0);
+}
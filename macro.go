@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// macroKeys are the keys macros are played with. F1 is the help's.
+var macroKeys = []glfw.Key{
+	glfw.KeyF2, glfw.KeyF3, glfw.KeyF4, glfw.KeyF5,
+	glfw.KeyF6, glfw.KeyF7, glfw.KeyF8, glfw.KeyF9, glfw.KeyF10,
+}
+
+// macroNumber is the Key of the macro k plays, 2 for F2.
+func macroNumber(k glfw.Key) int {
+	return int(k-glfw.KeyF1) + 1
+}
+
+// macro is a named run of commands and parameter changes, saved in the
+// store as macros/<name>.json and played with F2 to F10.
+type macro struct {
+	Name string `json:"name"`
+	// Key is which function key plays it, 2 for F2.
+	Key   int         `json:"key"`
+	Steps []macroStep `json:"steps"`
+}
+
+// macroStep is one command, or one parameter set to Value, Time into the
+// macro.
+type macroStep struct {
+	Time      time.Duration `json:"t"`
+	Command   string        `json:"command,omitempty"`
+	Parameter string        `json:"parameter,omitempty"`
+	Value     float64       `json:"value,omitempty"`
+}
+
+// macros records what is done between two presses of M, from the keys, the
+// command palette or the dashboard, and plays saved macros back at the pace
+// they were recorded. Playing goes through RunOnMainThread a step at a
+// time, so frames carry on in between.
+type macros struct {
+	saved     []*macro
+	recording *macro
+	started   time.Time
+	playing   atomic.Bool
+}
+
+func (s *State) loadMacros() *macros {
+	m := &macros{}
+	ctx := context.Background()
+	keys, err := s.store.List(ctx, "macros/")
+	if err != nil {
+		log.Println("listing macros:", err)
+		return m
+	}
+	for _, key := range keys {
+		b, err := s.store.Get(ctx, key)
+		if err != nil {
+			log.Println("loading macro:", err)
+			continue
+		}
+		var mc macro
+		if err := json.Unmarshal(b, &mc); err != nil {
+			log.Printf("loading macro %s: %v", key, err)
+			continue
+		}
+		if mc.Key == 1 {
+			log.Printf("macro %s was saved on F1, which shows the help now; the command palette still plays it", mc.Name)
+		}
+		m.saved = append(m.saved, &mc)
+	}
+	return m
+}
+
+// record adds a step to the macro being recorded, if there is one.
+func (m *macros) record(step macroStep) {
+	if m == nil || m.recording == nil {
+		return
+	}
+	step.Time = time.Since(m.started)
+	m.recording.Steps = append(m.recording.Steps, step)
+}
+
+// recordKey records a key pressed in normal mode as the command it runs,
+// apart from the M that stops the recording.
+func (m *macros) recordKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if m == nil || m.recording == nil || action != glfw.Press || key == glfw.KeyM {
+		return
+	}
+	for _, c := range commands {
+		if c.key == key && c.mods == mods&glfw.ModShift {
+			m.record(macroStep{Command: c.name})
+			return
+		}
+	}
+}
+
+func (m *macros) bound(key int) *macro {
+	for _, mc := range m.saved {
+		if mc.Key == key {
+			return mc
+		}
+	}
+	return nil
+}
+
+// handleMacroKey starts and stops recording with M, and plays the macro
+// bound to F2 to F10.
+func (s *State) handleMacroKey(key glfw.Key, action glfw.Action) {
+	m := s.macros
+	if m == nil || action != glfw.Press {
+		return
+	}
+	if key == glfw.KeyM {
+		if m.recording == nil {
+			m.recording, m.started = &macro{}, time.Now()
+			s.showPrompt("Recording a macro (M stops)")
+			return
+		}
+		recorded := m.recording
+		m.recording = nil
+		if len(recorded.Steps) == 0 {
+			s.showPrompt("")
+			fmt.Println("nothing recorded")
+			return
+		}
+		s.setMode(&macroNaming{prev: s.mode, macro: recorded})
+		return
+	}
+	for _, k := range macroKeys {
+		if k == key {
+			s.playMacro(m.bound(macroNumber(k)))
+			return
+		}
+	}
+}
+
+// playMacro plays mc back in the background, unless another is playing.
+func (s *State) playMacro(mc *macro) {
+	if mc == nil || !s.macros.playing.CompareAndSwap(false, true) {
+		return
+	}
+	fmt.Printf("playing macro %s\n", mc.Name)
+	go func() {
+		defer s.macros.playing.Store(false)
+		start := time.Now()
+		for _, step := range mc.Steps {
+			time.Sleep(time.Until(start.Add(step.Time)))
+			var err error
+			if perr := s.RunOnMainThread(func() { err = s.runMacroStep(step) }); perr != nil {
+				return
+			}
+			if err != nil {
+				log.Printf("macro %s: %v", mc.Name, err)
+			}
+		}
+	}()
+}
+
+func (s *State) runMacroStep(step macroStep) error {
+	if step.Parameter != "" {
+		return s.setParameter(step.Parameter, step.Value)
+	}
+	for _, c := range commands {
+		if c.name == step.Command {
+			c.run(s)
+			return nil
+		}
+	}
+	return fmt.Errorf("no command %q", step.Command)
+}
+
+// saveMacro stores mc under its name, taking its key from any macro that
+// had it.
+func (s *State) saveMacro(mc *macro) error {
+	ctx := context.Background()
+	b, err := json.MarshalIndent(mc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := s.store.Put(ctx, macroKey(mc.Name), b); err != nil {
+		return err
+	}
+	saved := []*macro{mc}
+	for _, old := range s.macros.saved {
+		switch {
+		case old.Name == mc.Name:
+		case old.Key == mc.Key:
+			if err := s.store.Delete(ctx, macroKey(old.Name)); err != nil {
+				return err
+			}
+		default:
+			saved = append(saved, old)
+		}
+	}
+	s.macros.saved = saved
+	return nil
+}
+
+func macroKey(name string) string {
+	return path.Join("macros", name+".json")
+}
+
+// macroNaming is the mode a macro is named and bound to a key in, after it
+// has been recorded: typing names it, F2 to F10 saves it to be played with
+// that key and Esc throws it away.
+type macroNaming struct {
+	prev  Mode
+	macro *macro
+	name  string
+}
+
+func (n *macroNaming) Enter(s *State) { n.show(s) }
+func (n *macroNaming) Exit(s *State)  {}
+
+func (n *macroNaming) show(s *State) {
+	s.prompt = fmt.Sprintf("Macro name: %s_ (F2-F10 saves it to play with that key, Esc discards)", n.name)
+	s.updateTitle()
+}
+
+func (n *macroNaming) HandleKey(s *State, key glfw.Key, action glfw.Action, mods glfw.ModifierKey) bool {
+	if action == glfw.Release {
+		return true
+	}
+	switch key {
+	case glfw.KeyEscape:
+		s.setMode(n.prev)
+		fmt.Println("macro discarded")
+		return true
+	case glfw.KeyBackspace:
+		if r := []rune(n.name); len(r) > 0 {
+			n.name = string(r[:len(r)-1])
+			n.show(s)
+		}
+		return true
+	}
+	for _, k := range macroKeys {
+		if k != key {
+			continue
+		}
+		name := strings.TrimSpace(n.name)
+		if name == "" || strings.ContainsAny(name, `/\`) {
+			s.showPrompt("Macro names can't be empty or have slashes in them")
+			return true
+		}
+		n.macro.Name, n.macro.Key = name, macroNumber(k)
+		s.setMode(n.prev)
+		if err := s.saveMacro(n.macro); err != nil {
+			log.Println("saving macro:", err)
+			return true
+		}
+		fmt.Printf("saved macro %s, F%d plays it\n", name, macroNumber(k))
+		return true
+	}
+	return true
+}
+
+func (n *macroNaming) HandleChar(s *State, char rune) {
+	n.name += string(char)
+	n.show(s)
+}
+
+func (n *macroNaming) OnAction(s *State, action string) {}
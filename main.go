@@ -9,6 +9,7 @@ import (
 	"math/rand"
 	"os"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/go-gl/glfw/v3.3/glfw"
@@ -16,28 +17,99 @@ import (
 	wgpuext_glfw "github.com/rajveermalviya/go-webgpu/wgpuext/glfw"
 )
 
-const GRID_SIZE = 128 // creates a GRID_SIZE x GRID_SIZE grid
-
 type State struct {
 	window    *glfw.Window
 	instance  *wgpu.Instance
 	adapter   *wgpu.Adapter
 	device    *wgpu.Device
 	surface   *wgpu.Surface
-	queue     *wgpu.Queue
+	queue     *uploadQueue
 	swapChain *wgpu.SwapChain
 	config    *wgpu.SwapChainDescriptor
-
-	pipeline           *wgpu.RenderPipeline
-	simulationPipeline *wgpu.ComputePipeline
-
-	vertexBuffer   *wgpu.Buffer
-	gridBuffer     *wgpu.Buffer
-	vertices       []float32
-	grid           []float32
-	gridBindGroups []*wgpu.BindGroup
-	cellStates     [][]uint32
-	steps          int
+	// config.Format is what everything is drawn in and surfaceFormat what
+	// the swap chain presents, which differ when output encodes to sRGB.
+	surfaceFormat  wgpu.TextureFormat
+	surfaceFormats []wgpu.TextureFormat
+	// contentScale is how many framebuffer pixels there are to a screen
+	// coordinate, 2 on most high-DPI displays. config is in framebuffer
+	// pixels and the cursor in screen coordinates.
+	contentScale float32
+	// windowed is where the window was and how big, x, y, width and
+	// height, before it went fullscreen.
+	windowed [4]int
+	// stats counts what is recorded until the end of the frame, when it
+	// moves to lastStats.
+	stats     *frameStats
+	lastStats frameStats
+
+	vertexBuffer *wgpu.Buffer
+	gridBuffer   *wgpu.Buffer
+	// paletteBuffer is the uniform cell shaders colour by, from palette.
+	paletteBuffer *wgpu.Buffer
+	gridLines     *gridLines
+	minimap       *minimap
+	// sampleCount is how many samples a pixel the simulations are drawn
+	// with, into multisample's textures when it is above 1.
+	sampleCount uint32
+	multisample *multisampleTargets
+	// cameraLayout is group 1 of the pipelines that draw through camera.
+	cameraLayout *wgpu.BindGroupLayout
+	camera       *viewCamera
+	palette      palette
+	vertices     []float32
+	grid         []float32
+	gridWidth    int
+	gridHeight   int
+
+	cfg        *Config
+	sim        Simulation
+	steps      int
+	mainThread *mainThread
+	input      *inputLog
+	store      Store
+	manifest   *Manifest
+	mode       Mode
+	prompt     string
+	showStats  bool
+	filter     *accessibilityFilter
+	output     *outputPass
+	post       *postChain
+	scene      *sceneTarget
+	hud        *hud
+	ui         *debugUI
+	frameGraph *frameGraph
+	selection  *selection
+	hover      *hover
+	help       *help
+	stamper    *stamper
+	recording  *recording
+	gif        *gifCapture
+	// saving counts the screenshots and GIFs still being written.
+	saving sync.WaitGroup
+	// tickRate is how many generations a second the simulation runs, and
+	// ticker how many each frame does to keep to it.
+	tickRate float64
+	ticker   ticker
+	// nextFrame is when the next frame is due, while waitEvents paces them.
+	nextFrame time.Time
+	edits     cellEdits
+	history   editHistory
+	// paused keeps the simulation where it is, still drawing it.
+	paused     bool
+	away       away
+	highlights *highlighter
+	timeline   *timeline
+	dashboard  *dashboard
+	parameters *parameterRegistry
+	session    *session
+	macros     *macros
+	bindings   *keyBindings
+	gamepad    gamepad
+	title      titleCounts
+	format     numberFormat
+	rand       *rand.Rand
+	seed       int64
+	start      time.Time
 }
 
 func init() {
@@ -45,40 +117,117 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "get" {
+		if err := runGet(os.Args[2:]); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "snapshots" {
+		if err := runSnapshots(os.Args[2:]); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	cfg, err := loadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if cfg.Timelapse.Minutes > 0 {
+		if err := runTimelapse(cfg); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+	if cfg.GIF.Path != "" {
+		if err := runGIF(cfg); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
 	if err := glfw.Init(); err != nil {
 		panic(err)
 	}
 	defer glfw.Terminate()
 
 	glfw.WindowHint(glfw.ClientAPI, glfw.NoAPI)
-	window, err := glfw.CreateWindow(640, 480, "Testing", nil, nil)
+	window, err := glfw.CreateWindow(640, 480, windowTitle, nil, nil)
 	if err != nil {
 		panic(err)
 	}
 	defer window.Destroy()
 
-	s, err := InitState(window)
+	s, err := InitState(window, cfg)
 	if err != nil {
 		panic(err)
 	}
 	defer s.Destroy()
 
-	window.SetSizeCallback(func(w *glfw.Window, width, height int) {
+	// The swap chain is as big as the framebuffer, which on high-DPI
+	// displays has more pixels than the window has screen coordinates.
+	window.SetFramebufferSizeCallback(func(w *glfw.Window, width, height int) {
 		s.Resize(width, height)
 	})
+	window.SetContentScaleCallback(func(w *glfw.Window, x, y float32) {
+		s.contentScale = x
+		s.post.setScale(s)
+	})
+	window.SetFocusCallback(func(w *glfw.Window, focused bool) {
+		s.setAway(!focused, s.away.iconified)
+	})
+	window.SetIconifyCallback(func(w *glfw.Window, iconified bool) {
+		s.setAway(s.away.unfocused, iconified)
+	})
 
 	window.SetKeyCallback(func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
-		// Print resource usage on pressing 'R'
-		if key == glfw.KeyR && (action == glfw.Press || action == glfw.Repeat) {
-			report := s.instance.GenerateReport()
-			buf, _ := json.MarshalIndent(report, "", "  ")
-			fmt.Print(string(buf))
+		s.input.record(InputEvent{Kind: "key", Key: key, Action: action, Mods: mods}, s.steps)
+		s.handleKey(key, action, mods)
+	})
+	window.SetCharCallback(func(w *glfw.Window, char rune) {
+		if t, ok := s.mode.(textMode); ok {
+			t.HandleChar(s, char)
+		}
+	})
+
+	window.SetMouseButtonCallback(func(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+		s.input.record(InputEvent{Kind: "mouse", Button: button, Action: action, Mods: mods}, s.steps)
+		if s.ui.capturesMouse() {
+			return
+		}
+		s.handleMouse(w)
+		s.handleEditMouse(w)
+		s.handleCameraMouse(w)
+	})
+
+	window.SetCursorPosCallback(func(w *glfw.Window, x, y float64) {
+		s.input.record(InputEvent{Kind: "cursor", X: x, Y: y}, s.steps)
+		if s.ui.capturesMouse() {
+			return
 		}
+		s.handleMouse(w)
+		s.handleEditMouse(w)
+		s.handleCameraMouse(w)
+	})
+
+	window.SetScrollCallback(func(w *glfw.Window, x, y float64) {
+		s.input.record(InputEvent{Kind: "scroll", X: x, Y: y}, s.steps)
+		if s.ui.capturesMouse() {
+			s.ui.scroll(y)
+			return
+		}
+		if s.handleBrushScroll(w, y) || s.handleTrackpadScroll(w, x, y) {
+			return
+		}
+		s.handleCameraScroll(w, y)
 	})
 
 	for !window.ShouldClose() {
-		time.Sleep(100 * time.Millisecond)
-		glfw.PollEvents()
+		s.waitEvents()
+		s.mainThread.runQueued()
+		s.pollGamepad()
 
 		if err := s.Render(); err != nil {
 			fmt.Println("error occured while rendering:", err)
@@ -95,12 +244,6 @@ func main() {
 
 var forceFallbackAdapter = os.Getenv("WGPU_FORCE_FALLBACK_ADAPTER") == "1"
 
-//go:embed draw.wgsl
-var draw string
-
-//go:embed compute.wgsl
-var compute string
-
 func (s *State) setSurface() {
 	instance := wgpu.CreateInstance(nil)
 	s.instance = instance
@@ -116,33 +259,52 @@ func (s *State) setDevice() {
 		log.Fatalln(err)
 	}
 	s.adapter = adapter
-	s.device, err = adapter.RequestDevice(nil)
+	// Multisampling other than 4 times needs the adapter's own format
+	// features, asked for whenever it has them.
+	var features []wgpu.FeatureName
+	if adapter.HasFeature(wgpu.NativeFeature_TextureAdapterSpecificFormatFeatures) {
+		features = append(features, wgpu.NativeFeature_TextureAdapterSpecificFormatFeatures)
+	}
+	s.device, err = adapter.RequestDevice(&wgpu.DeviceDescriptor{RequiredFeatures: features})
 	if err != nil {
 		log.Fatalln(err)
 	}
-	s.queue = s.device.GetQueue()
+	s.stats = &frameStats{}
+	s.queue = &uploadQueue{s.device.GetQueue(), s.stats}
 }
 
-func (s *State) setSwapChain() {
+func (s *State) setSwapChain(hdr bool) {
 	caps := s.surface.GetCapabilities(s.adapter)
-	width, height := s.window.GetSize()
+	width, height := s.window.GetFramebufferSize()
+	s.contentScale, _ = s.window.GetContentScale()
 
+	s.surfaceFormats = caps.Formats
+	var format wgpu.TextureFormat
+	s.surfaceFormat, format = chooseSurfaceFormat(caps.Formats, hdr)
 	s.config = &wgpu.SwapChainDescriptor{
 		Usage:       wgpu.TextureUsage_RenderAttachment,
-		Format:      caps.Formats[0],
+		Format:      format,
 		Width:       uint32(width),
 		Height:      uint32(height),
 		PresentMode: wgpu.PresentMode_Fifo,
 		AlphaMode:   caps.AlphaModes[0],
 	}
 
-	sc, err := s.device.CreateSwapChain(s.surface, s.config)
+	sc, err := s.createSwapChain()
 	if err != nil {
 		log.Fatalln(err)
 	}
 	s.swapChain = sc
 }
 
+// createSwapChain creates a swap chain as s.config describes, presenting in
+// surfaceFormat.
+func (s *State) createSwapChain() (*wgpu.SwapChain, error) {
+	config := *s.config
+	config.Format = s.surfaceFormat
+	return s.device.CreateSwapChain(s.surface, &config)
+}
+
 func (s *State) initVertexBuffer() {
 	s.vertices = []float32{
 		// X, Y,
@@ -167,8 +329,9 @@ func (s *State) initVertexBuffer() {
 	}
 }
 
-func (s *State) initGridBuffer() {
-	s.grid = []float32{GRID_SIZE, GRID_SIZE}
+func (s *State) initGridBuffer(width, height int) {
+	s.gridWidth, s.gridHeight = width, height
+	s.grid = []float32{float32(width), float32(height)}
 	gridBuffer, err := s.device.CreateBufferInit(&wgpu.BufferInitDescriptor{
 		Label:    "grid",
 		Contents: wgpu.ToBytes(s.grid[:]),
@@ -196,7 +359,7 @@ func (s *State) createShader(label, code string) *wgpu.ShaderModule {
 	return shader
 }
 
-func InitState(window *glfw.Window) (s *State, err error) {
+func InitState(window *glfw.Window, cfg *Config) (s *State, err error) {
 	defer func() {
 		if err != nil {
 			s.Destroy()
@@ -204,170 +367,203 @@ func InitState(window *glfw.Window) (s *State, err error) {
 		}
 	}()
 	s = &State{
-		window: window,
+		window:     window,
+		mainThread: newMainThread(),
+		start:      time.Now(),
+		tickRate:   cfg.TickRate,
 	}
 	s.setSurface()
 	s.setDevice()
-	s.setSwapChain()
-	s.initVertexBuffer()
-	s.initGridBuffer()
-
-	drawShader := s.createShader("render shader", draw)
-	defer drawShader.Release()
-
-	computeShader := s.createShader("compute shader", compute)
-	defer computeShader.Release()
-
-	vertexBufferLayout := []wgpu.VertexBufferLayout{
-		{
-			ArrayStride: 8,
-			StepMode:    wgpu.VertexStepMode_Vertex,
-			Attributes: []wgpu.VertexAttribute{
-				{
-					Format:         wgpu.VertexFormat_Float32x2,
-					Offset:         0,
-					ShaderLocation: 0,
-				},
-			},
-		},
+	s.setSwapChain(cfg.HDR.Enabled)
+	if cfg.HDR.Enabled || s.surfaceFormat != s.config.Format {
+		if s.output, err = newOutputPass(s, cfg.HDR); err != nil {
+			return s, err
+		}
 	}
 
-	bindGroupLayout, err := s.device.CreateBindGroupLayout(&wgpu.BindGroupLayoutDescriptor{
-		Label: "bind group layouts",
-		Entries: []wgpu.BindGroupLayoutEntry{
-			{
-				Binding:    0,
-				Visibility: wgpu.ShaderStage_Vertex | wgpu.ShaderStage_Compute | wgpu.ShaderStage_Fragment,
-				Buffer: wgpu.BufferBindingLayout{
-					Type: wgpu.BufferBindingType_Uniform,
-				},
-			},
-			{
-				Binding:    1,
-				Visibility: wgpu.ShaderStage_Vertex | wgpu.ShaderStage_Compute,
-				Buffer: wgpu.BufferBindingLayout{
-					Type: wgpu.BufferBindingType_ReadOnlyStorage,
-				},
-			},
-			{
-				Binding:    2,
-				Visibility: wgpu.ShaderStage_Compute,
-				Buffer: wgpu.BufferBindingLayout{
-					Type: wgpu.BufferBindingType_Storage,
-				},
-			},
-		},
-	})
+	s.input, err = newInputLog(cfg.RecordInput)
 	if err != nil {
 		return s, err
 	}
 
-	renderPipelineLayout, err := s.device.CreatePipelineLayout(&wgpu.PipelineLayoutDescriptor{
-		Label: "Render Pipeline Layout",
-		BindGroupLayouts: []*wgpu.BindGroupLayout{
-			bindGroupLayout,
-		},
-	})
-	if err != nil {
+	if err := s.init(cfg); err != nil {
+		return s, err
+	}
+	if s.hud, err = newHUD(s, cfg.HUD); err != nil {
+		return s, err
+	}
+	if s.ui, err = newDebugUI(s); err != nil {
+		return s, err
+	}
+	if s.frameGraph, err = newFrameGraph(s); err != nil {
+		return s, err
+	}
+	s.frameGraph.shown = cfg.FrameGraph
+	if s.selection, err = newSelection(s); err != nil {
+		return s, err
+	}
+	if s.hover, err = newHover(s); err != nil {
+		return s, err
+	}
+	if s.help, err = newHelp(s); err != nil {
+		return s, err
+	}
+	if s.stamper, err = newStamper(cfg); err != nil {
 		return s, err
 	}
-	// defer renderPipelineLayout.Release()
 
-	pipeline, err := s.device.CreateRenderPipeline(&wgpu.RenderPipelineDescriptor{
-		Label:  "Render Pipeline",
-		Layout: renderPipelineLayout,
-		Vertex: wgpu.VertexState{
-			Module:     drawShader,
-			EntryPoint: "main_vs",
-			Buffers:    vertexBufferLayout,
-		},
-		Fragment: &wgpu.FragmentState{
-			Module:     drawShader,
-			EntryPoint: "main_fs",
-			Targets: []wgpu.ColorTargetState{
-				{
-					Format:    s.config.Format,
-					Blend:     nil,
-					WriteMask: wgpu.ColorWriteMask_All,
-				},
-			},
-		},
-		Primitive: wgpu.PrimitiveState{
-			Topology:  wgpu.PrimitiveTopology_TriangleList,
-			FrontFace: wgpu.FrontFace_CCW,
-			CullMode:  wgpu.CullMode_Back,
-		},
-		Multisample: wgpu.MultisampleState{
-			Count:                  1,
-			Mask:                   0xFFFFFFFF,
-			AlphaToCoverageEnabled: false,
-		},
-	})
+	if cfg.Tutorial {
+		s.setMode(&tutorialMode{})
+	} else {
+		s.setMode(normalMode{})
+	}
+	return s, nil
+}
+
+// init creates everything past the device and s.config that does not need a
+// window.
+func (s *State) init(cfg *Config) (err error) {
+	s.cfg = cfg
+	s.format, err = newNumberFormat(cfg.Locale)
 	if err != nil {
-		return s, err
+		return err
+	}
+	if err := s.initMultisample(cfg.MSAA); err != nil {
+		return err
+	}
+	s.initVertexBuffer()
+	s.initGridBuffer(cfg.Grid.Width, cfg.Grid.Height)
+	if err := s.initPalette(cfg); err != nil {
+		return err
+	}
+	if err := s.initCamera(); err != nil {
+		return err
+	}
+	s.gridLines, err = newGridLines(s, cfg.GridLines)
+	if err != nil {
+		return err
+	}
+	s.minimap, err = newMinimap(s)
+	if err != nil {
+		return err
 	}
 
-	s.pipeline = pipeline
+	s.store, err = openStore(cfg.Storage)
+	if err != nil {
+		return err
+	}
+	s.macros = s.loadMacros()
+	s.bindings, err = newKeyBindings(cfg.Keys)
+	if err != nil {
+		return err
+	}
 
-	s.cellStates = [][]uint32{
-		make([]uint32, GRID_SIZE*GRID_SIZE),
-		make([]uint32, GRID_SIZE*GRID_SIZE),
+	// Everything random about a run comes from s.rand, so the same seed
+	// gives the same starting soup.
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
 	}
+	s.rand = rand.New(rand.NewSource(seed))
+	s.seed = seed
+	fmt.Println("random seed", seed)
 
-	for i := range s.cellStates[0] {
-		r := rand.Float32()
-		if r > 0.7 {
-			s.cellStates[0][i] = 1
-			s.cellStates[1][i] = 1
+	if cfg.Manifest {
+		s.manifest = newManifest(cfg, s.adapter, s.store)
+		s.manifest.Seed = &seed
+		if err := s.manifest.save(); err != nil {
+			log.Println("writing run manifest:", err)
 		}
 	}
 
-	cellStateStorage := []*wgpu.Buffer{
-		s.storageBuffer(wgpu.ToBytes(s.cellStates[0])),
-		s.storageBuffer(wgpu.ToBytes(s.cellStates[1])),
+	s.sim, err = s.startSimulation(cfg)
+	if err != nil {
+		return err
+	}
+	s.timeline = newTimeline(cfg.KeyframeEvery)
+	if cfg.Snapshot != "" {
+		if err := s.loadSnapshot(cfg.Snapshot); err != nil {
+			return err
+		}
+		fmt.Printf("carrying on from snapshot %s at generation %d\n", cfg.Snapshot, s.steps)
+	}
+	s.timeline.restart(s)
+	if cfg.Validate > 0 {
+		c, ok := s.sim.(referenceChecker)
+		if !ok {
+			return fmt.Errorf("%s has no CPU reference to validate against", cfg.Simulation)
+		}
+		diff, err := c.CheckReference(s, cfg.Validate)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("after %d steps the GPU is at most %g from the CPU reference\n", cfg.Validate, diff)
+	}
+	if cfg.Skip > 0 {
+		if err := s.advanceBy(cfg.Skip); err != nil {
+			return err
+		}
 	}
 
-	s.gridBindGroups = []*wgpu.BindGroup{
-		s.bindGroup("cell renderer A", bindGroupLayout, s.gridBuffer, cellStateStorage[0], cellStateStorage[1]),
-		s.bindGroup("cell renderer B", bindGroupLayout, s.gridBuffer, cellStateStorage[1], cellStateStorage[0]),
+	if cfg.Accessibility.enabled() {
+		s.filter, err = newAccessibilityFilter(s, cfg.Accessibility)
+		if err != nil {
+			return err
+		}
 	}
 
-	computePipeline, err := s.device.CreateComputePipeline(&wgpu.ComputePipelineDescriptor{
-		Label:  "compute",
-		Layout: renderPipelineLayout,
-		Compute: wgpu.ProgrammableStageDescriptor{
-			Module:     computeShader,
-			EntryPoint: "main",
-		},
-	})
+	s.post, err = newPostChain(s, cfg.Post)
 	if err != nil {
-		return s, err
+		return err
+	}
+	s.scene, err = newSceneTarget(s)
+	if err != nil {
+		return err
 	}
-	s.simulationPipeline = computePipeline
-	return s, err
+
+	if cfg.Highlights.Dir != "" {
+		s.highlights, err = newHighlighter(s, cfg.Highlights)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.parameters = newParameterRegistry()
+	s.parameters.refresh(s)
+	s.session, err = newSession(cfg.Session)
+	if err != nil {
+		return err
+	}
+	if cfg.HTTP != "" {
+		s.dashboard, err = startDashboard(s, cfg.HTTP)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *State) bindGroup(label string, l *wgpu.BindGroupLayout, buffers ...*wgpu.Buffer) *wgpu.BindGroup {
+	return s.textureBindGroup(label, l, buffers)
 }
 
-func (s *State) bindGroup(label string, l *wgpu.BindGroupLayout, x, y, w *wgpu.Buffer) *wgpu.BindGroup {
+// textureBindGroup is bindGroup with texture views bound after the buffers.
+func (s *State) textureBindGroup(label string, l *wgpu.BindGroupLayout, buffers []*wgpu.Buffer, views ...*wgpu.TextureView) *wgpu.BindGroup {
+	entries := make([]wgpu.BindGroupEntry, len(buffers), len(buffers)+len(views))
+	for i, buf := range buffers {
+		entries[i] = wgpu.BindGroupEntry{
+			Binding: uint32(i),
+			Buffer:  buf,
+			Size:    wgpu.WholeSize,
+		}
+	}
+	for _, view := range views {
+		entries = append(entries, wgpu.BindGroupEntry{Binding: uint32(len(entries)), TextureView: view})
+	}
 	b, err := s.device.CreateBindGroup(&wgpu.BindGroupDescriptor{
-		Layout: l,
-		Label:  label,
-		Entries: []wgpu.BindGroupEntry{
-			{
-				Binding: 0,
-				Buffer:  x,
-				Size:    wgpu.WholeSize,
-			},
-			{
-				Binding: 1,
-				Buffer:  y,
-				Size:    wgpu.WholeSize,
-			},
-			{
-				Binding: 2,
-				Buffer:  w,
-				Size:    wgpu.WholeSize,
-			},
-		},
+		Layout:  l,
+		Label:   label,
+		Entries: entries,
 	})
 	if err != nil {
 		panic(err)
@@ -376,10 +572,24 @@ func (s *State) bindGroup(label string, l *wgpu.BindGroupLayout, x, y, w *wgpu.B
 }
 
 func (s *State) storageBuffer(content []byte) *wgpu.Buffer {
+	s.stats.uploadBytes += uint64(len(content))
 	b, err := s.device.CreateBufferInit(&wgpu.BufferInitDescriptor{
 		Label:    "cells",
 		Contents: content,
-		Usage:    wgpu.BufferUsage_Storage | wgpu.BufferUsage_CopyDst,
+		Usage:    wgpu.BufferUsage_Storage | wgpu.BufferUsage_CopyDst | wgpu.BufferUsage_CopySrc,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func (s *State) uniformBuffer(label string, content []byte) *wgpu.Buffer {
+	s.stats.uploadBytes += uint64(len(content))
+	b, err := s.device.CreateBufferInit(&wgpu.BufferInitDescriptor{
+		Label:    label,
+		Contents: content,
+		Usage:    wgpu.BufferUsage_Uniform | wgpu.BufferUsage_CopyDst,
 	})
 	if err != nil {
 		panic(err)
@@ -396,58 +606,129 @@ func (s *State) Resize(width, height int) {
 			s.swapChain.Release()
 		}
 		var err error
-		s.swapChain, err = s.device.CreateSwapChain(s.surface, s.config)
+		s.swapChain, err = s.createSwapChain()
 		if err != nil {
 			panic(err)
 		}
+		if s.output != nil {
+			if err := s.output.resize(s); err != nil {
+				panic(err)
+			}
+		}
+		if s.filter != nil {
+			if err := s.filter.resize(s); err != nil {
+				panic(err)
+			}
+		}
+		if err := s.post.resize(s); err != nil {
+			panic(err)
+		}
+		if err := s.scene.resize(s); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// scaled turns a length in screen coordinates into framebuffer pixels.
+func (s *State) scaled(length float32) float32 {
+	if s.contentScale <= 0 {
+		return length
 	}
+	return length * s.contentScale
 }
 
-func attachColourToView(view *wgpu.TextureView) wgpu.RenderPassColorAttachment {
+func attachColourToView(view *wgpu.TextureView, clear wgpu.Color) wgpu.RenderPassColorAttachment {
 	return wgpu.RenderPassColorAttachment{
-		View:    view,
-		LoadOp:  wgpu.LoadOp_Clear,
-		StoreOp: wgpu.StoreOp_Store,
-		ClearValue: wgpu.Color{
-			R: 0.0,
-			G: 0.01,
-			B: 0.05,
-			A: 1.0,
-		}}
+		View:       view,
+		LoadOp:     wgpu.LoadOp_Clear,
+		StoreOp:    wgpu.StoreOp_Store,
+		ClearValue: clear,
+	}
+}
+
+// draw records drawing the current generation into view, which must have the
+// format in s.config.
+func (s *State) draw(encoder *commandEncoder, view *wgpu.TextureView) {
+	s.compose(encoder, view, s.stages())
+}
+
+// drawSimulation records the simulation, or the mode's scene, and what is
+// drawn over it into a width by height target.
+func (s *State) drawSimulation(encoder *commandEncoder, target *wgpu.TextureView, width, height uint32) {
+	overlay, _ := s.mode.(overlayMode)
+	if overlay != nil {
+		overlay.RenderOverlay(encoder)
+	}
+	renderPass := encoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
+		ColorAttachments: []wgpu.RenderPassColorAttachment{s.attachScene(target, width, height, s.palette.clear())},
+	})
+	defer renderPass.Release()
+
+	scene, browsing := s.mode.(sceneMode)
+	if browsing {
+		scene.Draw(s, renderPass)
+	} else {
+		s.sim.Draw(renderPass)
+		s.drawGridLines(renderPass)
+	}
+	if overlay != nil {
+		overlay.DrawOverlay(s, renderPass)
+	}
+	renderPass.End()
+	if !browsing {
+		s.drawMinimap(encoder, target, width, height)
+	}
 }
 
 func (s *State) Render() error {
+	s.frameGraph.begin()
 	nextTexture, err := s.swapChain.GetCurrentTextureView()
 	if err != nil {
 		return err
 	}
 	defer nextTexture.Release()
-	commandEncoder, err := s.device.CreateCommandEncoder(nil)
+	commandEncoder, err := s.newEncoder()
 	if err != nil {
 		return err
 	}
 	defer commandEncoder.Release()
 
-	computePass := commandEncoder.BeginComputePass(nil)
-	defer computePass.Release()
-
-	computePass.SetPipeline(s.simulationPipeline)
-	computePass.SetBindGroup(0, s.gridBindGroups[s.steps%2], nil)
-	computePass.DispatchWorkgroups(GRID_SIZE, GRID_SIZE, 1)
-	computePass.End()
-
-	s.steps += 1
-
-	renderPass := commandEncoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
-		ColorAttachments: []wgpu.RenderPassColorAttachment{attachColourToView(nextTexture)},
-	})
-	defer renderPass.Release()
-
-	renderPass.SetPipeline(s.pipeline)
-	renderPass.SetBindGroup(0, s.gridBindGroups[s.steps%2], nil)
-	renderPass.SetVertexBuffer(0, s.vertexBuffer, 0, wgpu.WholeSize)
-	renderPass.Draw(6, GRID_SIZE*GRID_SIZE, 0, 0)
-	renderPass.End()
+	// Cells painted since the last frame go into the generation they were
+	// painted on, before it steps.
+	s.edits.flush(s)
+	scene, browsing := s.mode.(sceneMode)
+	stepped := false
+	if browsing {
+		scene.Step(commandEncoder)
+	} else if ticks := s.ticker.due(s); ticks > 0 {
+		// All but the last tick go ahead of the frame's own submission.
+		if err := s.stepNow(ticks - 1); err != nil {
+			return err
+		}
+		s.step(commandEncoder)
+		stepped = true
+	}
+	if overlay, ok := s.mode.(overlayMode); ok {
+		overlay.StepOverlay(commandEncoder)
+	}
+	if s.camera != nil {
+		s.camera.refit()
+		s.camera.ease()
+	}
+	stages := s.stages()
+	if s.output != nil {
+		stages = append(stages, s.output)
+	}
+	if s.recording.drawing(s) {
+		stages = append(stages, s.recording)
+	}
+	s.compose(commandEncoder, nextTexture, stages)
+	s.selection.draw(s, commandEncoder, nextTexture)
+	s.hover.draw(s, commandEncoder, nextTexture)
+	s.hud.draw(s, commandEncoder, nextTexture)
+	s.ui.draw(s, commandEncoder, nextTexture)
+	s.frameGraph.draw(s, commandEncoder, nextTexture)
+	s.help.draw(s, commandEncoder, nextTexture)
 
 	cmdBuffer, err := commandEncoder.Finish(nil)
 	if err != nil {
@@ -455,13 +736,186 @@ func (s *State) Render() error {
 	}
 	defer cmdBuffer.Release()
 
-	s.queue.Submit(cmdBuffer)
+	submitted := s.queue.Submit(cmdBuffer)
+	s.frameGraph.end(s, submitted)
 	s.swapChain.Present()
+	s.recording.capture(s)
+	s.input.presented()
+	// A generation is only captured and looked at as it goes by.
+	if stepped {
+		s.gif.capture(s)
+		s.highlights.observe(s)
+		s.timeline.observe(s)
+	}
+	s.lastStats, *s.stats = *s.stats, frameStats{}
+	s.parameters.refresh(s)
+	s.session.update(s)
+	s.hover.update(s)
+	s.hud.update(s)
+	s.ui.update(s)
+	s.frameGraph.update(s)
+	s.selection.update(s)
+	s.help.update(s)
+	s.title.update(s)
+	if s.showStats {
+		s.updateTitle()
+	}
 
 	return nil
 }
 
+// handleKey is a key from the window. Where the commands are, in normal mode
+// and the tutorial, the key bindings say which key it stands for.
+func (s *State) handleKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	switch s.mode.(type) {
+	case normalMode, *tutorialMode:
+		var ok bool
+		if key, mods, ok = s.bindings.translate(key, mods); !ok {
+			return
+		}
+	}
+	s.pressKey(key, action, mods)
+}
+
+// pressKey passes a key to the mode, and on to every feature with keys of
+// its own unless the mode takes it, doing what it does by default.
+func (s *State) pressKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if s.mode.HandleKey(s, key, action, mods) {
+		return
+	}
+	s.macros.recordKey(key, action, mods)
+	s.handleMacroKey(key, action)
+
+	s.handleReportKey(key, action)
+	s.handleGridKey(key, action, mods)
+	s.handleFastForwardKey(key, action, mods)
+	s.handlePauseKey(key, action)
+	s.handleStepKey(key, action, mods)
+	s.handleSpeedKey(key, action, mods)
+	s.handleStatsKey(key, action, mods)
+	s.handleAgeKey(key, action)
+	s.handlePosterKey(key, action, mods)
+	s.handlePaletteKey(key, action, mods)
+	s.handleFieldsKey(key, action, mods)
+	s.handleGridLinesKey(key, action)
+	s.handlePostKey(key, action, mods)
+	s.handleHUDKey(key, action)
+	s.handleHelpKey(key, action)
+	s.handleDebugUIKey(key, action)
+	s.handleArrayKey(key, action, mods)
+	s.handleResetKey(key, action, mods)
+	s.handleCameraKey(key, action, mods)
+	s.handleFullscreenKey(key, action)
+	s.handleScreenshotKey(key, action, mods)
+	s.handleSelectionKey(key, action, mods)
+	s.handleStampKey(key, action, mods)
+	s.handleUndoKey(key, action, mods)
+	s.handleSnapshotKey(key, action, mods)
+	s.handleTimelineKey(key, action, mods)
+
+	if h, ok := s.sim.(keyHandler); ok {
+		h.HandleKey(key, action, mods)
+	}
+}
+
+// handleReportKey prints resource usage with R.
+func (s *State) handleReportKey(key glfw.Key, action glfw.Action) {
+	if key != glfw.KeyR || action == glfw.Release {
+		return
+	}
+	report := s.instance.GenerateReport()
+	buf, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Print(string(buf))
+	fmt.Println(s.describeSurface())
+}
+
 func (s *State) Destroy() {
+	if s.dashboard != nil {
+		s.dashboard.Close()
+		s.dashboard = nil
+	}
+	if s.parameters != nil {
+		s.parameters.Close()
+	}
+	if s.mainThread != nil {
+		s.mainThread.stop()
+	}
+	if s.steps > 0 {
+		elapsed := time.Since(s.start)
+		fmt.Printf("%s steps in %s, %s\n", s.format.Count(int64(s.steps)),
+			s.format.Duration(elapsed), s.format.Rate(int64(s.steps), elapsed, "steps"))
+	}
+	if s.recording != nil {
+		if err := s.recording.stop(s); err != nil {
+			fmt.Println(err)
+		}
+		s.recording = nil
+	}
+	s.saving.Wait()
+	if err := s.manifest.Finish(s.steps); err != nil {
+		log.Println("writing run manifest:", err)
+	}
+	s.manifest = nil
+	if s.mode != nil {
+		s.mode.Exit(s)
+		s.mode = nil
+	}
+	if s.filter != nil {
+		s.filter.Release()
+		s.filter = nil
+	}
+	if s.post != nil {
+		s.post.Release()
+		s.post = nil
+	}
+	if s.scene != nil {
+		s.scene.Release()
+		s.scene = nil
+	}
+	if s.hud != nil {
+		s.hud.Release()
+		s.hud = nil
+	}
+	if s.ui != nil {
+		s.ui.Release()
+		s.ui = nil
+	}
+	if s.frameGraph != nil {
+		s.frameGraph.Release()
+		s.frameGraph = nil
+	}
+	if s.selection != nil {
+		s.selection.Release()
+		s.selection = nil
+	}
+	if s.hover != nil {
+		s.hover.Release()
+		s.hover = nil
+	}
+	if s.help != nil {
+		s.help.Release()
+		s.help = nil
+	}
+	if s.output != nil {
+		s.output.Release()
+		s.output = nil
+	}
+	if s.sim != nil {
+		s.sim.Release()
+		s.sim = nil
+	}
+	if s.session != nil {
+		if err := s.session.Close(); err != nil {
+			log.Println("writing session:", err)
+		}
+		s.session = nil
+	}
+	if s.input != nil {
+		if err := s.input.Close(); err != nil {
+			log.Println("writing input log:", err)
+		}
+		s.input = nil
+	}
 	if s.swapChain != nil {
 		s.swapChain.Release()
 		s.swapChain = nil
@@ -497,17 +951,28 @@ func (s *State) Destroy() {
 		s.gridBuffer.Release()
 		s.gridBuffer = nil
 	}
-	if s.pipeline != nil {
-		s.pipeline.Release()
-		s.pipeline = nil
+	if s.minimap != nil {
+		s.minimap.Release()
+		s.minimap = nil
 	}
-	if s.simulationPipeline != nil {
-		s.simulationPipeline.Release()
-		s.simulationPipeline = nil
+	if s.multisample != nil {
+		s.multisample.Release()
+		s.multisample = nil
 	}
-	for _, bg := range s.gridBindGroups {
-		if bg != nil {
-			bg.Release()
-		}
+	if s.gridLines != nil {
+		s.gridLines.Release()
+		s.gridLines = nil
+	}
+	if s.camera != nil {
+		s.camera.Release()
+		s.camera = nil
+	}
+	if s.cameraLayout != nil {
+		s.cameraLayout.Release()
+		s.cameraLayout = nil
+	}
+	if s.paletteBuffer != nil {
+		s.paletteBuffer.Release()
+		s.paletteBuffer = nil
 	}
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+var errMainThreadStopped = errors.New("main thread is no longer running")
+
+// mainThread queues work from other goroutines so that it runs on the locked
+// OS thread between frames, where GLFW and the engine state may be touched.
+type mainThread struct {
+	calls   chan func()
+	stopped chan struct{}
+}
+
+func newMainThread() *mainThread {
+	return &mainThread{
+		calls:   make(chan func(), 64),
+		stopped: make(chan struct{}),
+	}
+}
+
+// RunOnMainThread runs f on the main thread and blocks until it returns. A
+// panic in f is re-raised in the calling goroutine. It must not be called from
+// the main thread itself, which would deadlock.
+func (s *State) RunOnMainThread(f func()) error {
+	done := make(chan any, 1)
+	call := func() {
+		defer func() {
+			done <- recover()
+		}()
+		f()
+	}
+
+	select {
+	case s.mainThread.calls <- call:
+	case <-s.mainThread.stopped:
+		return errMainThreadStopped
+	}
+	// Headless runs have no events to wake up from, and get to it within
+	// the tick anyway.
+	if s.window != nil {
+		glfw.PostEmptyEvent()
+	}
+
+	select {
+	case p := <-done:
+		if p != nil {
+			panic(p)
+		}
+		return nil
+	case <-s.mainThread.stopped:
+		return errMainThreadStopped
+	}
+}
+
+// runQueued runs everything queued so far. Work queued while it runs waits for
+// the next frame so a busy caller can't starve rendering.
+func (m *mainThread) runQueued() {
+	for n := len(m.calls); n > 0; n-- {
+		(<-m.calls)()
+	}
+}
+
+func (m *mainThread) stop() {
+	select {
+	case <-m.stopped:
+	default:
+		close(m.stopped)
+	}
+}
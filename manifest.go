@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+// version is normally set at build time with
+//
+//	go build -ldflags "-X main.version=$(git describe --always --dirty)"
+//
+// and otherwise falls back to the VCS revision the go tool embedded.
+var version = ""
+
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	var revision, dirty string
+	for _, kv := range info.Settings {
+		switch kv.Key {
+		case "vcs.revision":
+			revision = kv.Value
+		case "vcs.modified":
+			if kv.Value == "true" {
+				dirty = "-dirty"
+			}
+		}
+	}
+	if revision == "" {
+		return info.Main.Version
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	return revision + dirty
+}
+
+// Manifest records everything needed to trace an output back to the run
+// that produced it and to reproduce that run.
+type Manifest struct {
+	Version   string      `json:"version"`
+	Config    *Config     `json:"config"`
+	Seed      *int64      `json:"seed,omitempty"`
+	Adapter   AdapterInfo `json:"adapter"`
+	Start     time.Time   `json:"start"`
+	End       *time.Time  `json:"end,omitempty"`
+	Steps     int         `json:"steps"`
+	Artifacts []Artifact  `json:"artifacts"`
+
+	mu    sync.Mutex
+	store Store
+	key   string
+}
+
+type AdapterInfo struct {
+	Name    string `json:"name"`
+	Vendor  string `json:"vendor"`
+	Driver  string `json:"driver"`
+	Type    string `json:"type"`
+	Backend string `json:"backend"`
+}
+
+// Artifact is a file the run wrote, for example a screenshot or recording.
+type Artifact struct {
+	Kind string    `json:"kind"`
+	Path string    `json:"path"`
+	Time time.Time `json:"time"`
+}
+
+func newManifest(cfg *Config, adapter *wgpu.Adapter, store Store) *Manifest {
+	props := adapter.GetProperties()
+	start := time.Now().UTC()
+	return &Manifest{
+		Version: buildVersion(),
+		Config:  cfg,
+		Adapter: AdapterInfo{
+			Name:    props.Name,
+			Vendor:  props.VendorName,
+			Driver:  props.DriverDescription,
+			Type:    props.AdapterType.String(),
+			Backend: props.BackendType.String(),
+		},
+		Start:     start,
+		Artifacts: []Artifact{},
+		store:     store,
+		key:       "runs/" + start.Format("20060102T150405.000Z") + ".json",
+	}
+}
+
+// AddArtifact records an output file and rewrites the manifest so it is
+// up to date even if the run later crashes. Like Finish it does nothing on a
+// nil manifest, which is what runs with manifests turned off have.
+func (m *Manifest) AddArtifact(kind, path string) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	m.Artifacts = append(m.Artifacts, Artifact{Kind: kind, Path: path, Time: time.Now().UTC()})
+	m.mu.Unlock()
+	return m.save()
+}
+
+// Finish stamps the end of the run.
+func (m *Manifest) Finish(steps int) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	end := time.Now().UTC()
+	m.End = &end
+	m.Steps = steps
+	m.mu.Unlock()
+	return m.save()
+}
+
+func (m *Manifest) save() error {
+	m.mu.Lock()
+	b, err := json.MarshalIndent(m, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return m.store.Put(context.Background(), m.key, b)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	_ "embed"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed minimap.wgsl
+var minimapShader string
+
+// minimapMargin is how far the minimap is from the window's edges, in screen
+// coordinates.
+const minimapMargin = 8
+
+// minimap shows the whole grid in the top right corner while the view
+// camera is zoomed in, with the part of it in view outlined. It is drawn in
+// a render pass of its own after the main one, kept to the corner by its
+// viewport and scissor rectangle.
+type minimap struct {
+	layout         *wgpu.BindGroupLayout
+	pipelineLayout *wgpu.PipelineLayout
+	background     *wgpu.RenderPipeline
+	frame          *wgpu.RenderPipeline
+	view           *wgpu.RenderPipeline
+	bindGroup      *wgpu.BindGroup
+	// whole is a camera that never moves, for the simulation to draw all of
+	// the grid through.
+	whole *viewCamera
+}
+
+func newMinimap(s *State) (m *minimap, err error) {
+	m = &minimap{}
+	defer func() {
+		if err != nil {
+			m.Release()
+		}
+	}()
+
+	shader := s.createShader("minimap shader", minimapShader+paletteShader+cameraShader)
+	defer shader.Release()
+
+	m.layout, err = s.bindGroupLayout("minimap",
+		bufferEntry(0, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.pipelineLayout, err = s.pipelineLayout("minimap", m.layout, s.cameraLayout)
+	if err != nil {
+		return nil, err
+	}
+	m.background, err = s.renderPipeline("minimap background", m.pipelineLayout, shader, "background_vs", "background_fs")
+	if err != nil {
+		return nil, err
+	}
+	blend := &wgpu.BlendState{
+		Color: wgpu.BlendComponent{Operation: wgpu.BlendOperation_Add, SrcFactor: wgpu.BlendFactor_SrcAlpha, DstFactor: wgpu.BlendFactor_OneMinusSrcAlpha},
+		Alpha: wgpu.BlendComponent{Operation: wgpu.BlendOperation_Add, SrcFactor: wgpu.BlendFactor_Zero, DstFactor: wgpu.BlendFactor_One},
+	}
+	m.frame, err = s.blendedRenderPipeline("minimap frame", m.pipelineLayout, shader, "background_vs", "frame_fs", blend)
+	if err != nil {
+		return nil, err
+	}
+	m.view, err = s.blendedRenderPipeline("minimap view", m.pipelineLayout, shader, "view_vs", "view_fs", blend)
+	if err != nil {
+		return nil, err
+	}
+	m.bindGroup = s.bindGroup("minimap", m.layout, s.paletteBuffer)
+	m.whole = newViewCamera(s)
+	return m, nil
+}
+
+// drawMinimap records the minimap's pass into a width by height target, if
+// the simulation goes through the view camera and it is zoomed in.
+func (s *State) drawMinimap(encoder *commandEncoder, target *wgpu.TextureView, targetWidth, targetHeight uint32) {
+	m := s.minimap
+	if m == nil || s.camera == nil || s.camera.zoom() <= 1 {
+		return
+	}
+	if _, ok := s.sim.(cameraUser); !ok {
+		return
+	}
+	width, height := targetWidth/4, targetHeight/4
+	if width < 16 || height < 16 {
+		return
+	}
+	margin := uint32(s.scaled(minimapMargin))
+	x, y := targetWidth-width-margin, margin
+
+	attachment := s.attachScene(target, targetWidth, targetHeight, wgpu.Color{})
+	attachment.LoadOp = wgpu.LoadOp_Load
+	pass := encoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
+		ColorAttachments: []wgpu.RenderPassColorAttachment{attachment},
+	})
+	defer pass.Release()
+	pass.SetViewport(float32(x), float32(y), float32(width), float32(height), 0, 1)
+	pass.SetScissorRect(x, y, width, height)
+
+	m.draw(s, pass, m.background)
+	// Simulations bind whatever bind group the view camera has when they
+	// record their draw, so for the minimap it is briefly the whole grid's.
+	view := s.camera.bindGroup
+	m.whole.fit(s.gridWidth, s.gridHeight)
+	s.camera.bindGroup = m.whole.bindGroup
+	s.sim.Draw(pass)
+	s.camera.bindGroup = view
+	m.draw(s, pass, m.frame)
+	m.draw(s, pass, m.view)
+	pass.End()
+}
+
+// draw draws the minimap's background, its frame or the outline of the
+// view, with pipeline.
+func (m *minimap) draw(s *State, pass *renderPass, pipeline *wgpu.RenderPipeline) {
+	pass.SetPipeline(pipeline)
+	pass.SetBindGroup(0, m.bindGroup, nil)
+	pass.SetBindGroup(1, s.camera.bindGroup, nil)
+	pass.SetVertexBuffer(0, s.vertexBuffer, 0, wgpu.WholeSize)
+	pass.Draw(6, 1, 0, 0)
+}
+
+func (m *minimap) Release() {
+	if m.whole != nil {
+		m.whole.Release()
+		m.whole = nil
+	}
+	if m.bindGroup != nil {
+		m.bindGroup.Release()
+		m.bindGroup = nil
+	}
+	if m.view != nil {
+		m.view.Release()
+		m.view = nil
+	}
+	if m.frame != nil {
+		m.frame.Release()
+		m.frame = nil
+	}
+	if m.background != nil {
+		m.background.Release()
+		m.background = nil
+	}
+	if m.pipelineLayout != nil {
+		m.pipelineLayout.Release()
+		m.pipelineLayout = nil
+	}
+	if m.layout != nil {
+		m.layout.Release()
+		m.layout = nil
+	}
+}
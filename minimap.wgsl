@@ -0,0 +1,60 @@
+// palette.wgsl and camera.wgsl go after this.
+struct VertexOutput {
+  @builtin(position) pos: vec4<f32>,
+  // local runs from -1 to 1 across the quad.
+  @location(0) local: vec2<f32>,
+};
+
+@group(0) @binding(0) var<uniform> palette: Palette;
+
+// The background and frame fill the minimap's viewport.
+@vertex
+fn background_vs(@location(0) pos: vec2<f32>) -> VertexOutput {
+  var output: VertexOutput;
+  output.local = pos / 0.8;
+  output.pos = vec4<f32>(output.local, 0.0, 1.0);
+  return output;
+}
+
+// The view is the part of the grid the camera shows, found by undoing its
+// zoom and translation. The minimap letterboxes the grid just as the window
+// does, so that is all there is to undo, and the zoom is the larger of the
+// scales as the letterbox leaves one of them alone.
+@vertex
+fn view_vs(@location(0) pos: vec2<f32>) -> VertexOutput {
+  var output: VertexOutput;
+  output.local = pos / 0.8;
+  let zoom = max(camera[0][0], camera[1][1]);
+  output.pos = vec4<f32>((output.local - camera[3].xy) / zoom, 0.0, 1.0);
+  return output;
+}
+
+// edge is 1 in the last pixel of the quad, pixel across, and ink a colour
+// that stands out from the background.
+fn edge(local: vec2<f32>, pixel: vec2<f32>) -> f32 {
+  let distance = (1.0 - abs(local)) / pixel;
+  return 1.0 - clamp(min(distance.x, distance.y) - 0.5, 0.0, 1.0);
+}
+
+fn ink() -> vec3<f32> {
+  let light = dot(palette.background.rgb, vec3(0.2126, 0.7152, 0.0722)) > 0.5;
+  return select(vec3(1.0), vec3(0.0), light);
+}
+
+@fragment
+fn background_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  return palette.background;
+}
+
+// The frame edges the minimap, drawn over the simulation in case it covers
+// the background.
+@fragment
+fn frame_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  return vec4<f32>(ink(), 0.4 * edge(input.local, fwidth(input.local)));
+}
+
+// The view is lightly shaded inside its edge.
+@fragment
+fn view_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  return vec4<f32>(ink(), max(edge(input.local, fwidth(input.local)), 0.15));
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+const windowTitle = "webgpu-go"
+
+// Actions are reported through State.emit by the features that perform them,
+// so modes can react to what the user did rather than which key they used.
+const (
+	actionPaint = "paint"
+	actionStamp = "stamp"
+	actionSpeed = "speed"
+	actionRule  = "rule"
+)
+
+// Mode is the state machine for how input is interpreted. Exactly one mode is
+// active; normalMode is the default.
+type Mode interface {
+	Enter(s *State)
+	Exit(s *State)
+	// HandleKey returns true if the key was consumed and should not reach
+	// the rest of the app.
+	HandleKey(s *State, key glfw.Key, action glfw.Action, mods glfw.ModifierKey) bool
+	OnAction(s *State, action string)
+}
+
+// sceneMode is implemented by modes that show something other than the
+// simulation. While one is active it is stepped and drawn instead.
+type sceneMode interface {
+	Step(encoder *commandEncoder)
+	Draw(s *State, pass *renderPass)
+}
+
+// textMode is implemented by modes that take typed text, given a character
+// at a time as the keyboard layout produces them.
+type textMode interface {
+	HandleChar(s *State, char rune)
+}
+
+// overlayMode is implemented by modes that draw over the running
+// simulation. RenderOverlay records any passes of their own, before the
+// simulation is drawn.
+type overlayMode interface {
+	StepOverlay(encoder *commandEncoder)
+	RenderOverlay(encoder *commandEncoder)
+	DrawOverlay(s *State, pass *renderPass)
+}
+
+func (s *State) setMode(m Mode) {
+	if s.mode != nil {
+		s.mode.Exit(s)
+	}
+	s.mode = m
+	m.Enter(s)
+}
+
+func (s *State) emit(action string) {
+	s.mode.OnAction(s, action)
+}
+
+// showPrompt puts short guidance text in front of the user, or clears it when
+// text is empty.
+func (s *State) showPrompt(text string) {
+	s.prompt = text
+	s.updateTitle()
+	if text != "" {
+		fmt.Println(text)
+	}
+}
+
+// updateTitle shows the simulation, its counts, whether it is paused, the
+// prompt and, if they are turned on, the last frame's stats in the title
+// bar.
+func (s *State) updateTitle() {
+	if s.window == nil {
+		return
+	}
+	title := windowTitle
+	if info, ok := simulations[s.cfg.Simulation]; ok {
+		title = info.Name + " - " + title
+	}
+	if s.title.text != "" {
+		title += " - " + s.title.text
+	}
+	if s.paused {
+		title += " (paused)"
+	}
+	if s.prompt != "" {
+		title += " - " + s.prompt
+	}
+	if s.showStats {
+		title += " | " + s.lastStats.describe(s.format)
+		if latency := s.input.Latency(); latency > 0 {
+			title += ", " + s.format.Float(latency.Seconds()*1000, 1) + " ms input latency"
+		}
+	}
+	s.window.SetTitle(title)
+}
+
+type normalMode struct{}
+
+func (normalMode) Enter(s *State) { s.showPrompt("") }
+func (normalMode) Exit(s *State)  {}
+func (normalMode) HandleKey(s *State, key glfw.Key, action glfw.Action, mods glfw.ModifierKey) bool {
+	if action != glfw.Press {
+		return false
+	}
+	switch key {
+	case glfw.KeyTab:
+		s.openRuleBrowser()
+	case glfw.KeyP:
+		if mods&(glfw.ModControl|glfw.ModSuper) != 0 {
+			s.openCommandPalette()
+		} else {
+			s.openPresetPicker()
+		}
+	case glfw.KeyS:
+		if mods&(glfw.ModControl|glfw.ModSuper) != 0 {
+			return false
+		}
+		s.openStill()
+	default:
+		return false
+	}
+	return true
+}
+func (normalMode) OnAction(s *State, action string) {}
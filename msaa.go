@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+// maxMultisampleTargets is how many sizes of multisampled target are kept
+// before they are all let go, as the window and stills change size.
+const maxMultisampleTargets = 8
+
+// multisampleTargets are the multisampled colour textures that passes the
+// simulations draw into render into with -msaa above 1, one for each size
+// of target, resolved into the real one at the end of the pass. Previews
+// share them with the State they belong to.
+type multisampleTargets struct {
+	device  *wgpu.Device
+	format  wgpu.TextureFormat
+	count   uint32
+	targets map[[2]uint32]multisampleTarget
+}
+
+type multisampleTarget struct {
+	texture *wgpu.Texture
+	view    *wgpu.TextureView
+}
+
+// initMultisample checks that the device can multisample s.config.Format
+// count times, by making the window's target. WebGPU always can 4 times;
+// 2, 8 and 16 depend on the adapter.
+func (s *State) initMultisample(count int) error {
+	switch count {
+	case 1:
+		s.sampleCount = 1
+		return nil
+	case 4:
+	case 2, 8, 16:
+		if !s.adapter.HasFeature(wgpu.NativeFeature_TextureAdapterSpecificFormatFeatures) {
+			return fmt.Errorf("-msaa %d needs an adapter with its own format features, this one only has 1 and 4", count)
+		}
+	default:
+		return fmt.Errorf("-msaa must be 1, 2, 4, 8 or 16, got %d", count)
+	}
+	s.sampleCount = uint32(count)
+	s.multisample = &multisampleTargets{
+		device:  s.device,
+		format:  s.config.Format,
+		count:   uint32(count),
+		targets: map[[2]uint32]multisampleTarget{},
+	}
+	if _, err := s.multisample.view(s.config.Width, s.config.Height); err != nil {
+		return fmt.Errorf("-msaa %d: %w", count, err)
+	}
+	return nil
+}
+
+// multisampleState is the multisample state of every pipeline that draws
+// into the passes attachScene sets up.
+func (s *State) multisampleState() wgpu.MultisampleState {
+	return wgpu.MultisampleState{
+		Count: max(s.sampleCount, 1),
+		Mask:  0xFFFFFFFF,
+	}
+}
+
+// attachScene is attachColourToView for a pass the simulations draw into, a
+// width by height view of s.config.Format. With multisampling they draw
+// into a multisampled texture of the same size, resolved into view.
+func (s *State) attachScene(view *wgpu.TextureView, width, height uint32, clear wgpu.Color) wgpu.RenderPassColorAttachment {
+	a := attachColourToView(view, clear)
+	if s.multisample == nil {
+		return a
+	}
+	samples, err := s.multisample.view(width, height)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	a.View, a.ResolveTarget = samples, view
+	return a
+}
+
+func (m *multisampleTargets) view(width, height uint32) (*wgpu.TextureView, error) {
+	size := [2]uint32{width, height}
+	if t, ok := m.targets[size]; ok {
+		return t.view, nil
+	}
+	if len(m.targets) >= maxMultisampleTargets {
+		m.Release()
+	}
+	texture, err := m.device.CreateTexture(&wgpu.TextureDescriptor{
+		Label:         "multisampled scene",
+		Usage:         wgpu.TextureUsage_RenderAttachment,
+		Dimension:     wgpu.TextureDimension_2D,
+		Size:          wgpu.Extent3D{Width: width, Height: height, DepthOrArrayLayers: 1},
+		Format:        m.format,
+		MipLevelCount: 1,
+		SampleCount:   m.count,
+	})
+	if err != nil {
+		return nil, err
+	}
+	view, err := texture.CreateView(nil)
+	if err != nil {
+		texture.Release()
+		return nil, err
+	}
+	m.targets[size] = multisampleTarget{texture, view}
+	return view, nil
+}
+
+func (m *multisampleTargets) Release() {
+	for size, t := range m.targets {
+		t.view.Release()
+		t.texture.Release()
+		delete(m.targets, size)
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+// instance is a simulation with a State of its own, sharing the device and
+// the tile vertices with the main one but with its own grid, random source
+// and view size.
+type instance struct {
+	name  string
+	state *State
+	sim   Simulation
+}
+
+// newInstance starts the simulation cfg describes on a width by height grid,
+// to be drawn into views of pixels by pixels, with its random source seeded
+// from seed.
+func newInstance(s *State, cfg *Config, width, height int, pixels uint32, seed int64) (in *instance, err error) {
+	in = &instance{
+		name: cfg.Simulation,
+		state: &State{
+			device: s.device,
+			queue:  s.queue,
+			stats:  s.stats,
+			config: &wgpu.SwapChainDescriptor{
+				Format: s.config.Format,
+				Width:  pixels,
+				Height: pixels,
+			},
+			vertexBuffer:  s.vertexBuffer,
+			paletteBuffer: s.paletteBuffer,
+			cameraLayout:  s.cameraLayout,
+			sampleCount:   s.sampleCount,
+			multisample:   s.multisample,
+			palette:       s.palette,
+			format:        s.format,
+			cfg:           cfg,
+			rand:          rand.New(rand.NewSource(seed)),
+		},
+	}
+	in.state.initGridBuffer(width, height)
+	// Each view shows the whole of its grid, whatever the main camera does.
+	in.state.camera = newViewCamera(in.state)
+	in.sim, err = newSimulation(in.state, cfg)
+	if err != nil {
+		in.Release()
+		return nil, err
+	}
+	return in, nil
+}
+
+func (in *instance) Release() {
+	if in.sim != nil {
+		in.sim.Release()
+		in.sim = nil
+	}
+	if in.state.gridBuffer != nil {
+		in.state.gridBuffer.Release()
+		in.state.gridBuffer = nil
+	}
+	if in.state.camera != nil {
+		in.state.camera.Release()
+		in.state.camera = nil
+	}
+}
+
+// startSimulation creates the simulation cfg describes on the current grid,
+// or cfg.Instances independent copies of it side by side, or one for each of
+// cfg.Panes.
+func (s *State) startSimulation(cfg *Config) (Simulation, error) {
+	if cfg.Instances > 1 || len(cfg.Panes) > 0 {
+		return newMultiSim(s, cfg)
+	}
+	return newSimulation(s, cfg)
+}
+
+// multiSim runs several instances of a simulation at once, each from its own
+// random start, and draws them in a grid of views. With panes each is a
+// simulation of its own, to compare rules or seeds side by side. The number
+// keys pick which one the simulation's own controls go to, and 0 sends them
+// to all of them.
+type multiSim struct {
+	config *wgpu.SwapChainDescriptor
+	// parent is the State the instances are drawn into.
+	parent    *State
+	instances []*instance
+	// selected is the instance controls go to, or -1 for all of them.
+	selected int
+}
+
+func newMultiSim(s *State, cfg *Config) (sim Simulation, err error) {
+	m := &multiSim{config: s.config, parent: s, selected: -1}
+	defer func() {
+		if err != nil {
+			m.Release()
+		}
+	}()
+	if len(cfg.Panes) > 0 {
+		for i, pane := range cfg.Panes {
+			c, err := presetConfig(cfg, pane)
+			if err != nil {
+				return nil, err
+			}
+			c.Instances, c.Panes = 1, nil
+			// A pane with a -seed of its own starts from it, so that seeds
+			// can be compared too.
+			seed := s.rand.Int63()
+			if c.Seed != cfg.Seed && c.Seed != 0 {
+				seed = c.Seed
+			}
+			in, err := newInstance(s, c, s.gridWidth, s.gridHeight, s.config.Height, seed)
+			if err != nil {
+				return nil, fmt.Errorf("pane %d: %w", i+1, err)
+			}
+			in.name = pane
+			fmt.Printf("pane %d: %s\n", i+1, pane)
+			m.instances = append(m.instances, in)
+		}
+		return m, nil
+	}
+	for i := 0; i < cfg.Instances; i++ {
+		in, err := newInstance(s, cfg, s.gridWidth, s.gridHeight, s.config.Height, s.rand.Int63())
+		if err != nil {
+			return nil, fmt.Errorf("instance %d: %w", i+1, err)
+		}
+		m.instances = append(m.instances, in)
+	}
+	return m, nil
+}
+
+// controlled returns the instances that controls currently go to.
+func (m *multiSim) controlled() []*instance {
+	if m.selected < 0 {
+		return m.instances
+	}
+	return m.instances[m.selected : m.selected+1]
+}
+
+func (m *multiSim) Step(encoder *commandEncoder) {
+	for _, in := range m.instances {
+		in.sim.Step(encoder)
+	}
+}
+
+// columns is how many views go across each row.
+func (m *multiSim) columns() int {
+	return int(math.Ceil(math.Sqrt(float64(len(m.instances)))))
+}
+
+// Draw splits the window into a view for each instance, with a small gap
+// between them. While one instance is picked out the others are drawn a
+// little smaller.
+func (m *multiSim) Draw(pass *renderPass) {
+	cols := m.columns()
+	rows := (len(m.instances) + cols - 1) / cols
+	width := float32(m.config.Width) / float32(cols)
+	height := float32(m.config.Height) / float32(rows)
+	for i, in := range m.instances {
+		in.state.config.Width, in.state.config.Height = uint32(width), uint32(height)
+		in.state.camera.refit()
+		inset := m.parent.scaled(2)
+		if m.selected >= 0 && i != m.selected {
+			inset += min(width, height) * 0.05
+		}
+		x := width * float32(i%cols)
+		y := height * float32(i/cols)
+		pass.SetViewport(x+inset, y+inset, width-2*inset, height-2*inset, 0, 1)
+		in.sim.Draw(pass)
+	}
+}
+
+// HandleKey picks an instance with 1 to 9, or all of them with 0, and passes
+// every other key on to the picked instances.
+func (m *multiSim) HandleKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if key >= glfw.Key0 && key <= glfw.Key9 {
+		if action != glfw.Press {
+			return
+		}
+		i := int(key-glfw.Key0) - 1
+		switch {
+		case i < 0:
+			m.selected = -1
+			fmt.Println("controlling all instances")
+		case i < len(m.instances):
+			m.selected = i
+			fmt.Printf("controlling instance %d, %s\n", i+1, m.instances[i].name)
+		}
+		return
+	}
+	for _, in := range m.controlled() {
+		if h, ok := in.sim.(keyHandler); ok {
+			h.HandleKey(key, action, mods)
+		}
+	}
+}
+
+// batchesSteps is whether every instance does.
+func (m *multiSim) batchesSteps() bool {
+	for _, in := range m.instances {
+		if b, ok := in.sim.(batchStepper); !ok || !b.batchesSteps() {
+			return false
+		}
+	}
+	return true
+}
+
+// ResizeGrid resizes every instance, as they all share the main grid size.
+func (m *multiSim) ResizeGrid(s *State, r gridRemap) error {
+	for i, in := range m.instances {
+		resizer, ok := in.sim.(gridResizer)
+		if !ok {
+			return fmt.Errorf("this simulation can't change its grid size")
+		}
+		if err := resizer.ResizeGrid(in.state, r); err != nil {
+			return fmt.Errorf("instance %d: %w", i+1, err)
+		}
+		if err := in.state.setGridSize(r.width, r.height); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gridLimit is the smallest limit of any instance, as they share the grid.
+func (m *multiSim) gridLimit() int {
+	limit := maxGridSize
+	for _, in := range m.instances {
+		if l, ok := in.sim.(gridLimiter); ok {
+			limit = min(limit, l.gridLimit())
+		}
+	}
+	return limit
+}
+
+// Population is the total over every instance.
+func (m *multiSim) Population(s *State) (float64, error) {
+	total := 0.0
+	for _, in := range m.instances {
+		counter, ok := in.sim.(populationCounter)
+		if !ok {
+			return 0, fmt.Errorf("this simulation has no population")
+		}
+		n, err := counter.Population(in.state)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// FastForward jumps the picked instances ahead.
+func (m *multiSim) FastForward(s *State, generations uint64) error {
+	for _, in := range m.controlled() {
+		f, ok := in.sim.(fastForwarder)
+		if !ok {
+			return fmt.Errorf("this simulation can't fast-forward")
+		}
+		if err := f.FastForward(in.state, generations); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *multiSim) Release() {
+	for _, in := range m.instances {
+		in.Release()
+	}
+	m.instances = nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed nbody_compute.wgsl
+var nbodyCompute string
+
+//go:embed nbody_draw.wgsl
+var nbodyDraw string
+
+const (
+	// nbodyBins is how many bins the box is cut into along each axis.
+	nbodyBins = 16
+	// nbodyBox is half the width of the binned box, a little more than
+	// twice the starting disc's radius.
+	nbodyBox = 2.5
+)
+
+// NBody is gravity between many equal masses, starting out as a rotating
+// disc. Bodies are sorted into bins each step so that only nearby ones are
+// summed one by one; see nbody_compute.wgsl. It is drawn as glowing sprites
+// from a camera orbiting it like 3D life's.
+type NBody struct {
+	computeLayout         *wgpu.BindGroupLayout
+	drawLayout            *wgpu.BindGroupLayout
+	computePipelineLayout *wgpu.PipelineLayout
+	drawPipelineLayout    *wgpu.PipelineLayout
+	pipeline              *wgpu.RenderPipeline
+	binning               *binning
+	summarise, step       *wgpu.ComputePipeline
+
+	params        *wgpu.Buffer
+	camera        *orbitCamera
+	positions     []*wgpu.Buffer
+	velocities    *wgpu.Buffer
+	bins          *wgpu.Buffer
+	computeSets   []*wgpu.BindGroup
+	drawSets      []*wgpu.BindGroup
+	vertices      *wgpu.Buffer
+	count         int
+	steps         int
+	stepsPerFrame int
+}
+
+func newNBody(s *State, cfg *Config) (sim Simulation, err error) {
+	p := cfg.NBody
+	if p.Count < 2 || p.Count > 1<<16 {
+		return nil, fmt.Errorf("n-body count %d out of range [2, %d]", p.Count, 1<<16)
+	}
+	if p.StepsPerFrame < 1 {
+		return nil, fmt.Errorf("n-body needs at least one step per frame, got %d", p.StepsPerFrame)
+	}
+	if p.DT <= 0 || p.Softening <= 0 {
+		return nil, fmt.Errorf("n-body time step and softening must be positive, got %v and %v", p.DT, p.Softening)
+	}
+
+	n := &NBody{
+		vertices:      s.vertexBuffer,
+		count:         p.Count,
+		stepsPerFrame: p.StepsPerFrame,
+	}
+	defer func() {
+		if err != nil {
+			n.Release()
+		}
+	}()
+
+	drawShader := s.createShader("n-body render shader", nbodyDraw)
+	defer drawShader.Release()
+
+	computeShader := s.createShader("n-body compute shader", nbodyCompute+binningShader)
+	defer computeShader.Release()
+
+	entries := append([]wgpu.BindGroupLayoutEntry{
+		bufferEntry(0, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(2, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(3, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+	}, binningEntries()...)
+	entries = append(entries, bufferEntry(8, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage))
+	n.computeLayout, err = s.bindGroupLayout("n-body compute", entries...)
+	if err != nil {
+		return nil, err
+	}
+	n.drawLayout, err = s.bindGroupLayout("n-body render",
+		bufferEntry(0, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_Uniform),
+		bufferEntry(2, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(3, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_ReadOnlyStorage),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	n.computePipelineLayout, err = s.pipelineLayout("n-body compute", n.computeLayout)
+	if err != nil {
+		return nil, err
+	}
+	n.drawPipelineLayout, err = s.pipelineLayout("n-body render", n.drawLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	n.pipeline, err = s.blendedRenderPipeline("n-body render", n.drawPipelineLayout, drawShader, "main_vs", "main_fs", additiveBlend)
+	if err != nil {
+		return nil, err
+	}
+	n.binning, err = newBinning(s, "n-body", n.computePipelineLayout, computeShader, nbodyBins*nbodyBins*nbodyBins, n.count)
+	if err != nil {
+		return nil, err
+	}
+	n.summarise, err = s.computePipeline("n-body summarise", n.computePipelineLayout, computeShader, "summarise")
+	if err != nil {
+		return nil, err
+	}
+	n.step, err = s.computePipeline("n-body step", n.computePipelineLayout, computeShader, "step")
+	if err != nil {
+		return nil, err
+	}
+
+	n.params = s.uniformBuffer("n-body params", wgpu.ToBytes([]uint32{
+		uint32(n.count), nbodyBins, math.Float32bits(nbodyBox), math.Float32bits(p.DT),
+		math.Float32bits(p.Softening), 0, 0, 0,
+	}))
+	n.camera = newOrbitCamera(s, "n-body camera")
+
+	positions, velocities := nbodyDisc(s.rand, n.count)
+	n.positions = []*wgpu.Buffer{
+		s.storageBuffer(wgpu.ToBytes(positions)),
+		s.storageBuffer(wgpu.ToBytes(positions)),
+	}
+	n.velocities = s.storageBuffer(wgpu.ToBytes(velocities))
+	n.bins = s.storageBuffer(make([]byte, nbodyBins*nbodyBins*nbodyBins*16))
+
+	for i := range n.positions {
+		in, out := n.positions[i], n.positions[1-i]
+		buffers := append([]*wgpu.Buffer{n.params, in, out, n.velocities}, n.binning.buffers()...)
+		n.computeSets = append(n.computeSets, s.bindGroup("n-body compute", n.computeLayout, append(buffers, n.bins)...))
+		n.drawSets = append(n.drawSets, s.bindGroup("n-body render", n.drawLayout,
+			n.params, n.camera.buffer, in, n.velocities))
+	}
+	return n, nil
+}
+
+// nbodyDisc lays count bodies out in a thin disc in the xz plane, thinning
+// out exponentially to a radius of 1, with the total mass 1. Each moves at
+// the speed of a circular orbit around the mass closer in than it.
+func nbodyDisc(rng *rand.Rand, count int) (positions, velocities []float32) {
+	radii := make([]float64, count)
+	for i := range radii {
+		for radii[i] = 1; radii[i] >= 1; {
+			radii[i] = rng.ExpFloat64() * 0.25
+		}
+	}
+	sort.Float64s(radii)
+	for i, r := range radii {
+		angle := rng.Float64() * 2 * math.Pi
+		sin, cos := math.Sincos(angle)
+		speed := math.Sqrt(float64(i+1) / float64(count) / math.Max(r, 0.05))
+		positions = append(positions,
+			float32(r*cos), float32(rng.NormFloat64()*0.02), float32(r*sin), 1)
+		velocities = append(velocities,
+			float32(-speed*sin), 0, float32(speed*cos), 0)
+	}
+	return positions, velocities
+}
+
+func (n *NBody) Step(encoder *commandEncoder) {
+	n.camera.advance()
+
+	computePass := encoder.BeginComputePass(nil)
+	defer computePass.Release()
+
+	bodies := uint32(n.count+63) / 64
+	bins := uint32(nbodyBins*nbodyBins*nbodyBins+63) / 64
+	for i := 0; i < n.stepsPerFrame; i++ {
+		computePass.SetBindGroup(0, n.computeSets[n.steps%2], nil)
+		n.binning.record(computePass)
+		computePass.SetPipeline(n.summarise)
+		computePass.DispatchWorkgroups(bins, 1, 1)
+		computePass.SetPipeline(n.step)
+		computePass.DispatchWorkgroups(bodies, 1, 1)
+		n.steps += 1
+	}
+	computePass.End()
+}
+
+func (n *NBody) Draw(pass *renderPass) {
+	pass.SetPipeline(n.pipeline)
+	pass.SetBindGroup(0, n.drawSets[n.steps%2], nil)
+	pass.SetVertexBuffer(0, n.vertices, 0, wgpu.WholeSize)
+	pass.Draw(6, uint32(n.count), 0, 0)
+}
+
+func (n *NBody) Release() {
+	for _, bg := range n.computeSets {
+		bg.Release()
+	}
+	n.computeSets = nil
+	for _, bg := range n.drawSets {
+		bg.Release()
+	}
+	n.drawSets = nil
+	for _, b := range n.positions {
+		b.Release()
+	}
+	n.positions = nil
+	for _, b := range []*wgpu.Buffer{n.velocities, n.bins, n.params} {
+		if b != nil {
+			b.Release()
+		}
+	}
+	n.velocities, n.bins, n.params = nil, nil, nil
+	if n.camera != nil {
+		n.camera.Release()
+		n.camera = nil
+	}
+	if n.binning != nil {
+		n.binning.Release()
+		n.binning = nil
+	}
+	if n.summarise != nil {
+		n.summarise.Release()
+		n.summarise = nil
+	}
+	if n.step != nil {
+		n.step.Release()
+		n.step = nil
+	}
+	if n.pipeline != nil {
+		n.pipeline.Release()
+		n.pipeline = nil
+	}
+	if n.drawPipelineLayout != nil {
+		n.drawPipelineLayout.Release()
+		n.drawPipelineLayout = nil
+	}
+	if n.computePipelineLayout != nil {
+		n.computePipelineLayout.Release()
+		n.computePipelineLayout = nil
+	}
+	if n.drawLayout != nil {
+		n.drawLayout.Release()
+		n.drawLayout = nil
+	}
+	if n.computeLayout != nil {
+		n.computeLayout.Release()
+		n.computeLayout = nil
+	}
+}
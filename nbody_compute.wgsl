@@ -0,0 +1,113 @@
+// Gravity between count equal masses, with the space around them cut into
+// bins^3 bins across [-box, box] on each axis. Each step sorts the bodies
+// into their bins, then every body feels the bodies in its own and the
+// neighbouring bins one by one and every other bin as a single mass at its
+// centre. Bodies past the edge are kept in the bins along it. binning.wgsl
+// goes after this.
+struct Params {
+  count: u32,
+  bins: u32,
+  box: f32,
+  dt: f32,
+  softening: f32,
+};
+
+// Bin is the centre of mass of a bin and the mass in it.
+struct Bin {
+  centre: vec3<f32>,
+  mass: f32,
+};
+
+@group(0) @binding(0) var<uniform> params: Params;
+@group(0) @binding(1) var<storage> positionIn: array<vec4<f32>>;
+@group(0) @binding(2) var<storage, read_write> positionOut: array<vec4<f32>>;
+@group(0) @binding(3) var<storage, read_write> velocity: array<vec4<f32>>;
+@group(0) @binding(8) var<storage, read_write> bins: array<Bin>;
+
+fn binOf(p: vec3<f32>) -> vec3<i32> {
+  let n = i32(params.bins);
+  let b = vec3<i32>(floor((p / params.box + 1.0) * 0.5 * f32(n)));
+  return clamp(b, vec3(0), vec3(n - 1));
+}
+
+fn binIndex(b: vec3<i32>) -> u32 {
+  let n = i32(params.bins);
+  return u32((b.z * n + b.y) * n + b.x);
+}
+
+fn binTotal() -> u32 {
+  return params.bins * params.bins * params.bins;
+}
+
+fn bodyCount() -> u32 {
+  return params.count;
+}
+
+fn binOfBody(i: u32) -> u32 {
+  return binIndex(binOf(positionIn[i].xyz));
+}
+
+@compute
+@workgroup_size(64)
+fn summarise(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x >= binTotal() {
+    return;
+  }
+  let start = binStart[id.x];
+  let n = atomicLoad(&binCount[id.x]);
+  var sum = vec3(0.0);
+  for (var i = start; i < start + n; i += 1u) {
+    sum += positionIn[sorted[i]].xyz;
+  }
+  let mass = f32(n) / f32(params.count);
+  bins[id.x] = Bin(sum / max(f32(n), 1.0), mass);
+}
+
+// pull is the acceleration towards mass at offset d.
+fn pull(d: vec3<f32>, mass: f32) -> vec3<f32> {
+  let r2 = dot(d, d) + params.softening * params.softening;
+  return d * (mass * inverseSqrt(r2 * r2 * r2));
+}
+
+// step kicks each body by the acceleration then moves it by its new
+// velocity, which keeps orbits from spiralling in or out over time.
+@compute
+@workgroup_size(64)
+fn step(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x >= params.count {
+    return;
+  }
+  let p = positionIn[id.x].xyz;
+  let own = binOf(p);
+  let n = i32(params.bins);
+  let m = 1.0 / f32(params.count);
+  var a = vec3(0.0);
+
+  for (var z = 0; z < n; z += 1) {
+    for (var y = 0; y < n; y += 1) {
+      for (var x = 0; x < n; x += 1) {
+        let b = vec3(x, y, z);
+        let bin = bins[binIndex(b)];
+        if bin.mass == 0.0 {
+          continue;
+        }
+        if any(abs(b - own) > vec3(1)) {
+          a += pull(bin.centre - p, bin.mass);
+          continue;
+        }
+        let start = binStart[binIndex(b)];
+        let end = start + atomicLoad(&binCount[binIndex(b)]);
+        for (var i = start; i < end; i += 1u) {
+          let other = sorted[i];
+          if other != id.x {
+            a += pull(positionIn[other].xyz - p, m);
+          }
+        }
+      }
+    }
+  }
+
+  let v = velocity[id.x].xyz + a * params.dt;
+  velocity[id.x] = vec4(v, 0.0);
+  positionOut[id.x] = vec4(p + v * params.dt, 1.0);
+}
@@ -0,0 +1,64 @@
+struct Params {
+  count: u32,
+  bins: u32,
+  box: f32,
+  dt: f32,
+  softening: f32,
+};
+
+struct Camera {
+  angle: f32,
+  aspect: f32,
+};
+
+struct VertexInput {
+  @location(0) pos: vec2<f32>,
+  @builtin(instance_index) instance: u32,
+};
+
+struct VertexOutput {
+  @builtin(position) pos: vec4<f32>,
+  @location(0) uv: vec2<f32>,
+  @location(1) colour: vec3<f32>,
+};
+
+@group(0) @binding(0) var<uniform> params: Params;
+@group(0) @binding(1) var<uniform> camera: Camera;
+@group(0) @binding(2) var<storage> position: array<vec4<f32>>;
+@group(0) @binding(3) var<storage> velocity: array<vec4<f32>>;
+
+// Each body is a sprite facing the camera, whose eye is set up the same way
+// as in life3d_draw.wgsl.
+@vertex
+fn main_vs(input: VertexInput) -> VertexOutput {
+  let eye = vec3(sin(camera.angle), 0.6, cos(camera.angle)) * 3.0;
+  let forward = normalize(-eye);
+  let right = normalize(cross(forward, vec3(0.0, 1.0, 0.0)));
+  let up = cross(right, forward);
+
+  let d = position[input.instance].xyz - eye;
+  let depth = dot(d, forward);
+  let centre = 2.0 * vec2(dot(d, right), dot(d, up)) / depth;
+  let corner = input.pos / 0.8;
+  let size = 0.05 / depth;
+
+  var output: VertexOutput;
+  if depth <= 0.1 {
+    // Behind the camera: put every corner in one place so nothing is drawn.
+    output.pos = vec4(0.0, 0.0, -1.0, 1.0);
+  } else {
+    output.pos = vec4((centre + corner * size) / vec2(camera.aspect, 1.0), 0.0, 1.0);
+  }
+  output.uv = corner;
+  // Slow bodies are red and fast ones blue-white.
+  let speed = length(velocity[input.instance].xyz);
+  output.colour = mix(vec3(1.0, 0.35, 0.1), vec3(0.6, 0.8, 1.0), clamp(speed * 0.5, 0.0, 1.0));
+  return output;
+}
+
+// Sprites add up, so dense clusters glow.
+@fragment
+fn main_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  let glow = exp(-6.0 * dot(input.uv, input.uv)) * 0.3;
+  return vec4(input.colour * glow, 1.0);
+}
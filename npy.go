@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// npyArray is a NumPy array of height rows of width values, or of width
+// vectors of channels values. Rows run from the top of the grid down, the
+// way the grid is drawn and images are stored.
+type npyArray struct {
+	width, height, channels int
+	values                  []float32
+}
+
+// npyTypes are the element types arrays can be read from, by their NumPy
+// descr.
+var npyTypes = map[string]int{"|u1": 1, "<u1": 1, "<u4": 4, "<f4": 4}
+
+var (
+	npyDescr   = regexp.MustCompile(`'descr':\s*'([^']*)'`)
+	npyFortran = regexp.MustCompile(`'fortran_order':\s*(True|False)`)
+	npyShape   = regexp.MustCompile(`'shape':\s*\(([^)]*)\)`)
+)
+
+// readNPY reads a 2D array, or a 3D one of vectors, from a .npy file.
+func readNPY(path string) (*npyArray, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 10 || string(data[:6]) != "\x93NUMPY" {
+		return nil, fmt.Errorf("%s: not a .npy file", path)
+	}
+	var header string
+	switch data[6] {
+	case 1:
+		n := int(binary.LittleEndian.Uint16(data[8:]))
+		if len(data) < 10+n {
+			return nil, fmt.Errorf("%s: header cut short", path)
+		}
+		header, data = string(data[10:10+n]), data[10+n:]
+	case 2, 3:
+		if len(data) < 12 {
+			return nil, fmt.Errorf("%s: header cut short", path)
+		}
+		n := int(binary.LittleEndian.Uint32(data[8:]))
+		if len(data) < 12+n {
+			return nil, fmt.Errorf("%s: header cut short", path)
+		}
+		header, data = string(data[12:12+n]), data[12+n:]
+	default:
+		return nil, fmt.Errorf("%s: unknown .npy version %d", path, data[6])
+	}
+
+	descr, fortran, shape := npyDescr.FindStringSubmatch(header), npyFortran.FindStringSubmatch(header), npyShape.FindStringSubmatch(header)
+	if descr == nil || fortran == nil || shape == nil {
+		return nil, fmt.Errorf("%s: can't read header %q", path, header)
+	}
+	size, ok := npyTypes[descr[1]]
+	if !ok {
+		return nil, fmt.Errorf("%s: can't read %s arrays, only uint8, uint32 and float32", path, descr[1])
+	}
+	if fortran[1] == "True" {
+		return nil, fmt.Errorf("%s: can't read Fortran-ordered arrays", path)
+	}
+	var dims []int
+	for _, d := range strings.Split(shape[1], ",") {
+		if d = strings.TrimSpace(d); d == "" {
+			continue
+		}
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("%s: bad shape (%s)", path, shape[1])
+		}
+		dims = append(dims, n)
+	}
+	if len(dims) == 2 {
+		dims = append(dims, 1)
+	}
+	if len(dims) != 3 {
+		return nil, fmt.Errorf("%s: need a 2D array, or 3D of vectors, got shape (%s)", path, shape[1])
+	}
+
+	a := &npyArray{height: dims[0], width: dims[1], channels: dims[2]}
+	n := a.width * a.height * a.channels
+	if len(data) < n*size {
+		return nil, fmt.Errorf("%s: %d bytes of data for %d values", path, len(data), n)
+	}
+	a.values = make([]float32, n)
+	for i := range a.values {
+		switch descr[1] {
+		case "<f4":
+			a.values[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+		case "<u4":
+			a.values[i] = float32(binary.LittleEndian.Uint32(data[4*i:]))
+		default:
+			a.values[i] = float32(data[i])
+		}
+	}
+	return a, nil
+}
+
+// at is channel c of the value for cell (x, y) of a width by height grid,
+// from the bottom left, with the array in the middle of it. Cells past its
+// edges are 0.
+func (a *npyArray) at(x, y, c, width, height int) float32 {
+	x -= (width - a.width) / 2
+	y -= (height - a.height) / 2
+	if x < 0 || y < 0 || x >= a.width || y >= a.height || c >= a.channels {
+		return 0
+	}
+	return a.values[((a.height-1-y)*a.width+x)*a.channels+c]
+}
+
+// writeNPY writes f as a float32 array, or uint8 or uint32 for whole
+// numbered states, with the top row first. One channel makes a 2D array
+// and more a 3D one with a vector for each cell.
+func writeNPY(w io.Writer, f *fieldSet) error {
+	descr, size := "<f4", 4
+	if f.whole {
+		descr, size = "<u1", 1
+		for _, c := range f.channels {
+			for _, v := range c.values {
+				if v > 255 {
+					descr, size = "<u4", 4
+				}
+			}
+		}
+	}
+	shape := fmt.Sprintf("%d, %d", f.height, f.width)
+	if len(f.channels) > 1 {
+		shape += fmt.Sprintf(", %d", len(f.channels))
+	}
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%s), }", descr, shape)
+	// The header is padded with spaces so the data starts on a multiple of
+	// 64 bytes, and ends with a newline.
+	header += strings.Repeat(" ", 63-(10+len(header))%64) + "\n"
+
+	var head bytes.Buffer
+	head.WriteString("\x93NUMPY\x01\x00")
+	binary.Write(&head, binary.LittleEndian, uint16(len(header)))
+	head.WriteString(header)
+
+	b := bufio.NewWriter(w)
+	if _, err := b.Write(head.Bytes()); err != nil {
+		return err
+	}
+	value := make([]byte, 4)
+	for y := f.height - 1; y >= 0; y-- {
+		for x := 0; x < f.width; x++ {
+			for _, c := range f.channels {
+				v := c.values[y*f.width+x]
+				switch descr {
+				case "<f4":
+					binary.LittleEndian.PutUint32(value, math.Float32bits(v))
+				case "<u4":
+					binary.LittleEndian.PutUint32(value, uint32(v))
+				default:
+					value[0] = byte(v)
+				}
+				if _, err := b.Write(value[:size]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return b.Flush()
+}
+
+// handleArrayKey saves the simulation's state as a .npy array with Shift+N.
+func (s *State) handleArrayKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if key != glfw.KeyN || action != glfw.Press || mods&glfw.ModShift == 0 {
+		return
+	}
+	e, ok := s.sim.(fieldExporter)
+	if !ok {
+		fmt.Println("this simulation has no state to export")
+		return
+	}
+	f, err := e.Fields(s)
+	if err != nil {
+		fmt.Println("reading state:", err)
+		return
+	}
+	path := fmt.Sprintf("state-%06d.npy", s.steps)
+	file, err := os.Create(path)
+	if err == nil {
+		err = writeNPY(file, f)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Println("saving state:", err)
+		return
+	}
+	fmt.Printf("saved %s, %dx%d cells of %d channels\n", path, f.width, f.height, len(f.channels))
+}
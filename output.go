@@ -0,0 +1,454 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"image"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed output.wgsl
+var outputShader string
+
+// chooseSurfaceFormat picks the format to present in from those the surface
+// supports, and the format to draw in. The shaders work in linear colour, so
+// an sRGB format is best for both. Without one, the scene is drawn in the
+// sRGB version of the first format and the output pass encodes it on the
+// way to the surface. HDR scenes are drawn in half floats, and presented in
+// them too where the surface takes them.
+func chooseSurfaceFormat(formats []wgpu.TextureFormat, hdr bool) (surface, scene wgpu.TextureFormat) {
+	if hdr {
+		for _, f := range formats {
+			if f == hdrFormat {
+				return f, f
+			}
+		}
+		surface, _ = chooseSurfaceFormat(formats, false)
+		return surface, hdrFormat
+	}
+	for _, f := range formats {
+		if isSrgb(f) {
+			return f, f
+		}
+	}
+	switch formats[0] {
+	case wgpu.TextureFormat_BGRA8Unorm:
+		return formats[0], wgpu.TextureFormat_BGRA8UnormSrgb
+	case wgpu.TextureFormat_RGBA8Unorm:
+		return formats[0], wgpu.TextureFormat_RGBA8UnormSrgb
+	}
+	return formats[0], formats[0]
+}
+
+// hdrFormat is what HDR scenes are drawn in, and the extended range format
+// surfaces that can show them offer.
+const hdrFormat = wgpu.TextureFormat_RGBA16Float
+
+// hdr is whether the scene is drawn in HDR, for the output pass to bloom and
+// tonemap.
+func (s *State) hdr() bool {
+	return s.output != nil && s.output.hdr
+}
+
+// describeSurface says which formats the surface offers and how colours get
+// to it, for the capability report.
+func (s *State) describeSurface() string {
+	var how string
+	switch {
+	case s.hdr() && s.surfaceFormat == hdrFormat:
+		how = "drawn in HDR and shown past white where the display can"
+	case s.hdr() && isSrgb(s.surfaceFormat):
+		how = "drawn in HDR and tonemapped for an SDR display"
+	case s.hdr():
+		how = "drawn in HDR, tonemapped for an SDR display and encoded to sRGB in a shader"
+	case s.output != nil:
+		how = fmt.Sprintf("drawn in %s and encoded to sRGB in a shader", s.config.Format)
+	case isSrgb(s.surfaceFormat):
+		how = "encoded to sRGB by the surface"
+	default:
+		how = "written as they are, with no sRGB encoding"
+	}
+	return fmt.Sprintf("surface formats %v, presenting in %s: colours %s", s.surfaceFormats, s.surfaceFormat, how)
+}
+
+// outputPass sits last, taking the scene to the surface. HDR scenes get a
+// glow around whatever is brighter than white, and are tonemapped for
+// surfaces that can't go past it. Surfaces with no sRGB format have the
+// encoding done here.
+type outputPass struct {
+	hdr   bool
+	bloom float32
+
+	shader          *wgpu.ShaderModule
+	layout          *wgpu.BindGroupLayout
+	flagsLayout     *wgpu.BindGroupLayout
+	pipelineLayout  *wgpu.PipelineLayout
+	compositeLayout *wgpu.PipelineLayout
+	bright, blur    *wgpu.RenderPipeline
+	// params are for blurring across, blurring up, and compositing with
+	// and without the glow.
+	params [4]*wgpu.Buffer
+	// composites are by the format they draw into, the surface's and those
+	// frames are captured in.
+	composites map[wgpu.TextureFormat]*outputComposite
+
+	screen *outputTargets
+}
+
+type outputComposite struct {
+	pipeline *wgpu.RenderPipeline
+	flags    *wgpu.Buffer
+	set      *wgpu.BindGroup
+}
+
+// outputTargets are the scene the simulation is drawn into and the glow
+// taken from it, at a quarter of its size.
+type outputTargets struct {
+	width, height uint32
+	bloom         bool
+
+	scene     *wgpu.Texture
+	sceneView *wgpu.TextureView
+	glow      [2]*wgpu.Texture
+	glowViews [2]*wgpu.TextureView
+	// sets are the bright pass's, the two blurs' and the composite's.
+	sets [4]*wgpu.BindGroup
+}
+
+// glowScale is glowScale in output.wgsl.
+const glowScale = 4
+
+func newOutputPass(s *State, cfg HDRConfig) (o *outputPass, err error) {
+	if cfg.Enabled && cfg.Brightness < 1 {
+		return nil, fmt.Errorf("HDR brightness %g below 1", cfg.Brightness)
+	}
+	if cfg.Enabled && cfg.Bloom < 0 {
+		return nil, fmt.Errorf("bloom %g below 0", cfg.Bloom)
+	}
+
+	o = &outputPass{hdr: cfg.Enabled, composites: map[wgpu.TextureFormat]*outputComposite{}}
+	if o.hdr {
+		o.bloom = cfg.Bloom
+	}
+	defer func() {
+		if err != nil {
+			o.Release()
+		}
+	}()
+
+	o.shader = s.createShader("output shader", outputShader)
+
+	texture := wgpu.TextureBindingLayout{
+		SampleType:    wgpu.TextureSampleType_UnfilterableFloat,
+		ViewDimension: wgpu.TextureViewDimension_2D,
+	}
+	o.layout, err = s.bindGroupLayout("output",
+		bufferEntry(0, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		wgpu.BindGroupLayoutEntry{Binding: 1, Visibility: wgpu.ShaderStage_Fragment, Texture: texture},
+		wgpu.BindGroupLayoutEntry{Binding: 2, Visibility: wgpu.ShaderStage_Fragment, Texture: texture},
+	)
+	if err != nil {
+		return nil, err
+	}
+	o.flagsLayout, err = s.bindGroupLayout("output flags",
+		bufferEntry(0, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+	)
+	if err != nil {
+		return nil, err
+	}
+	o.pipelineLayout, err = s.pipelineLayout("output", o.layout)
+	if err != nil {
+		return nil, err
+	}
+	o.compositeLayout, err = s.pipelineLayout("output composite", o.layout, o.flagsLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	o.bright, err = o.pipeline(s, o.pipelineLayout, "bright_fs", hdrFormat)
+	if err != nil {
+		return nil, err
+	}
+	o.blur, err = o.pipeline(s, o.pipelineLayout, "blur_fs", hdrFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	params := func(dx, dy int32, bloom float32) []byte {
+		return append(wgpu.ToBytes([]int32{dx, dy}), wgpu.ToBytes([]float32{bloom, 0})...)
+	}
+	o.params = [4]*wgpu.Buffer{
+		s.uniformBuffer("output across", params(1, 0, 0)),
+		s.uniformBuffer("output up", params(0, 1, 0)),
+		s.uniformBuffer("output glow", params(0, 0, o.bloom)),
+		s.uniformBuffer("output plain", params(0, 0, 0)),
+	}
+
+	o.screen, err = o.targets(s, s.config.Width, s.config.Height, o.bloom > 0)
+	if err != nil {
+		return nil, err
+	}
+	_, err = o.composite(s, s.surfaceFormat)
+	return o, err
+}
+
+func (o *outputPass) pipeline(s *State, layout *wgpu.PipelineLayout, entryPoint string, format wgpu.TextureFormat) (*wgpu.RenderPipeline, error) {
+	return s.device.CreateRenderPipeline(&wgpu.RenderPipelineDescriptor{
+		Label:  "output " + entryPoint,
+		Layout: layout,
+		Vertex: wgpu.VertexState{
+			Module:     o.shader,
+			EntryPoint: "main_vs",
+		},
+		Fragment: &wgpu.FragmentState{
+			Module:     o.shader,
+			EntryPoint: entryPoint,
+			Targets:    []wgpu.ColorTargetState{{Format: format, WriteMask: wgpu.ColorWriteMask_All}},
+		},
+		Primitive: wgpu.PrimitiveState{
+			Topology: wgpu.PrimitiveTopology_TriangleList,
+		},
+		Multisample: wgpu.MultisampleState{
+			Count: 1,
+			Mask:  0xFFFFFFFF,
+		},
+	})
+}
+
+// composite is the pass drawing the scene into format, made the first time
+// it is needed.
+func (o *outputPass) composite(s *State, format wgpu.TextureFormat) (c *outputComposite, err error) {
+	if c := o.composites[format]; c != nil {
+		return c, nil
+	}
+	c = &outputComposite{}
+	defer func() {
+		if err != nil {
+			c.Release()
+		}
+	}()
+	c.pipeline, err = o.pipeline(s, o.compositeLayout, "composite_fs", format)
+	if err != nil {
+		return nil, err
+	}
+	var tonemap, encode uint32
+	if format != hdrFormat {
+		if o.hdr {
+			tonemap = 1
+		}
+		if !isSrgb(format) {
+			encode = 1
+		}
+	}
+	c.flags = s.uniformBuffer("output flags", wgpu.ToBytes([]uint32{tonemap, encode, 0, 0}))
+	c.set, err = s.device.CreateBindGroup(&wgpu.BindGroupDescriptor{
+		Label:   "output flags",
+		Layout:  o.flagsLayout,
+		Entries: []wgpu.BindGroupEntry{{Binding: 0, Buffer: c.flags, Size: wgpu.WholeSize}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	o.composites[format] = c
+	return c, nil
+}
+
+// targets makes a scene of width by height to draw into, and room for its
+// glow if it is to have one.
+func (o *outputPass) targets(s *State, width, height uint32, bloom bool) (t *outputTargets, err error) {
+	t = &outputTargets{width: width, height: height, bloom: bloom}
+	defer func() {
+		if err != nil {
+			t.Release()
+		}
+	}()
+	t.scene, t.sceneView, err = s.sizedRenderTexture("output scene", s.config.Format, width, height)
+	if err != nil {
+		return nil, err
+	}
+	glowWidth, glowHeight := max((width+glowScale-1)/glowScale, 1), max((height+glowScale-1)/glowScale, 1)
+	for i := range t.glow {
+		t.glow[i], t.glowViews[i], err = s.sizedRenderTexture("output glow", hdrFormat, glowWidth, glowHeight)
+		if err != nil {
+			return nil, err
+		}
+	}
+	composite := o.params[3]
+	if bloom {
+		composite = o.params[2]
+	}
+	inputs := [4]struct {
+		params         *wgpu.Buffer
+		source, second *wgpu.TextureView
+	}{
+		{o.params[3], t.sceneView, t.glowViews[1]},
+		{o.params[0], t.glowViews[0], t.sceneView},
+		{o.params[1], t.glowViews[1], t.sceneView},
+		{composite, t.sceneView, t.glowViews[0]},
+	}
+	for i, in := range inputs {
+		t.sets[i], err = s.device.CreateBindGroup(&wgpu.BindGroupDescriptor{
+			Label:  "output",
+			Layout: o.layout,
+			Entries: []wgpu.BindGroupEntry{
+				{Binding: 0, Buffer: in.params, Size: wgpu.WholeSize},
+				{Binding: 1, TextureView: in.source},
+				{Binding: 2, TextureView: in.second},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (o *outputPass) input() *wgpu.TextureView {
+	return o.screen.sceneView
+}
+
+// resize recreates the scene to match the swapchain.
+func (o *outputPass) resize(s *State) (err error) {
+	o.screen.Release()
+	o.screen, err = o.targets(s, s.config.Width, s.config.Height, o.bloom > 0)
+	return err
+}
+
+// apply draws the scene into view, which is in the surface's format.
+func (o *outputPass) apply(s *State, encoder *commandEncoder, view *wgpu.TextureView) {
+	o.draw(encoder, o.screen, o.composites[s.surfaceFormat], view)
+}
+
+func (o *outputPass) draw(encoder *commandEncoder, t *outputTargets, c *outputComposite, view *wgpu.TextureView) {
+	if t.bloom {
+		o.pass(encoder, t.glowViews[0], o.bright, t.sets[0])
+		o.pass(encoder, t.glowViews[1], o.blur, t.sets[1])
+		o.pass(encoder, t.glowViews[0], o.blur, t.sets[2])
+	}
+	o.pass(encoder, view, c.pipeline, t.sets[3], c.set)
+}
+
+func (o *outputPass) pass(encoder *commandEncoder, view *wgpu.TextureView, pipeline *wgpu.RenderPipeline, sets ...*wgpu.BindGroup) {
+	pass := encoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
+		ColorAttachments: []wgpu.RenderPassColorAttachment{attachColourToView(view, wgpu.Color{A: 1})},
+	})
+	defer pass.Release()
+
+	pass.SetPipeline(pipeline)
+	for i, set := range sets {
+		pass.SetBindGroup(uint32(i), set, nil)
+	}
+	pass.Draw(3, 1, 0, 0)
+	pass.End()
+}
+
+// capture has render draw a width by height scene and reads it back as an
+// SDR display would show it. The glow is sized for the window, so scenes of
+// other sizes go without.
+func (o *outputPass) capture(s *State, width, height uint32, render func(encoder *commandEncoder, view *wgpu.TextureView)) (*image.RGBA, error) {
+	format := s.exportFormat()
+	c, err := o.composite(s, format)
+	if err != nil {
+		return nil, err
+	}
+	t := o.screen
+	if width != t.width || height != t.height {
+		if t, err = o.targets(s, width, height, false); err != nil {
+			return nil, err
+		}
+		defer t.Release()
+	}
+	return s.capture(format, width, height, func(encoder *commandEncoder, view *wgpu.TextureView) {
+		render(encoder, t.sceneView)
+		o.draw(encoder, t, c, view)
+	})
+}
+
+func (t *outputTargets) Release() {
+	if t == nil {
+		return
+	}
+	for i := range t.sets {
+		if t.sets[i] != nil {
+			t.sets[i].Release()
+			t.sets[i] = nil
+		}
+	}
+	for i := range t.glow {
+		if t.glowViews[i] != nil {
+			t.glowViews[i].Release()
+			t.glowViews[i] = nil
+		}
+		if t.glow[i] != nil {
+			t.glow[i].Release()
+			t.glow[i] = nil
+		}
+	}
+	if t.sceneView != nil {
+		t.sceneView.Release()
+		t.sceneView = nil
+	}
+	if t.scene != nil {
+		t.scene.Release()
+		t.scene = nil
+	}
+}
+
+func (c *outputComposite) Release() {
+	if c.set != nil {
+		c.set.Release()
+		c.set = nil
+	}
+	if c.flags != nil {
+		c.flags.Release()
+		c.flags = nil
+	}
+	if c.pipeline != nil {
+		c.pipeline.Release()
+		c.pipeline = nil
+	}
+}
+
+func (o *outputPass) Release() {
+	o.screen.Release()
+	o.screen = nil
+	for format, c := range o.composites {
+		c.Release()
+		delete(o.composites, format)
+	}
+	for i := range o.params {
+		if o.params[i] != nil {
+			o.params[i].Release()
+			o.params[i] = nil
+		}
+	}
+	if o.bright != nil {
+		o.bright.Release()
+		o.bright = nil
+	}
+	if o.blur != nil {
+		o.blur.Release()
+		o.blur = nil
+	}
+	if o.pipelineLayout != nil {
+		o.pipelineLayout.Release()
+		o.pipelineLayout = nil
+	}
+	if o.compositeLayout != nil {
+		o.compositeLayout.Release()
+		o.compositeLayout = nil
+	}
+	if o.layout != nil {
+		o.layout.Release()
+		o.layout = nil
+	}
+	if o.flagsLayout != nil {
+		o.flagsLayout.Release()
+		o.flagsLayout = nil
+	}
+	if o.shader != nil {
+		o.shader.Release()
+		o.shader = nil
+	}
+}
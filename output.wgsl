@@ -0,0 +1,100 @@
+struct Params {
+  // direction is the step blur_fs takes between taps, across or up.
+  direction: vec2<i32>,
+  // bloom is how much of the glow composite_fs adds to the scene.
+  bloom: f32,
+  _pad: f32,
+};
+
+// Flags say what composite_fs does for the surface it draws into.
+struct Flags {
+  // tonemap squeezes HDR colours into the 0 to 1 an SDR surface shows.
+  tonemap: u32,
+  // encode applies the sRGB transfer function the surface doesn't.
+  encode: u32,
+};
+
+@group(0) @binding(0) var<uniform> params: Params;
+@group(0) @binding(1) var source: texture_2d<f32>;
+@group(0) @binding(2) var glow: texture_2d<f32>;
+@group(1) @binding(0) var<uniform> flags: Flags;
+
+// glowScale is how many scene pixels a glow pixel covers along each side.
+const glowScale = 4;
+
+// One triangle covering the whole screen.
+@vertex
+fn main_vs(@builtin(vertex_index) i: u32) -> @builtin(position) vec4<f32> {
+  let uv = vec2<f32>(f32((i << 1u) & 2u), f32(i & 2u));
+  return vec4<f32>(uv * 2.0 - 1.0, 0.0, 1.0);
+}
+
+fn load(t: texture_2d<f32>, p: vec2<i32>) -> vec3<f32> {
+  let size = vec2<i32>(textureDimensions(t));
+  return textureLoad(t, clamp(p, vec2(0), size - 1), 0).rgb;
+}
+
+// bright_fs keeps what is brighter than white, averaged down to the glow's
+// size.
+@fragment
+fn bright_fs(@builtin(position) pos: vec4<f32>) -> @location(0) vec4<f32> {
+  let base = vec2<i32>(pos.xy) * glowScale;
+  var sum = vec3(0.0);
+  for (var y = 0; y < glowScale; y++) {
+    for (var x = 0; x < glowScale; x++) {
+      sum += max(load(source, base + vec2(x, y)) - 1.0, vec3(0.0));
+    }
+  }
+  return vec4<f32>(sum / f32(glowScale * glowScale), 1.0);
+}
+
+// blur_fs is one direction of a Gaussian blur.
+@fragment
+fn blur_fs(@builtin(position) pos: vec4<f32>) -> @location(0) vec4<f32> {
+  // A var, as naga only indexes constant arrays by constants.
+  var weights = array<f32, 5>(0.227027, 0.1945946, 0.1216216, 0.054054, 0.016216);
+  let p = vec2<i32>(pos.xy);
+  var sum = load(source, p) * weights[0];
+  for (var i = 1; i < 5; i++) {
+    let d = params.direction * i;
+    sum += (load(source, p + d) + load(source, p - d)) * weights[i];
+  }
+  return vec4<f32>(sum, 1.0);
+}
+
+// The glow is a quarter of the size, so it is filtered up by hand.
+fn upsampled(pos: vec2<f32>) -> vec3<f32> {
+  let p = pos / f32(glowScale) - 0.5;
+  let i = vec2<i32>(floor(p));
+  let f = fract(p);
+  let bottom = mix(load(glow, i), load(glow, i + vec2(1, 0)), f.x);
+  let top = mix(load(glow, i + vec2(0, 1)), load(glow, i + vec2(1, 1)), f.x);
+  return mix(bottom, top, f.y);
+}
+
+// ACES filmic curve, as fitted by Krzysztof Narkowicz.
+fn tonemap(c: vec3<f32>) -> vec3<f32> {
+  return clamp((c * (2.51 * c + 0.03)) / (c * (2.43 * c + 0.59) + 0.14), vec3(0.0), vec3(1.0));
+}
+
+fn encode(c: vec3<f32>) -> vec3<f32> {
+  let low = c * 12.92;
+  let high = 1.055 * pow(c, vec3(1.0 / 2.4)) - 0.055;
+  return select(high, low, c <= vec3(0.0031308));
+}
+
+// The scene is read back linear, whichever format it is in.
+@fragment
+fn composite_fs(@builtin(position) pos: vec4<f32>) -> @location(0) vec4<f32> {
+  var c = textureLoad(source, vec2<i32>(pos.xy), 0).rgb;
+  if params.bloom > 0.0 {
+    c += upsampled(pos.xy) * params.bloom;
+  }
+  if flags.tonemap != 0u {
+    c = tonemap(c);
+  }
+  if flags.encode != 0u {
+    c = encode(c);
+  }
+  return vec4<f32>(c, 1.0);
+}
@@ -0,0 +1,104 @@
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+// Each row is packed into rowWords() words, cell x in bit x % 32. Bits past
+// the end of a row are always zero.
+@group(0) @binding(1) var<storage> cellStateIn: array<u32>;
+@group(0) @binding(2) var<storage, read_write> cellStateOut: array<u32>;
+// 0 wraps around (torus), 1 treats cells beyond the edges as dead and 2
+// mirrors the edge cells.
+@group(0) @binding(3) var<uniform> boundary: u32;
+
+// Each invocation steps the 32 cells in one word. The rows above and below
+// come in a word at a time, and only the cells either side of the word are
+// read one by one.
+@compute
+@workgroup_size(16)
+fn main(@builtin(global_invocation_id) id: vec3<u32>) {
+      let size = vec2<i32>(grid);
+      if id.x >= rowWords() || id.y >= u32(size.y) {
+        return;
+      }
+      let x = i32(id.x * 32u);
+      let y = i32(id.y);
+      // The number of cells in this word, less than 32 at the end of a row.
+      let n = u32(min(size.x - x, 32));
+
+      var west: array<u32, 3>;
+      var centre: array<u32, 3>;
+      var east: array<u32, 3>;
+      for (var r = 0; r < 3; r++) {
+        let word = rowWord(y + r - 1, id.x);
+        // Shifted so that bit b holds the neighbour of cell b to the west
+        // or east.
+        west[r] = (word << 1u) | cellActive(x - 1, y + r - 1);
+        centre[r] = word;
+        east[r] = (word >> 1u) | (cellActive(x + i32(n), y + r - 1) << (n - 1u));
+      }
+
+      let current = centre[1];
+      var next = 0u;
+      for (var b = 0u; b < n; b++) {
+        let activeNeighbors = bit(west[0], b) + bit(centre[0], b) + bit(east[0], b) +
+                              bit(west[1], b) + bit(east[1], b) +
+                              bit(west[2], b) + bit(centre[2], b) + bit(east[2], b);
+
+        // Conway's game of life rules:
+        if activeNeighbors == 3u || (activeNeighbors == 2u && bit(current, b) == 1u) {
+          next |= 1u << b;
+        }
+      }
+      cellStateOut[id.y * rowWords() + id.x] = next;
+}
+
+fn bit(word: u32, b: u32) -> u32 {
+  return (word >> b) & 1u;
+}
+
+fn rowWords() -> u32 {
+  return (u32(grid.x) + 31u) / 32u;
+}
+
+// rowWord returns word w of row y, or zero if the row is beyond a dead edge.
+fn rowWord(y: i32, w: u32) -> u32 {
+  let size = vec2<i32>(grid);
+  var row = y;
+  switch boundary {
+    case 1u: {
+      if row < 0 || row >= size.y {
+        return 0u;
+      }
+    }
+    case 2u: {
+      row = mirror(vec2(0, row), size).y;
+    }
+    default: {
+      row = (row % size.y + size.y) % size.y;
+    }
+  }
+  return cellStateIn[u32(row) * rowWords() + w];
+}
+
+fn cellActive(x: i32, y: i32) -> u32 {
+  let size = vec2<i32>(grid);
+  var cell = vec2(x, y);
+  switch boundary {
+    case 1u: {
+      if any(cell < vec2(0)) || any(cell >= size) {
+        return 0u;
+      }
+    }
+    case 2u: {
+      cell = mirror(cell, size);
+    }
+    default: {
+      cell = (cell % size + size) % size;
+    }
+  }
+  let c = vec2<u32>(cell);
+  return bit(cellStateIn[c.y * rowWords() + c.x / 32u], c.x % 32u);
+}
+
+// Reflects coordinates just outside the grid back onto the edge cells.
+fn mirror(cell: vec2<i32>, size: vec2<i32>) -> vec2<i32> {
+  return select(cell, -cell - 1, cell < vec2(0)) -
+         select(vec2(0), 2 * (cell - size) + 1, cell >= size);
+}
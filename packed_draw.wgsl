@@ -0,0 +1,53 @@
+// palette.wgsl and camera.wgsl go after this.
+struct VertexInput {
+  @location(0) pos: vec2<f32>,
+  @builtin(instance_index) instance: u32,
+};
+
+struct VertexOutput {
+  @builtin(position) pos: vec4<f32>,
+  @location(0) cell: vec2<f32>,
+  // local runs from -1 to 1 across the cell's quad.
+  @location(1) local: vec2<f32>,
+};
+
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+// Each row is packed into (width + 31) / 32 words, cell x in bit x % 32.
+@group(0) @binding(1) var<storage> cellStateIn: array<u32>;
+@group(0) @binding(2) var<storage, read_write> cellStateOut: array<u32>;
+@group(0) @binding(6) var<uniform> palette: Palette;
+// visible are the cells culled_vs draws.
+@group(2) @binding(0) var<storage> visible: array<u32>;
+
+@vertex
+fn main_vs(input: VertexInput) -> VertexOutput {
+    return cellVertex(input.pos, input.instance);
+}
+
+// culled_vs draws the cells cull.wgsl picked out, each instance the one at
+// its place in visible.
+@vertex
+fn culled_vs(input: VertexInput) -> VertexOutput {
+    return cellVertex(input.pos, visible[input.instance]);
+}
+
+fn cellVertex(pos: vec2<f32>, index: u32) -> VertexOutput {
+    let width = u32(grid.x);
+    let cell = vec2<u32>(index % width, index / width);
+    let word = cellStateIn[cell.y * ((width + 31u) / 32u) + cell.x / 32u];
+    let state = f32((word >> (cell.x % 32u)) & 1u);
+
+    let cellOffset = vec2<f32>(cell) / grid * 2.0;
+    let gridPos = (state*pos + 1.0) / grid - 1.0 + cellOffset;
+
+    var output: VertexOutput;
+    output.pos = camera * vec4<f32>(gridPos, 0.0, 1.0);
+    output.local = pos / 0.8;
+    output.cell = vec2<f32>(cell);
+    return output;
+}
+
+@fragment
+fn main_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+    return shapeColour(palette, paletteColour(palette, input.cell / grid), cellShape(palette, input.local, fwidth(input.local)));
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"sort"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed palette.wgsl
+var paletteShader string
+
+// palette is the background and the corner colours of a palette, and the
+// shape cells are drawn in; see palette.wgsl.
+type palette struct {
+	background [3]float32
+	corners    [4][3]float32
+	// shape is one of cellShapes, softness how far across the cell its edge
+	// blurs into the background.
+	shape    string
+	softness float32
+	// brightness scales live cells, past white in HDR; 0 leaves them be.
+	brightness float32
+}
+
+// cellShapes are the shapes -cell-shape can name, by their number in
+// palette.wgsl.
+var cellShapes = map[string]float32{
+	"square":  0,
+	"circle":  1,
+	"rounded": 2,
+}
+
+func cellShapeNames() []string {
+	names := make([]string, 0, len(cellShapes))
+	for name := range cellShapes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// palettes are the palettes -palette can name. classic is the colouring
+// cells have always had. deuteranopia, protanopia and tritanopia keep to
+// colours that can still be told apart with those kinds of colour
+// blindness, after Okabe and Ito, and high-contrast is bright yellow on
+// black. Unlike -colourblind-safe they change only the cells' colours, not
+// everything drawn.
+var palettes = map[string]palette{
+	"classic": {
+		background: [3]float32{0, 0.01, 0.05},
+		corners:    [4][3]float32{{0, 0, 1}, {1, 0, 0}, {0, 1, 1}, {1, 1, 0}},
+	},
+	"ember": {
+		background: [3]float32{0.03, 0, 0},
+		corners:    [4][3]float32{{0.6, 0.05, 0}, {1, 0.4, 0}, {0.9, 0.2, 0.1}, {1, 0.9, 0.4}},
+	},
+	"ocean": {
+		background: [3]float32{0, 0.02, 0.04},
+		corners:    [4][3]float32{{0, 0.2, 0.4}, {0, 0.6, 0.7}, {0.1, 0.4, 0.9}, {0.6, 0.95, 1}},
+		shape:      "circle",
+		softness:   0.15,
+	},
+	"forest": {
+		background: [3]float32{0.01, 0.03, 0.01},
+		corners:    [4][3]float32{{0.1, 0.4, 0.1}, {0.5, 0.7, 0.1}, {0, 0.5, 0.4}, {0.8, 0.9, 0.5}},
+	},
+	"mono": {
+		corners: [4][3]float32{{0.9, 0.9, 0.9}, {0.9, 0.9, 0.9}, {0.9, 0.9, 0.9}, {0.9, 0.9, 0.9}},
+	},
+	"paper": {
+		background: [3]float32{0.95, 0.93, 0.88},
+		corners:    [4][3]float32{{0.1, 0.1, 0.15}, {0.3, 0.1, 0.1}, {0.1, 0.2, 0.3}, {0.2, 0.2, 0.2}},
+		shape:      "rounded",
+	},
+	// Red and green look alike, so these go from blue to orange and yellow.
+	"deuteranopia": {
+		background: [3]float32{0, 0.01, 0.03},
+		corners:    [4][3]float32{{0, 0.45, 0.7}, {0.9, 0.6, 0}, {0.35, 0.7, 0.9}, {0.95, 0.9, 0.25}},
+	},
+	// Reds also look dark, so there are none and the yellow end is lighter.
+	"protanopia": {
+		background: [3]float32{0, 0.01, 0.03},
+		corners:    [4][3]float32{{0, 0.45, 0.7}, {0.95, 0.9, 0.25}, {0.35, 0.7, 0.9}, {1, 1, 0.8}},
+	},
+	// Blue and yellow look alike, so this goes from red to teal and pink.
+	"tritanopia": {
+		background: [3]float32{0.02, 0.01, 0.01},
+		corners:    [4][3]float32{{0.85, 0.15, 0.1}, {0, 0.6, 0.6}, {1, 0.6, 0.7}, {0.6, 0.95, 0.95}},
+	},
+	"high-contrast": {
+		corners: [4][3]float32{{1, 1, 0}, {1, 1, 0}, {1, 1, 0}, {1, 1, 0}},
+	},
+}
+
+func paletteNames() []string {
+	names := make([]string, 0, len(palettes))
+	for name := range palettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// configPaletteNames are the built-in palettes' names and those of cfg's
+// own, in order.
+func configPaletteNames(cfg *Config) []string {
+	names := paletteNames()
+	for name := range cfg.Palettes {
+		if _, ok := palettes[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupPalette finds the palette called name, among cfg's own first.
+func lookupPalette(cfg *Config, name string) (palette, bool) {
+	if c, ok := cfg.Palettes[name]; ok {
+		return palette{background: c.Background, corners: c.Colours, shape: c.Shape, softness: c.Softness}, true
+	}
+	p, ok := palettes[name]
+	return p, ok
+}
+
+// bytes lays the palette out as palette.wgsl's Palette.
+func (p palette) bytes() []byte {
+	colours := append([]float32{}, p.background[0], p.background[1], p.background[2], 1)
+	for _, c := range p.corners {
+		colours = append(colours, c[0], c[1], c[2], 1)
+	}
+	colours = append(colours, cellShapes[p.shape], p.softness, max(p.brightness, 1), 0)
+	return wgpu.ToBytes(colours)
+}
+
+// clear is the palette's background, for clearing the window to.
+func (p palette) clear() wgpu.Color {
+	return wgpu.Color{R: float64(p.background[0]), G: float64(p.background[1]), B: float64(p.background[2]), A: 1}
+}
+
+// initPalette uploads the palette cfg names for the cell shaders to bind,
+// with the cell shape and softness cfg gives in place of its own.
+func (s *State) initPalette(cfg *Config) error {
+	p, err := s.paletteFor(cfg)
+	if err != nil {
+		return err
+	}
+	s.palette = p
+	s.paletteBuffer = s.uniformBuffer("palette", p.bytes())
+	return nil
+}
+
+// paletteFor is the palette cfg names, as initPalette uses it.
+func (s *State) paletteFor(cfg *Config) (palette, error) {
+	p, ok := lookupPalette(cfg, cfg.Palette)
+	if !ok {
+		return palette{}, fmt.Errorf("unknown palette %q (have %v)", cfg.Palette, configPaletteNames(cfg))
+	}
+	if cfg.CellShape != "" {
+		p.shape = cfg.CellShape
+	}
+	if p.shape == "" {
+		p.shape = "square"
+	}
+	if _, ok := cellShapes[p.shape]; !ok {
+		return palette{}, fmt.Errorf("unknown cell shape %q (have %v)", p.shape, cellShapeNames())
+	}
+	if cfg.CellSoftness >= 0 {
+		p.softness = cfg.CellSoftness
+	}
+	if p.softness > 1 {
+		return palette{}, fmt.Errorf("cell softness %g out of range [0, 1]", p.softness)
+	}
+	if s.hdr() {
+		p.brightness = cfg.HDR.Brightness
+	}
+	return p, nil
+}
+
+// setPalette switches to the palette called name while running.
+func (s *State) setPalette(name string) error {
+	cfg := *s.cfg
+	cfg.Palette = name
+	p, err := s.paletteFor(&cfg)
+	if err != nil {
+		return err
+	}
+	if err := s.queue.WriteBuffer(s.paletteBuffer, 0, p.bytes()); err != nil {
+		return err
+	}
+	s.palette = p
+	s.cfg = &cfg
+	return nil
+}
+
+// handlePaletteKey switches to the next palette with T, and back to the one
+// before with Shift+T.
+func (s *State) handlePaletteKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if key != glfw.KeyT || action != glfw.Press {
+		return
+	}
+	names := configPaletteNames(s.cfg)
+	step := 1
+	if mods&glfw.ModShift != 0 {
+		step = len(names) - 1
+	}
+	next := names[0]
+	for i, name := range names {
+		if name == s.cfg.Palette {
+			next = names[(i+step)%len(names)]
+		}
+	}
+	if err := s.setPalette(next); err != nil {
+		fmt.Println("switching palette:", err)
+		return
+	}
+	fmt.Println("palette", next)
+}
@@ -0,0 +1,49 @@
+// A palette colours cells by where they are on the grid, blending its
+// corner colours across it, and fills the background behind them. Shaders
+// that use it bind the palette uniform and have this file put after them.
+struct Palette {
+  background: vec4<f32>,
+  // corners are the colours at the bottom left, bottom right, top left and
+  // top right of the grid.
+  corners: array<vec4<f32>, 4>,
+  // shape.x is the shape cells are drawn in: 0 for squares, 1 for circles
+  // and 2 for rounded squares. shape.y is how far across a cell its edges
+  // blur into the background. shape.z is how bright cells are, past 1 only
+  // when the scene is drawn in HDR.
+  shape: vec4<f32>,
+};
+
+// paletteColour is the colour at c, from (0, 0) at the bottom left of the
+// grid to (1, 1) at the top right.
+fn paletteColour(p: Palette, c: vec2<f32>) -> vec3<f32> {
+  let bottom = mix(p.corners[0].rgb, p.corners[1].rgb, c.x);
+  let top = mix(p.corners[2].rgb, p.corners[3].rgb, c.x);
+  return mix(bottom, top, c.y);
+}
+
+// cellShape is how much of a cell the palette's shape covers at local, from
+// (-1, -1) to (1, 1) across the quad it is drawn as. pixel is fwidth(local),
+// which the fragment shader has to take itself: GL builds every function
+// into the vertex shader too, where there are no derivatives.
+fn cellShape(p: Palette, local: vec2<f32>, pixel: vec2<f32>) -> f32 {
+  if p.shape.x == 0.0 && p.shape.y == 0.0 {
+    return 1.0;
+  }
+  // Signed distances from the edge, negative inside.
+  var d = max(abs(local.x), abs(local.y)) - 1.0;
+  if p.shape.x == 1.0 {
+    d = length(local) - 1.0;
+  } else if p.shape.x == 2.0 {
+    let radius = 0.4;
+    let q = abs(local) - (1.0 - radius);
+    d = length(max(q, vec2(0.0))) + min(max(q.x, q.y), 0.0) - radius;
+  }
+  let edge = max(pixel.x, pixel.y) + 2.0 * p.shape.y;
+  return 1.0 - smoothstep(-edge, 0.0, d);
+}
+
+// shapeColour is colour where the cell's shape covers it, blending into the
+// background around it.
+fn shapeColour(p: Palette, colour: vec3<f32>, coverage: f32) -> vec4<f32> {
+  return vec4<f32>(mix(p.background.rgb, colour * p.shape.z, coverage), 1.0);
+}
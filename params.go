@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// parameterRegistry is where every control finds the running simulation's
+// parameters and changes them: the dashboard and its WebSocket clients as
+// much as the keys. It keeps the last values it saw and tells everyone
+// watching whenever they change, whichever control changed them, so that
+// none of them has to keep its own copy in step.
+type parameterRegistry struct {
+	mu       sync.Mutex
+	current  parameterSnapshot
+	watchers map[chan parameterSnapshot]struct{}
+}
+
+// parameterSnapshot is the simulation's parameters, and the effects', at one
+// moment.
+type parameterSnapshot struct {
+	Simulation string      `json:"simulation"`
+	Parameters []parameter `json:"parameters"`
+}
+
+func newParameterRegistry() *parameterRegistry {
+	return &parameterRegistry{watchers: map[chan parameterSnapshot]struct{}{}}
+}
+
+// refresh reads the simulation's parameters and passes them on to the
+// watchers if they have changed. It runs on the main thread, after each
+// frame and after anything sets a parameter.
+func (r *parameterRegistry) refresh(s *State) {
+	if r == nil {
+		return
+	}
+	snap := parameterSnapshot{Simulation: s.cfg.Simulation, Parameters: []parameter{}}
+	for _, t := range s.tunables() {
+		snap.Parameters = append(snap.Parameters, t.Parameters()...)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if snap.Simulation == r.current.Simulation && slices.Equal(snap.Parameters, r.current.Parameters) {
+		return
+	}
+	r.current = snap
+	for w := range r.watchers {
+		// A watcher only needs the latest values, so any it hasn't read
+		// yet are dropped for these.
+		select {
+		case <-w:
+		default:
+		}
+		w <- snap
+	}
+}
+
+// snapshot is the parameters as they were last seen.
+func (r *parameterRegistry) snapshot() parameterSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// watch returns a channel that gets the parameters as they are now and then
+// every time they change, and a function to stop watching.
+func (r *parameterRegistry) watch() (<-chan parameterSnapshot, func()) {
+	w := make(chan parameterSnapshot, 1)
+	r.mu.Lock()
+	r.watchers[w] = struct{}{}
+	w <- r.current
+	r.mu.Unlock()
+	return w, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if _, ok := r.watchers[w]; ok {
+			delete(r.watchers, w)
+			close(w)
+		}
+	}
+}
+
+// Close stops everyone watching.
+func (r *parameterRegistry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for w := range r.watchers {
+		delete(r.watchers, w)
+		close(w)
+	}
+}
+
+// tunables are everything with parameters: the simulation, if it has any,
+// and the effects.
+func (s *State) tunables() []tunable {
+	var ts []tunable
+	if t, ok := s.sim.(tunable); ok {
+		ts = append(ts, t)
+	}
+	if s.post != nil {
+		ts = append(ts, s.post)
+	}
+	return ts
+}
+
+// setParameter sets one of the simulation's or the effects' parameters, on
+// the main thread.
+func (s *State) setParameter(name string, value float64) error {
+	t := s.tunableWith(name)
+	if t == nil {
+		return fmt.Errorf("%s has no parameter %q", s.cfg.Simulation, name)
+	}
+	if err := t.SetParameter(name, value); err != nil {
+		return err
+	}
+	s.parameters.refresh(s)
+	s.macros.record(macroStep{Parameter: name, Value: value})
+	return nil
+}
+
+func (s *State) tunableWith(name string) tunable {
+	for _, t := range s.tunables() {
+		for _, p := range t.Parameters() {
+			if p.Name == name {
+				return t
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// pattern is a block of life cells, row by row from the bottom as cellRect
+// has them, 0 for dead.
+type pattern struct {
+	name          string
+	width, height int
+	cells         []uint32
+}
+
+// builtinPatterns are the patterns there are to stamp without any files,
+// in RLE.
+var builtinPatterns = []struct{ name, rle string }{
+	{"glider", "bob$2bo$3o!"},
+	{"lightweight spaceship", "bo2bo$o4b$o3bo$4o!"},
+	{"middleweight spaceship", "3bo2b$bo3bo$o5b$o4bo$5o!"},
+	{"heavyweight spaceship", "3b2o2b$bo4bo$o6b$o5bo$6o!"},
+	{"gosper glider gun", "24bo11b$22bobo11b$12b2o6b2o12b2o$11bo3bo4b2o12b2o$2o8bo5bo3b2o14b$2o8bo3bob2o4bobo11b$10bo5bo7bo11b$11bo3bo20b$12b2o22b!"},
+	{"r-pentomino", "b2o$2o$bo!"},
+	{"acorn", "bo5b$3bo3b$2o2b3o!"},
+	{"diehard", "6bob$2o6b$bo3b3o!"},
+}
+
+func (p *pattern) at(x, y int) uint32 {
+	return p.cells[y*p.width+x]
+}
+
+// rotated is the pattern turned a quarter clockwise.
+func (p *pattern) rotated() *pattern {
+	r := &pattern{name: p.name, width: p.height, height: p.width, cells: make([]uint32, len(p.cells))}
+	for y := 0; y < p.height; y++ {
+		for x := 0; x < p.width; x++ {
+			r.cells[(p.width-1-x)*r.width+y] = p.at(x, y)
+		}
+	}
+	return r
+}
+
+// flipped is the pattern mirrored left to right.
+func (p *pattern) flipped() *pattern {
+	f := &pattern{name: p.name, width: p.width, height: p.height, cells: make([]uint32, len(p.cells))}
+	for y := 0; y < p.height; y++ {
+		for x := 0; x < p.width; x++ {
+			f.cells[y*f.width+p.width-1-x] = p.at(x, y)
+		}
+	}
+	return f
+}
+
+// A pattern can be no more than maxPatternSize cells across or up, the
+// widest a sparse life view goes, and no more than maxPatternCells in all,
+// so that a bad file can't take all the memory there is.
+const (
+	maxPatternSize  = maxSparseView
+	maxPatternCells = 1 << 24
+)
+
+// parseRLE reads a pattern in run length encoded form: # comment lines, a
+// header with its size, then runs of dead cells (b) and live ones (any
+// other letter), rows ending in $ and the pattern in !. The header can be
+// left out, as the built-in patterns do, and the size is then that of the
+// runs. A #N line names it.
+func parseRLE(name, text string) (*pattern, error) {
+	var body strings.Builder
+	var width, height int
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "#N"):
+			name = strings.TrimSpace(line[2:])
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "x"):
+			// The size keeps any dead rows and columns the runs leave out.
+			fmt.Sscanf(strings.ReplaceAll(line, " ", ""), "x=%d,y=%d", &width, &height)
+			if width < 0 || height < 0 || width > maxPatternSize || height > maxPatternSize || width*height > maxPatternCells {
+				return nil, fmt.Errorf("%s: a %dx%d pattern is too big", name, width, height)
+			}
+		default:
+			body.WriteString(line)
+		}
+	}
+
+	// rows are filled top down, as RLE has them. cells is how many they
+	// have had so far, and widest the longest.
+	var rows [][]uint32
+	row, run := []uint32{}, 0
+	cells, widest := 0, 0
+	tooBig := fmt.Errorf("%s: pattern is bigger than %dx%d or %d cells", name, maxPatternSize, maxPatternSize, maxPatternCells)
+	for _, c := range body.String() {
+		n := max(run, 1)
+		switch {
+		case c >= '0' && c <= '9':
+			if run = run*10 + int(c-'0'); run > maxPatternSize {
+				return nil, tooBig
+			}
+			continue
+		case c == '!':
+			if max(widest, width)*max(len(rows)+1, height) > maxPatternCells {
+				return nil, tooBig
+			}
+			rows = append(rows, row)
+			for len(rows) < height {
+				rows = append(rows, nil)
+			}
+			p := fromRows(name, rows)
+			if p.width < width {
+				p = p.padded(width)
+			}
+			return p, nil
+		case c == '$':
+			if len(rows)+n > maxPatternSize {
+				return nil, tooBig
+			}
+			rows = append(rows, row)
+			for i := 1; i < run; i++ {
+				rows = append(rows, nil)
+			}
+			row = []uint32{}
+		case c == 'b' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			if len(row)+n > maxPatternSize || cells+n > maxPatternCells {
+				return nil, tooBig
+			}
+			cells, widest = cells+n, max(widest, len(row)+n)
+			if c == 'b' || c == '.' {
+				row = append(row, make([]uint32, n)...)
+				break
+			}
+			for i := 0; i < n; i++ {
+				row = append(row, 1)
+			}
+		default:
+			return nil, fmt.Errorf("%s: unexpected %q in RLE", name, c)
+		}
+		run = 0
+	}
+	return nil, fmt.Errorf("%s: RLE has no ending !", name)
+}
+
+// fromRows makes a pattern of rows from the top down, padding them out to
+// the longest.
+func fromRows(name string, rows [][]uint32) *pattern {
+	p := &pattern{name: name, height: len(rows)}
+	for _, r := range rows {
+		p.width = max(p.width, len(r))
+	}
+	p.cells = make([]uint32, p.width*p.height)
+	for i, r := range rows {
+		copy(p.cells[(p.height-1-i)*p.width:], r)
+	}
+	return p
+}
+
+// padded is the pattern made width wide with dead cells on the right.
+func (p *pattern) padded(width int) *pattern {
+	w := &pattern{name: p.name, width: width, height: p.height, cells: make([]uint32, width*p.height)}
+	for y := 0; y < p.height; y++ {
+		copy(w.cells[y*width:], p.cells[y*p.width:(y+1)*p.width])
+	}
+	return w
+}
+
+// rle is the pattern in run length encoded form, as parseRLE reads it, with
+// a header giving its size and lines of at most 70 characters.
+func (p *pattern) rle() string {
+	var runs []string
+	run := func(n int, tag byte) {
+		if n > 1 {
+			runs = append(runs, fmt.Sprintf("%d%c", n, tag))
+		} else {
+			runs = append(runs, string(tag))
+		}
+	}
+	// Row ends are put off until the next row with any live cells, so that
+	// dead rows go in one run and those at the bottom not at all.
+	ends := 0
+	for y := p.height - 1; y >= 0; y-- {
+		for x := 0; x < p.width; {
+			alive := p.at(x, y) != 0
+			n := 1
+			for x+n < p.width && (p.at(x+n, y) != 0) == alive {
+				n++
+			}
+			// Dead cells at the end of a row are left out.
+			if alive {
+				if ends > 0 {
+					run(ends, '$')
+					ends = 0
+				}
+				run(n, 'o')
+			} else if x+n < p.width {
+				if ends > 0 {
+					run(ends, '$')
+					ends = 0
+				}
+				run(n, 'b')
+			}
+			x += n
+		}
+		ends++
+	}
+	runs = append(runs, "!")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "x = %d, y = %d, rule = B3/S23\n", p.width, p.height)
+	line := 0
+	for _, r := range runs {
+		if line+len(r) > 70 {
+			b.WriteByte('\n')
+			line = 0
+		}
+		b.WriteString(r)
+		line += len(r)
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// loadPatterns is the built-in patterns, then those in the RLE files in
+// dir, if there is one, by name.
+func loadPatterns(dir string) ([]*pattern, error) {
+	var patterns []*pattern
+	for _, b := range builtinPatterns {
+		p, err := parseRLE(b.name, b.rle)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.rle"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		p, err := parseRLE(strings.TrimSuffix(filepath.Base(f), ".rle"), string(b))
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// size is how big the pattern is, for people.
+func (p *pattern) size() string {
+	return fmt.Sprintf("%dx%d", p.width, p.height)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRLE(t *testing.T) {
+	for _, tt := range []struct {
+		name, rle     string
+		width, height int
+		cells         string
+	}{
+		{"glider", "bob$2bo$3o!", 3, 3, "111001010"},
+		{"header pads", "x = 4, y = 2\nbo!", 4, 2, "00000100"},
+		{"blank rows", "o2$o!", 1, 3, "101"},
+		{"named", "#N blinker\n3o!", 3, 1, "111"},
+	} {
+		p, err := parseRLE(tt.name, tt.rle)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var cells strings.Builder
+		for _, c := range p.cells {
+			cells.WriteByte('0' + byte(c))
+		}
+		if p.width != tt.width || p.height != tt.height || cells.String() != tt.cells {
+			t.Errorf("%s: got %dx%d %s, want %dx%d %s", tt.name, p.width, p.height, cells.String(), tt.width, tt.height, tt.cells)
+		}
+	}
+}
+
+func TestParseRLERefusesHugePatterns(t *testing.T) {
+	for _, rle := range []string{
+		"99999999999999999b!",
+		"x = 3000000000, y = 3000000000\no!",
+		"x = 60000, y = 60000\no!",
+		"x = -1, y = 3\no!",
+		"65537o!",
+		"o65537$o!",
+		strings.Repeat("60000b$", 300) + "!",
+	} {
+		if _, err := parseRLE("huge", rle); err == nil {
+			t.Errorf("parseRLE(%.40q) succeeded, want an error", rle)
+		}
+	}
+}
+
+func TestParseRLEErrors(t *testing.T) {
+	for _, rle := range []string{"bob$2bo", "b?o!"} {
+		if _, err := parseRLE("bad", rle); err == nil {
+			t.Errorf("parseRLE(%q) succeeded, want an error", rle)
+		}
+	}
+}
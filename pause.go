@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// handlePauseKey pauses and resumes the simulation with Space. While it is
+// paused the loop still draws and takes input, so the camera, palettes and
+// the rest work as usual, but no generations go by.
+func (s *State) handlePauseKey(key glfw.Key, action glfw.Action) {
+	if key != glfw.KeySpace || action != glfw.Press {
+		return
+	}
+	s.paused = !s.paused
+	s.updateTitle()
+}
+
+// step records the simulation's next generation.
+func (s *State) step(encoder *commandEncoder) {
+	s.sim.Step(encoder)
+	s.steps += 1
+}
+
+// stepNow steps the simulation n generations straight away rather than in
+// the next frame. Each goes in a submission of its own, since some
+// simulations upload or read back between generations.
+func (s *State) stepNow(n int) error {
+	for i := 0; i < n; i++ {
+		encoder, err := s.newEncoder()
+		if err != nil {
+			return err
+		}
+		s.step(encoder)
+		cmdBuffer, err := encoder.Finish(nil)
+		encoder.Release()
+		if err != nil {
+			return err
+		}
+		s.queue.Submit(cmdBuffer)
+		cmdBuffer.Release()
+		s.highlights.observe(s)
+		s.timeline.observe(s)
+	}
+	return nil
+}
+
+// handleStepKey steps a paused simulation a generation at a time with the
+// full stop, 10 with Shift and 100 with Ctrl, pausing it first if it is
+// running. Holding the key keeps stepping.
+func (s *State) handleStepKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if key != glfw.KeyPeriod || action == glfw.Release {
+		return
+	}
+	if _, browsing := s.mode.(sceneMode); browsing {
+		return
+	}
+	if !s.paused {
+		s.paused = true
+		s.updateTitle()
+	}
+	n := 1
+	switch {
+	case mods&glfw.ModControl != 0:
+		n = 100
+	case mods&glfw.ModShift != 0:
+		n = 10
+	}
+	if err := s.stepNow(n); err != nil {
+		fmt.Println("stepping:", err)
+	}
+}
@@ -0,0 +1,542 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed plugin_compute.wgsl
+var pluginCompute string
+
+//go:embed plugin_draw.wgsl
+var pluginDraw string
+
+// pluginABI is the version of the plugin format this engine reads, which
+// every plugin.json names. It goes up whenever a change would break rule
+// packs written for the last one.
+const pluginABI = 1
+
+// pluginName is what the directory of a plugin can be called: lower-case
+// letters, digits, hyphens and underscores, not starting with either of
+// the last two.
+var pluginName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// A plugin is a rule pack in a directory of its own under the plugins
+// directory, run like any built-in simulation by the directory's name:
+//
+//   - plugin.json describes it, see pluginManifest.
+//   - rule.wgsl is the rule, a text/template of WGSL defining
+//     fn rule(cell: vec2<i32>, state: u32) -> u32; see plugin_compute.wgsl
+//     for what it can call. Each parameter is filled in as {{.name}}.
+//   - tests.json, if there is one, lists test vectors that the rule has to
+//     pass before it runs, see pluginTest.
+type plugin struct {
+	dir      string
+	manifest pluginManifest
+	rule     *template.Template
+	tests    []pluginTest
+}
+
+type pluginManifest struct {
+	ABI         int    `json:"abi"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Author      string `json:"author"`
+	// States is how many states cells can be in, from 0, the background.
+	States int `json:"states"`
+	// Parameters are the defaults of what the rule is filled in with,
+	// which -plugin-param overrides.
+	Parameters map[string]float64 `json:"parameters"`
+	// Colours are what each state is drawn in as #rrggbb, the first being
+	// unused as state 0 is the background. Without them states are coloured
+	// by the palette.
+	Colours     []string `json:"colours"`
+	Behaviours  []string `json:"behaviours"`
+	Recommended []string `json:"recommended"`
+}
+
+// pluginTest is a test vector: a grid of cells, one string for each row
+// from the top with . for state 0 and a digit or letter for the others,
+// and the grid it has to be after steps generations. Test grids wrap
+// around like the simulation's. Tests run with the plugin's default
+// parameters, whatever -plugin-param says, apart from any of their own.
+type pluginTest struct {
+	Name       string             `json:"name"`
+	Steps      int                `json:"steps"`
+	Parameters map[string]float64 `json:"parameters"`
+	Before     []string           `json:"before"`
+	After      []string           `json:"after"`
+}
+
+// loadPlugins registers every plugin in dir as a simulation. There being
+// no dir at all is fine.
+func loadPlugins(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		// Hidden directories, such as those get downloads into, aren't
+		// plugins.
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if !pluginName.MatchString(name) {
+			return fmt.Errorf("plugin %s: plugin names are lower-case letters, digits, - and _", name)
+		}
+		if _, ok := simulations[name]; ok {
+			return fmt.Errorf("plugin %s: there is already a simulation called that", name)
+		}
+		p, err := loadPlugin(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("plugin %s: %w", name, err)
+		}
+		simulations[name] = simulationInfo{
+			create: func(s *State, cfg *Config) (Simulation, error) {
+				return newPluginRule(s, cfg, p)
+			},
+			Name:        p.manifest.Name,
+			Description: p.manifest.Description,
+			Discoverer:  p.manifest.Author,
+			Behaviours:  p.manifest.Behaviours,
+			Recommended: p.manifest.Recommended,
+		}
+	}
+	return nil
+}
+
+func loadPlugin(dir string) (*plugin, error) {
+	p := &plugin{dir: dir}
+	b, err := os.ReadFile(filepath.Join(dir, "plugin.json"))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &p.manifest); err != nil {
+		return nil, fmt.Errorf("plugin.json: %w", err)
+	}
+	m := &p.manifest
+	if m.ABI != pluginABI {
+		return nil, fmt.Errorf("written for plugin ABI %d, this engine reads %d", m.ABI, pluginABI)
+	}
+	if m.Name == "" {
+		m.Name = filepath.Base(dir)
+	}
+	if m.States < 2 || m.States > maxTableStates {
+		return nil, fmt.Errorf("state count %d out of range [2, %d]", m.States, maxTableStates)
+	}
+	if len(m.Colours) != 0 && len(m.Colours) != m.States {
+		return nil, fmt.Errorf("%d colours for %d states", len(m.Colours), m.States)
+	}
+	if _, err := p.colours(); err != nil {
+		return nil, err
+	}
+
+	b, err = os.ReadFile(filepath.Join(dir, "rule.wgsl"))
+	if err != nil {
+		return nil, err
+	}
+	p.rule, err = template.New("rule.wgsl").Option("missingkey=error").Parse(string(b))
+	if err != nil {
+		return nil, err
+	}
+	// Filling in the defaults catches parameters the rule uses but doesn't
+	// have now, rather than when it is run.
+	if _, err := p.source(nil); err != nil {
+		return nil, err
+	}
+
+	b, err = os.ReadFile(filepath.Join(dir, "tests.json"))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(b, &p.tests); err != nil {
+			return nil, fmt.Errorf("tests.json: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// source is the plugin's compute shader, with its parameters at their
+// defaults apart from those in params.
+func (p *plugin) source(params map[string]float64) (string, error) {
+	values := map[string]string{}
+	for name, v := range p.manifest.Parameters {
+		values[name] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	for name, v := range params {
+		if _, ok := values[name]; !ok {
+			return "", fmt.Errorf("%s has no parameter %q (have %v)", p.manifest.Name, name, p.parameterNames())
+		}
+		values[name] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	var rule strings.Builder
+	if err := p.rule.Execute(&rule, values); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("const states = %du;\n", p.manifest.States) + pluginCompute + rule.String(), nil
+}
+
+func (p *plugin) parameterNames() []string {
+	names := make([]string, 0, len(p.manifest.Parameters))
+	for name := range p.manifest.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// colours is a colour for each state for the renderer, with 0 alpha for
+// those left to the palette.
+func (p *plugin) colours() ([]float32, error) {
+	colours := make([]float32, 4*p.manifest.States)
+	for i, c := range p.manifest.Colours {
+		v, err := strconv.ParseUint(strings.TrimPrefix(c, "#"), 16, 32)
+		if err != nil || len(c) != 7 || c[0] != '#' {
+			return nil, fmt.Errorf("colour %q: want #rrggbb", c)
+		}
+		colours[4*i] = float32(v>>16&0xff) / 255
+		colours[4*i+1] = float32(v>>8&0xff) / 255
+		colours[4*i+2] = float32(v&0xff) / 255
+		colours[4*i+3] = 1
+	}
+	return colours, nil
+}
+
+// parsePluginGrid reads the cells of a test vector, bottom row first like
+// the cell buffers.
+func parsePluginGrid(rows []string, states int) (cells []uint32, width, height int, err error) {
+	height = len(rows)
+	if height == 0 {
+		return nil, 0, 0, fmt.Errorf("no rows")
+	}
+	width = len(rows[0])
+	cells = make([]uint32, width*height)
+	for i, row := range rows {
+		if len(row) != width {
+			return nil, 0, 0, fmt.Errorf("row %d is %d cells, want %d like the first", i+1, len(row), width)
+		}
+		y := height - 1 - i
+		for x, c := range row {
+			if c == '.' {
+				continue
+			}
+			n, err := strconv.ParseUint(string(c), 36, 32)
+			if err != nil || int(n) >= states {
+				return nil, 0, 0, fmt.Errorf("row %d has %q, want . or a state up to %d", i+1, c, states-1)
+			}
+			cells[y*width+x] = uint32(n)
+		}
+	}
+	return cells, width, height, nil
+}
+
+// formatPluginGrid writes cells the way test vectors are.
+func formatPluginGrid(cells []uint32, width, height int) string {
+	var b strings.Builder
+	for y := height - 1; y >= 0; y-- {
+		for _, c := range cells[y*width : (y+1)*width] {
+			if c == 0 {
+				b.WriteByte('.')
+			} else {
+				b.WriteString(strconv.FormatUint(uint64(c), 36))
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// PluginRule runs a plugin's rule on a torus.
+type PluginRule struct {
+	bindGroupLayout    *wgpu.BindGroupLayout
+	pipelineLayout     *wgpu.PipelineLayout
+	drawPipelineLayout *wgpu.PipelineLayout
+	pipeline           *wgpu.RenderPipeline
+	simulationPipeline *wgpu.ComputePipeline
+
+	colours          *wgpu.Buffer
+	cellStateStorage []*wgpu.Buffer
+	gridBindGroups   []*wgpu.BindGroup
+	vertices         *wgpu.Buffer
+	camera           *viewCamera
+	width, height    int
+	steps            int
+}
+
+func newPluginRule(s *State, cfg *Config, p *plugin) (sim Simulation, err error) {
+	colours, err := p.colours()
+	if err != nil {
+		return nil, err
+	}
+	gen, err := newGenerator(cfg.Init)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &PluginRule{
+		vertices: s.vertexBuffer,
+		camera:   s.camera,
+		width:    s.gridWidth,
+		height:   s.gridHeight,
+	}
+	defer func() {
+		if err != nil {
+			r.Release()
+		}
+	}()
+
+	drawShader := s.createShader(p.manifest.Name+" render shader", pluginDraw+paletteShader+cameraShader)
+	defer drawShader.Release()
+
+	r.bindGroupLayout, err = s.bindGroupLayout(p.manifest.Name,
+		bufferEntry(0, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Fragment|wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(2, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(3, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(4, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	r.pipelineLayout, err = s.pipelineLayout(p.manifest.Name, r.bindGroupLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	r.drawPipelineLayout, err = s.pipelineLayout(p.manifest.Name+" render", r.bindGroupLayout, s.cameraLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	r.pipeline, err = s.renderPipeline(p.manifest.Name+" render", r.drawPipelineLayout, drawShader, "main_vs", "main_fs")
+	if err != nil {
+		return nil, err
+	}
+	r.simulationPipeline, err = r.compile(s, p, cfg.Plugins.Params)
+	if err != nil {
+		return nil, err
+	}
+	r.colours = s.storageBuffer(wgpu.ToBytes(colours))
+
+	for _, t := range p.tests {
+		if err := r.runTest(s, p, t); err != nil {
+			return nil, fmt.Errorf("%s: test %q: %w", p.manifest.Name, t.Name, err)
+		}
+	}
+
+	r.cellStateStorage, r.gridBindGroups = r.bindCells(s, s.gridBuffer, wgpu.ToBytes(generate(gen, s.rand, r.width, r.height)))
+	return r, nil
+}
+
+// compile makes the compute pipeline of p's rule with params. A rule that
+// doesn't compile is the plugin's fault rather than the engine's, so it is
+// an error instead of the end of the program.
+func (r *PluginRule) compile(s *State, p *plugin, params map[string]float64) (*wgpu.ComputePipeline, error) {
+	source, err := p.source(params)
+	if err != nil {
+		return nil, err
+	}
+	shader, err := s.device.CreateShaderModule(&wgpu.ShaderModuleDescriptor{
+		Label:          p.manifest.Name + " compute shader",
+		WGSLDescriptor: &wgpu.ShaderModuleWGSLDescriptor{Code: source},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: rule.wgsl: %w", p.manifest.Name, err)
+	}
+	defer shader.Release()
+	return s.computePipeline(p.manifest.Name+" compute", r.pipelineLayout, shader, "main")
+}
+
+// bindCells makes both cell state buffers, filled with cells, and the bind
+// groups that step from each to the other on the grid in grid.
+func (r *PluginRule) bindCells(s *State, grid *wgpu.Buffer, cells []byte) ([]*wgpu.Buffer, []*wgpu.BindGroup) {
+	storage := []*wgpu.Buffer{
+		s.storageBuffer(cells),
+		s.storageBuffer(cells),
+	}
+	return storage, []*wgpu.BindGroup{
+		s.bindGroup("plugin A", r.bindGroupLayout, grid, storage[0], storage[1], r.colours, s.paletteBuffer),
+		s.bindGroup("plugin B", r.bindGroupLayout, grid, storage[1], storage[0], r.colours, s.paletteBuffer),
+	}
+}
+
+// runTest steps the cells of a test vector on a grid of their own and
+// checks they end up as it says.
+func (r *PluginRule) runTest(s *State, p *plugin, t pluginTest) error {
+	states := p.manifest.States
+	before, width, height, err := parsePluginGrid(t.Before, states)
+	if err != nil {
+		return fmt.Errorf("before: %w", err)
+	}
+	after, afterWidth, afterHeight, err := parsePluginGrid(t.After, states)
+	if err != nil {
+		return fmt.Errorf("after: %w", err)
+	}
+	if afterWidth != width || afterHeight != height {
+		return fmt.Errorf("before is %dx%d but after is %dx%d", width, height, afterWidth, afterHeight)
+	}
+	if t.Steps < 1 {
+		return fmt.Errorf("needs at least one step, got %d", t.Steps)
+	}
+	pipeline, err := r.compile(s, p, t.Parameters)
+	if err != nil {
+		return err
+	}
+	defer pipeline.Release()
+
+	grid := s.uniformBuffer("plugin test grid", wgpu.ToBytes([]float32{float32(width), float32(height)}))
+	defer grid.Release()
+	storage, bindGroups := r.bindCells(s, grid, wgpu.ToBytes(before))
+	defer func() {
+		for _, bg := range bindGroups {
+			bg.Release()
+		}
+		for _, b := range storage {
+			b.Release()
+		}
+	}()
+
+	encoder, err := s.newEncoder()
+	if err != nil {
+		return err
+	}
+	defer encoder.Release()
+	for i := 0; i < t.Steps; i++ {
+		pass := encoder.BeginComputePass(nil)
+		pass.SetPipeline(pipeline)
+		pass.SetBindGroup(0, bindGroups[i%2], nil)
+		pass.DispatchWorkgroups(uint32(width+7)/8, uint32(height+7)/8, 1)
+		pass.End()
+		pass.Release()
+	}
+	cmdBuffer, err := encoder.Finish(nil)
+	if err != nil {
+		return err
+	}
+	defer cmdBuffer.Release()
+	s.queue.Submit(cmdBuffer)
+
+	data, err := s.readBuffer(storage[t.Steps%2])
+	if err != nil {
+		return err
+	}
+	got := wgpu.FromBytes[uint32](data)
+	for i := range after {
+		if got[i] != after[i] {
+			return fmt.Errorf("after %d steps got\n%swant\n%s", t.Steps, formatPluginGrid(got, width, height), formatPluginGrid(after, width, height))
+		}
+	}
+	return nil
+}
+
+func (r *PluginRule) ResizeGrid(s *State, remap gridRemap) error {
+	cells, err := s.readBuffer(r.cellStateStorage[r.steps%2])
+	if err != nil {
+		return err
+	}
+	r.width, r.height = remap.width, remap.height
+	r.releaseCells()
+	r.cellStateStorage, r.gridBindGroups = r.bindCells(s, s.gridBuffer, remap.cells(cells, 4, nil))
+	return nil
+}
+
+// Fields reads the state of every cell.
+func (r *PluginRule) Fields(s *State) (*fieldSet, error) {
+	data, err := s.readBuffer(r.cellStateStorage[r.steps%2])
+	if err != nil {
+		return nil, err
+	}
+	return cellFields(wgpu.FromBytes[uint32](data), r.width, r.height), nil
+}
+
+// Population counts the cells in any state but 0.
+func (r *PluginRule) Population(s *State) (float64, error) {
+	return liveCells(s, r.cellStateStorage[r.steps%2])
+}
+
+func (r *PluginRule) Step(encoder *commandEncoder) {
+	computePass := encoder.BeginComputePass(nil)
+	defer computePass.Release()
+
+	computePass.SetPipeline(r.simulationPipeline)
+	computePass.SetBindGroup(0, r.gridBindGroups[r.steps%2], nil)
+	computePass.DispatchWorkgroups(uint32(r.width+7)/8, uint32(r.height+7)/8, 1)
+	computePass.End()
+
+	r.steps += 1
+}
+
+func (r *PluginRule) batchesSteps() bool { return true }
+
+func (r *PluginRule) squareCells() bool { return true }
+
+func (r *PluginRule) usesCamera() {}
+
+func (r *PluginRule) Draw(pass *renderPass) {
+	pass.SetPipeline(r.pipeline)
+	pass.SetBindGroup(0, r.gridBindGroups[r.steps%2], nil)
+	pass.SetBindGroup(1, r.camera.bindGroup, nil)
+	pass.SetVertexBuffer(0, r.vertices, 0, wgpu.WholeSize)
+	pass.Draw(6, uint32(r.width*r.height), 0, 0)
+}
+
+func (r *PluginRule) releaseCells() {
+	for _, bg := range r.gridBindGroups {
+		bg.Release()
+	}
+	r.gridBindGroups = nil
+	for _, b := range r.cellStateStorage {
+		b.Release()
+	}
+	r.cellStateStorage = nil
+}
+
+func (r *PluginRule) Release() {
+	r.releaseCells()
+	if r.colours != nil {
+		r.colours.Release()
+		r.colours = nil
+	}
+	if r.simulationPipeline != nil {
+		r.simulationPipeline.Release()
+		r.simulationPipeline = nil
+	}
+	if r.pipeline != nil {
+		r.pipeline.Release()
+		r.pipeline = nil
+	}
+	if r.pipelineLayout != nil {
+		r.pipelineLayout.Release()
+		r.pipelineLayout = nil
+	}
+	if r.drawPipelineLayout != nil {
+		r.drawPipelineLayout.Release()
+		r.drawPipelineLayout = nil
+	}
+	if r.bindGroupLayout != nil {
+		r.bindGroupLayout.Release()
+		r.bindGroupLayout = nil
+	}
+}
@@ -0,0 +1,38 @@
+// The plugin's rule goes after this: a function
+//
+//   fn rule(cell: vec2<i32>, state: u32) -> u32
+//
+// giving the next state of the cell at cell, which is in state now. It can
+// read any cell with cellState, which wraps around the edges of the grid.
+// states, how many states the plugin has, goes before.
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<storage> cellStateIn: array<u32>;
+@group(0) @binding(2) var<storage, read_write> cellStateOut: array<u32>;
+
+@compute
+@workgroup_size(8, 8)
+fn main(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x >= u32(grid.x) || id.y >= u32(grid.y) {
+    return;
+  }
+  let cell = vec2<i32>(id.xy);
+  let i = cellIndex(cell);
+  cellStateOut[i] = min(rule(cell, cellStateIn[i]), states - 1u);
+}
+
+fn cellState(x: i32, y: i32) -> u32 {
+  let size = vec2<i32>(grid);
+  return cellStateIn[cellIndex(vec2(wrap(x, size.x), wrap(y, size.y)))];
+}
+
+// wrap takes n back onto [0, size). Only ever taking % of positive numbers
+// keeps it the same on every backend, as GLSL leaves negative ones
+// undefined.
+fn wrap(n: i32, size: i32) -> i32 {
+  let m = abs(n) % size;
+  return select(m, (size - m) % size, n < 0);
+}
+
+fn cellIndex(cell: vec2<i32>) -> u32 {
+  return u32(cell.y * i32(grid.x) + cell.x);
+}
@@ -0,0 +1,19 @@
+// Off cells fire with {{.birth}} firing neighbours, firing cells rest and
+// resting cells turn off.
+fn rule(cell: vec2<i32>, state: u32) -> u32 {
+  if state == 1u {
+    return 2u;
+  }
+  if state == 2u {
+    return 0u;
+  }
+  var firing = 0u;
+  for (var dy = -1; dy <= 1; dy += 1) {
+    for (var dx = -1; dx <= 1; dx += 1) {
+      if (dx != 0 || dy != 0) && cellState(cell.x + dx, cell.y + dy) == 1u {
+        firing += 1u;
+      }
+    }
+  }
+  return select(0u, 1u, firing == {{.birth}}u);
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed post.wgsl
+var postShader string
+
+// postEffects are the full screen effects -post can name, in the order they
+// are applied, and how strong they start when it names them.
+var postEffects = []struct {
+	name     string
+	strength float64
+}{
+	{"bloom", 0.5},
+	{"scanlines", 0.5},
+	{"crt", 0.5},
+	{"vignette", 0.6},
+}
+
+func postEffectNames() []string {
+	names := make([]string, len(postEffects))
+	for i, e := range postEffects {
+		names[i] = e.name
+	}
+	return names
+}
+
+// postChain runs full screen effects over the frame on its way to the
+// screen, each reading the last one's output. The effects are parameters
+// like the simulation's, from 0 for off to 1, so the dashboard, the command
+// palette and macros can all change them; V skips them all and brings them
+// back.
+type postChain struct {
+	enabled bool
+	scale   float32
+	queue   *uploadQueue
+
+	layout         *wgpu.BindGroupLayout
+	pipelineLayout *wgpu.PipelineLayout
+	effects        []*postEffect
+
+	textures [2]*wgpu.Texture
+	views    [2]*wgpu.TextureView
+}
+
+type postEffect struct {
+	name     string
+	strength float64
+	pipeline *wgpu.RenderPipeline
+	params   *wgpu.Buffer
+	// sets[i] reads views[i].
+	sets [2]*wgpu.BindGroup
+}
+
+func newPostChain(s *State, names []string) (p *postChain, err error) {
+	for _, name := range names {
+		if !slices.Contains(postEffectNames(), name) {
+			return nil, fmt.Errorf("unknown effect %q (have %v)", name, postEffectNames())
+		}
+	}
+
+	p = &postChain{enabled: true, scale: s.scaled(1), queue: s.queue}
+	defer func() {
+		if err != nil {
+			p.Release()
+		}
+	}()
+
+	shader := s.createShader("post shader", postShader)
+	defer shader.Release()
+
+	p.layout, err = s.bindGroupLayout("post",
+		bufferEntry(0, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		wgpu.BindGroupLayoutEntry{
+			Binding:    1,
+			Visibility: wgpu.ShaderStage_Fragment,
+			Texture: wgpu.TextureBindingLayout{
+				SampleType:    wgpu.TextureSampleType_UnfilterableFloat,
+				ViewDimension: wgpu.TextureViewDimension_2D,
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	p.pipelineLayout, err = s.pipelineLayout("post", p.layout)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range postEffects {
+		effect := &postEffect{name: e.name}
+		p.effects = append(p.effects, effect)
+		if slices.Contains(names, e.name) {
+			effect.strength = e.strength
+		}
+		effect.pipeline, err = s.device.CreateRenderPipeline(&wgpu.RenderPipelineDescriptor{
+			Label:  "post " + e.name,
+			Layout: p.pipelineLayout,
+			Vertex: wgpu.VertexState{
+				Module:     shader,
+				EntryPoint: "main_vs",
+			},
+			Fragment: &wgpu.FragmentState{
+				Module:     shader,
+				EntryPoint: e.name + "_fs",
+				Targets:    []wgpu.ColorTargetState{{Format: s.config.Format, WriteMask: wgpu.ColorWriteMask_All}},
+			},
+			Primitive: wgpu.PrimitiveState{
+				Topology: wgpu.PrimitiveTopology_TriangleList,
+			},
+			Multisample: wgpu.MultisampleState{
+				Count: 1,
+				Mask:  0xFFFFFFFF,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		effect.params = s.uniformBuffer("post "+e.name, p.paramBytes(effect))
+	}
+	return p, p.resize(s)
+}
+
+func (p *postChain) paramBytes(e *postEffect) []byte {
+	return wgpu.ToBytes([]float32{float32(e.strength), p.scale, 0, 0})
+}
+
+// active is whether any effect is to be drawn.
+func (p *postChain) active() bool {
+	if p == nil || !p.enabled {
+		return false
+	}
+	for _, e := range p.effects {
+		if e.strength > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *postChain) input() *wgpu.TextureView {
+	return p.views[0]
+}
+
+// resize recreates the textures to match the swapchain.
+func (p *postChain) resize(s *State) (err error) {
+	p.releaseTextures()
+	for i := range p.textures {
+		p.textures[i], p.views[i], err = s.renderTexture("post", s.config.Format)
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range p.effects {
+		for i := range e.sets {
+			e.sets[i], err = s.device.CreateBindGroup(&wgpu.BindGroupDescriptor{
+				Label:  "post " + e.name,
+				Layout: p.layout,
+				Entries: []wgpu.BindGroupEntry{
+					{Binding: 0, Buffer: e.params, Size: wgpu.WholeSize},
+					{Binding: 1, TextureView: p.views[i]},
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// apply runs the effects that are on over the input, the last of them into
+// view.
+func (p *postChain) apply(s *State, encoder *commandEncoder, view *wgpu.TextureView) {
+	var on []*postEffect
+	for _, e := range p.effects {
+		if e.strength > 0 {
+			on = append(on, e)
+		}
+	}
+	for i, e := range on {
+		target := view
+		if i < len(on)-1 {
+			target = p.views[(i+1)%2]
+		}
+		pass := encoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
+			ColorAttachments: []wgpu.RenderPassColorAttachment{attachColourToView(target, s.palette.clear())},
+		})
+		pass.SetPipeline(e.pipeline)
+		pass.SetBindGroup(0, e.sets[i%2], nil)
+		pass.Draw(3, 1, 0, 0)
+		pass.End()
+		pass.Release()
+	}
+}
+
+// setScale follows the content scale, which scanlines and bloom are
+// measured in.
+func (p *postChain) setScale(s *State) {
+	if p == nil {
+		return
+	}
+	p.scale = s.scaled(1)
+	for _, e := range p.effects {
+		p.queue.WriteBuffer(e.params, 0, p.paramBytes(e))
+	}
+}
+
+func (p *postChain) Parameters() []parameter {
+	params := make([]parameter, len(p.effects))
+	for i, e := range p.effects {
+		params[i] = parameter{e.name, e.strength, 0, 1}
+	}
+	return params
+}
+
+func (p *postChain) SetParameter(name string, value float64) error {
+	for _, e := range p.effects {
+		if e.name == name {
+			e.strength = max(0, min(value, 1))
+			return p.queue.WriteBuffer(e.params, 0, p.paramBytes(e))
+		}
+	}
+	return fmt.Errorf("no effect %q", name)
+}
+
+// handlePostKey turns the effects off with V, and back on as they were.
+func (s *State) handlePostKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	// Ctrl+V stamps the clipboard.
+	if key != glfw.KeyV || action != glfw.Press || s.post == nil || mods&(glfw.ModControl|glfw.ModSuper) != 0 {
+		return
+	}
+	s.post.enabled = !s.post.enabled
+	var on []string
+	for _, e := range s.post.effects {
+		if e.strength > 0 {
+			on = append(on, e.name)
+		}
+	}
+	switch {
+	case !s.post.enabled:
+		fmt.Println("effects off")
+	case len(on) == 0:
+		fmt.Println("effects on, but all at 0: set them from the dashboard or the command palette")
+	default:
+		fmt.Println("effects on:", strings.Join(on, ", "))
+	}
+}
+
+func (p *postChain) releaseTextures() {
+	for _, e := range p.effects {
+		for i := range e.sets {
+			if e.sets[i] != nil {
+				e.sets[i].Release()
+				e.sets[i] = nil
+			}
+		}
+	}
+	for i := range p.textures {
+		if p.views[i] != nil {
+			p.views[i].Release()
+			p.views[i] = nil
+		}
+		if p.textures[i] != nil {
+			p.textures[i].Release()
+			p.textures[i] = nil
+		}
+	}
+}
+
+func (p *postChain) Release() {
+	p.releaseTextures()
+	for _, e := range p.effects {
+		if e.params != nil {
+			e.params.Release()
+			e.params = nil
+		}
+		if e.pipeline != nil {
+			e.pipeline.Release()
+			e.pipeline = nil
+		}
+	}
+	if p.pipelineLayout != nil {
+		p.pipelineLayout.Release()
+		p.pipelineLayout = nil
+	}
+	if p.layout != nil {
+		p.layout.Release()
+		p.layout = nil
+	}
+}
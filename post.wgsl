@@ -0,0 +1,74 @@
+struct Effect {
+  // strength runs from 0, no effect, to 1.
+  strength: f32,
+  // scale is framebuffer pixels to a screen coordinate, so that scanlines
+  // are as far apart on high-DPI displays.
+  scale: f32,
+};
+
+@group(0) @binding(0) var<uniform> effect: Effect;
+@group(0) @binding(1) var source: texture_2d<f32>;
+
+// One triangle covering the whole screen.
+@vertex
+fn main_vs(@builtin(vertex_index) i: u32) -> @builtin(position) vec4<f32> {
+  let uv = vec2<f32>(f32((i << 1u) & 2u), f32(i & 2u));
+  return vec4<f32>(uv * 2.0 - 1.0, 0.0, 1.0);
+}
+
+fn load(p: vec2<i32>) -> vec3<f32> {
+  let size = vec2<i32>(textureDimensions(source));
+  return textureLoad(source, clamp(p, vec2(0), size - 1), 0).rgb;
+}
+
+fn luminance(c: vec3<f32>) -> f32 {
+  return dot(c, vec3(0.2126, 0.7152, 0.0722));
+}
+
+// bloom_fs spreads the brightest colours into a soft glow around them, from
+// a sparse 7 by 7 sample of the neighbourhood.
+@fragment
+fn bloom_fs(@builtin(position) pos: vec4<f32>) -> @location(0) vec4<f32> {
+  let p = vec2<i32>(pos.xy);
+  let spacing = max(i32(3.0 * effect.scale), 1);
+  var glow = vec3(0.0);
+  var total = 0.0;
+  for (var y = -3; y <= 3; y++) {
+    for (var x = -3; x <= 3; x++) {
+      let c = load(p + vec2(x, y) * spacing);
+      let w = exp(-f32(x * x + y * y) / 4.5);
+      glow += c * smoothstep(0.5, 1.0, luminance(c)) * w;
+      total += w;
+    }
+  }
+  return vec4<f32>(load(p) + glow / total * 2.0 * effect.strength, 1.0);
+}
+
+// scanlines_fs darkens every other line of a CRT.
+@fragment
+fn scanlines_fs(@builtin(position) pos: vec4<f32>) -> @location(0) vec4<f32> {
+  let line = 0.5 + 0.5 * cos(pos.y / effect.scale * 3.14159265 / 1.5);
+  return vec4<f32>(load(vec2<i32>(pos.xy)) * (1.0 - 0.6 * effect.strength * line), 1.0);
+}
+
+// crt_fs bulges the picture out like the glass of a CRT, black past its
+// edges.
+@fragment
+fn crt_fs(@builtin(position) pos: vec4<f32>) -> @location(0) vec4<f32> {
+  let size = vec2<f32>(textureDimensions(source));
+  let centred = pos.xy / size * 2.0 - 1.0;
+  let bent = centred * (1.0 + 0.15 * effect.strength * dot(centred, centred)) / (1.0 + 0.15 * effect.strength);
+  if any(abs(bent) > vec2(1.0)) {
+    return vec4<f32>(0.0, 0.0, 0.0, 1.0);
+  }
+  return vec4<f32>(load(vec2<i32>((bent + 1.0) * 0.5 * size)), 1.0);
+}
+
+// vignette_fs darkens towards the corners.
+@fragment
+fn vignette_fs(@builtin(position) pos: vec4<f32>) -> @location(0) vec4<f32> {
+  let size = vec2<f32>(textureDimensions(source));
+  let d = length(pos.xy / size - 0.5) * 1.4142136;
+  let shade = 1.0 - effect.strength * smoothstep(0.4, 1.0, d);
+  return vec4<f32>(load(vec2<i32>(pos.xy)) * shade, 1.0);
+}
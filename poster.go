@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+
+	"webgpu-go/viewmath"
+)
+
+// maxPosterSize keeps posters inside the viewport sizes GPUs allow, which
+// is what each tile is drawn through.
+const maxPosterSize = 16384
+
+// posterSize is the size of the poster cfg asks for, keeping the window's
+// shape.
+func (s *State) posterSize(cfg PosterConfig) (width, height int) {
+	width = cfg.Width
+	height = int(float64(width)*float64(s.config.Height)/float64(s.config.Width) + 0.5)
+	return width, max(height, 1)
+}
+
+// renderPoster draws the simulation far larger than a texture can be. The
+// poster is cut into tiles, and each is drawn into a texture of its own
+// with the viewport set to where the whole poster would be, shifted so that
+// only the tile's part of it lands on the texture. The tiles are read back
+// and put together on the CPU.
+func (s *State) renderPoster(cfg PosterConfig) (*image.RGBA, error) {
+	width, height := s.posterSize(cfg)
+	if width < 1 || width > maxPosterSize || height > maxPosterSize {
+		return nil, fmt.Errorf("poster size %dx%d out of range [1, %d]", width, height, maxPosterSize)
+	}
+	if limit := int(s.device.GetLimits().Limits.MaxTextureDimension2D); cfg.Tile < 1 || cfg.Tile > limit {
+		return nil, fmt.Errorf("poster tile size %d out of range [1, %d]", cfg.Tile, limit)
+	}
+	if _, ok := s.sim.(*multiSim); ok {
+		return nil, fmt.Errorf("posters need a single instance")
+	}
+
+	poster := image.NewRGBA(image.Rect(0, 0, width, height))
+	for _, t := range viewmath.Tiles(width, height, cfg.Tile) {
+		tile, err := s.capture(s.config.Format, uint32(t.Width), uint32(t.Height), func(encoder *commandEncoder, view *wgpu.TextureView) {
+			pass := encoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
+				ColorAttachments: []wgpu.RenderPassColorAttachment{s.attachScene(view, uint32(t.Width), uint32(t.Height), s.palette.clear())},
+			})
+			defer pass.Release()
+			x, y, w, h := t.Viewport(width, height)
+			pass.SetViewport(x, y, w, h, 0, 1)
+			s.sim.Draw(pass)
+			pass.End()
+		})
+		if err != nil {
+			return nil, err
+		}
+		for row := 0; row < t.Height; row++ {
+			copy(poster.Pix[poster.PixOffset(t.X, t.Y+row):], tile.Pix[row*tile.Stride:row*tile.Stride+4*t.Width])
+		}
+	}
+	return poster, nil
+}
+
+// handlePosterKey saves a poster of the simulation as it is now with E, and
+// starts an animated GIF of the generations to come with Shift+E.
+func (s *State) handlePosterKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if key != glfw.KeyE || action != glfw.Press {
+		return
+	}
+	if mods&glfw.ModShift != 0 {
+		s.startGIF()
+		return
+	}
+	start := time.Now()
+	poster, err := s.renderPoster(s.cfg.Poster)
+	if err != nil {
+		fmt.Println("making a poster:", err)
+		return
+	}
+	path := fmt.Sprintf("poster-%06d.png", s.steps)
+	if err := savePNG(path, poster); err != nil {
+		fmt.Println("saving the poster:", err)
+		return
+	}
+	fmt.Printf("saved %s, %dx%d in %s\n", path, poster.Rect.Dx(), poster.Rect.Dy(), s.format.Duration(time.Since(start)))
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// presetPicker is a mode that lays a strip of live thumbnails over the
+// running simulation, one for its current settings and one for each of its
+// recommended presets. Left and right move between them, Enter switches to
+// the selected one and Esc leaves things as they were.
+type presetPicker struct {
+	prev     Mode
+	presets  []string
+	configs  []*Config
+	thumbs   *thumbnails
+	selected int
+}
+
+func (s *State) openPresetPicker() {
+	s.setMode(&presetPicker{prev: s.mode})
+}
+
+// presetConfig returns cfg with the flags in preset applied.
+func presetConfig(cfg *Config, preset string) (*Config, error) {
+	c := *cfg
+	fs := flag.NewFlagSet("preset", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	c.registerFlags(fs)
+	if err := fs.Parse(strings.Fields(preset)); err != nil {
+		return nil, fmt.Errorf("preset %q: %w", preset, err)
+	}
+	return &c, nil
+}
+
+func (p *presetPicker) Enter(s *State) {
+	current := *s.cfg
+	current.Grid = GridConfig{Width: s.gridWidth, Height: s.gridHeight}
+
+	var previews []*instance
+	for _, preset := range append([]string{""}, simulations[s.cfg.Simulation].Recommended...) {
+		cfg, err := presetConfig(&current, preset)
+		if err == nil {
+			var pv *instance
+			if pv, err = newPreview(s, cfg); err == nil {
+				p.presets = append(p.presets, preset)
+				p.configs = append(p.configs, cfg)
+				previews = append(previews, pv)
+				continue
+			}
+		}
+		log.Printf("previewing %q: %v", preset, err)
+	}
+	if len(previews) == 0 {
+		s.setMode(p.prev)
+		return
+	}
+
+	var err error
+	if p.thumbs, err = newThumbnails(s, previews); err != nil {
+		log.Println("previewing presets:", err)
+		s.setMode(p.prev)
+		return
+	}
+	p.describe(s)
+}
+
+func (p *presetPicker) Exit(s *State) {
+	if p.thumbs != nil {
+		p.thumbs.Release()
+		p.thumbs = nil
+	}
+}
+
+func (p *presetPicker) describe(s *State) {
+	preset := p.presets[p.selected]
+	if preset == "" {
+		preset = "current settings"
+	}
+	s.showPrompt(fmt.Sprintf("Preset %d/%d: %s (Enter picks, Esc goes back)", p.selected+1, len(p.presets), preset))
+}
+
+func (p *presetPicker) HandleKey(s *State, key glfw.Key, action glfw.Action, mods glfw.ModifierKey) bool {
+	if action == glfw.Release {
+		return false
+	}
+	switch key {
+	case glfw.KeyLeft:
+		if p.selected > 0 {
+			p.selected--
+			p.describe(s)
+		}
+	case glfw.KeyRight:
+		if p.selected < len(p.presets)-1 {
+			p.selected++
+			p.describe(s)
+		}
+	case glfw.KeyEscape:
+		s.setMode(p.prev)
+	case glfw.KeyEnter:
+		cfg := p.configs[p.selected]
+		s.setMode(p.prev)
+		if p.presets[p.selected] == "" {
+			return true
+		}
+		if err := s.switchSimulation(cfg); err != nil {
+			fmt.Println("switching preset:", err)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func (p *presetPicker) OnAction(s *State, action string) {}
+
+func (p *presetPicker) StepOverlay(encoder *commandEncoder) {
+	p.thumbs.Step(encoder)
+}
+
+func (p *presetPicker) RenderOverlay(encoder *commandEncoder) {
+	p.thumbs.render(encoder)
+}
+
+// DrawOverlay puts the thumbnails in a row along the bottom, the selected one
+// raised above the rest.
+func (p *presetPicker) DrawOverlay(s *State, pass *renderPass) {
+	n := float32(len(p.presets))
+	width, height := float32(s.config.Width), float32(s.config.Height)
+	size := min(width/n*0.8, height/4)
+	gap := (width - size*n) / (n + 1)
+	for i := range p.presets {
+		y := height - size*1.2
+		if i == p.selected {
+			y -= size / 8
+		}
+		p.thumbs.draw(pass, i, gap+(size+gap)*float32(i), y, size)
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+// recordingBacklog is how many frames can wait for ffmpeg before more are
+// dropped rather than holding the render loop up.
+const recordingBacklog = 32
+
+// recordingRing is how many frames can be on their way back from the GPU at
+// once.
+const recordingRing = 3
+
+// recording pipes every frame presented while it runs to ffmpeg as raw
+// RGBA, which encodes them into a video. It is the last stage frames go
+// through on their way to the screen: they are drawn into its texture,
+// copied from there into the next free buffer of a ring and read once the
+// GPU is done with them, frames later, so the loop never waits for them.
+// Frames that come while the ring is full or ffmpeg is too far behind, or
+// once the window is a different size from the video, are dropped and
+// counted.
+type recording struct {
+	path          string
+	width, height uint32
+	cmd           *exec.Cmd
+	frames        chan []byte
+	// done has what writing the frames to ffmpeg came to, once frames is
+	// closed.
+	done             chan error
+	written, dropped int
+	started          time.Time
+
+	// texture is what frames are drawn into, in format, and pipeline draws
+	// it on to the screen through set. Without a texture, for the float
+	// frames of an HDR display, frames are read back the way screenshots
+	// are instead, waiting on the GPU every frame.
+	format   wgpu.TextureFormat
+	texture  *wgpu.Texture
+	view     *wgpu.TextureView
+	set      *wgpu.BindGroup
+	pipeline *wgpu.RenderPipeline
+	// The buffers of the ring have rows rowSize bytes apart; free are
+	// those with no frame in, and inFlight the frames on their way back,
+	// oldest first.
+	rowSize  uint32
+	ring     []*wgpu.Buffer
+	free     []*wgpu.Buffer
+	inFlight []*recordedFrame
+}
+
+// recordedFrame is a frame copied into a buffer of the ring, to be read
+// once the buffer is mapped.
+type recordedFrame struct {
+	buffer        *wgpu.Buffer
+	mapping, done bool
+	err           error
+}
+
+// startRecording starts ffmpeg on a video named for the time, the size the
+// window is now.
+func (s *State) startRecording() (r *recording, err error) {
+	cfg := s.cfg.Recording
+	if cfg.Format != "mp4" && cfg.Format != "webm" {
+		return nil, fmt.Errorf("unknown recording format %q, want mp4 or webm", cfg.Format)
+	}
+	fps := strconv.Itoa(cfg.FPS)
+	if cfg.FPS == 0 {
+		fps = strconv.Itoa(int((time.Second + s.framePeriod()/2) / s.framePeriod()))
+	} else if cfg.FPS < 0 {
+		return nil, fmt.Errorf("recording needs a positive frame rate, got %d", cfg.FPS)
+	}
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("recording: %w", err)
+	}
+
+	r = &recording{
+		path:    time.Now().Format("recording-20060102-150405") + "." + cfg.Format,
+		width:   s.config.Width,
+		height:  s.config.Height,
+		frames:  make(chan []byte, recordingBacklog),
+		done:    make(chan error, 1),
+		started: time.Now(),
+	}
+	if err := r.setTarget(s); err != nil {
+		r.release()
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			r.release()
+		}
+	}()
+	r.cmd = exec.Command(ffmpeg, "-y", "-loglevel", "error",
+		"-f", "rawvideo", "-pix_fmt", "rgba",
+		"-video_size", fmt.Sprintf("%dx%d", r.width, r.height),
+		"-framerate", fps,
+		"-i", "-",
+		"-vf", "pad=ceil(iw/2)*2:ceil(ih/2)*2",
+		"-pix_fmt", "yuv420p",
+		r.path)
+	r.cmd.Stdout, r.cmd.Stderr = os.Stdout, os.Stderr
+	stdin, err := r.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting ffmpeg: %w", err)
+	}
+	bgra := r.format == wgpu.TextureFormat_BGRA8Unorm || r.format == wgpu.TextureFormat_BGRA8UnormSrgb
+	go func() {
+		var err error
+		for frame := range r.frames {
+			// Once ffmpeg has gone the rest are drained and thrown away.
+			if err == nil {
+				if bgra {
+					for i := 0; i < len(frame); i += 4 {
+						frame[i], frame[i+2] = frame[i+2], frame[i]
+					}
+				}
+				_, err = stdin.Write(frame)
+			}
+		}
+		if cerr := stdin.Close(); err == nil {
+			err = cerr
+		}
+		r.done <- err
+	}()
+	return r, nil
+}
+
+// setTarget makes the texture frames are drawn into and the ring they are
+// read back through, in the format the frame is in at the end: the
+// swapchain's, or the surface's after the output pass.
+func (r *recording) setTarget(s *State) (err error) {
+	r.format = s.config.Format
+	if s.output != nil {
+		r.format = s.surfaceFormat
+	}
+	switch r.format {
+	case wgpu.TextureFormat_BGRA8Unorm, wgpu.TextureFormat_BGRA8UnormSrgb,
+		wgpu.TextureFormat_RGBA8Unorm, wgpu.TextureFormat_RGBA8UnormSrgb:
+	default:
+		return nil
+	}
+	r.texture, err = s.device.CreateTexture(&wgpu.TextureDescriptor{
+		Label:         "recording",
+		Usage:         wgpu.TextureUsage_RenderAttachment | wgpu.TextureUsage_TextureBinding | wgpu.TextureUsage_CopySrc,
+		Dimension:     wgpu.TextureDimension_2D,
+		Size:          wgpu.Extent3D{Width: r.width, Height: r.height, DepthOrArrayLayers: 1},
+		Format:        r.format,
+		MipLevelCount: 1,
+		SampleCount:   1,
+	})
+	if err != nil {
+		return err
+	}
+	if r.view, err = r.texture.CreateView(nil); err != nil {
+		return err
+	}
+	r.set, err = s.device.CreateBindGroup(&wgpu.BindGroupDescriptor{
+		Label:   "recording",
+		Layout:  s.scene.layout,
+		Entries: []wgpu.BindGroupEntry{{Binding: 0, TextureView: r.view}},
+	})
+	if err != nil {
+		return err
+	}
+	if r.pipeline, err = s.scene.copyPipeline(s, r.format); err != nil {
+		return err
+	}
+	// Rows in a texture copy have to start on 256 byte boundaries.
+	r.rowSize = (r.width*4 + 255) / 256 * 256
+	for i := 0; i < recordingRing; i++ {
+		b, err := s.device.CreateBuffer(&wgpu.BufferDescriptor{
+			Label: "recording readback",
+			Size:  uint64(r.rowSize * r.height),
+			Usage: wgpu.BufferUsage_MapRead | wgpu.BufferUsage_CopyDst,
+		})
+		if err != nil {
+			return err
+		}
+		r.ring = append(r.ring, b)
+	}
+	r.free = append([]*wgpu.Buffer{}, r.ring...)
+	return nil
+}
+
+// drawing is whether frames are drawn through the recording, as they are
+// while it has a texture the size of the window.
+func (r *recording) drawing(s *State) bool {
+	return r != nil && r.texture != nil && s.config.Width == r.width && s.config.Height == r.height
+}
+
+func (r *recording) input() *wgpu.TextureView {
+	return r.view
+}
+
+// apply copies the frame into a free buffer of the ring, if there is one,
+// and draws it on into view.
+func (r *recording) apply(s *State, encoder *commandEncoder, view *wgpu.TextureView) {
+	if n := len(r.free); n == 0 {
+		r.dropped++
+	} else {
+		b := r.free[n-1]
+		size := wgpu.Extent3D{Width: r.width, Height: r.height, DepthOrArrayLayers: 1}
+		err := encoder.CopyTextureToBuffer(r.texture.AsImageCopy(), &wgpu.ImageCopyBuffer{
+			Buffer: b,
+			Layout: wgpu.TextureDataLayout{BytesPerRow: r.rowSize, RowsPerImage: r.height},
+		}, &size)
+		if err != nil {
+			fmt.Println("recording a frame:", err)
+			r.dropped++
+		} else {
+			r.free = r.free[:n-1]
+			r.inFlight = append(r.inFlight, &recordedFrame{buffer: b})
+		}
+	}
+
+	pass := encoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
+		ColorAttachments: []wgpu.RenderPassColorAttachment{attachColourToView(view, s.palette.clear())},
+	})
+	defer pass.Release()
+	pass.SetPipeline(r.pipeline)
+	pass.SetBindGroup(0, r.set, nil)
+	pass.Draw(3, 1, 0, 0)
+	pass.End()
+}
+
+// capture is called once the frame is submitted: it maps the buffers
+// copied into since the last, and queues the frames the GPU is done with
+// for ffmpeg.
+func (r *recording) capture(s *State) {
+	if r == nil {
+		return
+	}
+	if s.config.Width != r.width || s.config.Height != r.height {
+		r.dropped++
+	} else if r.texture == nil {
+		img, err := s.captureFrame()
+		if err != nil {
+			fmt.Println("recording a frame:", err)
+			r.dropped++
+		} else {
+			r.send(img.Pix)
+		}
+		return
+	}
+	r.collect(s, false)
+}
+
+// collect maps the buffers frames have been copied into and queues every
+// frame that has been read, in order, waiting for the GPU first if wait.
+func (r *recording) collect(s *State, wait bool) {
+	for _, f := range r.inFlight {
+		if f.mapping {
+			continue
+		}
+		f.mapping = true
+		err := f.buffer.MapAsync(wgpu.MapMode_Read, 0, f.buffer.GetSize(), func(status wgpu.BufferMapAsyncStatus) {
+			if status != wgpu.BufferMapAsyncStatus_Success {
+				f.err = fmt.Errorf("mapping buffer for reading: %s", status)
+			}
+			f.done = true
+		})
+		if err != nil {
+			f.err, f.done = err, true
+		}
+	}
+	if len(r.inFlight) == 0 {
+		return
+	}
+	s.device.Poll(wait, nil)
+	for len(r.inFlight) > 0 && r.inFlight[0].done {
+		f := r.inFlight[0]
+		r.inFlight = r.inFlight[1:]
+		if f.err != nil {
+			fmt.Println("recording a frame:", f.err)
+			r.dropped++
+		} else {
+			r.send(r.read(f.buffer))
+			f.buffer.Unmap()
+		}
+		r.free = append(r.free, f.buffer)
+	}
+}
+
+// read copies a frame out of a mapped buffer of the ring, its rows packed
+// and every pixel opaque.
+func (r *recording) read(b *wgpu.Buffer) []byte {
+	data := b.GetMappedRange(0, uint(b.GetSize()))
+	row := int(r.width) * 4
+	pix := make([]byte, row*int(r.height))
+	for y := 0; y < int(r.height); y++ {
+		copy(pix[y*row:], data[y*int(r.rowSize):y*int(r.rowSize)+row])
+	}
+	for i := 3; i < len(pix); i += 4 {
+		pix[i] = 255
+	}
+	return pix
+}
+
+// send queues a frame for ffmpeg, or drops it if ffmpeg is too far behind.
+func (r *recording) send(frame []byte) {
+	select {
+	case r.frames <- frame:
+		r.written++
+	default:
+		r.dropped++
+	}
+}
+
+func (r *recording) release() {
+	for _, b := range r.ring {
+		b.Release()
+	}
+	r.ring, r.free, r.inFlight = nil, nil, nil
+	if r.pipeline != nil {
+		r.pipeline.Release()
+		r.pipeline = nil
+	}
+	if r.set != nil {
+		r.set.Release()
+		r.set = nil
+	}
+	if r.view != nil {
+		r.view.Release()
+		r.view = nil
+	}
+	if r.texture != nil {
+		r.texture.Release()
+		r.texture = nil
+	}
+}
+
+// stop reads back the frames still on their way and waits for ffmpeg to
+// finish the video.
+func (r *recording) stop(s *State) error {
+	r.collect(s, true)
+	r.release()
+	close(r.frames)
+	err := <-r.done
+	if werr := r.cmd.Wait(); err == nil {
+		err = werr
+	}
+	if err != nil {
+		return fmt.Errorf("recording %s: %w", r.path, err)
+	}
+	fmt.Printf("wrote %s, %s frames in %s, %s dropped\n", r.path, s.format.Count(int64(r.written)),
+		s.format.Duration(time.Since(r.started)), s.format.Count(int64(r.dropped)))
+	return s.manifest.AddArtifact("video", r.path)
+}
+
+// toggleRecording starts recording, or stops the recording there is.
+func (s *State) toggleRecording() {
+	if s.recording != nil {
+		if err := s.recording.stop(s); err != nil {
+			fmt.Println(err)
+		}
+		s.recording = nil
+		return
+	}
+	r, err := s.startRecording()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("recording to", r.path)
+	s.recording = r
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// cellResetter is implemented by simulations that can start again on the
+// grid they have, from cells made on the CPU and written over their own.
+type cellResetter interface {
+	// resetCells starts again from a new soup, or an empty grid unless
+	// random.
+	resetCells(s *State, random bool) error
+}
+
+// reset starts the simulation again from generation 0, from a new soup with
+// a new seed or, unless random, from nothing.
+func (s *State) reset(random bool) error {
+	r, ok := s.sim.(cellResetter)
+	if !ok {
+		return fmt.Errorf("%s can't start again", simulations[s.cfg.Simulation].Name)
+	}
+	if random {
+		// The new seed comes from the old one, so the run can be repeated.
+		s.seed = s.rand.Int63()
+		s.rand = rand.New(rand.NewSource(s.seed))
+	}
+	if err := r.resetCells(s, random); err != nil {
+		return err
+	}
+	s.steps = 0
+	s.history = editHistory{}
+	s.highlights.restart()
+	s.timeline.restart(s)
+	return nil
+}
+
+// handleResetKey starts again from a new soup with N and from an empty grid
+// with C.
+func (s *State) handleResetKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if key != glfw.KeyN && key != glfw.KeyC || action != glfw.Press || mods != 0 {
+		return
+	}
+	if _, browsing := s.mode.(sceneMode); browsing {
+		return
+	}
+	random := key == glfw.KeyN
+	if err := s.reset(random); err != nil {
+		fmt.Println("starting again:", err)
+		return
+	}
+	if random {
+		fmt.Println("new soup from seed", s.seed)
+	} else {
+		fmt.Println("cleared the grid")
+	}
+}
+
+func (l *Life) resetCells(s *State, random bool) error {
+	cells := make([]uint32, l.width*l.height)
+	if random {
+		var err error
+		if cells, err = l.soup(s, s.cfg.Init); err != nil {
+			return err
+		}
+	}
+	data, ages := l.encode(cells), l.startingAges(cells)
+	for i := range l.cellStateStorage {
+		if err := s.queue.WriteBuffer(l.cellStateStorage[i], 0, data); err != nil {
+			return err
+		}
+		if ages == nil {
+			continue
+		}
+		if err := s.queue.WriteBuffer(l.ageStorage[i], 0, ages); err != nil {
+			return err
+		}
+	}
+	if l.trail != nil {
+		if err := s.queue.WriteBuffer(l.trail.buffer, 0, make([]byte, 4*l.width*l.height)); err != nil {
+			return err
+		}
+	}
+	return l.redraw(s)
+}
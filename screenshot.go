@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// screenshotName is the name screenshots are saved under, from when they
+// were taken down to the millisecond so that a burst of them don't collide.
+const screenshotName = "screenshot-20060102-150405.000.png"
+
+// takeScreenshot saves the window as it is now, effects included and the
+// HUD and panels left out, to a PNG named for the time. Reading the frame
+// back waits for the GPU to finish drawing it, which holds up this frame;
+// encoding and writing the PNG happen off the render loop.
+func (s *State) takeScreenshot() {
+	img, err := s.captureFrame()
+	if err != nil {
+		fmt.Println("taking a screenshot:", err)
+		return
+	}
+	path := time.Now().Format(screenshotName)
+	s.saving.Add(1)
+	go func() {
+		defer s.saving.Done()
+		if err := savePNG(path, img); err != nil {
+			fmt.Println("saving the screenshot:", err)
+			return
+		}
+		fmt.Println("saved", path)
+		if err := s.manifest.AddArtifact("screenshot", path); err != nil {
+			fmt.Println("recording the screenshot:", err)
+		}
+	}()
+}
+
+// handleScreenshotKey takes a screenshot with F12, and starts or stops
+// recording a video with Shift+F12.
+func (s *State) handleScreenshotKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if key != glfw.KeyF12 || action != glfw.Press {
+		return
+	}
+	if mods&glfw.ModShift != 0 {
+		s.toggleRecording()
+		return
+	}
+	s.takeScreenshot()
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+
+	"webgpu-go/viewmath"
+)
+
+var (
+	selectionEdge = [4]float32{0.3, 0.6, 1, 0.9}
+	selectionFill = [4]float32{0.3, 0.6, 1, 0.15}
+)
+
+// selection is a rectangle of cells, dragged out with Shift and the left
+// button, that Ctrl+C copies to the clipboard, Ctrl+X cuts and Delete
+// clears. The system clipboard gets them as RLE text as well. Esc lets go
+// of it. It is outlined over the simulation, and kept out of stills and
+// screenshots.
+type selection struct {
+	text *textRenderer
+	// rect is what is selected, nothing while its w is 0, dragged from the
+	// cell from.
+	rect      cellRect
+	from      [2]int
+	clipboard *pattern
+	// copied is the text put on the system clipboard with it, so that while
+	// it is still there pasting keeps the species RLE leaves out.
+	copied string
+}
+
+func newSelection(s *State) (*selection, error) {
+	text, err := newTextRenderer(s, s.surfaceFormat)
+	if err != nil {
+		return nil, err
+	}
+	return &selection{text: text}, nil
+}
+
+// begin starts a selection of the one cell x, y, and extend stretches it
+// from there to x, y.
+func (sel *selection) begin(x, y int) {
+	sel.from = [2]int{x, y}
+	sel.extend(x, y)
+}
+
+func (sel *selection) extend(x, y int) {
+	x0, x1 := min(sel.from[0], x), max(sel.from[0], x)
+	y0, y1 := min(sel.from[1], y), max(sel.from[1], y)
+	sel.rect = cellRect{x0, y0, x1 - x0 + 1, y1 - y0 + 1}
+}
+
+func (sel *selection) empty() bool { return sel.rect.w == 0 }
+
+// copy puts the selected cells on the clipboard.
+func (sel *selection) copy(s *State, e cellEditor) error {
+	cells, err := e.readCells(s, sel.rect)
+	if err != nil {
+		return err
+	}
+	sel.clipboard = &pattern{name: "the clipboard", width: sel.rect.w, height: sel.rect.h, cells: cells}
+	sel.copied = sel.clipboard.rle()
+	if s.window != nil {
+		s.window.SetClipboardString(sel.copied)
+	}
+	return nil
+}
+
+// clear kills the selected cells.
+func (sel *selection) clear(s *State, e cellEditor) error {
+	before, err := e.readCells(s, sel.rect)
+	if err != nil {
+		return err
+	}
+	return s.edit(e, sel.rect, before, make([]uint32, sel.rect.w*sel.rect.h), false)
+}
+
+// handleSelectionKey copies, cuts, clears and lets go of the selection.
+func (s *State) handleSelectionKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	sel := s.selection
+	if action != glfw.Press || sel == nil || sel.empty() {
+		return
+	}
+	e, ok := s.editor()
+	if !ok {
+		return
+	}
+	control := mods&(glfw.ModControl|glfw.ModSuper) != 0
+	var err error
+	switch {
+	case key == glfw.KeyC && control:
+		if err = sel.copy(s, e); err == nil {
+			fmt.Printf("copied %dx%d cells\n", sel.rect.w, sel.rect.h)
+		}
+	case key == glfw.KeyX && control:
+		if err = sel.copy(s, e); err == nil {
+			err = sel.clear(s, e)
+		}
+		if err == nil {
+			fmt.Printf("cut %dx%d cells\n", sel.rect.w, sel.rect.h)
+		}
+	case key == glfw.KeyDelete || key == glfw.KeyBackspace:
+		err = sel.clear(s, e)
+	case key == glfw.KeyEscape:
+		sel.rect = cellRect{}
+	}
+	if err != nil {
+		fmt.Println("editing the selection:", err)
+	}
+}
+
+// update outlines the selection where the camera shows it, letting go of it
+// if it no longer fits the grid.
+func (sel *selection) update(s *State) {
+	if sel == nil {
+		return
+	}
+	r := sel.rect
+	if r.x+r.w > s.gridWidth || r.y+r.h > s.gridHeight {
+		sel.rect = cellRect{}
+	}
+	t := sel.text
+	t.clear()
+	if _, ok := s.editor(); !ok || sel.empty() || s.camera == nil {
+		return
+	}
+	left, top, width, height := s.cellPixels(r)
+	t.addRect(left, top, width, height, selectionFill)
+	outline(t, left, top, width, height, max(1, s.scaled(1)), selectionEdge)
+	if err := t.upload(); err != nil {
+		fmt.Println("outlining the selection:", err)
+	}
+}
+
+// cellPixels is where the camera shows the cells of r, as the left, top,
+// width and height of a rectangle of framebuffer pixels.
+func (s *State) cellPixels(r cellRect) (left, top, width, height float32) {
+	// The corners go from grid space through the camera to framebuffer
+	// pixels, y down.
+	corner := func(x, y int) (float32, float32) {
+		p := s.camera.view.GridToClip(viewmath.Vec2{
+			2*float32(x)/float32(s.gridWidth) - 1,
+			2*float32(y)/float32(s.gridHeight) - 1,
+		})
+		return (p[0] + 1) / 2 * float32(s.config.Width), (1 - p[1]) / 2 * float32(s.config.Height)
+	}
+	left, bottom := corner(r.x, r.y)
+	right, top := corner(r.x+r.w, r.y+r.h)
+	return left, top, right - left, bottom - top
+}
+
+// outline adds the edges of a rectangle, edge pixels thick, to t.
+func outline(t *textRenderer, left, top, width, height, edge float32, colour [4]float32) {
+	t.addRect(left, top, width, edge, colour)
+	t.addRect(left, top+height-edge, width, edge, colour)
+	t.addRect(left, top, edge, height, colour)
+	t.addRect(left+width-edge, top, edge, height, colour)
+}
+
+// draw records the outline over view, if there is a selection.
+func (sel *selection) draw(s *State, encoder *commandEncoder, view *wgpu.TextureView) {
+	if sel == nil || len(sel.text.instances) == 0 {
+		return
+	}
+	sel.text.draw(encoder, view, s.config.Width, s.config.Height)
+}
+
+func (sel *selection) Release() {
+	if sel.text != nil {
+		sel.text.Release()
+		sel.text = nil
+	}
+}
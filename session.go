@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// sessionEvent is one parameter taking a new value, stamped with how far
+// into the session it happened.
+type sessionEvent struct {
+	Time       time.Duration `json:"t"`
+	Simulation string        `json:"simulation"`
+	Name       string        `json:"name"`
+	Value      float64       `json:"value"`
+}
+
+// session records every parameter change as JSON lines, from the keys, the
+// dashboard or a replay, and replays a recorded one at the pace it was
+// played. Both can play an audio track from the start, so that a
+// performance is rehearsed and reproduced against the same music.
+type session struct {
+	start time.Time
+
+	file *os.File
+	out  *bufio.Writer
+	enc  *json.Encoder
+	// last is what was recorded for each parameter, by simulation.
+	last map[string]map[string]float64
+
+	events []sessionEvent
+	next   int
+
+	audio *exec.Cmd
+}
+
+// newSession returns nil when there is nothing to record or replay.
+func newSession(cfg SessionConfig) (l *session, err error) {
+	if cfg.Record == "" && cfg.Replay == "" {
+		return nil, nil
+	}
+	l = &session{last: map[string]map[string]float64{}}
+	defer func() {
+		if err != nil {
+			l.Close()
+		}
+	}()
+	if cfg.Replay != "" {
+		if l.events, err = readSession(cfg.Replay); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Record != "" {
+		if l.file, err = os.Create(cfg.Record); err != nil {
+			return nil, err
+		}
+		l.out = bufio.NewWriter(l.file)
+		l.enc = json.NewEncoder(l.out)
+	}
+	if cfg.Audio != "" {
+		ffplay, err := exec.LookPath("ffplay")
+		if err != nil {
+			return nil, fmt.Errorf("-session-audio needs ffplay, from ffmpeg: %w", err)
+		}
+		l.audio = exec.Command(ffplay, "-nodisp", "-autoexit", "-loglevel", "error", cfg.Audio)
+		l.audio.Stderr = os.Stderr
+		if err := l.audio.Start(); err != nil {
+			l.audio = nil
+			return nil, err
+		}
+	}
+	l.start = time.Now()
+	return l, nil
+}
+
+func readSession(path string) ([]sessionEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var events []sessionEvent
+	dec := json.NewDecoder(f)
+	for {
+		var e sessionEvent
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// update replays the events that are due and records whatever has changed
+// since the last frame. It runs on the main thread once a frame.
+func (l *session) update(s *State) {
+	if l == nil {
+		return
+	}
+	elapsed := time.Since(l.start)
+	for ; l.next < len(l.events) && l.events[l.next].Time <= elapsed; l.next++ {
+		// Events for simulations that aren't running are passed over.
+		if e := l.events[l.next]; e.Simulation == s.cfg.Simulation {
+			if err := s.setParameter(e.Name, e.Value); err != nil {
+				log.Println("replaying session:", err)
+			}
+		}
+		if l.next == len(l.events)-1 {
+			fmt.Println("session replayed")
+		}
+	}
+
+	if l.enc == nil {
+		return
+	}
+	snap := s.parameters.snapshot()
+	last := l.last[snap.Simulation]
+	if last == nil {
+		last = map[string]float64{}
+		l.last[snap.Simulation] = last
+	}
+	for _, p := range snap.Parameters {
+		if v, ok := last[p.Name]; ok && v == p.Value {
+			continue
+		}
+		last[p.Name] = p.Value
+		l.enc.Encode(sessionEvent{elapsed, snap.Simulation, p.Name, p.Value})
+	}
+}
+
+func (l *session) Close() error {
+	if l.audio != nil {
+		l.audio.Process.Kill()
+		l.audio.Wait()
+	}
+	if l.file == nil {
+		return nil
+	}
+	if err := l.out.Flush(); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+
+	"webgpu-go/viewmath"
+)
+
+// Simulation is a GPU automaton that State steps and draws every frame.
+type Simulation interface {
+	// Step records one generation of compute work into the encoder.
+	Step(encoder *commandEncoder)
+	// Draw records the draw calls for the current generation.
+	Draw(pass *renderPass)
+	Release()
+}
+
+// keyHandler is implemented by simulations that have their own runtime
+// controls.
+type keyHandler interface {
+	HandleKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey)
+}
+
+// mouseHandler is implemented by simulations the mouse can reach into. x and
+// y run from -1 to 1 across the window, with y up.
+type mouseHandler interface {
+	HandleMouse(x, y float32, left, right bool)
+}
+
+// handleMouse passes where the cursor is and which buttons are held on to
+// the simulation, if it takes the mouse.
+func (s *State) handleMouse(w *glfw.Window) {
+	h, ok := s.sim.(mouseHandler)
+	if !ok {
+		return
+	}
+	width, height := w.GetSize()
+	x, y := w.GetCursorPos()
+	at, ok := viewmath.CursorToClip(x, y, width, height)
+	if !ok {
+		return
+	}
+	h.HandleMouse(at[0], at[1],
+		w.GetMouseButton(glfw.MouseButtonLeft) == glfw.Press,
+		w.GetMouseButton(glfw.MouseButtonRight) == glfw.Press)
+}
+
+// populationCounter is implemented by simulations that can say how much of
+// the grid is alive, which is what highlights watch for sudden changes.
+type populationCounter interface {
+	Population(s *State) (float64, error)
+}
+
+// tunable is implemented by simulations with parameters that can be changed
+// while they run, which the dashboard has controls for.
+type tunable interface {
+	Parameters() []parameter
+	SetParameter(name string, value float64) error
+}
+
+// parameter is a setting of a tunable simulation and the range it can take.
+type parameter struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+}
+
+// liveCells counts the non-zero cells in a u32 cell buffer.
+func liveCells(s *State, buf *wgpu.Buffer) (float64, error) {
+	data, err := s.readBuffer(buf)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, c := range wgpu.FromBytes[uint32](data) {
+		if c != 0 {
+			n++
+		}
+	}
+	return float64(n), nil
+}
+
+// simulationInfo is a registered simulation and what the rule browser says
+// about it.
+type simulationInfo struct {
+	create func(s *State, cfg *Config) (Simulation, error)
+
+	Name        string
+	Description string
+	Discoverer  string
+	// Behaviours are what to look out for when it runs.
+	Behaviours []string
+	// Recommended are flags worth trying with it.
+	Recommended []string
+}
+
+var simulations = map[string]simulationInfo{
+	"life": {
+		create:      newLife,
+		Name:        "Conway's Game of Life",
+		Description: "Cells are born with three live neighbours and survive with two or three.",
+		Discoverer:  "John Horton Conway, 1970",
+		Behaviours:  []string{"gliders", "blinkers and other oscillators", "still lifes left behind as the soup settles"},
+		Recommended: []string{"-boundary dead", "-topology hex", "-packed -width 8192 -height 8192", "-species 2"},
+	},
+	"life-3d": {
+		create:      newLife3D,
+		Name:        "3D Life",
+		Description: "Life in a cube with 26 neighbours, using survival and birth ranges.",
+		Discoverer:  "Carter Bays, 1987",
+		Behaviours:  []string{"4555 grows and shrinks in blobs", "5766 has gliders of its own"},
+		Recommended: []string{"-rule-3d 4555", "-rule-3d 5766 -size-3d 64"},
+	},
+	"life-sparse": {
+		create:      newSparseLife,
+		Name:        "Unbounded Life",
+		Description: "Conway's Life on a plane with no edges, stored as chunks around the live cells.",
+		Discoverer:  "John Horton Conway, 1970",
+		Behaviours:  []string{"gliders escaping from the soup for good"},
+		Recommended: []string{"-width 512 -height 512"},
+	},
+	"hashlife": {
+		create:      newHashLife,
+		Name:        "HashLife",
+		Description: "Conway's Life on the CPU, remembering how each square evolves to leap through generations.",
+		Discoverer:  "Bill Gosper, 1984",
+		Behaviours:  []string{"slow to start on a fresh soup", "huge jumps once the soup has settled into still lifes and oscillators"},
+		Recommended: []string{"-hashlife-steps 64", "-fast-forward 1048576"},
+	},
+	"table": {
+		create:      newRuleTable,
+		Name:        "Rule Tables",
+		Description: "Any outer-totalistic automaton, looking up each cell's next state by its state and live neighbours.",
+		Discoverer:  "Brian Silverman, whose Brian's Brain is the default rule, 1996",
+		Behaviours:  []string{"B2/S/3 fills with sparks and gliders", "B2/S345/4 (Star Wars) builds ships out of the debris", "R5,C0,M1,S34..58,B34..45,NM (Bosco's rule) grows blobs that crawl about"},
+		Recommended: []string{"-table-rule B2/S/3", "-table-rule B2/S345/4", "-table-rule B36/S23", "-table-rule R5,C0,M1,S34..58,B34..45,NM -density 0.4"},
+	},
+	"stochastic": {
+		create:      newStochastic,
+		Name:        "Stochastic Automata",
+		Description: "Rules whose transitions happen by chance: forest fires, or life where births and deaths may not happen.",
+		Discoverer:  "Barbara Drossel and Franz Schwabl, whose forest-fire model is the default rule, 1992",
+		Behaviours:  []string{"fires of every size sweep through the regrowing forest", "life with chancy deaths boils instead of settling"},
+		Recommended: []string{"-stochastic-lightning 0.000001", "-stochastic-rule life -stochastic-death 0.5"},
+	},
+	"nbody": {
+		create:      newNBody,
+		Name:        "N-Body Gravity",
+		Description: "Thousands of equal masses pulling on each other, starting out as a spinning disc.",
+		Discoverer:  "Isaac Newton, 1687; binned like a particle-mesh code",
+		Behaviours:  []string{"the disc winds up into spiral arms", "clumps merge and sink to the middle"},
+		Recommended: []string{"-nbody-count 16384 -nbody-steps 2", "-nbody-softening 0.01"},
+	},
+	"sph": {
+		create:      newSPH,
+		Name:        "SPH Fluid",
+		Description: "Water as particles that push apart where they crowd, breaking out of a dam.",
+		Discoverer:  "Gingold, Monaghan and Lucy, 1977",
+		Behaviours:  []string{"a wave runs along the floor and breaks on the far wall", "the water sloshes and settles flat", "drags and splashes under the mouse"},
+		Recommended: []string{"-sph-count 16384", "-sph-viscosity 0.05"},
+	},
+	"cloth": {
+		create:      newCloth,
+		Name:        "Cloth",
+		Description: "A square of cloth as particles on springs, hanging from two corners.",
+		Discoverer:  "Xavier Provot, 1995; relaxed like Thomas Jakobsen's Hitman cloth",
+		Behaviours:  []string{"the cloth swings down and folds over itself", "drags and tugs under the mouse"},
+		Recommended: []string{"-cloth-size 96 -cloth-iterations 32", "-cloth-iterations 4"},
+	},
+	"fractal": {
+		create:      newFractal,
+		Name:        "Ray-Marched Fractal",
+		Description: "A Mandelbulb or Menger sponge, ray-marched through every pixel and averaged into a still.",
+		Discoverer:  "Daniel White and Paul Nylander, 2009; Karl Menger, 1926",
+		Behaviours:  []string{"the image sharpens as samples pile up once the camera stops", "bulbs within bulbs down to the pixel"},
+		Recommended: []string{"-fractal-orbit=false -fractal-still fractal.png", "-fractal menger -fractal-iterations 5"},
+	},
+	"ant": {
+		create:      newAnts,
+		Name:        "Langton's Ant and Turmites",
+		Description: "Ants turn by the colour of the cell they are on, then change it and move on.",
+		Discoverer:  "Chris Langton, 1986",
+		Behaviours:  []string{"RL wanders for about 10,000 steps and then builds a highway", "LLRR grows symmetrically"},
+		Recommended: []string{"-ant-rule RL", "-ant-rule LLRR -ants 4"},
+	},
+	"lenia": {
+		create:      newLenia,
+		Name:        "Lenia",
+		Description: "A continuous Life with smooth states, a ring-shaped neighbourhood and a growth curve.",
+		Discoverer:  "Bert Wang-Chak Chan, 2018",
+		Behaviours:  []string{"gliding, breathing creatures", "blooms that fill the grid or die out"},
+		Recommended: []string{"-lenia-radius 13 -lenia-mu 0.15 -lenia-sigma 0.015"},
+	},
+	"gray-scott": {
+		create:      newGrayScott,
+		Name:        "Gray-Scott Reaction-Diffusion",
+		Description: "Two chemicals diffuse at different rates, one feeding on the other.",
+		Discoverer:  "Peter Gray and Stephen K. Scott, 1983",
+		Behaviours:  []string{"spots that divide", "stripes and labyrinths", "waves"},
+		Recommended: []string{"-gs-feed 0.0367 -gs-kill 0.0649", "-gs-feed 0.03 -gs-kill 0.062"},
+	},
+}
+
+func simulationNames() []string {
+	names := make([]string, 0, len(simulations))
+	for name := range simulations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newSimulation(s *State, cfg *Config) (Simulation, error) {
+	info, ok := simulations[cfg.Simulation]
+	if !ok {
+		return nil, fmt.Errorf("unknown simulation %q (have %v)", cfg.Simulation, simulationNames())
+	}
+	return info.create(s, cfg)
+}
+
+var quadBufferLayout = []wgpu.VertexBufferLayout{
+	{
+		ArrayStride: 8,
+		StepMode:    wgpu.VertexStepMode_Vertex,
+		Attributes: []wgpu.VertexAttribute{
+			{
+				Format:         wgpu.VertexFormat_Float32x2,
+				Offset:         0,
+				ShaderLocation: 0,
+			},
+		},
+	},
+}
+
+func bufferEntry(binding uint32, visibility wgpu.ShaderStage, typ wgpu.BufferBindingType) wgpu.BindGroupLayoutEntry {
+	return wgpu.BindGroupLayoutEntry{
+		Binding:    binding,
+		Visibility: visibility,
+		Buffer: wgpu.BufferBindingLayout{
+			Type: typ,
+		},
+	}
+}
+
+func (s *State) bindGroupLayout(label string, entries ...wgpu.BindGroupLayoutEntry) (*wgpu.BindGroupLayout, error) {
+	return s.device.CreateBindGroupLayout(&wgpu.BindGroupLayoutDescriptor{
+		Label:   label,
+		Entries: entries,
+	})
+}
+
+func (s *State) pipelineLayout(label string, layouts ...*wgpu.BindGroupLayout) (*wgpu.PipelineLayout, error) {
+	return s.device.CreatePipelineLayout(&wgpu.PipelineLayoutDescriptor{
+		Label:            label,
+		BindGroupLayouts: layouts,
+	})
+}
+
+// renderPipeline creates a pipeline drawing instanced copies of the tile in
+// s.vertexBuffer into the swapchain.
+func (s *State) renderPipeline(label string, layout *wgpu.PipelineLayout, shader *wgpu.ShaderModule, vs, fs string) (*wgpu.RenderPipeline, error) {
+	return s.blendedRenderPipeline(label, layout, shader, vs, fs, nil)
+}
+
+// additiveBlend adds what is drawn to what is already there, for glowing
+// sprites that brighten where they overlap.
+var additiveBlend = &wgpu.BlendState{
+	Color: wgpu.BlendComponent{Operation: wgpu.BlendOperation_Add, SrcFactor: wgpu.BlendFactor_One, DstFactor: wgpu.BlendFactor_One},
+	Alpha: wgpu.BlendComponent{Operation: wgpu.BlendOperation_Add, SrcFactor: wgpu.BlendFactor_One, DstFactor: wgpu.BlendFactor_One},
+}
+
+// blendedRenderPipeline is renderPipeline blending into the swapchain with
+// blend, or replacing what is there when it is nil.
+func (s *State) blendedRenderPipeline(label string, layout *wgpu.PipelineLayout, shader *wgpu.ShaderModule, vs, fs string, blend *wgpu.BlendState) (*wgpu.RenderPipeline, error) {
+	return s.device.CreateRenderPipeline(&wgpu.RenderPipelineDescriptor{
+		Label:  label,
+		Layout: layout,
+		Vertex: wgpu.VertexState{
+			Module:     shader,
+			EntryPoint: vs,
+			Buffers:    quadBufferLayout,
+		},
+		Fragment: &wgpu.FragmentState{
+			Module:     shader,
+			EntryPoint: fs,
+			Targets: []wgpu.ColorTargetState{
+				{
+					Format:    s.config.Format,
+					Blend:     blend,
+					WriteMask: wgpu.ColorWriteMask_All,
+				},
+			},
+		},
+		Primitive: wgpu.PrimitiveState{
+			Topology:  wgpu.PrimitiveTopology_TriangleList,
+			FrontFace: wgpu.FrontFace_CCW,
+			CullMode:  wgpu.CullMode_Back,
+		},
+		Multisample: s.multisampleState(),
+	})
+}
+
+func (s *State) computePipeline(label string, layout *wgpu.PipelineLayout, shader *wgpu.ShaderModule, entry string) (*wgpu.ComputePipeline, error) {
+	return s.device.CreateComputePipeline(&wgpu.ComputePipelineDescriptor{
+		Label:  label,
+		Layout: layout,
+		Compute: wgpu.ProgrammableStageDescriptor{
+			Module:     shader,
+			EntryPoint: entry,
+		},
+	})
+}
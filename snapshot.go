@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+// snapshotName is what Ctrl+S names snapshots, from when they were saved.
+const snapshotName = "20060102-150405"
+
+// snapshot is everything there is to a running simulation, to carry on from
+// exactly where it was: its grid, rule and generation, both cell buffers,
+// the current generation's first, and the seed the run started from. They
+// are stored as gzipped JSON under snapshots/ in the store.
+type snapshot struct {
+	Simulation string    `json:"simulation"`
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	Generation int       `json:"generation"`
+	Seed       int64     `json:"seed"`
+	Life       *lifeRule `json:"life,omitempty"`
+	// Cells and Ages are the buffers as the GPU has them. Packed cells have
+	// no ages.
+	Cells [2][]byte `json:"cells"`
+	Ages  [2][]byte `json:"ages,omitempty"`
+}
+
+// lifeRule is what sets one life grid apart from another.
+type lifeRule struct {
+	Topology string `json:"topology"`
+	Boundary string `json:"boundary"`
+	Packed   bool   `json:"packed"`
+	Species  int    `json:"species"`
+}
+
+// snapshotter is implemented by simulations that can be saved as snapshots
+// and restored from them. fits is whether a snapshot can be restored into
+// the simulation as it is, without starting a new one.
+type snapshotter interface {
+	snapshot(s *State) (*snapshot, error)
+	fits(snap *snapshot) bool
+	restore(s *State, snap *snapshot) error
+}
+
+func snapshotKey(name string) string {
+	return "snapshots/" + name + ".snap"
+}
+
+// saveSnapshot stores the simulation as it is now under name.
+func (s *State) saveSnapshot(name string) error {
+	sn, ok := s.sim.(snapshotter)
+	if !ok {
+		return fmt.Errorf("%s can't be saved as a snapshot", simulations[s.cfg.Simulation].Name)
+	}
+	snap, err := sn.snapshot(s)
+	if err != nil {
+		return err
+	}
+	snap.Simulation, snap.Generation, snap.Seed = s.cfg.Simulation, s.steps, s.seed
+	var b bytes.Buffer
+	z := gzip.NewWriter(&b)
+	if err := json.NewEncoder(z).Encode(snap); err != nil {
+		return err
+	}
+	if err := z.Close(); err != nil {
+		return err
+	}
+	return s.store.Put(context.Background(), snapshotKey(name), b.Bytes())
+}
+
+func readSnapshot(store Store, name string) (*snapshot, error) {
+	b, err := store.Get(context.Background(), snapshotKey(name))
+	if err != nil {
+		return nil, err
+	}
+	z, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", name, err)
+	}
+	var snap snapshot
+	if err := json.NewDecoder(z).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", name, err)
+	}
+	return &snap, nil
+}
+
+// loadSnapshot restores the snapshot saved as name, starting the simulation
+// it was saved from over again first if the one running isn't it.
+func (s *State) loadSnapshot(name string) error {
+	snap, err := readSnapshot(s.store, name)
+	if err != nil {
+		return err
+	}
+	if _, ok := simulations[snap.Simulation]; !ok {
+		return fmt.Errorf("snapshot %s is of %q, which there isn't", name, snap.Simulation)
+	}
+	if sn, ok := s.sim.(snapshotter); !ok || s.cfg.Simulation != snap.Simulation || !sn.fits(snap) {
+		cfg := *s.cfg
+		cfg.Simulation, cfg.Instances, cfg.Panes = snap.Simulation, 1, nil
+		cfg.Grid = GridConfig{Width: snap.Width, Height: snap.Height}
+		if r := snap.Life; r != nil {
+			cfg.Life.Topology, cfg.Life.Boundary, cfg.Life.Packed, cfg.Life.Species = r.Topology, r.Boundary, r.Packed, r.Species
+		}
+		if err := s.switchSimulation(&cfg); err != nil {
+			return err
+		}
+	}
+	sn, ok := s.sim.(snapshotter)
+	if !ok || !sn.fits(snap) {
+		return fmt.Errorf("snapshot %s doesn't fit %s", name, simulations[snap.Simulation].Name)
+	}
+	if err := sn.restore(s, snap); err != nil {
+		return err
+	}
+	s.steps, s.seed = snap.Generation, snap.Seed
+	s.rand = rand.New(rand.NewSource(snap.Seed))
+	s.history = editHistory{}
+	s.highlights.restart()
+	s.timeline.restart(s)
+	return nil
+}
+
+// latestSnapshot is the name of the snapshot saved last, going by the
+// names Ctrl+S gives them.
+func (s *State) latestSnapshot() (string, error) {
+	keys, err := s.store.List(context.Background(), "snapshots/")
+	if err != nil {
+		return "", err
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		if strings.HasSuffix(keys[i], ".snap") {
+			return strings.TrimSuffix(strings.TrimPrefix(keys[i], "snapshots/"), ".snap"), nil
+		}
+	}
+	return "", fmt.Errorf("there are no snapshots")
+}
+
+// handleSnapshotKey saves a snapshot named for the time with Ctrl+S and
+// loads the last one saved with Ctrl+O.
+func (s *State) handleSnapshotKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if action != glfw.Press || mods&(glfw.ModControl|glfw.ModSuper) == 0 {
+		return
+	}
+	switch key {
+	case glfw.KeyS:
+		name := time.Now().Format(snapshotName)
+		if err := s.saveSnapshot(name); err != nil {
+			fmt.Println("saving a snapshot:", err)
+			return
+		}
+		fmt.Printf("saved snapshot %s at generation %d\n", name, s.steps)
+	case glfw.KeyO:
+		name, err := s.latestSnapshot()
+		if err == nil {
+			err = s.loadSnapshot(name)
+		}
+		if err != nil {
+			fmt.Println("loading a snapshot:", err)
+			return
+		}
+		fmt.Printf("loaded snapshot %s at generation %d\n", name, s.steps)
+	}
+}
+
+// runSnapshots is the snapshots subcommand: it lists the snapshots in the
+// store, or deletes those named with -delete.
+func runSnapshots(args []string) error {
+	fs := flag.NewFlagSet("snapshots", flag.ExitOnError)
+	storage := fs.String("storage", defaultConfig().Storage, "directory or s3://bucket/prefix the snapshots are in (default: user config dir)")
+	remove := fs.Bool("delete", false, "delete the snapshots named instead of listing them")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s snapshots [flags] [-delete name ...]\n\nSnapshots are saved with Ctrl+S and started from with -snapshot.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	store, err := openStore(*storage)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if *remove {
+		for _, name := range fs.Args() {
+			if err := store.Delete(ctx, snapshotKey(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	keys, err := store.List(ctx, "snapshots/")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if !strings.HasSuffix(key, ".snap") {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "snapshots/"), ".snap")
+		snap, err := readSnapshot(store, name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-20s %-12s %5dx%-5d generation %d\n", name, snap.Simulation, snap.Width, snap.Height, snap.Generation)
+	}
+	return nil
+}
+
+func (l *Life) snapshot(s *State) (*snapshot, error) {
+	snap := &snapshot{
+		Width:  l.width,
+		Height: l.height,
+		Life:   &lifeRule{Topology: l.topology, Boundary: boundaryModes[l.boundary], Packed: l.packed, Species: l.species},
+	}
+	for i := range snap.Cells {
+		var err error
+		if snap.Cells[i], err = s.readBuffer(l.cellStateStorage[(l.steps+i)%2]); err != nil {
+			return nil, err
+		}
+		if l.packed {
+			continue
+		}
+		if snap.Ages[i], err = s.readBuffer(l.ageStorage[(l.steps+i)%2]); err != nil {
+			return nil, err
+		}
+	}
+	return snap, nil
+}
+
+func (l *Life) fits(snap *snapshot) bool {
+	r := snap.Life
+	return r != nil && snap.Width == l.width && snap.Height == l.height &&
+		r.Topology == l.topology && r.Packed == l.packed && r.Species == l.species
+}
+
+func (l *Life) restore(s *State, snap *snapshot) error {
+	boundary, err := parseBoundary(snap.Life.Boundary)
+	if err != nil {
+		return err
+	}
+	size := len(l.encode(make([]uint32, l.width*l.height)))
+	for i := range snap.Cells {
+		if len(snap.Cells[i]) != size || !l.packed && len(snap.Ages[i]) != size {
+			return fmt.Errorf("snapshot cells are the wrong size for a %dx%d grid", l.width, l.height)
+		}
+	}
+	l.setCells(s, snap.Cells[0], snap.Ages[0])
+	previous := (l.steps + 1) % 2
+	if err := s.queue.WriteBuffer(l.cellStateStorage[previous], 0, snap.Cells[1]); err != nil {
+		return err
+	}
+	if !l.packed {
+		if err := s.queue.WriteBuffer(l.ageStorage[previous], 0, snap.Ages[1]); err != nil {
+			return err
+		}
+	}
+	l.boundary = boundary
+	if err := s.queue.WriteBuffer(l.boundaryBuffer, 0, wgpu.ToBytes([]uint32{l.boundary})); err != nil {
+		return err
+	}
+	return l.redraw(s)
+}
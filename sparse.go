@@ -0,0 +1,390 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"log"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed sparse_draw.wgsl
+var sparseDraw string
+
+//go:embed sparse_compute.wgsl
+var sparseCompute string
+
+const (
+	chunkSize  = 64
+	chunkCells = chunkSize * chunkSize
+	// chunkWords is the size of a Chunk in the shaders: the position, whether
+	// the slot is used and the slots of the nine chunks around it.
+	chunkWords = 12
+	noChunk    = 0xffffffff
+	// manageEvery is how many steps go by between reading back which chunks
+	// are needed. The shader's margin has to be at least this, so that
+	// nothing can grow past a chunk that is not there yet.
+	manageEvery = 8
+	// maxSparseView is the largest view of the world, in cells.
+	maxSparseView = 1 << 16
+)
+
+type chunkPos struct{ x, y int32 }
+
+// SparseLife is life on an unbounded plane. Only the 64x64 chunks with live
+// cells in or near them are kept on the GPU, in slots of one pair of cell
+// buffers that grows as it fills. Every few steps the chunks report which of
+// their edges have live cells close to them, and chunks are added there and
+// dropped where they have emptied.
+type SparseLife struct {
+	bindGroupLayout    *wgpu.BindGroupLayout
+	pipelineLayout     *wgpu.PipelineLayout
+	pipeline           *wgpu.RenderPipeline
+	simulationPipeline *wgpu.ComputePipeline
+
+	view             *wgpu.Buffer
+	chunkBuffer      *wgpu.Buffer
+	flags            *wgpu.Buffer
+	cellStateStorage []*wgpu.Buffer
+	gridBindGroups   []*wgpu.BindGroup
+	vertices         *wgpu.Buffer
+	// state reads the chunk flags back between steps.
+	state *State
+
+	chunks map[chunkPos]int
+	slots  []chunkPos
+	free   []int
+
+	// The view is width by height cells centred on centreX, centreY.
+	centreX, centreY float32
+	width, height    int
+	steps            int
+}
+
+func newSparseLife(s *State, cfg *Config) (sim Simulation, err error) {
+	l := &SparseLife{
+		vertices: s.vertexBuffer,
+		state:    s,
+		chunks:   map[chunkPos]int{},
+		width:    s.gridWidth,
+		height:   s.gridHeight,
+	}
+	defer func() {
+		if err != nil {
+			l.Release()
+		}
+	}()
+
+	drawShader := s.createShader("sparse life render shader", sparseDraw+paletteShader)
+	defer drawShader.Release()
+
+	computeShader := s.createShader("sparse life compute shader", sparseCompute)
+	defer computeShader.Release()
+
+	l.bindGroupLayout, err = s.bindGroupLayout("sparse life",
+		bufferEntry(0, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(2, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(3, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(4, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(5, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	l.pipelineLayout, err = s.pipelineLayout("sparse life", l.bindGroupLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	l.pipeline, err = s.renderPipeline("sparse life render", l.pipelineLayout, drawShader, "main_vs", "main_fs")
+	if err != nil {
+		return nil, err
+	}
+	l.simulationPipeline, err = s.computePipeline("sparse life compute", l.pipelineLayout, computeShader, "main")
+	if err != nil {
+		return nil, err
+	}
+
+	l.view = s.uniformBuffer("sparse life view", l.viewBytes())
+
+	gen, err := newGenerator(cfg.Init)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start with the pattern the size of the view, in chunks along with the
+	// ring around them that it can grow into.
+	x0, y0 := -l.width/2, -l.height/2
+	x1, y1 := x0+l.width, y0+l.height
+	for y := floorDiv(y0, chunkSize) - 1; y <= floorDiv(y1-1, chunkSize)+1; y++ {
+		for x := floorDiv(x0, chunkSize) - 1; x <= floorDiv(x1-1, chunkSize)+1; x++ {
+			l.allocate(chunkPos{x, y})
+		}
+	}
+	cells := make([]uint32, len(l.slots)*chunkCells)
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			if gen.Alive(s.rand, x-x0, y-y0, l.width, l.height) {
+				cells[l.cellIndex(x, y)] = 1
+			}
+		}
+	}
+	l.setCells(s, max(len(l.slots), 16), cells)
+	return l, nil
+}
+
+func floorDiv(a, b int) int32 {
+	q := a / b
+	if a%b < 0 {
+		q--
+	}
+	return int32(q)
+}
+
+func (l *SparseLife) cellIndex(x, y int) int {
+	pos := chunkPos{floorDiv(x, chunkSize), floorDiv(y, chunkSize)}
+	x -= int(pos.x) * chunkSize
+	y -= int(pos.y) * chunkSize
+	return l.chunks[pos]*chunkCells + y*chunkSize + x
+}
+
+// allocate gives pos a slot if it doesn't have one, growing l.slots when
+// there are none free. It reports whether the cell buffers need to grow.
+func (l *SparseLife) allocate(pos chunkPos) bool {
+	if _, ok := l.chunks[pos]; ok {
+		return false
+	}
+	if n := len(l.free); n > 0 {
+		l.chunks[pos] = l.free[n-1]
+		l.slots[l.free[n-1]] = pos
+		l.free = l.free[:n-1]
+		return false
+	}
+	l.chunks[pos] = len(l.slots)
+	l.slots = append(l.slots, pos)
+	return true
+}
+
+// setCells replaces the cell buffers with room for capacity chunks, holding
+// cells for the slots that are already there.
+func (l *SparseLife) setCells(s *State, capacity int, cells []uint32) {
+	l.releaseCells()
+	data := make([]uint32, capacity*chunkCells)
+	copy(data, cells)
+	l.cellStateStorage = []*wgpu.Buffer{
+		s.storageBuffer(wgpu.ToBytes(data)),
+		s.storageBuffer(wgpu.ToBytes(data)),
+	}
+	l.chunkBuffer = s.storageBuffer(l.chunkBytes(capacity))
+	l.flags = s.storageBuffer(make([]byte, capacity*4))
+	l.gridBindGroups = []*wgpu.BindGroup{
+		s.bindGroup("sparse life A", l.bindGroupLayout, l.view, l.chunkBuffer, l.cellStateStorage[0], l.cellStateStorage[1], l.flags, s.paletteBuffer),
+		s.bindGroup("sparse life B", l.bindGroupLayout, l.view, l.chunkBuffer, l.cellStateStorage[1], l.cellStateStorage[0], l.flags, s.paletteBuffer),
+	}
+}
+
+func (l *SparseLife) capacity() int {
+	return int(l.flags.GetSize() / 4)
+}
+
+func (l *SparseLife) chunkBytes(capacity int) []byte {
+	words := make([]uint32, capacity*chunkWords)
+	for pos, slot := range l.chunks {
+		w := words[slot*chunkWords:][:chunkWords]
+		w[0], w[1], w[2] = uint32(pos.x), uint32(pos.y), 1
+		for i := range w[3:] {
+			w[3+i] = noChunk
+		}
+		for dy := int32(-1); dy <= 1; dy++ {
+			for dx := int32(-1); dx <= 1; dx++ {
+				if n, ok := l.chunks[chunkPos{pos.x + dx, pos.y + dy}]; ok {
+					w[3+(dy+1)*3+dx+1] = uint32(n)
+				}
+			}
+		}
+	}
+	return wgpu.ToBytes(words)
+}
+
+// manage reads back the flags the chunks have been setting since it last
+// ran, drops the chunks that have emptied and adds the ones that live cells
+// are getting close to.
+func (l *SparseLife) manage() error {
+	s := l.state
+	data, err := s.readBuffer(l.flags)
+	if err != nil {
+		return err
+	}
+	flags := wgpu.FromBytes[uint32](data)
+	if err := s.queue.WriteBuffer(l.flags, 0, make([]byte, len(data))); err != nil {
+		return err
+	}
+
+	needed := map[chunkPos]bool{}
+	for pos, slot := range l.chunks {
+		f := flags[slot]
+		for dy := int32(-1); dy <= 1; dy++ {
+			for dx := int32(-1); dx <= 1; dx++ {
+				if f&(1<<((dy+1)*3+dx+1)) != 0 {
+					needed[chunkPos{pos.x + dx, pos.y + dy}] = true
+				}
+			}
+		}
+	}
+
+	// A chunk that isn't needed has had no live cells since the last time,
+	// so its slot is left dead for whichever chunk gets it next.
+	changed := false
+	for pos, slot := range l.chunks {
+		if needed[pos] {
+			continue
+		}
+		delete(l.chunks, pos)
+		l.free = append(l.free, slot)
+		changed = true
+	}
+	grow := false
+	for pos := range needed {
+		if _, ok := l.chunks[pos]; !ok {
+			grow = l.allocate(pos) || grow
+			changed = true
+		}
+	}
+
+	if grow && len(l.slots) > l.capacity() {
+		capacity := l.capacity()
+		for capacity < len(l.slots) {
+			capacity *= 2
+		}
+		limit := s.device.GetLimits().Limits.MaxStorageBufferBindingSize
+		if uint64(capacity*chunkCells*4) > limit {
+			return fmt.Errorf("%d chunks don't fit in a storage buffer of %s bytes",
+				len(l.slots), s.format.Count(int64(limit)))
+		}
+		data, err := s.readBuffer(l.cellStateStorage[l.steps%2])
+		if err != nil {
+			return err
+		}
+		l.setCells(s, capacity, wgpu.FromBytes[uint32](data))
+		return nil
+	}
+	if !changed {
+		return nil
+	}
+	return s.queue.WriteBuffer(l.chunkBuffer, 0, l.chunkBytes(l.capacity()))
+}
+
+func (l *SparseLife) viewBytes() []byte {
+	w, h := float32(l.width), float32(l.height)
+	return wgpu.ToBytes([]float32{l.centreX - w/2, l.centreY - h/2, w, h})
+}
+
+// ResizeGrid changes how much of the world is in view. The world itself has
+// no edges, so there is nothing to crop or stretch.
+func (l *SparseLife) ResizeGrid(s *State, r gridRemap) error {
+	l.width, l.height = r.width, r.height
+	return s.queue.WriteBuffer(l.view, 0, l.viewBytes())
+}
+
+func (l *SparseLife) gridLimit() int {
+	return maxSparseView
+}
+
+func (l *SparseLife) Population(s *State) (float64, error) {
+	return liveCells(s, l.cellStateStorage[l.steps%2])
+}
+
+func (l *SparseLife) Step(encoder *commandEncoder) {
+	// The flags are only there once the chunks have been stepped.
+	if l.steps > 0 && l.steps%manageEvery == 0 {
+		if err := l.manage(); err != nil {
+			log.Println("sparse life:", err)
+		}
+	}
+
+	computePass := encoder.BeginComputePass(nil)
+	defer computePass.Release()
+
+	computePass.SetPipeline(l.simulationPipeline)
+	computePass.SetBindGroup(0, l.gridBindGroups[l.steps%2], nil)
+	computePass.DispatchWorkgroups(chunkSize/8, chunkSize/8, uint32(l.capacity()))
+	computePass.End()
+
+	l.steps += 1
+}
+
+func (l *SparseLife) Draw(pass *renderPass) {
+	pass.SetPipeline(l.pipeline)
+	pass.SetBindGroup(0, l.gridBindGroups[l.steps%2], nil)
+	pass.SetVertexBuffer(0, l.vertices, 0, wgpu.WholeSize)
+	pass.Draw(6, uint32(l.capacity()*chunkCells), 0, 0)
+}
+
+// HandleKey pans the view a quarter of the way across with the arrow keys.
+func (l *SparseLife) HandleKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if action == glfw.Release {
+		return
+	}
+	dx, dy := float32(l.width)/4, float32(l.height)/4
+	switch key {
+	case glfw.KeyLeft:
+		l.centreX -= dx
+	case glfw.KeyRight:
+		l.centreX += dx
+	case glfw.KeyDown:
+		l.centreY -= dy
+	case glfw.KeyUp:
+		l.centreY += dy
+	default:
+		return
+	}
+	if err := l.state.queue.WriteBuffer(l.view, 0, l.viewBytes()); err != nil {
+		panic(err)
+	}
+}
+
+func (l *SparseLife) releaseCells() {
+	for _, bg := range l.gridBindGroups {
+		bg.Release()
+	}
+	l.gridBindGroups = nil
+	for _, b := range l.cellStateStorage {
+		b.Release()
+	}
+	l.cellStateStorage = nil
+	if l.chunkBuffer != nil {
+		l.chunkBuffer.Release()
+		l.chunkBuffer = nil
+	}
+	if l.flags != nil {
+		l.flags.Release()
+		l.flags = nil
+	}
+}
+
+func (l *SparseLife) Release() {
+	l.releaseCells()
+	if l.view != nil {
+		l.view.Release()
+		l.view = nil
+	}
+	if l.simulationPipeline != nil {
+		l.simulationPipeline.Release()
+		l.simulationPipeline = nil
+	}
+	if l.pipeline != nil {
+		l.pipeline.Release()
+		l.pipeline = nil
+	}
+	if l.pipelineLayout != nil {
+		l.pipelineLayout.Release()
+		l.pipelineLayout = nil
+	}
+	if l.bindGroupLayout != nil {
+		l.bindGroupLayout.Release()
+		l.bindGroupLayout = nil
+	}
+}
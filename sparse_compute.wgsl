@@ -0,0 +1,102 @@
+struct View {
+  origin: vec2<f32>,
+  size: vec2<f32>,
+};
+
+struct Chunk {
+  x: i32,
+  y: i32,
+  used: u32,
+  // Slots of the chunks around this one, indexed by (dy + 1) * 3 + dx + 1
+  // with the chunk's own slot in the middle, or noChunk where there is none.
+  neighbours: array<u32, 9>,
+};
+
+const chunkSize = 64;
+const chunkCells = 4096u;
+const noChunk = 0xffffffffu;
+// Cells this close to an edge flag the chunk on the other side as needed.
+// It matches how often the flags are read back.
+const margin = 8;
+
+@group(0) @binding(0) var<uniform> view: View;
+@group(0) @binding(1) var<storage> chunks: array<Chunk>;
+@group(0) @binding(2) var<storage> cellStateIn: array<u32>;
+@group(0) @binding(3) var<storage, read_write> cellStateOut: array<u32>;
+// One word per slot with a bit for each direction in which a live cell is
+// near the edge, the middle bit meaning there was any live cell at all.
+@group(0) @binding(4) var<storage, read_write> flags: array<atomic<u32>>;
+
+var<workgroup> groupFlags: atomic<u32>;
+
+@compute
+@workgroup_size(8, 8)
+fn main(@builtin(global_invocation_id) id: vec3<u32>, @builtin(local_invocation_index) local: u32) {
+      let slot = id.z;
+      var f = 0u;
+      if chunks[slot].used != 0u {
+        let cell = vec2<i32>(id.xy);
+        let activeNeighbors = cellActive(slot, cell + vec2(1, 1)) +
+                              cellActive(slot, cell + vec2(1, 0)) +
+                              cellActive(slot, cell + vec2(1, -1)) +
+                              cellActive(slot, cell + vec2(0, -1)) +
+                              cellActive(slot, cell + vec2(-1, -1)) +
+                              cellActive(slot, cell + vec2(-1, 0)) +
+                              cellActive(slot, cell + vec2(-1, 1)) +
+                              cellActive(slot, cell + vec2(0, 1));
+
+        let i = slot * chunkCells + u32(cell.y * chunkSize + cell.x);
+        var next = 0u;
+        // Conway's game of life rules:
+        switch activeNeighbors {
+          case 2u: {
+            next = cellStateIn[i];
+          }
+          case 3u: {
+            next = 1u;
+          }
+          default: {
+          }
+        }
+        cellStateOut[i] = next;
+        if next != 0u {
+          f = nearEdges(cell);
+        }
+      }
+
+      // Collect the flags for the workgroup first so that there is only one
+      // atomic per workgroup on the slot's word.
+      atomicOr(&groupFlags, f);
+      workgroupBarrier();
+      if local == 0u {
+        let g = atomicLoad(&groupFlags);
+        if g != 0u {
+          atomicOr(&flags[slot], g);
+        }
+      }
+}
+
+fn direction(d: vec2<i32>) -> u32 {
+  return 1u << u32((d.y + 1) * 3 + d.x + 1);
+}
+
+// outside returns which way a cell is past the edges of its chunk.
+fn outside(cell: vec2<i32>, inset: i32) -> vec2<i32> {
+  return select(vec2(0), vec2(-1), cell < vec2(inset)) +
+         select(vec2(0), vec2(1), cell >= vec2(chunkSize - inset));
+}
+
+fn nearEdges(cell: vec2<i32>) -> u32 {
+  let d = outside(cell, margin);
+  return direction(vec2(0, 0)) | direction(vec2(d.x, 0)) | direction(vec2(0, d.y)) | direction(d);
+}
+
+fn cellActive(slot: u32, cell: vec2<i32>) -> u32 {
+  let d = outside(cell, 0);
+  let n = chunks[slot].neighbours[(d.y + 1) * 3 + d.x + 1];
+  if n == noChunk {
+    return 0u;
+  }
+  let c = cell - d * chunkSize;
+  return cellStateIn[n * chunkCells + u32(c.y * chunkSize + c.x)];
+}
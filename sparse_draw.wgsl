@@ -0,0 +1,56 @@
+// palette.wgsl goes after this.
+struct VertexInput {
+  @location(0) pos: vec2<f32>,
+  @builtin(instance_index) instance: u32,
+};
+
+struct VertexOutput {
+  @builtin(position) pos: vec4<f32>,
+  @location(0) cell: vec2<f32>,
+  // local runs from -1 to 1 across the cell's quad.
+  @location(1) local: vec2<f32>,
+};
+
+struct View {
+  origin: vec2<f32>,
+  size: vec2<f32>,
+};
+
+struct Chunk {
+  x: i32,
+  y: i32,
+  used: u32,
+  neighbours: array<u32, 9>,
+};
+
+const chunkSize = 64;
+const chunkCells = 4096u;
+
+@group(0) @binding(0) var<uniform> view: View;
+@group(0) @binding(1) var<storage> chunks: array<Chunk>;
+@group(0) @binding(2) var<storage> cellStateIn: array<u32>;
+@group(0) @binding(5) var<uniform> palette: Palette;
+
+// There is an instance for every cell of every slot. Free slots are all
+// dead, so they come out as empty quads like any other dead cell.
+@vertex
+fn main_vs(input: VertexInput) -> VertexOutput {
+    let chunk = chunks[input.instance / chunkCells];
+    let i = i32(input.instance % chunkCells);
+    let state = f32(cellStateIn[input.instance]);
+
+    let cell = vec2<f32>(vec2(chunk.x, chunk.y) * chunkSize + vec2(i % chunkSize, i / chunkSize)) - view.origin;
+    let gridPos = (state*input.pos + 1.0 + 2.0*cell) / view.size - 1.0;
+
+    var output: VertexOutput;
+    output.pos = vec4<f32>(gridPos, 0.0, 1.0);
+    output.local = input.pos / 0.8;
+    output.cell = cell;
+    return output;
+}
+
+@fragment
+fn main_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+    let c = clamp(input.cell / view.size, vec2(0.0), vec2(1.0));
+    return shapeColour(palette, paletteColour(palette, c), cellShape(palette, input.local, fwidth(input.local)));
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// The tick rate is how many generations a second the simulation runs,
+// whatever rate the display refreshes at. A rate of 0 runs as many as the
+// GPU keeps up with.
+const (
+	defaultTickRate = 10
+	minTickRate     = 1
+	maxTickRate     = 960
+	// maxTicksPerFrame is the most ticks one frame runs. A rate the GPU
+	// can't keep up with slows the simulation down, not the frames.
+	maxTicksPerFrame = 64
+)
+
+// tickRates are the rates + and - move between, before as fast as it can.
+var tickRates = []float64{1, 2, 5, 10, 20, 30, 60, 120, 240, 480, 960}
+
+// ticker works out how many ticks each frame is owed from the time since
+// the one before.
+type ticker struct {
+	last time.Time
+	// owed is the part of a tick carried over to the next frame.
+	owed float64
+	// fast is how many ticks a frame runs at a rate of 0. It grows while
+	// frames keep up with the display and shrinks when they fall behind.
+	fast int
+}
+
+// due is how many ticks the frame starting now runs.
+func (t *ticker) due(s *State) int {
+	now := time.Now()
+	elapsed := now.Sub(t.last)
+	if t.last.IsZero() {
+		elapsed = 0
+	}
+	t.last = now
+	if s.paused {
+		t.owed = 0
+		return 0
+	}
+	rate := s.tickRate
+	if s.throttled() && (rate == 0 || rate > backgroundTickRate) {
+		rate = backgroundTickRate
+	}
+	if rate == 0 {
+		if elapsed <= s.framePeriod()*5/4 {
+			t.fast = min(t.fast+1, maxTicksPerFrame)
+		} else {
+			t.fast = max(t.fast*3/4, 1)
+		}
+		return t.fast
+	}
+	t.owed += elapsed.Seconds() * rate
+	n := int(t.owed)
+	t.owed -= float64(n)
+	if n > maxTicksPerFrame {
+		n, t.owed = maxTicksPerFrame, 0
+	}
+	return n
+}
+
+// refreshInterval is how often the display the window started on
+// refreshes, or a 60 Hz display's when that isn't known.
+func (s *State) refreshInterval() time.Duration {
+	if s.input != nil && s.input.refresh > 0 {
+		return s.input.refresh
+	}
+	return time.Second / 60
+}
+
+// frameInterval is the least time a frame takes from the start of the one
+// before: a -max-fps frame's, or longer while the window is in the
+// background, or 0 to leave it to presenting, which waits for the display.
+func (s *State) frameInterval() time.Duration {
+	var interval time.Duration
+	if s.cfg.MaxFPS > 0 {
+		interval = time.Duration(float64(time.Second) / s.cfg.MaxFPS)
+	}
+	if s.inBackground() {
+		interval = max(interval, time.Second/backgroundFrameRate)
+	}
+	return interval
+}
+
+// framePeriod is how far apart frames come when nothing holds them up.
+func (s *State) framePeriod() time.Duration {
+	return max(s.refreshInterval(), s.frameInterval())
+}
+
+// waitEvents handles the window's events until the next frame is due. A
+// frame is due a frameInterval after the last was, or straight away if that
+// has passed. The ticker goes by the time that has gone by, so however far
+// apart frames are the simulation keeps to its tick rate.
+func (s *State) waitEvents() {
+	glfw.PollEvents()
+	interval := s.frameInterval()
+	if interval == 0 {
+		return
+	}
+	for wait := time.Until(s.nextFrame); wait > 0; wait = time.Until(s.nextFrame) {
+		glfw.WaitEventsTimeout(wait.Seconds())
+	}
+	if now := time.Now(); s.nextFrame.Before(now) {
+		s.nextFrame = now
+	}
+	s.nextFrame = s.nextFrame.Add(interval)
+}
+
+// setTickRate runs the simulation at rate, 0 being as fast as it can.
+func (s *State) setTickRate(rate float64) {
+	s.tickRate = rate
+	s.ticker.owed, s.ticker.fast = 0, 1
+	if rate == 0 {
+		fmt.Println("running as fast as it can")
+	} else {
+		fmt.Printf("running at %s generations a second\n", s.format.Float(rate, 0))
+	}
+	s.emit(actionSpeed)
+}
+
+// handleSpeedKey runs the simulation faster with + and slower with -,
+// through tickRates and then as fast as it can. = does what + does, so that
+// Shift isn't needed, and the keypad's keys do it too; with Ctrl the main
+// keys are life's trails.
+func (s *State) handleSpeedKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if action == glfw.Release {
+		return
+	}
+	main := mods&glfw.ModControl == 0
+	switch {
+	case key == glfw.KeyKPAdd || key == glfw.KeyEqual && main:
+		if s.tickRate == 0 {
+			return
+		}
+		for _, r := range tickRates {
+			if r > s.tickRate {
+				s.setTickRate(r)
+				return
+			}
+		}
+		s.setTickRate(0)
+	case key == glfw.KeyKPSubtract || key == glfw.KeyMinus && main:
+		if s.tickRate == 0 {
+			s.setTickRate(tickRates[len(tickRates)-1])
+			return
+		}
+		for i := len(tickRates) - 1; i >= 0; i-- {
+			if tickRates[i] < s.tickRate {
+				s.setTickRate(tickRates[i])
+				return
+			}
+		}
+	}
+}
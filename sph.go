@@ -0,0 +1,256 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed sph_compute.wgsl
+var sphCompute string
+
+//go:embed sph_draw.wgsl
+var sphDraw string
+
+// sphMouseForce is how hard the mouse pulls or pushes the fluid.
+const sphMouseForce = 60
+
+// SPH is a 2D fluid of particles, starting as a dam of water breaking
+// across the floor of a box. Particles are binned each step to find their
+// neighbours, then take their density from them and feel their pressure
+// and viscosity; see sph_compute.wgsl. The left mouse button pulls the
+// fluid towards the cursor and the right one pushes it away.
+type SPH struct {
+	computeLayout         *wgpu.BindGroupLayout
+	drawLayout            *wgpu.BindGroupLayout
+	computePipelineLayout *wgpu.PipelineLayout
+	drawPipelineLayout    *wgpu.PipelineLayout
+	pipeline              *wgpu.RenderPipeline
+	binning               *binning
+	densities, step       *wgpu.ComputePipeline
+
+	queue       *uploadQueue
+	params      []uint32
+	paramBuffer *wgpu.Buffer
+	particles   []*wgpu.Buffer
+	density     *wgpu.Buffer
+	computeSets []*wgpu.BindGroup
+	drawSets    []*wgpu.BindGroup
+	vertices    *wgpu.Buffer
+	count       int
+	steps       int
+	// stepsPerFrame is how many steps each Step takes.
+	stepsPerFrame int
+}
+
+func newSPH(s *State, cfg *Config) (sim Simulation, err error) {
+	p := cfg.SPH
+	if p.Count < 16 || p.Count > 1<<16 {
+		return nil, fmt.Errorf("SPH count %d out of range [16, %d]", p.Count, 1<<16)
+	}
+	if p.StepsPerFrame < 1 {
+		return nil, fmt.Errorf("SPH needs at least one step per frame, got %d", p.StepsPerFrame)
+	}
+	if p.DT <= 0 || p.Stiffness <= 0 || p.Viscosity < 0 {
+		return nil, fmt.Errorf("SPH time step and stiffness must be positive and viscosity not negative, got %v, %v and %v", p.DT, p.Stiffness, p.Viscosity)
+	}
+
+	h := &SPH{
+		queue:         s.queue,
+		vertices:      s.vertexBuffer,
+		count:         p.Count,
+		stepsPerFrame: p.StepsPerFrame,
+	}
+	defer func() {
+		if err != nil {
+			h.Release()
+		}
+	}()
+
+	drawShader := s.createShader("SPH render shader", sphDraw)
+	defer drawShader.Release()
+
+	computeShader := s.createShader("SPH compute shader", sphCompute+binningShader)
+	defer computeShader.Release()
+
+	h.computeLayout, err = s.bindGroupLayout("SPH compute", append([]wgpu.BindGroupLayoutEntry{
+		bufferEntry(0, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(2, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(3, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+	}, binningEntries()...)...)
+	if err != nil {
+		return nil, err
+	}
+	h.drawLayout, err = s.bindGroupLayout("SPH render",
+		bufferEntry(0, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(2, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(3, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(4, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_ReadOnlyStorage),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	h.computePipelineLayout, err = s.pipelineLayout("SPH compute", h.computeLayout)
+	if err != nil {
+		return nil, err
+	}
+	h.drawPipelineLayout, err = s.pipelineLayout("SPH render", h.drawLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	h.pipeline, err = s.renderPipeline("SPH render", h.drawPipelineLayout, drawShader, "main_vs", "main_fs")
+	if err != nil {
+		return nil, err
+	}
+
+	// The dam covers 1.6 of the box's area of 4, split evenly between the
+	// particles, and each reaches just past its nearest neighbours.
+	spacing := math.Sqrt(1.6 / float64(h.count))
+	radius := 2 * spacing
+	bins := int(2 / radius)
+	h.binning, err = newBinning(s, "SPH", h.computePipelineLayout, computeShader, bins*bins, h.count)
+	if err != nil {
+		return nil, err
+	}
+	h.densities, err = s.computePipeline("SPH densities", h.computePipelineLayout, computeShader, "densities")
+	if err != nil {
+		return nil, err
+	}
+	h.step, err = s.computePipeline("SPH step", h.computePipelineLayout, computeShader, "step")
+	if err != nil {
+		return nil, err
+	}
+
+	const restDensity = 1
+	h.params = []uint32{
+		uint32(h.count), uint32(bins), math.Float32bits(float32(radius)), math.Float32bits(float32(restDensity * spacing * spacing)),
+		math.Float32bits(restDensity), math.Float32bits(p.Stiffness), math.Float32bits(p.Viscosity), math.Float32bits(p.DT),
+		math.Float32bits(p.Gravity), 0, 0, 0,
+	}
+	h.paramBuffer = s.uniformBuffer("SPH params", wgpu.ToBytes(h.params))
+
+	// The dam is 1 wide and 1.6 high in the bottom left corner, jittered
+	// so that it doesn't fall as a lattice.
+	columns := int(math.Ceil(1 / spacing))
+	particles := make([]float32, 0, 4*h.count)
+	for i := 0; i < h.count; i++ {
+		x := -1 + (float64(i%columns)+0.5+0.1*s.rand.Float64())*spacing
+		y := -1 + (float64(i/columns)+0.5+0.1*s.rand.Float64())*spacing
+		particles = append(particles, float32(x), float32(y), 0, 0)
+	}
+	h.particles = []*wgpu.Buffer{
+		s.storageBuffer(wgpu.ToBytes(particles)),
+		s.storageBuffer(wgpu.ToBytes(particles)),
+	}
+	h.density = s.storageBuffer(make([]byte, h.count*4))
+
+	b := h.binning.buffers()
+	for i := range h.particles {
+		in, out := h.particles[i], h.particles[1-i]
+		h.computeSets = append(h.computeSets, s.bindGroup("SPH compute", h.computeLayout,
+			append([]*wgpu.Buffer{h.paramBuffer, in, out, h.density}, b...)...))
+		h.drawSets = append(h.drawSets, s.bindGroup("SPH render", h.drawLayout,
+			h.paramBuffer, in, b[0], b[1], b[3]))
+	}
+	return h, nil
+}
+
+// HandleMouse pulls the fluid towards the cursor while the left button is
+// held and pushes it away while the right one is.
+func (h *SPH) HandleMouse(x, y float32, left, right bool) {
+	force := float32(0)
+	if left {
+		force += sphMouseForce
+	}
+	if right {
+		force -= sphMouseForce
+	}
+	h.params[9] = math.Float32bits(force)
+	h.params[10] = math.Float32bits(x)
+	h.params[11] = math.Float32bits(y)
+	h.queue.WriteBuffer(h.paramBuffer, 0, wgpu.ToBytes(h.params))
+}
+
+func (h *SPH) Step(encoder *commandEncoder) {
+	computePass := encoder.BeginComputePass(nil)
+	defer computePass.Release()
+
+	particles := uint32(h.count+63) / 64
+	for i := 0; i < h.stepsPerFrame; i++ {
+		computePass.SetBindGroup(0, h.computeSets[h.steps%2], nil)
+		h.binning.record(computePass)
+		computePass.SetPipeline(h.densities)
+		computePass.DispatchWorkgroups(particles, 1, 1)
+		computePass.SetPipeline(h.step)
+		computePass.DispatchWorkgroups(particles, 1, 1)
+		h.steps += 1
+	}
+	computePass.End()
+}
+
+func (h *SPH) Draw(pass *renderPass) {
+	pass.SetPipeline(h.pipeline)
+	// The bins were last filled from the particles before the last step.
+	pass.SetBindGroup(0, h.drawSets[(h.steps+1)%2], nil)
+	pass.SetVertexBuffer(0, h.vertices, 0, wgpu.WholeSize)
+	pass.Draw(6, 1, 0, 0)
+}
+
+func (h *SPH) Release() {
+	for _, bg := range h.computeSets {
+		bg.Release()
+	}
+	h.computeSets = nil
+	for _, bg := range h.drawSets {
+		bg.Release()
+	}
+	h.drawSets = nil
+	for _, b := range h.particles {
+		b.Release()
+	}
+	h.particles = nil
+	for _, b := range []*wgpu.Buffer{h.density, h.paramBuffer} {
+		if b != nil {
+			b.Release()
+		}
+	}
+	h.density, h.paramBuffer = nil, nil
+	if h.binning != nil {
+		h.binning.Release()
+		h.binning = nil
+	}
+	if h.densities != nil {
+		h.densities.Release()
+		h.densities = nil
+	}
+	if h.step != nil {
+		h.step.Release()
+		h.step = nil
+	}
+	if h.pipeline != nil {
+		h.pipeline.Release()
+		h.pipeline = nil
+	}
+	if h.drawPipelineLayout != nil {
+		h.drawPipelineLayout.Release()
+		h.drawPipelineLayout = nil
+	}
+	if h.computePipelineLayout != nil {
+		h.computePipelineLayout.Release()
+		h.computePipelineLayout = nil
+	}
+	if h.drawLayout != nil {
+		h.drawLayout.Release()
+		h.drawLayout = nil
+	}
+	if h.computeLayout != nil {
+		h.computeLayout.Release()
+		h.computeLayout = nil
+	}
+}
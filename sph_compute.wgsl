@@ -0,0 +1,147 @@
+// Smoothed-particle hydrodynamics in the square [-1, 1]: each particle's
+// density is the sum of its neighbours' masses within the smoothing length
+// h, spread by a kernel, and its pressure pushes on the neighbours too
+// crowded around it. Neighbours are found in the bins of side at least h
+// around a particle, so only those 3x3 bins are searched.
+// binning.wgsl goes after this.
+struct Params {
+  count: u32,
+  bins: u32,
+  h: f32,
+  mass: f32,
+  restDensity: f32,
+  stiffness: f32,
+  viscosity: f32,
+  dt: f32,
+  gravity: f32,
+  // mouseForce pulls particles towards mouse while the button is held, and
+  // is 0 otherwise.
+  mouseForce: f32,
+  mouse: vec2<f32>,
+};
+
+@group(0) @binding(0) var<uniform> params: Params;
+// Particles are a position then a velocity.
+@group(0) @binding(1) var<storage> particleIn: array<vec4<f32>>;
+@group(0) @binding(2) var<storage, read_write> particleOut: array<vec4<f32>>;
+@group(0) @binding(3) var<storage, read_write> density: array<f32>;
+
+const pi = 3.14159265;
+
+fn binOf(p: vec2<f32>) -> vec2<i32> {
+  let n = i32(params.bins);
+  return clamp(vec2<i32>(floor((p + 1.0) * 0.5 * f32(n))), vec2(0), vec2(n - 1));
+}
+
+fn binTotal() -> u32 {
+  return params.bins * params.bins;
+}
+
+fn bodyCount() -> u32 {
+  return params.count;
+}
+
+fn binOfBody(i: u32) -> u32 {
+  let b = binOf(particleIn[i].xy);
+  return u32(b.y) * params.bins + u32(b.x);
+}
+
+fn poly6(r2: f32) -> f32 {
+  let h2 = params.h * params.h;
+  let d = h2 - r2;
+  return 4.0 / (pi * pow(params.h, 8.0)) * d * d * d;
+}
+
+fn pressure(rho: f32) -> f32 {
+  // Clamped at 0, as particles pulling on each other clump up.
+  return max(params.stiffness * (rho - params.restDensity), 0.0);
+}
+
+@compute
+@workgroup_size(64)
+fn densities(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x >= params.count {
+    return;
+  }
+  let p = particleIn[id.x].xy;
+  let own = binOf(p);
+  let n = i32(params.bins);
+  var rho = 0.0;
+  for (var y = max(own.y - 1, 0); y <= min(own.y + 1, n - 1); y += 1) {
+    for (var x = max(own.x - 1, 0); x <= min(own.x + 1, n - 1); x += 1) {
+      let b = u32(y * n + x);
+      let start = binStart[b];
+      let end = start + atomicLoad(&binCount[b]);
+      for (var k = start; k < end; k += 1u) {
+        let d = particleIn[sorted[k]].xy - p;
+        let r2 = dot(d, d);
+        if r2 < params.h * params.h {
+          rho += params.mass * poly6(r2);
+        }
+      }
+    }
+  }
+  density[id.x] = rho;
+}
+
+// step moves the particles on by the pressure, viscosity, gravity and mouse
+// forces, bouncing them off the walls.
+@compute
+@workgroup_size(64)
+fn step(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x >= params.count {
+    return;
+  }
+  let h = params.h;
+  let spiky = -30.0 / (pi * pow(h, 5.0));
+  let laplacian = 40.0 / (pi * pow(h, 5.0));
+
+  let p = particleIn[id.x].xy;
+  var v = particleIn[id.x].zw;
+  let rho = density[id.x];
+  let ownPressure = pressure(rho);
+  let own = binOf(p);
+  let n = i32(params.bins);
+  var force = vec2(0.0);
+  for (var y = max(own.y - 1, 0); y <= min(own.y + 1, n - 1); y += 1) {
+    for (var x = max(own.x - 1, 0); x <= min(own.x + 1, n - 1); x += 1) {
+      let b = u32(y * n + x);
+      let start = binStart[b];
+      let end = start + atomicLoad(&binCount[b]);
+      for (var k = start; k < end; k += 1u) {
+        let j = sorted[k];
+        let other = particleIn[j];
+        let d = p - other.xy;
+        let r = length(d);
+        if j == id.x || r >= h || r < 1e-6 {
+          continue;
+        }
+        let rhoJ = density[j];
+        let dir = d / r;
+        force -= dir * params.mass * (ownPressure + pressure(rhoJ)) / (2.0 * rhoJ) * spiky * (h - r) * (h - r);
+        force += params.viscosity * params.mass * (other.zw - v) / rhoJ * laplacian * (h - r);
+      }
+    }
+  }
+
+  var a = force / max(rho, 1e-6) + vec2(0.0, -params.gravity);
+  let toMouse = params.mouse - p;
+  let reach = 0.25;
+  if params.mouseForce != 0.0 && length(toMouse) < reach {
+    a += toMouse * params.mouseForce * (1.0 - length(toMouse) / reach);
+  }
+  v += a * params.dt;
+  var q = p + v * params.dt;
+
+  // Walls take half the speed off particles bouncing off them.
+  let wall = 1.0 - 1e-3;
+  if abs(q.x) > wall {
+    q.x = clamp(q.x, -wall, wall);
+    v.x *= -0.5;
+  }
+  if abs(q.y) > wall {
+    q.y = clamp(q.y, -wall, wall);
+    v.y *= -0.5;
+  }
+  particleOut[id.x] = vec4(q, v);
+}
@@ -0,0 +1,69 @@
+struct Params {
+  count: u32,
+  bins: u32,
+  h: f32,
+  mass: f32,
+  restDensity: f32,
+  stiffness: f32,
+  viscosity: f32,
+  dt: f32,
+  gravity: f32,
+  mouseForce: f32,
+  mouse: vec2<f32>,
+};
+
+struct VertexOutput {
+  @builtin(position) pos: vec4<f32>,
+  @location(0) uv: vec2<f32>,
+};
+
+@group(0) @binding(0) var<uniform> params: Params;
+@group(0) @binding(1) var<storage> particles: array<vec4<f32>>;
+// The bins the particles were last sorted into; see binning.wgsl.
+@group(0) @binding(2) var<storage> binCount: array<u32>;
+@group(0) @binding(3) var<storage> binStart: array<u32>;
+@group(0) @binding(4) var<storage> sorted: array<u32>;
+
+// The cell tile is stretched over the whole window, which shows the whole
+// square.
+@vertex
+fn main_vs(@location(0) pos: vec2<f32>) -> VertexOutput {
+  var output: VertexOutput;
+  output.uv = pos / 0.8;
+  output.pos = vec4<f32>(output.uv, 0.0, 1.0);
+  return output;
+}
+
+// Every particle is a metaball of radius h, and the water is wherever
+// enough of them overlap. It whitens where it moves fast.
+@fragment
+fn main_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  let p = input.uv;
+  let n = i32(params.bins);
+  let own = clamp(vec2<i32>(floor((p + 1.0) * 0.5 * f32(n))), vec2(0), vec2(n - 1));
+  let h2 = params.h * params.h;
+  var field = 0.0;
+  var speed = 0.0;
+  for (var y = max(own.y - 1, 0); y <= min(own.y + 1, n - 1); y += 1) {
+    for (var x = max(own.x - 1, 0); x <= min(own.x + 1, n - 1); x += 1) {
+      let b = u32(y * n + x);
+      let start = binStart[b];
+      for (var k = start; k < start + binCount[b]; k += 1u) {
+        let particle = particles[sorted[k]];
+        let d = particle.xy - p;
+        let f = max(1.0 - dot(d, d) / h2, 0.0);
+        let w = f * f * f;
+        field += w;
+        speed += w * length(particle.zw);
+      }
+    }
+  }
+  let background = vec4(0.02, 0.02, 0.05, 1.0);
+  if field < 0.5 {
+    return background;
+  }
+  let depth = clamp((field - 0.5) / 3.0, 0.0, 1.0);
+  let water = mix(vec3(0.3, 0.6, 0.9), vec3(0.05, 0.2, 0.6), depth);
+  let foam = clamp(speed / field * 0.5 - 0.3, 0.0, 1.0);
+  return vec4(mix(water, vec3(0.9, 0.95, 1.0), foam), 1.0);
+}
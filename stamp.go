@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// stamper stamps patterns onto life at the cursor with Q: the built-in ones
+// and those in the patterns directory, which W and Shift+W pick between.
+// Shift+Q turns the pattern a quarter clockwise and Ctrl+Q mirrors it, for
+// every stamp after. Ctrl+V stamps the clipboard: RLE text from the system
+// clipboard, or what was copied here.
+type stamper struct {
+	library []*pattern
+	current int
+	turns   int
+	flipped bool
+}
+
+func newStamper(cfg *Config) (*stamper, error) {
+	library, err := loadPatterns(cfg.Patterns)
+	if err != nil {
+		return nil, err
+	}
+	return &stamper{library: library}, nil
+}
+
+// pattern is the current pattern, turned and flipped.
+func (st *stamper) pattern() *pattern {
+	p := st.library[st.current]
+	if st.flipped {
+		p = p.flipped()
+	}
+	for i := 0; i < st.turns; i++ {
+		p = p.rotated()
+	}
+	return p
+}
+
+// stamp writes p over the cells with its middle at x, y, leaving out any of
+// it past the grid's edges.
+func (s *State) stamp(e cellEditor, p *pattern, x, y int) error {
+	left, bottom := x-p.width/2, y-p.height/2
+	x0, y0 := max(left, 0), max(bottom, 0)
+	x1, y1 := min(left+p.width, s.gridWidth), min(bottom+p.height, s.gridHeight)
+	if x0 >= x1 || y0 >= y1 {
+		return nil
+	}
+	r := cellRect{x0, y0, x1 - x0, y1 - y0}
+	cells := make([]uint32, 0, r.w*r.h)
+	for cy := y0; cy < y1; cy++ {
+		for cx := x0; cx < x1; cx++ {
+			cells = append(cells, p.at(cx-left, cy-bottom))
+		}
+	}
+	before, err := e.readCells(s, r)
+	if err != nil {
+		return err
+	}
+	return s.edit(e, r, before, cells, false)
+}
+
+// pasted is what Ctrl+V stamps: the cells last copied while the system
+// clipboard still has them, and otherwise whatever RLE it has instead.
+func (s *State) pasted() *pattern {
+	var text string
+	if s.window != nil {
+		text = s.window.GetClipboardString()
+	}
+	sel := s.selection
+	if sel != nil && sel.clipboard != nil && (text == "" || text == sel.copied) {
+		return sel.clipboard
+	}
+	if strings.TrimSpace(text) == "" {
+		fmt.Println("nothing has been copied")
+		return nil
+	}
+	p, err := parseRLE("the clipboard", text)
+	if err != nil {
+		fmt.Println("pasting:", err)
+		return nil
+	}
+	return p
+}
+
+// handleStampKey stamps, picks and turns patterns.
+func (s *State) handleStampKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	st := s.stamper
+	if action != glfw.Press || st == nil {
+		return
+	}
+	shift := mods&glfw.ModShift != 0
+	control := mods&(glfw.ModControl|glfw.ModSuper) != 0
+	var p *pattern
+	switch {
+	case key == glfw.KeyW:
+		step := 1
+		if shift {
+			step = len(st.library) - 1
+		}
+		st.current = (st.current + step) % len(st.library)
+		fmt.Printf("stamping %s, %s\n", st.library[st.current].name, st.library[st.current].size())
+		return
+	case key == glfw.KeyQ && shift:
+		st.turns = (st.turns + 1) % 4
+		fmt.Printf("stamps turned %d degrees\n", st.turns*90)
+		return
+	case key == glfw.KeyQ && control:
+		st.flipped = !st.flipped
+		if st.flipped {
+			fmt.Println("stamps mirrored")
+		} else {
+			fmt.Println("stamps the right way round")
+		}
+		return
+	case key == glfw.KeyQ:
+		p = st.pattern()
+	case key == glfw.KeyV && control:
+		if p = s.pasted(); p == nil {
+			return
+		}
+	default:
+		return
+	}
+
+	e, ok := s.editor()
+	if !ok || s.window == nil {
+		return
+	}
+	x, y, ok := s.cursorCell(s.window, s.gridWidth, s.gridHeight)
+	if !ok {
+		fmt.Println("the cursor isn't over the grid")
+		return
+	}
+	if err := s.stamp(e, p, x, y); err != nil {
+		fmt.Println("stamping:", err)
+		return
+	}
+	s.emit(actionStamp)
+}
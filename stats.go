@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+// frameStats counts the GPU work recorded for one frame. Everything is
+// recorded through the wrappers below, which count as they go, so no
+// simulation has to report anything itself.
+type frameStats struct {
+	drawCalls int
+	// instances is how many instances the draw calls asked for.
+	instances  uint64
+	dispatches int
+	workgroups uint64
+	// uploadBytes is everything written into buffers from the CPU.
+	uploadBytes uint64
+}
+
+func (f *frameStats) describe(format numberFormat) string {
+	return fmt.Sprintf("%s draws, %s instances, %s dispatches (%s workgroups), %s bytes uploaded",
+		format.Count(int64(f.drawCalls)), format.Count(int64(f.instances)),
+		format.Count(int64(f.dispatches)), format.Count(int64(f.workgroups)), format.Count(int64(f.uploadBytes)))
+}
+
+// commandEncoder is a command encoder whose passes count into stats.
+type commandEncoder struct {
+	*wgpu.CommandEncoder
+	stats *frameStats
+}
+
+func (s *State) newEncoder() (*commandEncoder, error) {
+	encoder, err := s.device.CreateCommandEncoder(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &commandEncoder{encoder, s.stats}, nil
+}
+
+func (e *commandEncoder) BeginRenderPass(descriptor *wgpu.RenderPassDescriptor) *renderPass {
+	return &renderPass{e.CommandEncoder.BeginRenderPass(descriptor), e.stats}
+}
+
+func (e *commandEncoder) BeginComputePass(descriptor *wgpu.ComputePassDescriptor) *computePass {
+	return &computePass{e.CommandEncoder.BeginComputePass(descriptor), e.stats}
+}
+
+type renderPass struct {
+	*wgpu.RenderPassEncoder
+	stats *frameStats
+}
+
+func (p *renderPass) Draw(vertexCount, instanceCount, firstVertex, firstInstance uint32) {
+	p.stats.drawCalls++
+	p.stats.instances += uint64(instanceCount)
+	p.RenderPassEncoder.Draw(vertexCount, instanceCount, firstVertex, firstInstance)
+}
+
+// DrawIndirect counts the draw but not its instances, which only the GPU
+// knows.
+func (p *renderPass) DrawIndirect(buffer *wgpu.Buffer, offset uint64) {
+	p.stats.drawCalls++
+	p.RenderPassEncoder.DrawIndirect(buffer, offset)
+}
+
+type computePass struct {
+	*wgpu.ComputePassEncoder
+	stats *frameStats
+}
+
+func (p *computePass) DispatchWorkgroups(x, y, z uint32) {
+	p.stats.dispatches++
+	p.stats.workgroups += uint64(x) * uint64(y) * uint64(z)
+	p.ComputePassEncoder.DispatchWorkgroups(x, y, z)
+}
+
+// uploadQueue is the device's queue, counting the bytes written through it.
+type uploadQueue struct {
+	*wgpu.Queue
+	stats *frameStats
+}
+
+func (q *uploadQueue) WriteBuffer(buffer *wgpu.Buffer, offset uint64, data []byte) error {
+	q.stats.uploadBytes += uint64(len(data))
+	return q.Queue.WriteBuffer(buffer, offset, data)
+}
+
+func (q *uploadQueue) WriteTexture(destination *wgpu.ImageCopyTexture, data []byte, dataLayout *wgpu.TextureDataLayout, writeSize *wgpu.Extent3D) error {
+	q.stats.uploadBytes += uint64(len(data))
+	return q.Queue.WriteTexture(destination, data, dataLayout, writeSize)
+}
+
+// handleStatsKey shows the frame stats in the title bar with I, or hides
+// them again, and the frame time graph with Shift+I.
+func (s *State) handleStatsKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if key != glfw.KeyI || action != glfw.Press {
+		return
+	}
+	if mods&glfw.ModShift != 0 {
+		s.frameGraph.toggle()
+		return
+	}
+	s.showStats = !s.showStats
+	s.updateTitle()
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"image"
+	"log"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed still.wgsl
+var stillShader string
+
+// stillMode pauses the simulation and makes a clean still of it. Every frame
+// it draws the simulation once more at -still-scale times the window's size,
+// shifted by a different fraction of a pixel, and averages the frames
+// together, smoothing the edges of cells as samples pile up. Enter saves
+// the still at full size, and S or Esc goes back.
+type stillMode struct {
+	prev    Mode
+	samples int
+	scale   uint32
+	// depth is the bits a channel stills are saved with.
+	depth int
+
+	layout, showLayout                 *wgpu.BindGroupLayout
+	pipelineLayout, showPipelineLayout *wgpu.PipelineLayout
+	accumulate, show, resolve          *wgpu.RenderPipeline
+	params, showScale, resolveScale    *wgpu.Buffer
+
+	frame         *wgpu.Texture
+	frameView     *wgpu.TextureView
+	mean          [2]*wgpu.Texture
+	meanViews     [2]*wgpu.TextureView
+	sets          [2]*wgpu.BindGroup
+	showSets      [2]*wgpu.BindGroup
+	resolveSets   [2]*wgpu.BindGroup
+	width, height uint32
+	// current is which of mean has the frames so far in it.
+	current int
+	taken   int
+
+	state *State
+}
+
+// stillMargin is the margin around the image in the frame each sample is
+// drawn into, so that shifting the viewport keeps it on the target.
+const stillMargin = 1
+
+func (s *State) openStill() {
+	if _, ok := s.sim.(*multiSim); ok {
+		s.showPrompt("Stills need a single instance")
+		return
+	}
+	m, err := newStillMode(s, s.cfg.Still, s.cfg.PNGDepth)
+	if err != nil {
+		log.Println("making a still:", err)
+		return
+	}
+	m.prev = s.mode
+	s.setMode(m)
+}
+
+func newStillMode(s *State, cfg StillConfig, depth int) (m *stillMode, err error) {
+	if cfg.Samples < 1 {
+		return nil, fmt.Errorf("stills need at least one sample, got %d", cfg.Samples)
+	}
+	if cfg.Scale < 1 || cfg.Scale > 8 {
+		return nil, fmt.Errorf("still scale %d out of range [1, 8]", cfg.Scale)
+	}
+	m = &stillMode{state: s, samples: cfg.Samples, scale: uint32(cfg.Scale), depth: depth}
+	defer func() {
+		if err != nil {
+			m.Release()
+		}
+	}()
+
+	shader := s.createShader("still shader", stillShader)
+	defer shader.Release()
+
+	texture := wgpu.TextureBindingLayout{
+		SampleType:    wgpu.TextureSampleType_UnfilterableFloat,
+		ViewDimension: wgpu.TextureViewDimension_2D,
+	}
+	m.layout, err = s.bindGroupLayout("still",
+		bufferEntry(0, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		wgpu.BindGroupLayoutEntry{Binding: 1, Visibility: wgpu.ShaderStage_Fragment, Texture: texture},
+		wgpu.BindGroupLayoutEntry{Binding: 2, Visibility: wgpu.ShaderStage_Fragment, Texture: texture},
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.showLayout, err = s.bindGroupLayout("still show",
+		bufferEntry(0, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		wgpu.BindGroupLayoutEntry{Binding: 1, Visibility: wgpu.ShaderStage_Fragment, Texture: texture},
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.pipelineLayout, err = s.pipelineLayout("still", m.layout)
+	if err != nil {
+		return nil, err
+	}
+	m.showPipelineLayout, err = s.pipelineLayout("still show", m.showLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := func(label string, layout *wgpu.PipelineLayout, fs string, format wgpu.TextureFormat, multisample wgpu.MultisampleState) (*wgpu.RenderPipeline, error) {
+		return s.device.CreateRenderPipeline(&wgpu.RenderPipelineDescriptor{
+			Label:  label,
+			Layout: layout,
+			Vertex: wgpu.VertexState{
+				Module:     shader,
+				EntryPoint: "main_vs",
+			},
+			Fragment: &wgpu.FragmentState{
+				Module:     shader,
+				EntryPoint: fs,
+				Targets: []wgpu.ColorTargetState{
+					{Format: format, WriteMask: wgpu.ColorWriteMask_All},
+				},
+			},
+			Primitive: wgpu.PrimitiveState{
+				Topology: wgpu.PrimitiveTopology_TriangleList,
+			},
+			Multisample: multisample,
+		})
+	}
+	single := wgpu.MultisampleState{Count: 1, Mask: 0xFFFFFFFF}
+	m.accumulate, err = pipeline("still accumulate", m.pipelineLayout, "accumulate_fs", historyFormat, single)
+	if err != nil {
+		return nil, err
+	}
+	m.show, err = pipeline("still show", m.showPipelineLayout, "show_fs", s.config.Format, s.multisampleState())
+	if err != nil {
+		return nil, err
+	}
+	resolveFormat := m.exportFormat()
+	if m.depth == 16 {
+		resolveFormat = historyFormat
+	}
+	m.resolve, err = pipeline("still resolve", m.showPipelineLayout, "show_fs", resolveFormat, single)
+	if err != nil {
+		return nil, err
+	}
+
+	m.params = s.uniformBuffer("still params", wgpu.ToBytes([]uint32{0, stillMargin, 0, 0}))
+	m.showScale = s.uniformBuffer("still show scale", wgpu.ToBytes([]uint32{m.scale, 0, 0, 0}))
+	m.resolveScale = s.uniformBuffer("still resolve scale", wgpu.ToBytes([]uint32{1, 0, 0, 0}))
+	return m, m.resize()
+}
+
+// resize remakes the frame and the mean when the window has changed size,
+// starting the still over.
+func (m *stillMode) resize() (err error) {
+	s := m.state
+	if m.frame != nil && m.width == s.config.Width && m.height == s.config.Height {
+		return nil
+	}
+	m.releaseTextures()
+	width, height := s.config.Width*m.scale, s.config.Height*m.scale
+	m.frame, m.frameView, err = s.sizedRenderTexture("still frame", s.config.Format, width+2*stillMargin, height+2*stillMargin)
+	if err != nil {
+		return err
+	}
+	for i := range m.mean {
+		m.mean[i], m.meanViews[i], err = s.sizedRenderTexture("still mean", historyFormat, width, height)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range m.mean {
+		m.sets[i], err = s.device.CreateBindGroup(&wgpu.BindGroupDescriptor{
+			Label:  "still",
+			Layout: m.layout,
+			Entries: []wgpu.BindGroupEntry{
+				{Binding: 0, Buffer: m.params, Size: wgpu.WholeSize},
+				{Binding: 1, TextureView: m.frameView},
+				{Binding: 2, TextureView: m.meanViews[i]},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		for _, set := range []struct {
+			bindGroup **wgpu.BindGroup
+			scale     *wgpu.Buffer
+		}{{&m.showSets[i], m.showScale}, {&m.resolveSets[i], m.resolveScale}} {
+			*set.bindGroup, err = s.device.CreateBindGroup(&wgpu.BindGroupDescriptor{
+				Label:  "still show",
+				Layout: m.showLayout,
+				Entries: []wgpu.BindGroupEntry{
+					{Binding: 0, Buffer: set.scale, Size: wgpu.WholeSize},
+					{Binding: 1, TextureView: m.meanViews[i]},
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	m.width, m.height = s.config.Width, s.config.Height
+	m.taken = 0
+	return nil
+}
+
+func (m *stillMode) Enter(s *State) {
+	s.showPrompt(fmt.Sprintf("Paused for a still at %dx (Enter saves, S or Esc goes back)", m.scale))
+}
+
+func (m *stillMode) Exit(s *State) {
+	m.Release()
+}
+
+func (m *stillMode) HandleKey(s *State, key glfw.Key, action glfw.Action, mods glfw.ModifierKey) bool {
+	if action != glfw.Press {
+		return true
+	}
+	switch key {
+	case glfw.KeyS, glfw.KeyEscape:
+		s.setMode(m.prev)
+	case glfw.KeyEnter:
+		if err := m.save(s); err != nil {
+			log.Println("saving the still:", err)
+		}
+	}
+	return true
+}
+
+func (m *stillMode) OnAction(s *State, action string) {}
+
+// Step draws the next shifted sample of the paused simulation and adds it
+// into the mean, until there are enough.
+func (m *stillMode) Step(encoder *commandEncoder) {
+	if err := m.resize(); err != nil {
+		log.Println("resizing the still:", err)
+		return
+	}
+	if m.taken >= m.samples {
+		return
+	}
+	s := m.state
+
+	// The first sample is drawn where the window would draw it.
+	dx, dy := float32(0), float32(0)
+	if m.taken > 0 {
+		dx, dy = halton(m.taken, 2)-0.5, halton(m.taken, 3)-0.5
+	}
+	pass := encoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
+		ColorAttachments: []wgpu.RenderPassColorAttachment{s.attachScene(m.frameView, m.frame.GetWidth(), m.frame.GetHeight(), s.palette.clear())},
+	})
+	pass.SetViewport(stillMargin+dx, stillMargin+dy, float32(m.width*m.scale), float32(m.height*m.scale), 0, 1)
+	s.sim.Draw(pass)
+	pass.End()
+	pass.Release()
+
+	s.queue.WriteBuffer(m.params, 0, wgpu.ToBytes([]uint32{uint32(m.taken), stillMargin, 0, 0}))
+	next := 1 - m.current
+	pass = encoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
+		ColorAttachments: []wgpu.RenderPassColorAttachment{attachColourToView(m.meanViews[next], s.palette.clear())},
+	})
+	pass.SetPipeline(m.accumulate)
+	pass.SetBindGroup(0, m.sets[m.current], nil)
+	pass.Draw(3, 1, 0, 0)
+	pass.End()
+	pass.Release()
+	m.current = next
+	m.taken += 1
+	if m.taken == m.samples {
+		s.showPrompt(fmt.Sprintf("Still done after %s samples (Enter saves, S or Esc goes back)", s.format.Count(int64(m.taken))))
+	}
+}
+
+func (m *stillMode) Draw(s *State, pass *renderPass) {
+	if m.showSets[m.current] == nil {
+		return
+	}
+	pass.SetPipeline(m.show)
+	pass.SetBindGroup(0, m.showSets[m.current], nil)
+	pass.Draw(3, 1, 0, 0)
+}
+
+// exportFormat is what the mean is resolved into to be saved. HDR stills
+// stay in the scene's format for capture to tonemap.
+func (m *stillMode) exportFormat() wgpu.TextureFormat {
+	if m.state.hdr() {
+		return m.state.config.Format
+	}
+	return m.state.exportFormat()
+}
+
+// save writes the mean so far at full size to a PNG named after the step
+// the simulation is paused at.
+func (m *stillMode) save(s *State) error {
+	width, height := m.width*m.scale, m.height*m.scale
+	render := func(encoder *commandEncoder, view *wgpu.TextureView) {
+		pass := encoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
+			ColorAttachments: []wgpu.RenderPassColorAttachment{attachColourToView(view, s.palette.clear())},
+		})
+		defer pass.Release()
+		pass.SetPipeline(m.resolve)
+		pass.SetBindGroup(0, m.resolveSets[m.current], nil)
+		pass.Draw(3, 1, 0, 0)
+		pass.End()
+	}
+	var img image.Image
+	var err error
+	if m.depth == 16 {
+		img, err = s.captureWide(width, height, render)
+	} else {
+		img, err = s.capture(m.exportFormat(), width, height, render)
+	}
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("still-%06d.png", s.steps)
+	if err := savePNG(path, img); err != nil {
+		return err
+	}
+	fmt.Printf("saved %s, %dx%d from %s samples\n", path, width, height, s.format.Count(int64(m.taken)))
+	return nil
+}
+
+func (m *stillMode) releaseTextures() {
+	for i := range m.mean {
+		for _, bg := range []*wgpu.BindGroup{m.sets[i], m.showSets[i], m.resolveSets[i]} {
+			if bg != nil {
+				bg.Release()
+			}
+		}
+		m.sets[i], m.showSets[i], m.resolveSets[i] = nil, nil, nil
+		if m.meanViews[i] != nil {
+			m.meanViews[i].Release()
+			m.meanViews[i] = nil
+		}
+		if m.mean[i] != nil {
+			m.mean[i].Release()
+			m.mean[i] = nil
+		}
+	}
+	if m.frameView != nil {
+		m.frameView.Release()
+		m.frameView = nil
+	}
+	if m.frame != nil {
+		m.frame.Release()
+		m.frame = nil
+	}
+}
+
+func (m *stillMode) Release() {
+	m.releaseTextures()
+	for _, b := range []**wgpu.Buffer{&m.params, &m.showScale, &m.resolveScale} {
+		if *b != nil {
+			(*b).Release()
+			*b = nil
+		}
+	}
+	for _, p := range []**wgpu.RenderPipeline{&m.accumulate, &m.show, &m.resolve} {
+		if *p != nil {
+			(*p).Release()
+			*p = nil
+		}
+	}
+	for _, l := range []**wgpu.PipelineLayout{&m.pipelineLayout, &m.showPipelineLayout} {
+		if *l != nil {
+			(*l).Release()
+			*l = nil
+		}
+	}
+	for _, l := range []**wgpu.BindGroupLayout{&m.layout, &m.showLayout} {
+		if *l != nil {
+			(*l).Release()
+			*l = nil
+		}
+	}
+}
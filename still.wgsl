@@ -0,0 +1,50 @@
+// The still mode's passes. Each frame the paused simulation is drawn once
+// more into frame, shifted by a fraction of a pixel, and accumulate adds it
+// into the running mean of the frames before. show then boxes the mean back
+// down onto the window, or at full size for saving.
+struct Params {
+  // count is how many frames are in the mean so far.
+  count: u32,
+  // margin is how many pixels frame has around the image on each side, for
+  // the shifted draw to stay inside it.
+  margin: u32,
+};
+
+@group(0) @binding(0) var<uniform> params: Params;
+@group(0) @binding(1) var frame: texture_2d<f32>;
+@group(0) @binding(2) var mean: texture_2d<f32>;
+
+// One triangle covering the whole target.
+@vertex
+fn main_vs(@builtin(vertex_index) i: u32) -> @builtin(position) vec4<f32> {
+  let uv = vec2<f32>(f32((i << 1u) & 2u), f32(i & 2u));
+  return vec4<f32>(uv * 2.0 - 1.0, 0.0, 1.0);
+}
+
+@fragment
+fn accumulate_fs(@builtin(position) pos: vec4<f32>) -> @location(0) vec4<f32> {
+  let p = vec2<i32>(pos.xy);
+  let colour = textureLoad(frame, p + i32(params.margin), 0).rgb;
+  if params.count == 0u {
+    return vec4<f32>(colour, 1.0);
+  }
+  let previous = textureLoad(mean, p, 0).rgb;
+  return vec4<f32>(mix(previous, colour, 1.0 / f32(params.count + 1u)), 1.0);
+}
+
+// scale is how many pixels of the mean go across each one drawn.
+@group(0) @binding(0) var<uniform> scale: vec4<u32>;
+@group(0) @binding(1) var image: texture_2d<f32>;
+
+@fragment
+fn show_fs(@builtin(position) pos: vec4<f32>) -> @location(0) vec4<f32> {
+  let n = scale.x;
+  let corner = vec2<u32>(pos.xy) * n;
+  var sum = vec3<f32>(0.0);
+  for (var y = 0u; y < n; y += 1u) {
+    for (var x = 0u; x < n; x += 1u) {
+      sum += textureLoad(image, corner + vec2(x, y), 0).rgb;
+    }
+  }
+  return vec4<f32>(sum / f32(n * n), 1.0);
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed stochastic_compute.wgsl
+var stochasticCompute string
+
+//go:embed stochastic_draw.wgsl
+var stochasticDraw string
+
+// stochasticRules are the rules -stochastic-rule can name, by their kind in
+// stochastic_compute.wgsl.
+var stochasticRules = map[string]uint32{
+	"forest-fire": 0,
+	"life":        1,
+}
+
+// Stochastic runs automata whose transitions happen by chance, each cell
+// drawing a random number every generation on the GPU: the forest-fire
+// model with chances for trees to grow and for lightning to strike, or life
+// with chances for births and deaths to happen.
+type Stochastic struct {
+	bindGroupLayout    *wgpu.BindGroupLayout
+	pipelineLayout     *wgpu.PipelineLayout
+	drawPipelineLayout *wgpu.PipelineLayout
+	pipeline           *wgpu.RenderPipeline
+	simulationPipeline *wgpu.ComputePipeline
+	tick               *wgpu.ComputePipeline
+
+	rule             *wgpu.Buffer
+	generation       *wgpu.Buffer
+	cellStateStorage []*wgpu.Buffer
+	gridBindGroups   []*wgpu.BindGroup
+	vertices         *wgpu.Buffer
+	camera           *viewCamera
+	width, height    int
+	steps            int
+}
+
+func newStochastic(s *State, cfg *Config) (sim Simulation, err error) {
+	p := cfg.Stochastic
+	kind, ok := stochasticRules[p.Rule]
+	if !ok {
+		return nil, fmt.Errorf("unknown stochastic rule %q (have forest-fire, life)", p.Rule)
+	}
+	chances := [2]float32{p.Growth, p.Lightning}
+	if kind == stochasticRules["life"] {
+		chances = [2]float32{p.Birth, p.Death}
+	}
+	for _, c := range chances {
+		if c < 0 || c > 1 {
+			return nil, fmt.Errorf("stochastic chance %v out of range [0, 1]", c)
+		}
+	}
+	gen, err := newGenerator(cfg.Init)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Stochastic{
+		vertices: s.vertexBuffer,
+		camera:   s.camera,
+		width:    s.gridWidth,
+		height:   s.gridHeight,
+	}
+	defer func() {
+		if err != nil {
+			t.Release()
+		}
+	}()
+
+	drawShader := s.createShader("stochastic render shader", stochasticDraw+paletteShader+cameraShader)
+	defer drawShader.Release()
+
+	computeShader := s.createShader("stochastic compute shader", stochasticCompute)
+	defer computeShader.Release()
+
+	t.bindGroupLayout, err = s.bindGroupLayout("stochastic",
+		bufferEntry(0, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Fragment|wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(2, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(3, wgpu.ShaderStage_Fragment|wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(4, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(5, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	t.pipelineLayout, err = s.pipelineLayout("stochastic", t.bindGroupLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	t.drawPipelineLayout, err = s.pipelineLayout("stochastic render", t.bindGroupLayout, s.cameraLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	t.pipeline, err = s.renderPipeline("stochastic render", t.drawPipelineLayout, drawShader, "main_vs", "main_fs")
+	if err != nil {
+		return nil, err
+	}
+	t.simulationPipeline, err = s.computePipeline("stochastic compute", t.pipelineLayout, computeShader, "main")
+	if err != nil {
+		return nil, err
+	}
+	t.tick, err = s.computePipeline("stochastic tick", t.pipelineLayout, computeShader, "tick")
+	if err != nil {
+		return nil, err
+	}
+
+	t.rule = s.uniformBuffer("stochastic rule", wgpu.ToBytes([]uint32{
+		kind, s.rand.Uint32(), math.Float32bits(chances[0]), math.Float32bits(chances[1]),
+	}))
+	t.generation = s.storageBuffer(make([]byte, 4))
+
+	t.setCells(s, wgpu.ToBytes(generate(gen, s.rand, t.width, t.height)))
+	return t, nil
+}
+
+// setCells replaces both cell state buffers with cells.
+func (t *Stochastic) setCells(s *State, cells []byte) {
+	t.releaseCells()
+	t.cellStateStorage = []*wgpu.Buffer{
+		s.storageBuffer(cells),
+		s.storageBuffer(cells),
+	}
+	t.gridBindGroups = []*wgpu.BindGroup{
+		s.bindGroup("stochastic A", t.bindGroupLayout, s.gridBuffer, t.cellStateStorage[0], t.cellStateStorage[1], t.rule, t.generation, s.paletteBuffer),
+		s.bindGroup("stochastic B", t.bindGroupLayout, s.gridBuffer, t.cellStateStorage[1], t.cellStateStorage[0], t.rule, t.generation, s.paletteBuffer),
+	}
+}
+
+func (t *Stochastic) ResizeGrid(s *State, r gridRemap) error {
+	cells, err := s.readBuffer(t.cellStateStorage[t.steps%2])
+	if err != nil {
+		return err
+	}
+	t.width, t.height = r.width, r.height
+	t.setCells(s, r.cells(cells, 4, nil))
+	return nil
+}
+
+// Population counts the trees and fires, or the live cells.
+// Fields reads the state of every cell.
+func (t *Stochastic) Fields(s *State) (*fieldSet, error) {
+	data, err := s.readBuffer(t.cellStateStorage[t.steps%2])
+	if err != nil {
+		return nil, err
+	}
+	return cellFields(wgpu.FromBytes[uint32](data), t.width, t.height), nil
+}
+
+func (t *Stochastic) Population(s *State) (float64, error) {
+	return liveCells(s, t.cellStateStorage[t.steps%2])
+}
+
+func (t *Stochastic) Step(encoder *commandEncoder) {
+	computePass := encoder.BeginComputePass(nil)
+	defer computePass.Release()
+
+	computePass.SetPipeline(t.simulationPipeline)
+	computePass.SetBindGroup(0, t.gridBindGroups[t.steps%2], nil)
+	computePass.DispatchWorkgroups(uint32(t.width+7)/8, uint32(t.height+7)/8, 1)
+	computePass.SetPipeline(t.tick)
+	computePass.DispatchWorkgroups(1, 1, 1)
+	computePass.End()
+
+	t.steps += 1
+}
+
+func (t *Stochastic) batchesSteps() bool { return true }
+
+func (t *Stochastic) squareCells() bool { return true }
+
+func (t *Stochastic) usesCamera() {}
+
+func (t *Stochastic) Draw(pass *renderPass) {
+	pass.SetPipeline(t.pipeline)
+	pass.SetBindGroup(0, t.gridBindGroups[t.steps%2], nil)
+	pass.SetBindGroup(1, t.camera.bindGroup, nil)
+	pass.SetVertexBuffer(0, t.vertices, 0, wgpu.WholeSize)
+	pass.Draw(6, uint32(t.width*t.height), 0, 0)
+}
+
+func (t *Stochastic) releaseCells() {
+	for _, bg := range t.gridBindGroups {
+		bg.Release()
+	}
+	t.gridBindGroups = nil
+	for _, b := range t.cellStateStorage {
+		b.Release()
+	}
+	t.cellStateStorage = nil
+}
+
+func (t *Stochastic) Release() {
+	t.releaseCells()
+	if t.generation != nil {
+		t.generation.Release()
+		t.generation = nil
+	}
+	if t.rule != nil {
+		t.rule.Release()
+		t.rule = nil
+	}
+	if t.tick != nil {
+		t.tick.Release()
+		t.tick = nil
+	}
+	if t.simulationPipeline != nil {
+		t.simulationPipeline.Release()
+		t.simulationPipeline = nil
+	}
+	if t.pipeline != nil {
+		t.pipeline.Release()
+		t.pipeline = nil
+	}
+	if t.pipelineLayout != nil {
+		t.pipelineLayout.Release()
+		t.pipelineLayout = nil
+	}
+	if t.drawPipelineLayout != nil {
+		t.drawPipelineLayout.Release()
+		t.drawPipelineLayout = nil
+	}
+	if t.bindGroupLayout != nil {
+		t.bindGroupLayout.Release()
+		t.bindGroupLayout = nil
+	}
+}
@@ -0,0 +1,114 @@
+// Automata whose transitions are random, each cell drawing its own number
+// every generation from a hash of where it is, the generation and the seed.
+struct Rule {
+  // kind is 0 for the forest-fire model and 1 for probabilistic life.
+  kind: u32,
+  seed: u32,
+  // p and q are the chances of the rule's two random transitions; see
+  // forestFire and life.
+  p: f32,
+  q: f32,
+};
+
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<storage> cellStateIn: array<u32>;
+@group(0) @binding(2) var<storage, read_write> cellStateOut: array<u32>;
+@group(0) @binding(3) var<uniform> rule: Rule;
+// generation counts the steps on the GPU, so that however many are recorded
+// before a submit each draws new numbers.
+@group(0) @binding(4) var<storage, read_write> generation: array<u32>;
+
+const empty = 0u;
+const tree = 1u;
+const fire = 2u;
+
+// pcg is the PCG hash of v, as in "Hash Functions for GPU Rendering"
+// (Jarzynski and Olano, 2020).
+fn pcg(v: u32) -> u32 {
+  let state = v * 747796405u + 2891336453u;
+  let word = ((state >> ((state >> 28u) + 4u)) ^ state) * 277803737u;
+  return (word >> 22u) ^ word;
+}
+
+// random is uniform in [0, 1) for the cell at index i this generation.
+fn random(i: u32) -> f32 {
+  let bits = pcg(pcg(i ^ rule.seed) + generation[0]);
+  return f32(bits >> 8u) / 16777216.0;
+}
+
+@compute
+@workgroup_size(8, 8)
+fn main(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x >= u32(grid.x) || id.y >= u32(grid.y) {
+    return;
+  }
+  let cell = vec2<i32>(id.xy);
+  let i = cellIndex(cell);
+  if rule.kind == 0u {
+    cellStateOut[i] = forestFire(cell, random(i));
+  } else {
+    cellStateOut[i] = life(cell, random(i));
+  }
+}
+
+// forestFire is the Drossel-Schwabl model: fire burns trees out, trees next
+// to fire catch, other trees are struck by lightning with chance q and
+// empty ground grows a tree with chance p.
+fn forestFire(cell: vec2<i32>, r: f32) -> u32 {
+  switch cellState(cell.x, cell.y) {
+    case 2u: {
+      return empty;
+    }
+    case 1u: {
+      for (var dy = -1; dy <= 1; dy += 1) {
+        for (var dx = -1; dx <= 1; dx += 1) {
+          if cellState(cell.x + dx, cell.y + dy) == fire {
+            return fire;
+          }
+        }
+      }
+      return select(tree, fire, r < rule.q);
+    }
+    default: {
+      return select(empty, tree, r < rule.p);
+    }
+  }
+}
+
+// life is B3/S23 where each birth only happens with chance p and each death
+// with chance q; otherwise the cell stays as it is.
+fn life(cell: vec2<i32>, r: f32) -> u32 {
+  var n = 0u;
+  for (var dy = -1; dy <= 1; dy += 1) {
+    for (var dx = -1; dx <= 1; dx += 1) {
+      if (dx != 0 || dy != 0) && cellState(cell.x + dx, cell.y + dy) != 0u {
+        n += 1u;
+      }
+    }
+  }
+  let alive = cellState(cell.x, cell.y) != 0u;
+  if !alive && n == 3u {
+    return select(0u, 1u, r < rule.p);
+  }
+  if alive && (n < 2u || n > 3u) {
+    return select(1u, 0u, r < rule.q);
+  }
+  return select(0u, 1u, alive);
+}
+
+// tick moves generation on after each step.
+@compute
+@workgroup_size(1)
+fn tick() {
+  generation[0] += 1u;
+}
+
+fn cellState(x: i32, y: i32) -> u32 {
+  let size = vec2<i32>(grid);
+  let cell = (vec2(x, y) % size + size) % size;
+  return cellStateIn[cellIndex(cell)];
+}
+
+fn cellIndex(cell: vec2<i32>) -> u32 {
+  return u32(cell.y * i32(grid.x) + cell.x);
+}
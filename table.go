@@ -0,0 +1,518 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed table_compute.wgsl
+var tableCompute string
+
+//go:embed table_draw.wgsl
+var tableDraw string
+
+// maxTableStates keeps rule tables to states that can be told apart when
+// drawn.
+const maxTableStates = 256
+
+// maxTableRadius keeps the neighbourhood loop of every cell short enough to
+// run each frame.
+const maxTableRadius = 10
+
+// maxTableWeight keeps the tables of weighted rules, with an entry for every
+// possible sum, from growing too big.
+const maxTableWeight = 1024
+
+// neighbourhood is which cells around a cell count towards its live
+// neighbours: those within radius of it in both directions, or with
+// vonNeumann only those within radius steps along the axes. middle counts
+// the cell itself too, as some Larger-than-Life rules do. weights, when
+// set, replaces all of those with how much each cell within radius counts
+// for; a cell that counts 0 isn't a neighbour at all.
+type neighbourhood struct {
+	radius     int
+	vonNeumann bool
+	middle     bool
+	weights    [][]uint32
+}
+
+// weightedNeighbourhood checks a weight matrix from the config file: square,
+// an odd number of cells across with the cell itself in the middle.
+func weightedNeighbourhood(weights [][]uint32) (neighbourhood, error) {
+	side := len(weights)
+	if side%2 == 0 || side > 2*maxTableRadius+1 {
+		return neighbourhood{}, fmt.Errorf("neighbour weights are %d rows, want an odd number up to %d", side, 2*maxTableRadius+1)
+	}
+	total := 0
+	for y, row := range weights {
+		if len(row) != side {
+			return neighbourhood{}, fmt.Errorf("neighbour weights row %d has %d weights, want %d like the number of rows", y, len(row), side)
+		}
+		for _, w := range row {
+			total += int(w)
+		}
+	}
+	if total == 0 || total > maxTableWeight {
+		return neighbourhood{}, fmt.Errorf("neighbour weights add up to %d, want [1, %d]", total, maxTableWeight)
+	}
+	return neighbourhood{radius: side / 2, weights: weights}, nil
+}
+
+// parseNeighbourhood reads the neighbourhood names -table-neighbourhood
+// takes.
+func parseNeighbourhood(name string, radius int) (neighbourhood, error) {
+	nb := neighbourhood{radius: radius}
+	switch name {
+	case "moore":
+	case "von-neumann":
+		nb.vonNeumann = true
+	default:
+		return nb, fmt.Errorf("unknown neighbourhood %q (have moore, von-neumann)", name)
+	}
+	if radius < 1 || radius > maxTableRadius {
+		return nb, fmt.Errorf("neighbourhood radius %d out of range [1, %d]", radius, maxTableRadius)
+	}
+	return nb, nil
+}
+
+// matrix is how much each cell within radius counts for, row by row from
+// radius above the cell.
+func (nb neighbourhood) matrix() []uint32 {
+	r := nb.radius
+	var m []uint32
+	for dy := -r; dy <= r; dy++ {
+		for dx := -r; dx <= r; dx++ {
+			switch {
+			case nb.weights != nil:
+				m = append(m, nb.weights[dy+r][dx+r])
+			case dx == 0 && dy == 0 && !nb.middle:
+				m = append(m, 0)
+			case nb.vonNeumann && abs(dx)+abs(dy) > r:
+				m = append(m, 0)
+			default:
+				m = append(m, 1)
+			}
+		}
+	}
+	return m
+}
+
+func abs(n int) int {
+	return max(n, -n)
+}
+
+// cells is the most live neighbours there can be, each counted by its
+// weight.
+func (nb neighbourhood) cells() int {
+	n := 0
+	for _, w := range nb.matrix() {
+		n += int(w)
+	}
+	return n
+}
+
+// parseTableRule builds the transition table for a rule in either B/S/C
+// notation, counted over nb, or Golly's Larger-than-Life notation, which
+// gives its own neighbourhood.
+func parseTableRule(rule string, nb neighbourhood) ([][]uint32, neighbourhood, error) {
+	if strings.HasPrefix(strings.ToUpper(rule), "R") {
+		if nb.weights != nil {
+			return nil, nb, fmt.Errorf("rule %q gives its own neighbourhood, so it can't have neighbour weights", rule)
+		}
+		return parseLargerThanLifeRule(rule)
+	}
+	table, err := parseGenerationsRule(rule, nb.cells())
+	return table, nb, err
+}
+
+// parseGenerationsRule turns a rule such as B2/S/3 into a transition table
+// for up to neighbours live neighbours. Cells in state 0 with a birth count
+// of live neighbours become live, state 1, and live cells with a survival
+// count stay live. Any other live cell moves on to state 2 and from there
+// through the rest of the C states before dying, whatever its neighbours.
+// C is 2, plain life-like rules, when it is left out. Counts past 9, which
+// weighted neighbourhoods can reach, are written with commas between them,
+// as in B3,12/S2,3.
+func parseGenerationsRule(rule string, neighbours int) ([][]uint32, error) {
+	fields := strings.Split(strings.ToUpper(rule), "/")
+	if len(fields) < 2 || len(fields) > 3 || !strings.HasPrefix(fields[0], "B") || !strings.HasPrefix(fields[1], "S") {
+		return nil, fmt.Errorf("rule %q: want B/S/C such as B2/S/3", rule)
+	}
+	states := 2
+	if len(fields) == 3 {
+		n, err := strconv.Atoi(strings.TrimPrefix(fields[2], "C"))
+		if err != nil || n < 2 || n > maxTableStates {
+			return nil, fmt.Errorf("rule %q: state count %q out of range [2, %d]", rule, fields[2], maxTableStates)
+		}
+		states = n
+	}
+	counts := func(field string) ([]bool, error) {
+		in := make([]bool, neighbours+1)
+		list := strings.Split(field, ",")
+		if !strings.Contains(field, ",") {
+			list = strings.Split(field, "")
+		}
+		for _, c := range list {
+			n, err := strconv.Atoi(c)
+			if err != nil || n < 0 || n > neighbours {
+				return in, fmt.Errorf("rule %q: bad neighbour count %q", rule, c)
+			}
+			in[n] = true
+		}
+		return in, nil
+	}
+	birth, err := counts(fields[0][1:])
+	if err != nil {
+		return nil, err
+	}
+	survival, err := counts(fields[1][1:])
+	if err != nil {
+		return nil, err
+	}
+	return buildTable(states, birth, survival), nil
+}
+
+// parseLargerThanLifeRule reads a rule such as R5,C0,M1,S34..58,B34..45,NM
+// (Bosco's rule): the radius, the state count, whether the middle cell
+// counts, the survival and birth ranges of live neighbours, and NM for a
+// Moore or NN for a von Neumann neighbourhood. C0 and C1 both mean two
+// states. S and B can be given more than once for several ranges.
+func parseLargerThanLifeRule(rule string) ([][]uint32, neighbourhood, error) {
+	var nb neighbourhood
+	bad := func(field string) ([][]uint32, neighbourhood, error) {
+		return nil, nb, fmt.Errorf("rule %q: bad field %q, want one like R5,C0,M1,S34..58,B34..45,NM", rule, field)
+	}
+	states, seen := 2, map[byte]bool{}
+	var survival, birth [][2]int
+	for _, field := range strings.Split(strings.ToUpper(rule), ",") {
+		if field == "" {
+			return bad(field)
+		}
+		key, value := field[0], field[1:]
+		if seen[key] && key != 'S' && key != 'B' {
+			return bad(field)
+		}
+		seen[key] = true
+		switch key {
+		case 'R', 'C', 'M':
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return bad(field)
+			}
+			switch {
+			case key == 'R':
+				nb.radius = n
+			case key == 'C' && n > 2:
+				states = n
+			case key == 'M' && (n == 0 || n == 1):
+				nb.middle = n == 1
+			case key == 'M':
+				return bad(field)
+			}
+		case 'S', 'B':
+			lo, hi, ok := strings.Cut(value, "..")
+			from, err1 := strconv.Atoi(lo)
+			to, err2 := strconv.Atoi(hi)
+			if !ok || err1 != nil || err2 != nil || from > to {
+				return bad(field)
+			}
+			if key == 'S' {
+				survival = append(survival, [2]int{from, to})
+			} else {
+				birth = append(birth, [2]int{from, to})
+			}
+		case 'N':
+			switch value {
+			case "M":
+			case "N":
+				nb.vonNeumann = true
+			default:
+				return bad(field)
+			}
+		default:
+			return bad(field)
+		}
+	}
+	if !seen['R'] {
+		return nil, nb, fmt.Errorf("rule %q: no radius", rule)
+	}
+	if nb.radius < 1 || nb.radius > maxTableRadius {
+		return nil, nb, fmt.Errorf("rule %q: radius %d out of range [1, %d]", rule, nb.radius, maxTableRadius)
+	}
+	if states > maxTableStates {
+		return nil, nb, fmt.Errorf("rule %q: state count %d out of range [2, %d]", rule, states, maxTableStates)
+	}
+	in := func(ranges [][2]int) []bool {
+		counts := make([]bool, nb.cells()+1)
+		for _, r := range ranges {
+			for n := max(r[0], 0); n <= min(r[1], len(counts)-1); n++ {
+				counts[n] = true
+			}
+		}
+		return counts
+	}
+	return buildTable(states, in(birth), in(survival)), nb, nil
+}
+
+// buildTable makes the table of a rule with the given number of states; see
+// parseGenerationsRule.
+func buildTable(states int, birth, survival []bool) [][]uint32 {
+	table := make([][]uint32, states)
+	for state := range table {
+		table[state] = make([]uint32, len(birth))
+		for n := range table[state] {
+			switch {
+			case state == 0 && birth[n]:
+				table[state][n] = 1
+			case state == 1 && survival[n]:
+				table[state][n] = 1
+			case state > 0 && state+1 < states:
+				table[state][n] = uint32(state + 1)
+			}
+		}
+	}
+	return table
+}
+
+// checkTable makes sure every row of a table has an entry for each count of
+// live neighbours up to neighbours and leads to a state the table has.
+func checkTable(table [][]uint32, neighbours int) error {
+	if len(table) < 2 || len(table) > maxTableStates {
+		return fmt.Errorf("rule table has %d states, want [2, %d]", len(table), maxTableStates)
+	}
+	for state, row := range table {
+		if len(row) != neighbours+1 {
+			return fmt.Errorf("rule table state %d has %d entries, want one for each of 0 to %d live neighbours", state, len(row), neighbours)
+		}
+		for n, next := range row {
+			if int(next) >= len(table) {
+				return fmt.Errorf("rule table state %d with %d live neighbours goes to state %d, past the last", state, n, next)
+			}
+		}
+	}
+	return nil
+}
+
+// RuleTable runs any outer-totalistic automaton on a torus: each cell's next
+// state is looked up in a table by its current state and how many of its
+// neighbours are alive, in a Moore or von Neumann neighbourhood of any
+// radius up to maxTableRadius or with each neighbour weighted.
+type RuleTable struct {
+	bindGroupLayout    *wgpu.BindGroupLayout
+	pipelineLayout     *wgpu.PipelineLayout
+	drawPipelineLayout *wgpu.PipelineLayout
+	pipeline           *wgpu.RenderPipeline
+	simulationPipeline *wgpu.ComputePipeline
+
+	table            *wgpu.Buffer
+	weights          *wgpu.Buffer
+	rule             *wgpu.Buffer
+	cellStateStorage []*wgpu.Buffer
+	gridBindGroups   []*wgpu.BindGroup
+	vertices         *wgpu.Buffer
+	camera           *viewCamera
+	width, height    int
+	steps            int
+}
+
+func newRuleTable(s *State, cfg *Config) (sim Simulation, err error) {
+	nb, err := parseNeighbourhood(cfg.Table.Neighbourhood, cfg.Table.Radius)
+	if len(cfg.Table.Weights) > 0 {
+		nb, err = weightedNeighbourhood(cfg.Table.Weights)
+	}
+	if err != nil {
+		return nil, err
+	}
+	table := cfg.Table.Transitions
+	if len(table) == 0 {
+		if table, nb, err = parseTableRule(cfg.Table.Rule, nb); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkTable(table, nb.cells()); err != nil {
+		return nil, err
+	}
+	if maxRadius := min(s.gridWidth, s.gridHeight) / 2; nb.radius > maxRadius {
+		return nil, fmt.Errorf("neighbourhood radius %d is too big for the grid, at most %d", nb.radius, maxRadius)
+	}
+	gen, err := newGenerator(cfg.Init)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &RuleTable{
+		vertices: s.vertexBuffer,
+		camera:   s.camera,
+		width:    s.gridWidth,
+		height:   s.gridHeight,
+	}
+	defer func() {
+		if err != nil {
+			t.Release()
+		}
+	}()
+
+	drawShader := s.createShader("rule table render shader", tableDraw+paletteShader+cameraShader)
+	defer drawShader.Release()
+
+	computeShader := s.createShader("rule table compute shader", tableCompute)
+	defer computeShader.Release()
+
+	t.bindGroupLayout, err = s.bindGroupLayout("rule table",
+		bufferEntry(0, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Fragment|wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(2, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(3, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(4, wgpu.ShaderStage_Fragment|wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(5, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(6, wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	t.pipelineLayout, err = s.pipelineLayout("rule table", t.bindGroupLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	t.drawPipelineLayout, err = s.pipelineLayout("rule table render", t.bindGroupLayout, s.cameraLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	t.pipeline, err = s.renderPipeline("rule table render", t.drawPipelineLayout, drawShader, "main_vs", "main_fs")
+	if err != nil {
+		return nil, err
+	}
+	t.simulationPipeline, err = s.computePipeline("rule table compute", t.pipelineLayout, computeShader, "main")
+	if err != nil {
+		return nil, err
+	}
+
+	flat := make([]uint32, 0, len(table)*len(table[0]))
+	for _, row := range table {
+		flat = append(flat, row...)
+	}
+	t.table = s.storageBuffer(wgpu.ToBytes(flat))
+	t.weights = s.storageBuffer(wgpu.ToBytes(nb.matrix()))
+	// Uniform buffers are padded out to 16 bytes.
+	t.rule = s.uniformBuffer("rule table rule", wgpu.ToBytes([]uint32{uint32(len(table)), uint32(nb.radius), 0, 0}))
+
+	t.setCells(s, wgpu.ToBytes(generate(gen, s.rand, t.width, t.height)))
+	return t, nil
+}
+
+// setCells replaces both cell state buffers with cells.
+func (t *RuleTable) setCells(s *State, cells []byte) {
+	t.releaseCells()
+	t.cellStateStorage = []*wgpu.Buffer{
+		s.storageBuffer(cells),
+		s.storageBuffer(cells),
+	}
+	t.gridBindGroups = []*wgpu.BindGroup{
+		s.bindGroup("rule table A", t.bindGroupLayout, s.gridBuffer, t.cellStateStorage[0], t.cellStateStorage[1], t.table, t.rule, t.weights, s.paletteBuffer),
+		s.bindGroup("rule table B", t.bindGroupLayout, s.gridBuffer, t.cellStateStorage[1], t.cellStateStorage[0], t.table, t.rule, t.weights, s.paletteBuffer),
+	}
+}
+
+func (t *RuleTable) ResizeGrid(s *State, r gridRemap) error {
+	cells, err := s.readBuffer(t.cellStateStorage[t.steps%2])
+	if err != nil {
+		return err
+	}
+	t.width, t.height = r.width, r.height
+	t.setCells(s, r.cells(cells, 4, nil))
+	return nil
+}
+
+// Population counts the cells in any state but 0.
+// Fields reads the state of every cell.
+func (t *RuleTable) Fields(s *State) (*fieldSet, error) {
+	data, err := s.readBuffer(t.cellStateStorage[t.steps%2])
+	if err != nil {
+		return nil, err
+	}
+	return cellFields(wgpu.FromBytes[uint32](data), t.width, t.height), nil
+}
+
+func (t *RuleTable) Population(s *State) (float64, error) {
+	return liveCells(s, t.cellStateStorage[t.steps%2])
+}
+
+func (t *RuleTable) Step(encoder *commandEncoder) {
+	computePass := encoder.BeginComputePass(nil)
+	defer computePass.Release()
+
+	computePass.SetPipeline(t.simulationPipeline)
+	computePass.SetBindGroup(0, t.gridBindGroups[t.steps%2], nil)
+	computePass.DispatchWorkgroups(uint32(t.width+7)/8, uint32(t.height+7)/8, 1)
+	computePass.End()
+
+	t.steps += 1
+}
+
+func (t *RuleTable) batchesSteps() bool { return true }
+
+func (t *RuleTable) squareCells() bool { return true }
+
+func (t *RuleTable) usesCamera() {}
+
+func (t *RuleTable) Draw(pass *renderPass) {
+	pass.SetPipeline(t.pipeline)
+	pass.SetBindGroup(0, t.gridBindGroups[t.steps%2], nil)
+	pass.SetBindGroup(1, t.camera.bindGroup, nil)
+	pass.SetVertexBuffer(0, t.vertices, 0, wgpu.WholeSize)
+	pass.Draw(6, uint32(t.width*t.height), 0, 0)
+}
+
+func (t *RuleTable) releaseCells() {
+	for _, bg := range t.gridBindGroups {
+		bg.Release()
+	}
+	t.gridBindGroups = nil
+	for _, b := range t.cellStateStorage {
+		b.Release()
+	}
+	t.cellStateStorage = nil
+}
+
+func (t *RuleTable) Release() {
+	t.releaseCells()
+	if t.rule != nil {
+		t.rule.Release()
+		t.rule = nil
+	}
+	if t.weights != nil {
+		t.weights.Release()
+		t.weights = nil
+	}
+	if t.table != nil {
+		t.table.Release()
+		t.table = nil
+	}
+	if t.simulationPipeline != nil {
+		t.simulationPipeline.Release()
+		t.simulationPipeline = nil
+	}
+	if t.pipeline != nil {
+		t.pipeline.Release()
+		t.pipeline = nil
+	}
+	if t.pipelineLayout != nil {
+		t.pipelineLayout.Release()
+		t.pipelineLayout = nil
+	}
+	if t.drawPipelineLayout != nil {
+		t.drawPipelineLayout.Release()
+		t.drawPipelineLayout = nil
+	}
+	if t.bindGroupLayout != nil {
+		t.bindGroupLayout.Release()
+		t.bindGroupLayout = nil
+	}
+}
@@ -0,0 +1,51 @@
+struct Rule {
+  states: u32,
+  radius: u32,
+};
+
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<storage> cellStateIn: array<u32>;
+@group(0) @binding(2) var<storage, read_write> cellStateOut: array<u32>;
+// table[state * (neighbours + 1) + n] is the next state of a cell in state
+// with n of its neighbours alive, that is in state 1, each counted by its
+// weight.
+@group(0) @binding(3) var<storage> table: array<u32>;
+@group(0) @binding(4) var<uniform> rule: Rule;
+// weights has how much each cell within radius counts for, row by row from
+// radius above the cell; cells past the shape of the neighbourhood count 0.
+@group(0) @binding(5) var<storage> weights: array<u32>;
+
+@compute
+@workgroup_size(8, 8)
+fn main(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x >= u32(grid.x) || id.y >= u32(grid.y) {
+    return;
+  }
+  let cell = vec2<i32>(id.xy);
+  let r = i32(rule.radius);
+  var alive = 0u;
+  var neighbours = 0u;
+  var w = 0u;
+  for (var dy = -r; dy <= r; dy += 1) {
+    for (var dx = -r; dx <= r; dx += 1) {
+      let weight = weights[w];
+      w += 1u;
+      neighbours += weight;
+      if weight != 0u && cellState(cell.x + dx, cell.y + dy) == 1u {
+        alive += weight;
+      }
+    }
+  }
+  let i = cellIndex(cell);
+  cellStateOut[i] = table[cellStateIn[i] * (neighbours + 1u) + alive];
+}
+
+fn cellState(x: i32, y: i32) -> u32 {
+  let size = vec2<i32>(grid);
+  let cell = (vec2(x, y) % size + size) % size;
+  return cellStateIn[cellIndex(cell)];
+}
+
+fn cellIndex(cell: vec2<i32>) -> u32 {
+  return u32(cell.y * i32(grid.x) + cell.x);
+}
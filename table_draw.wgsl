@@ -0,0 +1,47 @@
+// palette.wgsl and camera.wgsl go after this.
+struct VertexInput {
+  @location(0) pos: vec2<f32>,
+  @builtin(instance_index) instance: u32,
+};
+
+struct VertexOutput {
+  @builtin(position) pos: vec4<f32>,
+  @location(0) cell: vec2<f32>,
+  @location(1) @interpolate(flat) state: u32,
+  // local runs from -1 to 1 across the cell's quad.
+  @location(2) local: vec2<f32>,
+};
+
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<storage> cellStateIn: array<u32>;
+// rule.x is how many states the table has.
+@group(0) @binding(4) var<uniform> rule: vec4<u32>;
+@group(0) @binding(6) var<uniform> palette: Palette;
+
+@vertex
+fn main_vs(input: VertexInput) -> VertexOutput {
+  let i = f32(input.instance);
+  let state = cellStateIn[input.instance];
+  let cell = vec2<f32>(i % grid.x, floor(i / grid.x));
+  let cellOffset = cell / grid * 2.0;
+  let scale = select(0.0, 1.0, state > 0u);
+  let gridPos = (scale * input.pos + 1.0) / grid - 1.0 + cellOffset;
+
+  var output: VertexOutput;
+  output.pos = camera * vec4<f32>(gridPos, 0.0, 1.0);
+  output.cell = cell;
+  output.state = state;
+  output.local = input.pos / 0.8;
+  return output;
+}
+
+// Live cells are coloured by position like life, and the states after them
+// fade out towards the last.
+@fragment
+fn main_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  var fade = 1.0;
+  if rule.x > 2u {
+    fade = 1.0 - 0.8 * f32(input.state - 1u) / f32(rule.x - 2u);
+  }
+  return shapeColour(palette, paletteColour(palette, input.cell / grid) * fade, cellShape(palette, input.local, fwidth(input.local)));
+}
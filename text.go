@@ -0,0 +1,422 @@
+package main
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed text.wgsl
+var textShader string
+
+// glyphWidth and glyphHeight are the size of the font's glyphs in font
+// pixels, and glyphWidth is the width of one in the atlas.
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+)
+
+// fontGlyphs are the characters the font has, in atlas order. Lower case is
+// drawn as upper case, and anything else as a question mark.
+const fontGlyphs = " 0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ.,:;/-+()%=!?'_#[]<>*"
+
+// font has the rows of each of fontGlyphs from the top, the leftmost pixel
+// in the highest of the five bits.
+var font = [len(fontGlyphs)][glyphHeight]uint8{
+	{},
+	{0b01110, 0b10001, 0b10011, 0b10101, 0b11001, 0b10001, 0b01110},
+	{0b00100, 0b01100, 0b00100, 0b00100, 0b00100, 0b00100, 0b01110},
+	{0b01110, 0b10001, 0b00001, 0b00010, 0b00100, 0b01000, 0b11111},
+	{0b11111, 0b00010, 0b00100, 0b00010, 0b00001, 0b10001, 0b01110},
+	{0b00010, 0b00110, 0b01010, 0b10010, 0b11111, 0b00010, 0b00010},
+	{0b11111, 0b10000, 0b11110, 0b00001, 0b00001, 0b10001, 0b01110},
+	{0b00110, 0b01000, 0b10000, 0b11110, 0b10001, 0b10001, 0b01110},
+	{0b11111, 0b00001, 0b00010, 0b00100, 0b01000, 0b01000, 0b01000},
+	{0b01110, 0b10001, 0b10001, 0b01110, 0b10001, 0b10001, 0b01110},
+	{0b01110, 0b10001, 0b10001, 0b01111, 0b00001, 0b00010, 0b01100},
+	{0b01110, 0b10001, 0b10001, 0b11111, 0b10001, 0b10001, 0b10001},
+	{0b11110, 0b10001, 0b10001, 0b11110, 0b10001, 0b10001, 0b11110},
+	{0b01110, 0b10001, 0b10000, 0b10000, 0b10000, 0b10001, 0b01110},
+	{0b11100, 0b10010, 0b10001, 0b10001, 0b10001, 0b10010, 0b11100},
+	{0b11111, 0b10000, 0b10000, 0b11110, 0b10000, 0b10000, 0b11111},
+	{0b11111, 0b10000, 0b10000, 0b11110, 0b10000, 0b10000, 0b10000},
+	{0b01110, 0b10001, 0b10000, 0b10111, 0b10001, 0b10001, 0b01111},
+	{0b10001, 0b10001, 0b10001, 0b11111, 0b10001, 0b10001, 0b10001},
+	{0b01110, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0b01110},
+	{0b00111, 0b00010, 0b00010, 0b00010, 0b00010, 0b10010, 0b01100},
+	{0b10001, 0b10010, 0b10100, 0b11000, 0b10100, 0b10010, 0b10001},
+	{0b10000, 0b10000, 0b10000, 0b10000, 0b10000, 0b10000, 0b11111},
+	{0b10001, 0b11011, 0b10101, 0b10101, 0b10001, 0b10001, 0b10001},
+	{0b10001, 0b10001, 0b11001, 0b10101, 0b10011, 0b10001, 0b10001},
+	{0b01110, 0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01110},
+	{0b11110, 0b10001, 0b10001, 0b11110, 0b10000, 0b10000, 0b10000},
+	{0b01110, 0b10001, 0b10001, 0b10001, 0b10101, 0b10010, 0b01101},
+	{0b11110, 0b10001, 0b10001, 0b11110, 0b10100, 0b10010, 0b10001},
+	{0b01111, 0b10000, 0b10000, 0b01110, 0b00001, 0b00001, 0b11110},
+	{0b11111, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100},
+	{0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01110},
+	{0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01010, 0b00100},
+	{0b10001, 0b10001, 0b10001, 0b10101, 0b10101, 0b10101, 0b01010},
+	{0b10001, 0b10001, 0b01010, 0b00100, 0b01010, 0b10001, 0b10001},
+	{0b10001, 0b10001, 0b10001, 0b01010, 0b00100, 0b00100, 0b00100},
+	{0b11111, 0b00001, 0b00010, 0b00100, 0b01000, 0b10000, 0b11111},
+	{0b00000, 0b00000, 0b00000, 0b00000, 0b00000, 0b01100, 0b01100},
+	{0b00000, 0b00000, 0b00000, 0b00000, 0b01100, 0b00100, 0b01000},
+	{0b00000, 0b01100, 0b01100, 0b00000, 0b01100, 0b01100, 0b00000},
+	{0b00000, 0b01100, 0b01100, 0b00000, 0b01100, 0b00100, 0b01000},
+	{0b00000, 0b00001, 0b00010, 0b00100, 0b01000, 0b10000, 0b00000},
+	{0b00000, 0b00000, 0b00000, 0b11111, 0b00000, 0b00000, 0b00000},
+	{0b00000, 0b00100, 0b00100, 0b11111, 0b00100, 0b00100, 0b00000},
+	{0b00010, 0b00100, 0b01000, 0b01000, 0b01000, 0b00100, 0b00010},
+	{0b01000, 0b00100, 0b00010, 0b00010, 0b00010, 0b00100, 0b01000},
+	{0b11000, 0b11001, 0b00010, 0b00100, 0b01000, 0b10011, 0b00011},
+	{0b00000, 0b00000, 0b11111, 0b00000, 0b11111, 0b00000, 0b00000},
+	{0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0b00000, 0b00100},
+	{0b01110, 0b10001, 0b00001, 0b00010, 0b00100, 0b00000, 0b00100},
+	{0b01100, 0b00100, 0b01000, 0b00000, 0b00000, 0b00000, 0b00000},
+	{0b00000, 0b00000, 0b00000, 0b00000, 0b00000, 0b00000, 0b11111},
+	{0b01010, 0b01010, 0b11111, 0b01010, 0b11111, 0b01010, 0b01010},
+	{0b01110, 0b01000, 0b01000, 0b01000, 0b01000, 0b01000, 0b01110},
+	{0b01110, 0b00010, 0b00010, 0b00010, 0b00010, 0b00010, 0b01110},
+	{0b00010, 0b00100, 0b01000, 0b10000, 0b01000, 0b00100, 0b00010},
+	{0b01000, 0b00100, 0b00010, 0b00001, 0b00010, 0b00100, 0b01000},
+	{0b00000, 0b00100, 0b10101, 0b01110, 0b10101, 0b00100, 0b00000},
+}
+
+// glyphIndex is where r is drawn from in the atlas.
+func glyphIndex(r rune) uint32 {
+	switch r {
+	case '\u00a0', '\u202f':
+		r = ' '
+	case '’':
+		r = '\''
+	}
+	if i := strings.IndexRune(fontGlyphs, unicode.ToUpper(r)); i >= 0 {
+		return uint32(i)
+	}
+	return uint32(strings.IndexByte(fontGlyphs, '?'))
+}
+
+// fontAtlas is the font as a single row of glyphs, one byte a pixel.
+func fontAtlas() []byte {
+	width := glyphWidth * len(fontGlyphs)
+	texels := make([]byte, width*glyphHeight)
+	for g, rows := range font {
+		for y, row := range rows {
+			for x := 0; x < glyphWidth; x++ {
+				if row&(1<<(glyphWidth-1-x)) != 0 {
+					texels[y*width+g*glyphWidth+x] = 255
+				}
+			}
+		}
+	}
+	return texels
+}
+
+// noGlyph marks a quad as a solid rectangle rather than a glyph.
+const noGlyph = 0xFFFFFFFF
+
+// quadInstance is Quad in text.wgsl.
+type quadInstance struct {
+	x, y, width, height float32
+	glyph               uint32
+	colour              uint32
+}
+
+// packColour packs a linear colour into the four bytes of Quad's colour.
+func packColour(c [4]float32) uint32 {
+	var packed uint32
+	for i, v := range c {
+		packed |= uint32(max(0, min(v, 1))*255+0.5) << (8 * i)
+	}
+	return packed
+}
+
+var (
+	textWhite  = [4]float32{1, 1, 1, 1}
+	textShadow = [4]float32{0, 0, 0, 1}
+)
+
+// textRenderer draws text, and rectangles to set it off, over a frame, each
+// an instance of a quad. Glyphs are read from the font atlas.
+type textRenderer struct {
+	device *wgpu.Device
+	queue  *uploadQueue
+
+	layout         *wgpu.BindGroupLayout
+	pipelineLayout *wgpu.PipelineLayout
+	pipeline       *wgpu.RenderPipeline
+	atlas          *wgpu.Texture
+	atlasView      *wgpu.TextureView
+	params         *wgpu.Buffer
+
+	quads     *wgpu.Buffer
+	set       *wgpu.BindGroup
+	capacity  int
+	instances []quadInstance
+	// encode is whether the view needs the sRGB encoding done in the
+	// shader, and size is the view size params was last written for.
+	encode bool
+	size   [2]uint32
+	// pixel is how many framebuffer pixels wide a font pixel is.
+	pixel float32
+}
+
+// newTextRenderer draws text into views of the given format, which needn't
+// be the scene's.
+func newTextRenderer(s *State, format wgpu.TextureFormat) (t *textRenderer, err error) {
+	t = &textRenderer{
+		device: s.device,
+		queue:  s.queue,
+		encode: !isSrgb(format) && format != hdrFormat,
+		pixel:  1,
+	}
+	defer func() {
+		if err != nil {
+			t.Release()
+		}
+	}()
+
+	shader := s.createShader("text shader", textShader)
+	defer shader.Release()
+
+	t.layout, err = s.bindGroupLayout("text",
+		bufferEntry(0, wgpu.ShaderStage_Vertex|wgpu.ShaderStage_Fragment, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Vertex, wgpu.BufferBindingType_ReadOnlyStorage),
+		wgpu.BindGroupLayoutEntry{
+			Binding:    2,
+			Visibility: wgpu.ShaderStage_Fragment,
+			Texture: wgpu.TextureBindingLayout{
+				SampleType:    wgpu.TextureSampleType_UnfilterableFloat,
+				ViewDimension: wgpu.TextureViewDimension_2D,
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	t.pipelineLayout, err = s.pipelineLayout("text", t.layout)
+	if err != nil {
+		return nil, err
+	}
+	t.pipeline, err = s.device.CreateRenderPipeline(&wgpu.RenderPipelineDescriptor{
+		Label:  "text",
+		Layout: t.pipelineLayout,
+		Vertex: wgpu.VertexState{
+			Module:     shader,
+			EntryPoint: "main_vs",
+		},
+		Fragment: &wgpu.FragmentState{
+			Module:     shader,
+			EntryPoint: "main_fs",
+			Targets: []wgpu.ColorTargetState{{
+				Format:    format,
+				WriteMask: wgpu.ColorWriteMask_All,
+				Blend: &wgpu.BlendState{
+					Color: wgpu.BlendComponent{Operation: wgpu.BlendOperation_Add, SrcFactor: wgpu.BlendFactor_SrcAlpha, DstFactor: wgpu.BlendFactor_OneMinusSrcAlpha},
+					Alpha: wgpu.BlendComponent{Operation: wgpu.BlendOperation_Add, SrcFactor: wgpu.BlendFactor_Zero, DstFactor: wgpu.BlendFactor_One},
+				},
+			}},
+		},
+		Primitive: wgpu.PrimitiveState{
+			Topology: wgpu.PrimitiveTopology_TriangleList,
+		},
+		Multisample: wgpu.MultisampleState{
+			Count: 1,
+			Mask:  0xFFFFFFFF,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	size := wgpu.Extent3D{Width: glyphWidth * uint32(len(fontGlyphs)), Height: glyphHeight, DepthOrArrayLayers: 1}
+	t.atlas, err = s.device.CreateTexture(&wgpu.TextureDescriptor{
+		Label:         "font atlas",
+		Usage:         wgpu.TextureUsage_TextureBinding | wgpu.TextureUsage_CopyDst,
+		Dimension:     wgpu.TextureDimension_2D,
+		Size:          size,
+		Format:        wgpu.TextureFormat_R8Unorm,
+		MipLevelCount: 1,
+		SampleCount:   1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.queue.WriteTexture(t.atlas.AsImageCopy(), fontAtlas(),
+		&wgpu.TextureDataLayout{BytesPerRow: size.Width, RowsPerImage: glyphHeight}, &size)
+	if err != nil {
+		return nil, err
+	}
+	t.atlasView, err = t.atlas.CreateView(nil)
+	if err != nil {
+		return nil, err
+	}
+	t.params = s.uniformBuffer("text params", make([]byte, 16))
+	return t, t.reserve(256)
+}
+
+// reserve makes room for at least n quads.
+func (t *textRenderer) reserve(n int) (err error) {
+	if n <= t.capacity {
+		return nil
+	}
+	t.releaseQuads()
+	t.capacity = max(n, 2*t.capacity)
+	t.quads, err = t.device.CreateBuffer(&wgpu.BufferDescriptor{
+		Label: "text quads",
+		Size:  uint64(t.capacity) * 24,
+		Usage: wgpu.BufferUsage_Storage | wgpu.BufferUsage_CopyDst,
+	})
+	if err != nil {
+		return err
+	}
+	t.set, err = t.device.CreateBindGroup(&wgpu.BindGroupDescriptor{
+		Label:  "text",
+		Layout: t.layout,
+		Entries: []wgpu.BindGroupEntry{
+			{Binding: 0, Buffer: t.params, Size: wgpu.WholeSize},
+			{Binding: 1, Buffer: t.quads, Size: wgpu.WholeSize},
+			{Binding: 2, TextureView: t.atlasView},
+		},
+	})
+	return err
+}
+
+// setScale has font pixels drawn pixel framebuffer pixels wide, rounded to
+// a whole number so that they stay sharp.
+func (t *textRenderer) setScale(pixel float32) {
+	t.pixel = float32(math.Max(1, math.Round(float64(pixel))))
+}
+
+// lineHeight is how far apart lines are in framebuffer pixels.
+func (t *textRenderer) lineHeight() float32 {
+	return (glyphHeight + 3) * t.pixel
+}
+
+// textWidth is how wide text is drawn, in framebuffer pixels.
+func (t *textRenderer) textWidth(text string) float32 {
+	return float32(utf8.RuneCountInString(text)) * (glyphWidth + 1) * t.pixel
+}
+
+// clear forgets everything added since the last clear.
+func (t *textRenderer) clear() {
+	t.instances = t.instances[:0]
+}
+
+// addRect adds a rectangle with its top left corner at x, y, in
+// framebuffer pixels.
+func (t *textRenderer) addRect(x, y, width, height float32, colour [4]float32) {
+	t.instances = append(t.instances, quadInstance{x, y, width, height, noGlyph, packColour(colour)})
+}
+
+// addText adds a line of text from x, y, the top left corner of its first
+// glyph.
+func (t *textRenderer) addText(x, y float32, colour [4]float32, text string) {
+	packed := packColour(colour)
+	for i, r := range []rune(text) {
+		if r != ' ' {
+			at := x + float32(i)*(glyphWidth+1)*t.pixel
+			t.instances = append(t.instances, quadInstance{at, y, glyphWidth * t.pixel, glyphHeight * t.pixel, glyphIndex(r), packed})
+		}
+	}
+}
+
+// addShadowedText is addText over a black shadow a font pixel down and to
+// the right, so that it reads over any simulation.
+func (t *textRenderer) addShadowedText(x, y float32, colour [4]float32, text string) {
+	t.addText(x+t.pixel, y+t.pixel, textShadow, text)
+	t.addText(x, y, colour, text)
+}
+
+// upload sends what has been added to the GPU, to be drawn from then on.
+func (t *textRenderer) upload() error {
+	if len(t.instances) == 0 {
+		return nil
+	}
+	if err := t.reserve(len(t.instances)); err != nil {
+		return err
+	}
+	return t.queue.WriteBuffer(t.quads, 0, wgpu.ToBytes(t.instances))
+}
+
+// setLines replaces what is drawn with lines of white text from x, y, the
+// top left corner in framebuffer pixels, in font pixels pixel framebuffer
+// pixels wide.
+func (t *textRenderer) setLines(x, y, pixel float32, lines []string) error {
+	t.setScale(pixel)
+	t.clear()
+	for i, line := range lines {
+		t.addShadowedText(x, y+float32(i)*t.lineHeight(), textWhite, line)
+	}
+	return t.upload()
+}
+
+// draw records the text over what is already in a width by height view.
+func (t *textRenderer) draw(encoder *commandEncoder, view *wgpu.TextureView, width, height uint32) {
+	if len(t.instances) == 0 {
+		return
+	}
+	if t.size != [2]uint32{width, height} {
+		t.size = [2]uint32{width, height}
+		encode := uint32(0)
+		if t.encode {
+			encode = 1
+		}
+		t.queue.WriteBuffer(t.params, 0, wgpu.ToBytes([]uint32{math.Float32bits(float32(width)), math.Float32bits(float32(height)), encode, 0}))
+	}
+	attachment := attachColourToView(view, wgpu.Color{})
+	attachment.LoadOp = wgpu.LoadOp_Load
+	pass := encoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
+		ColorAttachments: []wgpu.RenderPassColorAttachment{attachment},
+	})
+	defer pass.Release()
+
+	pass.SetPipeline(t.pipeline)
+	pass.SetBindGroup(0, t.set, nil)
+	pass.Draw(6, uint32(len(t.instances)), 0, 0)
+	pass.End()
+}
+
+func (t *textRenderer) releaseQuads() {
+	if t.set != nil {
+		t.set.Release()
+		t.set = nil
+	}
+	if t.quads != nil {
+		t.quads.Release()
+		t.quads = nil
+	}
+}
+
+func (t *textRenderer) Release() {
+	t.releaseQuads()
+	if t.params != nil {
+		t.params.Release()
+		t.params = nil
+	}
+	if t.atlasView != nil {
+		t.atlasView.Release()
+		t.atlasView = nil
+	}
+	if t.atlas != nil {
+		t.atlas.Release()
+		t.atlas = nil
+	}
+	if t.pipeline != nil {
+		t.pipeline.Release()
+		t.pipeline = nil
+	}
+	if t.pipelineLayout != nil {
+		t.pipelineLayout.Release()
+		t.pipelineLayout = nil
+	}
+	if t.layout != nil {
+		t.layout.Release()
+		t.layout = nil
+	}
+}
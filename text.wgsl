@@ -0,0 +1,70 @@
+struct Params {
+  // size is the view's, in framebuffer pixels.
+  size: vec2<f32>,
+  // encode applies the sRGB transfer function the view doesn't.
+  encode: u32,
+};
+
+// A Quad is a glyph from the atlas, or a solid rectangle when its glyph is
+// noGlyph, in one colour.
+struct Quad {
+  // corner is the top left, in framebuffer pixels from the view's.
+  corner: vec2<f32>,
+  size: vec2<f32>,
+  glyph: u32,
+  colour: u32,
+};
+
+@group(0) @binding(0) var<uniform> params: Params;
+@group(0) @binding(1) var<storage, read> quads: array<Quad>;
+@group(0) @binding(2) var atlas: texture_2d<f32>;
+
+const noGlyph = 0xFFFFFFFFu;
+
+// glyphSize is glyphWidth and glyphHeight in text.go.
+const glyphSize = vec2<i32>(5, 7);
+
+struct VertexOutput {
+  @builtin(position) position: vec4<f32>,
+  // uv runs from 0 to 1 across the quad.
+  @location(0) uv: vec2<f32>,
+  @location(1) @interpolate(flat) glyph: u32,
+  @location(2) @interpolate(flat) colour: u32,
+};
+
+@vertex
+fn main_vs(@builtin(vertex_index) v: u32, @builtin(instance_index) i: u32) -> VertexOutput {
+  var corners = array<vec2<f32>, 6>(
+    vec2(0.0, 0.0), vec2(0.0, 1.0), vec2(1.0, 0.0),
+    vec2(1.0, 0.0), vec2(0.0, 1.0), vec2(1.0, 1.0),
+  );
+  let q = quads[i];
+  let p = (q.corner + corners[v] * q.size) / params.size;
+  var out: VertexOutput;
+  out.position = vec4<f32>(p.x * 2.0 - 1.0, 1.0 - p.y * 2.0, 0.0, 1.0);
+  out.uv = corners[v];
+  out.glyph = q.glyph;
+  out.colour = q.colour;
+  return out;
+}
+
+fn encode(c: vec3<f32>) -> vec3<f32> {
+  let low = c * 12.92;
+  let high = 1.055 * pow(c, vec3(1.0 / 2.4)) - 0.055;
+  return select(high, low, c <= vec3(0.0031308));
+}
+
+@fragment
+fn main_fs(in: VertexOutput) -> @location(0) vec4<f32> {
+  if in.glyph != noGlyph {
+    let t = min(vec2<i32>(in.uv * vec2<f32>(glyphSize)), glyphSize - 1);
+    if textureLoad(atlas, vec2(i32(in.glyph) * glyphSize.x + t.x, t.y), 0).r < 0.5 {
+      discard;
+    }
+  }
+  var c = unpack4x8unorm(in.colour);
+  if params.encode != 0u {
+    c = vec4<f32>(encode(c.rgb), c.a);
+  }
+  return c;
+}
@@ -0,0 +1,39 @@
+// Copies the life cells into a texture for texture_draw.wgsl. A texel has
+// the cell's species in its low 8 bits, 0 for dead, and above them its age
+// while it is alive, or its trail to 16 bits once it has died.
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<storage> cells: array<u32>;
+@group(0) @binding(2) var<storage> ages: array<u32>;
+@group(0) @binding(3) var<storage> trail: array<f32>;
+// life.w is 1 when there are trails.
+@group(0) @binding(4) var<uniform> life: vec4<u32>;
+@group(0) @binding(5) var texels: texture_storage_2d<r32uint, write>;
+
+@compute @workgroup_size(16, 16)
+fn unpacked(@builtin(global_invocation_id) id: vec3<u32>) {
+  let size = vec2<u32>(grid);
+  if id.x >= size.x || id.y >= size.y {
+    return;
+  }
+  let i = id.y * size.x + id.x;
+  let species = cells[i];
+  var high = min(ages[i], 0xFFFFFFu);
+  if species == 0u {
+    high = 0u;
+    if life.w == 1u {
+      high = u32(clamp(trail[i], 0.0, 1.0) * 65535.0 + 0.5);
+    }
+  }
+  textureStore(texels, vec2<i32>(id.xy), vec4<u32>(species | (high << 8u), 0u, 0u, 0u));
+}
+
+// Packed rows are (width + 31) / 32 words, cell x in bit x % 32.
+@compute @workgroup_size(16, 16)
+fn packed(@builtin(global_invocation_id) id: vec3<u32>) {
+  let size = vec2<u32>(grid);
+  if id.x >= size.x || id.y >= size.y {
+    return;
+  }
+  let word = cells[id.y * ((size.x + 31u) / 32u) + id.x / 32u];
+  textureStore(texels, vec2<i32>(id.xy), vec4<u32>((word >> (id.x % 32u)) & 1u, 0u, 0u, 0u));
+}
@@ -0,0 +1,69 @@
+// palette.wgsl and camera.wgsl go after this.
+//
+// Draws the whole life grid as one quad, colouring each fragment from the
+// texel of the cell under it the way draw.wgsl colours the cell's own quad.
+struct VertexOutput {
+  @builtin(position) pos: vec4<f32>,
+  // cell runs from 0 to the grid's size across it.
+  @location(0) cell: vec2<f32>,
+};
+
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<uniform> life: vec4<u32>;
+@group(0) @binding(2) var<uniform> palette: Palette;
+// texels are from texture_compute.wgsl.
+@group(0) @binding(3) var texels: texture_2d<u32>;
+@group(0) @binding(4) var gradient: texture_1d<f32>;
+
+// The tile is a cell's quad, 0.8 across, so it is scaled up to the grid's.
+@vertex
+fn main_vs(@location(0) pos: vec2<f32>) -> VertexOutput {
+  let corner = pos / 0.8;
+  var output: VertexOutput;
+  output.pos = camera * vec4<f32>(corner, 0.0, 1.0);
+  output.cell = (corner + 1.0) / 2.0 * grid;
+  return output;
+}
+
+@fragment
+fn main_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  // local runs from -1 to 1 across where the cell's quad would be, with
+  // the gap between quads outside it. Its derivatives come from cell,
+  // which unlike local carries on smoothly into the next cell.
+  let local = (fract(input.cell) * 2.0 - 1.0) / 0.8;
+  var coverage = cellShape(palette, local, fwidth(input.cell) * 2.5);
+  if abs(local.x) > 1.0 || abs(local.y) > 1.0 {
+    coverage = 0.0;
+  }
+  let at = min(vec2<i32>(input.cell), vec2<i32>(grid) - 1);
+  let texel = textureLoad(texels, at, 0).r;
+  let species = texel & 0xFFu;
+  let high = texel >> 8u;
+  let position = paletteColour(palette, vec2<f32>(at) / grid);
+
+  if species == 0u {
+    var fading = 0.0;
+    if life.w == 1u {
+      fading = f32(high) / 65535.0;
+    }
+    if fading <= 1.0 / 256.0 {
+      return palette.background;
+    }
+    return shapeColour(palette, position, 0.6 * fading * coverage);
+  }
+  if life.y > 1u {
+    let hue = f32(species - 1u) / f32(life.y) * 6.28318;
+    let colour = 0.55 + 0.45 * cos(vec3(hue, hue - 2.09440, hue + 2.09440));
+    var shade = 1.0;
+    if life.z == 1u {
+      shade = 1.0 - 0.7 * (1.0 - exp(-f32(high) / 64.0));
+    }
+    return shapeColour(palette, colour * shade, coverage);
+  }
+  if life.z == 1u {
+    let old = 1.0 - exp(-f32(high) / 64.0);
+    let last = i32(textureDimensions(gradient)) - 1;
+    return shapeColour(palette, textureLoad(gradient, i32(old * f32(last) + 0.5), 0).rgb, coverage);
+  }
+  return shapeColour(palette, position, coverage);
+}
@@ -0,0 +1,24 @@
+struct VertexOutput {
+  @builtin(position) pos: vec4<f32>,
+  @location(0) uv: vec2<f32>,
+};
+
+@group(0) @binding(0) var thumbnail: texture_2d<f32>;
+
+// One triangle covering the viewport, with uv running down from the top left
+// like the texture's rows.
+@vertex
+fn main_vs(@builtin(vertex_index) i: u32) -> VertexOutput {
+  let uv = vec2<f32>(f32((i << 1u) & 2u), f32(i & 2u));
+  var output: VertexOutput;
+  output.pos = vec4<f32>(uv * 2.0 - 1.0, 0.0, 1.0);
+  output.uv = vec2<f32>(uv.x, 1.0 - uv.y);
+  return output;
+}
+
+@fragment
+fn main_fs(input: VertexOutput) -> @location(0) vec4<f32> {
+  let size = vec2<f32>(textureDimensions(thumbnail));
+  let p = vec2<i32>(min(input.uv * size, size - 1.0));
+  return vec4<f32>(textureLoad(thumbnail, p, 0).rgb, 1.0);
+}
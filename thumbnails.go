@@ -0,0 +1,155 @@
+package main
+
+import (
+	_ "embed"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed thumbnail.wgsl
+var thumbnailShader string
+
+// thumbnails draw previews into textures of their own, which can then be
+// laid over the running simulation as opaque tiles. They own the previews
+// and release them along with everything else.
+type thumbnails struct {
+	layout         *wgpu.BindGroupLayout
+	pipelineLayout *wgpu.PipelineLayout
+	pipeline       *wgpu.RenderPipeline
+
+	previews   []*instance
+	textures   []*wgpu.Texture
+	views      []*wgpu.TextureView
+	bindGroups []*wgpu.BindGroup
+}
+
+func newThumbnails(s *State, previews []*instance) (t *thumbnails, err error) {
+	t = &thumbnails{previews: previews}
+	defer func() {
+		if err != nil {
+			t.Release()
+		}
+	}()
+
+	shader := s.createShader("thumbnail shader", thumbnailShader)
+	defer shader.Release()
+
+	t.layout, err = s.bindGroupLayout("thumbnail", wgpu.BindGroupLayoutEntry{
+		Binding:    0,
+		Visibility: wgpu.ShaderStage_Fragment,
+		Texture: wgpu.TextureBindingLayout{
+			SampleType:    wgpu.TextureSampleType_UnfilterableFloat,
+			ViewDimension: wgpu.TextureViewDimension_2D,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t.pipelineLayout, err = s.pipelineLayout("thumbnail", t.layout)
+	if err != nil {
+		return nil, err
+	}
+
+	t.pipeline, err = s.device.CreateRenderPipeline(&wgpu.RenderPipelineDescriptor{
+		Label:  "thumbnail",
+		Layout: t.pipelineLayout,
+		Vertex: wgpu.VertexState{
+			Module:     shader,
+			EntryPoint: "main_vs",
+		},
+		Fragment: &wgpu.FragmentState{
+			Module:     shader,
+			EntryPoint: "main_fs",
+			Targets: []wgpu.ColorTargetState{
+				{Format: s.config.Format, WriteMask: wgpu.ColorWriteMask_All},
+			},
+		},
+		Primitive: wgpu.PrimitiveState{
+			Topology: wgpu.PrimitiveTopology_TriangleList,
+		},
+		Multisample: s.multisampleState(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range previews {
+		// The preview's own config is the size of its thumbnail.
+		texture, view, err := p.state.renderTexture("thumbnail", s.config.Format)
+		if err != nil {
+			return nil, err
+		}
+		t.textures = append(t.textures, texture)
+		t.views = append(t.views, view)
+
+		bindGroup, err := s.device.CreateBindGroup(&wgpu.BindGroupDescriptor{
+			Label:   "thumbnail",
+			Layout:  t.layout,
+			Entries: []wgpu.BindGroupEntry{{Binding: 0, TextureView: view}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		t.bindGroups = append(t.bindGroups, bindGroup)
+	}
+	return t, nil
+}
+
+func (t *thumbnails) Step(encoder *commandEncoder) {
+	for _, p := range t.previews {
+		p.sim.Step(encoder)
+	}
+}
+
+// render draws each preview into its texture.
+func (t *thumbnails) render(encoder *commandEncoder) {
+	for i, p := range t.previews {
+		pass := encoder.BeginRenderPass(&wgpu.RenderPassDescriptor{
+			ColorAttachments: []wgpu.RenderPassColorAttachment{p.state.attachScene(t.views[i], t.textures[i].GetWidth(), t.textures[i].GetHeight(), p.state.palette.clear())},
+		})
+		p.sim.Draw(pass)
+		pass.End()
+		pass.Release()
+	}
+}
+
+// draw puts thumbnail i in the square at (x, y) from the top left of the
+// pass's target.
+func (t *thumbnails) draw(pass *renderPass, i int, x, y, size float32) {
+	pass.SetViewport(x, y, size, size, 0, 1)
+	pass.SetPipeline(t.pipeline)
+	pass.SetBindGroup(0, t.bindGroups[i], nil)
+	pass.Draw(3, 1, 0, 0)
+}
+
+func (t *thumbnails) Release() {
+	for _, bg := range t.bindGroups {
+		bg.Release()
+	}
+	t.bindGroups = nil
+	for _, v := range t.views {
+		v.Release()
+	}
+	t.views = nil
+	for _, tex := range t.textures {
+		tex.Release()
+	}
+	t.textures = nil
+	for _, p := range t.previews {
+		p.Release()
+	}
+	t.previews = nil
+	if t.pipeline != nil {
+		t.pipeline.Release()
+		t.pipeline = nil
+	}
+	if t.pipelineLayout != nil {
+		t.pipelineLayout.Release()
+		t.pipelineLayout = nil
+	}
+	if t.layout != nil {
+		t.layout.Release()
+		t.layout = nil
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+// runTimelapse steps the simulation without a window at the same pace as the
+// windowed loop and saves a frame every cfg.Timelapse.Minutes, until it is
+// interrupted. The frames can then be stitched into a video with ffmpeg.
+func runTimelapse(cfg *Config) (err error) {
+	t := cfg.Timelapse
+	if t.Width < 1 || t.Height < 1 {
+		return fmt.Errorf("time-lapse frame size %dx%d must be positive", t.Width, t.Height)
+	}
+	var ffmpeg string
+	if t.Video != "" {
+		if t.FPS < 1 {
+			return fmt.Errorf("time-lapse video needs a positive frame rate, got %d", t.FPS)
+		}
+		// Better to find out now than after a run of several days.
+		if ffmpeg, err = exec.LookPath("ffmpeg"); err != nil {
+			return fmt.Errorf("time-lapse video: %w", err)
+		}
+	}
+
+	s := &State{start: time.Now(), mainThread: newMainThread()}
+	defer s.Destroy()
+
+	s.instance = wgpu.CreateInstance(nil)
+	s.setDevice()
+	s.config = &wgpu.SwapChainDescriptor{
+		Format: wgpu.TextureFormat_RGBA8Unorm,
+		Width:  uint32(t.Width),
+		Height: uint32(t.Height),
+	}
+	if err := s.init(cfg); err != nil {
+		return err
+	}
+
+	dir := t.Dir
+	if dir == "" {
+		dir = "timelapse-" + s.start.UTC().Format("20060102T150405")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := s.manifest.AddArtifact("timelapse", dir); err != nil {
+		log.Println("writing run manifest:", err)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	interval := time.Duration(t.Minutes * float64(time.Minute))
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+
+	frames := 0
+	next := time.Now()
+	fmt.Printf("time-lapse of %s every %s into %s, interrupt to stop\n", cfg.Simulation, s.format.Duration(interval), dir)
+loop:
+	for {
+		if !time.Now().Before(next) {
+			frames++
+			if err := s.saveFrame(filepath.Join(dir, timelapseFrame(frames))); err != nil {
+				return err
+			}
+			fmt.Printf("frame %s at step %s\n", s.format.Count(int64(frames)), s.format.Count(int64(s.steps)))
+			next = next.Add(interval)
+		}
+
+		select {
+		case <-stop:
+			break loop
+		case <-tick.C:
+		}
+		s.mainThread.runQueued()
+
+		if err := s.stepHeadless(); err != nil {
+			return err
+		}
+	}
+
+	if t.Video == "" {
+		return nil
+	}
+	return s.stitchTimelapse(ffmpeg, dir, t.Video, t.FPS)
+}
+
+func timelapseFrame(n int) string {
+	return fmt.Sprintf("frame-%06d.png", n)
+}
+
+func (s *State) stepHeadless() error {
+	encoder, err := s.newEncoder()
+	if err != nil {
+		return err
+	}
+	defer encoder.Release()
+
+	s.step(encoder)
+
+	cmdBuffer, err := encoder.Finish(nil)
+	if err != nil {
+		return err
+	}
+	defer cmdBuffer.Release()
+
+	s.queue.Submit(cmdBuffer)
+	s.device.Poll(false, nil)
+	s.highlights.observe(s)
+	s.timeline.observe(s)
+	s.lastStats, *s.stats = *s.stats, frameStats{}
+	s.parameters.refresh(s)
+	s.session.update(s)
+	return nil
+}
+
+func (s *State) saveFrame(path string) error {
+	img, err := s.captureFrame()
+	if err != nil {
+		return err
+	}
+	return savePNG(path, img)
+}
+
+func (s *State) stitchTimelapse(ffmpeg, dir, video string, fps int) error {
+	cmd := exec.Command(ffmpeg, "-y", "-loglevel", "error",
+		"-framerate", strconv.Itoa(fps),
+		"-i", filepath.Join(dir, "frame-%06d.png"),
+		// yuv420p needs even dimensions and is what most players expect.
+		"-vf", "pad=ceil(iw/2)*2:ceil(ih/2)*2",
+		"-pix_fmt", "yuv420p",
+		video)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("stitching time-lapse: %w", err)
+	}
+	fmt.Println("wrote", video)
+	return s.manifest.AddArtifact("video", video)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// maxKeyframes is how many keyframes the timeline keeps before forgetting
+// the oldest.
+const maxKeyframes = 64
+
+// timeline keeps a keyframe of the cells every so many generations, so that
+// Alt+Left can scrub back through them: it goes back to the last keyframe
+// before where it is going and steps on from there. From the same cells
+// life always goes the same way, so the generations come out as they were.
+// Like the highlighter it does nothing when nil.
+type timeline struct {
+	every int
+	// keyframes are by generation, of which next is the one to keep next.
+	keyframes []*snapshot
+	next      int
+}
+
+// newTimeline returns nil when every is 0.
+func newTimeline(every int) *timeline {
+	if every <= 0 {
+		return nil
+	}
+	return &timeline{every: every}
+}
+
+// observe is called after every step, keeping a keyframe when one is due.
+func (t *timeline) observe(s *State) {
+	if t == nil || s.steps < t.next {
+		return
+	}
+	t.keep(s)
+}
+
+// keep makes the generation showing a keyframe, in place of any after it,
+// which were from before an edit. All of them go if they were of another
+// grid.
+func (t *timeline) keep(s *State) {
+	sn, ok := s.sim.(snapshotter)
+	if !ok {
+		t.keyframes = nil
+		return
+	}
+	if len(t.keyframes) > 0 && !sn.fits(t.keyframes[0]) {
+		t.keyframes = nil
+	}
+	snap, err := sn.snapshot(s)
+	if err != nil {
+		log.Println("keeping a keyframe:", err)
+		return
+	}
+	snap.Simulation, snap.Generation, snap.Seed = s.cfg.Simulation, s.steps, s.seed
+	// Stepping on only needs the current generation.
+	snap.Cells[1], snap.Ages[1] = snap.Cells[0], snap.Ages[0]
+	i := sort.Search(len(t.keyframes), func(i int) bool { return t.keyframes[i].Generation >= s.steps })
+	t.keyframes = append(t.keyframes[:i], snap)
+	if len(t.keyframes) > maxKeyframes {
+		t.keyframes = t.keyframes[len(t.keyframes)-maxKeyframes:]
+	}
+	t.next = s.steps - s.steps%t.every + t.every
+}
+
+// edited is called when the cells change other than by stepping, keeping
+// them as they are now so that scrubbing back past them comes back to them.
+func (t *timeline) edited(s *State) {
+	if t == nil {
+		return
+	}
+	t.keep(s)
+}
+
+// restart forgets every keyframe and starts again from the generation
+// showing.
+func (t *timeline) restart(s *State) {
+	if t == nil {
+		return
+	}
+	t.keyframes = nil
+	t.keep(s)
+}
+
+// scrubBack goes n generations back, or as far as the timeline goes. What
+// comes after is stepped again from there, so any keyframes after it are
+// let go of.
+func (s *State) scrubBack(n int) error {
+	t := s.timeline
+	if t == nil {
+		return fmt.Errorf("there is no timeline to scrub, -keyframe-every is 0")
+	}
+	sn, ok := s.sim.(snapshotter)
+	if !ok || len(t.keyframes) == 0 || !sn.fits(t.keyframes[0]) {
+		t.keyframes = nil
+		return fmt.Errorf("there is nothing to scrub back to")
+	}
+	target := max(s.steps-n, t.keyframes[0].Generation)
+	i := sort.Search(len(t.keyframes), func(i int) bool { return t.keyframes[i].Generation > target }) - 1
+	from := t.keyframes[i]
+	t.keyframes = t.keyframes[:i+1]
+	if err := sn.restore(s, from); err != nil {
+		return err
+	}
+	s.steps = from.Generation
+	t.next = s.steps - s.steps%t.every + t.every
+	s.highlights.restart()
+	return s.stepNow(target - from.Generation)
+}
+
+// handleTimelineKey scrubs a generation back with Alt+Left and forward with
+// Alt+Right, or ten with Shift as well, pausing first.
+func (s *State) handleTimelineKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if key != glfw.KeyLeft && key != glfw.KeyRight || action == glfw.Release || mods&glfw.ModAlt == 0 {
+		return
+	}
+	if _, browsing := s.mode.(sceneMode); browsing {
+		return
+	}
+	if !s.paused {
+		s.paused = true
+		s.updateTitle()
+	}
+	n := 1
+	if mods&glfw.ModShift != 0 {
+		n = 10
+	}
+	var err error
+	if key == glfw.KeyLeft {
+		err = s.scrubBack(n)
+	} else {
+		err = s.stepNow(n)
+	}
+	if err != nil {
+		fmt.Println("scrubbing:", err)
+	}
+}
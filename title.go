@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// titleInterval is how often the counts in the title bar are brought up to
+// date.
+const titleInterval = time.Second
+
+// titleCounts are the generation, population and rates the title bar
+// shows after the simulation's name, over the last titleInterval.
+type titleCounts struct {
+	text string
+	// frames have been presented, and steps was the generation, since the
+	// counts were taken at updated.
+	frames  int
+	steps   int
+	updated time.Time
+}
+
+// update counts a frame, and takes the counts again once titleInterval has
+// passed since they last were.
+func (t *titleCounts) update(s *State) {
+	if s.window == nil {
+		return
+	}
+	t.frames++
+	elapsed := time.Since(t.updated)
+	if elapsed < titleInterval {
+		return
+	}
+	if !t.updated.IsZero() {
+		parts := []string{"generation " + s.format.Count(int64(s.steps))}
+		if c, ok := s.sim.(populationCounter); ok {
+			if p, err := c.Population(s); err != nil {
+				fmt.Println("counting the population:", err)
+			} else {
+				parts = append(parts, "population "+s.format.Float(p, 0))
+			}
+		}
+		// Starting again or scrubbing back goes back in generations.
+		ticks := float64(max(s.steps-t.steps, 0)) / elapsed.Seconds()
+		parts = append(parts,
+			s.format.Float(ticks, 0)+" generations/s",
+			s.format.Float(float64(t.frames)/elapsed.Seconds(), 0)+" fps")
+		t.text = strings.Join(parts, ", ")
+		s.updateTitle()
+	}
+	t.frames, t.steps, t.updated = 0, s.steps, time.Now()
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+//go:embed trail.wgsl
+var trailCompute string
+
+// trail remembers where life cells have been alive, fading by fade a
+// generation after they die, for the draw shaders to leave ghostly tracks
+// behind moving patterns.
+type trail struct {
+	layout         *wgpu.BindGroupLayout
+	pipelineLayout *wgpu.PipelineLayout
+	pipeline       *wgpu.ComputePipeline
+	params         *wgpu.Buffer
+	buffer         *wgpu.Buffer
+	// sets read the cells from each of life's cell buffers.
+	sets          []*wgpu.BindGroup
+	width, height int
+	fade          float32
+}
+
+func newTrail(s *State, fade float32) (t *trail, err error) {
+	if fade <= 0 || fade > 1 {
+		return nil, fmt.Errorf("trail fade %g out of range (0, 1]", fade)
+	}
+	t = &trail{fade: fade}
+	defer func() {
+		if err != nil {
+			t.Release()
+		}
+	}()
+
+	shader := s.createShader("trail shader", trailCompute)
+	defer shader.Release()
+
+	t.layout, err = s.bindGroupLayout("trail",
+		bufferEntry(0, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+		bufferEntry(1, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_ReadOnlyStorage),
+		bufferEntry(2, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Storage),
+		bufferEntry(3, wgpu.ShaderStage_Compute, wgpu.BufferBindingType_Uniform),
+	)
+	if err != nil {
+		return nil, err
+	}
+	t.pipelineLayout, err = s.pipelineLayout("trail", t.layout)
+	if err != nil {
+		return nil, err
+	}
+	t.pipeline, err = s.computePipeline("trail", t.pipelineLayout, shader, "main")
+	if err != nil {
+		return nil, err
+	}
+	t.params = s.uniformBuffer("trail params", wgpu.ToBytes([]float32{1 - fade, 0, 0, 0}))
+	return t, nil
+}
+
+// setCells starts the trail over for cells, which are life's pair of width
+// by height cell buffers.
+func (t *trail) setCells(s *State, cells []*wgpu.Buffer, width, height int) {
+	t.releaseCells()
+	t.width, t.height = width, height
+	t.buffer = s.storageBuffer(make([]byte, 4*width*height))
+	t.sets = make([]*wgpu.BindGroup, len(cells))
+	for i, c := range cells {
+		t.sets[i] = s.bindGroup("trail", t.layout, s.gridBuffer, c, t.buffer, t.params)
+	}
+}
+
+// setFade changes how quickly the trail fades, keeping it in (0, 1].
+func (t *trail) setFade(queue *uploadQueue, fade float32) {
+	t.fade = min(max(fade, 1.0/1024), 1)
+	queue.WriteBuffer(t.params, 0, wgpu.ToBytes([]float32{1 - t.fade}))
+}
+
+// step fades the trail and fills it in again under the cells in buffer
+// current of the pair.
+func (t *trail) step(encoder *commandEncoder, current int) {
+	pass := encoder.BeginComputePass(nil)
+	defer pass.Release()
+	pass.SetPipeline(t.pipeline)
+	pass.SetBindGroup(0, t.sets[current], nil)
+	pass.DispatchWorkgroups(uint32(t.width+15)/16, uint32(t.height), 1)
+	pass.End()
+}
+
+func (t *trail) releaseCells() {
+	for _, bg := range t.sets {
+		bg.Release()
+	}
+	t.sets = nil
+	if t.buffer != nil {
+		t.buffer.Release()
+		t.buffer = nil
+	}
+}
+
+func (t *trail) Release() {
+	t.releaseCells()
+	if t.params != nil {
+		t.params.Release()
+		t.params = nil
+	}
+	if t.pipeline != nil {
+		t.pipeline.Release()
+		t.pipeline = nil
+	}
+	if t.pipelineLayout != nil {
+		t.pipelineLayout.Release()
+		t.pipelineLayout = nil
+	}
+	if t.layout != nil {
+		t.layout.Release()
+		t.layout = nil
+	}
+}
@@ -0,0 +1,16 @@
+@group(0) @binding(0) var<uniform> grid: vec2<f32>;
+@group(0) @binding(1) var<storage> cells: array<u32>;
+// trail is 1 where a cell is alive, fading towards 0 once it dies.
+@group(0) @binding(2) var<storage, read_write> trail: array<f32>;
+// keep is how much of the trail is left after each generation.
+@group(0) @binding(3) var<uniform> keep: vec4<f32>;
+
+@compute
+@workgroup_size(16)
+fn main(@builtin(global_invocation_id) id: vec3<u32>) {
+  if id.x >= u32(grid.x) || id.y >= u32(grid.y) {
+    return;
+  }
+  let i = id.y * u32(grid.x) + id.x;
+  trail[i] = select(trail[i] * keep.x, 1.0, cells[i] > 0u);
+}
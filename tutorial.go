@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+type tutorialStep struct {
+	prompt string
+	want   string
+}
+
+var tutorialSteps = []tutorialStep{
+	{"Paint some cells with the mouse", actionPaint},
+	{"Stamp a glider onto the grid", actionStamp},
+	{"Change how fast the simulation runs", actionSpeed},
+	{"Switch to a different rule", actionRule},
+}
+
+// tutorialMode walks through the controls one prompt at a time, moving on
+// when the user performs the action the prompt asks for.
+type tutorialMode struct {
+	step int
+}
+
+// Enter carries on from the current step, so the tutorial picks up where it
+// was after a visit to the rule browser.
+func (t *tutorialMode) Enter(s *State) {
+	t.prompt(s)
+}
+
+func (t *tutorialMode) Exit(s *State) {}
+
+func (t *tutorialMode) prompt(s *State) {
+	s.showPrompt(fmt.Sprintf("Tutorial %d/%d: %s (Enter skips, Esc quits)",
+		t.step+1, len(tutorialSteps), tutorialSteps[t.step].prompt))
+}
+
+func (t *tutorialMode) advance(s *State) {
+	t.step++
+	if t.step == len(tutorialSteps) {
+		fmt.Println("Tutorial complete")
+		s.setMode(normalMode{})
+		return
+	}
+	t.prompt(s)
+}
+
+func (t *tutorialMode) HandleKey(s *State, key glfw.Key, action glfw.Action, mods glfw.ModifierKey) bool {
+	if action != glfw.Press {
+		return false
+	}
+	switch key {
+	case glfw.KeyEnter:
+		t.advance(s)
+		return true
+	case glfw.KeyEscape:
+		s.setMode(normalMode{})
+		return true
+	case glfw.KeyTab:
+		s.openRuleBrowser()
+		return true
+	}
+	return false
+}
+
+func (t *tutorialMode) OnAction(s *State, action string) {
+	if action == tutorialSteps[t.step].want {
+		t.advance(s)
+	}
+}
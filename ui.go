@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/rajveermalviya/go-webgpu/wgpu"
+)
+
+var (
+	uiPanel    = [4]float32{0.01, 0.01, 0.015, 0.85}
+	uiControl  = [4]float32{0.05, 0.05, 0.07, 1}
+	uiHover    = [4]float32{0.1, 0.1, 0.14, 1}
+	uiSelected = [4]float32{0.08, 0.2, 0.55, 1}
+	uiDim      = [4]float32{0.45, 0.45, 0.5, 1}
+)
+
+// uiColumns is how many glyphs wide the panel is.
+const uiColumns = 44
+
+// debugUI is a panel of controls down the right of the window, laid out
+// afresh every frame from what it controls, in the manner of an immediate
+// mode GUI: a widget is a function call that draws it and says whether it
+// was used. It has sliders for the tick rate, the starting density and
+// every parameter the dashboard has, buttons for the simulation and the
+// palette, and the resource report R prints, kept up to date. ` shows and
+// hides it.
+type debugUI struct {
+	shown bool
+	text  *textRenderer
+
+	// The cursor, in framebuffer pixels, and whether the left button is
+	// down now and was at the last frame.
+	mouseX, mouseY float32
+	down, wasDown  bool
+	// active is the slider being dragged, and over whether the cursor was
+	// over the panel.
+	active string
+	over   bool
+	// density is what Reseed starts the simulation at.
+	density float64
+	// scrolled is how far the panel has been scrolled up, for windows too
+	// short for all of it.
+	scrolled float32
+
+	// The panel's left edge and width, and how far down it has been laid
+	// out, all in framebuffer pixels.
+	left, width, y float32
+	unit           float32
+}
+
+func newDebugUI(s *State) (*debugUI, error) {
+	text, err := newTextRenderer(s, s.surfaceFormat)
+	if err != nil {
+		return nil, err
+	}
+	return &debugUI{text: text, density: s.cfg.Init.Density}, nil
+}
+
+// capturesMouse is whether the panel, rather than the simulation or the
+// camera, gets what the mouse does.
+func (u *debugUI) capturesMouse() bool {
+	return u != nil && u.shown && (u.over || u.active != "")
+}
+
+// readMouse takes the cursor from the window, from screen coordinates to
+// framebuffer pixels.
+func (u *debugUI) readMouse(s *State) {
+	if s.window == nil {
+		return
+	}
+	u.wasDown = u.down
+	x, y := s.window.GetCursorPos()
+	width, height := s.window.GetSize()
+	if width > 0 && height > 0 {
+		u.mouseX = float32(x) * float32(s.config.Width) / float32(width)
+		u.mouseY = float32(y) * float32(s.config.Height) / float32(height)
+	}
+	u.down = s.window.GetMouseButton(glfw.MouseButtonLeft) == glfw.Press
+}
+
+func (u *debugUI) inside(x, y, width, height float32) bool {
+	return u.mouseX >= x && u.mouseX < x+width && u.mouseY >= y && u.mouseY < y+height
+}
+
+func (u *debugUI) pressed() bool {
+	return u.down && !u.wasDown
+}
+
+// update lays the panel out and does what its widgets were used for, once
+// a frame while it is shown.
+func (u *debugUI) update(s *State) {
+	if u == nil || !u.shown {
+		return
+	}
+	u.readMouse(s)
+	if !u.down {
+		u.active = ""
+	}
+
+	t := u.text
+	t.setScale(s.scaled(2))
+	u.unit = t.pixel
+	u.width = t.textWidth(strings.Repeat(" ", uiColumns)) + 4*u.unit
+	u.left = float32(s.config.Width) - u.width - s.scaled(hudMargin)
+	top := s.scaled(hudMargin)
+	u.y = top + 2*u.unit - u.scrolled
+	t.clear()
+	// The panel is drawn behind everything, once it is known how tall it
+	// is.
+	t.addRect(0, 0, 0, 0, uiPanel)
+
+	u.heading("run")
+	// The slider's 0 is as fast as it can.
+	if rate, ok := u.slider("tick rate", "tick rate", s.tickRate, 0, maxTickRate, 0); ok && math.Round(rate) != s.tickRate {
+		s.setTickRate(math.Round(rate))
+	}
+	if d, ok := u.slider("density", "density", u.density, 0, 1, 2); ok {
+		u.density = d
+	}
+	if u.button("reseed") {
+		cfg := *s.cfg
+		cfg.Init.Density = u.density
+		cfg.Grid = GridConfig{Width: s.gridWidth, Height: s.gridHeight}
+		if err := s.switchSimulation(&cfg); err != nil {
+			fmt.Println("reseeding:", err)
+		}
+	}
+
+	u.heading("simulation")
+	if name, ok := u.choice(simulationNames(), s.cfg.Simulation); ok {
+		cfg := *s.cfg
+		cfg.Simulation = name
+		cfg.Grid = GridConfig{Width: s.gridWidth, Height: s.gridHeight}
+		if err := s.switchSimulation(&cfg); err != nil {
+			fmt.Println("switching simulation:", err)
+		} else {
+			s.emit(actionRule)
+		}
+	}
+
+	u.heading("palette")
+	if name, ok := u.choice(configPaletteNames(s.cfg), s.cfg.Palette); ok {
+		if err := s.setPalette(name); err != nil {
+			fmt.Println("switching palette:", err)
+		}
+	}
+
+	if params := s.parameters.snapshot().Parameters; len(params) > 0 {
+		u.heading("parameters")
+		for _, p := range params {
+			if v, ok := u.slider("param "+p.Name, p.Name, p.Value, p.Min, p.Max, 3); ok {
+				if err := s.setParameter(p.Name, v); err != nil {
+					fmt.Println("setting parameter:", err)
+				}
+			}
+		}
+	}
+
+	u.heading("resources")
+	for _, line := range reportLines(s.instance.GenerateReport()) {
+		u.label(line, textWhite)
+	}
+
+	height := u.y + u.scrolled + 2*u.unit - top
+	t.instances[0] = quadInstance{u.left, top - u.scrolled, u.width, height, noGlyph, packColour(uiPanel)}
+	u.over = u.inside(u.left, top-u.scrolled, u.width, height)
+	u.scrolled = max(0, min(u.scrolled, height+2*top-float32(s.config.Height)))
+	if err := t.upload(); err != nil {
+		fmt.Println("laying out the debug panel:", err)
+	}
+}
+
+// scroll moves the panel up by notches of the mouse wheel.
+func (u *debugUI) scroll(notches float64) {
+	u.scrolled = max(0, u.scrolled-float32(notches)*u.row())
+}
+
+// row is the height of a line of widgets.
+func (u *debugUI) row() float32 {
+	return u.text.lineHeight() + 3*u.unit
+}
+
+func (u *debugUI) heading(title string) {
+	u.y += u.unit * 3
+	u.text.addText(u.left+2*u.unit, u.y, uiDim, strings.ToUpper(title))
+	u.y += u.text.lineHeight()
+}
+
+func (u *debugUI) label(text string, colour [4]float32) {
+	u.text.addText(u.left+2*u.unit, u.y, colour, text)
+	u.y += u.text.lineHeight()
+}
+
+// slider draws a bar filled as far as value is from lo to hi, with the name
+// and the value to prec decimal places on it. Dragging along it sets the
+// value, which it returns with true while it is changing; id tells sliders
+// apart from one frame to the next.
+func (u *debugUI) slider(id, name string, value, lo, hi float64, prec int) (float64, bool) {
+	x, width, height := u.left+2*u.unit, u.width-4*u.unit, u.row()-u.unit
+	y := u.y
+	u.y += u.row()
+	if u.pressed() && u.inside(x, y, width, height) {
+		u.active = id
+	}
+	changed := false
+	if u.active == id {
+		at := float64(max(0, min((u.mouseX-x)/width, 1)))
+		if v := lo + at*(hi-lo); v != value {
+			value, changed = v, true
+		}
+	}
+	background := uiControl
+	if u.active == id || u.inside(x, y, width, height) {
+		background = uiHover
+	}
+	u.text.addRect(x, y, width, height, background)
+	if hi > lo {
+		fill := float32(max(0, min((value-lo)/(hi-lo), 1)))
+		u.text.addRect(x, y, width*fill, height, uiSelected)
+	}
+	top := y + (height-glyphHeight*u.unit)/2
+	u.text.addText(x+2*u.unit, top, textWhite, name)
+	shown := fmt.Sprintf("%.*f", prec, value)
+	u.text.addText(x+width-2*u.unit-u.text.textWidth(shown), top, textWhite, shown)
+	return value, changed
+}
+
+// button draws a button the width of the panel, returning whether it was
+// clicked.
+func (u *debugUI) button(name string) bool {
+	x, width := u.left+2*u.unit, u.width-4*u.unit
+	clicked := u.flowButton(x, u.y, width, name, false)
+	u.y += u.row()
+	return clicked
+}
+
+func (u *debugUI) flowButton(x, y, width float32, name string, selected bool) bool {
+	height := u.row() - u.unit
+	hovered := u.active == "" && u.inside(x, y, width, height)
+	colour := uiControl
+	switch {
+	case selected:
+		colour = uiSelected
+	case hovered:
+		colour = uiHover
+	}
+	u.text.addRect(x, y, width, height, colour)
+	u.text.addText(x+(width-u.text.textWidth(name))/2, y+(height-glyphHeight*u.unit)/2, textWhite, name)
+	return hovered && u.pressed()
+}
+
+// choice lays a button out for each of options, as many to a row as fit,
+// with selected's highlighted, returning the one clicked if any.
+func (u *debugUI) choice(options []string, selected string) (string, bool) {
+	gap := 2 * u.unit
+	x, right := u.left+gap, u.left+u.width-gap
+	clicked, ok := "", false
+	for _, option := range options {
+		width := u.text.textWidth(option) + 4*u.unit
+		if x+width > right && x > u.left+gap {
+			x = u.left + gap
+			u.y += u.row()
+		}
+		if u.flowButton(x, u.y, width, option, option == selected) && option != selected {
+			clicked, ok = option, true
+		}
+		x += width + gap
+	}
+	u.y += u.row()
+	return clicked, ok
+}
+
+// reportLines are how many of each kind of resource the report counts as
+// in use, for the backends it covers.
+func reportLines(r wgpu.GlobalReport) []string {
+	var lines []string
+	for _, hub := range []struct {
+		name   string
+		report *wgpu.HubReport
+	}{{"vulkan", r.Vulkan}, {"metal", r.Metal}, {"dx12", r.Dx12}, {"dx11", r.Dx11}, {"gl", r.Gl}} {
+		if hub.report == nil {
+			continue
+		}
+		h := hub.report
+		var counts []string
+		for _, c := range []struct {
+			name   string
+			report wgpu.StorageReport
+		}{
+			{"adapters", h.Adapters}, {"devices", h.Devices},
+			{"buffers", h.Buffers}, {"textures", h.Textures}, {"views", h.TextureViews},
+			{"samplers", h.Samplers}, {"shaders", h.ShaderModules},
+			{"bind groups", h.BindGroups}, {"layouts", h.BindGroupLayouts},
+			{"pipeline layouts", h.PipelineLayouts},
+			{"render pipelines", h.RenderPipelines}, {"compute pipelines", h.ComputePipelines},
+			{"command buffers", h.CommandBuffers}, {"query sets", h.QuerySets},
+		} {
+			if c.report.NumOccupied > 0 {
+				counts = append(counts, fmt.Sprintf("%d %s", c.report.NumOccupied, c.name))
+			}
+		}
+		lines = append(lines, hub.name+":")
+		// Two to a line, as many as fit across the panel.
+		for i := 0; i < len(counts); i += 2 {
+			line := "  " + counts[i]
+			if i+1 < len(counts) {
+				line += ", " + counts[i+1]
+			}
+			lines = append(lines, line)
+		}
+	}
+	return append(lines, fmt.Sprintf("%d surfaces", r.Surfaces.NumOccupied))
+}
+
+// draw records the panel over view, if it is shown.
+func (u *debugUI) draw(s *State, encoder *commandEncoder, view *wgpu.TextureView) {
+	if u == nil || !u.shown {
+		return
+	}
+	u.text.draw(encoder, view, s.config.Width, s.config.Height)
+}
+
+// handleDebugUIKey shows and hides the panel with `.
+func (s *State) handleDebugUIKey(key glfw.Key, action glfw.Action) {
+	if key != glfw.KeyGraveAccent || action != glfw.Press || s.ui == nil {
+		return
+	}
+	s.ui.shown = !s.ui.shown
+	s.ui.active, s.ui.over = "", false
+}
+
+func (u *debugUI) Release() {
+	if u.text != nil {
+		u.text.Release()
+		u.text = nil
+	}
+}
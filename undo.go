@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// maxUndo is how many edits Ctrl+Z can take back before the oldest are
+// forgotten.
+const maxUndo = 100
+
+// cellChange is cells written over a rectangle, with what was there before.
+type cellChange struct {
+	rect          cellRect
+	before, after []uint32
+}
+
+// edit is one thing done to the cells: a click or stroke of the brush, a
+// stamp or a clear. It belongs to the simulation and grid it was made on.
+type edit struct {
+	sim           Simulation
+	width, height int
+	changes       []cellChange
+}
+
+// editHistory is the edits Ctrl+Z undoes and Ctrl+Y redoes. Undoing writes
+// the cells back as they were over whatever generation is showing, and
+// redoing writes them as the edit left them.
+type editHistory struct {
+	done, undone []*edit
+	// open is set while the last edit can still grow, as a stroke does a
+	// frame at a time.
+	open bool
+}
+
+// edit writes cells over r, keeping before, what was there, to undo it. A
+// change that carries on the open edit is undone along with it.
+func (s *State) edit(e cellEditor, r cellRect, before, cells []uint32, carryOn bool) error {
+	if err := e.writeCells(s, r, cells); err != nil {
+		return err
+	}
+	h := &s.history
+	change := cellChange{rect: r, before: before, after: cells}
+	if last := len(h.done) - 1; carryOn && h.open && last >= 0 && h.done[last].sim == s.sim {
+		h.done[last].changes = append(h.done[last].changes, change)
+	} else {
+		h.done = append(h.done, &edit{sim: s.sim, width: s.gridWidth, height: s.gridHeight, changes: []cellChange{change}})
+		if len(h.done) > maxUndo {
+			h.done = h.done[len(h.done)-maxUndo:]
+		}
+	}
+	h.undone, h.open = nil, carryOn
+	s.timeline.edited(s)
+	return nil
+}
+
+// applies is whether ed was made on the cells there are now.
+func (s *State) applies(ed *edit) bool {
+	return ed.sim == s.sim && ed.width == s.gridWidth && ed.height == s.gridHeight
+}
+
+// undo takes the last edit back, returning false when there is none left.
+func (s *State) undo(e cellEditor) (bool, error) {
+	h := &s.history
+	h.open = false
+	if len(h.done) == 0 {
+		return false, nil
+	}
+	ed := h.done[len(h.done)-1]
+	if !s.applies(ed) {
+		h.done, h.undone = nil, nil
+		return false, nil
+	}
+	for i := len(ed.changes) - 1; i >= 0; i-- {
+		if err := e.writeCells(s, ed.changes[i].rect, ed.changes[i].before); err != nil {
+			return false, err
+		}
+	}
+	h.done = h.done[:len(h.done)-1]
+	h.undone = append(h.undone, ed)
+	s.timeline.edited(s)
+	return true, nil
+}
+
+// redo makes the last edit undone again, returning false when there is
+// none.
+func (s *State) redo(e cellEditor) (bool, error) {
+	h := &s.history
+	h.open = false
+	if len(h.undone) == 0 {
+		return false, nil
+	}
+	ed := h.undone[len(h.undone)-1]
+	if !s.applies(ed) {
+		h.done, h.undone = nil, nil
+		return false, nil
+	}
+	for _, c := range ed.changes {
+		if err := e.writeCells(s, c.rect, c.after); err != nil {
+			return false, err
+		}
+	}
+	h.undone = h.undone[:len(h.undone)-1]
+	h.done = append(h.done, ed)
+	s.timeline.edited(s)
+	return true, nil
+}
+
+// handleUndoKey undoes edits with Ctrl+Z and redoes them with Ctrl+Y or
+// Ctrl+Shift+Z.
+func (s *State) handleUndoKey(key glfw.Key, action glfw.Action, mods glfw.ModifierKey) {
+	if action == glfw.Release || mods&(glfw.ModControl|glfw.ModSuper) == 0 {
+		return
+	}
+	e, ok := s.editor()
+	if !ok {
+		return
+	}
+	var did bool
+	var err error
+	switch {
+	case key == glfw.KeyY || key == glfw.KeyZ && mods&glfw.ModShift != 0:
+		if did, err = s.redo(e); err == nil && !did {
+			fmt.Println("nothing to redo")
+		}
+	case key == glfw.KeyZ:
+		if did, err = s.undo(e); err == nil && !did {
+			fmt.Println("nothing to undo")
+		}
+	}
+	if err != nil {
+		fmt.Println("undoing:", err)
+	}
+}
@@ -0,0 +1,166 @@
+// Package viewmath is the coordinate maths the views of a grid share, kept
+// apart from the GPU so that it can be checked on its own.
+//
+// There are four spaces. Screen coordinates are the cursor's, from the top
+// left of the window, y down. Clip space runs from -1 to 1 across whatever
+// is being drawn into, y up. Grid space runs from -1 to 1 across the whole
+// grid, y up, and is what the simulations place their cells in before the
+// view camera takes them to clip space. Cells are counted from the bottom
+// left, cell (x, y) filling the square of grid space from -1+2x/width to
+// -1+2(x+1)/width across and likewise up.
+package viewmath
+
+import "math"
+
+// Vec2 is a point or a distance in any of the spaces.
+type Vec2 [2]float32
+
+// CursorToClip takes a cursor position in a width by height window to clip
+// space. It is false for an empty window.
+func CursorToClip(x, y float64, width, height int) (Vec2, bool) {
+	if width <= 0 || height <= 0 {
+		return Vec2{}, false
+	}
+	return Vec2{float32(2*x/float64(width) - 1), float32(1 - 2*y/float64(height))}, true
+}
+
+// CursorDelta takes a cursor movement in a width by height window to clip
+// space.
+func CursorDelta(dx, dy float64, width, height int) (Vec2, bool) {
+	if width <= 0 || height <= 0 {
+		return Vec2{}, false
+	}
+	return Vec2{float32(2 * dx / float64(width)), float32(-2 * dy / float64(height))}, true
+}
+
+// GridToCell is the cell of a width by height grid that grid space point p
+// is in. It is false outside the grid.
+func GridToCell(p Vec2, width, height int) (x, y int, ok bool) {
+	fx := math.Floor(float64(p[0]+1) / 2 * float64(width))
+	fy := math.Floor(float64(p[1]+1) / 2 * float64(height))
+	if fx < 0 || fy < 0 || fx >= float64(width) || fy >= float64(height) {
+		return 0, 0, false
+	}
+	return int(fx), int(fy), true
+}
+
+// CellToGrid is the centre of cell x, y of a width by height grid in grid
+// space.
+func CellToGrid(x, y, width, height int) Vec2 {
+	return Vec2{
+		(2*float32(x)+1)/float32(width) - 1,
+		(2*float32(y)+1)/float32(height) - 1,
+	}
+}
+
+// Letterbox is how much to shrink a grid of gridWidth by gridHeight cells
+// across and down to fit a targetWidth by targetHeight target without
+// stretching its square cells, one of the two always 1. Anything empty is
+// left as it is.
+func Letterbox(gridWidth, gridHeight, targetWidth, targetHeight int) Vec2 {
+	if gridWidth <= 0 || gridHeight <= 0 || targetWidth <= 0 || targetHeight <= 0 {
+		return Vec2{1, 1}
+	}
+	grid := float32(gridWidth) / float32(gridHeight)
+	target := float32(targetWidth) / float32(targetHeight)
+	if target > grid {
+		return Vec2{grid / target, 1}
+	}
+	return Vec2{1, target / grid}
+}
+
+// View is where a camera looks at grid space from: Centre is the grid space
+// point in the middle of the target, Zoom how many times larger than the
+// whole grid it is drawn and Scale the Letterbox the grid is fitted with.
+type View struct {
+	Centre Vec2
+	Zoom   float32
+	Scale  Vec2
+}
+
+// scale is how far a unit of grid space takes up in clip space.
+func (v View) scale() Vec2 {
+	return Vec2{v.Zoom * v.Scale[0], v.Zoom * v.Scale[1]}
+}
+
+// Matrix takes grid space to clip space, column by column as WGSL's
+// mat4x4 has it.
+func (v View) Matrix() [16]float32 {
+	s := v.scale()
+	return [16]float32{
+		s[0], 0, 0, 0,
+		0, s[1], 0, 0,
+		0, 0, 1, 0,
+		-s[0] * v.Centre[0], -s[1] * v.Centre[1], 0, 1,
+	}
+}
+
+// GridToClip takes grid space point p to clip space.
+func (v View) GridToClip(p Vec2) Vec2 {
+	s := v.scale()
+	return Vec2{(p[0] - v.Centre[0]) * s[0], (p[1] - v.Centre[1]) * s[1]}
+}
+
+// ClipToGrid takes clip space point p to grid space, the inverse of
+// GridToClip.
+func (v View) ClipToGrid(p Vec2) Vec2 {
+	s := v.scale()
+	return Vec2{p[0]/s[0] + v.Centre[0], p[1]/s[1] + v.Centre[1]}
+}
+
+// Clamp keeps the zoom between 1 and maxZoom and the view inside the grid,
+// or centred along an edge the target is longer than.
+func (v View) Clamp(maxZoom float32) View {
+	v.Zoom = min(max(v.Zoom, 1), max(maxZoom, 1))
+	s := v.scale()
+	for i := range v.Centre {
+		edge := max(1-1/s[i], 0)
+		v.Centre[i] = min(max(v.Centre[i], -edge), edge)
+	}
+	return v
+}
+
+// Dragged is the view moved so that the grid follows a drag of delta in
+// clip space.
+func (v View) Dragged(delta Vec2) View {
+	s := v.scale()
+	v.Centre = Vec2{v.Centre[0] - delta[0]/s[0], v.Centre[1] - delta[1]/s[1]}
+	return v
+}
+
+// ZoomedAbout is the view at zoom with grid space point anchor at clip
+// space point at.
+func (v View) ZoomedAbout(anchor, at Vec2, zoom float32) View {
+	v.Zoom = zoom
+	s := v.scale()
+	v.Centre = Vec2{anchor[0] - at[0]/s[0], anchor[1] - at[1]/s[1]}
+	return v
+}
+
+// Tile is a piece of an image too large to draw at once, X and Y from its
+// top left corner.
+type Tile struct {
+	X, Y, Width, Height int
+}
+
+// Tiles cuts a width by height image into tiles of at most size across,
+// row by row from the top left.
+func Tiles(width, height, size int) []Tile {
+	if size <= 0 {
+		return nil
+	}
+	var tiles []Tile
+	for y := 0; y < height; y += size {
+		for x := 0; x < width; x += size {
+			tiles = append(tiles, Tile{x, y, min(size, width-x), min(size, height-y)})
+		}
+	}
+	return tiles
+}
+
+// Viewport is where to put the viewport, in the tile's own pixels, for the
+// whole of a width by height image to be drawn through it with only the
+// tile's part landing on the tile.
+func (t Tile) Viewport(width, height int) (x, y, w, h float32) {
+	return float32(-t.X), float32(-t.Y), float32(width), float32(height)
+}
@@ -0,0 +1,281 @@
+package viewmath
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func near(a, b Vec2) bool {
+	const epsilon = 1e-5
+	return math.Abs(float64(a[0]-b[0])) < epsilon && math.Abs(float64(a[1]-b[1])) < epsilon
+}
+
+func TestCursorToClip(t *testing.T) {
+	tests := []struct {
+		name          string
+		x, y          float64
+		width, height int
+		want          Vec2
+		ok            bool
+	}{
+		{"top left", 0, 0, 800, 600, Vec2{-1, 1}, true},
+		{"bottom right", 800, 600, 800, 600, Vec2{1, -1}, true},
+		{"centre", 400, 300, 800, 600, Vec2{0, 0}, true},
+		{"tall window", 100, 900, 200, 1200, Vec2{0, -0.5}, true},
+		{"left of the window", -200, 300, 800, 600, Vec2{-1.5, 0}, true},
+		{"empty window", 10, 10, 0, 600, Vec2{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := CursorToClip(tt.x, tt.y, tt.width, tt.height)
+		if ok != tt.ok || !near(got, tt.want) {
+			t.Errorf("%s: CursorToClip(%g, %g, %d, %d) = %v, %v, want %v, %v",
+				tt.name, tt.x, tt.y, tt.width, tt.height, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCursorDelta(t *testing.T) {
+	tests := []struct {
+		dx, dy        float64
+		width, height int
+		want          Vec2
+		ok            bool
+	}{
+		{400, 300, 800, 600, Vec2{1, -1}, true},
+		{-80, 60, 800, 600, Vec2{-0.2, -0.2}, true},
+		{10, 10, 800, 0, Vec2{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := CursorDelta(tt.dx, tt.dy, tt.width, tt.height)
+		if ok != tt.ok || !near(got, tt.want) {
+			t.Errorf("CursorDelta(%g, %g, %d, %d) = %v, %v, want %v, %v",
+				tt.dx, tt.dy, tt.width, tt.height, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGridToCell(t *testing.T) {
+	tests := []struct {
+		name          string
+		p             Vec2
+		width, height int
+		x, y          int
+		ok            bool
+	}{
+		{"bottom left corner", Vec2{-1, -1}, 8, 4, 0, 0, true},
+		{"just inside the top right", Vec2{0.999, 0.999}, 8, 4, 7, 3, true},
+		{"top right edge", Vec2{1, 1}, 8, 4, 0, 0, false},
+		{"centre of a wide grid", Vec2{0, 0}, 8, 4, 4, 2, true},
+		{"centre of a tall grid", Vec2{0, 0}, 3, 5, 1, 2, true},
+		{"left of the grid", Vec2{-1.01, 0}, 8, 4, 0, 0, false},
+		{"below the grid", Vec2{0, -1.5}, 8, 4, 0, 0, false},
+	}
+	for _, tt := range tests {
+		x, y, ok := GridToCell(tt.p, tt.width, tt.height)
+		if ok != tt.ok || ok && (x != tt.x || y != tt.y) {
+			t.Errorf("%s: GridToCell(%v, %d, %d) = %d, %d, %v, want %d, %d, %v",
+				tt.name, tt.p, tt.width, tt.height, x, y, ok, tt.x, tt.y, tt.ok)
+		}
+	}
+}
+
+// TestCellRoundTrip takes the centre of every cell to clip space through a
+// view and back, and checks it lands in the same cell.
+func TestCellRoundTrip(t *testing.T) {
+	views := []struct {
+		name string
+		view View
+	}{
+		{"whole grid", View{Zoom: 1, Scale: Vec2{1, 1}}},
+		{"letterboxed", View{Zoom: 1, Scale: Letterbox(16, 9, 800, 800)}},
+		{"zoomed off centre", View{Centre: Vec2{0.3, -0.6}, Zoom: 4, Scale: Vec2{1, 0.5}}},
+	}
+	grids := [][2]int{{1, 1}, {7, 7}, {16, 9}, {3, 40}}
+	for _, v := range views {
+		for _, g := range grids {
+			width, height := g[0], g[1]
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					clip := v.view.GridToClip(CellToGrid(x, y, width, height))
+					gx, gy, ok := GridToCell(v.view.ClipToGrid(clip), width, height)
+					if !ok || gx != x || gy != y {
+						t.Fatalf("%s, %dx%d: cell %d, %d came back as %d, %d, %v", v.name, width, height, x, y, gx, gy, ok)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestLetterbox(t *testing.T) {
+	tests := []struct {
+		name                      string
+		gridWidth, gridHeight     int
+		targetWidth, targetHeight int
+		want                      Vec2
+	}{
+		{"same shape", 128, 128, 600, 600, Vec2{1, 1}},
+		{"square grid, wide target", 128, 128, 800, 400, Vec2{0.5, 1}},
+		{"square grid, tall target", 128, 128, 400, 800, Vec2{1, 0.5}},
+		{"wide grid, square target", 200, 100, 500, 500, Vec2{1, 0.5}},
+		{"tall grid, wide target", 100, 400, 800, 400, Vec2{0.125, 1}},
+		{"same aspect, other size", 320, 180, 1280, 720, Vec2{1, 1}},
+		{"empty grid", 0, 128, 800, 600, Vec2{1, 1}},
+		{"empty target", 128, 128, 800, 0, Vec2{1, 1}},
+	}
+	for _, tt := range tests {
+		got := Letterbox(tt.gridWidth, tt.gridHeight, tt.targetWidth, tt.targetHeight)
+		if !near(got, tt.want) {
+			t.Errorf("%s: Letterbox(%d, %d, %d, %d) = %v, want %v",
+				tt.name, tt.gridWidth, tt.gridHeight, tt.targetWidth, tt.targetHeight, got, tt.want)
+		}
+		// The fitted grid's cells are as wide as they are tall on the
+		// target.
+		if tt.gridWidth > 0 && tt.gridHeight > 0 && tt.targetWidth > 0 && tt.targetHeight > 0 {
+			across := got[0] * float32(tt.targetWidth) / float32(tt.gridWidth)
+			down := got[1] * float32(tt.targetHeight) / float32(tt.gridHeight)
+			if math.Abs(float64(across-down)) > 1e-4 {
+				t.Errorf("%s: cells are %g by %g pixels", tt.name, across, down)
+			}
+		}
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	views := []View{
+		{Zoom: 1, Scale: Vec2{1, 1}},
+		{Centre: Vec2{0.25, -0.5}, Zoom: 3, Scale: Vec2{0.5, 1}},
+	}
+	points := []Vec2{{0, 0}, {1, 1}, {-0.7, 0.2}}
+	for _, v := range views {
+		m := v.Matrix()
+		for _, p := range points {
+			got := Vec2{m[0]*p[0] + m[4]*p[1] + m[12], m[1]*p[0] + m[5]*p[1] + m[13]}
+			if want := v.GridToClip(p); !near(got, want) {
+				t.Errorf("%+v: the matrix takes %v to %v, GridToClip to %v", v, p, got, want)
+			}
+		}
+	}
+}
+
+func TestDragged(t *testing.T) {
+	tests := []struct {
+		name  string
+		view  View
+		delta Vec2
+		want  Vec2
+	}{
+		{"no zoom", View{Zoom: 1, Scale: Vec2{1, 1}}, Vec2{0.5, 0}, Vec2{-0.5, 0}},
+		{"zoomed in", View{Zoom: 4, Scale: Vec2{1, 1}}, Vec2{0.5, -1}, Vec2{-0.125, 0.25}},
+		{"letterboxed", View{Centre: Vec2{0.1, 0.1}, Zoom: 2, Scale: Vec2{0.5, 1}}, Vec2{-0.5, 0.5}, Vec2{0.6, -0.15}},
+	}
+	for _, tt := range tests {
+		got := tt.view.Dragged(tt.delta)
+		if !near(got.Centre, tt.want) || got.Zoom != tt.view.Zoom {
+			t.Errorf("%s: Dragged(%v) = %+v, want the centre at %v", tt.name, tt.delta, got, tt.want)
+		}
+		// Whatever was under the cursor stays under it.
+		at := Vec2{0.2, 0.3}
+		before := tt.view.ClipToGrid(at)
+		after := got.GridToClip(before)
+		if want := (Vec2{at[0] + tt.delta[0], at[1] + tt.delta[1]}); !near(after, want) {
+			t.Errorf("%s: %v moved to %v, want %v", tt.name, at, after, want)
+		}
+	}
+}
+
+func TestZoomedAbout(t *testing.T) {
+	tests := []struct {
+		name   string
+		view   View
+		anchor Vec2
+		at     Vec2
+		zoom   float32
+	}{
+		{"in about the centre", View{Zoom: 1, Scale: Vec2{1, 1}}, Vec2{0, 0}, Vec2{0, 0}, 2},
+		{"in about a corner", View{Zoom: 1, Scale: Vec2{1, 1}}, Vec2{0.5, 0.5}, Vec2{0.5, 0.5}, 8},
+		{"out, letterboxed", View{Centre: Vec2{0.2, 0}, Zoom: 6, Scale: Vec2{1, 0.75}}, Vec2{0.3, -0.1}, Vec2{0.6, -0.075}, 3},
+	}
+	for _, tt := range tests {
+		got := tt.view.ZoomedAbout(tt.anchor, tt.at, tt.zoom)
+		if got.Zoom != tt.zoom || got.Scale != tt.view.Scale {
+			t.Errorf("%s: ZoomedAbout = %+v, want zoom %g", tt.name, got, tt.zoom)
+		}
+		if clip := got.GridToClip(tt.anchor); !near(clip, tt.at) {
+			t.Errorf("%s: the anchor %v is at %v, want %v", tt.name, tt.anchor, clip, tt.at)
+		}
+	}
+}
+
+func TestClamp(t *testing.T) {
+	tests := []struct {
+		name    string
+		view    View
+		maxZoom float32
+		want    View
+	}{
+		{"zoom below 1", View{Centre: Vec2{0.5, 0.5}, Zoom: 0.5, Scale: Vec2{1, 1}}, 8, View{Zoom: 1, Scale: Vec2{1, 1}}},
+		{"zoom past the most", View{Zoom: 20, Scale: Vec2{1, 1}}, 8, View{Zoom: 8, Scale: Vec2{1, 1}}},
+		{"centre past an edge", View{Centre: Vec2{0.9, -0.9}, Zoom: 2, Scale: Vec2{1, 1}}, 8, View{Centre: Vec2{0.5, -0.5}, Zoom: 2, Scale: Vec2{1, 1}}},
+		{"inside", View{Centre: Vec2{0.25, -0.1}, Zoom: 4, Scale: Vec2{1, 1}}, 8, View{Centre: Vec2{0.25, -0.1}, Zoom: 4, Scale: Vec2{1, 1}}},
+		// Letterboxed across at zoom 2, the grid is exactly as wide as the
+		// target, so it is kept centred along x.
+		{"letterboxed", View{Centre: Vec2{0.4, 0.4}, Zoom: 2, Scale: Vec2{0.5, 1}}, 8, View{Centre: Vec2{0, 0.4}, Zoom: 2, Scale: Vec2{0.5, 1}}},
+		{"max zoom below 1", View{Zoom: 3, Scale: Vec2{1, 1}}, 0.5, View{Zoom: 1, Scale: Vec2{1, 1}}},
+	}
+	for _, tt := range tests {
+		got := tt.view.Clamp(tt.maxZoom)
+		if got.Zoom != tt.want.Zoom || !near(got.Centre, tt.want.Centre) || got.Scale != tt.want.Scale {
+			t.Errorf("%s: Clamp(%g) = %+v, want %+v", tt.name, tt.maxZoom, got, tt.want)
+		}
+	}
+}
+
+func TestTiles(t *testing.T) {
+	tests := []struct {
+		name                string
+		width, height, size int
+		want                []Tile
+	}{
+		{"one tile", 100, 50, 128, []Tile{{0, 0, 100, 50}}},
+		{"exact fit", 200, 100, 100, []Tile{{0, 0, 100, 100}, {100, 0, 100, 100}}},
+		{"wide, ragged", 250, 100, 100, []Tile{{0, 0, 100, 100}, {100, 0, 100, 100}, {200, 0, 50, 100}}},
+		{"tall, ragged", 60, 150, 64, []Tile{
+			{0, 0, 60, 64},
+			{0, 64, 60, 64},
+			{0, 128, 60, 22},
+		}},
+		{"no size", 100, 100, 0, nil},
+		{"empty image", 0, 100, 64, nil},
+	}
+	for _, tt := range tests {
+		got := Tiles(tt.width, tt.height, tt.size)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Tiles(%d, %d, %d) = %v, want %v", tt.name, tt.width, tt.height, tt.size, got, tt.want)
+		}
+	}
+}
+
+// TestTileViewport checks that the viewport of every tile puts the tile's
+// part of the image, at negative offsets for all but the first, on the tile.
+func TestTileViewport(t *testing.T) {
+	images := [][3]int{{300, 200, 128}, {64, 500, 100}, {1000, 30, 256}}
+	for _, im := range images {
+		width, height := im[0], im[1]
+		covered := 0
+		for _, tile := range Tiles(width, height, im[2]) {
+			x, y, w, h := tile.Viewport(width, height)
+			if x != float32(-tile.X) || y != float32(-tile.Y) || w != float32(width) || h != float32(height) {
+				t.Errorf("%dx%d: tile %+v has the viewport %g, %g, %g, %g", width, height, tile, x, y, w, h)
+			}
+			if x > 0 || y > 0 || x+w < float32(tile.Width) || y+h < float32(tile.Height) {
+				t.Errorf("%dx%d: tile %+v is not all inside its viewport", width, height, tile)
+			}
+			covered += tile.Width * tile.Height
+		}
+		if covered != width*height {
+			t.Errorf("%dx%d: the tiles cover %d pixels", width, height, covered)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxWebSocketMessage is the largest message read from a client, far more
+// than any parameter change needs.
+const maxWebSocketMessage = 1 << 16
+
+// webSocketGUID is what RFC 6455 has the server hash with the client's key
+// to accept the connection.
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// webSocket is the server's end of a WebSocket connection, enough of RFC
+// 6455 for text messages: no extensions, and messages are read whole.
+type webSocket struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	// mu keeps frames from different goroutines from interleaving.
+	mu sync.Mutex
+}
+
+// upgradeWebSocket takes over r's connection as a WebSocket, if it asks to
+// be one from a page of the dashboard's own. Browsers let any site open
+// WebSockets anywhere, saying only where from in Origin.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*webSocket, error) {
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin WebSockets aren't accepted", http.StatusForbidden)
+		return nil, errors.New("cross-origin WebSocket")
+	}
+	if !headerHas(r.Header, "Connection", "upgrade") || !headerHas(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "expected a WebSocket upgrade", http.StatusBadRequest)
+		return nil, errors.New("not a WebSocket upgrade")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported WebSocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("missing Sec-WebSocket-Key")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "can't take over the connection", http.StatusInternalServerError)
+		return nil, errors.New("connection can't be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &webSocket{conn: conn, reader: rw.Reader}, nil
+}
+
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// read returns the next text message, answering pings on the way. It
+// returns io.EOF once the client has closed the connection.
+func (ws *webSocket) read() (string, error) {
+	var message []byte
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(ws.reader, head[:]); err != nil {
+			return "", err
+		}
+		fin, opcode := head[0]&0x80 != 0, head[0]&0x0f
+		if head[1]&0x80 == 0 {
+			return "", errors.New("client frames must be masked")
+		}
+		size := uint64(head[1] & 0x7f)
+		switch size {
+		case 126:
+			var b [2]byte
+			if _, err := io.ReadFull(ws.reader, b[:]); err != nil {
+				return "", err
+			}
+			size = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			if _, err := io.ReadFull(ws.reader, b[:]); err != nil {
+				return "", err
+			}
+			size = binary.BigEndian.Uint64(b[:])
+		}
+		if size > maxWebSocketMessage || uint64(len(message))+size > maxWebSocketMessage {
+			return "", fmt.Errorf("message longer than %d bytes", maxWebSocketMessage)
+		}
+		var mask [4]byte
+		if _, err := io.ReadFull(ws.reader, mask[:]); err != nil {
+			return "", err
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(ws.reader, payload); err != nil {
+			return "", err
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch opcode {
+		case 0x0, 0x1:
+			message = append(message, payload...)
+			if fin {
+				return string(message), nil
+			}
+		case 0x8:
+			ws.write(0x8, payload)
+			return "", io.EOF
+		case 0x9:
+			if err := ws.write(0xa, payload); err != nil {
+				return "", err
+			}
+		case 0xa:
+		default:
+			return "", fmt.Errorf("unexpected WebSocket opcode %d", opcode)
+		}
+	}
+}
+
+// writeText sends message as a single text frame.
+func (ws *webSocket) writeText(message []byte) error {
+	return ws.write(0x1, message)
+}
+
+func (ws *webSocket) write(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	_, err := ws.conn.Write(append(frame, payload...))
+	return err
+}
+
+func (ws *webSocket) Close() error {
+	return ws.conn.Close()
+}